@@ -0,0 +1,108 @@
+// Command clone copia colecciones del proyecto configurado a un proyecto de staging, con
+// muestreo opcional y redacción de datos personales.
+//
+//	go run ./cmd/clone -target my-staging -collections users,orders -sample 200 \
+//		-redact users.email=email,users.phone=mask,users.birth_date=year,*.ip_address=drop \
+//		-k-anonymity users=5:birth_date,zip_code
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/anonymize"
+	"github.com/andrescris/firestore/lib/firebase/clone"
+)
+
+// rulesByName reglas disponibles desde la línea de comandos
+var rulesByName = map[string]clone.Rule{
+	"drop":  clone.RedactDrop,
+	"hash":  clone.RedactHash,
+	"email": clone.RedactEmail,
+	"mask":  clone.RedactMask,
+	"year":  anonymize.GeneralizeDate(anonymize.Year),
+	"month": anonymize.GeneralizeDate(anonymize.Month),
+	"day":   anonymize.GeneralizeDate(anonymize.Day),
+	"age10": anonymize.Bucket(10),
+	"zip3":  anonymize.MaskKeeping(3, 0),
+}
+
+func main() {
+	target := flag.String("target", "", "proyecto destino")
+	database := flag.String("database", "", "base de datos destino (por defecto \"(default)\")")
+	collections := flag.String("collections", "", "colecciones separadas por comas")
+	sample := flag.Int("sample", 0, "documentos por colección (0 = todos)")
+	subcollections := flag.Bool("subcollections", false, "copiar también las subcolecciones")
+	overwrite := flag.Bool("overwrite", false, "reemplazar los documentos que ya existen en el destino")
+	dryRun := flag.Bool("dry-run", false, "recorrer sin escribir")
+	redactions := flag.String("redact", "", "reglas colección.campo=regla separadas por comas (drop, hash, email, mask, year, month, day, age10, zip3)")
+	kAnonymity := flag.String("k-anonymity", "", "colección=k:campo,campo con los cuasi-identificadores (separar colecciones con ';')")
+	flag.Parse()
+
+	for _, spec := range strings.Split(*redactions, ",") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		path, ruleName, ok := strings.Cut(strings.TrimSpace(spec), "=")
+		collection, field, hasField := strings.Cut(path, ".")
+		rule, known := rulesByName[ruleName]
+		if !ok || !hasField || !known {
+			log.Fatalf("Regla de redacción inválida: %s", spec)
+		}
+		clone.RegisterRedaction(collection, field, rule)
+	}
+
+	for _, spec := range strings.Split(*kAnonymity, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		collection, config, ok := strings.Cut(strings.TrimSpace(spec), "=")
+		kValue, fields, hasFields := strings.Cut(config, ":")
+		k, err := strconv.Atoi(kValue)
+		if !ok || !hasFields || err != nil || k < 2 {
+			log.Fatalf("Configuración de k-anonimato inválida: %s", spec)
+		}
+		anonymize.SetKAnonymity(collection, anonymize.KAnonymity{K: k, QuasiIdentifiers: strings.Split(fields, ",")})
+	}
+
+	if err := firebase.InitFirebaseFromEnv(); err != nil {
+		log.Fatalf("Error initializing Firebase: %v", err)
+	}
+	defer firebase.Close()
+
+	var names []string
+	for _, name := range strings.Split(*collections, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	report, err := clone.Clone(context.Background(), clone.Options{
+		Collections:      names,
+		TargetProjectID:  *target,
+		TargetDatabaseID: *database,
+		SampleSize:       *sample,
+		Subcollections:   *subcollections,
+		Overwrite:        *overwrite,
+		DryRun:           *dryRun,
+	})
+	if report != nil {
+		paths := make([]string, 0, len(report.Copied))
+		for path := range report.Copied {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			log.Printf("📄 %s: %d documentos", path, report.Copied[path])
+		}
+		log.Printf("⏭️  Omitidos: %d · 🔒 Campos redactados: %d · 👥 Registros generalizados (k-anonimato): %d", report.Skipped, report.RedactedFields, report.SuppressedRecords)
+	}
+	if err != nil {
+		log.Fatalf("Error cloning collections: %v", err)
+	}
+}
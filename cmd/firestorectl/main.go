@@ -0,0 +1,177 @@
+// Command firestorectl agrupa tareas de mantenimiento sobre el proyecto configurado.
+//
+//	go run ./cmd/firestorectl doctor                       # diagnostica e imprime el plan
+//	go run ./cmd/firestorectl doctor -checks expired_sessions,expired_otps -apply
+//	go run ./cmd/firestorectl doctor -collections orders,users -limit 50 -json
+//	go run ./cmd/firestorectl reconcile -dry-run           # diferencias entre Auth y users/user_claims
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/doctor"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "doctor":
+		runDoctor(os.Args[2:])
+	case "reconcile":
+		runReconcile(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "uso: firestorectl doctor [-checks a,b] [-collections a,b] [-limit n] [-size-threshold bytes] [-apply] [-json]")
+	fmt.Fprintln(os.Stderr, "     firestorectl reconcile [-dry-run] [-delete-orphaned-profiles] [-json]")
+	os.Exit(2)
+}
+
+func runDoctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	checks := flags.String("checks", "", "verificaciones separadas por comas ("+strings.Join(doctor.AllChecks, ", ")+"; por defecto todas)")
+	collections := flags.String("collections", "", "colecciones revisadas por missing_created_at y oversized (por defecto todas)")
+	limit := flags.Int("limit", 0, "máximo de hallazgos por verificación (0 = sin límite)")
+	sizeThreshold := flags.Int("size-threshold", 0, "tamaño estimado en bytes desde el que se reporta un documento (por defecto 90% de 1 MiB)")
+	apply := flags.Bool("apply", false, "aplicar el plan de corrección")
+	asJSON := flags.Bool("json", false, "imprimir el plan como JSON")
+	flags.Parse(args)
+
+	if err := firebase.InitFirebaseFromEnv(); err != nil {
+		log.Fatalf("Error initializing Firebase: %v", err)
+	}
+	defer firebase.Close()
+
+	ctx := context.Background()
+	plan, err := doctor.Diagnose(ctx, doctor.Options{
+		Checks:        splitList(*checks),
+		Collections:   splitList(*collections),
+		SizeThreshold: *sizeThreshold,
+		Limit:         *limit,
+	})
+	if err != nil {
+		log.Fatalf("Error running diagnostics: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(plan); err != nil {
+			log.Fatalf("Error encoding plan: %v", err)
+		}
+	} else {
+		printPlan(plan)
+	}
+
+	if !*apply || len(plan.Findings) == 0 {
+		return
+	}
+	report := doctor.Apply(ctx, plan)
+	log.Printf("🩺 Correcciones aplicadas: %d · ⏭️  Sin corrección automática: %d · 🔁 Ya no aplican: %d · ❌ Fallidas: %d", report.Applied, report.Skipped, report.Stale, len(report.Failed))
+	paths := make([]string, 0, len(report.Failed))
+	for path := range report.Failed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		log.Printf("   %s: %s", path, report.Failed[path])
+	}
+	if len(report.Failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runReconcile(args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "solo reportar las diferencias")
+	deleteProfiles := flags.Bool("delete-orphaned-profiles", false, "eliminar los perfiles sin usuario en Auth")
+	asJSON := flags.Bool("json", false, "imprimir el reporte como JSON")
+	flags.Parse(args)
+
+	if err := firebase.InitFirebaseFromEnv(); err != nil {
+		log.Fatalf("Error initializing Firebase: %v", err)
+	}
+	defer firebase.Close()
+
+	report, err := auth.ReconcileMirrors(context.Background(), auth.ReconcileOptions{
+		DryRun:                 *dryRun,
+		DeleteOrphanedProfiles: *deleteProfiles,
+	})
+	if report != nil {
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				log.Fatalf("Error encoding report: %v", err)
+			}
+		} else {
+			for _, drift := range report.Drift {
+				note := ""
+				if drift.Manual {
+					note = "  (revisión manual)"
+				}
+				fmt.Printf("  %-18s %s  %s%s\n", drift.Kind, drift.UID, strings.Join(drift.Fields, ","), note)
+			}
+			log.Printf("👥 Usuarios: %d · 📄 Perfiles: %d · 🔑 Claims: %d · ⚠️  Diferencias: %d · 🔧 Corregidas: %d · ❌ Fallidas: %d",
+				report.Users, report.Profiles, report.Claims, len(report.Drift), report.Repaired, len(report.Failed))
+			for uid, failure := range report.Failed {
+				log.Printf("   %s: %s", uid, failure)
+			}
+		}
+	}
+	if err != nil {
+		log.Fatalf("Error reconciling mirrors: %v", err)
+	}
+	if report != nil && len(report.Failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printPlan imprime los hallazgos agrupados por verificación
+func printPlan(plan *doctor.Plan) {
+	byCheck := map[string][]doctor.Finding{}
+	for _, finding := range plan.Findings {
+		byCheck[finding.Check] = append(byCheck[finding.Check], finding)
+	}
+	for _, check := range doctor.AllChecks {
+		scanned, ran := plan.Scanned[check]
+		findings := byCheck[check]
+		if !ran && len(findings) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s: %d hallazgos (%d documentos revisados)\n", check, len(findings), scanned)
+		for _, finding := range findings {
+			fmt.Printf("  %-16s %s/%s  %s\n", finding.Fix, finding.Collection, finding.DocumentID, finding.Problem)
+		}
+	}
+	if len(plan.Truncated) > 0 {
+		fmt.Printf("\n⚠️  Resultados truncados por -limit en: %s\n", strings.Join(plan.Truncated, ", "))
+	}
+	if len(plan.Findings) == 0 {
+		fmt.Println("✅ No se encontraron problemas")
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,290 @@
+// Command loadtest ejercita las operaciones del paquete (CRUD de documentos, consultas y
+// flujo OTP) con concurrencia configurable y reporta percentiles de latencia.
+//
+// Usar contra el emulador (FIRESTORE_EMULATOR_HOST, FIREBASE_AUTH_EMULATOR_HOST) o un
+// proyecto de pruebas; nunca contra producción.
+//
+//	go run ./cmd/loadtest -scenario all -concurrency 20 -duration 30s
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{latencies: map[string][]time.Duration{}, errors: map[string]int{}}
+}
+
+// measure ejecuta fn y registra su latencia bajo op
+func (r *recorder) measure(op string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies[op] = append(r.latencies[op], elapsed)
+	if err != nil {
+		r.errors[op]++
+	}
+	return err
+}
+
+func (r *recorder) report(elapsed time.Duration) {
+	ops := make([]string, 0, len(r.latencies))
+	for op := range r.latencies {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	fmt.Printf("\n%-18s %8s %8s %9s %9s %9s %9s %9s\n", "operación", "total", "errores", "ops/s", "p50", "p90", "p99", "máx")
+	fmt.Println(strings.Repeat("-", 90))
+	for _, op := range ops {
+		samples := r.latencies[op]
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		fmt.Printf("%-18s %8d %8d %9.1f %9s %9s %9s %9s\n",
+			op, len(samples), r.errors[op], float64(len(samples))/elapsed.Seconds(),
+			percentile(samples, 0.50), percentile(samples, 0.90), percentile(samples, 0.99), samples[len(samples)-1].Round(time.Microsecond))
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index].Round(time.Microsecond)
+}
+
+type runner struct {
+	rec        *recorder
+	collection string
+	runID      string
+}
+
+// crud crea, lee, actualiza y elimina un documento
+func (r *runner) crud(ctx context.Context, worker, iteration int) {
+	var id string
+	err := r.rec.measure("create", func() (err error) {
+		id, err = firestore.CreateDocument(ctx, r.collection, map[string]interface{}{
+			"run_id":    r.runID,
+			"worker":    worker,
+			"iteration": iteration,
+			"payload":   strings.Repeat("x", 256),
+		})
+		return err
+	})
+	if err != nil {
+		return
+	}
+	r.rec.measure("get", func() error {
+		_, err := firestore.GetDocument(ctx, r.collection, id)
+		return err
+	})
+	r.rec.measure("update", func() error {
+		return firestore.UpdateDocument(ctx, r.collection, id, map[string]interface{}{"iteration": iteration + 1})
+	})
+	r.rec.measure("delete", func() error {
+		return firestore.DeleteDocument(ctx, r.collection, id)
+	})
+}
+
+// query consulta documentos del propio worker
+func (r *runner) query(ctx context.Context, worker, iteration int) {
+	r.rec.measure("query", func() error {
+		_, err := firestore.QueryDocuments(ctx, r.collection+"_seed", firebase.QueryOptions{
+			Filters: []firebase.QueryFilter{firebase.Where("bucket", firebase.OpEqual, int64(iteration%10))},
+			OrderBy: "created_at",
+			Limit:   20,
+		})
+		return err
+	})
+	r.rec.measure("count", func() error {
+		_, err := firestore.CountDocuments(ctx, r.collection+"_seed", []firebase.QueryFilter{
+			firebase.Where("bucket", firebase.OpEqual, int64(worker%10)),
+		})
+		return err
+	})
+}
+
+// otp recorre el flujo completo: alta de usuario, solicitud de OTP, login y baja
+func (r *runner) otp(ctx context.Context, worker, iteration int) {
+	email := fmt.Sprintf("loadtest-%s-%d-%d@example.test", r.runID, worker, iteration)
+	var user *firebase.UserRecord
+	err := r.rec.measure("create_user", func() (err error) {
+		user, err = auth.CreateUser(ctx, firebase.CreateUserRequest{Email: email})
+		return err
+	})
+	if err != nil {
+		return
+	}
+	defer auth.DeleteUser(ctx, user.UID)
+
+	err = r.rec.measure("request_otp", func() error {
+		resp, err := auth.RequestOTP(ctx, firebase.RequestOTPRequest{Email: email})
+		if err == nil && !resp.Success {
+			err = fmt.Errorf("%s", resp.Message)
+		}
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	docs, err := firestore.QueryDocuments(ctx, "user_otps", firebase.QueryOptions{
+		Filters:  []firebase.QueryFilter{firebase.Where("email", firebase.OpEqual, email)},
+		OrderBy:  "created_at",
+		OrderDir: "desc",
+		Limit:    1,
+	})
+	if err != nil || len(docs) == 0 {
+		return
+	}
+	code, _ := docs[0].GetString("otp")
+
+	r.rec.measure("login_otp", func() error {
+		resp, err := auth.LoginWithOTP(ctx, firebase.LoginWithOTPRequest{Email: email, OTP: code})
+		if err == nil && !resp.Success {
+			err = fmt.Errorf("%s", resp.Message)
+		}
+		if err == nil {
+			_, err = auth.ValidateSession(ctx, resp.SessionID)
+		}
+		return err
+	})
+}
+
+func (r *runner) seed(ctx context.Context, count int) error {
+	for start := 0; start < count; start += 500 {
+		var operations []firebase.BatchOperation
+		for i := start; i < start+500 && i < count; i++ {
+			operations = append(operations, firebase.BatchOperation{
+				Type:       "create",
+				Collection: r.collection + "_seed",
+				Data:       map[string]interface{}{"run_id": r.runID, "bucket": int64(i % 10)},
+			})
+		}
+		if err := firestore.BatchWrite(ctx, operations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *runner) cleanup(ctx context.Context) {
+	for _, collection := range []string{r.collection, r.collection + "_seed"} {
+		docs, err := firestore.QueryDocuments(ctx, collection, firebase.QueryOptions{
+			Filters: []firebase.QueryFilter{firebase.Where("run_id", firebase.OpEqual, r.runID)},
+		})
+		if err != nil {
+			log.Printf("⚠️  Error listing %s for cleanup: %v", collection, err)
+			continue
+		}
+		for start := 0; start < len(docs); start += 500 {
+			var operations []firebase.BatchOperation
+			for _, doc := range docs[start:min(start+500, len(docs))] {
+				operations = append(operations, firebase.BatchOperation{Type: "delete", Collection: collection, DocumentID: doc.ID})
+			}
+			if err := firestore.BatchWrite(ctx, operations); err != nil {
+				log.Printf("⚠️  Error cleaning up %s: %v", collection, err)
+			}
+		}
+	}
+}
+
+func main() {
+	scenario := flag.String("scenario", "all", "escenarios separados por comas: crud, query, otp, all")
+	concurrency := flag.Int("concurrency", 10, "workers concurrentes")
+	duration := flag.Duration("duration", 30*time.Second, "duración de la prueba")
+	iterations := flag.Int("iterations", 0, "iteraciones por worker (0 = hasta -duration)")
+	collection := flag.String("collection", "loadtest_docs", "colección usada por los escenarios de documentos")
+	seedDocs := flag.Int("seed", 1000, "documentos de semilla para el escenario query")
+	cleanup := flag.Bool("cleanup", true, "eliminar los documentos creados al terminar")
+	flag.Parse()
+
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		log.Println("⚠️  FIRESTORE_EMULATOR_HOST no está definido: la prueba se ejecutará contra el proyecto configurado")
+	}
+
+	if err := firebase.InitFirebaseFromEnv(); err != nil {
+		log.Fatalf("Error initializing Firebase: %v", err)
+	}
+	defer firebase.Close()
+
+	ctx := context.Background()
+	r := &runner{rec: newRecorder(), collection: *collection, runID: fmt.Sprintf("%d", time.Now().UnixNano())}
+
+	enabled := map[string]bool{}
+	for _, name := range strings.Split(*scenario, ",") {
+		enabled[strings.TrimSpace(name)] = true
+	}
+	if enabled["all"] {
+		enabled = map[string]bool{"crud": true, "query": true, "otp": true}
+	}
+
+	var steps []func(context.Context, int, int)
+	if enabled["crud"] {
+		steps = append(steps, r.crud)
+	}
+	if enabled["query"] {
+		log.Printf("🌱 Sembrando %d documentos...", *seedDocs)
+		if err := r.seed(ctx, *seedDocs); err != nil {
+			log.Fatalf("Error seeding documents: %v", err)
+		}
+		steps = append(steps, r.query)
+	}
+	if enabled["otp"] {
+		steps = append(steps, r.otp)
+	}
+	if len(steps) == 0 {
+		log.Fatalf("Escenario desconocido: %s", *scenario)
+	}
+
+	log.Printf("🚀 Ejecutando %s con %d workers...", *scenario, *concurrency)
+	deadline := time.Now().Add(*duration)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < *concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				if *iterations > 0 && i >= *iterations {
+					return
+				}
+				if *iterations == 0 && time.Now().After(deadline) {
+					return
+				}
+				for _, step := range steps {
+					step(ctx, worker, i)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	r.rec.report(time.Since(start))
+
+	if *cleanup {
+		r.cleanup(ctx)
+	}
+}
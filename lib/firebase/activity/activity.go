@@ -0,0 +1,157 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+const (
+	// ActivitiesCollection colección con el registro canónico de eventos
+	ActivitiesCollection = "activities"
+	// FollowsCollection colección con las relaciones seguidor -> seguido
+	FollowsCollection = "follows"
+)
+
+// Activity evento estructurado: Actor Verb Object (sobre Target)
+type Activity struct {
+	Actor  string                 `json:"actor"`
+	Verb   string                 `json:"verb"`
+	Object string                 `json:"object"`
+	Target string                 `json:"target,omitempty"`
+	Extra  map[string]interface{} `json:"extra,omitempty"`
+}
+
+// FeedCollection retorna la subcolección del feed del usuario (feeds/{uid}/items)
+func FeedCollection(uid string) string {
+	return "feeds/" + uid + "/items"
+}
+
+// Follow hace que follower reciba en su feed las actividades de followee (idempotente:
+// seguir de nuevo conserva el created_at original)
+func Follow(ctx context.Context, follower, followee string) error {
+	if follower == followee {
+		return fmt.Errorf("user '%s' cannot follow themselves", follower)
+	}
+	err := firestore.CreateDocumentIfAbsent(ctx, FollowsCollection, followID(follower, followee), map[string]interface{}{
+		"follower": follower,
+		"followee": followee,
+	})
+	if err != nil && !firestore.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to follow '%s': %w", followee, err)
+	}
+	return nil
+}
+
+// Unfollow deja de seguir a followee
+func Unfollow(ctx context.Context, follower, followee string) error {
+	if err := firestore.DeleteDocument(ctx, FollowsCollection, followID(follower, followee)); err != nil {
+		return fmt.Errorf("failed to unfollow '%s': %w", followee, err)
+	}
+	return nil
+}
+
+// ListFollowers retorna los UIDs que siguen a uid
+func ListFollowers(ctx context.Context, uid string) ([]string, error) {
+	docs, err := firestore.QueryDocuments(ctx, FollowsCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{firebase.Where("followee", firebase.OpEqual, uid)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list followers of '%s': %w", uid, err)
+	}
+
+	followers := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if follower, ok := doc.Data["follower"].(string); ok {
+			followers = append(followers, follower)
+		}
+	}
+	return followers, nil
+}
+
+// Record guarda la actividad y la replica (fan-out on write) en el feed del actor y de
+// todos sus seguidores. Retorna el ID de la actividad, que se reutiliza en cada feed.
+func Record(ctx context.Context, activity Activity) (string, error) {
+	if activity.Actor == "" || activity.Verb == "" || activity.Object == "" {
+		return "", fmt.Errorf("activity requires actor, verb and object")
+	}
+
+	activityID, err := firestore.CreateDocument(ctx, ActivitiesCollection, activityData(activity))
+	if err != nil {
+		return "", fmt.Errorf("failed to record activity: %w", err)
+	}
+
+	followers, err := ListFollowers(ctx, activity.Actor)
+	if err != nil {
+		return activityID, err
+	}
+	recipients := append([]string{activity.Actor}, followers...)
+
+	operations := make([]firebase.BatchOperation, 0, len(recipients))
+	for _, uid := range recipients {
+		data := activityData(activity)
+		data["activity_id"] = activityID
+		operations = append(operations, firebase.BatchOperation{
+			Type:       "create",
+			Collection: FeedCollection(uid),
+			DocumentID: activityID,
+			Data:       data,
+		})
+	}
+	if err := firestore.BatchWrite(ctx, operations); err != nil {
+		return activityID, fmt.Errorf("failed to fan out activity '%s': %w", activityID, err)
+	}
+
+	return activityID, nil
+}
+
+// ReadFeed lista el feed del usuario, más reciente primero. El cursor es el NextCursor
+// del resultado anterior.
+func ReadFeed(ctx context.Context, uid string, pageSize int, cursor string) (*firebase.QueryResult, error) {
+	result, err := firestore.QueryDocumentsWithMeta(ctx, FeedCollection(uid), firebase.QueryOptions{
+		OrderBy:  "created_at",
+		OrderDir: "desc",
+		Limit:    pageSize,
+		Cursor:   cursor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed of '%s': %w", uid, err)
+	}
+	return result, nil
+}
+
+// ReadTimeline lista las actividades realizadas por actor, más reciente primero
+func ReadTimeline(ctx context.Context, actor string, pageSize int, cursor string) (*firebase.QueryResult, error) {
+	result, err := firestore.QueryDocumentsWithMeta(ctx, ActivitiesCollection, firebase.QueryOptions{
+		Filters:  []firebase.QueryFilter{firebase.Where("actor", firebase.OpEqual, actor)},
+		OrderBy:  "created_at",
+		OrderDir: "desc",
+		Limit:    pageSize,
+		Cursor:   cursor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timeline of '%s': %w", actor, err)
+	}
+	return result, nil
+}
+
+func activityData(activity Activity) map[string]interface{} {
+	data := map[string]interface{}{
+		"actor":  activity.Actor,
+		"verb":   activity.Verb,
+		"object": activity.Object,
+	}
+	if activity.Target != "" {
+		data["target"] = activity.Target
+	}
+	if len(activity.Extra) > 0 {
+		data["extra"] = activity.Extra
+	}
+	return data
+}
+
+func followID(follower, followee string) string {
+	return firestore.CompositeID(follower, followee)
+}
@@ -0,0 +1,511 @@
+// Package admin expone una API JSON lista para montar un panel de administración interno:
+// colecciones y documentos (con auditoría de cada cambio), usuarios, claims, sesiones,
+// bloqueos de IP y métricas de OTP y login. Todas las rutas requieren una sesión de
+// administrador (ver auth.IsAdmin).
+package admin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/ipblock"
+)
+
+// internalCollections colecciones con secretos o registros del sistema que la API nunca
+// expone, aunque estén en Options.Collections
+var internalCollections = map[string]bool{
+	"user_sessions":                true,
+	"user_otps":                    true,
+	"email_changes":                true,
+	"user_invitations":             true,
+	auth.RevokedSessionsCollection: true,
+	auth.AuditCollection:           true,
+	"signing_clients":              true,
+	"webhooks":                     true,
+	"webhook_deliveries":           true,
+	"api_keys":                     true,
+}
+
+// Options configuración del Handler
+type Options struct {
+	// Collections colecciones visibles; vacío expone todas las de primer nivel salvo las
+	// internas (sesiones, OTP, auditoría, claves de API, webhooks...), que nunca se exponen
+	Collections []string
+	// Schemas campos filtrables y ordenables de cada colección (ver
+	// firebase.ParseQueryString); sin esquema solo se pagina
+	Schemas map[string]firebase.QueryStringSchema
+	// ReadOnly rechaza las rutas que modifican datos
+	ReadOnly bool
+}
+
+// api estado del Handler
+type api struct {
+	options Options
+	allowed map[string]bool
+}
+
+// Handler retorna la API de administración. Montarla con http.StripPrefix:
+//
+//	GET    /collections
+//	GET    /collections/{collection}/documents?filter[...]&sort=...&page[size]=...
+//	POST   /collections/{collection}/documents
+//	GET    /collections/{collection}/documents/{id}
+//	PATCH  /collections/{collection}/documents/{id}
+//	DELETE /collections/{collection}/documents/{id}
+//	GET    /users?limit=...&page_token=...
+//	GET    /users/{uid}
+//	PATCH  /users/{uid}
+//	DELETE /users/{uid}
+//	GET    /users/{uid}/claims
+//	PATCH  /users/{uid}/claims
+//	GET    /users/{uid}/sessions
+//	DELETE /users/{uid}/sessions
+//	DELETE /users/{uid}/sessions/{handle}
+//	GET    /metrics?since=24h
+//
+// Las sesiones se listan sin su ID (que autentica las peticiones) sino con un handle
+// derivado de él. Los documentos viajan con los tipos de firestore.EncodeJSONValue ({"$time": ...}) y
+// cada cambio se registra en auth.AuditCollection.
+func Handler(options Options) http.Handler {
+	a := &api{options: options, allowed: map[string]bool{}}
+	for _, collection := range options.Collections {
+		a.allowed[collection] = true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /collections", a.listCollections)
+	mux.HandleFunc("GET /collections/{collection}/documents", a.listDocuments)
+	mux.HandleFunc("POST /collections/{collection}/documents", a.writable(a.createDocument))
+	mux.HandleFunc("GET /collections/{collection}/documents/{id}", a.getDocument)
+	mux.HandleFunc("PATCH /collections/{collection}/documents/{id}", a.writable(a.updateDocument))
+	mux.HandleFunc("DELETE /collections/{collection}/documents/{id}", a.writable(a.deleteDocument))
+	mux.HandleFunc("GET /users", a.listUsers)
+	mux.HandleFunc("GET /users/{uid}", a.getUser)
+	mux.HandleFunc("PATCH /users/{uid}", a.writable(a.updateUser))
+	mux.HandleFunc("DELETE /users/{uid}", a.writable(a.deleteUser))
+	mux.HandleFunc("GET /users/{uid}/claims", a.getClaims)
+	mux.HandleFunc("PATCH /users/{uid}/claims", a.writable(a.updateClaims))
+	mux.HandleFunc("GET /users/{uid}/sessions", a.listSessions)
+	mux.HandleFunc("DELETE /users/{uid}/sessions", a.writable(a.revokeSessions))
+	mux.HandleFunc("DELETE /users/{uid}/sessions/{handle}", a.writable(a.deleteSession))
+	mux.HandleFunc("GET /ipblocks", a.listIPBlocks)
+	mux.HandleFunc("POST /ipblocks", a.writable(a.blockIPRange))
+	mux.HandleFunc("DELETE /ipblocks", a.writable(a.unblockIPRange))
+	mux.HandleFunc("GET /metrics", a.metrics)
+
+	return auth.SessionMiddleware(true)(requireAdmin(mux))
+}
+
+// requireAdmin rechaza las sesiones sin permisos de administrador o suplantadas
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := firebase.SessionFromContext(r.Context())
+		if !auth.IsAdmin(session) || session.ImpersonatedBy != "" {
+			writeError(w, http.StatusForbidden, "se requieren permisos de administrador")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writable rechaza la ruta si la API es de solo lectura
+func (a *api) writable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.options.ReadOnly {
+			writeError(w, http.StatusForbidden, "la API de administración es de solo lectura")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// collection valida la colección de la ruta contra Options.Collections
+func (a *api) collection(w http.ResponseWriter, r *http.Request) (string, bool) {
+	collection := r.PathValue("collection")
+	if internalCollections[collection] || (len(a.allowed) > 0 && !a.allowed[collection]) {
+		writeError(w, http.StatusNotFound, "colección no disponible")
+		return "", false
+	}
+	return collection, true
+}
+
+func (a *api) listCollections(w http.ResponseWriter, r *http.Request) {
+	if len(a.options.Collections) > 0 {
+		var names []string
+		for _, name := range a.options.Collections {
+			if !internalCollections[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"collections": names})
+		return
+	}
+
+	var names []string
+	iter := firebase.GetFirestoreClient().Collections(r.Context())
+	for {
+		ref, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+		if !internalCollections[ref.ID] {
+			names = append(names, ref.ID)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"collections": names})
+}
+
+func (a *api) listDocuments(w http.ResponseWriter, r *http.Request) {
+	collection, ok := a.collection(w, r)
+	if !ok {
+		return
+	}
+	options, err := firebase.ParseQueryString(r.URL.Query(), a.options.Schemas[collection])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result, err := firestore.QueryDocumentsWithMeta(r.Context(), collection, options)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	documents := make([]interface{}, len(result.Documents))
+	for i, doc := range result.Documents {
+		documents[i] = encodeDocument(doc)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"documents":   documents,
+		"next_cursor": result.NextCursor,
+		"has_more":    result.Truncated,
+	})
+}
+
+func (a *api) getDocument(w http.ResponseWriter, r *http.Request) {
+	collection, ok := a.collection(w, r)
+	if !ok {
+		return
+	}
+	doc, err := firestore.GetDocument(r.Context(), collection, r.PathValue("id"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, encodeDocument(doc))
+}
+
+func (a *api) createDocument(w http.ResponseWriter, r *http.Request) {
+	collection, ok := a.collection(w, r)
+	if !ok {
+		return
+	}
+	data, ok := decodeBody(w, r)
+	if !ok {
+		return
+	}
+	id, err := firestore.CreateDocument(r.Context(), collection, data)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	audit(r, "admin_document_created", "", map[string]interface{}{"collection": collection, "document_id": id})
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (a *api) updateDocument(w http.ResponseWriter, r *http.Request) {
+	collection, ok := a.collection(w, r)
+	if !ok {
+		return
+	}
+	data, ok := decodeBody(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	fields := make([]string, 0, len(data))
+	for field := range data {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	if err := firestore.UpdateDocument(r.Context(), collection, id, data); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	audit(r, "admin_document_updated", "", map[string]interface{}{"collection": collection, "document_id": id, "fields": fields})
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+func (a *api) deleteDocument(w http.ResponseWriter, r *http.Request) {
+	collection, ok := a.collection(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	if err := firestore.DeleteDocument(r.Context(), collection, id); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	audit(r, "admin_document_deleted", "", map[string]interface{}{"collection": collection, "document_id": id})
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+func (a *api) listUsers(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	users, next, err := auth.ListUsers(r.Context(), limit, r.URL.Query().Get("page_token"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"users": users, "next_page_token": next})
+}
+
+func (a *api) getUser(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUser(r.Context(), r.PathValue("uid"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (a *api) updateUser(w http.ResponseWriter, r *http.Request) {
+	var request firebase.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "cuerpo JSON inválido")
+		return
+	}
+	uid := r.PathValue("uid")
+	user, err := auth.UpdateUser(r.Context(), uid, request)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	audit(r, "admin_user_updated", uid, nil)
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (a *api) deleteUser(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	if err := auth.DeleteUser(r.Context(), uid); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	audit(r, "admin_user_deleted", uid, nil)
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+func (a *api) getClaims(w http.ResponseWriter, r *http.Request) {
+	claims, err := auth.ResolveClaims(r.Context(), r.PathValue("uid"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+// updateClaims aplica cambios parciales a los claims (un valor null elimina el claim)
+func (a *api) updateClaims(w http.ResponseWriter, r *http.Request) {
+	var changes map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil || len(changes) == 0 {
+		writeError(w, http.StatusBadRequest, "cuerpo JSON inválido")
+		return
+	}
+	uid := r.PathValue("uid")
+	if err := auth.UpdateClaims(r.Context(), uid, changes); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	audit(r, "admin_claims_updated", uid, map[string]interface{}{"changes": changes})
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+func (a *api) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := activeSessions(r)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	documents := make([]interface{}, len(sessions))
+	for i, session := range sessions {
+		documents[i] = map[string]interface{}{
+			"handle": sessionHandle(session.ID),
+			"data":   firestore.EncodeJSONValue(session.Data),
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": documents})
+}
+
+func (a *api) revokeSessions(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	if err := auth.RevokeUserSessions(r.Context(), uid); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	audit(r, "admin_sessions_revoked", uid, nil)
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// deleteSession cierra la sesión activa del usuario cuyo handle coincide
+func (a *api) deleteSession(w http.ResponseWriter, r *http.Request) {
+	sessions, err := activeSessions(r)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	handle := r.PathValue("handle")
+	for _, session := range sessions {
+		if sessionHandle(session.ID) != handle {
+			continue
+		}
+		if err := auth.Logout(r.Context(), session.ID); err != nil {
+			writeFailure(w, err)
+			return
+		}
+		audit(r, "admin_session_closed", r.PathValue("uid"), map[string]interface{}{"session_handle": handle})
+		writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+		return
+	}
+	writeError(w, http.StatusNotFound, "sesión no encontrada")
+}
+
+// activeSessions sesiones activas del usuario de la ruta
+func activeSessions(r *http.Request) ([]*firebase.Document, error) {
+	return firestore.QueryDocuments(r.Context(), "user_sessions", firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			{Field: "uid", Operator: firebase.OpEqual, Value: r.PathValue("uid")},
+			{Field: "active", Operator: firebase.OpEqual, Value: true},
+		},
+	})
+}
+
+// sessionHandle identificador público de una sesión; el ID real funciona como credencial
+// y no sale de la API
+func sessionHandle(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (a *api) listIPBlocks(w http.ResponseWriter, r *http.Request) {
+	blocks, err := ipblock.ListBlocks(r.Context())
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"blocks": blocks})
+}
+
+func (a *api) blockIPRange(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Range           string `json:"range"`
+		Reason          string `json:"reason"`
+		DurationSeconds int64  `json:"duration_seconds"` // 0 = permanente
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Range == "" {
+		writeError(w, http.StatusBadRequest, "se requiere el rango a bloquear")
+		return
+	}
+	block, err := ipblock.BlockRange(r.Context(), request.Range, request.Reason, time.Duration(request.DurationSeconds)*time.Second)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	audit(r, "admin_ip_blocked", "", map[string]interface{}{"range": block.Range, "reason": block.Reason})
+	writeJSON(w, http.StatusCreated, block)
+}
+
+// unblockIPRange recibe el rango en ?range= (un CIDR contiene "/")
+func (a *api) unblockIPRange(w http.ResponseWriter, r *http.Request) {
+	cidr := r.URL.Query().Get("range")
+	if cidr == "" {
+		writeError(w, http.StatusBadRequest, "se requiere el parámetro range")
+		return
+	}
+	if err := ipblock.Unblock(r.Context(), cidr); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	audit(r, "admin_ip_unblocked", "", map[string]interface{}{"range": cidr})
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// audit registra la acción del administrador en auth.AuditCollection; un fallo no revierte
+// la acción ya aplicada
+func audit(r *http.Request, action, targetUID string, details map[string]interface{}) {
+	session, _ := firebase.SessionFromContext(r.Context())
+	data := map[string]interface{}{
+		"action":     action,
+		"actor_uid":  session.UID,
+		"target_uid": targetUID,
+	}
+	if len(details) > 0 {
+		data["details"] = details
+	}
+	firestore.CreateDocument(r.Context(), auth.AuditCollection, data)
+}
+
+// encodeDocument documento con los tipos de firestore.EncodeJSONValue
+func encodeDocument(doc *firebase.Document) map[string]interface{} {
+	return map[string]interface{}{"id": doc.ID, "data": firestore.EncodeJSONValue(doc.Data)}
+}
+
+// decodeBody lee un objeto JSON con los tipos de firestore.EncodeJSONValue
+func decodeBody(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	var body map[string]interface{}
+	if err := decoder.Decode(&body); err != nil || len(body) == 0 {
+		writeError(w, http.StatusBadRequest, "cuerpo JSON inválido")
+		return nil, false
+	}
+	data, _ := firestore.DecodeJSONValue(firebase.GetFirestoreClient(), body).(map[string]interface{})
+	return data, true
+}
+
+// writeFailure traduce los errores del paquete a códigos HTTP
+func writeFailure(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var validation *firebase.ValidationError
+	var queryString *firebase.QueryStringError
+	switch {
+	case errors.As(err, new(*firebase.DocumentNotFoundError)), errors.As(err, new(*firebase.UserNotFoundError)), firestore.IsNotFound(err):
+		status = http.StatusNotFound
+	case errors.As(err, new(*firebase.PermissionDeniedError)):
+		status = http.StatusForbidden
+	case errors.Is(err, firebase.ErrReadOnly), errors.Is(err, firebase.ErrImmutableCollection), errors.Is(err, firebase.ErrLegalHold):
+		status = http.StatusConflict
+	case errors.As(err, &validation), errors.As(err, &queryString), errors.Is(err, firebase.ErrMissingIndex):
+		status = http.StatusBadRequest
+	}
+	writeError(w, status, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Metrics métricas de OTP y login desde Since
+type Metrics struct {
+	Since          time.Time `json:"since"`
+	OTPsRequested  int       `json:"otps_requested"`
+	OTPsUsed       int       `json:"otps_used"`
+	OTPConversion  float64   `json:"otp_conversion"` // usados / solicitados
+	Logins         int       `json:"logins"`         // sesiones creadas
+	ActiveSessions int       `json:"active_sessions"`
+}
+
+// metrics cuenta OTPs y sesiones del periodo ?since=<duración> (por defecto 24h)
+func (a *api) metrics(w http.ResponseWriter, r *http.Request) {
+	period := 24 * time.Hour
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "parámetro since inválido (p. ej. 24h)")
+			return
+		}
+		period = parsed
+	}
+
+	ctx := r.Context()
+	now := firebase.Now()
+	metrics := Metrics{Since: now.Add(-period)}
+	counts := []struct {
+		target     *int
+		collection string
+		filters    []firebase.QueryFilter
+	}{
+		{&metrics.OTPsRequested, "user_otps", []firebase.QueryFilter{
+			firebase.Where("created_at", firebase.OpGreaterThanOrEqual, metrics.Since),
+		}},
+		{&metrics.OTPsUsed, "user_otps", []firebase.QueryFilter{
+			firebase.Where("used", firebase.OpEqual, true),
+			firebase.Where("created_at", firebase.OpGreaterThanOrEqual, metrics.Since),
+		}},
+		{&metrics.Logins, "user_sessions", []firebase.QueryFilter{
+			firebase.Where("created_at", firebase.OpGreaterThanOrEqual, metrics.Since),
+		}},
+		{&metrics.ActiveSessions, "user_sessions", []firebase.QueryFilter{
+			firebase.Where("active", firebase.OpEqual, true),
+			firebase.Where("expires_at", firebase.OpGreaterThan, now),
+		}},
+	}
+	for _, count := range counts {
+		n, err := firestore.CountDocuments(ctx, count.collection, count.filters)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+		*count.target = n
+	}
+	if metrics.OTPsRequested > 0 {
+		metrics.OTPConversion = float64(metrics.OTPsUsed) / float64(metrics.OTPsRequested)
+	}
+	writeJSON(w, http.StatusOK, metrics)
+}
@@ -0,0 +1,218 @@
+// Package alerts detecta picos de fallos de login con OTP (p. ej. credential stuffing) y
+// avisa a través de hooks configurables (webhook de Slack, correo o una función propia).
+//
+//	detector := &alerts.Detector{
+//		MaxFailures: 50, MaxFailureRate: 0.5, MinAttempts: 20,
+//		Hooks: []alerts.Hook{alerts.SlackHook(os.Getenv("SLACK_WEBHOOK_URL"))},
+//	}
+//	go detector.Run(ctx)
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/mailer"
+)
+
+// Alert aviso emitido cuando se supera un umbral en la ventana
+type Alert struct {
+	Reason      string        `json:"reason"` // "failures" o "failure_rate"
+	Window      time.Duration `json:"window"`
+	Attempts    int64         `json:"attempts"`
+	Failures    int64         `json:"failures"`
+	FailureRate float64       `json:"failure_rate"`
+	DetectedAt  time.Time     `json:"detected_at"`
+}
+
+// Message texto legible del aviso
+func (a Alert) Message() string {
+	return fmt.Sprintf("⚠️ Fallos de login anómalos: %d fallos de %d intentos (%.0f%%) en los últimos %s",
+		a.Failures, a.Attempts, a.FailureRate*100, a.Window)
+}
+
+// Hook recibe cada aviso
+type Hook func(ctx context.Context, alert Alert) error
+
+// Counters contadores acumulados de intentos y fallos de login; el detector trabaja con
+// sus diferencias, por lo que un contador que retrocede (reinicio, cambio de día) solo
+// reinicia la ventana
+type Counters func(ctx context.Context) (attempts, failures int64, err error)
+
+// LocalCounters cuenta los logins con OTP de este proceso (firebase.EventCounts)
+func LocalCounters(ctx context.Context) (int64, int64, error) {
+	events := firebase.EventCounts()
+	failures := events[firebase.EventOTPLoginFailure]
+	return events[firebase.EventOTPLoginSuccess] + failures, failures, nil
+}
+
+// LoginStatsCounters cuenta los logins con OTP de todas las instancias a partir del
+// agregado diario de auth.LoginStatsCollection (la ventana se reinicia al cambiar el día)
+func LoginStatsCounters(ctx context.Context) (int64, int64, error) {
+	today := firebase.Now().UTC().Format("2006-01-02")
+	snap, err := firestore.DocRefContext(ctx, auth.LoginStatsCollection, today).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("failed to read login stats: %w", err)
+	}
+	data := snap.Data()
+	logins, _ := data["otp_logins"].(int64)
+	failures, _ := data["failures"].(int64)
+	return logins + failures, failures, nil
+}
+
+// Detector compara los fallos de login de la ventana con los umbrales cada Interval
+type Detector struct {
+	Counters       Counters      // por defecto LocalCounters
+	Window         time.Duration // por defecto 5 minutos
+	Interval       time.Duration // por defecto 1 minuto
+	MaxFailures    int64         // fallos en la ventana que disparan el aviso (0 = sin límite)
+	MaxFailureRate float64       // proporción de fallos que dispara el aviso (0 = sin límite)
+	MinAttempts    int64         // intentos mínimos para evaluar MaxFailureRate
+	Cooldown       time.Duration // tiempo mínimo entre avisos (por defecto 15 minutos)
+	Hooks          []Hook
+
+	mu        sync.Mutex
+	samples   []sample
+	lastAlert time.Time
+}
+
+type sample struct {
+	at                 time.Time
+	attempts, failures int64
+}
+
+// Run evalúa los umbrales cada Interval hasta que ctx se cancela
+func (d *Detector) Run(ctx context.Context) error {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.Check(ctx); err != nil {
+			log.Printf("⚠️  Error checking auth failure rates: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Check toma una muestra de los contadores y, si la ventana supera algún umbral fuera
+// del periodo de Cooldown, llama a los hooks. Retorna el aviso emitido, si lo hubo.
+func (d *Detector) Check(ctx context.Context) (*Alert, error) {
+	counters := d.Counters
+	if counters == nil {
+		counters = LocalCounters
+	}
+	attempts, failures, err := counters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	window := d.Window
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	cooldown := d.Cooldown
+	if cooldown <= 0 {
+		cooldown = 15 * time.Minute
+	}
+	now := firebase.Now()
+
+	d.mu.Lock()
+	if n := len(d.samples); n > 0 && (attempts < d.samples[n-1].attempts || failures < d.samples[n-1].failures) {
+		d.samples = nil
+	}
+	d.samples = append(d.samples, sample{at: now, attempts: attempts, failures: failures})
+	// Conservar la muestra más reciente anterior a la ventana como base
+	for len(d.samples) > 1 && !d.samples[1].at.After(now.Add(-window)) {
+		d.samples = d.samples[1:]
+	}
+	base := d.samples[0]
+	alert := Alert{
+		Window:     window,
+		Attempts:   attempts - base.attempts,
+		Failures:   failures - base.failures,
+		DetectedAt: now,
+	}
+	if alert.Attempts > 0 {
+		alert.FailureRate = float64(alert.Failures) / float64(alert.Attempts)
+	}
+	switch {
+	case d.MaxFailures > 0 && alert.Failures >= d.MaxFailures:
+		alert.Reason = "failures"
+	case d.MaxFailureRate > 0 && alert.Attempts >= d.MinAttempts && alert.Attempts > 0 && alert.FailureRate >= d.MaxFailureRate:
+		alert.Reason = "failure_rate"
+	}
+	if alert.Reason == "" || (!d.lastAlert.IsZero() && now.Sub(d.lastAlert) < cooldown) {
+		d.mu.Unlock()
+		return nil, nil
+	}
+	d.lastAlert = now
+	hooks := d.Hooks
+	d.mu.Unlock()
+
+	var errs []string
+	for _, hook := range hooks {
+		if err := hook(ctx, alert); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return &alert, fmt.Errorf("failed to deliver alert: %s", strings.Join(errs, "; "))
+	}
+	return &alert, nil
+}
+
+// SlackHook publica el aviso en un webhook entrante de Slack
+func SlackHook(webhookURL string) Hook {
+	return func(ctx context.Context, alert Alert) error {
+		body, _ := json.Marshal(map[string]string{"text": alert.Message()})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to build Slack request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to post Slack alert: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("slack webhook responded with status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// EmailHook envía el aviso por correo con el mailer configurado
+func EmailHook(recipients ...string) Hook {
+	return func(ctx context.Context, alert Alert) error {
+		for _, to := range recipients {
+			if err := mailer.Send(ctx, mailer.Message{
+				To:      to,
+				Subject: "Alerta: fallos de login anómalos",
+				Text:    alert.Message(),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,354 @@
+// Package anonymize transforma campos con datos personales (hash, máscara, generalización
+// de fechas y rangos numéricos) y aplica k-anonimato sobre conjuntos de documentos, para
+// que las exportaciones y los clonados de entornos no expongan a las personas. Las
+// transformaciones se configuran por colección y campo con Register.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transform transforma el valor de un campo; ok=false elimina el campo
+type Transform func(value interface{}) (transformed interface{}, ok bool)
+
+// KAnonymity configuración de k-anonimato de una colección: cada combinación de los
+// cuasi-identificadores (ya transformados) debe aparecer en al menos K documentos. En los
+// grupos menores se eliminan esos campos, o el documento completo con DropRecords.
+type KAnonymity struct {
+	K                int
+	QuasiIdentifiers []string
+	DropRecords      bool
+}
+
+// Report resultado de aplicar las transformaciones a un conjunto de documentos
+type Report struct {
+	TransformedFields int `json:"transformed_fields"`
+	SuppressedRecords int `json:"suppressed_records"` // grupos menores que K
+	DroppedRecords    int `json:"dropped_records"`
+}
+
+var (
+	mu         sync.RWMutex
+	transforms = map[string]map[string]Transform{} // colección ("*" = todas) -> campo -> transformación
+	kAnonymity = map[string]KAnonymity{}
+)
+
+// Register registra la transformación de un campo (se admiten rutas con puntos, p. ej.
+// "profile.phone"). collection "*" aplica a todas las colecciones; una transformación
+// específica de la colección tiene prioridad.
+func Register(collection, field string, transform Transform) {
+	mu.Lock()
+	defer mu.Unlock()
+	if transforms[collection] == nil {
+		transforms[collection] = map[string]Transform{}
+	}
+	transforms[collection][field] = transform
+}
+
+// SetKAnonymity exige k-anonimato en los conjuntos de documentos de la colección
+// (ApplyAll); K <= 1 lo desactiva
+func SetKAnonymity(collection string, config KAnonymity) {
+	mu.Lock()
+	defer mu.Unlock()
+	if config.K <= 1 || len(config.QuasiIdentifiers) == 0 {
+		delete(kAnonymity, collection)
+		return
+	}
+	kAnonymity[collection] = config
+}
+
+// HasKAnonymity indica si la colección exige k-anonimato, en cuyo caso sus documentos
+// deben procesarse juntos con ApplyAll
+func HasKAnonymity(collection string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := kAnonymity[collection]
+	return ok
+}
+
+// Clear elimina todas las transformaciones y configuraciones de k-anonimato
+func Clear() {
+	mu.Lock()
+	defer mu.Unlock()
+	transforms = map[string]map[string]Transform{}
+	kAnonymity = map[string]KAnonymity{}
+}
+
+// Drop elimina el campo
+func Drop(value interface{}) (interface{}, bool) {
+	return nil, false
+}
+
+// Hash reemplaza el valor por un hash estable: el mismo valor produce el mismo hash en
+// todas las colecciones, por lo que las referencias entre documentos se conservan. Sin
+// clave es reversible por fuerza bruta en valores predecibles; usar HMAC para
+// seudonimizar.
+func Hash(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	return hashValue(value), true
+}
+
+// HMAC retorna una transformación que seudonimiza con HMAC-SHA256 y una clave secreta:
+// estable para la misma clave y no reversible sin ella
+func HMAC(key []byte) Transform {
+	return func(value interface{}) (interface{}, bool) {
+		if value == nil {
+			return nil, true
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(fmt.Sprint(value)))
+		return hex.EncodeToString(mac.Sum(nil)[:12]), true
+	}
+}
+
+// Email reemplaza un email por uno ficticio y estable (user-<hash>@example.com)
+func Email(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	return fmt.Sprintf("user-%s@example.com", hashValue(strings.ToLower(fmt.Sprint(value)))), true
+}
+
+// Mask conserva los últimos 4 caracteres y reemplaza el resto por '*'
+func Mask(value interface{}) (interface{}, bool) {
+	return MaskKeeping(0, 4)(value)
+}
+
+// MaskKeeping retorna una máscara que conserva los primeros prefix y los últimos suffix
+// caracteres (p. ej. MaskKeeping(3, 0) generaliza "28013" a "280**")
+func MaskKeeping(prefix, suffix int) Transform {
+	return func(value interface{}) (interface{}, bool) {
+		if value == nil {
+			return nil, true
+		}
+		s := []rune(fmt.Sprint(value))
+		for i := prefix; i < len(s)-suffix; i++ {
+			s[i] = '*'
+		}
+		return string(s), true
+	}
+}
+
+// Constant retorna una transformación que reemplaza el valor por uno fijo
+func Constant(replacement interface{}) Transform {
+	return func(interface{}) (interface{}, bool) {
+		return replacement, true
+	}
+}
+
+// Precisiones de GeneralizeDate
+const (
+	Year  = "year"
+	Month = "month"
+	Day   = "day"
+)
+
+// GeneralizeDate retorna una transformación que trunca fechas (time.Time o string
+// RFC 3339) al inicio del año, mes o día en UTC, conservando el tipo
+func GeneralizeDate(precision string) Transform {
+	return func(value interface{}) (interface{}, bool) {
+		switch v := value.(type) {
+		case time.Time:
+			return truncateDate(v, precision), true
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, false
+			}
+			return truncateDate(t, precision).Format(time.RFC3339), true
+		}
+		return value, value == nil
+	}
+}
+
+func truncateDate(t time.Time, precision string) time.Time {
+	t = t.UTC()
+	switch precision {
+	case Year:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Bucket retorna una transformación que reemplaza un número por el rango de ancho width
+// que lo contiene ("30-39" con width 10 y enteros, "30-40" con decimales)
+func Bucket(width float64) Transform {
+	return func(value interface{}) (interface{}, bool) {
+		n, ok := toFloat(value)
+		if !ok || width <= 0 {
+			return nil, value == nil
+		}
+		low := math.Floor(n/width) * width
+		if low == math.Trunc(low) && width == math.Trunc(width) {
+			return fmt.Sprintf("%d-%d", int64(low), int64(low+width-1)), true
+		}
+		return fmt.Sprintf("%g-%g", low, low+width), true
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func hashValue(value interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+	return hex.EncodeToString(sum[:6])
+}
+
+// transformsFor combina las transformaciones globales con las de la colección
+func transformsFor(collection string) map[string]Transform {
+	mu.RLock()
+	defer mu.RUnlock()
+	combined := make(map[string]Transform, len(transforms["*"])+len(transforms[collection]))
+	for field, transform := range transforms["*"] {
+		combined[field] = transform
+	}
+	for field, transform := range transforms[collection] {
+		combined[field] = transform
+	}
+	return combined
+}
+
+// Apply aplica las transformaciones de la colección a una copia de data y retorna cuántos
+// campos modificó. No aplica k-anonimato, que requiere el conjunto completo (ApplyAll).
+func Apply(collection string, data map[string]interface{}) (map[string]interface{}, int) {
+	applicable := transformsFor(collection)
+	if len(applicable) == 0 {
+		return data, 0
+	}
+
+	out := copyMap(data)
+	transformed := 0
+	for field, transform := range applicable {
+		if applyTransform(out, strings.Split(field, "."), transform) {
+			transformed++
+		}
+	}
+	return out, transformed
+}
+
+// ApplyAll aplica las transformaciones a cada documento y luego el k-anonimato de la
+// colección sobre el conjunto. El resultado conserva el orden de docs; los documentos
+// eliminados por DropRecords quedan en nil.
+func ApplyAll(collection string, docs []map[string]interface{}) ([]map[string]interface{}, Report) {
+	var report Report
+	out := make([]map[string]interface{}, len(docs))
+	for i, data := range docs {
+		var transformed int
+		out[i], transformed = Apply(collection, data)
+		report.TransformedFields += transformed
+	}
+
+	mu.RLock()
+	config, ok := kAnonymity[collection]
+	mu.RUnlock()
+	if !ok {
+		return out, report
+	}
+
+	groups := make(map[string][]int)
+	for i, data := range out {
+		key := quasiKey(data, config.QuasiIdentifiers)
+		groups[key] = append(groups[key], i)
+	}
+	for _, members := range groups {
+		if len(members) >= config.K {
+			continue
+		}
+		for _, i := range members {
+			report.SuppressedRecords++
+			if config.DropRecords {
+				out[i] = nil
+				report.DroppedRecords++
+				continue
+			}
+			// Sin transformaciones, Apply retorna el mapa original
+			out[i] = copyMap(out[i])
+			for _, field := range config.QuasiIdentifiers {
+				applyTransform(out[i], strings.Split(field, "."), Drop)
+			}
+		}
+	}
+	return out, report
+}
+
+// quasiKey clave del grupo de un documento según sus cuasi-identificadores
+func quasiKey(data map[string]interface{}, fields []string) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		value, ok := lookup(data, strings.Split(field, "."))
+		if ok {
+			parts[i] = fmt.Sprintf("%T:%v", value, value)
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func lookup(data map[string]interface{}, path []string) (interface{}, bool) {
+	value, ok := data[path[0]]
+	if !ok || len(path) == 1 {
+		return value, ok
+	}
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookup(nested, path[1:])
+}
+
+func applyTransform(data map[string]interface{}, path []string, transform Transform) bool {
+	value, ok := data[path[0]]
+	if !ok {
+		return false
+	}
+	if len(path) > 1 {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		return applyTransform(nested, path[1:], transform)
+	}
+
+	replacement, keep := transform(value)
+	if !keep {
+		delete(data, path[0])
+	} else {
+		data[path[0]] = replacement
+	}
+	return true
+}
+
+// copyMap copia los mapas anidados para que las transformaciones no modifiquen el original
+func copyMap(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = copyMap(nested)
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+package anonymize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/columnar"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// exportPageSize documentos leídos por página al exportar
+const exportPageSize = 500
+
+// exportLine línea JSONL de una exportación: ID y datos con tipos (EncodeJSONValue)
+type exportLine struct {
+	ID   string                 `json:"id"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// ExportJSONL escribe en w los documentos de la colección que cumplen filters, ya
+// transformados, como JSON por línea. Lee con QueryDocumentsWithMeta, por lo que aplican
+// interceptores, tenancy y políticas de lectura. Si la colección exige k-anonimato, los
+// documentos se acumulan en memoria y se escriben al final.
+func ExportJSONL(ctx context.Context, collection string, filters []firebase.QueryFilter, w io.Writer) (*Report, error) {
+	report := &Report{}
+	grouped := HasKAnonymity(collection)
+	var ids []string
+	var pending []map[string]interface{}
+
+	encoder := json.NewEncoder(w)
+	write := func(id string, data map[string]interface{}) error {
+		encoded, _ := firestore.EncodeJSONValue(data).(map[string]interface{})
+		if err := encoder.Encode(exportLine{ID: id, Data: encoded}); err != nil {
+			return fmt.Errorf("failed to write export line for '%s': %w", id, err)
+		}
+		return nil
+	}
+
+	options := firebase.QueryOptions{Filters: filters, Limit: exportPageSize}
+	for {
+		result, err := firestore.QueryDocumentsWithMeta(ctx, collection, options)
+		if err != nil {
+			return report, fmt.Errorf("failed to export collection '%s': %w", collection, err)
+		}
+		for _, doc := range result.Documents {
+			if grouped {
+				ids = append(ids, doc.ID)
+				pending = append(pending, doc.Data)
+				continue
+			}
+			data, transformed := Apply(collection, doc.Data)
+			report.TransformedFields += transformed
+			if err := write(doc.ID, data); err != nil {
+				return report, err
+			}
+		}
+		if !result.Truncated || result.NextCursor == "" {
+			break
+		}
+		options.Cursor = result.NextCursor
+	}
+	if !grouped {
+		return report, nil
+	}
+
+	docs, applied := ApplyAll(collection, pending)
+	*report = applied
+	for i, data := range docs {
+		if data == nil {
+			continue
+		}
+		if err := write(ids[i], data); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// ExportParquet escribe en w los documentos de la colección que cumplen filters, ya
+// transformados, como archivo Parquet con el esquema inferido por columnar.Infer. Los
+// documentos se acumulan en memoria para inferir el esquema.
+func ExportParquet(ctx context.Context, collection string, filters []firebase.QueryFilter, w io.Writer, options columnar.ParquetOptions) (*Report, error) {
+	ids, docs, report, err := exportAll(ctx, collection, filters)
+	if err != nil {
+		return report, err
+	}
+	if err := columnar.WriteParquet(w, columnar.Infer(ids, docs), options); err != nil {
+		return report, fmt.Errorf("failed to export collection '%s': %w", collection, err)
+	}
+	return report, nil
+}
+
+// ExportArrow es como ExportParquet pero escribe el formato de archivo IPC de Arrow
+// (Feather v2)
+func ExportArrow(ctx context.Context, collection string, filters []firebase.QueryFilter, w io.Writer, options columnar.ArrowOptions) (*Report, error) {
+	ids, docs, report, err := exportAll(ctx, collection, filters)
+	if err != nil {
+		return report, err
+	}
+	if err := columnar.WriteArrow(w, columnar.Infer(ids, docs), options); err != nil {
+		return report, fmt.Errorf("failed to export collection '%s': %w", collection, err)
+	}
+	return report, nil
+}
+
+// exportAll lee y transforma todos los documentos de la colección que cumplen filters
+func exportAll(ctx context.Context, collection string, filters []firebase.QueryFilter) ([]string, []map[string]interface{}, *Report, error) {
+	var ids []string
+	var docs []map[string]interface{}
+	report := &Report{}
+	options := firebase.QueryOptions{Filters: filters, Limit: exportPageSize}
+	for {
+		result, err := firestore.QueryDocumentsWithMeta(ctx, collection, options)
+		if err != nil {
+			return nil, nil, report, fmt.Errorf("failed to export collection '%s': %w", collection, err)
+		}
+		for _, doc := range result.Documents {
+			ids = append(ids, doc.ID)
+			docs = append(docs, doc.Data)
+		}
+		if !result.Truncated || result.NextCursor == "" {
+			break
+		}
+		options.Cursor = result.NextCursor
+	}
+
+	transformed, applied := ApplyAll(collection, docs)
+	*report = applied
+	var keptIDs []string
+	var kept []map[string]interface{}
+	for i, data := range transformed {
+		if data != nil {
+			keptIDs = append(keptIDs, ids[i])
+			kept = append(kept, data)
+		}
+	}
+	return keptIDs, kept, report, nil
+}
@@ -0,0 +1,355 @@
+// Package apikeys emite claves de API públicas de solo lectura, limitadas a ciertas
+// colecciones y vistas (firestore.RunView) y con una cuota por ventana de tiempo, para
+// exponer datos públicos sin sesión. La clave se entrega una sola vez al emitirla; en
+// Firestore solo se guarda su hash.
+//
+//	key, secret, err := apikeys.Issue(ctx, apikeys.Options{Name: "catálogo", Collections: []string{"products"}, Quota: 1000, Window: time.Hour})
+//	apikeys.Enable()
+//	mux.Handle("/public/", apikeys.Middleware(publicAPI))
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/ratelimit"
+)
+
+// Collection colección donde se guardan las claves (el documento es el ID de la clave)
+const Collection = "api_keys"
+
+// keyPrefix prefijo de las claves emitidas: "pk_<id>_<secreto>"
+const keyPrefix = "pk_"
+
+var (
+	// CacheTTL tiempo que se reutiliza una clave validada; una clave revocada en otra
+	// instancia puede seguir aceptándose hasta CacheTTL
+	CacheTTL = time.Minute
+	// QuotaShards si es mayor que 1, la cuota se cuenta con ratelimit.AllowSharded:
+	// aproximada pero sin contención en claves con mucho tráfico
+	QuotaShards = 0
+)
+
+// APIKey clave de API de solo lectura
+type APIKey struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Collections []string  `json:"collections,omitempty"`
+	Views       []string  `json:"views,omitempty"`
+	Quota       int       `json:"quota"`  // lecturas permitidas por Window (0 = sin cuota)
+	Window      int64     `json:"window"` // segundos
+	Revoked     bool      `json:"revoked"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+}
+
+// Options datos de una clave nueva
+type Options struct {
+	Name        string
+	Collections []string // colecciones que se pueden leer directamente
+	Views       []string // vistas que se pueden ejecutar con RunView
+	Quota       int
+	Window      time.Duration // por defecto una hora
+	TTL         time.Duration // 0 = no expira
+}
+
+// AllowsCollection indica si la clave puede leer la colección
+func (k *APIKey) AllowsCollection(collection string) bool {
+	return contains(k.Collections, collection)
+}
+
+// AllowsView indica si la clave puede ejecutar la vista
+func (k *APIKey) AllowsView(name string) bool {
+	return contains(k.Views, name)
+}
+
+type cachedKey struct {
+	key        *APIKey
+	secretHash string
+	expiresAt  time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]cachedKey{}
+)
+
+// Issue crea una clave y retorna su definición y el valor secreto que debe entregarse
+// al cliente (no se puede recuperar después)
+func Issue(ctx context.Context, options Options) (*APIKey, string, error) {
+	if options.Name == "" || len(options.Collections)+len(options.Views) == 0 {
+		return nil, "", fmt.Errorf("api key requires a name and at least one collection or view")
+	}
+	if options.Quota < 0 {
+		return nil, "", fmt.Errorf("api key quota cannot be negative")
+	}
+	if options.Window <= 0 {
+		options.Window = time.Hour
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key id: %w", err)
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key secret: %w", err)
+	}
+
+	key := &APIKey{
+		ID:          id,
+		Name:        options.Name,
+		Collections: options.Collections,
+		Views:       options.Views,
+		Quota:       options.Quota,
+		Window:      int64(options.Window / time.Second),
+		CreatedAt:   firebase.Now(),
+	}
+	data := map[string]interface{}{
+		"name":        key.Name,
+		"collections": key.Collections,
+		"views":       key.Views,
+		"quota":       key.Quota,
+		"window":      key.Window,
+		"revoked":     false,
+		"secret_hash": hashSecret(secret),
+		"created_at":  key.CreatedAt,
+	}
+	if options.TTL > 0 {
+		key.ExpiresAt = key.CreatedAt.Add(options.TTL)
+		data["expires_at"] = key.ExpiresAt
+	}
+	if _, err := firebase.GetFirestoreClient().Collection(Collection).Doc(id).Create(ctx, data); err != nil {
+		return nil, "", fmt.Errorf("failed to save api key '%s': %w", options.Name, err)
+	}
+	return key, keyPrefix + id + "_" + secret, nil
+}
+
+// Revoke revoca la clave
+func Revoke(ctx context.Context, id string) error {
+	_, err := firebase.GetFirestoreClient().Collection(Collection).Doc(id).Update(ctx, []gfirestore.Update{
+		{Path: "revoked", Value: true},
+		{Path: "revoked_at", Value: firebase.Now()},
+	})
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return &firebase.DocumentNotFoundError{Collection: Collection, DocumentID: id}
+		}
+		return fmt.Errorf("failed to revoke api key '%s': %w", id, err)
+	}
+	cacheMu.Lock()
+	delete(cache, id)
+	cacheMu.Unlock()
+	return nil
+}
+
+// Get obtiene la definición de una clave
+func Get(ctx context.Context, id string) (*APIKey, error) {
+	key, _, err := load(ctx, id)
+	return key, err
+}
+
+// List lista todas las claves (sin sus secretos)
+func List(ctx context.Context) ([]*APIKey, error) {
+	docs, err := firestore.QueryDocuments(ctx, Collection, firebase.QueryOptions{OrderBy: "created_at"})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*APIKey, 0, len(docs))
+	for _, doc := range docs {
+		keys = append(keys, keyFromDocument(doc))
+	}
+	return keys, nil
+}
+
+// Validate verifica la clave presentada por un cliente y retorna su definición, o un
+// *firebase.APIKeyError si no existe, fue revocada o expiró. No consume cuota.
+func Validate(ctx context.Context, presented string) (*APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(presented, keyPrefix), "_")
+	if !ok || !strings.HasPrefix(presented, keyPrefix) || id == "" || strings.Contains(id, "/") {
+		return nil, &firebase.APIKeyError{Reason: "invalid"}
+	}
+	key, secretHash, err := load(ctx, id)
+	if err != nil {
+		if errors.Is(err, firebase.ErrDocumentNotFound) {
+			return nil, &firebase.APIKeyError{Reason: "invalid"}
+		}
+		return nil, err
+	}
+	switch {
+	case subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(secretHash)) != 1:
+		return nil, &firebase.APIKeyError{Reason: "invalid"}
+	case key.Revoked:
+		return nil, &firebase.APIKeyError{KeyID: id, Reason: "revoked"}
+	case !key.ExpiresAt.IsZero() && firebase.Now().After(key.ExpiresAt):
+		return nil, &firebase.APIKeyError{KeyID: id, Reason: "expired"}
+	}
+	return key, nil
+}
+
+// Consume contabiliza una petición en la cuota de la clave. Retorna el resultado del
+// contador (nil con QuotaShards o sin cuota) y un *firebase.APIKeyError con "quota" si
+// se agotó.
+func Consume(ctx context.Context, key *APIKey) (*ratelimit.Result, error) {
+	if key.Quota <= 0 {
+		return nil, nil
+	}
+	window := time.Duration(key.Window) * time.Second
+	if window <= 0 {
+		window = time.Hour
+	}
+	counter := "api_key:" + key.ID
+	if QuotaShards > 1 {
+		allowed, err := ratelimit.AllowSharded(ctx, counter, key.Quota, window, QuotaShards)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, &firebase.APIKeyError{KeyID: key.ID, Reason: "quota"}
+		}
+		return nil, nil
+	}
+	result, err := ratelimit.Check(ctx, counter, key.Quota, window)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Allowed {
+		return result, &firebase.APIKeyError{KeyID: key.ID, Reason: "quota", RetryAt: result.ResetAt}
+	}
+	return result, nil
+}
+
+// load lee la clave y el hash de su secreto (con caché de CacheTTL)
+func load(ctx context.Context, id string) (*APIKey, string, error) {
+	cacheMu.Lock()
+	cached, ok := cache[id]
+	cacheMu.Unlock()
+	hit := ok && firebase.Now().Before(cached.expiresAt)
+	firebase.RecordCacheLookup("api_keys", hit)
+	if hit {
+		return cached.key, cached.secretHash, nil
+	}
+
+	snap, err := firebase.GetFirestoreClient().Collection(Collection).Doc(id).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, "", &firebase.DocumentNotFoundError{Collection: Collection, DocumentID: id}
+		}
+		return nil, "", fmt.Errorf("failed to get api key '%s': %w", id, err)
+	}
+	doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+	key := keyFromDocument(doc)
+	secretHash, _ := doc.GetString("secret_hash")
+
+	cacheMu.Lock()
+	cache[id] = cachedKey{key: key, secretHash: secretHash, expiresAt: firebase.Now().Add(CacheTTL)}
+	cacheMu.Unlock()
+	return key, secretHash, nil
+}
+
+func keyFromDocument(doc *firebase.Document) *APIKey {
+	key := &APIKey{ID: doc.ID}
+	key.Name, _ = doc.GetString("name")
+	key.Collections, _ = doc.GetStringSlice("collections")
+	key.Views, _ = doc.GetStringSlice("views")
+	if quota, ok := doc.GetInt("quota"); ok {
+		key.Quota = int(quota)
+	}
+	key.Window, _ = doc.GetInt("window")
+	key.Revoked, _ = doc.GetBool("revoked")
+	key.CreatedAt, _ = doc.GetTime("created_at")
+	key.ExpiresAt, _ = doc.GetTime("expires_at")
+	return key
+}
+
+// Middleware exige una clave válida en la cabecera X-API-Key (o Authorization: Bearer),
+// consume su cuota y la guarda en el contexto (ver FromContext). Solo admite GET y HEAD.
+// Responde las cabeceras X-RateLimit-* cuando la cuota se cuenta de forma exacta.
+// Registra el interceptor de alcance (ver Enable) si no está activo y rechaza las
+// peticiones si después se quita, porque sin él la clave no limitaría nada.
+func Middleware(next http.Handler) http.Handler {
+	if !firebase.HasInterceptor(interceptorName) {
+		Enable()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !firebase.HasInterceptor(interceptorName) {
+			http.Error(w, "el alcance de las claves de API no está habilitado", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "la clave de API es de solo lectura", http.StatusMethodNotAllowed)
+			return
+		}
+		presented := r.Header.Get("X-API-Key")
+		if presented == "" {
+			presented = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+
+		key, err := Validate(r.Context(), presented)
+		var keyErr *firebase.APIKeyError
+		if err != nil {
+			if errors.As(err, &keyErr) {
+				http.Error(w, "clave de API inválida", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "no se pudo verificar la clave de API", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		result, err := Consume(r.Context(), key)
+		if result != nil {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(key.Quota))
+			w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+		}
+		if err != nil {
+			if errors.As(err, &keyErr) {
+				if !keyErr.RetryAt.IsZero() {
+					w.Header().Set("Retry-After", strconv.Itoa(int(keyErr.RetryAt.Sub(firebase.Now()).Seconds())+1))
+				}
+				http.Error(w, "se agotó la cuota de la clave de API", http.StatusTooManyRequests)
+			} else {
+				http.Error(w, "no se pudo verificar la clave de API", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithAPIKey(r.Context(), key)))
+	})
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
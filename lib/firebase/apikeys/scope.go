@@ -0,0 +1,81 @@
+package apikeys
+
+import (
+	"context"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+const interceptorName = "apikeys"
+
+type keyContextKey struct{}
+
+type viewContextKey struct{}
+
+// WithAPIKey retorna un contexto que transporta la clave validada
+func WithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// FromContext obtiene la clave guardada en el contexto (si existe)
+func FromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(*APIKey)
+	return key, ok && key != nil
+}
+
+// Enable registra el interceptor que limita las llamadas hechas con un contexto que
+// transporta una clave: solo lecturas (get y query) sobre sus colecciones, o sobre la
+// colección de una vista ejecutada con RunView. Las colecciones se comparan por su nombre
+// lógico (ver firebase.LogicalCollection), por lo que el orden respecto de tenancy no
+// importa. Middleware lo registra si no está activo.
+func Enable() {
+	firebase.RegisterInterceptor(firebase.Interceptor{Name: interceptorName, Before: enforceScope})
+}
+
+// Disable quita el interceptor de alcance de las claves; Middleware rechaza las peticiones
+// mientras no esté activo
+func Disable() {
+	firebase.UnregisterInterceptor(interceptorName)
+}
+
+// RunView ejecuta la vista si la clave del contexto la permite
+func RunView(ctx context.Context, name string, params map[string]interface{}) ([]*firebase.Document, error) {
+	key, ok := FromContext(ctx)
+	if !ok {
+		return nil, firebase.ErrUnauthenticated
+	}
+	if !key.AllowsView(name) {
+		return nil, &firebase.PermissionDeniedError{Collection: firestore.ViewsCollection, DocumentID: name, Operation: firebase.OperationRead}
+	}
+	view, err := firestore.GetView(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return firestore.RunView(context.WithValue(ctx, viewContextKey{}, view.Collection), name, params)
+}
+
+func enforceScope(ctx context.Context, call *firebase.Call) error {
+	key, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	operation := firebase.OperationRead
+	switch call.Operation {
+	case firebase.CallFirestoreGet, firebase.CallFirestoreQuery:
+		logical := firebase.LogicalCollection(call.Collection)
+		if key.AllowsCollection(logical) {
+			return nil
+		}
+		if collection, ok := ctx.Value(viewContextKey{}).(string); ok && collection == logical {
+			return nil
+		}
+	case firebase.CallFirestoreDelete:
+		operation = firebase.OperationDelete
+	case firebase.CallFirestoreCreate:
+		operation = firebase.OperationCreate
+	default:
+		operation = firebase.OperationUpdate
+	}
+	return &firebase.PermissionDeniedError{Collection: call.Collection, DocumentID: call.DocumentID, Operation: operation}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"firebase.google.com/go/v4/auth"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/mailer"
+)
+
+// GeneratePasswordResetLink genera un enlace para restablecer la contraseña
+func GeneratePasswordResetLink(ctx context.Context, email string, settings *firebase.ActionCodeSettings) (string, error) {
+	client := firebase.GetAuthClient()
+
+	var link string
+	var err error
+	if settings != nil {
+		link, err = client.PasswordResetLinkWithSettings(ctx, email, toActionCodeSettings(settings))
+	} else {
+		link, err = client.PasswordResetLink(ctx, email)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate password reset link: %w", err)
+	}
+	return link, nil
+}
+
+// GenerateEmailVerificationLink genera un enlace para verificar el email
+func GenerateEmailVerificationLink(ctx context.Context, email string, settings *firebase.ActionCodeSettings) (string, error) {
+	client := firebase.GetAuthClient()
+
+	var link string
+	var err error
+	if settings != nil {
+		link, err = client.EmailVerificationLinkWithSettings(ctx, email, toActionCodeSettings(settings))
+	} else {
+		link, err = client.EmailVerificationLink(ctx, email)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate email verification link: %w", err)
+	}
+	return link, nil
+}
+
+// GenerateSignInWithEmailLink genera un enlace de inicio de sesión por email (settings es obligatorio)
+func GenerateSignInWithEmailLink(ctx context.Context, email string, settings *firebase.ActionCodeSettings) (string, error) {
+	if settings == nil || settings.ContinueURL == "" {
+		return "", fmt.Errorf("continue URL is required for sign-in links")
+	}
+
+	client := firebase.GetAuthClient()
+	link, err := client.EmailSignInLink(ctx, email, toActionCodeSettings(settings))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate sign-in link: %w", err)
+	}
+	return link, nil
+}
+
+// SendPasswordResetEmail genera el enlace de restablecimiento y lo envía por correo
+func SendPasswordResetEmail(ctx context.Context, email string, settings *firebase.ActionCodeSettings) error {
+	link, err := GeneratePasswordResetLink(ctx, email, settings)
+	if err != nil {
+		return err
+	}
+	return mailer.SendTemplate(ctx, email, "password_reset", "", map[string]interface{}{"Link": link})
+}
+
+// SendEmailVerification genera el enlace de verificación y lo envía por correo
+func SendEmailVerification(ctx context.Context, email string, settings *firebase.ActionCodeSettings) error {
+	link, err := GenerateEmailVerificationLink(ctx, email, settings)
+	if err != nil {
+		return err
+	}
+	return mailer.SendTemplate(ctx, email, "email_verification", "", map[string]interface{}{"Link": link})
+}
+
+// SendSignInWithEmailLink genera el enlace de inicio de sesión y lo envía por correo
+func SendSignInWithEmailLink(ctx context.Context, email string, settings *firebase.ActionCodeSettings) error {
+	link, err := GenerateSignInWithEmailLink(ctx, email, settings)
+	if err != nil {
+		return err
+	}
+	return mailer.SendTemplate(ctx, email, "sign_in_link", "", map[string]interface{}{"Link": link})
+}
+
+// toActionCodeSettings convierte firebase.ActionCodeSettings a auth.ActionCodeSettings
+func toActionCodeSettings(settings *firebase.ActionCodeSettings) *auth.ActionCodeSettings {
+	return &auth.ActionCodeSettings{
+		URL:                   settings.ContinueURL,
+		HandleCodeInApp:       settings.HandleCodeInApp,
+		IOSBundleID:           settings.IOSBundleID,
+		AndroidPackageName:    settings.AndroidPackageName,
+		AndroidMinimumVersion: settings.AndroidMinimumVersion,
+		AndroidInstallApp:     settings.AndroidInstallApp,
+		DynamicLinkDomain:     settings.DynamicLinkDomain,
+	}
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// AuditCollection colección con el registro de auditoría de acciones administrativas
+const AuditCollection = "audit_logs"
+
+// writeAuditLog registra una acción realizada por actorUID sobre targetUID
+func writeAuditLog(ctx context.Context, action, actorUID, targetUID string, details map[string]interface{}) error {
+	data := map[string]interface{}{
+		"action":     action,
+		"actor_uid":  actorUID,
+		"target_uid": targetUID,
+	}
+	if len(details) > 0 {
+		data["details"] = details
+	}
+	if _, err := firestore.CreateDocument(ctx, AuditCollection, data); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
@@ -12,7 +12,18 @@ import (
 
 // CreateUser crea un nuevo usuario sin contraseña
 func CreateUser(ctx context.Context, request firebase.CreateUserRequest) (*firebase.UserRecord, error) {
+	return createUser(ctx, request, "", nil)
+}
+
+// createUser crea el usuario en Auth y su perfil espejo con el estado indicado (vacío:
+// activo, o suspendido si se crea deshabilitado) y los campos extra
+func createUser(ctx context.Context, request firebase.CreateUserRequest, state AccountState, extra map[string]interface{}) (_ *firebase.UserRecord, err error) {
 	client := firebase.GetAuthClient()
+	call := newCall(firebase.CallAuthCreateUser, "", &request)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
 	params := (&auth.UserToCreate{}).
 		Email(request.Email).
 		Disabled(request.Disabled)
@@ -28,12 +39,40 @@ func CreateUser(ctx context.Context, request firebase.CreateUserRequest) (*fireb
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
+
+	// Crear el perfil espejo con el estado inicial de la cuenta
+	if state == "" {
+		state = StateActive
+		if request.Disabled {
+			state = StateSuspended
+		}
+	}
+	profile := map[string]interface{}{
+		"email":        record.Email,
+		"display_name": record.DisplayName,
+		"state":        string(state),
+	}
+	for key, value := range extra {
+		profile[key] = value
+	}
+	if err := syncUserProfile(ctx, record.UID, profile); err != nil {
+		// Sin perfil espejo el usuario quedaría a medias: se elimina de Auth
+		if deleteErr := client.DeleteUser(ctx, record.UID); deleteErr != nil {
+			return nil, fmt.Errorf("%w (failed to delete user '%s' after profile error: %v)", err, record.UID, deleteErr)
+		}
+		return nil, err
+	}
 	return mapUserRecord(record), nil
 }
 
 // GetUser obtiene un usuario por UID
-func GetUser(ctx context.Context, uid string) (*firebase.UserRecord, error) {
+func GetUser(ctx context.Context, uid string) (_ *firebase.UserRecord, err error) {
 	client := firebase.GetAuthClient()
+	call := newCall(firebase.CallAuthGetUser, uid, nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
 	record, err := client.GetUser(ctx, uid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
@@ -42,8 +81,13 @@ func GetUser(ctx context.Context, uid string) (*firebase.UserRecord, error) {
 }
 
 // GetUserByEmail obtiene un usuario por email
-func GetUserByEmail(ctx context.Context, email string) (*firebase.UserRecord, error) {
+func GetUserByEmail(ctx context.Context, email string) (_ *firebase.UserRecord, err error) {
 	client := firebase.GetAuthClient()
+	call := newCall(firebase.CallAuthGetUser, "", email)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
 	record, err := client.GetUserByEmail(ctx, email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
@@ -52,8 +96,13 @@ func GetUserByEmail(ctx context.Context, email string) (*firebase.UserRecord, er
 }
 
 // UpdateUser actualiza un usuario existente
-func UpdateUser(ctx context.Context, uid string, request firebase.UpdateUserRequest) (*firebase.UserRecord, error) {
+func UpdateUser(ctx context.Context, uid string, request firebase.UpdateUserRequest) (_ *firebase.UserRecord, err error) {
 	client := firebase.GetAuthClient()
+	call := newCall(firebase.CallAuthUpdateUser, uid, &request)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
 	params := (&auth.UserToUpdate{})
 
 	if request.Email != nil {
@@ -93,17 +142,31 @@ func CreateCustomToken(ctx context.Context, uid string, claims map[string]interf
 }
 
 // SetCustomClaims establece claims personalizados para un usuario
-func SetCustomClaims(ctx context.Context, uid string, claims map[string]interface{}) error {
+func SetCustomClaims(ctx context.Context, uid string, claims map[string]interface{}) (err error) {
 	client := firebase.GetAuthClient()
+	call := newCall(firebase.CallAuthSetClaims, uid, claims)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
 	if err := client.SetCustomUserClaims(ctx, uid, claims); err != nil {
 		return fmt.Errorf("failed to set custom claims: %w", err)
 	}
+	// Auth es la fuente de verdad; user_claims es la copia que leen las sesiones
+	if err := setUserClaimsDocument(ctx, uid, claims); err != nil {
+		return fmt.Errorf("failed to mirror custom claims: %w", err)
+	}
 	return nil
 }
 
 // ListUsers lista usuarios con paginación
-func ListUsers(ctx context.Context, maxResults int, pageToken string) ([]*firebase.UserRecord, string, error) {
+func ListUsers(ctx context.Context, maxResults int, pageToken string) (_ []*firebase.UserRecord, _ string, err error) {
 	client := firebase.GetAuthClient()
+	call := newCall(firebase.CallAuthListUsers, "", pageToken)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, "", err
+	}
 	iterator := client.Users(ctx, pageToken)
 	var users []*firebase.UserRecord
 	count := 0
@@ -142,8 +205,13 @@ func UserExists(ctx context.Context, uid string) (bool, error) {
 }
 
 // ListAllUsers es una función auxiliar para obtener todos los usuarios
-func ListAllUsers(ctx context.Context) ([]*firebase.UserRecord, error) {
+func ListAllUsers(ctx context.Context) (_ []*firebase.UserRecord, err error) {
 	client := firebase.GetAuthClient()
+	call := newCall(firebase.CallAuthListUsers, "", nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
 	iterator := client.Users(ctx, "")
 	var users []*firebase.UserRecord
 	for {
@@ -169,6 +237,16 @@ func GetUserCount(ctx context.Context) (int, error) {
 }
 
 
+// newCall describe una llamada a Auth para la cadena de interceptores (DocumentID es el UID)
+func newCall(operation, uid string, payload interface{}) *firebase.Call {
+	return &firebase.Call{Operation: operation, DocumentID: uid, Index: -1, Payload: payload}
+}
+
+// finishCall ejecuta los hooks After con el error final (pensado para usarse con defer)
+func finishCall(ctx context.Context, call *firebase.Call, err *error) {
+	firebase.FinishCall(ctx, call, *err)
+}
+
 // mapUserRecord convierte auth.UserRecord a firebase.UserRecord
 func mapUserRecord(record *auth.UserRecord) *firebase.UserRecord {
 	return &firebase.UserRecord{
@@ -185,8 +263,13 @@ func mapUserRecord(record *auth.UserRecord) *firebase.UserRecord {
 }
 
 
-func DeleteUser(ctx context.Context, uid string) error {
+func DeleteUser(ctx context.Context, uid string) (err error) {
 	client := firebase.GetAuthClient()
+	call := newCall(firebase.CallAuthDeleteUser, uid, nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
 	if err := client.DeleteUser(ctx, uid); err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
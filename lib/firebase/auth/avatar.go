@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/storage"
+)
+
+var (
+	// AvatarOptions validación y tamaños de los avatares
+	AvatarOptions = storage.ImageOptions{
+		AllowedTypes: []string{"image/jpeg", "image/png"},
+		MaxBytes:     5 * 1024 * 1024,
+		Sizes:        []int{64, 256, 512},
+	}
+	// AvatarPhotoSize variante usada como PhotoURL del usuario en Auth
+	AvatarPhotoSize = 256
+)
+
+// AvatarResult avatar guardado de un usuario
+type AvatarResult struct {
+	ImageID  string            `json:"image_id"`
+	PhotoURL string            `json:"photo_url"`
+	Variants map[string]string `json:"variants"` // nombre de la variante -> URL
+}
+
+// SetUserAvatar valida la imagen, la guarda con sus miniaturas en Cloud Storage
+// (storage.UploadImage en "avatars/<uid>/"), actualiza el PhotoURL del usuario en Auth y
+// el perfil espejo, y elimina el avatar anterior. Si algún paso falla se deshacen los
+// anteriores.
+func SetUserAvatar(ctx context.Context, uid string, reader io.Reader) (*AvatarResult, error) {
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	previousID := ""
+	if profile, err := GetUserProfile(ctx, uid); err == nil {
+		previousID, _ = profile.GetString("avatar_image_id")
+	} else if !firestore.IsNotFound(err) {
+		return nil, err
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, fmt.Errorf("error generating avatar token: %w", err)
+	}
+	options := AvatarOptions
+	options.Metadata = map[string]string{"firebaseStorageDownloadTokens": token, "uid": uid}
+	name := fmt.Sprintf("avatars/%s/%d", uid, firebase.Now().UnixNano())
+	record, err := storage.UploadImage(ctx, name, reader, options)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AvatarResult{ImageID: record.ID, Variants: map[string]string{}}
+	result.Variants[record.Original.Name] = storage.DownloadTokenURL(record.Original.Path, token)
+	result.PhotoURL = result.Variants[record.Original.Name]
+	for _, variant := range record.Variants {
+		result.Variants[variant.Name] = storage.DownloadTokenURL(variant.Path, token)
+		if variant.Name == strconv.Itoa(AvatarPhotoSize) {
+			result.PhotoURL = result.Variants[variant.Name]
+		}
+	}
+
+	cleanup := func() {
+		if err := storage.DeleteImage(ctx, record.ID); err != nil {
+			log.Printf("⚠️  Error deleting avatar '%s' after failure: %v", record.ID, err)
+		}
+	}
+	if _, err := UpdateUser(ctx, uid, firebase.UpdateUserRequest{PhotoURL: &result.PhotoURL}); err != nil {
+		cleanup()
+		return nil, err
+	}
+	variants := make(map[string]interface{}, len(result.Variants))
+	for name, url := range result.Variants {
+		variants[name] = url
+	}
+	if err := syncUserProfile(ctx, uid, map[string]interface{}{
+		"photo_url":         result.PhotoURL,
+		"avatar_image_id":   result.ImageID,
+		"avatar_variants":   variants,
+		"avatar_updated_at": firebase.Now(),
+	}); err != nil {
+		if _, rollbackErr := UpdateUser(ctx, uid, firebase.UpdateUserRequest{PhotoURL: &user.PhotoURL}); rollbackErr != nil {
+			log.Printf("⚠️  Error restoring photo URL of '%s' after failed profile sync: %v", uid, rollbackErr)
+		}
+		cleanup()
+		return nil, err
+	}
+
+	if previousID != "" && previousID != record.ID {
+		if err := storage.DeleteImage(ctx, previousID); err != nil && !firestore.IsNotFound(err) {
+			log.Printf("⚠️  Error deleting previous avatar '%s': %v", previousID, err)
+		}
+	}
+	return result, nil
+}
+
+// RemoveUserAvatar quita el avatar del usuario en Auth y en el perfil y elimina sus
+// archivos
+func RemoveUserAvatar(ctx context.Context, uid string) error {
+	profile, err := GetUserProfile(ctx, uid)
+	if err != nil && !firestore.IsNotFound(err) {
+		return err
+	}
+	empty := ""
+	if _, err := UpdateUser(ctx, uid, firebase.UpdateUserRequest{PhotoURL: &empty}); err != nil {
+		return err
+	}
+	if profile == nil {
+		return nil
+	}
+
+	if imageID, _ := profile.GetString("avatar_image_id"); imageID != "" {
+		if err := storage.DeleteImage(ctx, imageID); err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+	}
+	if err := firestore.UpdateDocumentFields(ctx, UsersCollection, uid, []gfirestore.Update{
+		{Path: "photo_url", Value: gfirestore.Delete},
+		{Path: "avatar_image_id", Value: gfirestore.Delete},
+		{Path: "avatar_variants", Value: gfirestore.Delete},
+	}); err != nil {
+		return fmt.Errorf("failed to sync profile for user '%s': %w", uid, err)
+	}
+	return nil
+}
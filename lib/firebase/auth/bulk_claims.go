@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// ClaimSelector selecciona los usuarios afectados por BulkSetClaims. Sin Claim selecciona
+// a todos; con Claim selecciona a quienes tienen ese claim (igual a Value si no es nil).
+type ClaimSelector struct {
+	Claim string      `json:"claim,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// BulkClaimsOptions opciones de BulkSetClaims
+type BulkClaimsOptions struct {
+	Concurrency int  `json:"concurrency,omitempty"` // por defecto 10
+	MaxRetries  int  `json:"max_retries,omitempty"` // reintentos por usuario, por defecto 3
+	DryRun      bool `json:"dry_run,omitempty"`     // solo calcula los cambios
+}
+
+// ClaimChange cambio de claims calculado para un usuario
+type ClaimChange struct {
+	UID    string                 `json:"uid"`
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+}
+
+// BulkClaimsReport resultado de BulkSetClaims
+type BulkClaimsReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Scanned   int               `json:"scanned"`
+	Matched   int               `json:"matched"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Failed    map[string]string `json:"failed,omitempty"`  // UID -> último error
+	Changes   []ClaimChange     `json:"changes,omitempty"` // solo en DryRun
+}
+
+// BulkSetClaims recorre los usuarios de Auth sin cargarlos todos en memoria y aplica
+// claims a los que coinciden con selector (un valor nil elimina el claim). Las
+// actualizaciones se hacen en paralelo con concurrencia acotada y reintentos.
+func BulkSetClaims(ctx context.Context, selector ClaimSelector, claims map[string]interface{}, options BulkClaimsOptions) (*BulkClaimsReport, error) {
+	if len(claims) == 0 {
+		return nil, fmt.Errorf("no claims to apply")
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 10
+	}
+	if options.MaxRetries <= 0 {
+		options.MaxRetries = 3
+	}
+
+	report := &BulkClaimsReport{DryRun: options.DryRun, Failed: map[string]string{}}
+	var mu sync.Mutex
+	changes := make(chan ClaimChange)
+
+	var wg sync.WaitGroup
+	for i := 0; i < options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for change := range changes {
+				err := applyClaimsWithRetry(ctx, change, options.MaxRetries)
+				mu.Lock()
+				if err != nil {
+					report.Failed[change.UID] = err.Error()
+				} else {
+					report.Updated++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var listErr error
+	users := firebase.GetAuthClient().Users(ctx, "")
+	for {
+		record, err := users.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			listErr = fmt.Errorf("failed to list users: %w", err)
+			break
+		}
+
+		mu.Lock()
+		report.Scanned++
+		mu.Unlock()
+		if !selector.matches(record.CustomClaims) {
+			continue
+		}
+
+		change := ClaimChange{UID: record.UID, Before: record.CustomClaims, After: mergeClaims(record.CustomClaims, claims)}
+		mu.Lock()
+		report.Matched++
+		unchanged := claimsEqual(normalizeClaims(change.Before), change.After)
+		if unchanged {
+			report.Unchanged++
+		} else if options.DryRun {
+			report.Changes = append(report.Changes, change)
+		}
+		mu.Unlock()
+
+		if unchanged || options.DryRun {
+			continue
+		}
+		select {
+		case changes <- change:
+		case <-ctx.Done():
+			listErr = ctx.Err()
+		}
+		if listErr != nil {
+			break
+		}
+	}
+
+	close(changes)
+	wg.Wait()
+
+	if len(report.Failed) == 0 {
+		report.Failed = nil
+	}
+	return report, listErr
+}
+
+func (s ClaimSelector) matches(claims map[string]interface{}) bool {
+	if s.Claim == "" {
+		return true
+	}
+	value, ok := claims[s.Claim]
+	if !ok {
+		return false
+	}
+	return s.Value == nil || claimsEqual(value, s.Value)
+}
+
+// mergeClaims aplica changes sobre current sin modificarlo; un valor nil elimina el claim
+func mergeClaims(current, changes map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current)+len(changes))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+func normalizeClaims(claims map[string]interface{}) map[string]interface{} {
+	if claims == nil {
+		return map[string]interface{}{}
+	}
+	return claims
+}
+
+// claimsEqual compara valores de claims sin distinguir tipos numéricos: Auth los
+// decodifica de JSON como float64 y Firestore o el llamador pueden usar int o int64
+func claimsEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeClaimValue(a), normalizeClaimValue(b))
+}
+
+func normalizeClaimValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeClaimValue(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeClaimValue(item)
+		}
+		return normalized
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+func applyClaimsWithRetry(ctx context.Context, change ClaimChange, maxRetries int) error {
+	var err error
+	backoff := 200 * time.Millisecond
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+		}
+
+		if err = SetCustomClaims(ctx, change.UID, change.After); err == nil {
+			return nil
+		}
+	}
+	return err
+}
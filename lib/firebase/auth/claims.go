@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Almacenamiento de claims
+//
+// Los custom claims de Firebase Auth son la fuente de verdad: viajan en los ID tokens y
+// los valida el propio Firebase. El documento user_claims/{uid} (campo claims) es una
+// copia que leen las sesiones del paquete (ValidateSession, login) para no consultar Auth
+// en cada petición. SetCustomClaims, UpdateClaims y ModifyClaims escriben primero en Auth
+// y después la copia. Si user_claims se edita por fuera del paquete, SyncClaimsToAuth
+// publica ese cambio en Auth; ante cualquier otra diferencia prevalece Auth
+// (SyncClaimsFromAuth). MigrateClaims reconcilia los datos creados antes de unificarlos.
+
+// errMalformedClaims el campo claims de user_claims no es un mapa
+var errMalformedClaims = errors.New("malformed claims")
+
+// ModifyClaims lee los claims actuales de Auth, aplica fn sobre una copia y guarda el
+// resultado en ambos almacenes. Auth no ofrece transacciones: dos modificaciones
+// concurrentes del mismo usuario pueden pisarse.
+func ModifyClaims(ctx context.Context, uid string, fn func(claims map[string]interface{})) error {
+	record, err := firebase.GetAuthClient().GetUser(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("failed to get user claims: %w", err)
+	}
+	claims := copyClaims(record.CustomClaims)
+	fn(claims)
+	return SetCustomClaims(ctx, uid, claims)
+}
+
+// UpdateClaims combina changes con los claims actuales del usuario (un valor nil elimina
+// el claim)
+func UpdateClaims(ctx context.Context, uid string, changes map[string]interface{}) error {
+	return ModifyClaims(ctx, uid, func(claims map[string]interface{}) {
+		for key, value := range changes {
+			if value == nil {
+				delete(claims, key)
+			} else {
+				claims[key] = value
+			}
+		}
+	})
+}
+
+// SyncClaimsFromAuth sobrescribe la copia de user_claims con los claims de Auth
+func SyncClaimsFromAuth(ctx context.Context, uid string) error {
+	record, err := firebase.GetAuthClient().GetUser(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("failed to get user claims: %w", err)
+	}
+	return setUserClaimsDocument(ctx, uid, copyClaims(record.CustomClaims))
+}
+
+// SyncClaimsToAuth publica en Auth los claims guardados en user_claims (p. ej. tras una
+// edición manual del documento)
+func SyncClaimsToAuth(ctx context.Context, uid string) error {
+	claims, err := getUserClaims(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("failed to get stored claims: %w", err)
+	}
+	if err := firebase.GetAuthClient().SetCustomUserClaims(ctx, uid, claims); err != nil {
+		return fmt.Errorf("failed to set custom claims: %w", err)
+	}
+	return nil
+}
+
+// ClaimsMigrationReport resultado de MigrateClaims
+type ClaimsMigrationReport struct {
+	DryRun    bool                `json:"dry_run"`
+	Scanned   int                 `json:"scanned"`
+	InSync    int                 `json:"in_sync"`
+	Migrated  int                 `json:"migrated"`
+	Conflicts map[string][]string `json:"conflicts,omitempty"` // UID -> claims con valores distintos (ganó Auth)
+	Failed    map[string]string   `json:"failed,omitempty"`
+}
+
+// MigrateClaims reconcilia los claims de todos los usuarios: combina user_claims con los
+// de Auth (Auth prevalece en los conflictos) y escribe el resultado en ambos almacenes
+func MigrateClaims(ctx context.Context, dryRun bool) (*ClaimsMigrationReport, error) {
+	report := &ClaimsMigrationReport{DryRun: dryRun, Conflicts: map[string][]string{}, Failed: map[string]string{}}
+
+	users := firebase.GetAuthClient().Users(ctx, "")
+	for {
+		record, err := users.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to list users: %w", err)
+		}
+		report.Scanned++
+
+		stored, err := getUserClaims(ctx, record.UID)
+		if err != nil {
+			stored = map[string]interface{}{}
+		}
+		authClaims := copyClaims(record.CustomClaims)
+
+		merged := copyClaims(stored)
+		for key, value := range authClaims {
+			if storedValue, ok := stored[key]; ok && !claimsEqual(storedValue, value) {
+				report.Conflicts[record.UID] = append(report.Conflicts[record.UID], key)
+			}
+			merged[key] = value
+		}
+
+		if claimsEqual(merged, authClaims) && claimsEqual(merged, stored) {
+			report.InSync++
+			continue
+		}
+		report.Migrated++
+		if dryRun {
+			continue
+		}
+		if err := SetCustomClaims(ctx, record.UID, merged); err != nil {
+			report.Migrated--
+			report.Failed[record.UID] = err.Error()
+		}
+	}
+
+	if len(report.Conflicts) == 0 {
+		report.Conflicts = nil
+	}
+	if len(report.Failed) == 0 {
+		report.Failed = nil
+	}
+	return report, nil
+}
+
+func copyClaims(claims map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(claims))
+	for key, value := range claims {
+		copied[key] = value
+	}
+	return copied
+}
+
+// ResolvedClaims claims combinados de ambos almacenes con el origen de cada uno
+type ResolvedClaims struct {
+	Claims  map[string]interface{}          `json:"claims"`
+	Sources map[string]firebase.ClaimSource `json:"sources"`
+}
+
+// ResolveClaims retorna los custom claims de Auth, que prevalecen sobre la copia de
+// user_claims: los claims que solo están en la copia se eliminaron en Auth y no se
+// incluyen. La copia solo indica el origen de cada claim (ClaimSourceBoth si coincide).
+// Un documento ausente o malformado no es un error; cualquier otra falla al leerlo sí.
+func ResolveClaims(ctx context.Context, uid string) (*ResolvedClaims, error) {
+	record, err := firebase.GetAuthClient().GetUser(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user claims: %w", err)
+	}
+
+	stored, err := getUserClaims(ctx, uid)
+	if err != nil && !firestore.IsNotFound(err) && !errors.Is(err, errMalformedClaims) {
+		return nil, fmt.Errorf("failed to get stored claims: %w", err)
+	}
+
+	resolved := &ResolvedClaims{Claims: map[string]interface{}{}, Sources: map[string]firebase.ClaimSource{}}
+	for key, value := range record.CustomClaims {
+		source := firebase.ClaimSourceAuth
+		if storedValue, ok := stored[key]; ok && claimsEqual(storedValue, value) {
+			source = firebase.ClaimSourceBoth
+		}
+		resolved.Claims[key] = value
+		resolved.Sources[key] = source
+	}
+	return resolved, nil
+}
+
+// resolveSessionClaims usa la copia de user_claims (sin consultar Auth). Sin documento
+// el usuario no tiene claims (las escrituras de claims siempre crean la copia); solo una
+// copia malformada recurre a ResolveClaims.
+func resolveSessionClaims(ctx context.Context, uid string) (*ResolvedClaims, error) {
+	stored, err := getUserClaims(ctx, uid)
+	switch {
+	case firestore.IsNotFound(err):
+		stored = map[string]interface{}{}
+	case errors.Is(err, errMalformedClaims):
+		return ResolveClaims(ctx, uid)
+	case err != nil:
+		return nil, fmt.Errorf("failed to get stored claims: %w", err)
+	}
+	resolved := &ResolvedClaims{Claims: stored, Sources: make(map[string]firebase.ClaimSource, len(stored))}
+	for key := range stored {
+		resolved.Sources[key] = firebase.ClaimSourceFirestore
+	}
+	return resolved, nil
+}
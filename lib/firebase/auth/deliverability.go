@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// EmailSuppressionsCollection direcciones a las que no se envían correos por rebotes
+// permanentes o quejas de spam
+const EmailSuppressionsCollection = "email_suppressions"
+
+// Motivos de supresión
+const (
+	SuppressionBounce    = "bounce"
+	SuppressionComplaint = "complaint"
+	SuppressionManual    = "manual"
+)
+
+// EmailSuppression dirección marcada como no entregable
+type EmailSuppression struct {
+	Email     string    `json:"email"`
+	Reason    string    `json:"reason"`
+	Detail    string    `json:"detail,omitempty"` // p. ej. el código de diagnóstico del rebote
+	Source    string    `json:"source,omitempty"` // "sendgrid", "ses", ...
+	UID       string    `json:"uid,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SuppressEmail marca la dirección como no entregable. Si pertenece a un usuario de Auth
+// también se marca su perfil espejo (email_deliverable = false).
+func SuppressEmail(ctx context.Context, suppression EmailSuppression) (*EmailSuppression, error) {
+	email := normalizeEmail(suppression.Email)
+	if email == "" {
+		return nil, fmt.Errorf("email is required")
+	}
+	if suppression.Reason == "" {
+		suppression.Reason = SuppressionManual
+	}
+	suppression.Email = email
+	suppression.CreatedAt = firebase.Now()
+	if user, err := GetUserByEmail(ctx, email); err == nil {
+		suppression.UID = user.UID
+	}
+
+	_, err := firebase.GetFirestoreClient().Collection(EmailSuppressionsCollection).Doc(suppressionID(email)).Set(ctx, map[string]interface{}{
+		"email":      email,
+		"reason":     suppression.Reason,
+		"detail":     suppression.Detail,
+		"source":     suppression.Source,
+		"uid":        suppression.UID,
+		"created_at": suppression.CreatedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to suppress email '%s': %w", email, err)
+	}
+
+	if suppression.UID != "" {
+		err := syncUserProfile(ctx, suppression.UID, map[string]interface{}{
+			"email_deliverable":        false,
+			"email_suppression_reason": suppression.Reason,
+			"email_suppressed_at":      suppression.CreatedAt,
+		})
+		if err != nil && !firestore.IsNotFound(err) {
+			log.Printf("⚠️  Error marking profile of '%s' as undeliverable: %v", suppression.UID, err)
+		}
+		if err := writeAuditLog(ctx, "email_suppressed", "", suppression.UID, map[string]interface{}{
+			"reason": suppression.Reason,
+			"source": suppression.Source,
+		}); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+	return &suppression, nil
+}
+
+// UnsuppressEmail quita la supresión de la dirección (p. ej. después de que el usuario
+// la corrija) y vuelve a marcar su perfil como entregable
+func UnsuppressEmail(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	suppression, err := GetEmailSuppression(ctx, email)
+	if err != nil || suppression == nil {
+		return err
+	}
+	if _, err := firebase.GetFirestoreClient().Collection(EmailSuppressionsCollection).Doc(suppressionID(email)).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to unsuppress email '%s': %w", email, err)
+	}
+	if suppression.UID != "" {
+		err := syncUserProfile(ctx, suppression.UID, map[string]interface{}{
+			"email_deliverable":        true,
+			"email_suppression_reason": "",
+		})
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEmailSuppression obtiene la supresión de la dirección (nil si es entregable)
+func GetEmailSuppression(ctx context.Context, email string) (*EmailSuppression, error) {
+	email = normalizeEmail(email)
+	snap, err := firebase.GetFirestoreClient().Collection(EmailSuppressionsCollection).Doc(suppressionID(email)).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get email suppression for '%s': %w", email, err)
+	}
+	doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+	suppression := &EmailSuppression{Email: email}
+	suppression.Reason, _ = doc.GetString("reason")
+	suppression.Detail, _ = doc.GetString("detail")
+	suppression.Source, _ = doc.GetString("source")
+	suppression.UID, _ = doc.GetString("uid")
+	suppression.CreatedAt, _ = doc.GetTime("created_at")
+	return suppression, nil
+}
+
+// CheckEmailDeliverable retorna *firebase.EmailUndeliverableError si la dirección está
+// suprimida
+func CheckEmailDeliverable(ctx context.Context, email string) error {
+	suppression, err := GetEmailSuppression(ctx, email)
+	if err != nil {
+		return err
+	}
+	if suppression != nil {
+		return &firebase.EmailUndeliverableError{Email: suppression.Email, Reason: suppression.Reason}
+	}
+	return nil
+}
+
+// GetUserEmailSuppression obtiene la supresión del email del usuario (nil si es
+// entregable o si el usuario no tiene email)
+func GetUserEmailSuppression(ctx context.Context, uid string) (*EmailSuppression, error) {
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email == "" {
+		return nil, nil
+	}
+	return GetEmailSuppression(ctx, user.Email)
+}
+
+// IsUserEmailDeliverable indica si se le pueden enviar correos al usuario
+func IsUserEmailDeliverable(ctx context.Context, uid string) (bool, error) {
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	if user.Email == "" {
+		return false, nil
+	}
+	suppression, err := GetEmailSuppression(ctx, user.Email)
+	if err != nil {
+		return false, err
+	}
+	return suppression == nil, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// suppressionID ID del documento de supresión (hash de la dirección normalizada)
+func suppressionID(email string) string {
+	sum := sha256.Sum256([]byte(email))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"firebase.google.com/go/v4/auth"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/mailer"
+	"github.com/andrescris/firestore/lib/firebase/ratelimit"
+)
+
+const (
+	emailChangesCollection = "email_changes"
+	emailChangeTTL         = 15 * time.Minute
+	emailChangeAttempts    = 5
+	emailChangeLimit       = 3
+)
+
+// EmailChangeResponse respuesta de las operaciones de cambio de correo
+type EmailChangeResponse struct {
+	Success   bool      `json:"success"`
+	Message   string    `json:"message"`
+	NewEmail  string    `json:"new_email,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// ChangeEmail inicia el cambio de correo del usuario: envía un OTP (y, si
+// EMAIL_CHANGE_CONFIRM_URL está definida, un enlace con un token de confirmación) a la
+// nueva dirección y avisa a la actual. El correo actual sigue activo hasta que se
+// confirme con ConfirmEmailChange o ConfirmEmailChangeToken; una nueva solicitud
+// reemplaza la pendiente.
+func ChangeEmail(ctx context.Context, uid, newEmail string) (*EmailChangeResponse, error) {
+	newEmail = normalizeEmail(newEmail)
+	if address, err := mail.ParseAddress(newEmail); err != nil || address.Address != newEmail {
+		return &EmailChangeResponse{Success: false, Message: "El correo no es válido."}, nil
+	}
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if normalizeEmail(user.Email) == newEmail {
+		return &EmailChangeResponse{Success: false, Message: "El correo nuevo es igual al actual."}, nil
+	}
+
+	allowed, err := ratelimit.Allow(ctx, "email_change:"+uid, emailChangeLimit, time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("error checking email change rate limit: %w", err)
+	}
+	if !allowed {
+		return &EmailChangeResponse{Success: false, Message: "Demasiadas solicitudes. Intenta más tarde."}, nil
+	}
+
+	if existing, err := GetUserByEmail(ctx, newEmail); err == nil && existing.UID != uid {
+		return &EmailChangeResponse{Success: false, Message: "El correo ya está en uso."}, nil
+	} else if err != nil && !auth.IsUserNotFound(err) {
+		return nil, err
+	}
+	if err := CheckEmailDeliverable(ctx, newEmail); err != nil {
+		if errors.Is(err, firebase.ErrEmailUndeliverable) {
+			return &EmailChangeResponse{Success: false, Message: "No podemos entregar correos a esa dirección."}, nil
+		}
+		return nil, err
+	}
+
+	otp, err := generateNumericOTP(6)
+	if err != nil {
+		return nil, fmt.Errorf("error generating OTP: %w", err)
+	}
+	secret, err := generateInvitationToken()
+	if err != nil {
+		return nil, fmt.Errorf("error generating email change token: %w", err)
+	}
+
+	// Un documento por usuario: solo se guardan los hashes del OTP y del token
+	expiresAt := firebase.Now().Add(emailChangeTTL)
+	err = firestore.CreateDocumentWithID(ctx, emailChangesCollection, uid, map[string]interface{}{
+		"uid":        uid,
+		"old_email":  user.Email,
+		"new_email":  newEmail,
+		"otp_hash":   hashInvitationToken(otp),
+		"token_hash": hashInvitationToken(secret),
+		"expires_at": expiresAt,
+		"attempts":   0,
+		"used":       false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error saving email change: %w", err)
+	}
+
+	confirmURL := ""
+	if base := os.Getenv("EMAIL_CHANGE_CONFIRM_URL"); base != "" {
+		confirmURL = fmt.Sprintf("%s?token=%s", base, emailChangeToken(uid, secret))
+	}
+	vars := map[string]interface{}{"OTP": otp, "ValidMinutes": int(emailChangeTTL.Minutes()), "ConfirmURL": confirmURL}
+	if err := mailer.SendTemplate(ctx, newEmail, "email_change", "", vars); err != nil {
+		return nil, err
+	}
+	if user.Email != "" {
+		if err := mailer.SendTemplate(ctx, user.Email, "email_change_notice", "", map[string]interface{}{"NewEmail": newEmail}); err != nil {
+			log.Printf("⚠️  Error notifying email change to '%s': %v", user.UID, err)
+		}
+	}
+
+	return &EmailChangeResponse{
+		Success:   true,
+		Message:   "Se ha enviado un código de confirmación al nuevo correo.",
+		NewEmail:  newEmail,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// ConfirmEmailChange confirma el cambio pendiente del usuario con el OTP recibido en el
+// nuevo correo
+func ConfirmEmailChange(ctx context.Context, uid, otp string) (*EmailChangeResponse, error) {
+	return confirmEmailChange(ctx, uid, "otp_hash", otp)
+}
+
+// ConfirmEmailChangeToken confirma el cambio pendiente con el token del enlace de
+// EMAIL_CHANGE_CONFIRM_URL
+func ConfirmEmailChangeToken(ctx context.Context, token string) (*EmailChangeResponse, error) {
+	encodedUID, secret, ok := strings.Cut(token, ".")
+	uid, err := base64.RawURLEncoding.DecodeString(encodedUID)
+	if !ok || err != nil || len(uid) == 0 {
+		return &EmailChangeResponse{Success: false, Message: "Enlace de confirmación inválido."}, nil
+	}
+	return confirmEmailChange(ctx, string(uid), "token_hash", secret)
+}
+
+// CancelEmailChange descarta el cambio de correo pendiente del usuario
+func CancelEmailChange(ctx context.Context, uid string) error {
+	if err := firestore.DeleteDocument(ctx, emailChangesCollection, uid); err != nil && !firestore.IsNotFound(err) {
+		return fmt.Errorf("error cancelling email change: %w", err)
+	}
+	return nil
+}
+
+// confirmEmailChange consume el cambio pendiente si el código coincide con el hash del
+// campo indicado, y actualiza el correo en Auth y en el perfil espejo. Si el perfil no
+// se puede actualizar, se restaura el correo anterior en Auth.
+func confirmEmailChange(ctx context.Context, uid, hashField, code string) (*EmailChangeResponse, error) {
+	ref := firestore.DocRefContext(ctx, emailChangesCollection, uid)
+
+	var oldEmail, newEmail, invalid string
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		invalid = ""
+		snap, err := tx.Get(ref)
+		if err != nil {
+			if firestore.IsNotFound(err) {
+				invalid = "No hay un cambio de correo pendiente."
+				return nil
+			}
+			return err
+		}
+
+		data := snap.Data()
+		used, _ := data["used"].(bool)
+		expiresAt, _ := data["expires_at"].(time.Time)
+		attempts, _ := data["attempts"].(int64)
+		expected, _ := data[hashField].(string)
+		switch {
+		case used:
+			invalid = "El cambio de correo ya fue confirmado."
+			return nil
+		case firebase.Now().After(expiresAt):
+			invalid = "El código ha expirado. Solicita el cambio de nuevo."
+			return nil
+		case attempts >= emailChangeAttempts:
+			invalid = "Demasiados intentos. Solicita el cambio de nuevo."
+			return nil
+		case subtle.ConstantTimeCompare([]byte(hashInvitationToken(code)), []byte(expected)) != 1:
+			invalid = "El código no es válido."
+			return tx.Update(ref, []gfirestore.Update{{Path: "attempts", Value: attempts + 1}})
+		}
+
+		oldEmail, _ = data["old_email"].(string)
+		newEmail, _ = data["new_email"].(string)
+		return tx.Update(ref, []gfirestore.Update{
+			{Path: "used", Value: true},
+			{Path: "used_at", Value: firebase.Now()},
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error consuming email change: %w", err)
+	}
+	if invalid != "" {
+		return &EmailChangeResponse{Success: false, Message: invalid}, nil
+	}
+
+	verified := true
+	if _, err := UpdateUser(ctx, uid, firebase.UpdateUserRequest{Email: &newEmail, EmailVerified: &verified}); err != nil {
+		if auth.IsEmailAlreadyExists(err) {
+			return &EmailChangeResponse{Success: false, Message: "El correo ya está en uso."}, nil
+		}
+		return nil, err
+	}
+	if err := syncUserProfile(ctx, uid, map[string]interface{}{
+		"email":          newEmail,
+		"email_verified": true,
+	}); err != nil {
+		if _, rollbackErr := UpdateUser(ctx, uid, firebase.UpdateUserRequest{Email: &oldEmail}); rollbackErr != nil {
+			log.Printf("⚠️  Error restoring email of '%s' after failed profile sync: %v", uid, rollbackErr)
+		}
+		return nil, err
+	}
+	if err := writeAuditLog(ctx, "email_changed", uid, uid, map[string]interface{}{
+		"old_email": oldEmail,
+		"new_email": newEmail,
+	}); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+
+	return &EmailChangeResponse{Success: true, Message: "Tu correo se actualizó correctamente.", NewEmail: newEmail}, nil
+}
+
+// emailChangeToken token del enlace de confirmación: el UID (base64) y el secreto
+func emailChangeToken(uid, secret string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(uid)) + "." + secret
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// ImpersonationTTL duración máxima de una sesión de suplantación
+var ImpersonationTTL = 30 * time.Minute
+
+// IsAdmin indica si la sesión tiene el claim admin=true o role=admin
+func IsAdmin(session *SessionInfo) bool {
+	if session == nil {
+		return false
+	}
+	if admin, _ := session.Claims["admin"].(bool); admin {
+		return true
+	}
+	role, _ := session.Claims["role"].(string)
+	return role == "admin"
+}
+
+// ImpersonateUser crea una sesión de corta duración como targetUID para que un
+// administrador pueda reproducir problemas del usuario. La sesión queda marcada con
+// impersonated_by (también en el token) y la acción se registra en AuditCollection.
+// No se emite un custom token: su intercambio en Firebase crearía una sesión del usuario
+// que no expira con ImpersonationTTL ni se cierra con EndImpersonation.
+func ImpersonateUser(ctx context.Context, adminSession *SessionInfo, targetUID string) (*LoginResponse, error) {
+	if adminSession == nil || adminSession.UID == "" {
+		return nil, firebase.ErrUnauthenticated
+	}
+	if !IsAdmin(adminSession) || adminSession.ImpersonatedBy != "" {
+		return nil, &firebase.PermissionDeniedError{Collection: "user_sessions", DocumentID: targetUID, Operation: firebase.OperationCreate}
+	}
+	if adminSession.UID == targetUID {
+		return nil, fmt.Errorf("cannot impersonate yourself")
+	}
+
+	user, err := GetUser(ctx, targetUID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return &LoginResponse{Success: false, Message: "La cuenta está deshabilitada."}, nil
+	}
+
+	resolved, err := ResolveClaims(ctx, targetUID)
+	if err != nil {
+		return nil, err
+	}
+	claims := resolved.Claims
+	if IsAdmin(&SessionInfo{Claims: claims}) {
+		return nil, &firebase.PermissionDeniedError{Collection: "user_sessions", DocumentID: targetUID, Operation: firebase.OperationCreate}
+	}
+
+	tokenClaims := make(map[string]interface{}, len(claims)+1)
+	for key, value := range claims {
+		tokenClaims[key] = value
+	}
+	tokenClaims["impersonated_by"] = adminSession.UID
+
+	sessionID, expiresAt, err := createSessionWithTTL(ctx, user.UID, user.Email, ImpersonationTTL, map[string]interface{}{
+		"impersonated_by": adminSession.UID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating session: %w", err)
+	}
+
+	if err := writeAuditLog(ctx, "impersonation_started", adminSession.UID, targetUID, map[string]interface{}{
+		"session_id": sessionID,
+		"expires_at": expiresAt,
+	}); err != nil {
+		// Sin registro de auditoría no se permite la suplantación
+		Logout(ctx, sessionID)
+		return nil, err
+	}
+
+	response := &LoginResponse{
+		Success:   true,
+		Message:   "Sesión de suplantación iniciada",
+		User:      user,
+		SessionID: sessionID,
+		ExpiresAt: expiresAt,
+		Claims:    tokenClaims,
+	}
+	if currentJWTConfig() != nil {
+		response.SessionToken, err = IssueSessionJWT(ctx, &SessionInfo{
+			UID:            user.UID,
+			Email:          user.Email,
+			Claims:         claims,
+			ExpiresAt:      expiresAt,
+			ImpersonatedBy: adminSession.UID,
+			SessionID:      sessionID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// EndImpersonation cierra una sesión de suplantación y lo registra en la auditoría. Solo
+// puede cerrarla la propia sesión suplantada o el administrador que la inició.
+func EndImpersonation(ctx context.Context, caller *SessionInfo, sessionID string) error {
+	if caller == nil || caller.UID == "" {
+		return firebase.ErrUnauthenticated
+	}
+	doc, err := firestore.GetDocument(ctx, "user_sessions", sessionID)
+	if err != nil {
+		return err
+	}
+	adminUID, _ := doc.Data["impersonated_by"].(string)
+	if adminUID == "" {
+		return fmt.Errorf("session '%s' is not an impersonation session", sessionID)
+	}
+	if caller.SessionID != sessionID && (caller.UID != adminUID || caller.ImpersonatedBy != "") {
+		return &firebase.PermissionDeniedError{Collection: "user_sessions", DocumentID: sessionID, Operation: firebase.OperationDelete}
+	}
+
+	if err := Logout(ctx, sessionID); err != nil {
+		return err
+	}
+	targetUID, _ := doc.Data["uid"].(string)
+	return writeAuditLog(ctx, "impersonation_ended", adminUID, targetUID, map[string]interface{}{
+		"session_id": sessionID,
+	})
+}
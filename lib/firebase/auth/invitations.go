@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/mailer"
+)
+
+const (
+	invitationsCollection = "user_invitations"
+	invitationTTL         = 7 * 24 * time.Hour
+)
+
+// InviteUserResponse respuesta de la invitación de un usuario
+type InviteUserResponse struct {
+	UID       string    `json:"uid"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// InviteUser crea un usuario deshabilitado en estado invitado, genera un token de
+// invitación de un solo uso y envía la invitación por correo. Si INVITATION_ACCEPT_URL
+// está definida, el correo incluye un enlace con el token como parámetro. Si algún paso
+// falla se eliminan el usuario, su perfil y la invitación, para poder invitarlo de nuevo.
+func InviteUser(ctx context.Context, email, role string) (_ *InviteUserResponse, err error) {
+	user, err := createUser(ctx, firebase.CreateUserRequest{Email: email, Disabled: true}, StateInvited, map[string]interface{}{
+		"state_changed_at": firebase.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var tokenHash string
+	defer func() {
+		if err != nil {
+			err = discardInvitation(context.WithoutCancel(ctx), user.UID, tokenHash, err)
+		}
+	}()
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, fmt.Errorf("error generating invitation token: %w", err)
+	}
+
+	// Solo se guarda el hash del token; el token en claro viaja únicamente en el correo
+	expiresAt := firebase.Now().Add(invitationTTL)
+	err = firestore.CreateDocumentWithID(ctx, invitationsCollection, hashInvitationToken(token), map[string]interface{}{
+		"uid":        user.UID,
+		"email":      email,
+		"role":       role,
+		"expires_at": expiresAt,
+		"used":       false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error saving invitation: %w", err)
+	}
+	tokenHash = hashInvitationToken(token)
+
+	acceptURL := ""
+	if base := os.Getenv("INVITATION_ACCEPT_URL"); base != "" {
+		acceptURL = fmt.Sprintf("%s?token=%s", base, token)
+	}
+	vars := map[string]interface{}{"Token": token, "AcceptURL": acceptURL}
+	if err := mailer.SendTemplate(ctx, email, "invitation", "", vars); err != nil {
+		return nil, err
+	}
+
+	return &InviteUserResponse{
+		UID:       user.UID,
+		Email:     email,
+		Role:      role,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// discardInvitation elimina la invitación (si se guardó), el perfil y el usuario de Auth
+// de una invitación fallida y retorna cause
+func discardInvitation(ctx context.Context, uid, tokenHash string, cause error) error {
+	var failures []string
+	if tokenHash != "" {
+		if err := firestore.DeleteDocument(ctx, invitationsCollection, tokenHash); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if err := firestore.DeleteDocument(ctx, UsersCollection, uid); err != nil && !firestore.IsNotFound(err) {
+		failures = append(failures, err.Error())
+	}
+	if err := firebase.GetAuthClient().DeleteUser(ctx, uid); err != nil {
+		failures = append(failures, err.Error())
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%w (failed to clean up invited user '%s': %s)", cause, uid, strings.Join(failures, "; "))
+	}
+	return cause
+}
+
+// AcceptInvitation consume el token de invitación, activa la cuenta, asigna el rol
+// y crea una sesión para el usuario. Si la activación o el rol fallan el token se libera
+// para que la invitación pueda aceptarse de nuevo.
+func AcceptInvitation(ctx context.Context, token string) (*LoginResponse, error) {
+	ref := firestore.DocRefContext(ctx, invitationsCollection, hashInvitationToken(token))
+
+	var uid, role string
+	var invalid string
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			if firestore.IsNotFound(err) {
+				invalid = "Invitación inválida."
+				return nil
+			}
+			return err
+		}
+
+		data := snap.Data()
+		used, _ := data["used"].(bool)
+		expiresAt, _ := data["expires_at"].(time.Time)
+		switch {
+		case used:
+			invalid = "La invitación ya fue utilizada."
+			return nil
+		case firebase.Now().After(expiresAt):
+			invalid = "La invitación ha expirado."
+			return nil
+		}
+
+		uid, _ = data["uid"].(string)
+		role, _ = data["role"].(string)
+		return tx.Update(ref, []gfirestore.Update{
+			{Path: "used", Value: true},
+			{Path: "used_at", Value: firebase.Now()},
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error consuming invitation: %w", err)
+	}
+	if invalid != "" {
+		return &LoginResponse{Success: false, Message: invalid}, nil
+	}
+
+	if err := ActivateUser(ctx, uid); err != nil {
+		return nil, releaseInvitation(ctx, ref, fmt.Errorf("error activating invited user: %w", err))
+	}
+
+	if role != "" {
+		if err := UpdateClaims(ctx, uid, map[string]interface{}{"role": role}); err != nil {
+			return nil, releaseInvitation(ctx, ref, fmt.Errorf("error saving invitation claims: %w", err))
+		}
+	}
+
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return &LoginResponse{Success: false, Message: "No se pudo verificar al usuario."}, nil
+	}
+	return issueLogin(ctx, user)
+}
+
+// releaseInvitation revierte el consumo del token tras una falla y retorna cause
+func releaseInvitation(ctx context.Context, ref *gfirestore.DocumentRef, cause error) error {
+	_, err := ref.Update(ctx, []gfirestore.Update{
+		{Path: "used", Value: false},
+		{Path: "used_at", Value: gfirestore.Delete},
+	})
+	if err != nil {
+		return fmt.Errorf("%w (failed to release invitation: %v)", cause, err)
+	}
+	return cause
+}
+
+func generateInvitationToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,394 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"github.com/golang-jwt/jwt/v4"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// RevokedSessionsCollection lista de bloqueo de tokens de sesión: documentos por sesión
+// (ID = jti) y por usuario ("user_<uid>", invalida los tokens emitidos antes de revoked_at)
+const RevokedSessionsCollection = "revoked_sessions"
+
+// JWTKey clave de firma de tokens de sesión. HS256 usa Secret; RS256 usa PrivateKey para
+// firmar y PublicKey (o la pública de PrivateKey) para verificar.
+type JWTKey struct {
+	ID         string
+	Algorithm  string // "HS256" o "RS256"
+	Secret     []byte
+	PrivateKey *rsa.PrivateKey
+	PublicKey  *rsa.PublicKey
+}
+
+// JWTConfig configuración de los tokens de sesión. Para rotar claves se agrega la nueva,
+// se cambia ActiveKeyID y se retira la anterior cuando vencen sus tokens.
+type JWTConfig struct {
+	Keys        []JWTKey
+	ActiveKeyID string
+	Issuer      string
+	TTL         time.Duration // por defecto 24h, igual que las sesiones
+}
+
+type sessionTokenClaims struct {
+	Email          string                 `json:"email,omitempty"`
+	Claims         map[string]interface{} `json:"claims,omitempty"`
+	ImpersonatedBy string                 `json:"impersonated_by,omitempty"`
+	AuthTime       int64                  `json:"auth_time,omitempty"`
+	Binding        map[string]interface{} `json:"binding,omitempty"`
+	// IssuedAtMicros hora de emisión en microsegundos (iat solo tiene segundos) para
+	// comparar con revoked_at con la misma precisión que Firestore
+	IssuedAtMicros int64 `json:"iat_us,omitempty"`
+	jwt.RegisteredClaims
+}
+
+var (
+	jwtMu     sync.RWMutex
+	jwtConfig *JWTConfig
+
+	blocklistMu     sync.RWMutex
+	blocklist       map[string]time.Time // nil si no hay sincronización activa
+	blocklistCancel context.CancelFunc
+)
+
+// ConfigureJWT habilita los tokens de sesión firmados. Con la configuración activa el
+// login también retorna SessionToken, verificable localmente con ValidateSessionJWT.
+func ConfigureJWT(config JWTConfig) error {
+	if len(config.Keys) == 0 {
+		return fmt.Errorf("at least one JWT key is required")
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	active := false
+	for _, key := range config.Keys {
+		switch key.Algorithm {
+		case "HS256":
+			if len(key.Secret) < 32 {
+				return fmt.Errorf("JWT key '%s': HS256 secret must be at least 32 bytes", key.ID)
+			}
+		case "RS256":
+			if key.PrivateKey == nil && key.PublicKey == nil {
+				return fmt.Errorf("JWT key '%s': RS256 requires a private or public key", key.ID)
+			}
+		default:
+			return fmt.Errorf("JWT key '%s': unsupported algorithm '%s'", key.ID, key.Algorithm)
+		}
+		if key.ID == config.ActiveKeyID {
+			if key.Algorithm == "RS256" && key.PrivateKey == nil {
+				return fmt.Errorf("active JWT key '%s' has no private key", key.ID)
+			}
+			active = true
+		}
+	}
+	if !active {
+		return fmt.Errorf("active JWT key '%s' not found", config.ActiveKeyID)
+	}
+
+	jwtMu.Lock()
+	defer jwtMu.Unlock()
+	jwtConfig = &config
+	return nil
+}
+
+// DisableJWT deshabilita la emisión de tokens de sesión
+func DisableJWT() {
+	jwtMu.Lock()
+	defer jwtMu.Unlock()
+	jwtConfig = nil
+}
+
+func currentJWTConfig() *JWTConfig {
+	jwtMu.RLock()
+	defer jwtMu.RUnlock()
+	return jwtConfig
+}
+
+// IssueSessionJWT firma un token para la sesión con la clave activa. El jti es el ID de
+// la sesión en user_sessions, de modo que Logout también revoca el token.
+func IssueSessionJWT(ctx context.Context, session *SessionInfo) (string, error) {
+	config := currentJWTConfig()
+	if config == nil {
+		return "", fmt.Errorf("JWT sessions are not configured")
+	}
+
+	var key JWTKey
+	for _, candidate := range config.Keys {
+		if candidate.ID == config.ActiveKeyID {
+			key = candidate
+		}
+	}
+
+	now := firebase.Now()
+	expiresAt := now.Add(config.TTL)
+	if !session.ExpiresAt.IsZero() && session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+	authTime := session.AuthTime
+	if authTime.IsZero() {
+		authTime = now
+	}
+
+	claims := sessionTokenClaims{
+		Email:          session.Email,
+		Claims:         session.Claims,
+		ImpersonatedBy: session.ImpersonatedBy,
+		AuthTime:       authTime.Unix(),
+		Binding:        bindingFields(ctx),
+		IssuedAtMicros: now.UnixMicro(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        session.SessionID,
+			Subject:   session.UID,
+			Issuer:    config.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	var token *jwt.Token
+	var signingKey interface{}
+	switch key.Algorithm {
+	case "HS256":
+		token, signingKey = jwt.NewWithClaims(jwt.SigningMethodHS256, claims), key.Secret
+	case "RS256":
+		token, signingKey = jwt.NewWithClaims(jwt.SigningMethodRS256, claims), key.PrivateKey
+	}
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(signingKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign session token: %w", err)
+	}
+	return signed, nil
+}
+
+// ValidateSessionJWT verifica la firma y vigencia del token sin leer la sesión de
+// Firestore. La revocación se consulta en la lista de bloqueo (en memoria si
+// StartJWTBlocklistSync está activo; si no, con una lectura).
+func ValidateSessionJWT(ctx context.Context, tokenString string) (*SessionInfo, error) {
+	config := currentJWTConfig()
+	if config == nil {
+		return nil, fmt.Errorf("JWT sessions are not configured")
+	}
+
+	// Las fechas se validan aparte con el reloj del paquete (firebase.SetClock), sin
+	// modificar jwt.TimeFunc, que es global para todo el proceso
+	claims := &sessionTokenClaims{}
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		for _, key := range config.Keys {
+			if key.ID != kid {
+				continue
+			}
+			if token.Method.Alg() != key.Algorithm {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			if key.Algorithm == "HS256" {
+				return key.Secret, nil
+			}
+			if key.PublicKey != nil {
+				return key.PublicKey, nil
+			}
+			return &key.PrivateKey.PublicKey, nil
+		}
+		return nil, fmt.Errorf("unknown signing key '%s'", kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sesión inválida o expirada: %w", err)
+	}
+	now := firebase.Now()
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyNotBefore(now, false) || !claims.VerifyIssuedAt(now, false) {
+		return nil, fmt.Errorf("sesión inválida o expirada")
+	}
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return nil, fmt.Errorf("sesión inválida: emisor desconocido")
+	}
+
+	revoked, err := isTokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("sesión inactiva o expirada")
+	}
+	if err := checkSessionBinding(ctx, claims.Binding); err != nil {
+		return nil, err
+	}
+
+	sessionClaims := claims.Claims
+	if sessionClaims == nil {
+		sessionClaims = map[string]interface{}{}
+	}
+	return &SessionInfo{
+		UID:            claims.Subject,
+		Email:          claims.Email,
+		Active:         true,
+		Claims:         sessionClaims,
+		ExpiresAt:      claims.ExpiresAt.Time,
+		ImpersonatedBy: claims.ImpersonatedBy,
+		SessionID:      claims.ID,
+		AuthTime:       time.Unix(claims.AuthTime, 0),
+	}, nil
+}
+
+// StartJWTBlocklistSync mantiene la lista de bloqueo en memoria mediante un listener, de
+// modo que ValidateSessionJWT no lea Firestore. Se detiene con StopJWTBlocklistSync o al
+// cancelar ctx.
+func StartJWTBlocklistSync(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	snapshots := firebase.GetFirestoreClient().Collection(RevokedSessionsCollection).
+		Where("expires_at", ">", firebase.Now()).Snapshots(ctx)
+
+	// La primera instantánea se espera para no aceptar tokens revocados al arrancar
+	snap, err := snapshots.Next()
+	if err != nil {
+		cancel()
+		snapshots.Stop()
+		return fmt.Errorf("failed to load session blocklist: %w", err)
+	}
+	if err := applyBlocklistSnapshot(snap, true); err != nil {
+		cancel()
+		snapshots.Stop()
+		return err
+	}
+
+	blocklistMu.Lock()
+	if blocklistCancel != nil {
+		blocklistCancel()
+	}
+	blocklistCancel = cancel
+	blocklistMu.Unlock()
+
+	go func() {
+		defer snapshots.Stop()
+		for {
+			snap, err := snapshots.Next()
+			if err != nil {
+				// Sin sincronización se vuelve a consultar Firestore en cada validación
+				blocklistMu.Lock()
+				blocklist = nil
+				blocklistMu.Unlock()
+				return
+			}
+			applyBlocklistSnapshot(snap, false)
+		}
+	}()
+	return nil
+}
+
+// StopJWTBlocklistSync detiene la sincronización de la lista de bloqueo
+func StopJWTBlocklistSync() {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	if blocklistCancel != nil {
+		blocklistCancel()
+		blocklistCancel = nil
+	}
+	blocklist = nil
+}
+
+func applyBlocklistSnapshot(snap *gfirestore.QuerySnapshot, reset bool) error {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	if reset || blocklist == nil {
+		blocklist = map[string]time.Time{}
+		docs, err := snap.Documents.GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to read session blocklist: %w", err)
+		}
+		for _, doc := range docs {
+			revokedAt, _ := doc.Data()["revoked_at"].(time.Time)
+			blocklist[doc.Ref.ID] = revokedAt
+		}
+		return nil
+	}
+	for _, change := range snap.Changes {
+		if change.Kind == gfirestore.DocumentRemoved {
+			delete(blocklist, change.Doc.Ref.ID)
+			continue
+		}
+		revokedAt, _ := change.Doc.Data()["revoked_at"].(time.Time)
+		blocklist[change.Doc.Ref.ID] = revokedAt
+	}
+	return nil
+}
+
+// isTokenRevoked consulta si la sesión del token o todos los tokens del usuario emitidos
+// antes de cierta fecha fueron revocados
+func isTokenRevoked(ctx context.Context, claims *sessionTokenClaims) (bool, error) {
+	issuedAt := tokenIssuedAt(claims)
+	userKey := "user_" + claims.Subject
+
+	blocklistMu.RLock()
+	if blocklist != nil {
+		_, sessionRevoked := blocklist[claims.ID]
+		userRevokedAt, userRevoked := blocklist[userKey]
+		blocklistMu.RUnlock()
+		return sessionRevoked || (userRevoked && !issuedAt.After(userRevokedAt)), nil
+	}
+	blocklistMu.RUnlock()
+
+	refs := []*gfirestore.DocumentRef{firestore.DocRefContext(ctx, RevokedSessionsCollection, userKey)}
+	if claims.ID != "" {
+		refs = append(refs, firestore.DocRefContext(ctx, RevokedSessionsCollection, claims.ID))
+	}
+	snaps, err := firebase.GetFirestoreClient().GetAll(ctx, refs)
+	if err != nil {
+		return false, fmt.Errorf("failed to check session blocklist: %w", err)
+	}
+	if snaps[0].Exists() {
+		revokedAt, _ := snaps[0].Data()["revoked_at"].(time.Time)
+		if !issuedAt.After(revokedAt) {
+			return true, nil
+		}
+	}
+	return len(snaps) > 1 && snaps[1].Exists(), nil
+}
+
+// tokenIssuedAt retorna la hora de emisión del token. Los tokens sin iat_us solo tienen
+// segundos: se toma el inicio de ese segundo, así que una revocación dentro del mismo
+// segundo los invalida.
+func tokenIssuedAt(claims *sessionTokenClaims) time.Time {
+	if claims.IssuedAtMicros > 0 {
+		return time.UnixMicro(claims.IssuedAtMicros).UTC()
+	}
+	if claims.IssuedAt == nil {
+		return time.Time{}
+	}
+	return claims.IssuedAt.Time.Truncate(time.Second)
+}
+
+// revokeSessionToken agrega una sesión a la lista de bloqueo
+func revokeSessionToken(ctx context.Context, sessionID string) error {
+	config := currentJWTConfig()
+	if config == nil || sessionID == "" {
+		return nil
+	}
+	now := firebase.Now()
+	return firestore.CreateDocumentWithID(ctx, RevokedSessionsCollection, sessionID, map[string]interface{}{
+		"revoked_at": now,
+		"expires_at": now.Add(config.TTL), // ningún token de la sesión vive más que TTL
+	})
+}
+
+// revokeUserTokens invalida todos los tokens del usuario emitidos hasta ahora
+func revokeUserTokens(ctx context.Context, uid string) error {
+	config := currentJWTConfig()
+	if config == nil {
+		return nil
+	}
+	now := firebase.Now()
+	return firestore.CreateDocumentWithID(ctx, RevokedSessionsCollection, "user_"+uid, map[string]interface{}{
+		"revoked_at": now,
+		"expires_at": now.Add(config.TTL),
+	})
+}
@@ -3,13 +3,25 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
-	"log"
 	"math/big"
 	"time"
 
+	gfirestore "cloud.google.com/go/firestore"
+
 	firebase "github.com/andrescris/firestore/lib/firebase"
 	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/ipblock"
+	"github.com/andrescris/firestore/lib/firebase/mailer"
+	"github.com/andrescris/firestore/lib/firebase/ratelimit"
+)
+
+// Límites de solicitudes e intentos de OTP por email
+const (
+	otpRequestLimit  = 5
+	otpAttemptLimit  = 10
+	otpLimiterWindow = 15 * time.Minute
 )
 
 // LoginResponse respuesta del login
@@ -21,6 +33,7 @@ type LoginResponse struct {
 	SessionID    string                 `json:"session_id,omitempty"`
 	ExpiresAt    time.Time              `json:"expires_at,omitempty"`
 	Claims       map[string]interface{} `json:"claims,omitempty"`
+	SessionToken string                 `json:"session_token,omitempty"` // JWT si ConfigureJWT está activo
 }
 
 // RequestOTPResponse respuesta de la solicitud de OTP
@@ -31,11 +44,31 @@ type RequestOTPResponse struct {
 
 // RequestOTP genera y envía un OTP al usuario
 func RequestOTP(ctx context.Context, request firebase.RequestOTPRequest) (*RequestOTPResponse, error) {
+	if err := ipblock.CheckContext(ctx); errors.Is(err, firebase.ErrIPBlocked) {
+		return &RequestOTPResponse{Success: false, Message: "Acceso bloqueado temporalmente desde tu red."}, nil
+	}
+	allowed, err := ratelimit.Allow(ctx, "otp_request:"+normalizeEmail(request.Email), otpRequestLimit, otpLimiterWindow)
+	if err != nil {
+		return nil, fmt.Errorf("error checking OTP rate limit: %w", err)
+	}
+	if !allowed {
+		return &RequestOTPResponse{Success: false, Message: "Demasiadas solicitudes. Intenta más tarde."}, nil
+	}
+
 	// 1. Validar que el usuario existe
 	user, err := GetUserByEmail(ctx, request.Email)
 	if err != nil {
 		return &RequestOTPResponse{Success: false, Message: "Usuario no encontrado."}, nil
 	}
+	if user.Disabled {
+		return &RequestOTPResponse{Success: false, Message: "La cuenta está deshabilitada."}, nil
+	}
+	if err := CheckEmailDeliverable(ctx, user.Email); err != nil {
+		if errors.Is(err, firebase.ErrEmailUndeliverable) {
+			return &RequestOTPResponse{Success: false, Message: "No podemos entregar correos a tu dirección. Contacta con soporte."}, nil
+		}
+		return nil, fmt.Errorf("error checking email deliverability: %w", err)
+	}
 
 	// 2. Generar un OTP numérico de 6 dígitos
 	otp, err := generateNumericOTP(6)
@@ -44,7 +77,7 @@ func RequestOTP(ctx context.Context, request firebase.RequestOTPRequest) (*Reque
 	}
 
 	// 3. Guardar el OTP en Firestore
-	expiresAt := time.Now().Add(10 * time.Minute) // OTP válido por 10 minutos
+	expiresAt := firebase.Now().Add(10 * time.Minute) // OTP válido por 10 minutos
 	otpData := map[string]interface{}{
 		"uid":         user.UID,
 		"email":       user.Email,
@@ -58,8 +91,12 @@ func RequestOTP(ctx context.Context, request firebase.RequestOTPRequest) (*Reque
 		return nil, fmt.Errorf("error saving OTP: %w", err)
 	}
 
-	// 4. Enviar el OTP (simulado)
-	log.Printf("✅ OTP para %s: %s (Válido por 10 minutos)", user.Email, otp)
+	// 4. Enviar el OTP por correo
+	if err := mailer.SendTemplate(ctx, user.Email, "otp", "", map[string]interface{}{"OTP": otp, "ValidMinutes": 10}); err != nil {
+		return nil, fmt.Errorf("error sending OTP: %w", err)
+	}
+	firebase.RecordEvent(firebase.EventOTPIssued)
+	recordLoginStat(ctx, statOTPsIssued)
 
 	return &RequestOTPResponse{
 		Success: true,
@@ -69,6 +106,17 @@ func RequestOTP(ctx context.Context, request firebase.RequestOTPRequest) (*Reque
 
 // LoginWithOTP autentica a un usuario usando un OTP
 func LoginWithOTP(ctx context.Context, request firebase.LoginWithOTPRequest) (*LoginResponse, error) {
+	if err := ipblock.CheckContext(ctx); errors.Is(err, firebase.ErrIPBlocked) {
+		return &LoginResponse{Success: false, Message: "Acceso bloqueado temporalmente desde tu red."}, nil
+	}
+	allowed, err := ratelimit.Allow(ctx, "otp_attempt:"+normalizeEmail(request.Email), otpAttemptLimit, otpLimiterWindow)
+	if err != nil {
+		return nil, fmt.Errorf("error checking OTP rate limit: %w", err)
+	}
+	if !allowed {
+		return &LoginResponse{Success: false, Message: "Demasiados intentos. Intenta más tarde."}, nil
+	}
+
 	// 1. Buscar el OTP en Firestore
 	queryOptions := firebase.QueryOptions{
 		Filters: []firebase.QueryFilter{
@@ -83,14 +131,20 @@ func LoginWithOTP(ctx context.Context, request firebase.LoginWithOTPRequest) (*L
 
 	otpDocs, err := firestore.QueryDocuments(ctx, "user_otps", queryOptions)
 	if err != nil || len(otpDocs) == 0 {
+		firebase.RecordEvent(firebase.EventOTPLoginFailure)
+		recordLoginStat(ctx, statFailures)
+		ipblock.RecordFailureContext(ctx)
 		return &LoginResponse{Success: false, Message: "OTP inválido o no encontrado."}, nil
 	}
 	
 	otpDoc := otpDocs[0]
 
 	// 2. Verificar si el OTP ha expirado
-	expiresAt := otpDoc.Data["expires_at"].(time.Time)
-	if time.Now().After(expiresAt) {
+	expiresAt, ok := otpDoc.GetTime("expires_at")
+	if !ok || firebase.Now().After(expiresAt) {
+		firebase.RecordEvent(firebase.EventOTPLoginFailure)
+		recordLoginStat(ctx, statFailures)
+		ipblock.RecordFailureContext(ctx)
 		return &LoginResponse{Success: false, Message: "El OTP ha expirado."}, nil
 	}
 
@@ -101,48 +155,36 @@ func LoginWithOTP(ctx context.Context, request firebase.LoginWithOTPRequest) (*L
 	}
 	
 	// 4. Obtener datos del usuario
-	uid := otpDoc.Data["uid"].(string)
+	uid, ok := otpDoc.GetString("uid")
 	user, err := GetUser(ctx, uid)
-	if err != nil {
+	if !ok || err != nil {
 		return &LoginResponse{Success: false, Message: "No se pudo verificar al usuario."}, nil
 	}
 	
-	// 5. Crear token personalizado y sesión
-	claims, _ := getUserClaims(ctx, uid)
-	customToken, err := CreateCustomToken(ctx, user.UID, claims)
-	if err != nil {
-		return nil, fmt.Errorf("error creating custom token: %w", err)
+	// 5. Crear token personalizado, sesión y actualizar último login
+	response, err := issueLogin(ctx, user)
+	if err == nil && response.Success {
+		firebase.RecordEvent(firebase.EventOTPLoginSuccess)
+		recordLoginStat(ctx, statOTPLogins)
 	}
-	
-	sessionID, sessionExpiresAt, err := createSession(ctx, user.UID, user.Email)
-	if err != nil {
-		return nil, fmt.Errorf("error creating session: %w", err)
-	}
-
-	// 6. Actualizar último login
-	updateLastLogin(ctx, user.UID)
-
-	return &LoginResponse{
-		Success:     true,
-		Message:     "Login exitoso",
-		User:        user,
-		CustomToken: customToken,
-		SessionID:   sessionID,
-		ExpiresAt:   sessionExpiresAt,
-		Claims:      claims,
-	}, nil
+	return response, err
 }
 
-type SessionInfo struct {
-	UID       string
-	Email     string
-	Active    bool
-	Claims    map[string]interface{}
-	ExpiresAt time.Time
-}
+// SessionInfo alias de firebase.SessionInfo para mantener compatibilidad
+type SessionInfo = firebase.SessionInfo
 
 // ValidateSession verifica si una sesión es válida y activa.
 func ValidateSession(ctx context.Context, sessionID string) (*SessionInfo, error) {
+	if info, binding, ok := cachedSession(sessionID); ok {
+		if err := checkSessionBinding(ctx, binding); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+
+	// Marca tomada antes de leer: si llega una invalidación durante la lectura, la sesión
+	// leída no se guarda en caché
+	marker := sessionCacheMarker()
 	doc, err := firestore.GetDocument(ctx, "user_sessions", sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("sesión no encontrada")
@@ -151,31 +193,119 @@ func ValidateSession(ctx context.Context, sessionID string) (*SessionInfo, error
 	active, _ := doc.Data["active"].(bool)
 	expiresAt, _ := doc.Data["expires_at"].(time.Time)
 
-	if !active || time.Now().After(expiresAt) {
+	if !active || firebase.Now().After(expiresAt) {
 		return nil, fmt.Errorf("sesión inactiva o expirada")
 	}
 
+	if err := checkSessionBinding(ctx, doc.Data); err != nil {
+		return nil, err
+	}
+
 	uid, _ := doc.Data["uid"].(string)
 	email, _ := doc.Data["email"].(string)
-	claims, err := getUserClaims(ctx, uid)
+	resolved, err := resolveSessionClaims(ctx, uid)
 	if err != nil {
-		claims = make(map[string]interface{}) // Si no hay claims, devolver mapa vacío
+		return nil, err
 	}
 
-	return &SessionInfo{
-		UID:       uid,
-		Email:     email,
-		Active:    true,
-		Claims:    claims,
-		ExpiresAt: expiresAt,
-	}, nil
+	impersonatedBy, _ := doc.Data["impersonated_by"].(string)
+	authTime, ok := doc.Data["auth_time"].(time.Time)
+	if !ok {
+		authTime, _ = doc.Data["created_at"].(time.Time) // sesiones anteriores a step-up auth
+	}
+
+	info := &SessionInfo{
+		UID:            uid,
+		Email:          email,
+		Active:         true,
+		Claims:         resolved.Claims,
+		ExpiresAt:      expiresAt,
+		ImpersonatedBy: impersonatedBy,
+		SessionID:      sessionID,
+		AuthTime:       authTime,
+		ClaimSources:   resolved.Sources,
+	}
+	cacheSession(marker, info, doc.Data)
+	return info, nil
 }
 
 // Logout invalida una sesión de usuario.
 func Logout(ctx context.Context, sessionID string) error {
-	return firestore.UpdateDocument(ctx, "user_sessions", sessionID, map[string]interface{}{
+	if err := firestore.UpdateDocument(ctx, "user_sessions", sessionID, map[string]interface{}{
 		"active": false,
+	}); err != nil {
+		return err
+	}
+	return revokeSessionToken(ctx, sessionID)
+}
+
+// RevokeUserSessions revoca los refresh tokens y todas las sesiones activas de un usuario.
+func RevokeUserSessions(ctx context.Context, uid string) error {
+	client := firebase.GetAuthClient()
+	if err := client.RevokeRefreshTokens(ctx, uid); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	if err := revokeUserTokens(ctx, uid); err != nil {
+		return fmt.Errorf("failed to revoke session tokens: %w", err)
+	}
+
+	sessions, err := firestore.QueryDocuments(ctx, "user_sessions", firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			{Field: "uid", Operator: "==", Value: uid},
+			{Field: "active", Operator: "==", Value: true},
+		},
 	})
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	operations := make([]firebase.BatchOperation, 0, len(sessions))
+	for _, session := range sessions {
+		operations = append(operations, firebase.BatchOperation{
+			Type:       "update",
+			Collection: "user_sessions",
+			DocumentID: session.ID,
+			Data:       map[string]interface{}{"active": false},
+		})
+	}
+	if err := firestore.BatchWrite(ctx, operations); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// invalidatePendingOTPs marca como usados los OTP pendientes de un usuario, para que un
+// código pedido antes de suspender o desactivar la cuenta no inicie sesión.
+func invalidatePendingOTPs(ctx context.Context, uid string) error {
+	otps, err := firestore.QueryDocuments(ctx, "user_otps", firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			{Field: "uid", Operator: "==", Value: uid},
+			{Field: "used", Operator: "==", Value: false},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pending OTPs: %w", err)
+	}
+	if len(otps) == 0 {
+		return nil
+	}
+
+	operations := make([]firebase.BatchOperation, 0, len(otps))
+	for _, otp := range otps {
+		operations = append(operations, firebase.BatchOperation{
+			Type:       "update",
+			Collection: "user_otps",
+			DocumentID: otp.ID,
+			Data:       map[string]interface{}{"used": true},
+		})
+	}
+	if err := firestore.BatchWrite(ctx, operations); err != nil {
+		return fmt.Errorf("failed to invalidate pending OTPs: %w", err)
+	}
+	return nil
 }
 
 // --- FUNCIONES AUXILIARES ---
@@ -193,28 +323,120 @@ func generateNumericOTP(length int) (string, error) {
 	return string(otp), nil
 }
 
+// getUserClaims lee la copia de claims de user_claims. Un documento sin campo claims
+// equivale a no tener claims; un campo que no es un mapa es un error.
 func getUserClaims(ctx context.Context, uid string) (map[string]interface{}, error) {
 	doc, err := firestore.GetDocument(ctx, "user_claims", uid)
 	if err != nil {
 		return nil, err
 	}
-	return doc.Data["claims"].(map[string]interface{}), nil
+	raw, ok := doc.Data["claims"]
+	if !ok || raw == nil {
+		return map[string]interface{}{}, nil
+	}
+	claims, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w for user '%s': expected a map, got %T", errMalformedClaims, uid, raw)
+	}
+	return claims, nil
 }
 
 func createSession(ctx context.Context, uid, email string) (string, time.Time, error) {
-	expiresAt := time.Now().Add(24 * time.Hour)
+	return createSessionWithTTL(ctx, uid, email, 24*time.Hour, nil)
+}
+
+// createSessionWithTTL crea una sesión con duración ttl y campos adicionales
+func createSessionWithTTL(ctx context.Context, uid, email string, ttl time.Duration, extra map[string]interface{}) (string, time.Time, error) {
+	now := firebase.Now()
+	expiresAt := now.Add(ttl)
 	sessionData := map[string]interface{}{
 		"uid":        uid,
 		"email":      email,
 		"active":     true,
 		"expires_at": expiresAt,
+		"auth_time":  now,
+	}
+	for key, value := range bindingFields(ctx) {
+		sessionData[key] = value
+	}
+	for key, value := range extra {
+		sessionData[key] = value
 	}
 	sessionID, err := firestore.CreateDocument(ctx, "user_sessions", sessionData)
 	return sessionID, expiresAt, err
 }
 
+// issueLogin crea el token personalizado y la sesión de un usuario ya verificado. Rechaza
+// las cuentas deshabilitadas o que no están activas (p. ej. suspendidas después de pedir
+// el código).
+func issueLogin(ctx context.Context, user *firebase.UserRecord) (*LoginResponse, error) {
+	if user.Disabled {
+		return &LoginResponse{Success: false, Message: "La cuenta está deshabilitada."}, nil
+	}
+	state, err := GetAccountState(ctx, user.UID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking account state: %w", err)
+	}
+	if state != StateActive {
+		return &LoginResponse{Success: false, Message: "La cuenta no está activa."}, nil
+	}
+
+	resolved, err := ResolveClaims(ctx, user.UID)
+	if err != nil {
+		return nil, err
+	}
+	claims := resolved.Claims
+	customToken, err := CreateCustomToken(ctx, user.UID, claims)
+	if err != nil {
+		return nil, fmt.Errorf("error creating custom token: %w", err)
+	}
+
+	sessionID, sessionExpiresAt, err := createSession(ctx, user.UID, user.Email)
+	if err != nil {
+		return nil, fmt.Errorf("error creating session: %w", err)
+	}
+
+	updateLastLogin(ctx, user.UID)
+	recordLogin(ctx, user.UID)
+
+	response := &LoginResponse{
+		Success:     true,
+		Message:     "Login exitoso",
+		User:        user,
+		CustomToken: customToken,
+		SessionID:   sessionID,
+		ExpiresAt:   sessionExpiresAt,
+		Claims:      claims,
+	}
+	if currentJWTConfig() != nil {
+		response.SessionToken, err = IssueSessionJWT(ctx, &SessionInfo{
+			UID:       user.UID,
+			Email:     user.Email,
+			Claims:    claims,
+			ExpiresAt: sessionExpiresAt,
+			SessionID: sessionID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// setUserClaimsDocument guarda claims en la colección user_claims usada por las sesiones.
+// Reemplaza el mapa completo: un merge conservaría los claims eliminados.
+func setUserClaimsDocument(ctx context.Context, uid string, claims map[string]interface{}) error {
+	err := firestore.UpdateDocumentFields(ctx, "user_claims", uid, []gfirestore.Update{{Path: "claims", Value: claims}})
+	if err != nil && firestore.IsNotFound(err) {
+		return firestore.UpdateDocument(ctx, "user_claims", uid, map[string]interface{}{
+			"claims": claims,
+		})
+	}
+	return err
+}
+
 func updateLastLogin(ctx context.Context, uid string) error {
 	return firestore.UpdateDocument(ctx, "user_activity", uid, map[string]interface{}{
-		"last_login": time.Now(),
+		"last_login": firebase.Now(),
 	})
 }
\ No newline at end of file
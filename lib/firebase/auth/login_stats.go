@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// LoginStatsCollection colección con los agregados diarios de login (documento
+// AAAA-MM-DD en UTC, con la subcolección users para contar usuarios únicos)
+const LoginStatsCollection = "login_stats"
+
+// Campos de los agregados diarios
+const (
+	statLogins      = "logins"
+	statFailures    = "failures"
+	statUniqueUsers = "unique_users"
+	statOTPsIssued  = "otps_issued"
+	statOTPLogins   = "otp_logins"
+)
+
+// DailyLoginStats agregado de un día (UTC)
+type DailyLoginStats struct {
+	Date        string `json:"date"`
+	Logins      int64  `json:"logins"`
+	Failures    int64  `json:"failures"`
+	UniqueUsers int64  `json:"unique_users"`
+	OTPsIssued  int64  `json:"otps_issued"`
+	OTPLogins   int64  `json:"otp_logins"`
+}
+
+// LoginStats agregado de una ventana. UniqueUsers cuenta cada usuario una sola vez en
+// toda la ventana; OTPConversionRate es otp_logins / otps_issued.
+type LoginStats struct {
+	Since             time.Time          `json:"since"`
+	Logins            int64              `json:"logins"`
+	Failures          int64              `json:"failures"`
+	UniqueUsers       int64              `json:"unique_users"`
+	OTPsIssued        int64              `json:"otps_issued"`
+	OTPLogins         int64              `json:"otp_logins"`
+	OTPConversionRate float64            `json:"otp_conversion_rate"`
+	Days              []*DailyLoginStats `json:"days"`
+}
+
+// GetLoginStats agrega los días (UTC) que cubre window hasta hoy a partir de
+// LoginStatsCollection, que RequestOTP, LoginWithOTP y cada login mantienen al día
+func GetLoginStats(ctx context.Context, window time.Duration) (*LoginStats, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("login stats window must be positive")
+	}
+	now := firebase.Now().UTC()
+	since := now.Add(-window)
+	stats := &LoginStats{Since: since}
+
+	var refs []*gfirestore.DocumentRef
+	for day := truncateDay(since); !day.After(now); day = day.AddDate(0, 0, 1) {
+		refs = append(refs, firestore.DocRefContext(ctx, LoginStatsCollection, day.Format("2006-01-02")))
+	}
+	snaps, err := firebase.FirestoreClientFor(ctx, LoginStatsCollection).GetAll(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login stats: %w", err)
+	}
+
+	users := map[string]bool{}
+	for i, snap := range snaps {
+		day := &DailyLoginStats{Date: refs[i].ID}
+		stats.Days = append(stats.Days, day)
+		if !snap.Exists() {
+			continue
+		}
+		data := snap.Data()
+		day.Logins, _ = data[statLogins].(int64)
+		day.Failures, _ = data[statFailures].(int64)
+		day.UniqueUsers, _ = data[statUniqueUsers].(int64)
+		day.OTPsIssued, _ = data[statOTPsIssued].(int64)
+		day.OTPLogins, _ = data[statOTPLogins].(int64)
+
+		stats.Logins += day.Logins
+		stats.Failures += day.Failures
+		stats.OTPsIssued += day.OTPsIssued
+		stats.OTPLogins += day.OTPLogins
+		if err := collectDailyUsers(ctx, refs[i], users); err != nil {
+			return nil, err
+		}
+	}
+	stats.UniqueUsers = int64(len(users))
+	if stats.OTPsIssued > 0 {
+		stats.OTPConversionRate = float64(stats.OTPLogins) / float64(stats.OTPsIssued)
+	}
+	return stats, nil
+}
+
+// collectDailyUsers agrega a users los UIDs que iniciaron sesión ese día
+func collectDailyUsers(ctx context.Context, day *gfirestore.DocumentRef, users map[string]bool) error {
+	iter := day.Collection("users").Select().Documents(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list users of login stats '%s': %w", day.ID, err)
+		}
+		users[snap.Ref.ID] = true
+	}
+}
+
+// RebuildLoginStats recalcula los agregados desde since con user_otps (emitidos y usados)
+// y user_sessions (logins y usuarios únicos), p. ej. para los días anteriores a esta
+// versión. Los fallos no se guardan en esas colecciones y se conservan.
+func RebuildLoginStats(ctx context.Context, since time.Time) error {
+	days := map[string]*DailyLoginStats{}
+	dayUsers := map[string]map[string]bool{}
+	get := func(t time.Time) *DailyLoginStats {
+		key := t.UTC().Format("2006-01-02")
+		if days[key] == nil {
+			days[key] = &DailyLoginStats{Date: key}
+			dayUsers[key] = map[string]bool{}
+		}
+		return days[key]
+	}
+	filters := []firebase.QueryFilter{firebase.Where("created_at", firebase.OpGreaterThanOrEqual, since)}
+
+	otps, err := firestore.QueryDocuments(ctx, "user_otps", firebase.QueryOptions{Filters: filters})
+	if err != nil {
+		return fmt.Errorf("failed to read OTPs for login stats: %w", err)
+	}
+	for _, otp := range otps {
+		created, ok := otp.GetTime("created_at")
+		if !ok {
+			continue
+		}
+		day := get(created)
+		day.OTPsIssued++
+		if used, _ := otp.GetBool("used"); used {
+			day.OTPLogins++
+		}
+	}
+
+	sessions, err := firestore.QueryDocuments(ctx, "user_sessions", firebase.QueryOptions{Filters: filters})
+	if err != nil {
+		return fmt.Errorf("failed to read sessions for login stats: %w", err)
+	}
+	for _, session := range sessions {
+		created, ok := session.GetTime("created_at")
+		if !ok {
+			continue
+		}
+		// Las suplantaciones no son logins del usuario
+		if impersonator, _ := session.GetString("impersonated_by"); impersonator != "" {
+			continue
+		}
+		day := get(created)
+		day.Logins++
+		if uid, _ := session.GetString("uid"); uid != "" {
+			dayUsers[day.Date][uid] = true
+		}
+	}
+
+	client := firebase.FirestoreClientFor(ctx, LoginStatsCollection)
+	writer := client.BulkWriter(ctx)
+	var jobs []*gfirestore.BulkWriterJob
+	for key, day := range days {
+		ref := firestore.DocRefContext(ctx, LoginStatsCollection, key)
+		job, err := writer.Set(ref, map[string]interface{}{
+			statLogins:      day.Logins,
+			statUniqueUsers: int64(len(dayUsers[key])),
+			statOTPsIssued:  day.OTPsIssued,
+			statOTPLogins:   day.OTPLogins,
+			"date":          key,
+		}, gfirestore.MergeAll)
+		if err != nil {
+			writer.End()
+			return fmt.Errorf("failed to enqueue login stats '%s': %w", key, err)
+		}
+		jobs = append(jobs, job)
+		for uid := range dayUsers[key] {
+			job, err := writer.Set(ref.Collection("users").Doc(uid), map[string]interface{}{"uid": uid})
+			if err != nil {
+				writer.End()
+				return fmt.Errorf("failed to enqueue login stats user '%s': %w", uid, err)
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	writer.End()
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return fmt.Errorf("failed to write login stats: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordLoginStat incrementa un campo del agregado de hoy. Es un registro auxiliar: un
+// fallo no afecta al login.
+func recordLoginStat(ctx context.Context, field string) {
+	today := firebase.Now().UTC().Format("2006-01-02")
+	firestore.DocRefContext(ctx, LoginStatsCollection, today).Set(ctx, map[string]interface{}{
+		field:  gfirestore.Increment(1),
+		"date": today,
+	}, gfirestore.MergeAll)
+}
+
+// recordLogin cuenta un login y, si es el primero del usuario en el día, un usuario único
+func recordLogin(ctx context.Context, uid string) {
+	today := firebase.Now().UTC().Format("2006-01-02")
+	dayRef := firestore.DocRefContext(ctx, LoginStatsCollection, today)
+	userRef := dayRef.Collection("users").Doc(uid)
+
+	firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(userRef)
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+		update := map[string]interface{}{statLogins: gfirestore.Increment(1), "date": today}
+		if snap == nil || !snap.Exists() {
+			update[statUniqueUsers] = gfirestore.Increment(1)
+			if err := tx.Create(userRef, map[string]interface{}{"uid": uid}); err != nil {
+				return err
+			}
+		}
+		return tx.Set(dayRef, update, gfirestore.MergeAll)
+	})
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// SessionMiddleware valida la sesión enviada en "Authorization: Bearer <token>" (un ID de
+// sesión o un JWT de sesión) y la guarda en el contexto con firebase.WithSession, junto
+// con los datos del cliente para la validación del enlace de sesión. Un token inválido
+// responde 401; sin token, responde 401 solo si required es verdadero.
+func SessionMiddleware(required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := firebase.WithClientInfo(r.Context(), ClientInfoFromRequest(r))
+
+			token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+			if token == "" || token == r.Header.Get("Authorization") {
+				if required {
+					http.Error(w, "sesión requerida", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			var session *SessionInfo
+			var err error
+			if strings.Count(token, ".") == 2 {
+				session, err = ValidateSessionJWT(ctx, token)
+			} else {
+				session, err = ValidateSession(ctx, token)
+			}
+			if err != nil {
+				http.Error(w, "sesión inválida o expirada", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(firebase.WithSession(ctx, session)))
+		})
+	}
+}
+
+// ClientInfoFromRequest extrae IP (ver firebase.RequestIP), user agent y huella (cabecera
+// X-Device-Fingerprint) de la petición
+func ClientInfoFromRequest(r *http.Request) firebase.ClientInfo {
+	ip := firebase.RequestIP(r)
+	return firebase.ClientInfo{
+		IP:          ip,
+		Fingerprint: r.Header.Get("X-Device-Fingerprint"),
+		UserAgent:   r.UserAgent(),
+	}
+}
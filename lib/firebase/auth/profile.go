@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// UsersCollection colección donde se guarda el perfil espejo de cada usuario de Auth
+const UsersCollection = "users"
+
+// GetUserProfile obtiene el perfil espejo de un usuario
+func GetUserProfile(ctx context.Context, uid string) (*firebase.Document, error) {
+	doc, err := firestore.GetDocument(ctx, UsersCollection, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile for user '%s': %w", uid, err)
+	}
+	return doc, nil
+}
+
+// syncUserProfile actualiza (merge) el perfil espejo de un usuario
+func syncUserProfile(ctx context.Context, uid string, fields map[string]interface{}) error {
+	if err := firestore.UpdateDocument(ctx, UsersCollection, uid, fields); err != nil {
+		return fmt.Errorf("failed to sync profile for user '%s': %w", uid, err)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"firebase.google.com/go/v4/auth"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Tipos de diferencia entre Auth y sus copias en Firestore
+const (
+	DriftMissingProfile  = "missing_profile"  // usuario de Auth sin documento en users
+	DriftStaleProfile    = "stale_profile"    // el perfil no coincide con Auth (ver Fields)
+	DriftOrphanedProfile = "orphaned_profile" // perfil sin usuario en Auth
+	DriftMissingClaims   = "missing_claims"   // usuario con claims en Auth sin user_claims
+	DriftStaleClaims     = "stale_claims"     // user_claims distinto de los claims de Auth
+	DriftOrphanedClaims  = "orphaned_claims"  // user_claims sin usuario en Auth
+)
+
+// reconcileBatchSize usuarios cuyos documentos espejo se leen juntos
+const reconcileBatchSize = 100
+
+// Drift diferencia encontrada para un usuario
+type Drift struct {
+	UID    string   `json:"uid"`
+	Kind   string   `json:"kind"`
+	Fields []string `json:"fields,omitempty"` // campos distintos en stale_profile
+	// Manual la diferencia (o parte de ella) no se corrige automáticamente: un perfil
+	// activo con el usuario deshabilitado en Auth puede deberse a un bloqueo desde la consola
+	Manual bool `json:"manual,omitempty"`
+}
+
+// ReconcileOptions configuración de ReconcileMirrors
+type ReconcileOptions struct {
+	DryRun bool // solo reportar
+	// DeleteOrphanedProfiles elimina los perfiles sin usuario en Auth; por defecto solo se
+	// reportan, porque pueden tener datos que no existen en ningún otro lugar
+	DeleteOrphanedProfiles bool
+}
+
+// ReconcileReport resultado de ReconcileMirrors
+type ReconcileReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Users    int               `json:"users"`    // usuarios de Auth revisados
+	Profiles int               `json:"profiles"` // documentos de users revisados
+	Claims   int               `json:"claims"`   // documentos de user_claims revisados
+	Drift    []Drift           `json:"drift"`
+	Repaired int               `json:"repaired"`
+	Failed   map[string]string `json:"failed,omitempty"` // UID -> error
+}
+
+// ReconcileMirrors compara los usuarios de Auth con sus perfiles espejo (users) y la copia
+// de sus claims (user_claims), reporta las diferencias y, salvo en DryRun, las corrige:
+// Auth prevalece en los datos de identidad y los claims (como en SyncClaimsFromAuth); un
+// perfil suspendido deshabilita al usuario en Auth, pero un usuario deshabilitado con el
+// perfil activo solo se reporta (Drift.Manual). Los claims huérfanos se eliminan; los
+// perfiles huérfanos solo con DeleteOrphanedProfiles. Mantiene en memoria los UID de Auth
+// y vuelve a consultar cada candidato a huérfano antes de eliminarlo, porque el usuario
+// pudo crearse durante el recorrido.
+func ReconcileMirrors(ctx context.Context, options ReconcileOptions) (*ReconcileReport, error) {
+	report := &ReconcileReport{DryRun: options.DryRun, Failed: map[string]string{}}
+	known := map[string]bool{}
+
+	var batch []*auth.ExportedUserRecord
+	users := firebase.GetAuthClient().Users(ctx, "")
+	for {
+		record, err := users.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to list users: %w", err)
+		}
+		report.Users++
+		known[record.UID] = true
+		batch = append(batch, record)
+		if len(batch) == reconcileBatchSize {
+			if err := reconcileUsers(ctx, batch, report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := reconcileUsers(ctx, batch, report); err != nil {
+		return report, err
+	}
+
+	for _, mirror := range []struct {
+		collection, kind string
+		scanned          *int
+		repair           bool
+	}{
+		{UsersCollection, DriftOrphanedProfile, &report.Profiles, options.DeleteOrphanedProfiles},
+		{"user_claims", DriftOrphanedClaims, &report.Claims, true},
+	} {
+		var candidates []string
+		iter := firebase.GetFirestoreClient().Collection(mirror.collection).Select().Documents(ctx)
+		for {
+			snap, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				iter.Stop()
+				return report, fmt.Errorf("failed to scan %s: %w", mirror.collection, err)
+			}
+			*mirror.scanned++
+			if !known[snap.Ref.ID] {
+				candidates = append(candidates, snap.Ref.ID)
+			}
+		}
+		iter.Stop()
+
+		orphans, err := missingUsers(ctx, candidates)
+		if err != nil {
+			return report, err
+		}
+		for _, uid := range orphans {
+			drift := Drift{UID: uid, Kind: mirror.kind}
+			report.Drift = append(report.Drift, drift)
+			if !mirror.repair {
+				continue
+			}
+			collection := mirror.collection
+			report.repair(drift, func() error {
+				err := firestore.DeleteDocument(ctx, collection, uid)
+				if firestore.IsNotFound(err) {
+					return nil
+				}
+				return err
+			})
+		}
+	}
+
+	if len(report.Failed) == 0 {
+		report.Failed = nil
+	}
+	return report, nil
+}
+
+// missingUsers retorna los UID que siguen sin usuario en Auth (consultados en lotes de
+// reconcileBatchSize)
+func missingUsers(ctx context.Context, uids []string) ([]string, error) {
+	var missing []string
+	for start := 0; start < len(uids); start += reconcileBatchSize {
+		end := min(start+reconcileBatchSize, len(uids))
+		identifiers := make([]auth.UserIdentifier, 0, end-start)
+		for _, uid := range uids[start:end] {
+			identifiers = append(identifiers, auth.UIDIdentifier{UID: uid})
+		}
+		result, err := firebase.GetAuthClient().GetUsers(ctx, identifiers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up users: %w", err)
+		}
+		for _, notFound := range result.NotFound {
+			if identifier, ok := notFound.(auth.UIDIdentifier); ok {
+				missing = append(missing, identifier.UID)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// reconcileUsers compara un lote de usuarios con sus documentos espejo
+func reconcileUsers(ctx context.Context, records []*auth.ExportedUserRecord, report *ReconcileReport) error {
+	if len(records) == 0 {
+		return nil
+	}
+	client := firebase.GetFirestoreClient()
+	refs := make([]*gfirestore.DocumentRef, 0, len(records)*2)
+	for _, record := range records {
+		refs = append(refs, client.Collection(UsersCollection).Doc(record.UID), client.Collection("user_claims").Doc(record.UID))
+	}
+	snaps, err := client.GetAll(ctx, refs)
+	if err != nil {
+		return fmt.Errorf("failed to read mirror documents: %w", err)
+	}
+
+	for i, record := range records {
+		profile, claimsDoc := snaps[2*i], snaps[2*i+1]
+		authClaims := copyClaims(record.CustomClaims)
+
+		switch {
+		case !profile.Exists():
+			drift := Drift{UID: record.UID, Kind: DriftMissingProfile}
+			report.Drift = append(report.Drift, drift)
+			report.repair(drift, func() error {
+				state := StateActive
+				if record.Disabled {
+					state = StateSuspended
+				}
+				return syncUserProfile(ctx, record.UID, map[string]interface{}{
+					"email":        record.Email,
+					"display_name": record.DisplayName,
+					"photo_url":    record.PhotoURL,
+					"state":        string(state),
+				})
+			})
+		default:
+			fields, updates, disabled, manual := profileDrift(record, profile.Data())
+			if len(fields) == 0 {
+				break
+			}
+			drift := Drift{UID: record.UID, Kind: DriftStaleProfile, Fields: fields, Manual: manual}
+			report.Drift = append(report.Drift, drift)
+			if len(updates) == 0 && disabled == nil {
+				break
+			}
+			report.repair(drift, func() error {
+				if disabled != nil {
+					if _, err := firebase.GetAuthClient().UpdateUser(ctx, record.UID, (&auth.UserToUpdate{}).Disabled(*disabled)); err != nil {
+						return fmt.Errorf("failed to update disabled flag for user '%s': %w", record.UID, err)
+					}
+					if *disabled {
+						if err := RevokeUserSessions(ctx, record.UID); err != nil {
+							return err
+						}
+					}
+				}
+				if len(updates) == 0 {
+					return nil
+				}
+				return syncUserProfile(ctx, record.UID, updates)
+			})
+		}
+
+		if !claimsDoc.Exists() {
+			if len(authClaims) == 0 {
+				continue
+			}
+			drift := Drift{UID: record.UID, Kind: DriftMissingClaims}
+			report.Drift = append(report.Drift, drift)
+			report.repair(drift, func() error { return setUserClaimsDocument(ctx, record.UID, authClaims) })
+			continue
+		}
+		stored, _ := claimsDoc.Data()["claims"].(map[string]interface{})
+		if stored == nil {
+			stored = map[string]interface{}{}
+		}
+		if !claimsEqual(stored, authClaims) {
+			drift := Drift{UID: record.UID, Kind: DriftStaleClaims}
+			report.Drift = append(report.Drift, drift)
+			report.repair(drift, func() error { return setUserClaimsDocument(ctx, record.UID, authClaims) })
+		}
+	}
+	return nil
+}
+
+// profileDrift compara el perfil con el usuario de Auth. Retorna los campos distintos,
+// los valores de Auth que deben copiarse al perfil, el valor que debe tener el flag
+// disabled de Auth si el perfil está suspendido y Auth no, y si queda una diferencia que
+// requiere revisión manual.
+func profileDrift(record *auth.ExportedUserRecord, data map[string]interface{}) ([]string, map[string]interface{}, *bool, bool) {
+	var fields []string
+	updates := map[string]interface{}{}
+	mirrored := []struct {
+		field string
+		value string
+	}{
+		{"email", record.Email},
+		{"display_name", record.DisplayName},
+		{"photo_url", record.PhotoURL},
+	}
+	for _, m := range mirrored {
+		stored, present := data[m.field].(string)
+		if stored == m.value || (!present && m.value == "") {
+			continue
+		}
+		fields = append(fields, m.field)
+		updates[m.field] = m.value
+	}
+	if verified, ok := data["email_verified"].(bool); ok && verified != record.EmailVerified {
+		fields = append(fields, "email_verified")
+		updates["email_verified"] = record.EmailVerified
+	}
+
+	// Sin estado en el perfil (creado antes del ciclo de vida) se toma el de Auth. Nunca se
+	// habilita en Auth un usuario deshabilitado: pudo bloquearse desde la consola.
+	var disabled *bool
+	var manual bool
+	state, _ := data["state"].(string)
+	switch expected := AccountState(state) != StateActive; {
+	case state == "" && record.Disabled:
+		fields = append(fields, "state")
+		updates["state"] = string(StateSuspended)
+	case state != "" && expected && !record.Disabled:
+		fields = append(fields, "state")
+		disabled = &expected
+	case state != "" && !expected && record.Disabled:
+		fields = append(fields, "state")
+		manual = true
+	}
+	return fields, updates, disabled, manual
+}
+
+// repair aplica la corrección de una diferencia (salvo en DryRun) y la contabiliza
+func (r *ReconcileReport) repair(drift Drift, fix func() error) {
+	if r.DryRun {
+		return
+	}
+	if err := fix(); err != nil {
+		r.Failed[drift.UID] = fmt.Sprintf("%s: %v", drift.Kind, err)
+		return
+	}
+	r.Repaired++
+}
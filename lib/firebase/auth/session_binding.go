@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"sync"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// BindingLevel nivel de exigencia al comparar el cliente de la petición con el que creó
+// la sesión. Los datos del cliente se toman de firebase.WithClientInfo.
+type BindingLevel int
+
+const (
+	// BindingOff no verifica el cliente (por defecto)
+	BindingOff BindingLevel = iota
+	// BindingLenient rechaza solo si cambian a la vez la subred IP y la huella del
+	// dispositivo; tolera redes móviles o un navegador actualizado
+	BindingLenient
+	// BindingStrict exige que coincidan la subred IP y la huella del dispositivo, y que
+	// la petición informe ambos datos
+	BindingStrict
+)
+
+var (
+	bindingMu    sync.RWMutex
+	bindingLevel = BindingOff
+)
+
+// SetSessionBinding configura el nivel de vinculación de sesiones. Solo afecta a las
+// sesiones creadas con datos del cliente en el contexto.
+func SetSessionBinding(level BindingLevel) {
+	bindingMu.Lock()
+	defer bindingMu.Unlock()
+	bindingLevel = level
+}
+
+// GetSessionBinding retorna el nivel de vinculación configurado
+func GetSessionBinding() BindingLevel {
+	bindingMu.RLock()
+	defer bindingMu.RUnlock()
+	return bindingLevel
+}
+
+// bindingFields campos del cliente guardados al crear la sesión. La huella se guarda
+// como hash para no almacenarla en claro.
+func bindingFields(ctx context.Context) map[string]interface{} {
+	client, ok := firebase.ClientInfoFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	fields := map[string]interface{}{}
+	if subnet := ipSubnet(client.IP); subnet != "" {
+		fields["client_subnet"] = subnet
+	}
+	if client.Fingerprint != "" {
+		fields["client_fingerprint"] = hashFingerprint(client.Fingerprint)
+	}
+	if client.UserAgent != "" {
+		fields["client_user_agent"] = client.UserAgent
+	}
+	return fields
+}
+
+// checkSessionBinding compara el cliente de la petición con el guardado en la sesión
+func checkSessionBinding(ctx context.Context, session map[string]interface{}) error {
+	level := GetSessionBinding()
+	if level == BindingOff {
+		return nil
+	}
+
+	storedSubnet, _ := session["client_subnet"].(string)
+	storedFingerprint, _ := session["client_fingerprint"].(string)
+	if storedSubnet == "" && storedFingerprint == "" {
+		return nil // sesión creada sin datos del cliente
+	}
+
+	client, ok := firebase.ClientInfoFromContext(ctx)
+	if !ok {
+		if level == BindingStrict {
+			return &firebase.SessionBindingError{Reason: "request has no client information"}
+		}
+		return nil
+	}
+
+	subnetMatches := storedSubnet == "" || ipSubnet(client.IP) == storedSubnet
+	fingerprintMatches := storedFingerprint == "" || (client.Fingerprint != "" && hashFingerprint(client.Fingerprint) == storedFingerprint)
+
+	switch level {
+	case BindingStrict:
+		if !subnetMatches {
+			return &firebase.SessionBindingError{Reason: "IP subnet changed"}
+		}
+		if !fingerprintMatches {
+			return &firebase.SessionBindingError{Reason: "device fingerprint changed"}
+		}
+	case BindingLenient:
+		if !subnetMatches && !fingerprintMatches {
+			return &firebase.SessionBindingError{Reason: "IP subnet and device fingerprint changed"}
+		}
+	}
+	return nil
+}
+
+// ipSubnet reduce la IP a su red (/24 en IPv4, /64 en IPv6)
+func ipSubnet(value string) string {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return ""
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return (&net.IPNet{IP: ipv4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+func hashFingerprint(fingerprint string) string {
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:])
+}
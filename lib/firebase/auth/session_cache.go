@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+type cachedSessionEntry struct {
+	info     SessionInfo
+	binding  map[string]interface{}
+	cachedAt time.Time
+}
+
+// sessionCache caché en memoria de ValidateSession. Los listeners sobre user_sessions y
+// user_claims invalidan las entradas en cuanto una sesión o los claims de su usuario
+// cambian (Logout, RevokeUserSessions, SetClaims...), incluso desde otros procesos.
+// Cada invalidación incrementa generation, para descartar las lecturas que estaban en
+// curso cuando llegó.
+type sessionCache struct {
+	mu         sync.RWMutex
+	ttl        time.Duration
+	entries    map[string]*cachedSessionEntry
+	byUID      map[string]map[string]bool
+	generation uint64
+	cancel     context.CancelFunc
+}
+
+// cacheMarker estado de la caché antes de leer una sesión de Firestore
+type cacheMarker struct {
+	cache      *sessionCache
+	generation uint64
+}
+
+var (
+	sessionCacheMu sync.RWMutex
+	activeCache    *sessionCache
+)
+
+// EnableSessionCache activa la caché de sesiones. ttl limita cuánto puede servirse una
+// entrada sin volver a leer Firestore aunque no llegue ninguna invalidación. Si un
+// listener falla, la caché se desactiva y ValidateSession vuelve a leer Firestore.
+func EnableSessionCache(ctx context.Context, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cache := &sessionCache{
+		ttl:     ttl,
+		entries: map[string]*cachedSessionEntry{},
+		byUID:   map[string]map[string]bool{},
+		cancel:  cancel,
+	}
+
+	// Solo interesan los documentos modificados desde que arranca la caché
+	since := firebase.Now()
+	stop, err := cache.watch(ctx, since)
+	if err != nil {
+		cancel()
+		return err
+	}
+	go cache.rotate(ctx, since, stop)
+
+	sessionCacheMu.Lock()
+	previous := activeCache
+	activeCache = cache
+	sessionCacheMu.Unlock()
+	if previous != nil {
+		previous.cancel()
+	}
+	return nil
+}
+
+// DisableSessionCache detiene los listeners y descarta la caché de sesiones
+func DisableSessionCache() {
+	sessionCacheMu.Lock()
+	cache := activeCache
+	activeCache = nil
+	sessionCacheMu.Unlock()
+	if cache != nil {
+		cache.cancel()
+	}
+}
+
+func currentSessionCache() *sessionCache {
+	sessionCacheMu.RLock()
+	defer sessionCacheMu.RUnlock()
+	return activeCache
+}
+
+// watch inicia los listeners sobre los documentos modificados desde since. Los cambios de
+// la instantánea inicial también invalidan: cubren el intervalo entre since y el inicio.
+func (c *sessionCache) watch(ctx context.Context, since time.Time) (context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	client := firebase.GetFirestoreClient()
+	sessions := client.Collection("user_sessions").Where("updated_at", ">", since).Snapshots(ctx)
+	claims := client.Collection("user_claims").Where("updated_at", ">", since).Snapshots(ctx)
+	evictions := []func(*gfirestore.DocumentSnapshot){
+		func(doc *gfirestore.DocumentSnapshot) { c.evictSession(doc.Ref.ID) },
+		func(doc *gfirestore.DocumentSnapshot) { c.evictUser(doc.Ref.ID) },
+	}
+
+	for i, iter := range []*gfirestore.QuerySnapshotIterator{sessions, claims} {
+		snap, err := iter.Next()
+		if err != nil {
+			cancel()
+			sessions.Stop()
+			claims.Stop()
+			return nil, fmt.Errorf("failed to start session cache listener: %w", err)
+		}
+		for _, change := range snap.Changes {
+			evictions[i](change.Doc)
+		}
+	}
+
+	go c.listen(ctx, sessions, evictions[0])
+	go c.listen(ctx, claims, evictions[1])
+	return cancel, nil
+}
+
+// rotate reinicia los listeners cada ttl con since en el inicio del período anterior,
+// para que el conjunto de documentos escuchados no crezca indefinidamente. Los nuevos
+// listeners arrancan antes de detener los anteriores, por lo que no quedan huecos.
+func (c *sessionCache) rotate(ctx context.Context, since time.Time, stop context.CancelFunc) {
+	ticker := time.NewTicker(c.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			return
+		case <-ticker.C:
+		}
+
+		next := firebase.Now()
+		nextStop, err := c.watch(ctx, since)
+		if err != nil {
+			stop()
+			c.disable()
+			return
+		}
+		stop()
+		stop, since = nextStop, next
+	}
+}
+
+func (c *sessionCache) listen(ctx context.Context, iter *gfirestore.QuerySnapshotIterator, evict func(*gfirestore.DocumentSnapshot)) {
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err != nil {
+			if ctx.Err() == nil {
+				// Sin invalidaciones la caché podría servir sesiones revocadas
+				c.disable()
+			}
+			return
+		}
+		for _, change := range snap.Changes {
+			evict(change.Doc)
+		}
+	}
+}
+
+// disable desactiva la caché si sigue siendo la activa y detiene sus listeners
+func (c *sessionCache) disable() {
+	sessionCacheMu.Lock()
+	if activeCache == c {
+		activeCache = nil
+	}
+	sessionCacheMu.Unlock()
+	c.cancel()
+}
+
+func (c *sessionCache) get(sessionID string) (*cachedSessionEntry, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[sessionID]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	now := firebase.Now()
+	if now.Sub(entry.cachedAt) > c.ttl || now.After(entry.info.ExpiresAt) {
+		c.evictSession(sessionID)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (c *sessionCache) put(generation uint64, info *SessionInfo, binding map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return
+	}
+	c.entries[info.SessionID] = &cachedSessionEntry{info: *info, binding: binding, cachedAt: firebase.Now()}
+	if c.byUID[info.UID] == nil {
+		c.byUID[info.UID] = map[string]bool{}
+	}
+	c.byUID[info.UID][info.SessionID] = true
+}
+
+func (c *sessionCache) evictSession(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if entry, ok := c.entries[sessionID]; ok {
+		delete(c.byUID[entry.info.UID], sessionID)
+		if len(c.byUID[entry.info.UID]) == 0 {
+			delete(c.byUID, entry.info.UID)
+		}
+		delete(c.entries, sessionID)
+	}
+}
+
+func (c *sessionCache) evictUser(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for sessionID := range c.byUID[uid] {
+		delete(c.entries, sessionID)
+	}
+	delete(c.byUID, uid)
+}
+
+// cachedSession retorna una copia de la sesión en caché, si la hay
+func cachedSession(sessionID string) (*SessionInfo, map[string]interface{}, bool) {
+	cache := currentSessionCache()
+	if cache == nil {
+		return nil, nil, false
+	}
+	entry, ok := cache.get(sessionID)
+	firebase.RecordCacheLookup("sessions", ok)
+	if !ok {
+		return nil, nil, false
+	}
+	info := entry.info
+	claims := make(map[string]interface{}, len(info.Claims))
+	for key, value := range info.Claims {
+		claims[key] = value
+	}
+	info.Claims = claims
+	return &info, entry.binding, true
+}
+
+// sessionCacheMarker captura la caché activa y su generación antes de leer una sesión
+func sessionCacheMarker() cacheMarker {
+	cache := currentSessionCache()
+	if cache == nil {
+		return cacheMarker{}
+	}
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	return cacheMarker{cache: cache, generation: cache.generation}
+}
+
+// cacheSession guarda la sesión validada junto con sus datos de vinculación, salvo que la
+// caché haya cambiado o recibido una invalidación desde marker
+func cacheSession(marker cacheMarker, info *SessionInfo, session map[string]interface{}) {
+	cache := currentSessionCache()
+	if cache == nil || cache != marker.cache {
+		return
+	}
+	binding := map[string]interface{}{}
+	for _, field := range []string{"client_subnet", "client_fingerprint"} {
+		if value, ok := session[field]; ok {
+			binding[field] = value
+		}
+	}
+	cache.put(marker.generation, info, binding)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"firebase.google.com/go/v4/auth"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// AccountState estado del ciclo de vida de una cuenta
+type AccountState string
+
+const (
+	StateInvited     AccountState = "invited"
+	StateActive      AccountState = "active"
+	StateSuspended   AccountState = "suspended"
+	StateDeactivated AccountState = "deactivated"
+)
+
+// allowedTransitions define las transiciones válidas entre estados
+var allowedTransitions = map[AccountState][]AccountState{
+	StateInvited:     {StateActive, StateDeactivated},
+	StateActive:      {StateSuspended, StateDeactivated},
+	StateSuspended:   {StateActive, StateDeactivated},
+	StateDeactivated: {},
+}
+
+// CanTransition indica si se puede pasar del estado from al estado to
+func CanTransition(from, to AccountState) bool {
+	for _, allowed := range allowedTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAccountState obtiene el estado de la cuenta desde el perfil espejo.
+// Los usuarios sin perfil (creados antes del espejo) se consideran activos.
+func GetAccountState(ctx context.Context, uid string) (AccountState, error) {
+	doc, err := GetUserProfile(ctx, uid)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return StateActive, nil
+		}
+		return "", err
+	}
+	state, _ := doc.Data["state"].(string)
+	if state == "" {
+		return StateActive, nil
+	}
+	return AccountState(state), nil
+}
+
+// TransitionAccountState cambia el estado de la cuenta validando la transición.
+// Deshabilita al usuario, revoca sus sesiones e invalida sus OTP pendientes al suspender
+// o desactivar, y lo habilita de nuevo al activar.
+func TransitionAccountState(ctx context.Context, uid string, to AccountState, reason string) error {
+	from, err := GetAccountState(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if !CanTransition(from, to) {
+		return &firebase.InvalidStateTransitionError{UID: uid, From: string(from), To: string(to)}
+	}
+
+	client := firebase.GetAuthClient()
+	record, err := client.GetUser(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("failed to get user '%s': %w", uid, err)
+	}
+	disabled := to != StateActive
+	if _, err := client.UpdateUser(ctx, uid, (&auth.UserToUpdate{}).Disabled(disabled)); err != nil {
+		return fmt.Errorf("failed to update disabled flag for user '%s': %w", uid, err)
+	}
+
+	if to == StateSuspended || to == StateDeactivated {
+		if err := RevokeUserSessions(ctx, uid); err != nil {
+			return err
+		}
+		if err := invalidatePendingOTPs(ctx, uid); err != nil {
+			return err
+		}
+	}
+
+	err = syncUserProfile(ctx, uid, map[string]interface{}{
+		"state":            string(to),
+		"previous_state":   string(from),
+		"state_reason":     reason,
+		"state_changed_at": firebase.Now(),
+	})
+	if err != nil && record.Disabled != disabled {
+		// El perfil conserva el estado anterior: Auth vuelve a corresponderle
+		if _, rollbackErr := client.UpdateUser(ctx, uid, (&auth.UserToUpdate{}).Disabled(record.Disabled)); rollbackErr != nil {
+			return fmt.Errorf("%w (failed to restore disabled flag for user '%s': %v)", err, uid, rollbackErr)
+		}
+	}
+	return err
+}
+
+// ActivateUser activa una cuenta invitada
+func ActivateUser(ctx context.Context, uid string) error {
+	return TransitionAccountState(ctx, uid, StateActive, "activated")
+}
+
+// SuspendUser suspende una cuenta activa
+func SuspendUser(ctx context.Context, uid, reason string) error {
+	return TransitionAccountState(ctx, uid, StateSuspended, reason)
+}
+
+// ReactivateUser reactiva una cuenta suspendida
+func ReactivateUser(ctx context.Context, uid string) error {
+	from, err := GetAccountState(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if from != StateSuspended {
+		return &firebase.InvalidStateTransitionError{UID: uid, From: string(from), To: string(StateActive)}
+	}
+	return TransitionAccountState(ctx, uid, StateActive, "reactivated")
+}
+
+// DeactivateUser desactiva una cuenta de forma definitiva
+func DeactivateUser(ctx context.Context, uid, reason string) error {
+	return TransitionAccountState(ctx, uid, StateDeactivated, reason)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/ratelimit"
+)
+
+// ReauthenticateResponse respuesta de ReauthenticateWithOTP
+type ReauthenticateResponse struct {
+	Success      bool      `json:"success"`
+	Message      string    `json:"message"`
+	AuthTime     time.Time `json:"auth_time,omitempty"`
+	SessionToken string    `json:"session_token,omitempty"` // JWT renovado si ConfigureJWT está activo
+}
+
+// RequireRecentAuth exige que la identidad del usuario se haya verificado hace menos de
+// maxAge, aunque la sesión siga vigente. Retorna *firebase.ReauthenticationRequiredError
+// para que el cliente pida un OTP nuevo (RequestOTP + ReauthenticateWithOTP).
+func RequireRecentAuth(ctx context.Context, session *SessionInfo, maxAge time.Duration) error {
+	if session == nil || session.UID == "" {
+		return firebase.ErrUnauthenticated
+	}
+	if session.AuthTime.IsZero() || firebase.Now().Sub(session.AuthTime) > maxAge {
+		return &firebase.ReauthenticationRequiredError{AuthTime: session.AuthTime, MaxAge: maxAge}
+	}
+	return nil
+}
+
+// ReauthenticateWithOTP verifica un OTP del titular de la sesión y renueva su auth_time,
+// habilitando las operaciones protegidas con RequireRecentAuth
+func ReauthenticateWithOTP(ctx context.Context, sessionID, otp string) (*ReauthenticateResponse, error) {
+	session, err := ValidateSession(ctx, sessionID)
+	if err != nil {
+		return &ReauthenticateResponse{Success: false, Message: "Sesión inválida o expirada."}, nil
+	}
+	if session.ImpersonatedBy != "" {
+		return &ReauthenticateResponse{Success: false, Message: "Una sesión de suplantación no puede reautenticarse."}, nil
+	}
+
+	allowed, err := ratelimit.Allow(ctx, "otp_attempt:"+normalizeEmail(session.Email), otpAttemptLimit, otpLimiterWindow)
+	if err != nil {
+		return nil, fmt.Errorf("error checking OTP rate limit: %w", err)
+	}
+	if !allowed {
+		return &ReauthenticateResponse{Success: false, Message: "Demasiados intentos. Intenta más tarde."}, nil
+	}
+
+	otpDocs, err := firestore.QueryDocuments(ctx, "user_otps", firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			{Field: "uid", Operator: "==", Value: session.UID},
+			{Field: "otp", Operator: "==", Value: otp},
+			{Field: "used", Operator: "==", Value: false},
+		},
+		OrderBy:  "created_at",
+		OrderDir: "desc",
+		Limit:    1,
+	})
+	if err != nil || len(otpDocs) == 0 {
+		return &ReauthenticateResponse{Success: false, Message: "OTP inválido o no encontrado."}, nil
+	}
+
+	otpDoc := otpDocs[0]
+	if expiresAt, _ := otpDoc.Data["expires_at"].(time.Time); firebase.Now().After(expiresAt) {
+		return &ReauthenticateResponse{Success: false, Message: "El OTP ha expirado."}, nil
+	}
+	if err := firestore.UpdateDocument(ctx, "user_otps", otpDoc.ID, map[string]interface{}{"used": true}); err != nil {
+		return nil, fmt.Errorf("error marking OTP as used: %w", err)
+	}
+
+	authTime := firebase.Now()
+	if err := firestore.UpdateDocument(ctx, "user_sessions", sessionID, map[string]interface{}{"auth_time": authTime}); err != nil {
+		return nil, fmt.Errorf("error updating session auth time: %w", err)
+	}
+
+	response := &ReauthenticateResponse{Success: true, Message: "Identidad verificada", AuthTime: authTime}
+	if currentJWTConfig() != nil {
+		session.AuthTime = authTime
+		response.SessionToken, err = IssueSessionJWT(ctx, session)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// UsernamesCollection reservas de nombres de usuario: un documento por nombre
+// normalizado con el UID dueño, de modo que la unicidad se garantiza en la transacción
+// que crea la reserva
+const UsernamesCollection = "usernames"
+
+var (
+	// UsernameCooldown tiempo mínimo entre cambios de nombre de un usuario
+	UsernameCooldown = 30 * 24 * time.Hour
+	// UsernameHold tiempo que un nombre liberado queda reservado para su dueño anterior
+	UsernameHold = 30 * 24 * time.Hour
+)
+
+// usernamePattern 3 a 30 caracteres: letras, números, puntos y guiones bajos, sin
+// empezar ni terminar con punto o guion bajo
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._]{1,28}[a-z0-9]$`)
+
+var (
+	reservedMu        sync.RWMutex
+	reservedUsernames = map[string]bool{}
+)
+
+func init() {
+	ReserveUsernames("admin", "administrator", "root", "system", "support", "help", "api",
+		"www", "mail", "security", "staff", "moderator", "official", "null", "undefined",
+		"me", "settings", "login", "logout", "signup", "register", "account")
+}
+
+// ReserveUsernames agrega nombres a la lista de palabras reservadas
+func ReserveUsernames(names ...string) {
+	reservedMu.Lock()
+	defer reservedMu.Unlock()
+	for _, name := range names {
+		reservedUsernames[NormalizeUsername(name)] = true
+	}
+}
+
+// NormalizeUsername normaliza un nombre de usuario para compararlo (minúsculas, sin
+// espacios ni "@" inicial)
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+}
+
+// ValidateUsername verifica el formato y que el nombre no sea una palabra reservada
+func ValidateUsername(username string) error {
+	normalized := NormalizeUsername(username)
+	if !usernamePattern.MatchString(normalized) || strings.Contains(normalized, "..") {
+		return &firebase.UsernameError{Username: username, Reason: "invalid"}
+	}
+	reservedMu.RLock()
+	reserved := reservedUsernames[normalized]
+	reservedMu.RUnlock()
+	if reserved {
+		return &firebase.UsernameError{Username: username, Reason: "reserved"}
+	}
+	return nil
+}
+
+// SetUsername asigna (o cambia) el nombre de usuario. Falla con *firebase.UsernameError
+// si el nombre es inválido, reservado, pertenece a otro usuario o si el último cambio
+// fue hace menos de UsernameCooldown. El nombre anterior queda retenido UsernameHold
+// para que solo su dueño pueda recuperarlo.
+func SetUsername(ctx context.Context, uid, username string) error {
+	if err := ValidateUsername(username); err != nil {
+		return err
+	}
+	normalized := NormalizeUsername(username)
+	profileRef := firestore.DocRefContext(ctx, UsersCollection, uid)
+	newRef := firestore.DocRefContext(ctx, UsernamesCollection, normalized)
+
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		now := firebase.Now()
+		var current string
+		profile, err := tx.Get(profileRef)
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+		if profile != nil && profile.Exists() {
+			data := profile.Data()
+			current, _ = data["username"].(string)
+			changedAt, _ := data["username_changed_at"].(time.Time)
+			if current == normalized {
+				return nil
+			}
+			if current != "" && now.Sub(changedAt) < UsernameCooldown {
+				return &firebase.UsernameError{Username: username, Reason: "cooldown", RetryAt: changedAt.Add(UsernameCooldown)}
+			}
+		}
+
+		reservation, err := tx.Get(newRef)
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+		if reservation != nil && reservation.Exists() && !reservationFree(reservation.Data(), uid, now) {
+			return &firebase.UsernameError{Username: username, Reason: "taken"}
+		}
+
+		if err := tx.Set(newRef, map[string]interface{}{
+			"uid":        uid,
+			"username":   normalized,
+			"created_at": now,
+		}); err != nil {
+			return err
+		}
+		if current != "" {
+			if err := tx.Set(firestore.DocRefContext(ctx, UsernamesCollection, current), map[string]interface{}{
+				"uid":         uid,
+				"username":    current,
+				"released_at": now,
+			}); err != nil {
+				return err
+			}
+		}
+		return tx.Set(profileRef, map[string]interface{}{
+			"username":            normalized,
+			"username_changed_at": now,
+		}, gfirestore.MergeAll)
+	})
+	if err != nil {
+		var usernameErr *firebase.UsernameError
+		if errors.As(err, &usernameErr) {
+			return usernameErr
+		}
+		return fmt.Errorf("failed to set username for user '%s': %w", uid, err)
+	}
+	return nil
+}
+
+// reservationFree indica si una reserva existente puede tomarla uid: es suya, o fue
+// liberada hace más de UsernameHold
+func reservationFree(data map[string]interface{}, uid string, now time.Time) bool {
+	owner, _ := data["uid"].(string)
+	if owner == uid {
+		return true
+	}
+	releasedAt, released := data["released_at"].(time.Time)
+	return released && now.Sub(releasedAt) >= UsernameHold
+}
+
+// LookupUsername retorna el UID dueño del nombre de usuario
+func LookupUsername(ctx context.Context, username string) (string, error) {
+	normalized := NormalizeUsername(username)
+	if normalized == "" {
+		return "", &firebase.UserNotFoundError{Identifier: username}
+	}
+	snap, err := firestore.DocRefContext(ctx, UsernamesCollection, normalized).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return "", &firebase.UserNotFoundError{Identifier: username}
+		}
+		return "", fmt.Errorf("failed to look up username '%s': %w", username, err)
+	}
+	data := snap.Data()
+	if _, released := data["released_at"]; released {
+		return "", &firebase.UserNotFoundError{Identifier: username}
+	}
+	uid, _ := data["uid"].(string)
+	return uid, nil
+}
+
+// GetUserByUsername obtiene el usuario de Auth dueño del nombre de usuario
+func GetUserByUsername(ctx context.Context, username string) (*firebase.UserRecord, error) {
+	uid, err := LookupUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return GetUser(ctx, uid)
+}
+
+// IsUsernameAvailable indica si uid podría tomar el nombre (uid vacío = cualquier
+// usuario nuevo). No reserva el nombre.
+func IsUsernameAvailable(ctx context.Context, uid, username string) (bool, error) {
+	if err := ValidateUsername(username); err != nil {
+		return false, nil
+	}
+	snap, err := firestore.DocRefContext(ctx, UsernamesCollection, NormalizeUsername(username)).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to check username '%s': %w", username, err)
+	}
+	return reservationFree(snap.Data(), uid, firebase.Now()), nil
+}
+
+// ReleaseUsername libera de inmediato el nombre de usuario (p. ej. al eliminar la
+// cuenta), sin periodo de retención
+func ReleaseUsername(ctx context.Context, uid string) error {
+	profileRef := firestore.DocRefContext(ctx, UsersCollection, uid)
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		profile, err := tx.Get(profileRef)
+		if err != nil {
+			if firestore.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		current, _ := profile.Data()["username"].(string)
+		if current == "" {
+			return nil
+		}
+		if err := tx.Delete(firestore.DocRefContext(ctx, UsernamesCollection, current)); err != nil {
+			return err
+		}
+		return tx.Update(profileRef, []gfirestore.Update{{Path: "username", Value: gfirestore.Delete}})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release username of user '%s': %w", uid, err)
+	}
+	return nil
+}
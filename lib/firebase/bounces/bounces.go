@@ -0,0 +1,34 @@
+// Package bounces procesa los webhooks de rebotes y quejas de los proveedores de correo
+// (SendGrid Event Webhook y Amazon SES vía SNS) y suprime las direcciones afectadas con
+// auth.SuppressEmail: el perfil del usuario queda marcado como no entregable y
+// auth.RequestOTP deja de enviarle códigos. Los rebotes temporales se ignoran.
+//
+//	http.HandleFunc("/hooks/sendgrid", func(w http.ResponseWriter, r *http.Request) {
+//		body, _ := io.ReadAll(r.Body)
+//		_, err := bounces.HandleSendGridWebhook(r.Context(), body,
+//			r.Header.Get(bounces.SendGridSignatureHeader), r.Header.Get(bounces.SendGridTimestampHeader))
+//		...
+//	})
+package bounces
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/andrescris/firestore/lib/firebase/auth"
+)
+
+// HTTPClient cliente usado para descargar certificados de SNS y confirmar suscripciones
+var HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// suppress suprime una dirección informada por un proveedor
+func suppress(ctx context.Context, email, reason, detail, source string) error {
+	_, err := auth.SuppressEmail(ctx, auth.EmailSuppression{
+		Email:  email,
+		Reason: reason,
+		Detail: detail,
+		Source: source,
+	})
+	return err
+}
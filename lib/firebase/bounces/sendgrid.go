@@ -0,0 +1,118 @@
+package bounces
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+)
+
+// Cabeceras del Event Webhook firmado de SendGrid
+const (
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// SendGridSignatureTolerance antigüedad máxima aceptada del timestamp de la firma
+var SendGridSignatureTolerance = 5 * time.Minute
+
+type sendGridEvent struct {
+	Email  string `json:"email"`
+	Event  string `json:"event"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// HandleSendGridWebhook verifica la firma ECDSA del Event Webhook (clave pública en
+// SENDGRID_WEBHOOK_PUBLIC_KEY) y suprime las direcciones de los eventos bounce (no
+// "blocked"), dropped por rebote o spam, y spamreport. Retorna cuántas se suprimieron.
+func HandleSendGridWebhook(ctx context.Context, payload []byte, signature, timestamp string) (int, error) {
+	if err := verifySendGridSignature(payload, signature, timestamp, os.Getenv("SENDGRID_WEBHOOK_PUBLIC_KEY")); err != nil {
+		return 0, err
+	}
+
+	var events []sendGridEvent
+	if err := json.Unmarshal(payload, &events); err != nil {
+		return 0, fmt.Errorf("failed to decode sendgrid events: %w", err)
+	}
+
+	suppressed := 0
+	for _, event := range events {
+		var reason string
+		switch event.Event {
+		case "bounce":
+			if event.Type != "blocked" {
+				reason = auth.SuppressionBounce
+			}
+		case "spamreport":
+			reason = auth.SuppressionComplaint
+		case "dropped":
+			lower := strings.ToLower(event.Reason)
+			if strings.Contains(lower, "spam") {
+				reason = auth.SuppressionComplaint
+			} else if strings.Contains(lower, "bounce") || strings.Contains(lower, "invalid") {
+				reason = auth.SuppressionBounce
+			}
+		}
+		if reason == "" || event.Email == "" {
+			continue
+		}
+		if err := suppress(ctx, event.Email, reason, event.Reason, "sendgrid"); err != nil {
+			return suppressed, err
+		}
+		suppressed++
+	}
+	return suppressed, nil
+}
+
+// verifySendGridSignature valida la firma ECDSA (SHA-256) de timestamp + payload con la
+// clave pública en base64 (DER) que muestra SendGrid al activar el webhook firmado. Un
+// timestamp fuera de SendGridSignatureTolerance se rechaza para evitar reenvíos.
+func verifySendGridSignature(payload []byte, signature, timestamp, publicKey string) error {
+	if publicKey == "" {
+		return fmt.Errorf("SENDGRID_WEBHOOK_PUBLIC_KEY is not configured")
+	}
+	if signature == "" || timestamp == "" {
+		return &firebase.WebhookSignatureError{Provider: "sendgrid", Reason: "missing signature headers"}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return &firebase.WebhookSignatureError{Provider: "sendgrid", Reason: "invalid timestamp"}
+	}
+	if age := firebase.Now().Sub(time.Unix(seconds, 0)); age > SendGridSignatureTolerance || age < -SendGridSignatureTolerance {
+		return &firebase.WebhookSignatureError{Provider: "sendgrid", Reason: "timestamp outside tolerance"}
+	}
+
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to decode sendgrid public key: %w", err)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse sendgrid public key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("sendgrid public key is not an ECDSA key")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return &firebase.WebhookSignatureError{Provider: "sendgrid", Reason: "malformed signature"}
+	}
+	digest := sha256.Sum256(append([]byte(timestamp), payload...))
+	if !ecdsa.VerifyASN1(key, digest[:], decoded) {
+		return &firebase.WebhookSignatureError{Provider: "sendgrid", Reason: "no matching signature"}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package bounces
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+)
+
+// snsHost hosts válidos para los certificados de firma y las URLs de suscripción de SNS
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+var (
+	certsMu sync.Mutex
+	certs   = map[string]*x509.Certificate{}
+)
+
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL"`
+}
+
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"` // publicación de eventos de SES
+	Bounce           struct {
+		BounceType        string `json:"bounceType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		ComplainedRecipients  []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+// HandleSESNotification verifica la firma del mensaje de SNS y suprime las direcciones de
+// los rebotes permanentes y las quejas de SES. Las confirmaciones de suscripción se
+// aceptan automáticamente, por lo que SES_SNS_TOPIC_ARNS (separados por coma) es
+// obligatorio: solo se aceptan esos tópicos. Retorna cuántas direcciones se suprimieron.
+func HandleSESNotification(ctx context.Context, payload []byte) (int, error) {
+	var message snsMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return 0, fmt.Errorf("failed to decode sns message: %w", err)
+	}
+	allowed := os.Getenv("SES_SNS_TOPIC_ARNS")
+	if allowed == "" {
+		return 0, fmt.Errorf("SES_SNS_TOPIC_ARNS is not configured")
+	}
+	if !containsTopic(allowed, message.TopicArn) {
+		return 0, fmt.Errorf("sns topic '%s' is not allowed", message.TopicArn)
+	}
+	if err := verifySNSSignature(ctx, &message); err != nil {
+		return 0, err
+	}
+
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		return 0, confirmSubscription(ctx, message.SubscribeURL)
+	case "Notification":
+	default:
+		return 0, nil
+	}
+
+	var notification sesNotification
+	if err := json.Unmarshal([]byte(message.Message), &notification); err != nil {
+		return 0, fmt.Errorf("failed to decode ses notification: %w", err)
+	}
+	kind := notification.NotificationType
+	if kind == "" {
+		kind = notification.EventType
+	}
+
+	suppressed := 0
+	switch kind {
+	case "Bounce":
+		if notification.Bounce.BounceType != "Permanent" {
+			return 0, nil
+		}
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			if err := suppress(ctx, recipient.EmailAddress, auth.SuppressionBounce, recipient.DiagnosticCode, "ses"); err != nil {
+				return suppressed, err
+			}
+			suppressed++
+		}
+	case "Complaint":
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			if err := suppress(ctx, recipient.EmailAddress, auth.SuppressionComplaint, notification.Complaint.ComplaintFeedbackType, "ses"); err != nil {
+				return suppressed, err
+			}
+			suppressed++
+		}
+	}
+	return suppressed, nil
+}
+
+// verifySNSSignature valida la firma RSA del mensaje con el certificado de SNS
+// (SignatureVersion 1 = SHA1, 2 = SHA256)
+func verifySNSSignature(ctx context.Context, message *snsMessage) error {
+	text := []byte(snsStringToSign(message))
+	var hash crypto.Hash
+	var digest []byte
+	switch message.SignatureVersion {
+	case "1":
+		sum := sha1.Sum(text)
+		hash, digest = crypto.SHA1, sum[:]
+	case "2":
+		sum := sha256.Sum256(text)
+		hash, digest = crypto.SHA256, sum[:]
+	default:
+		return &firebase.WebhookSignatureError{Provider: "ses", Reason: "unsupported signature version"}
+	}
+	signature, err := base64.StdEncoding.DecodeString(message.Signature)
+	if err != nil {
+		return &firebase.WebhookSignatureError{Provider: "ses", Reason: "malformed signature"}
+	}
+	cert, err := signingCert(ctx, message.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return &firebase.WebhookSignatureError{Provider: "ses", Reason: "signing certificate is not RSA"}
+	}
+	// rsa directamente: x509.CheckSignature ya no acepta SHA1
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+		return &firebase.WebhookSignatureError{Provider: "ses", Reason: "no matching signature"}
+	}
+	return nil
+}
+
+// snsStringToSign arma el texto firmado por SNS según el tipo de mensaje
+func snsStringToSign(message *snsMessage) string {
+	fields := [][2]string{{"Message", message.Message}, {"MessageId", message.MessageID}}
+	if message.Type == "Notification" {
+		if message.Subject != "" {
+			fields = append(fields, [2]string{"Subject", message.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", message.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", message.Timestamp})
+	if message.Type != "Notification" {
+		fields = append(fields, [2]string{"Token", message.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", message.TopicArn}, [2]string{"Type", message.Type})
+
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String()
+}
+
+// signingCert descarga (con caché) el certificado de firma, solo desde hosts de SNS
+func signingCert(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	if err := checkSNSURL(certURL); err != nil {
+		return nil, &firebase.WebhookSignatureError{Provider: "ses", Reason: "untrusted signing certificate URL"}
+	}
+	certsMu.Lock()
+	cert, ok := certs[certURL]
+	certsMu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sns certificate request: %w", err)
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download sns certificate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sns certificate download responded with status %d", resp.StatusCode)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download sns certificate: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("sns certificate is not PEM encoded")
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sns certificate: %w", err)
+	}
+
+	certsMu.Lock()
+	certs[certURL] = cert
+	certsMu.Unlock()
+	return cert, nil
+}
+
+// confirmSubscription visita la SubscribeURL para confirmar la suscripción del tópico
+func confirmSubscription(ctx context.Context, subscribeURL string) error {
+	if err := checkSNSURL(subscribeURL); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build sns subscription request: %w", err)
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to confirm sns subscription: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sns subscription confirmation responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func checkSNSURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" || !snsHost.MatchString(parsed.Hostname()) {
+		return fmt.Errorf("untrusted sns URL '%s'", raw)
+	}
+	return nil
+}
+
+func containsTopic(list, topic string) bool {
+	for _, allowed := range strings.Split(list, ",") {
+		if strings.TrimSpace(allowed) == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+// Package cdc (change data capture) recorre colecciones en orden de updated_at y entrega
+// los cambios por lotes a un handler, guardando un checkpoint después de cada lote para
+// continuar donde quedó tras un reinicio. La entrega es al menos una vez: un lote cuyo
+// checkpoint no llegó a guardarse se vuelve a entregar.
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// CheckpointsCollection colección donde se guardan los checkpoints de cada lector
+const CheckpointsCollection = "cdc_checkpoints"
+
+// Handler procesa un lote de cambios en orden; si retorna error el lote se reintenta
+type Handler func(ctx context.Context, changes []firebase.DocumentChange) error
+
+// Reader lector de cambios de una colección. Las eliminaciones no se ven (el documento ya
+// no existe): usar borrado lógico o leer las lápidas de datasync.
+type Reader struct {
+	Name       string // identifica el checkpoint; un solo proceso por nombre
+	Collection string
+	Handler    Handler
+	BatchSize  int           // por defecto 200
+	Poll       time.Duration // espera cuando no hay cambios (por defecto 5s)
+	// Lag solo lee documentos con updated_at anterior a ahora - Lag, para no saltar
+	// escrituras cuyo updated_at (hora del servidor que escribió) llega con retraso
+	// (por defecto 5s)
+	Lag time.Duration
+}
+
+// Checkpoint posición de un lector
+type Checkpoint struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	LastID    string    `json:"last_id"`
+}
+
+// Run procesa cambios hasta que ctx se cancele. Un error del handler se reintenta tras
+// Poll; los errores de lectura o del checkpoint detienen el lector.
+func (r *Reader) Run(ctx context.Context) error {
+	if r.Name == "" || r.Collection == "" || r.Handler == nil {
+		return fmt.Errorf("cdc reader requires name, collection and handler")
+	}
+	if r.BatchSize <= 0 {
+		r.BatchSize = 200
+	}
+	if r.Poll <= 0 {
+		r.Poll = 5 * time.Second
+	}
+	if r.Lag <= 0 {
+		r.Lag = 5 * time.Second
+	}
+
+	checkpoint, err := LoadCheckpoint(ctx, r.Name)
+	if err != nil {
+		return err
+	}
+
+	for {
+		changes, next, err := r.readBatch(ctx, checkpoint)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if len(changes) > 0 {
+			if err := r.Handler(ctx, changes); err != nil {
+				if !sleep(ctx, r.Poll) {
+					return nil
+				}
+				continue
+			}
+			if err := saveCheckpoint(ctx, r.Name, r.Collection, next); err != nil {
+				return err
+			}
+			checkpoint = next
+		}
+
+		if len(changes) < r.BatchSize && !sleep(ctx, r.Poll) {
+			return nil
+		}
+	}
+}
+
+// readBatch lee el siguiente lote después del checkpoint (orden updated_at, ID)
+func (r *Reader) readBatch(ctx context.Context, checkpoint Checkpoint) ([]firebase.DocumentChange, Checkpoint, error) {
+	query := firebase.FirestoreClientFor(ctx, r.Collection).Collection(r.Collection).
+		Where("updated_at", "<=", firebase.Now().Add(-r.Lag)).
+		OrderBy("updated_at", gfirestore.Asc).
+		OrderBy(gfirestore.DocumentID, gfirestore.Asc).
+		Limit(r.BatchSize)
+	if !checkpoint.UpdatedAt.IsZero() {
+		query = query.StartAfter(checkpoint.UpdatedAt, checkpoint.LastID)
+	}
+
+	snaps, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, checkpoint, fmt.Errorf("failed to read changes from collection '%s': %w", r.Collection, err)
+	}
+
+	changes := make([]firebase.DocumentChange, 0, len(snaps))
+	next := checkpoint
+	for _, snap := range snaps {
+		doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+		updatedAt, _ := doc.GetTime("updated_at")
+		createdAt, _ := doc.GetTime("created_at")
+		change := firebase.DocumentChange{Type: "modified", Document: doc, ReadTime: snap.ReadTime}
+		if createdAt.Equal(updatedAt) {
+			change.Type = "added"
+		}
+		changes = append(changes, change)
+		next = Checkpoint{UpdatedAt: updatedAt, LastID: snap.Ref.ID}
+	}
+	return changes, next, nil
+}
+
+// LoadCheckpoint obtiene el checkpoint de un lector (vacío si nunca guardó uno)
+func LoadCheckpoint(ctx context.Context, name string) (Checkpoint, error) {
+	snap, err := firebase.GetFirestoreClient().Collection(CheckpointsCollection).Doc(name).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return Checkpoint{}, nil
+		}
+		return Checkpoint{}, fmt.Errorf("failed to load cdc checkpoint '%s': %w", name, err)
+	}
+	doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+	checkpoint := Checkpoint{}
+	checkpoint.UpdatedAt, _ = doc.GetTime("updated_at")
+	checkpoint.LastID, _ = doc.GetString("last_id")
+	return checkpoint, nil
+}
+
+// ResetCheckpoint borra el checkpoint: el lector vuelve a procesar la colección completa
+func ResetCheckpoint(ctx context.Context, name string) error {
+	if _, err := firebase.GetFirestoreClient().Collection(CheckpointsCollection).Doc(name).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to reset cdc checkpoint '%s': %w", name, err)
+	}
+	return nil
+}
+
+func saveCheckpoint(ctx context.Context, name, collection string, checkpoint Checkpoint) error {
+	_, err := firebase.GetFirestoreClient().Collection(CheckpointsCollection).Doc(name).Set(ctx, map[string]interface{}{
+		"collection": collection,
+		"updated_at": checkpoint.UpdatedAt,
+		"last_id":    checkpoint.LastID,
+		"saved_at":   firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save cdc checkpoint '%s': %w", name, err)
+	}
+	return nil
+}
+
+// sleep espera d o hasta que ctx se cancele (retorna false en ese caso)
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
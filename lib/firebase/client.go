@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"sync"
 
 	"cloud.google.com/go/firestore"
+	gcs "cloud.google.com/go/storage"
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
+	"firebase.google.com/go/v4/messaging"
+	"firebase.google.com/go/v4/storage"
 	"github.com/joho/godotenv"
 	"google.golang.org/api/option"
 )
@@ -19,9 +23,15 @@ var (
 	app             *firebase.App
 	firestoreClient *firestore.Client
 	authClient      *auth.Client
+	storageClient   *storage.Client
+	storageErr      error
+	messagingClient *messaging.Client
+	messagingOnce   sync.Once
+	messagingErr    error
 	once            sync.Once
 	initErr         error
 	projectID       string
+	clientOption    option.ClientOption
 )
 
 // InitFirebaseFromEnv inicializa Firestore y Auth desde variables de entorno
@@ -94,6 +104,7 @@ func InitFirebaseFromEnv() error {
 			return
 		}
 
+		clientOption = opt
 		ctx := context.Background()
 
 		// Inicializar Firebase App
@@ -106,11 +117,39 @@ func InitFirebaseFromEnv() error {
 
 		// Inicializar clientes
 		initErr = initializeClients(ctx)
+
+		readOnlyFromEnv()
 	})
 
 	return initErr
 }
 
+// InitFirebaseForEmulator inicializa los clientes contra los emuladores de Firestore y Auth
+// (FIRESTORE_EMULATOR_HOST y FIREBASE_AUTH_EMULATOR_HOST deben estar definidas), sin
+// credenciales. Como InitFirebaseFromEnv, solo inicializa una vez por proceso.
+func InitFirebaseForEmulator(emulatorProjectID string) error {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		return fmt.Errorf("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	once.Do(func() {
+		projectID = emulatorProjectID
+		clientOption = option.WithoutAuthentication()
+		ctx := context.Background()
+
+		firebaseApp, err := firebase.NewApp(ctx, &firebase.Config{ProjectID: emulatorProjectID}, clientOption)
+		if err != nil {
+			initErr = fmt.Errorf("failed to initialize Firebase app: %w", err)
+			return
+		}
+		app = firebaseApp
+		initErr = initializeClients(ctx)
+	})
+	if initErr == nil && projectID != emulatorProjectID {
+		return fmt.Errorf("firebase already initialized for project '%s'", projectID)
+	}
+	return initErr
+}
+
 func initializeClients(ctx context.Context) error {
 	var err error
 
@@ -126,6 +165,12 @@ func initializeClients(ctx context.Context) error {
 		return fmt.Errorf("failed to create Auth client: %w", err)
 	}
 
+	// Storage: un fallo no impide usar Firestore ni Auth; se reporta al pedir un bucket
+	storageClient, err = app.Storage(ctx)
+	if err != nil {
+		storageErr = fmt.Errorf("failed to create Storage client: %w", err)
+	}
+
 	return nil
 }
 
@@ -145,6 +190,88 @@ func GetAuthClient() *auth.Client {
 	return authClient
 }
 
+// GetMessagingClient retorna el cliente de Firebase Cloud Messaging. Se crea en el
+// primer uso para que un fallo de FCM no impida inicializar Firestore y Auth.
+func GetMessagingClient() (*messaging.Client, error) {
+	if app == nil {
+		panic("Firebase app not initialized. Call InitFirebaseFromEnv first.")
+	}
+	messagingOnce.Do(func() {
+		client, err := app.Messaging(context.Background())
+		if err != nil {
+			messagingErr = fmt.Errorf("failed to create Messaging client: %w", err)
+			return
+		}
+		messagingClient = client
+	})
+	return messagingClient, messagingErr
+}
+
+// GetStorageBucketName retorna el bucket por defecto: FIREBASE_STORAGE_BUCKET, el
+// storageBucket de FIREBASE_CONFIG o <project_id>.firebasestorage.app. Los proyectos
+// anteriores a 2024 usan <project_id>.appspot.com y deben configurarlo explícitamente.
+func GetStorageBucketName() string {
+	if bucket := os.Getenv("FIREBASE_STORAGE_BUCKET"); bucket != "" {
+		return bucket
+	}
+	if bucket := configStorageBucket(); bucket != "" {
+		return bucket
+	}
+	return projectID + ".firebasestorage.app"
+}
+
+// configStorageBucket lee storageBucket de FIREBASE_CONFIG (JSON o ruta a un archivo JSON)
+func configStorageBucket() string {
+	config := strings.TrimSpace(os.Getenv("FIREBASE_CONFIG"))
+	if config == "" {
+		return ""
+	}
+	data := []byte(config)
+	if !strings.HasPrefix(config, "{") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return ""
+		}
+		data = fileData
+	}
+	var parsed struct {
+		StorageBucket string `json:"storageBucket"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return ""
+	}
+	return parsed.StorageBucket
+}
+
+// GetStorageBucket retorna el bucket de Cloud Storage por defecto
+func GetStorageBucket() (*gcs.BucketHandle, error) {
+	return GetStorageBucketByName(GetStorageBucketName())
+}
+
+// GetStorageBucketByName retorna un bucket de Cloud Storage por nombre
+func GetStorageBucketByName(name string) (*gcs.BucketHandle, error) {
+	if storageClient == nil {
+		if storageErr != nil {
+			return nil, storageErr
+		}
+		panic("Storage client not initialized. Call InitFirebaseFromEnv first.")
+	}
+	bucket, err := storageClient.Bucket(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage bucket '%s': %w", name, err)
+	}
+	return bucket, nil
+}
+
+// GetClientOptions retorna las opciones de credenciales usadas al inicializar, para crear
+// clientes de otras APIs de Google Cloud con la misma cuenta de servicio
+func GetClientOptions() []option.ClientOption {
+	if clientOption == nil {
+		return nil
+	}
+	return []option.ClientOption{clientOption}
+}
+
 // GetProjectID retorna el ID del proyecto
 func GetProjectID() string {
 	return projectID
@@ -162,5 +289,5 @@ func Close() error {
 			return fmt.Errorf("firestore close error: %w", err)
 		}
 	}
-	return nil
+	return closeRegions()
 }
\ No newline at end of file
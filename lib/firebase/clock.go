@@ -0,0 +1,79 @@
+package firebase
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock fuente de la hora actual usada por todo el paquete (expiración de OTP y sesiones,
+// TTLs, timestamps created_at/updated_at...)
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock reloj del sistema
+type SystemClock struct{}
+
+// Now retorna la hora del sistema
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+var (
+	clockMu sync.RWMutex
+	clock   Clock = SystemClock{}
+)
+
+// SetClock reemplaza el reloj del paquete (nil restaura el del sistema). Pensado para
+// pruebas: con un FakeClock se puede congelar y adelantar el tiempo en lugar de esperar.
+func SetClock(c Clock) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	if c == nil {
+		c = SystemClock{}
+	}
+	clock = c
+}
+
+// Now retorna la hora actual en UTC. Todas las horas que se guardan o se comparan pasan
+// por aquí: Firestore devuelve los timestamps en UTC y mezclarlos con horas locales
+// provoca errores de expiración entre regiones.
+func Now() time.Time {
+	clockMu.RLock()
+	c := clock
+	clockMu.RUnlock()
+	return c.Now().UTC()
+}
+
+// FakeClock reloj manual para pruebas
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock crea un reloj detenido en start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now retorna la hora fijada
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set fija la hora del reloj
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Advance adelanta el reloj d y retorna la nueva hora
+func (c *FakeClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
@@ -0,0 +1,215 @@
+// Package clone copia colecciones de un proyecto (normalmente producción) a otro (staging)
+// para obtener datos de prueba realistas, con muestreo opcional y limpieza de datos
+// personales mediante las transformaciones del paquete anonymize (ver RegisterRedaction).
+package clone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/anonymize"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Options opciones de Clone
+type Options struct {
+	Collections      []string
+	TargetProjectID  string
+	TargetDatabaseID string // por defecto "(default)"
+	// SampleSize limita los documentos copiados por colección (0 = todos)
+	SampleSize int
+	// Subcollections copia también las subcolecciones de cada documento copiado (con el
+	// mismo muestreo por subcolección)
+	Subcollections bool
+	// Overwrite reemplaza los documentos que ya existen en el destino. Por defecto se
+	// conservan, de modo que los cambios hechos en staging sobreviven a un nuevo clonado.
+	Overwrite bool
+	// DryRun recorre y redacta sin escribir en el destino
+	DryRun bool
+}
+
+// Report resultado de un clonado
+type Report struct {
+	Copied            map[string]int `json:"copied"` // ruta de la colección -> documentos
+	Skipped           int            `json:"skipped"`
+	RedactedFields    int            `json:"redacted_fields"`
+	SuppressedRecords int            `json:"suppressed_records"` // grupos menores que K (k-anonimato)
+}
+
+// cloner estado de un clonado en curso
+type cloner struct {
+	options Options
+	writer  *gfirestore.BulkWriter
+	target  *gfirestore.Client
+	jobs    []*gfirestore.BulkWriterJob
+	report  *Report
+}
+
+// Clone copia las colecciones indicadas del proyecto actual al proyecto destino, aplicando
+// las reglas de redacción a cada documento. Rechaza clonar sobre la misma base de datos.
+func Clone(ctx context.Context, options Options) (*Report, error) {
+	if len(options.Collections) == 0 {
+		return nil, fmt.Errorf("clone requires at least one collection")
+	}
+	if options.TargetProjectID == "" {
+		return nil, fmt.Errorf("clone requires a target project")
+	}
+	if options.TargetDatabaseID == "" {
+		options.TargetDatabaseID = gfirestore.DefaultDatabaseID
+	}
+	if options.TargetProjectID == firebase.GetProjectID() && options.TargetDatabaseID == gfirestore.DefaultDatabaseID {
+		return nil, fmt.Errorf("refusing to clone into the source database")
+	}
+
+	c := &cloner{options: options, report: &Report{Copied: map[string]int{}}}
+	if !options.DryRun {
+		target, err := gfirestore.NewClientWithDatabase(ctx, options.TargetProjectID, options.TargetDatabaseID, firebase.GetClientOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create target Firestore client: %w", err)
+		}
+		defer target.Close()
+		c.target = target
+		c.writer = target.BulkWriter(ctx)
+	}
+
+	for _, collection := range options.Collections {
+		source := firebase.FirestoreClientFor(ctx, collection).Collection(collection)
+		if err := c.copyCollection(ctx, source, collection); err != nil {
+			if c.writer != nil {
+				c.writer.End()
+			}
+			return c.report, err
+		}
+	}
+	if c.writer == nil {
+		return c.report, nil
+	}
+
+	c.writer.End()
+	for _, job := range c.jobs {
+		if _, err := job.Results(); err != nil {
+			if firestore.IsAlreadyExists(err) {
+				c.report.Skipped++
+				continue
+			}
+			return c.report, fmt.Errorf("failed to write cloned document: %w", err)
+		}
+	}
+	return c.report, nil
+}
+
+// copyCollection copia los documentos (muestreados) de una colección; root es la colección
+// de primer nivel cuyas reglas de redacción aplican. Si root exige k-anonimato, sus
+// documentos (no los de las subcolecciones) se redactan juntos antes de escribirlos.
+func (c *cloner) copyCollection(ctx context.Context, source *gfirestore.CollectionRef, root string) error {
+	query := source.Query
+	if c.options.SampleSize > 0 {
+		query = query.Limit(c.options.SampleSize)
+	}
+
+	grouped := anonymize.HasKAnonymity(root) && relativePath(source.Path) == root
+	var snaps []*gfirestore.DocumentSnapshot
+	var pending []map[string]interface{}
+
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read collection '%s': %w", source.Path, err)
+		}
+		if grouped {
+			snaps = append(snaps, snap)
+			pending = append(pending, snap.Data())
+			continue
+		}
+
+		data, redacted := anonymize.Apply(root, snap.Data())
+		c.report.RedactedFields += redacted
+		if err := c.copyDocument(ctx, source, snap, data, root); err != nil {
+			return err
+		}
+	}
+	if !grouped {
+		return nil
+	}
+
+	docs, report := anonymize.ApplyAll(root, pending)
+	c.report.RedactedFields += report.TransformedFields
+	c.report.SuppressedRecords += report.SuppressedRecords
+	c.report.Skipped += report.DroppedRecords
+	for i, data := range docs {
+		if data == nil {
+			continue
+		}
+		if err := c.copyDocument(ctx, source, snaps[i], data, root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyDocument escribe un documento ya redactado y, si corresponde, sus subcolecciones
+func (c *cloner) copyDocument(ctx context.Context, source *gfirestore.CollectionRef, snap *gfirestore.DocumentSnapshot, data map[string]interface{}, root string) error {
+	if err := c.write(relativePath(snap.Ref.Path), data); err != nil {
+		return err
+	}
+	c.report.Copied[relativePath(source.Path)]++
+
+	if c.options.Subcollections {
+		return c.copySubcollections(ctx, snap.Ref, root)
+	}
+	return nil
+}
+
+func (c *cloner) copySubcollections(ctx context.Context, ref *gfirestore.DocumentRef, root string) error {
+	collections := ref.Collections(ctx)
+	for {
+		child, err := collections.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list subcollections of '%s': %w", ref.Path, err)
+		}
+		if err := c.copyCollection(ctx, child, root); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *cloner) write(path string, data map[string]interface{}) error {
+	if c.writer == nil {
+		return nil
+	}
+
+	ref := c.target.Doc(path)
+	var job *gfirestore.BulkWriterJob
+	var err error
+	if c.options.Overwrite {
+		job, err = c.writer.Set(ref, data)
+	} else {
+		job, err = c.writer.Create(ref, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enqueue cloned document '%s': %w", path, err)
+	}
+	c.jobs = append(c.jobs, job)
+	return nil
+}
+
+// relativePath quita el prefijo "projects/<p>/databases/<d>/documents/" de una ruta
+func relativePath(path string) string {
+	if i := strings.Index(path, "/documents/"); i >= 0 {
+		return path[i+len("/documents/"):]
+	}
+	return path
+}
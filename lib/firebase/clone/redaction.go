@@ -0,0 +1,46 @@
+package clone
+
+import "github.com/andrescris/firestore/lib/firebase/anonymize"
+
+// Rule transforma el valor de un campo al clonar; ok=false elimina el campo de la copia.
+// Es una anonymize.Transform: cualquier transformación de ese paquete sirve como regla.
+type Rule = anonymize.Transform
+
+// RegisterRedaction registra la regla de un campo (se admiten rutas con puntos, p. ej.
+// "profile.phone"). collection "*" aplica a todas las colecciones; una regla específica
+// de la colección tiene prioridad. Equivale a anonymize.Register, por lo que las reglas
+// también aplican a las exportaciones.
+func RegisterRedaction(collection, field string, rule Rule) {
+	anonymize.Register(collection, field, rule)
+}
+
+// ClearRedactions elimina todas las reglas registradas
+func ClearRedactions() {
+	anonymize.Clear()
+}
+
+// RedactDrop elimina el campo
+func RedactDrop(value interface{}) (interface{}, bool) {
+	return anonymize.Drop(value)
+}
+
+// RedactHash reemplaza el valor por un hash estable: el mismo valor produce el mismo hash
+// en todas las colecciones, por lo que las referencias entre documentos se conservan
+func RedactHash(value interface{}) (interface{}, bool) {
+	return anonymize.Hash(value)
+}
+
+// RedactEmail reemplaza un email por uno ficticio y estable (user-<hash>@example.com)
+func RedactEmail(value interface{}) (interface{}, bool) {
+	return anonymize.Email(value)
+}
+
+// RedactMask conserva los últimos 4 caracteres y reemplaza el resto por '*'
+func RedactMask(value interface{}) (interface{}, bool) {
+	return anonymize.Mask(value)
+}
+
+// RedactConstant retorna una regla que reemplaza el valor por uno fijo
+func RedactConstant(replacement interface{}) Rule {
+	return anonymize.Constant(replacement)
+}
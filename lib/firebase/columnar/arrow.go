@@ -0,0 +1,224 @@
+package columnar
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ArrowOptions opciones de WriteArrow
+type ArrowOptions struct {
+	BatchSize int // filas por record batch (por defecto 65536)
+}
+
+// Constantes del formato Arrow (Schema.fbs y Message.fbs)
+const (
+	arrowMetadataV5 = 4
+
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeBinary        = 4
+	arrowTypeUtf8          = 5
+	arrowTypeBool          = 6
+	arrowTypeTimestamp     = 10
+
+	arrowPrecisionDouble = 2
+	arrowMicrosecond     = 2
+)
+
+var arrowMagic = []byte("ARROW1")
+
+// arrowBlock posición de un mensaje en el archivo (struct Block del pie)
+type arrowBlock struct {
+	offset     int64
+	metaLength int32
+	bodyLength int64
+}
+
+// WriteArrow escribe la tabla en el formato de archivo IPC de Arrow (Feather v2) sin
+// compresión
+func WriteArrow(w io.Writer, table *Table, options ArrowOptions) error {
+	if options.BatchSize <= 0 {
+		options.BatchSize = 65536
+	}
+	out := &countingWriter{w: w}
+	if _, err := out.Write(append(append([]byte{}, arrowMagic...), 0, 0)); err != nil {
+		return fmt.Errorf("failed to write arrow: %w", err)
+	}
+
+	schema := arrowSchema(table)
+	message := (&fbTable{}).i16(0, arrowMetadataV5).u8(1, arrowHeaderSchema).object(2, schema).i64(3, 0)
+	if _, err := writeArrowMessage(out, finishFlatbuffer(message), nil); err != nil {
+		return err
+	}
+
+	var blocks []arrowBlock
+	for start := 0; start < table.Rows; start += options.BatchSize {
+		end := start + options.BatchSize
+		if end > table.Rows {
+			end = table.Rows
+		}
+		batch, body := arrowRecordBatch(table, start, end)
+		message := (&fbTable{}).i16(0, arrowMetadataV5).u8(1, arrowHeaderRecordBatch).object(2, batch).i64(3, int64(len(body)))
+		block, err := writeArrowMessage(out, finishFlatbuffer(message), body)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, block)
+	}
+
+	// Fin del stream
+	if _, err := out.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("failed to write arrow: %w", err)
+	}
+
+	data := make([]byte, 0, 24*len(blocks))
+	for _, block := range blocks {
+		data = binary.LittleEndian.AppendUint64(data, uint64(block.offset))
+		data = binary.LittleEndian.AppendUint32(data, uint32(block.metaLength))
+		data = append(data, 0, 0, 0, 0)
+		data = binary.LittleEndian.AppendUint64(data, uint64(block.bodyLength))
+	}
+	footer := finishFlatbuffer((&fbTable{}).
+		i16(0, arrowMetadataV5).
+		object(1, arrowSchema(table)).
+		object(2, fbStructs{align: 8}).
+		object(3, fbStructs{align: 8, count: len(blocks), data: data}))
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	for _, part := range [][]byte{footer, length[:], arrowMagic} {
+		if _, err := out.Write(part); err != nil {
+			return fmt.Errorf("failed to write arrow: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeArrowMessage escribe un mensaje encapsulado: marca de continuación, largo de los
+// metadatos, metadatos y cuerpo
+func writeArrowMessage(out *countingWriter, metadata, body []byte) (arrowBlock, error) {
+	block := arrowBlock{offset: out.n, metaLength: int32(8 + len(metadata)), bodyLength: int64(len(body))}
+	prefix := []byte{0xFF, 0xFF, 0xFF, 0xFF}
+	prefix = binary.LittleEndian.AppendUint32(prefix, uint32(len(metadata)))
+	for _, part := range [][]byte{prefix, metadata, body} {
+		if _, err := out.Write(part); err != nil {
+			return block, fmt.Errorf("failed to write arrow: %w", err)
+		}
+	}
+	return block, nil
+}
+
+func arrowSchema(table *Table) *fbTable {
+	fields := make(fbVector, len(table.Columns))
+	for i, column := range table.Columns {
+		var kind uint8
+		typ := &fbTable{}
+		switch column.Type {
+		case TypeBool:
+			kind = arrowTypeBool
+		case TypeInt64:
+			kind = arrowTypeInt
+			typ.i32(0, 64).boolean(1, true)
+		case TypeDouble:
+			kind = arrowTypeFloatingPoint
+			typ.i16(0, arrowPrecisionDouble)
+		case TypeTimestamp:
+			kind = arrowTypeTimestamp
+			typ.i16(0, arrowMicrosecond).object(1, fbString("UTC"))
+		case TypeBinary:
+			kind = arrowTypeBinary
+		default:
+			kind = arrowTypeUtf8
+		}
+		fields[i] = (&fbTable{}).
+			object(0, fbString(column.Name)).
+			boolean(1, true).
+			u8(2, kind).
+			object(3, typ).
+			object(5, fbVector{})
+	}
+	return (&fbTable{}).i16(0, 0).object(1, fields)
+}
+
+// arrowRecordBatch construye los metadatos y el cuerpo de las filas [start, end). Cada
+// columna aporta su bitmap de validez y sus valores (y offsets para textos y binarios),
+// cada buffer alineado a 8 bytes.
+func arrowRecordBatch(table *Table, start, end int) (*fbTable, []byte) {
+	rows := end - start
+	var body, nodes, buffers []byte
+	addBuffer := func(data []byte) {
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(body)))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(data)))
+		body = append(body, data...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	for _, column := range table.Columns {
+		values := column.Values[start:end]
+		validity := make([]byte, (rows+7)/8)
+		nulls := 0
+		for i, value := range values {
+			if value == nil {
+				nulls++
+			} else {
+				validity[i/8] |= 1 << (i % 8)
+			}
+		}
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(rows))
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(nulls))
+		addBuffer(validity)
+
+		switch column.Type {
+		case TypeBool:
+			bits := make([]byte, (rows+7)/8)
+			for i, value := range values {
+				if v, _ := value.(bool); v {
+					bits[i/8] |= 1 << (i % 8)
+				}
+			}
+			addBuffer(bits)
+		case TypeInt64, TypeDouble, TypeTimestamp:
+			data := make([]byte, 8*rows)
+			for i, value := range values {
+				var raw uint64
+				switch v := value.(type) {
+				case int64:
+					raw = uint64(v)
+				case float64:
+					raw = math.Float64bits(v)
+				case time.Time:
+					raw = uint64(v.UnixMicro())
+				}
+				binary.LittleEndian.PutUint64(data[8*i:], raw)
+			}
+			addBuffer(data)
+		default:
+			offsets := make([]byte, 4*(rows+1))
+			var data []byte
+			for i, value := range values {
+				switch v := value.(type) {
+				case string:
+					data = append(data, v...)
+				case []byte:
+					data = append(data, v...)
+				}
+				binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+			}
+			addBuffer(offsets)
+			addBuffer(data)
+		}
+	}
+
+	batch := (&fbTable{}).
+		i64(0, int64(rows)).
+		object(1, fbStructs{align: 8, count: len(table.Columns), data: nodes}).
+		object(2, fbStructs{align: 8, count: len(buffers) / 16, data: buffers})
+	return batch, body
+}
@@ -0,0 +1,282 @@
+package columnar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// fbTab lector mínimo de una tabla FlatBuffers en buf
+type fbTab struct {
+	buf []byte
+	pos int
+}
+
+func fbRoot(buf []byte) fbTab {
+	return fbTab{buf: buf, pos: int(binary.LittleEndian.Uint32(buf))}
+}
+
+// offset posición absoluta del campo slot, o 0 si no está presente
+func (t fbTab) offset(slot int) int {
+	vtable := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	if 4+2*slot >= int(binary.LittleEndian.Uint16(t.buf[vtable:])) {
+		return 0
+	}
+	if off := int(binary.LittleEndian.Uint16(t.buf[vtable+4+2*slot:])); off != 0 {
+		return t.pos + off
+	}
+	return 0
+}
+
+func (t fbTab) u8(slot int) uint8 {
+	if at := t.offset(slot); at != 0 {
+		return t.buf[at]
+	}
+	return 0
+}
+
+func (t fbTab) i16(slot int) int16 {
+	if at := t.offset(slot); at != 0 {
+		return int16(binary.LittleEndian.Uint16(t.buf[at:]))
+	}
+	return 0
+}
+
+func (t fbTab) i32(slot int) int32 {
+	if at := t.offset(slot); at != 0 {
+		return int32(binary.LittleEndian.Uint32(t.buf[at:]))
+	}
+	return 0
+}
+
+func (t fbTab) i64(slot int) int64 {
+	if at := t.offset(slot); at != 0 {
+		return int64(binary.LittleEndian.Uint64(t.buf[at:]))
+	}
+	return 0
+}
+
+// ref posición del objeto referenciado por el campo slot
+func (t fbTab) ref(slot int) int {
+	at := t.offset(slot)
+	if at == 0 {
+		return 0
+	}
+	return at + int(binary.LittleEndian.Uint32(t.buf[at:]))
+}
+
+func (t fbTab) table(slot int) fbTab {
+	return fbTab{buf: t.buf, pos: t.ref(slot)}
+}
+
+func (t fbTab) str(slot int) string {
+	at := t.ref(slot)
+	n := int(binary.LittleEndian.Uint32(t.buf[at:]))
+	if t.buf[at+4+n] != 0 {
+		panic("string without terminator")
+	}
+	return string(t.buf[at+4 : at+4+n])
+}
+
+// tables elementos de un vector de tablas
+func (t fbTab) tables(slot int) []fbTab {
+	at := t.ref(slot)
+	out := make([]fbTab, binary.LittleEndian.Uint32(t.buf[at:]))
+	for i := range out {
+		item := at + 4 + 4*i
+		out[i] = fbTab{buf: t.buf, pos: item + int(binary.LittleEndian.Uint32(t.buf[item:]))}
+	}
+	return out
+}
+
+// structs elementos de un vector de structs de size bytes
+func (t fbTab) structs(slot, size int) [][]byte {
+	at := t.ref(slot)
+	out := make([][]byte, binary.LittleEndian.Uint32(t.buf[at:]))
+	for i := range out {
+		out[i] = t.buf[at+4+size*i : at+4+size*(i+1)]
+	}
+	return out
+}
+
+type arrowField struct {
+	name string
+	kind uint8
+}
+
+// readArrowSchema lee los campos de un Schema y valida sus tipos
+func readArrowSchema(t *testing.T, schema fbTab) []arrowField {
+	t.Helper()
+	var fields []arrowField
+	for _, field := range schema.tables(1) {
+		f := arrowField{name: field.str(0), kind: field.u8(2)}
+		if field.u8(1) != 1 {
+			t.Errorf("field %q is not nullable", f.name)
+		}
+		typ := field.table(3)
+		switch f.kind {
+		case arrowTypeInt:
+			if typ.i32(0) != 64 || typ.u8(1) != 1 {
+				t.Errorf("field %q: int%d signed=%d", f.name, typ.i32(0), typ.u8(1))
+			}
+		case arrowTypeFloatingPoint:
+			if typ.i16(0) != arrowPrecisionDouble {
+				t.Errorf("field %q: precision %d", f.name, typ.i16(0))
+			}
+		case arrowTypeTimestamp:
+			if typ.i16(0) != arrowMicrosecond || typ.str(1) != "UTC" {
+				t.Errorf("field %q: unit %d timezone %q", f.name, typ.i16(0), typ.str(1))
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// readArrowMessage lee el mensaje encapsulado en offset y devuelve su Message y su cuerpo
+func readArrowMessage(t *testing.T, file []byte, offset int) (fbTab, []byte, int) {
+	t.Helper()
+	if binary.LittleEndian.Uint32(file[offset:]) != 0xFFFFFFFF {
+		t.Fatalf("missing continuation marker at %d", offset)
+	}
+	length := int(binary.LittleEndian.Uint32(file[offset+4:]))
+	if (8+length)%8 != 0 {
+		t.Errorf("metadata at %d is not padded to 8 bytes", offset)
+	}
+	message := fbRoot(file[offset+8 : offset+8+length])
+	if message.i16(0) != arrowMetadataV5 {
+		t.Errorf("message version %d", message.i16(0))
+	}
+	start := offset + 8 + length
+	bodyLength := int(message.i64(3))
+	return message, file[start : start+bodyLength], start + bodyLength
+}
+
+// readArrow lee un archivo escrito por WriteArrow a través del pie y devuelve el número
+// de record batches y los valores de cada columna
+func readArrow(t *testing.T, file []byte) (int, map[string][]interface{}) {
+	t.Helper()
+	n := len(file)
+	if !bytes.Equal(file[:8], append(append([]byte{}, arrowMagic...), 0, 0)) || !bytes.Equal(file[n-6:], arrowMagic) {
+		t.Fatal("missing ARROW1 magic")
+	}
+
+	message, _, next := readArrowMessage(t, file, 8)
+	if message.u8(1) != arrowHeaderSchema {
+		t.Fatalf("first message has header type %d", message.u8(1))
+	}
+	fields := readArrowSchema(t, message.table(2))
+
+	footerLen := int(binary.LittleEndian.Uint32(file[n-10:]))
+	footer := fbRoot(file[n-10-footerLen : n-10])
+	if len(readArrowSchema(t, footer.table(1))) != len(fields) {
+		t.Error("footer schema differs from the stream schema")
+	}
+
+	values := map[string][]interface{}{}
+	blocks := footer.structs(3, 24)
+	for _, block := range blocks {
+		offset := int(binary.LittleEndian.Uint64(block))
+		if offset != next {
+			t.Errorf("block at %d, previous message ended at %d", offset, next)
+		}
+		message, body, end := readArrowMessage(t, file, offset)
+		next = end
+		if metaLength := int(binary.LittleEndian.Uint32(block[8:])); offset+metaLength+len(body) != end {
+			t.Errorf("block at %d: metadata length %d", offset, metaLength)
+		}
+		if bodyLength := int(binary.LittleEndian.Uint64(block[16:])); bodyLength != len(body) {
+			t.Errorf("block at %d: body length %d, want %d", offset, bodyLength, len(body))
+		}
+		if message.u8(1) != arrowHeaderRecordBatch {
+			t.Fatalf("message at %d has header type %d", offset, message.u8(1))
+		}
+		batch := message.table(2)
+		rows := int(batch.i64(0))
+		nodes := batch.structs(1, 16)
+		var buffers [][]byte
+		for _, spec := range batch.structs(2, 16) {
+			start := int(binary.LittleEndian.Uint64(spec))
+			if start%8 != 0 {
+				t.Errorf("buffer at %d is not aligned to 8 bytes", start)
+			}
+			buffers = append(buffers, body[start:start+int(binary.LittleEndian.Uint64(spec[8:]))])
+		}
+		if len(nodes) != len(fields) {
+			t.Fatalf("got %d nodes for %d fields", len(nodes), len(fields))
+		}
+		for i, field := range fields {
+			if int(binary.LittleEndian.Uint64(nodes[i])) != rows {
+				t.Errorf("field %q: node length %d, want %d", field.name, binary.LittleEndian.Uint64(nodes[i]), rows)
+			}
+			validity := buffers[0]
+			column := make([]interface{}, rows)
+			nulls := 0
+			for row := range column {
+				if validity[row/8]&(1<<(row%8)) == 0 {
+					nulls++
+					continue
+				}
+				switch field.kind {
+				case arrowTypeBool:
+					column[row] = buffers[1][row/8]&(1<<(row%8)) != 0
+				case arrowTypeInt:
+					column[row] = int64(binary.LittleEndian.Uint64(buffers[1][8*row:]))
+				case arrowTypeFloatingPoint:
+					column[row] = math.Float64frombits(binary.LittleEndian.Uint64(buffers[1][8*row:]))
+				case arrowTypeTimestamp:
+					column[row] = time.UnixMicro(int64(binary.LittleEndian.Uint64(buffers[1][8*row:]))).UTC()
+				default:
+					from := binary.LittleEndian.Uint32(buffers[1][4*row:])
+					to := binary.LittleEndian.Uint32(buffers[1][4*(row+1):])
+					raw := append([]byte{}, buffers[2][from:to]...)
+					if field.kind == arrowTypeUtf8 {
+						column[row] = string(raw)
+					} else {
+						column[row] = raw
+					}
+				}
+			}
+			if int(binary.LittleEndian.Uint64(nodes[i][8:])) != nulls {
+				t.Errorf("field %q: null count %d, want %d", field.name, binary.LittleEndian.Uint64(nodes[i][8:]), nulls)
+			}
+			values[field.name] = append(values[field.name], column...)
+			switch field.kind {
+			case arrowTypeUtf8, arrowTypeBinary:
+				buffers = buffers[3:]
+			default:
+				buffers = buffers[2:]
+			}
+		}
+	}
+	if !bytes.Equal(file[next:next+8], []byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}) {
+		t.Error("missing end-of-stream marker")
+	}
+	return len(blocks), values
+}
+
+func TestWriteArrowRoundTrip(t *testing.T) {
+	table := sampleTable()
+	var buf bytes.Buffer
+	if err := WriteArrow(&buf, table, ArrowOptions{BatchSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	batches, values := readArrow(t, buf.Bytes())
+	if batches != 3 {
+		t.Errorf("got %d record batches, want 3", batches)
+	}
+	checkColumns(t, table, values)
+}
+
+func TestWriteArrowEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteArrow(&buf, Infer(nil, nil), ArrowOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	batches, values := readArrow(t, buf.Bytes())
+	if batches != 0 || len(values) != 0 {
+		t.Errorf("got %d batches and %d columns, want none", batches, len(values))
+	}
+}
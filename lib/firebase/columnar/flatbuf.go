@@ -0,0 +1,171 @@
+package columnar
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Constructor mínimo de FlatBuffers (usado por los metadatos de Arrow). A diferencia del
+// constructor oficial escribe hacia adelante: cada objeto se escribe antes que los objetos
+// a los que referencia, de modo que todas las referencias (uoffset) apuntan hacia
+// adelante como exige el formato.
+
+// fbTable tabla con sus campos por número de slot
+type fbTable struct {
+	fields []fbField
+}
+
+type fbField struct {
+	slot   int
+	size   int    // 1, 2, 4 u 8 bytes
+	scalar []byte // valor escalar o struct en línea
+	ref    interface{}
+}
+
+// fbString texto
+type fbString string
+
+// fbVector vector de referencias (tablas o textos)
+type fbVector []interface{}
+
+// fbStructs vector de structs de elemSize bytes alineados a align
+type fbStructs struct {
+	align int
+	count int
+	data  []byte
+}
+
+func (t *fbTable) scalar(slot int, value []byte) *fbTable {
+	t.fields = append(t.fields, fbField{slot: slot, size: len(value), scalar: value})
+	return t
+}
+
+func (t *fbTable) u8(slot int, value uint8) *fbTable {
+	return t.scalar(slot, []byte{value})
+}
+
+func (t *fbTable) boolean(slot int, value bool) *fbTable {
+	if value {
+		return t.u8(slot, 1)
+	}
+	return t.u8(slot, 0)
+}
+
+func (t *fbTable) i16(slot int, value int16) *fbTable {
+	return t.scalar(slot, binary.LittleEndian.AppendUint16(nil, uint16(value)))
+}
+
+func (t *fbTable) i32(slot int, value int32) *fbTable {
+	return t.scalar(slot, binary.LittleEndian.AppendUint32(nil, uint32(value)))
+}
+
+func (t *fbTable) i64(slot int, value int64) *fbTable {
+	return t.scalar(slot, binary.LittleEndian.AppendUint64(nil, uint64(value)))
+}
+
+// object agrega una referencia a una tabla, texto o vector
+func (t *fbTable) object(slot int, value interface{}) *fbTable {
+	t.fields = append(t.fields, fbField{slot: slot, size: 4, ref: value})
+	return t
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+// finishFlatbuffer serializa root y rellena hasta un múltiplo de 8 bytes
+func finishFlatbuffer(root *fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	pos := b.write(root)
+	binary.LittleEndian.PutUint32(b.buf[0:], uint32(pos))
+	b.pad(8)
+	return b.buf
+}
+
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) write(value interface{}) int {
+	switch v := value.(type) {
+	case *fbTable:
+		return b.writeTable(v)
+	case fbString:
+		b.pad(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+		b.buf = append(b.buf, v...)
+		b.buf = append(b.buf, 0)
+		return pos
+	case fbVector:
+		b.pad(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+		b.buf = append(b.buf, make([]byte, 4*len(v))...)
+		for i, item := range v {
+			at := pos + 4 + 4*i
+			child := b.write(item)
+			binary.LittleEndian.PutUint32(b.buf[at:], uint32(child-at))
+		}
+		return pos
+	case fbStructs:
+		// El largo (4 bytes) va justo antes del primer elemento, que debe quedar alineado
+		b.pad(4)
+		for (len(b.buf)+4)%v.align != 0 {
+			b.buf = append(b.buf, 0)
+		}
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v.count))
+		b.buf = append(b.buf, v.data...)
+		return pos
+	}
+	panic("columnar: unsupported flatbuffer value")
+}
+
+// writeTable escribe la vtable seguida de la tabla (alineada a 8) y luego los objetos
+// referenciados
+func (b *fbBuilder) writeTable(t *fbTable) int {
+	fields := append([]fbField(nil), t.fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].size > fields[j].size })
+
+	offsets := map[int]int{}
+	size, slots := 4, 0
+	for _, field := range fields {
+		for size%field.size != 0 {
+			size++
+		}
+		offsets[field.slot] = size
+		size += field.size
+		if field.slot+1 > slots {
+			slots = field.slot + 1
+		}
+	}
+
+	b.pad(2)
+	vtable := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*slots))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+	for slot := 0; slot < slots; slot++ {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(offsets[slot]))
+	}
+
+	b.pad(8)
+	table := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[table:], uint32(int32(table-vtable)))
+	for _, field := range fields {
+		if field.ref == nil {
+			copy(b.buf[table+offsets[field.slot]:], field.scalar)
+		}
+	}
+	for _, field := range fields {
+		if field.ref != nil {
+			at := table + offsets[field.slot]
+			child := b.write(field.ref)
+			binary.LittleEndian.PutUint32(b.buf[at:], uint32(child-at))
+		}
+	}
+	return table
+}
@@ -0,0 +1,318 @@
+package columnar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// Compression códec de las páginas Parquet
+type Compression int
+
+const (
+	CompressionSnappy Compression = iota // por defecto, como pandas y DuckDB
+	CompressionGzip
+	CompressionNone
+)
+
+// ParquetOptions opciones de WriteParquet
+type ParquetOptions struct {
+	Compression  Compression
+	RowGroupSize int // filas por grupo (por defecto 65536)
+}
+
+// Constantes del formato Parquet (parquet.thrift)
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional = 1
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMicros = 10
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecUncompressed = 0
+	parquetCodecSnappy       = 1
+	parquetCodecGzip         = 2
+
+	parquetDataPage = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// WriteParquet escribe la tabla como archivo Parquet: todas las columnas opcionales, una
+// página PLAIN por columna y grupo de filas
+func WriteParquet(w io.Writer, table *Table, options ParquetOptions) error {
+	if options.RowGroupSize <= 0 {
+		options.RowGroupSize = 65536
+	}
+	out := &countingWriter{w: w}
+	if _, err := out.Write(parquetMagic); err != nil {
+		return fmt.Errorf("failed to write parquet: %w", err)
+	}
+
+	type chunkMeta struct {
+		offset       int64
+		uncompressed int64
+		compressed   int64
+		values       int64
+	}
+	var rowGroups [][]chunkMeta
+	var groupRows []int
+	for start := 0; start < table.Rows; start += options.RowGroupSize {
+		end := start + options.RowGroupSize
+		if end > table.Rows {
+			end = table.Rows
+		}
+		var chunks []chunkMeta
+		for _, column := range table.Columns {
+			page, err := encodePage(column, start, end, options.Compression)
+			if err != nil {
+				return err
+			}
+			meta := chunkMeta{offset: out.n, values: int64(end - start)}
+			if _, err := out.Write(page.header); err != nil {
+				return fmt.Errorf("failed to write parquet: %w", err)
+			}
+			if _, err := out.Write(page.body); err != nil {
+				return fmt.Errorf("failed to write parquet: %w", err)
+			}
+			meta.uncompressed = int64(len(page.header) + page.uncompressed)
+			meta.compressed = int64(len(page.header) + len(page.body))
+			chunks = append(chunks, meta)
+		}
+		rowGroups = append(rowGroups, chunks)
+		groupRows = append(groupRows, end-start)
+	}
+
+	// FileMetaData
+	t := &thriftWriter{}
+	t.beginStruct()
+	t.i32Field(1, 1)
+	t.listField(2, thriftStruct, len(table.Columns)+1)
+	t.beginStruct()
+	t.stringField(4, "schema")
+	t.i32Field(5, int32(len(table.Columns)))
+	t.endStruct()
+	for _, column := range table.Columns {
+		writeSchemaElement(t, column)
+	}
+	t.i64Field(3, int64(table.Rows))
+	t.listField(4, thriftStruct, len(rowGroups))
+	for g, chunks := range rowGroups {
+		t.beginStruct()
+		t.listField(1, thriftStruct, len(chunks))
+		var total int64
+		for c, chunk := range chunks {
+			column := table.Columns[c]
+			total += chunk.uncompressed
+			t.beginStruct()
+			t.i64Field(2, chunk.offset)
+			t.structField(3)
+			t.i32Field(1, physicalType(column.Type))
+			t.listField(2, thriftI32, 2)
+			t.i32Element(parquetEncodingPlain)
+			t.i32Element(parquetEncodingRLE)
+			t.listField(3, thriftBinary, 1)
+			t.stringElement(column.Name)
+			t.i32Field(4, codec(options.Compression))
+			t.i64Field(5, chunk.values)
+			t.i64Field(6, chunk.uncompressed)
+			t.i64Field(7, chunk.compressed)
+			t.i64Field(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64Field(2, total)
+		t.i64Field(3, int64(groupRows[g]))
+		t.endStruct()
+	}
+	t.stringField(6, "github.com/andrescris/firestore columnar")
+	t.endStruct()
+
+	footer := t.buf.Bytes()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	for _, part := range [][]byte{footer, length[:], parquetMagic} {
+		if _, err := out.Write(part); err != nil {
+			return fmt.Errorf("failed to write parquet: %w", err)
+		}
+	}
+	return nil
+}
+
+func writeSchemaElement(t *thriftWriter, column *Column) {
+	t.beginStruct()
+	t.i32Field(1, physicalType(column.Type))
+	t.i32Field(3, parquetOptional)
+	t.stringField(4, column.Name)
+	switch column.Type {
+	case TypeString:
+		t.i32Field(6, parquetConvertedUTF8)
+		t.structField(10) // LogicalType
+		t.structField(1)  // STRING
+		t.endStruct()
+		t.endStruct()
+	case TypeTimestamp:
+		t.i32Field(6, parquetConvertedTimestampMicros)
+		t.structField(10) // LogicalType
+		t.structField(8)  // TIMESTAMP
+		t.boolField(1, true)
+		t.structField(2) // TimeUnit
+		t.structField(2) // MICROS
+		t.endStruct()
+		t.endStruct()
+		t.endStruct()
+		t.endStruct()
+	}
+	t.endStruct()
+}
+
+func physicalType(kind Type) int32 {
+	switch kind {
+	case TypeBool:
+		return parquetBoolean
+	case TypeInt64, TypeTimestamp:
+		return parquetInt64
+	case TypeDouble:
+		return parquetDouble
+	}
+	return parquetByteArray
+}
+
+func codec(compression Compression) int32 {
+	switch compression {
+	case CompressionGzip:
+		return parquetCodecGzip
+	case CompressionNone:
+		return parquetCodecUncompressed
+	}
+	return parquetCodecSnappy
+}
+
+type encodedPage struct {
+	header       []byte
+	body         []byte
+	uncompressed int
+}
+
+// encodePage codifica las filas [start, end) de la columna como página de datos v1:
+// niveles de definición RLE (con su longitud) seguidos de los valores no nulos en PLAIN
+func encodePage(column *Column, start, end int, compression Compression) (*encodedPage, error) {
+	var levels, values bytes.Buffer
+	var bits []bool
+	defined := make([]bool, 0, end-start)
+	for _, value := range column.Values[start:end] {
+		defined = append(defined, value != nil)
+		if value == nil {
+			continue
+		}
+		switch v := value.(type) {
+		case bool:
+			bits = append(bits, v)
+		case int64:
+			binary.Write(&values, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&values, binary.LittleEndian, math.Float64bits(v))
+		case time.Time:
+			binary.Write(&values, binary.LittleEndian, v.UnixMicro())
+		case string:
+			binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+			values.WriteString(v)
+		case []byte:
+			binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+			values.Write(v)
+		default:
+			return nil, fmt.Errorf("column '%s' has an unsupported value %T", column.Name, value)
+		}
+	}
+	if column.Type == TypeBool {
+		values.Write(packBits(bits))
+	}
+	writeLevels(&levels, defined)
+
+	body := make([]byte, 0, 4+levels.Len()+values.Len())
+	body = binary.LittleEndian.AppendUint32(body, uint32(levels.Len()))
+	body = append(body, levels.Bytes()...)
+	body = append(body, values.Bytes()...)
+	uncompressed := len(body)
+
+	switch compression {
+	case CompressionSnappy:
+		body = snappy.Encode(nil, body)
+	case CompressionGzip:
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write(body)
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress parquet page: %w", err)
+		}
+		body = compressed.Bytes()
+	}
+
+	t := &thriftWriter{}
+	t.beginStruct()
+	t.i32Field(1, parquetDataPage)
+	t.i32Field(2, int32(uncompressed))
+	t.i32Field(3, int32(len(body)))
+	t.structField(5)
+	t.i32Field(1, int32(end-start))
+	t.i32Field(2, parquetEncodingPlain)
+	t.i32Field(3, parquetEncodingRLE)
+	t.i32Field(4, parquetEncodingRLE)
+	t.endStruct()
+	t.endStruct()
+	return &encodedPage{header: t.buf.Bytes(), body: body, uncompressed: uncompressed}, nil
+}
+
+// writeLevels codifica los niveles de definición (ancho 1 bit) como tramos RLE
+func writeLevels(buf *bytes.Buffer, defined []bool) {
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		var tmp [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(tmp[:], uint64(j-i)<<1)
+		buf.Write(tmp[:n])
+		if defined[i] {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		i = j
+	}
+}
+
+// packBits empaqueta booleanos de a 8 por byte, bit menos significativo primero
+func packBits(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
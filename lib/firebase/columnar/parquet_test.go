@@ -0,0 +1,311 @@
+package columnar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// thriftReader lector mínimo del protocolo compacto: los structs se leen como mapas de
+// ID de campo a valor (bool, int64, []byte, []interface{} o map[int16]interface{})
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	value, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		panic("bad varint")
+	}
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) zigzag() int64 {
+	value := r.uvarint()
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (r *thriftReader) value(kind byte) interface{} {
+	switch kind {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return r.buf[r.pos-n : r.pos]
+	case thriftList:
+		header := r.byte()
+		size, elem := int(header>>4), header&0x0F
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		items := make([]interface{}, size)
+		for i := range items {
+			items[i] = r.value(elem)
+		}
+		return items
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", kind))
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		kind := header & 0x0F
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(kind)
+		last = id
+	}
+}
+
+func TestThriftRoundTrip(t *testing.T) {
+	w := &thriftWriter{}
+	w.beginStruct()
+	w.i32Field(1, -7)
+	w.boolField(2, true)
+	w.boolField(40, false) // delta > 15: cabecera larga
+	w.i64Field(41, math.MinInt64)
+	w.listField(42, thriftI32, 20) // 15 o más elementos: tamaño aparte
+	for i := 0; i < 20; i++ {
+		w.i32Element(int32(i * 1000))
+	}
+	w.structField(43)
+	w.stringField(1, "ñ")
+	w.endStruct()
+	w.endStruct()
+
+	r := &thriftReader{buf: w.buf.Bytes()}
+	got := r.readStruct()
+	if r.pos != len(r.buf) {
+		t.Fatalf("read %d of %d bytes", r.pos, len(r.buf))
+	}
+	if got[1] != int64(-7) || got[2] != true || got[40] != false || got[41] != int64(math.MinInt64) {
+		t.Errorf("scalars: %#v", got)
+	}
+	list := got[42].([]interface{})
+	if len(list) != 20 || list[19] != int64(19000) {
+		t.Errorf("list: %#v", list)
+	}
+	if nested := got[43].(map[int16]interface{}); string(nested[1].([]byte)) != "ñ" {
+		t.Errorf("nested: %#v", nested)
+	}
+}
+
+// readParquet lee un archivo escrito por WriteParquet y devuelve el número de filas, los
+// grupos de filas y los valores de cada columna
+func readParquet(t *testing.T, file []byte) (int64, int, map[string][]interface{}) {
+	t.Helper()
+	n := len(file)
+	if !bytes.Equal(file[:4], parquetMagic) || !bytes.Equal(file[n-4:], parquetMagic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[n-8:]))
+	r := &thriftReader{buf: file[n-8-footerLen : n-8]}
+	meta := r.readStruct()
+	if r.pos != footerLen {
+		t.Fatalf("footer: read %d of %d bytes", r.pos, footerLen)
+	}
+
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	if int(root[5].(int64)) != len(schema)-1 {
+		t.Fatalf("root num_children %v, schema has %d columns", root[5], len(schema)-1)
+	}
+	types := map[string]int64{}
+	converted := map[string]int64{}
+	for _, item := range schema[1:] {
+		element := item.(map[int16]interface{})
+		name := string(element[4].([]byte))
+		types[name] = element[1].(int64)
+		converted[name] = -1
+		if element[3] != int64(parquetOptional) {
+			t.Errorf("column %q is not optional", name)
+		}
+		if c, ok := element[6]; ok {
+			converted[name] = c.(int64)
+		}
+	}
+
+	values := map[string][]interface{}{}
+	rowGroups := meta[4].([]interface{})
+	for _, item := range rowGroups {
+		group := item.(map[int16]interface{})
+		rows := int(group[3].(int64))
+		for _, chunkItem := range group[1].([]interface{}) {
+			chunk := chunkItem.(map[int16]interface{})[3].(map[int16]interface{})
+			name := string(chunk[3].([]interface{})[0].([]byte))
+			if chunk[1] != types[name] {
+				t.Errorf("column %q: chunk type %v, schema type %v", name, chunk[1], types[name])
+			}
+			if int(chunk[5].(int64)) != rows {
+				t.Errorf("column %q: %v values in a group of %d rows", name, chunk[5], rows)
+			}
+			offset := int(chunk[9].(int64))
+			page := &thriftReader{buf: file[offset:]}
+			header := page.readStruct()
+			data := header[5].(map[int16]interface{})
+			if int(data[1].(int64)) != rows {
+				t.Errorf("column %q: page has %v values, want %d", name, data[1], rows)
+			}
+			compressed := int(header[3].(int64))
+			if int(chunk[7].(int64)) != page.pos+compressed {
+				t.Errorf("column %q: compressed size %v, want %d", name, chunk[7], page.pos+compressed)
+			}
+			body := decompress(t, chunk[4].(int64), file[offset+page.pos:offset+page.pos+compressed])
+			if len(body) != int(header[2].(int64)) {
+				t.Errorf("column %q: uncompressed %d bytes, header says %v", name, len(body), header[2])
+			}
+			values[name] = append(values[name], decodePage(t, body, rows, types[name], converted[name])...)
+		}
+	}
+	return meta[3].(int64), len(rowGroups), values
+}
+
+func decompress(t *testing.T, codec int64, body []byte) []byte {
+	t.Helper()
+	switch codec {
+	case parquetCodecUncompressed:
+		return body
+	case parquetCodecSnappy:
+		out, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	case parquetCodecGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	t.Fatalf("unknown codec %d", codec)
+	return nil
+}
+
+// decodePage decodifica niveles de definición RLE y valores PLAIN
+func decodePage(t *testing.T, body []byte, rows int, physical, converted int64) []interface{} {
+	t.Helper()
+	levelsLen := int(binary.LittleEndian.Uint32(body))
+	levels := &thriftReader{buf: body[4 : 4+levelsLen]}
+	var defined []bool
+	for levels.pos < len(levels.buf) {
+		header := levels.uvarint()
+		if header&1 != 0 {
+			t.Fatal("unexpected bit-packed run")
+		}
+		value := levels.byte() == 1
+		for i := uint64(0); i < header>>1; i++ {
+			defined = append(defined, value)
+		}
+	}
+	if len(defined) != rows {
+		t.Fatalf("got %d definition levels, want %d", len(defined), rows)
+	}
+
+	data := body[4+levelsLen:]
+	out := make([]interface{}, rows)
+	bit := 0
+	for i := range out {
+		if !defined[i] {
+			continue
+		}
+		switch physical {
+		case parquetBoolean:
+			out[i] = data[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		case parquetInt64:
+			v := int64(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+			if converted == parquetConvertedTimestampMicros {
+				out[i] = time.UnixMicro(v).UTC()
+			} else {
+				out[i] = v
+			}
+		case parquetDouble:
+			out[i] = math.Float64frombits(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case parquetByteArray:
+			n := int(binary.LittleEndian.Uint32(data))
+			raw := append([]byte{}, data[4:4+n]...)
+			data = data[4+n:]
+			if converted == parquetConvertedUTF8 {
+				out[i] = string(raw)
+			} else {
+				out[i] = raw
+			}
+		}
+	}
+	return out
+}
+
+func TestWriteParquetRoundTrip(t *testing.T) {
+	table := sampleTable()
+	for _, tc := range []struct {
+		name        string
+		compression Compression
+	}{
+		{"snappy", CompressionSnappy},
+		{"gzip", CompressionGzip},
+		{"none", CompressionNone},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteParquet(&buf, table, ParquetOptions{Compression: tc.compression, RowGroupSize: 2}); err != nil {
+				t.Fatal(err)
+			}
+			rows, groups, values := readParquet(t, buf.Bytes())
+			if rows != int64(table.Rows) {
+				t.Errorf("num_rows %d, want %d", rows, table.Rows)
+			}
+			if groups != 3 {
+				t.Errorf("got %d row groups, want 3", groups)
+			}
+			checkColumns(t, table, values)
+		})
+	}
+}
+
+func TestWriteParquetEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, Infer(nil, nil), ParquetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	rows, groups, _ := readParquet(t, buf.Bytes())
+	if rows != 0 || groups != 0 {
+		t.Errorf("got %d rows in %d groups, want none", rows, groups)
+	}
+}
@@ -0,0 +1,232 @@
+// Package columnar convierte documentos de Firestore en una tabla con esquema inferido y
+// la escribe en Parquet o en el formato de archivo IPC de Arrow, legibles directamente
+// desde pandas, Polars o DuckDB. Los mapas se aplanan en columnas con puntos
+// ("address.city"); los arrays y demás tipos compuestos se guardan como JSON.
+package columnar
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// IDColumn columna con el ID de cada documento
+const IDColumn = "_id"
+
+// Type tipo de una columna
+type Type int
+
+const (
+	TypeString Type = iota
+	TypeBool
+	TypeInt64
+	TypeDouble
+	TypeTimestamp // microsegundos UTC
+	TypeBinary
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeBool:
+		return "bool"
+	case TypeInt64:
+		return "int64"
+	case TypeDouble:
+		return "double"
+	case TypeTimestamp:
+		return "timestamp"
+	case TypeBinary:
+		return "binary"
+	}
+	return "string"
+}
+
+// Column columna de la tabla. Values contiene nil (nulo) o valores del tipo de la
+// columna: string, bool, int64, float64, time.Time o []byte.
+type Column struct {
+	Name   string
+	Type   Type
+	Values []interface{}
+}
+
+// Table tabla con todas sus columnas de la misma longitud
+type Table struct {
+	Columns []*Column
+	Rows    int
+}
+
+// Infer construye la tabla de los documentos (ids[i] es el ID de docs[i]). Cada campo
+// con un único tipo conserva ese tipo; enteros y decimales mezclados son double y
+// cualquier otra mezcla se guarda como texto.
+func Infer(ids []string, docs []map[string]interface{}) *Table {
+	flat := make([]map[string]interface{}, len(docs))
+	kinds := map[string]map[Type]bool{}
+	for i, doc := range docs {
+		flat[i] = map[string]interface{}{}
+		flatten("", doc, flat[i])
+		for name, value := range flat[i] {
+			if value == nil {
+				continue
+			}
+			if kinds[name] == nil {
+				kinds[name] = map[Type]bool{}
+			}
+			kinds[name][typeOf(value)] = true
+		}
+	}
+
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		if name != IDColumn {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	table := &Table{Rows: len(docs)}
+	id := &Column{Name: IDColumn, Type: TypeString, Values: make([]interface{}, len(docs))}
+	for i := range docs {
+		id.Values[i] = ids[i]
+	}
+	table.Columns = append(table.Columns, id)
+
+	for _, name := range names {
+		column := &Column{Name: name, Type: resolveType(kinds[name]), Values: make([]interface{}, len(docs))}
+		for i := range flat {
+			column.Values[i] = convert(flat[i][name], column.Type)
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	return table
+}
+
+// flatten aplana los mapas anidados con nombres separados por puntos y normaliza los
+// valores hoja
+func flatten(prefix string, data map[string]interface{}, out map[string]interface{}) {
+	for key, value := range data {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flatten(name, nested, out)
+			continue
+		}
+		out[name] = normalize(value)
+	}
+}
+
+// normalize lleva el valor a uno de los tipos de columna; lo demás se serializa como JSON
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string, bool, int64, float64, []byte:
+		return v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case time.Time:
+		return v.UTC()
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC()
+	case *gfirestore.DocumentRef:
+		if v == nil {
+			return nil
+		}
+		return relativePath(v.Path)
+	}
+	if reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return nil
+	}
+	content, err := json.Marshal(firestore.EncodeJSONValue(value))
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(content)
+}
+
+func typeOf(value interface{}) Type {
+	switch value.(type) {
+	case bool:
+		return TypeBool
+	case int64:
+		return TypeInt64
+	case float64:
+		return TypeDouble
+	case time.Time:
+		return TypeTimestamp
+	case []byte:
+		return TypeBinary
+	}
+	return TypeString
+}
+
+func resolveType(kinds map[Type]bool) Type {
+	if len(kinds) == 1 {
+		for kind := range kinds {
+			return kind
+		}
+	}
+	if len(kinds) == 2 && kinds[TypeInt64] && kinds[TypeDouble] {
+		return TypeDouble
+	}
+	return TypeString
+}
+
+// convert lleva un valor normalizado al tipo resuelto de la columna
+func convert(value interface{}, target Type) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch target {
+	case TypeDouble:
+		if n, ok := value.(int64); ok {
+			return float64(n)
+		}
+	case TypeString:
+		switch v := value.(type) {
+		case string:
+			return v
+		case time.Time:
+			return v.Format(time.RFC3339Nano)
+		case []byte:
+			return base64.StdEncoding.EncodeToString(v)
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return value
+}
+
+// relativePath quita el prefijo "projects/<p>/databases/<d>/documents/" de una ruta
+func relativePath(path string) string {
+	if i := strings.Index(path, "/documents/"); i >= 0 {
+		return path[i+len("/documents/"):]
+	}
+	return path
+}
@@ -0,0 +1,86 @@
+package columnar
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// sampleTable tabla con todos los tipos de columna, nulos y un mapa anidado
+func sampleTable() *Table {
+	at := time.Date(2024, 3, 15, 10, 30, 0, 123456000, time.UTC)
+	docs := []map[string]interface{}{
+		{"name": "Ana", "active": true, "age": 31, "score": 9.5, "created_at": at, "avatar": []byte{0, 1, 2}, "address": map[string]interface{}{"city": "Lima"}},
+		{"name": "", "active": false, "age": int64(-4), "score": 7, "created_at": at.Add(time.Hour), "avatar": []byte{}},
+		{"name": nil, "active": nil, "age": nil, "score": nil, "created_at": nil, "avatar": nil, "tags": []interface{}{"a", "b"}},
+		{"name": "Ñandú 🚀", "active": true, "age": 1 << 40, "score": -0.25, "created_at": at.Add(-time.Minute), "address": map[string]interface{}{"city": "Quito"}},
+		{"name": "Luis", "active": true, "age": 0, "score": 1.0e300},
+	}
+	return Infer([]string{"u1", "u2", "u3", "u4", "u5"}, docs)
+}
+
+// equalValue compara dos valores de columna (time.Time por instante, []byte por contenido)
+func equalValue(a, b interface{}) bool {
+	switch x := a.(type) {
+	case time.Time:
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	case []byte:
+		y, ok := b.([]byte)
+		return ok && bytes.Equal(x, y)
+	}
+	return a == b
+}
+
+// checkColumns compara los valores leídos de vuelta con los de la tabla
+func checkColumns(t *testing.T, table *Table, got map[string][]interface{}) {
+	t.Helper()
+	for _, column := range table.Columns {
+		values, ok := got[column.Name]
+		if !ok {
+			t.Errorf("column %q missing", column.Name)
+			continue
+		}
+		if len(values) != table.Rows {
+			t.Errorf("column %q: got %d values, want %d", column.Name, len(values), table.Rows)
+			continue
+		}
+		for i, want := range column.Values {
+			if !equalValue(want, values[i]) {
+				t.Errorf("column %q row %d: got %#v, want %#v", column.Name, i, values[i], want)
+			}
+		}
+	}
+}
+
+func TestInfer(t *testing.T) {
+	table := sampleTable()
+	want := []struct {
+		name string
+		kind Type
+	}{
+		{IDColumn, TypeString},
+		{"active", TypeBool},
+		{"address.city", TypeString},
+		{"age", TypeInt64},
+		{"avatar", TypeBinary},
+		{"created_at", TypeTimestamp},
+		{"name", TypeString},
+		{"score", TypeDouble},
+		{"tags", TypeString},
+	}
+	if len(table.Columns) != len(want) {
+		t.Fatalf("got %d columns, want %d", len(table.Columns), len(want))
+	}
+	for i, w := range want {
+		if table.Columns[i].Name != w.name || table.Columns[i].Type != w.kind {
+			t.Errorf("column %d: got %s %s, want %s %s", i, table.Columns[i].Name, table.Columns[i].Type, w.name, w.kind)
+		}
+	}
+	if got := table.Columns[7].Values[1]; got != float64(7) {
+		t.Errorf("mixed int/double: got %#v, want 7.0", got)
+	}
+	if got := table.Columns[8].Values[2]; got != `["a","b"]` {
+		t.Errorf("array as JSON: got %#v", got)
+	}
+}
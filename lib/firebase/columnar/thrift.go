@@ -0,0 +1,105 @@
+package columnar
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Tipos del protocolo compacto de Thrift (usado por los metadatos de Parquet)
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter escritor mínimo del protocolo compacto de Thrift: solo los tipos que usan
+// los metadatos que escribe este paquete
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastIDs []int16 // último campo escrito de cada struct abierto
+}
+
+func (w *thriftWriter) fieldHeader(id int16, kind byte) {
+	last := w.lastIDs[len(w.lastIDs)-1]
+	if delta := id - last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		w.buf.WriteByte(kind)
+		w.varint(zigzag(int64(id)))
+	}
+	w.lastIDs[len(w.lastIDs)-1] = id
+}
+
+func (w *thriftWriter) beginStruct() {
+	w.lastIDs = append(w.lastIDs, 0)
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.lastIDs = w.lastIDs[:len(w.lastIDs)-1]
+}
+
+// structField abre un campo struct; cerrarlo con endStruct
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.beginStruct()
+}
+
+func (w *thriftWriter) i32Field(id int16, value int32) {
+	w.fieldHeader(id, thriftI32)
+	w.varint(zigzag(int64(value)))
+}
+
+func (w *thriftWriter) i64Field(id int16, value int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(zigzag(value))
+}
+
+func (w *thriftWriter) boolField(id int16, value bool) {
+	if value {
+		w.fieldHeader(id, thriftTrue)
+	} else {
+		w.fieldHeader(id, thriftFalse)
+	}
+}
+
+func (w *thriftWriter) stringField(id int16, value string) {
+	w.fieldHeader(id, thriftBinary)
+	w.varint(uint64(len(value)))
+	w.buf.WriteString(value)
+}
+
+// listField abre un campo lista de size elementos de tipo kind; los elementos struct se
+// escriben con beginStruct/endStruct
+func (w *thriftWriter) listField(id int16, kind byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | kind)
+	} else {
+		w.buf.WriteByte(0xF0 | kind)
+		w.varint(uint64(size))
+	}
+}
+
+func (w *thriftWriter) i32Element(value int32) {
+	w.varint(zigzag(int64(value)))
+}
+
+func (w *thriftWriter) stringElement(value string) {
+	w.varint(uint64(len(value)))
+	w.buf.WriteString(value)
+}
+
+func (w *thriftWriter) varint(value uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], value)
+	w.buf.Write(tmp[:n])
+}
+
+func zigzag(value int64) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
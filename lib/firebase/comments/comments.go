@@ -0,0 +1,152 @@
+package comments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+const (
+	// CountField contador de comentarios de primer nivel en el documento padre
+	CountField = "comment_count"
+	// ReplyCountField contador de respuestas en cada comentario
+	ReplyCountField = "reply_count"
+)
+
+// Collection retorna la subcolección de comentarios de un documento ("posts/abc" ->
+// "posts/abc/comments")
+func Collection(parentPath string) string {
+	return strings.Trim(parentPath, "/") + "/comments"
+}
+
+// AddComment agrega un comentario al documento parentPath (o una respuesta si replyTo no
+// está vacío) e incrementa el contador correspondiente en la misma transacción
+func AddComment(ctx context.Context, session *firebase.SessionInfo, parentPath, replyTo, body string) (string, error) {
+	if session == nil || session.UID == "" {
+		return "", firebase.ErrUnauthenticated
+	}
+	if strings.TrimSpace(body) == "" {
+		return "", fmt.Errorf("comment body cannot be empty")
+	}
+
+	collection := Collection(parentPath)
+	commentID := firebase.GetFirestoreClient().Collection(collection).NewDoc().ID
+	now := firebase.Now()
+	data := map[string]interface{}{
+		"author_uid":    session.UID,
+		"body":          body,
+		"reply_to":      replyTo,
+		"deleted":       false,
+		ReplyCountField: 0,
+		"created_at":    now,
+		"updated_at":    now,
+	}
+	if err := firestore.ValidateDocument(collection, commentID, data); err != nil {
+		return "", err
+	}
+
+	// El comentario y el contador pasan por solo lectura, interceptores y políticas
+	commentRef, err := firestore.CheckedDocRef(ctx, collection+"/"+commentID, firebase.OperationCreate, data)
+	if err != nil {
+		return "", err
+	}
+	counterPath, counterField := parentPath, CountField
+	if replyTo != "" {
+		counterPath, counterField = collection+"/"+replyTo, ReplyCountField
+	}
+	counterRef, err := firestore.CheckedDocRef(ctx, counterPath, firebase.OperationUpdate, nil)
+	if err != nil {
+		return "", err
+	}
+
+	err = firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		if err := tx.Create(commentRef, data); err != nil {
+			return err
+		}
+		return tx.Update(counterRef, []gfirestore.Update{{Path: counterField, Value: gfirestore.Increment(1)}})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to add comment to '%s': %w", parentPath, err)
+	}
+	return commentRef.ID, nil
+}
+
+// EditComment cambia el texto de un comentario (solo su autor)
+func EditComment(ctx context.Context, session *firebase.SessionInfo, parentPath, commentID, body string) error {
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("comment body cannot be empty")
+	}
+	if _, err := authorComment(ctx, session, parentPath, commentID); err != nil {
+		return err
+	}
+
+	err := firestore.UpdateDocument(ctx, Collection(parentPath), commentID, map[string]interface{}{
+		"body":      body,
+		"edited_at": firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to edit comment '%s': %w", commentID, err)
+	}
+	return nil
+}
+
+// SoftDeleteComment marca el comentario como eliminado y borra su texto, conservándolo
+// para no romper el hilo de respuestas ni los contadores (solo su autor)
+func SoftDeleteComment(ctx context.Context, session *firebase.SessionInfo, parentPath, commentID string) error {
+	if _, err := authorComment(ctx, session, parentPath, commentID); err != nil {
+		return err
+	}
+
+	err := firestore.UpdateDocument(ctx, Collection(parentPath), commentID, map[string]interface{}{
+		"body":       "",
+		"deleted":    true,
+		"deleted_at": firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete comment '%s': %w", commentID, err)
+	}
+	return nil
+}
+
+// ListThread lista los comentarios de primer nivel de parentPath (o las respuestas de
+// replyTo) en orden cronológico. El cursor es el NextCursor del resultado anterior.
+func ListThread(ctx context.Context, parentPath, replyTo string, pageSize int, cursor string) (*firebase.QueryResult, error) {
+	result, err := firestore.QueryDocumentsWithMeta(ctx, Collection(parentPath), firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{firebase.Where("reply_to", firebase.OpEqual, replyTo)},
+		OrderBy: "created_at",
+		Limit:   pageSize,
+		Cursor:  cursor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments of '%s': %w", parentPath, err)
+	}
+	return result, nil
+}
+
+// authorComment obtiene el comentario y verifica que pertenezca al usuario de la sesión
+func authorComment(ctx context.Context, session *firebase.SessionInfo, parentPath, commentID string) (*firebase.Document, error) {
+	if session == nil || session.UID == "" {
+		return nil, firebase.ErrUnauthenticated
+	}
+
+	doc, err := firestore.GetDocument(ctx, Collection(parentPath), commentID)
+	if err != nil {
+		return nil, err
+	}
+	if author, _ := doc.Data["author_uid"].(string); author != session.UID {
+		return nil, &firebase.PermissionDeniedError{
+			Collection: Collection(parentPath),
+			DocumentID: commentID,
+			Operation:  firebase.OperationUpdate,
+		}
+	}
+	if deleted, _ := doc.Data["deleted"].(bool); deleted {
+		return nil, fmt.Errorf("comment '%s' has been deleted", commentID)
+	}
+	return doc, nil
+}
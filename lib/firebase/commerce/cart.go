@@ -0,0 +1,357 @@
+package commerce
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+const (
+	// ProductsCollection colección de productos (campos name, price, stock, reserved)
+	ProductsCollection = "products"
+	// CartsCollection colección de carritos, uno por usuario (ID = UID)
+	CartsCollection = "carts"
+	// OrdersCollection colección de pedidos generados por Checkout
+	OrdersCollection = "orders"
+)
+
+// Estados de un carrito
+const (
+	CartOpen       = "open"
+	CartCheckedOut = "checked_out"
+	CartExpired    = "expired"
+)
+
+// CartTTL tiempo sin actividad tras el cual un carrito se considera abandonado
+var CartTTL = 24 * time.Hour
+
+// CartItem línea de un carrito
+type CartItem struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	Quantity  int64   `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+}
+
+// Cart carrito de compras de un usuario
+type Cart struct {
+	UID       string               `json:"uid"`
+	Status    string               `json:"status"`
+	Items     map[string]*CartItem `json:"items"`
+	Total     float64              `json:"total"`
+	ExpiresAt time.Time            `json:"expires_at"`
+}
+
+// InsufficientStockError cuando no hay existencias disponibles para la cantidad pedida
+type InsufficientStockError struct {
+	ProductID string
+	Requested int64
+	Available int64
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product '%s': requested %d, available %d", e.ProductID, e.Requested, e.Available)
+}
+
+// AddItem agrega qty unidades del producto al carrito del usuario, reservando las
+// existencias en el producto dentro de la misma transacción
+func AddItem(ctx context.Context, uid, productID string, qty int64) (*Cart, error) {
+	if qty <= 0 {
+		return nil, fmt.Errorf("quantity must be positive")
+	}
+	return changeQuantity(ctx, uid, productID, func(current int64) int64 { return current + qty })
+}
+
+// UpdateQuantity fija la cantidad del producto en el carrito (0 lo elimina), ajustando
+// la reserva de existencias
+func UpdateQuantity(ctx context.Context, uid, productID string, qty int64) (*Cart, error) {
+	if qty < 0 {
+		return nil, fmt.Errorf("quantity cannot be negative")
+	}
+	return changeQuantity(ctx, uid, productID, func(int64) int64 { return qty })
+}
+
+// RemoveItem elimina el producto del carrito y libera su reserva
+func RemoveItem(ctx context.Context, uid, productID string) (*Cart, error) {
+	return UpdateQuantity(ctx, uid, productID, 0)
+}
+
+// GetCart obtiene el carrito del usuario (vacío si no existe)
+func GetCart(ctx context.Context, uid string) (*Cart, error) {
+	snap, err := firestore.DocRefContext(ctx, CartsCollection, uid).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return &Cart{UID: uid, Status: CartOpen, Items: map[string]*CartItem{}}, nil
+		}
+		return nil, fmt.Errorf("failed to get cart of '%s': %w", uid, err)
+	}
+	return cartFromData(uid, snap.Data()), nil
+}
+
+// Checkout convierte el carrito en un pedido: descuenta las existencias reservadas,
+// crea el documento en OrdersCollection y cierra el carrito. Un carrito cuyo expires_at
+// ya pasó se rechaza aunque ExpireAbandonedCarts aún no lo haya marcado. Retorna el ID
+// del pedido.
+func Checkout(ctx context.Context, uid string) (string, error) {
+	cartRef := firestore.DocRefContext(ctx, CartsCollection, uid)
+	orderRef := firebase.GetFirestoreClient().Collection(OrdersCollection).NewDoc()
+
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(cartRef)
+		if err != nil {
+			return err
+		}
+		cart := cartFromData(uid, snap.Data())
+		if cart.Status != CartOpen {
+			return fmt.Errorf("cart is %s", cart.Status)
+		}
+		if !cart.ExpiresAt.IsZero() && !firebase.Now().Before(cart.ExpiresAt) {
+			return fmt.Errorf("cart is %s", CartExpired)
+		}
+		if len(cart.Items) == 0 {
+			return fmt.Errorf("cart is empty")
+		}
+
+		// Las lecturas deben preceder a las escrituras dentro de la transacción
+		for productID := range cart.Items {
+			if _, err := tx.Get(firestore.DocRefContext(ctx, ProductsCollection, productID)); err != nil {
+				return fmt.Errorf("failed to get product '%s': %w", productID, err)
+			}
+		}
+
+		items := make([]interface{}, 0, len(cart.Items))
+		for productID, item := range cart.Items {
+			err := tx.Update(firestore.DocRefContext(ctx, ProductsCollection, productID), []gfirestore.Update{
+				{Path: "stock", Value: gfirestore.Increment(-item.Quantity)},
+				{Path: "reserved", Value: gfirestore.Increment(-item.Quantity)},
+			})
+			if err != nil {
+				return err
+			}
+			items = append(items, itemData(item))
+		}
+
+		now := firebase.Now()
+		if err := tx.Create(orderRef, map[string]interface{}{
+			"uid":        uid,
+			"items":      items,
+			"total":      cart.Total,
+			"status":     "pending_payment",
+			"created_at": now,
+			"updated_at": now,
+		}); err != nil {
+			return err
+		}
+		return tx.Update(cartRef, []gfirestore.Update{
+			{Path: "status", Value: CartCheckedOut},
+			{Path: "order_id", Value: orderRef.ID},
+			{Path: "updated_at", Value: now},
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to checkout cart of '%s': %w", uid, err)
+	}
+	return orderRef.ID, nil
+}
+
+// ExpireAbandonedCarts marca como expirados los carritos abiertos cuyo expires_at ya pasó
+// y libera sus reservas. Retorna cuántos carritos expiró.
+func ExpireAbandonedCarts(ctx context.Context) (int, error) {
+	docs, err := firestore.QueryDocuments(ctx, CartsCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			firebase.Where("status", firebase.OpEqual, CartOpen),
+			firebase.Where("expires_at", firebase.OpLessThan, firebase.Now()),
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list abandoned carts: %w", err)
+	}
+
+	expired := 0
+	for _, doc := range docs {
+		cartRef := firestore.DocRefContext(ctx, CartsCollection, doc.ID)
+		changed := false
+		err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+			snap, err := tx.Get(cartRef)
+			if err != nil {
+				return err
+			}
+			cart := cartFromData(doc.ID, snap.Data())
+			// Pudo haberse renovado o pagado después de la consulta
+			changed = false
+			if cart.Status != CartOpen || cart.ExpiresAt.After(firebase.Now()) {
+				return nil
+			}
+
+			for productID, item := range cart.Items {
+				err := tx.Update(firestore.DocRefContext(ctx, ProductsCollection, productID), []gfirestore.Update{
+					{Path: "reserved", Value: gfirestore.Increment(-item.Quantity)},
+				})
+				if err != nil {
+					return err
+				}
+			}
+			changed = true
+			return tx.Update(cartRef, []gfirestore.Update{
+				{Path: "status", Value: CartExpired},
+				{Path: "updated_at", Value: firebase.Now()},
+			})
+		})
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire cart of '%s': %w", doc.ID, err)
+		}
+		if changed {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// changeQuantity aplica newQty a la línea del producto y ajusta product.reserved por la
+// diferencia, verificando que stock - reserved alcance
+func changeQuantity(ctx context.Context, uid, productID string, newQty func(current int64) int64) (*Cart, error) {
+	cartRef := firestore.DocRefContext(ctx, CartsCollection, uid)
+	productRef := firestore.DocRefContext(ctx, ProductsCollection, productID)
+
+	var result *Cart
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		productSnap, err := tx.Get(productRef)
+		if err != nil {
+			return fmt.Errorf("failed to get product '%s': %w", productID, err)
+		}
+		cart := &Cart{UID: uid, Status: CartOpen, Items: map[string]*CartItem{}}
+		cartSnap, err := tx.Get(cartRef)
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			cart = cartFromData(uid, cartSnap.Data())
+		}
+		if cart.Status == CartCheckedOut || cart.Status == CartExpired {
+			// El carrito anterior ya es un pedido o expiró (sus reservas se liberaron); se
+			// empieza uno nuevo
+			cart = &Cart{UID: uid, Status: CartOpen, Items: map[string]*CartItem{}}
+		}
+		if cart.Status != CartOpen {
+			return fmt.Errorf("cart is %s", cart.Status)
+		}
+
+		product := productSnap.Data()
+		item, ok := cart.Items[productID]
+		if !ok {
+			item = &CartItem{ProductID: productID}
+		}
+		target := newQty(item.Quantity)
+		delta := target - item.Quantity
+
+		if delta > 0 {
+			available := toInt64(product["stock"]) - toInt64(product["reserved"])
+			if available < delta {
+				return &InsufficientStockError{ProductID: productID, Requested: target, Available: available + item.Quantity}
+			}
+		}
+		if delta != 0 {
+			if err := tx.Update(productRef, []gfirestore.Update{{Path: "reserved", Value: gfirestore.Increment(delta)}}); err != nil {
+				return err
+			}
+		}
+
+		item.Quantity = target
+		item.Name, _ = product["name"].(string)
+		item.UnitPrice = toFloat64(product["price"])
+		if target == 0 {
+			delete(cart.Items, productID)
+		} else {
+			cart.Items[productID] = item
+		}
+		cart.ExpiresAt = firebase.Now().Add(CartTTL)
+		cart.Total = cartTotal(cart)
+
+		result = cart
+		return tx.Set(cartRef, cartData(cart))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update cart of '%s': %w", uid, err)
+	}
+	return result, nil
+}
+
+func cartTotal(cart *Cart) float64 {
+	total := 0.0
+	for _, item := range cart.Items {
+		total += float64(item.Quantity) * item.UnitPrice
+	}
+	return total
+}
+
+func cartData(cart *Cart) map[string]interface{} {
+	items := make(map[string]interface{}, len(cart.Items))
+	for productID, item := range cart.Items {
+		items[productID] = itemData(item)
+	}
+	return map[string]interface{}{
+		"uid":        cart.UID,
+		"status":     cart.Status,
+		"items":      items,
+		"total":      cart.Total,
+		"expires_at": cart.ExpiresAt,
+		"updated_at": firebase.Now(),
+	}
+}
+
+func itemData(item *CartItem) map[string]interface{} {
+	return map[string]interface{}{
+		"product_id": item.ProductID,
+		"name":       item.Name,
+		"quantity":   item.Quantity,
+		"unit_price": item.UnitPrice,
+	}
+}
+
+func cartFromData(uid string, data map[string]interface{}) *Cart {
+	cart := &Cart{UID: uid, Items: map[string]*CartItem{}}
+	cart.Status, _ = data["status"].(string)
+	cart.Total = toFloat64(data["total"])
+	cart.ExpiresAt, _ = data["expires_at"].(time.Time)
+
+	items, _ := data["items"].(map[string]interface{})
+	for productID, raw := range items {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		item := &CartItem{ProductID: productID, Quantity: toInt64(fields["quantity"]), UnitPrice: toFloat64(fields["unit_price"])}
+		item.Name, _ = fields["name"].(string)
+		cart.Items[productID] = item
+	}
+	return cart
+}
+
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+func toFloat64(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	}
+	return 0
+}
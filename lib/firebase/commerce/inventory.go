@@ -0,0 +1,191 @@
+package commerce
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// ReservationsCollection colección de reservas de existencias con vencimiento
+const ReservationsCollection = "stock_reservations"
+
+// Estados de una reserva
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation reserva temporal de existencias de un producto
+type Reservation struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"product_id"`
+	Quantity  int64     `json:"quantity"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ReserveStock aparta qty unidades del producto durante ttl. La verificación de
+// stock - reserved y el incremento de reserved ocurren en la misma transacción, por lo que
+// checkouts concurrentes no pueden vender más de lo que existe. Si no alcanza, primero se
+// liberan las reservas vencidas del producto que aún no se hayan barrido.
+func ReserveStock(ctx context.Context, productID string, qty int64, ttl time.Duration) (*Reservation, error) {
+	if qty <= 0 {
+		return nil, fmt.Errorf("quantity must be positive")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("reservation ttl must be positive")
+	}
+
+	productRef := firestore.DocRefContext(ctx, ProductsCollection, productID)
+	reservationRef := firebase.GetFirestoreClient().Collection(ReservationsCollection).NewDoc()
+
+	var reservation *Reservation
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(productRef)
+		if err != nil {
+			return fmt.Errorf("failed to get product '%s': %w", productID, err)
+		}
+		product := snap.Data()
+		available := toInt64(product["stock"]) - toInt64(product["reserved"])
+
+		now := firebase.Now()
+		var stale []*gfirestore.DocumentSnapshot
+		if available < qty {
+			stale, err = tx.Documents(firebase.GetFirestoreClient().Collection(ReservationsCollection).
+				Where("product_id", "==", productID).
+				Where("status", "==", ReservationActive).
+				Where("expires_at", "<", now)).GetAll()
+			if err != nil {
+				return fmt.Errorf("failed to list expired reservations: %w", err)
+			}
+			for _, doc := range stale {
+				available += toInt64(doc.Data()["quantity"])
+			}
+		}
+		if available < qty {
+			return &InsufficientStockError{ProductID: productID, Requested: qty, Available: available}
+		}
+
+		released := int64(0)
+		for _, doc := range stale {
+			released += toInt64(doc.Data()["quantity"])
+			if err := tx.Update(doc.Ref, []gfirestore.Update{
+				{Path: "status", Value: ReservationExpired},
+				{Path: "updated_at", Value: now},
+			}); err != nil {
+				return err
+			}
+		}
+		if err := tx.Update(productRef, []gfirestore.Update{{Path: "reserved", Value: gfirestore.Increment(qty - released)}}); err != nil {
+			return err
+		}
+
+		reservation = &Reservation{
+			ID:        reservationRef.ID,
+			ProductID: productID,
+			Quantity:  qty,
+			Status:    ReservationActive,
+			ExpiresAt: now.Add(ttl),
+		}
+		return tx.Create(reservationRef, map[string]interface{}{
+			"product_id": productID,
+			"quantity":   qty,
+			"status":     ReservationActive,
+			"expires_at": reservation.ExpiresAt,
+			"created_at": now,
+			"updated_at": now,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve stock of '%s': %w", productID, err)
+	}
+	return reservation, nil
+}
+
+// CommitReservation confirma una reserva activa y no vencida: descuenta las unidades del
+// stock y las quita de reserved
+func CommitReservation(ctx context.Context, reservationID string) error {
+	return settleReservation(ctx, reservationID, ReservationCommitted)
+}
+
+// ReleaseReservation cancela una reserva activa y devuelve sus unidades al disponible.
+// Liberar una reserva ya vencida o liberada no es un error.
+func ReleaseReservation(ctx context.Context, reservationID string) error {
+	return settleReservation(ctx, reservationID, ReservationReleased)
+}
+
+// ReleaseExpiredReservations barre las reservas activas vencidas y retorna cuántas liberó
+func ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	docs, err := firestore.QueryDocuments(ctx, ReservationsCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			firebase.Where("status", firebase.OpEqual, ReservationActive),
+			firebase.Where("expires_at", firebase.OpLessThan, firebase.Now()),
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired reservations: %w", err)
+	}
+
+	released := 0
+	for _, doc := range docs {
+		if err := settleReservation(ctx, doc.ID, ReservationExpired); err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// settleReservation cierra una reserva activa con el estado indicado
+func settleReservation(ctx context.Context, reservationID, status string) error {
+	reservationRef := firestore.DocRefContext(ctx, ReservationsCollection, reservationID)
+
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(reservationRef)
+		if err != nil {
+			return err
+		}
+		data := snap.Data()
+		current, _ := data["status"].(string)
+		expiresAt, _ := data["expires_at"].(time.Time)
+		expired := firebase.Now().After(expiresAt)
+
+		if current != ReservationActive {
+			if status != ReservationCommitted {
+				return nil
+			}
+			return fmt.Errorf("reservation is %s", current)
+		}
+		if status == ReservationCommitted && expired {
+			return fmt.Errorf("reservation expired at %s", expiresAt.Format(time.RFC3339))
+		}
+		if status == ReservationExpired && !expired {
+			return nil
+		}
+
+		productID, _ := data["product_id"].(string)
+		qty := toInt64(data["quantity"])
+		updates := []gfirestore.Update{{Path: "reserved", Value: gfirestore.Increment(-qty)}}
+		if status == ReservationCommitted {
+			updates = append(updates, gfirestore.Update{Path: "stock", Value: gfirestore.Increment(-qty)})
+		}
+		if err := tx.Update(firestore.DocRefContext(ctx, ProductsCollection, productID), updates); err != nil {
+			return err
+		}
+		return tx.Update(reservationRef, []gfirestore.Update{
+			{Path: "status", Value: status},
+			{Path: "updated_at", Value: firebase.Now()},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to settle reservation '%s' as %s: %w", reservationID, status, err)
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+// Package consent registra la aceptación de los términos de servicio, la política de
+// privacidad y otros documentos legales versionados. Cada aceptación guarda la versión,
+// la fecha, la IP y el user agent (de firebase.ClientInfoFromContext). Al publicar una
+// versión que exige volver a aceptar, RequireConsent y Middleware bloquean a los usuarios
+// que aún no la aceptaron y PendingReconsent los lista.
+//
+//	consent.Publish(ctx, consent.Document{Kind: consent.KindTerms, Version: "2025-01", Required: true})
+//	mux.Handle("/api/", auth.SessionMiddleware(true)(consent.Middleware()(api)))
+//	consent.Accept(ctx, uid, consent.KindTerms, "2025-01")
+package consent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+const (
+	// DocumentsCollection versión vigente de cada documento legal (un documento por tipo)
+	DocumentsCollection = "consent_documents"
+	// ConsentsCollection última aceptación de cada usuario y tipo ("<uid>__<tipo>")
+	ConsentsCollection = "user_consents"
+	// HistoryCollection historial de todas las aceptaciones
+	HistoryCollection = "consent_history"
+)
+
+// Tipos de documento habituales
+const (
+	KindTerms   = "tos"
+	KindPrivacy = "privacy"
+)
+
+// CacheTTL tiempo que se reutilizan las versiones vigentes leídas
+var CacheTTL = time.Minute
+
+// Document versión vigente de un documento legal. Required indica que los usuarios
+// deben aceptarla (también quienes aceptaron una versión anterior).
+type Document struct {
+	Kind        string    `json:"kind"`
+	Version     string    `json:"version"`
+	URL         string    `json:"url,omitempty"`
+	Required    bool      `json:"required"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Consent aceptación de un documento por un usuario
+type Consent struct {
+	UID        string    `json:"uid"`
+	Kind       string    `json:"kind"`
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+type cachedDocuments struct {
+	documents map[string]*Document
+	expiresAt time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   cachedDocuments
+)
+
+// Publish publica una nueva versión de un documento
+func Publish(ctx context.Context, document Document) error {
+	if document.Kind == "" || strings.Contains(document.Kind, "/") || document.Version == "" {
+		return fmt.Errorf("consent document requires a kind and a version")
+	}
+	document.PublishedAt = firebase.Now()
+	_, err := firebase.GetFirestoreClient().Collection(DocumentsCollection).Doc(document.Kind).Set(ctx, map[string]interface{}{
+		"version":      document.Version,
+		"url":          document.URL,
+		"required":     document.Required,
+		"published_at": document.PublishedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish consent document '%s': %w", document.Kind, err)
+	}
+	cacheMu.Lock()
+	cache = cachedDocuments{}
+	cacheMu.Unlock()
+	return nil
+}
+
+// CurrentDocuments retorna las versiones vigentes por tipo (con caché de CacheTTL)
+func CurrentDocuments(ctx context.Context) (map[string]*Document, error) {
+	cacheMu.Lock()
+	cached := cache
+	cacheMu.Unlock()
+	hit := cached.documents != nil && firebase.Now().Before(cached.expiresAt)
+	firebase.RecordCacheLookup("consent", hit)
+	if hit {
+		return cached.documents, nil
+	}
+
+	snaps, err := firebase.GetFirestoreClient().Collection(DocumentsCollection).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load consent documents: %w", err)
+	}
+	documents := make(map[string]*Document, len(snaps))
+	for _, snap := range snaps {
+		doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+		document := &Document{Kind: doc.ID}
+		document.Version, _ = doc.GetString("version")
+		document.URL, _ = doc.GetString("url")
+		document.Required, _ = doc.GetBool("required")
+		document.PublishedAt, _ = doc.GetTime("published_at")
+		documents[doc.ID] = document
+	}
+
+	cacheMu.Lock()
+	cache = cachedDocuments{documents: documents, expiresAt: firebase.Now().Add(CacheTTL)}
+	cacheMu.Unlock()
+	return documents, nil
+}
+
+// Accept registra que el usuario aceptó la versión indicada (vacía = la vigente). Solo
+// se acepta la versión vigente.
+func Accept(ctx context.Context, uid, kind, version string) (*Consent, error) {
+	documents, err := CurrentDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, ok := documents[kind]
+	if !ok {
+		return nil, &firebase.DocumentNotFoundError{Collection: DocumentsCollection, DocumentID: kind}
+	}
+	if version == "" {
+		version = current.Version
+	}
+	if version != current.Version {
+		return nil, fmt.Errorf("version '%s' of '%s' is not the current one (%s)", version, kind, current.Version)
+	}
+
+	consent := &Consent{UID: uid, Kind: kind, Version: version, AcceptedAt: firebase.Now()}
+	if client, ok := firebase.ClientInfoFromContext(ctx); ok {
+		consent.IP, consent.UserAgent = client.IP, client.UserAgent
+	}
+	data := map[string]interface{}{
+		"uid":         uid,
+		"kind":        kind,
+		"version":     version,
+		"accepted_at": consent.AcceptedAt,
+		"ip":          consent.IP,
+		"user_agent":  consent.UserAgent,
+	}
+	// Copia para el historial: las escrituras agregan sus timestamps al mapa
+	history := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		history[key] = value
+	}
+	if err := firestore.CreateDocumentWithID(ctx, ConsentsCollection, consentID(uid, kind), data); err != nil {
+		return nil, fmt.Errorf("failed to record consent: %w", err)
+	}
+	if _, err := firestore.CreateDocument(ctx, HistoryCollection, history); err != nil {
+		return nil, fmt.Errorf("failed to record consent history: %w", err)
+	}
+	return consent, nil
+}
+
+// GetConsents retorna la última aceptación del usuario por tipo
+func GetConsents(ctx context.Context, uid string) (map[string]*Consent, error) {
+	docs, err := firestore.QueryDocuments(ctx, ConsentsCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{{Field: "uid", Operator: firebase.OpEqual, Value: uid}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	consents := make(map[string]*Consent, len(docs))
+	for _, doc := range docs {
+		consent := consentFromDocument(doc)
+		consents[consent.Kind] = consent
+	}
+	return consents, nil
+}
+
+// Pending retorna los tipos obligatorios (o los indicados) cuya versión vigente el
+// usuario no aceptó
+func Pending(ctx context.Context, uid string, kinds ...string) ([]string, error) {
+	documents, err := CurrentDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(kinds) == 0 {
+		for kind, document := range documents {
+			if document.Required {
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	consents, err := GetConsents(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, kind := range kinds {
+		document, ok := documents[kind]
+		if !ok {
+			continue
+		}
+		if consent, ok := consents[kind]; !ok || consent.Version != document.Version {
+			pending = append(pending, kind)
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
+
+// RequireConsent retorna *firebase.ConsentRequiredError si el usuario de la sesión del
+// contexto tiene documentos pendientes (los obligatorios o los indicados), y
+// firebase.ErrUnauthenticated si no hay sesión
+func RequireConsent(ctx context.Context, kinds ...string) error {
+	session, ok := firebase.SessionFromContext(ctx)
+	if !ok || session.UID == "" {
+		return firebase.ErrUnauthenticated
+	}
+	pending, err := Pending(ctx, session.UID, kinds...)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return &firebase.ConsentRequiredError{UID: session.UID, Kinds: pending}
+	}
+	return nil
+}
+
+// Middleware responde 403 con la cabecera X-Consent-Required (tipos separados por coma)
+// cuando el usuario de la sesión tiene documentos pendientes. Las peticiones sin sesión
+// pasan sin verificar; usarlo después de auth.SessionMiddleware.
+func Middleware(kinds ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := RequireConsent(r.Context(), kinds...)
+			var required *firebase.ConsentRequiredError
+			switch {
+			case err == nil, errors.Is(err, firebase.ErrUnauthenticated):
+				next.ServeHTTP(w, r)
+			case errors.As(err, &required):
+				w.Header().Set("X-Consent-Required", strings.Join(required.Kinds, ","))
+				http.Error(w, "debes aceptar la versión vigente de los términos", http.StatusForbidden)
+			default:
+				http.Error(w, "no se pudo verificar el consentimiento", http.StatusInternalServerError)
+			}
+		})
+	}
+}
+
+// PendingReport usuarios que aceptaron una versión anterior a la vigente
+type PendingReport struct {
+	Kind           string     `json:"kind"`
+	CurrentVersion string     `json:"current_version"`
+	Total          int        `json:"total"`
+	Consents       []*Consent `json:"consents"` // su última aceptación
+	NextCursor     string     `json:"next_cursor,omitempty"`
+}
+
+// PendingReconsent lista (paginado) los usuarios que deben volver a aceptar el documento
+// tras un cambio de versión. Los usuarios que nunca lo aceptaron no aparecen.
+func PendingReconsent(ctx context.Context, kind string, limit int, cursor string) (*PendingReport, error) {
+	documents, err := CurrentDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	document, ok := documents[kind]
+	if !ok {
+		return nil, &firebase.DocumentNotFoundError{Collection: DocumentsCollection, DocumentID: kind}
+	}
+
+	filters := []firebase.QueryFilter{
+		{Field: "kind", Operator: firebase.OpEqual, Value: kind},
+		{Field: "version", Operator: firebase.OpNotEqual, Value: document.Version},
+	}
+	total, err := firestore.CountDocuments(ctx, ConsentsCollection, filters)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	result, err := firestore.QueryDocumentsWithMeta(ctx, ConsentsCollection, firebase.QueryOptions{
+		Filters: filters,
+		Limit:   limit,
+		Cursor:  cursor,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PendingReport{Kind: kind, CurrentVersion: document.Version, Total: total, NextCursor: result.NextCursor}
+	for _, doc := range result.Documents {
+		report.Consents = append(report.Consents, consentFromDocument(doc))
+	}
+	return report, nil
+}
+
+func consentFromDocument(doc *firebase.Document) *Consent {
+	consent := &Consent{}
+	consent.UID, _ = doc.GetString("uid")
+	consent.Kind, _ = doc.GetString("kind")
+	consent.Version, _ = doc.GetString("version")
+	consent.AcceptedAt, _ = doc.GetTime("accepted_at")
+	consent.IP, _ = doc.GetString("ip")
+	consent.UserAgent, _ = doc.GetString("user_agent")
+	return consent
+}
+
+func consentID(uid, kind string) string {
+	return uid + "__" + kind
+}
@@ -0,0 +1,36 @@
+package firebase
+
+import "context"
+
+type sessionContextKey struct{}
+
+// WithSession retorna un contexto que transporta la sesión del usuario autenticado
+func WithSession(ctx context.Context, session *SessionInfo) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+// SessionFromContext obtiene la sesión guardada en el contexto (si existe)
+func SessionFromContext(ctx context.Context) (*SessionInfo, bool) {
+	session, ok := ctx.Value(sessionContextKey{}).(*SessionInfo)
+	return session, ok && session != nil
+}
+
+type clientContextKey struct{}
+
+// ClientInfo datos del cliente que origina la petición (IP y huella del dispositivo)
+type ClientInfo struct {
+	IP          string `json:"ip,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	UserAgent   string `json:"user_agent,omitempty"`
+}
+
+// WithClientInfo retorna un contexto que transporta los datos del cliente de la petición
+func WithClientInfo(ctx context.Context, client ClientInfo) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientInfoFromContext obtiene los datos del cliente guardados en el contexto (si existen)
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	client, ok := ctx.Value(clientContextKey{}).(ClientInfo)
+	return client, ok
+}
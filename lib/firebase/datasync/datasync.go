@@ -0,0 +1,358 @@
+// Package datasync implementa un protocolo de sincronización para clientes offline-first:
+// los clientes descargan los cambios de una colección desde su último token (Pull) y
+// suben sus mutaciones locales (Push), que se aplican con detección de conflictos por
+// updated_at y la política de resolución de la colección.
+package datasync
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// TombstonesCollection colección donde se registran las eliminaciones de las colecciones
+// sincronizadas, para que los clientes también las reciban
+const TombstonesCollection = "sync_tombstones"
+
+// Políticas de resolución de conflictos
+const (
+	LastWriteWins = "last_write_wins" // gana la versión con updated_at más reciente
+	ServerWins    = "server_wins"
+	ClientWins    = "client_wins"
+	CustomMerge   = "custom"       // usa Config.Merger
+	MergeFields   = "merge_fields" // combina campo por campo con firestore.RegisterMergeStrategy
+)
+
+// Merger combina la versión del servidor con la del cliente cuando hay conflicto
+type Merger func(server, client map[string]interface{}) (map[string]interface{}, error)
+
+// Config configuración de una colección sincronizada
+type Config struct {
+	Policy string // por defecto LastWriteWins
+	Merger Merger
+}
+
+// Change cambio entregado por Pull; Deleted indica una eliminación (sin Data)
+type Change struct {
+	ID        string                 `json:"id"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Deleted   bool                   `json:"deleted,omitempty"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// PullResult cambios desde el token y el token para la siguiente descarga
+type PullResult struct {
+	Changes []Change `json:"changes"`
+	Token   string   `json:"token"`
+	HasMore bool     `json:"has_more"`
+}
+
+// Mutation cambio local de un cliente. BaseVersion es el updated_at de la versión del
+// servidor sobre la que se hizo el cambio (cero si el cliente creó el documento) y
+// ClientTime la hora local del cambio, usada por LastWriteWins.
+type Mutation struct {
+	ID          string                 `json:"id"`
+	Delete      bool                   `json:"delete,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	BaseVersion time.Time              `json:"base_version"`
+	ClientTime  time.Time              `json:"client_time"`
+}
+
+// Estados de una mutación subida
+const (
+	StatusApplied  = "applied"
+	StatusMerged   = "merged"   // hubo conflicto y se aplicó el resultado de la política
+	StatusRejected = "rejected" // el servidor conservó su versión (ver Document)
+	StatusFailed   = "failed"
+)
+
+// PushResult resultado de una mutación; Document es la versión final en el servidor
+type PushResult struct {
+	ID       string             `json:"id"`
+	Status   string             `json:"status"`
+	Document *firebase.Document `json:"document,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+var (
+	configsMu sync.RWMutex
+	configs   = map[string]Config{}
+)
+
+// Register habilita la sincronización de una colección y registra el interceptor que
+// guarda las lápidas de sus eliminaciones. Con tenancy por prefijo las lápidas se
+// registran con la ruta física de la colección.
+func Register(collection string, config Config) error {
+	if config.Policy == "" {
+		config.Policy = LastWriteWins
+	}
+	if config.Policy == CustomMerge && config.Merger == nil {
+		return fmt.Errorf("sync policy 'custom' requires a merger")
+	}
+
+	configsMu.Lock()
+	configs[collection] = config
+	configsMu.Unlock()
+	firebase.RegisterInterceptor(firebase.Interceptor{Name: "datasync", After: recordTombstone})
+	return nil
+}
+
+func configFor(collection string) (Config, bool) {
+	configsMu.RLock()
+	defer configsMu.RUnlock()
+	config, ok := configs[collection]
+	return config, ok
+}
+
+// recordTombstone registra las eliminaciones exitosas de las colecciones sincronizadas
+func recordTombstone(ctx context.Context, call *firebase.Call, err error) {
+	if err != nil {
+		return
+	}
+	deleted := call.Operation == firebase.CallFirestoreDelete
+	if op, ok := call.Payload.(*firebase.BatchOperation); ok && call.Operation == firebase.CallFirestoreBatch {
+		deleted = op.Type == "delete"
+	}
+	if !deleted {
+		return
+	}
+	if _, ok := configFor(call.Collection); !ok {
+		return
+	}
+
+	firebase.FirestoreClientFor(ctx, call.Collection).Collection(TombstonesCollection).
+		Doc(tombstoneID(call.Collection, call.DocumentID)).
+		Set(ctx, map[string]interface{}{
+			"collection":  call.Collection,
+			"document_id": call.DocumentID,
+			"deleted_at":  firebase.Now(),
+		})
+}
+
+func tombstoneID(collection, docID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(collection + "/" + docID))
+}
+
+// token posición de un cliente: último updated_at e ID entregados y última lápida
+type token struct {
+	UpdatedAt time.Time `json:"u"`
+	LastID    string    `json:"i,omitempty"`
+	DeletedAt time.Time `json:"d"`
+}
+
+func decodeToken(value string) (token, error) {
+	var t token
+	if value == "" {
+		return t, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(raw, &t)
+	}
+	if err != nil {
+		return t, fmt.Errorf("invalid sync token")
+	}
+	return t, nil
+}
+
+func (t token) encode() string {
+	raw, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Pull retorna hasta limit cambios (documentos modificados y eliminados) de la colección
+// posteriores al token, en orden de updated_at. Un token vacío descarga todo. La
+// consulta pasa por QueryDocuments, por lo que aplican tenancy y políticas.
+func Pull(ctx context.Context, collection, tokenValue string, limit int) (*PullResult, error) {
+	if _, ok := configFor(collection); !ok {
+		return nil, fmt.Errorf("collection '%s' is not registered for sync", collection)
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 500
+	}
+	position, err := decodeToken(tokenValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Documentos con el mismo updated_at que el último entregado se descartan hasta
+	// pasar el último ID (el orden secundario es el ID del documento)
+	options := firebase.QueryOptions{
+		Orders: []firebase.OrderClause{{Field: "updated_at", Direction: "asc"}},
+		Limit:  limit,
+	}
+	if !position.UpdatedAt.IsZero() {
+		options.Filters = []firebase.QueryFilter{firebase.Where("updated_at", firebase.OpGreaterThanOrEqual, position.UpdatedAt)}
+	}
+
+	result := &PullResult{}
+	next := position
+	for {
+		page, err := firestore.QueryDocumentsWithMeta(ctx, collection, options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull changes from '%s': %w", collection, err)
+		}
+		for _, doc := range page.Documents {
+			updatedAt, _ := doc.GetTime("updated_at")
+			if updatedAt.Equal(position.UpdatedAt) && doc.ID <= position.LastID {
+				continue
+			}
+			result.Changes = append(result.Changes, Change{ID: doc.ID, Data: doc.Data, UpdatedAt: updatedAt})
+			next.UpdatedAt, next.LastID = updatedAt, doc.ID
+		}
+		if len(result.Changes) > 0 || !page.Truncated {
+			result.HasMore = page.Truncated
+			break
+		}
+		options.Cursor = page.NextCursor
+	}
+
+	tombstones, err := firestore.QueryDocuments(ctx, TombstonesCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			firebase.Where("collection", firebase.OpEqual, collection),
+			firebase.Where("deleted_at", firebase.OpGreaterThan, position.DeletedAt),
+		},
+		Orders: []firebase.OrderClause{{Field: "deleted_at", Direction: "asc"}},
+		Limit:  limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull deletions from '%s': %w", collection, err)
+	}
+	for _, doc := range tombstones {
+		docID, _ := doc.GetString("document_id")
+		deletedAt, _ := doc.GetTime("deleted_at")
+		result.Changes = append(result.Changes, Change{ID: docID, Deleted: true, UpdatedAt: deletedAt})
+		next.DeletedAt = deletedAt
+	}
+	if len(tombstones) == limit {
+		result.HasMore = true
+	}
+
+	sort.SliceStable(result.Changes, func(i, j int) bool {
+		return result.Changes[i].UpdatedAt.Before(result.Changes[j].UpdatedAt)
+	})
+	result.Token = next.encode()
+	return result, nil
+}
+
+// Push aplica las mutaciones del cliente. Cada una se escribe con AtomicWrite y la
+// precondición de que updated_at no haya cambiado desde la lectura; si la versión del
+// servidor es posterior a BaseVersion se resuelve con la política de la colección.
+func Push(ctx context.Context, collection string, mutations []Mutation) ([]PushResult, error) {
+	config, ok := configFor(collection)
+	if !ok {
+		return nil, fmt.Errorf("collection '%s' is not registered for sync", collection)
+	}
+
+	results := make([]PushResult, len(mutations))
+	for i, mutation := range mutations {
+		results[i] = applyMutation(ctx, collection, config, mutation)
+	}
+	return results, nil
+}
+
+// applyMutation reintenta si otra escritura se adelanta entre la lectura y la escritura
+func applyMutation(ctx context.Context, collection string, config Config, mutation Mutation) PushResult {
+	result := PushResult{ID: mutation.ID}
+	if mutation.ID == "" {
+		result.Status, result.Error = StatusFailed, "mutation requires an id"
+		return result
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		status, err := tryMutation(ctx, collection, config, mutation)
+		var precondition *firebase.PreconditionFailedError
+		if errors.As(err, &precondition) {
+			continue
+		}
+		if err != nil {
+			result.Status, result.Error = StatusFailed, err.Error()
+			return result
+		}
+		result.Status = status
+		if doc, err := firestore.GetDocument(ctx, collection, mutation.ID); err == nil {
+			result.Document = doc
+		}
+		return result
+	}
+	result.Status, result.Error = StatusFailed, "too many concurrent updates"
+	return result
+}
+
+func tryMutation(ctx context.Context, collection string, config Config, mutation Mutation) (string, error) {
+	current, err := firestore.GetDocument(ctx, collection, mutation.ID)
+	if err != nil && !firestore.IsNotFound(err) {
+		return "", err
+	}
+
+	var serverVersion time.Time
+	if current != nil {
+		serverVersion, _ = current.GetTime("updated_at")
+	}
+	conflict := current != nil && serverVersion.After(mutation.BaseVersion)
+
+	data := mutation.Data
+	status := StatusApplied
+	if conflict {
+		status = StatusMerged
+		switch config.Policy {
+		case ServerWins:
+			return StatusRejected, nil
+		case LastWriteWins:
+			if !mutation.ClientTime.After(serverVersion) {
+				return StatusRejected, nil
+			}
+		case CustomMerge:
+			if mutation.Delete {
+				break
+			}
+			if data, err = config.Merger(current.Data, mutation.Data); err != nil {
+				return "", fmt.Errorf("merge failed: %w", err)
+			}
+		case MergeFields:
+			if !mutation.Delete {
+				data = firestore.MergeDocuments(collection, current.Data, mutation.Data)
+			}
+		}
+	}
+
+	op := firebase.BatchOperation{Collection: collection, DocumentID: mutation.ID}
+	switch {
+	case mutation.Delete && current == nil:
+		return StatusApplied, nil
+	case mutation.Delete:
+		op.Type = "delete"
+	case current == nil:
+		op.Type, op.Data = "create", copyData(data)
+	default:
+		op.Type, op.Data = "update", copyData(data)
+	}
+	if current != nil {
+		op.Preconditions = []firebase.QueryFilter{firebase.Where("updated_at", firebase.OpEqual, serverVersion)}
+	}
+
+	if _, err := firestore.AtomicWrite(ctx, []firebase.BatchOperation{op}); err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// copyData evita que el paquete firestore agregue timestamps al mapa del llamador
+func copyData(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if k == "created_at" || k == "updated_at" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
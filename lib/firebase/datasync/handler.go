@@ -0,0 +1,58 @@
+package datasync
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/andrescris/firestore/lib/firebase/auth"
+)
+
+// pushRequest cuerpo de POST
+type pushRequest struct {
+	Collection string     `json:"collection"`
+	Mutations  []Mutation `json:"mutations"`
+}
+
+// Handler expone el protocolo por HTTP con la sesión de auth.SessionMiddleware (requerida):
+//
+//	GET  ?collection=notes&token=...&limit=200   -> PullResult
+//	POST {"collection": "notes", "mutations": [...]} -> {"results": [...]}
+func Handler() http.Handler {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			query := r.URL.Query()
+			limit, _ := strconv.Atoi(query.Get("limit"))
+			result, err := Pull(r.Context(), query.Get("collection"), query.Get("token"), limit)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, result)
+
+		case http.MethodPost:
+			var request pushRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cuerpo JSON inválido"})
+				return
+			}
+			results, err := Push(r.Context(), request.Collection, request.Mutations)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+
+		default:
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+		}
+	})
+	return auth.SessionMiddleware(true)(handler)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
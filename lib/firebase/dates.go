@@ -0,0 +1,43 @@
+package firebase
+
+import "time"
+
+// StartOfDay trunca t al inicio del día en la zona horaria loc (UTC si loc es nil)
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// StartOfWeek trunca t al lunes de su semana en la zona horaria loc
+func StartOfWeek(t time.Time, loc *time.Location) time.Time {
+	day := StartOfDay(t, loc)
+	offset := (int(day.Weekday()) + 6) % 7 // lunes = 0
+	return day.AddDate(0, 0, -offset)
+}
+
+// StartOfMonth trunca t al primer día de su mes en la zona horaria loc
+func StartOfMonth(t time.Time, loc *time.Location) time.Time {
+	day := StartOfDay(t, loc)
+	return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+}
+
+// DayRange retorna el intervalo [inicio del día, inicio del día siguiente)
+func DayRange(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	from := StartOfDay(t, loc)
+	return from, from.AddDate(0, 0, 1)
+}
+
+// WeekRange retorna el intervalo [lunes, lunes siguiente)
+func WeekRange(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	from := StartOfWeek(t, loc)
+	return from, from.AddDate(0, 0, 7)
+}
+
+// MonthRange retorna el intervalo [primer día del mes, primer día del mes siguiente)
+func MonthRange(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	from := StartOfMonth(t, loc)
+	return from, from.AddDate(0, 1, 0)
+}
@@ -0,0 +1,274 @@
+package firebase
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DecodeOptions opciones de Document.DecodeInto
+type DecodeOptions struct {
+	// Strict falla si el documento tiene campos que el struct no declara o si falta algún
+	// campo del struct (salvo los marcados con omitempty)
+	Strict bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// DecodeInto copia los datos del documento en target (puntero a struct) usando las
+// etiquetas `firestore:"nombre"` igual que el SDK: "-" ignora el campo, omitempty lo hace
+// opcional en modo estricto y los structs embebidos sin etiqueta se aplanan. Convierte
+// int64/float64 entre sí cuando no hay pérdida y decodifica mapas anidados en structs.
+func (d *Document) DecodeInto(target interface{}, options DecodeOptions) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode target must be a non-nil pointer to a struct, got %T", target)
+	}
+	var data map[string]interface{}
+	var id string
+	if d != nil {
+		data, id = d.Data, d.ID
+	}
+	if err := decodeStruct(value.Elem(), data, "", options); err != nil {
+		if decodeErr, ok := err.(*DecodeError); ok {
+			decodeErr.DocumentID = id
+		}
+		return err
+	}
+	return nil
+}
+
+// structField campo de un struct con su nombre en Firestore
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields lista los campos decodificables, aplanando los structs embebidos
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("firestore")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.IsExported() {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && embedded != timeType {
+				for _, inner := range structFields(embedded) {
+					inner.index = append([]int{i}, inner.index...)
+					fields = append(fields, inner)
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, structField{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+	return fields
+}
+
+func decodeStruct(dst reflect.Value, data map[string]interface{}, path string, options DecodeOptions) error {
+	fields := structFields(dst.Type())
+	known := make(map[string]bool, len(fields))
+
+	for _, field := range fields {
+		known[field.name] = true
+		raw, ok := data[field.name]
+		if !ok {
+			if options.Strict && !field.omitEmpty {
+				return &DecodeError{Field: joinPath(path, field.name), Reason: "missing field"}
+			}
+			continue
+		}
+		if err := decodeValue(fieldByIndexAlloc(dst, field.index), raw, joinPath(path, field.name), options); err != nil {
+			return err
+		}
+	}
+
+	if options.Strict {
+		var unknown []string
+		for key := range data {
+			if !known[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return &DecodeError{Field: joinPath(path, unknown[0]), Reason: "unknown field"}
+		}
+	}
+	return nil
+}
+
+func decodeValue(dst reflect.Value, raw interface{}, path string, options DecodeOptions) error {
+	if raw == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	mismatch := func() error {
+		return &DecodeError{Field: path, Reason: fmt.Sprintf("cannot decode %T into %s", raw, dst.Type())}
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		value := reflect.New(dst.Type().Elem())
+		if err := decodeValue(value.Elem(), raw, path, options); err != nil {
+			return err
+		}
+		dst.Set(value)
+		return nil
+
+	case reflect.Interface:
+		value := reflect.ValueOf(raw)
+		if !value.Type().AssignableTo(dst.Type()) {
+			return mismatch()
+		}
+		dst.Set(value)
+		return nil
+
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			t, ok := raw.(time.Time)
+			if !ok {
+				return mismatch()
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		return decodeStruct(dst, m, path, options)
+
+	case reflect.Map:
+		m, ok := raw.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		result := reflect.MakeMapWithSize(dst.Type(), len(m))
+		for key, item := range m {
+			value := reflect.New(dst.Type().Elem()).Elem()
+			if err := decodeValue(value, item, joinPath(path, key), options); err != nil {
+				return err
+			}
+			result.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), value)
+		}
+		dst.Set(result)
+		return nil
+
+	case reflect.Slice:
+		if b, ok := raw.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(b)
+			return nil
+		}
+		items, ok := raw.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		result := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(result.Index(i), item, fmt.Sprintf("%s[%d]", path, i), options); err != nil {
+				return err
+			}
+		}
+		dst.Set(result)
+		return nil
+
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetString(s)
+		return nil
+
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch v := raw.(type) {
+		case int64:
+			n = v
+		case float64:
+			if v != math.Trunc(v) {
+				return mismatch()
+			}
+			n = int64(v)
+		default:
+			return mismatch()
+		}
+		if dst.OverflowInt(n) {
+			return &DecodeError{Field: path, Reason: fmt.Sprintf("value %d overflows %s", n, dst.Type())}
+		}
+		dst.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := raw.(int64)
+		if !ok || n < 0 || dst.OverflowUint(uint64(n)) {
+			return mismatch()
+		}
+		dst.SetUint(uint64(n))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch v := raw.(type) {
+		case float64:
+			dst.SetFloat(v)
+		case int64:
+			dst.SetFloat(float64(v))
+		default:
+			return mismatch()
+		}
+		return nil
+	}
+	return mismatch()
+}
+
+// fieldByIndexAlloc es como FieldByIndex pero crea los structs embebidos por puntero
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
@@ -0,0 +1,406 @@
+// Package doctor diagnostica datos obsoletos o inconsistentes y arma un plan de
+// corrección que se puede revisar antes de aplicarlo:
+//
+//   - expired_sessions: sesiones de user_sessions vencidas (se eliminan)
+//   - expired_otps: códigos de user_otps y cambios de correo vencidos (se eliminan)
+//   - orphaned_claims: documentos de user_claims sin usuario en Auth (se eliminan)
+//   - missing_created_at: documentos sin created_at (se completa con la fecha de creación
+//     del documento en Firestore)
+//   - oversized: documentos cerca del límite de 1 MiB (solo se reportan)
+//
+// Lo usa el comando firestorectl doctor.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"firebase.google.com/go/v4/auth"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Verificaciones disponibles
+const (
+	CheckExpiredSessions  = "expired_sessions"
+	CheckExpiredOTPs      = "expired_otps"
+	CheckOrphanedClaims   = "orphaned_claims"
+	CheckMissingCreatedAt = "missing_created_at"
+	CheckOversized        = "oversized"
+)
+
+// AllChecks verificaciones que se ejecutan por defecto
+var AllChecks = []string{CheckExpiredSessions, CheckExpiredOTPs, CheckOrphanedClaims, CheckMissingCreatedAt, CheckOversized}
+
+// Acciones de corrección
+const (
+	FixDelete       = "delete"
+	FixSetCreatedAt = "set_created_at"
+	FixNone         = "none" // requiere revisión manual
+)
+
+// expiringCollections colecciones con un campo expires_at por verificación
+var expiringCollections = map[string][]string{
+	CheckExpiredSessions: {"user_sessions"},
+	CheckExpiredOTPs:     {"user_otps", "email_changes"},
+}
+
+// Options configuración del diagnóstico
+type Options struct {
+	Checks []string // por defecto AllChecks
+	// Collections colecciones de primer nivel revisadas por missing_created_at y
+	// oversized (por defecto todas)
+	Collections []string
+	// SizeThreshold tamaño estimado a partir del cual un documento se reporta (por
+	// defecto el 90% de firestore.MaxDocumentSize)
+	SizeThreshold int
+	// Limit máximo de hallazgos por verificación (0 = sin límite)
+	Limit int
+}
+
+// Finding problema encontrado y su corrección propuesta
+type Finding struct {
+	Check      string    `json:"check"`
+	Collection string    `json:"collection"`
+	DocumentID string    `json:"document_id"`
+	Problem    string    `json:"problem"`
+	Fix        string    `json:"fix"`
+	CreatedAt  time.Time `json:"created_at,omitempty"` // valor propuesto para set_created_at
+}
+
+// Plan resultado del diagnóstico
+type Plan struct {
+	Findings  []Finding      `json:"findings"`
+	Scanned   map[string]int `json:"scanned"` // documentos revisados por verificación
+	Truncated []string       `json:"truncated,omitempty"`
+
+	counts map[string]int
+}
+
+// Counts hallazgos por verificación
+func (p *Plan) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, finding := range p.Findings {
+		counts[finding.Check]++
+	}
+	return counts
+}
+
+// ApplyReport resultado de aplicar un plan
+type ApplyReport struct {
+	Applied int               `json:"applied"`
+	Skipped int               `json:"skipped"` // hallazgos sin corrección automática
+	Stale   int               `json:"stale"`   // hallazgos que ya no aplican al re-verificarlos
+	Failed  map[string]string `json:"failed,omitempty"`
+}
+
+// Diagnose ejecuta las verificaciones y retorna el plan de corrección sin modificar nada
+func Diagnose(ctx context.Context, options Options) (*Plan, error) {
+	checks := options.Checks
+	if len(checks) == 0 {
+		checks = AllChecks
+	}
+	if options.SizeThreshold <= 0 {
+		options.SizeThreshold = firestore.MaxDocumentSize * 9 / 10
+	}
+
+	plan := &Plan{Scanned: map[string]int{}, counts: map[string]int{}}
+	var scanCollections bool
+	for _, check := range checks {
+		var err error
+		switch check {
+		case CheckExpiredSessions, CheckExpiredOTPs:
+			err = diagnoseExpired(ctx, plan, check, options.Limit)
+		case CheckOrphanedClaims:
+			err = diagnoseOrphanedClaims(ctx, plan, options.Limit)
+		case CheckMissingCreatedAt, CheckOversized:
+			scanCollections = true
+		default:
+			return nil, fmt.Errorf("unknown doctor check '%s'", check)
+		}
+		if err != nil {
+			return plan, err
+		}
+	}
+	if scanCollections {
+		if err := diagnoseDocuments(ctx, plan, checks, options); err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+// Apply aplica las correcciones del plan a través de los wrappers del paquete, por lo que
+// se respetan las retenciones legales y las colecciones inmutables. Cada hallazgo se
+// vuelve a verificar antes de corregirlo: el plan puede haberse revisado mucho después
+// del diagnóstico (una sesión extendida, un usuario recreado, un created_at escrito).
+func Apply(ctx context.Context, plan *Plan) *ApplyReport {
+	report := &ApplyReport{Failed: map[string]string{}}
+	recreated, err := recreatedUsers(ctx, plan.Findings)
+	if err != nil {
+		report.Failed["auth"] = err.Error()
+		return report
+	}
+
+	for _, finding := range plan.Findings {
+		if finding.Fix != FixDelete && finding.Fix != FixSetCreatedAt {
+			report.Skipped++
+			continue
+		}
+		applies, err := stillApplies(ctx, finding, recreated)
+		if err != nil {
+			report.Failed[finding.Collection+"/"+finding.DocumentID] = err.Error()
+			continue
+		}
+		if !applies {
+			report.Stale++
+			continue
+		}
+
+		switch finding.Fix {
+		case FixDelete:
+			err = firestore.DeleteDocument(ctx, finding.Collection, finding.DocumentID)
+			if firestore.IsNotFound(err) {
+				err = nil
+			}
+		case FixSetCreatedAt:
+			err = firestore.UpdateDocumentFields(ctx, finding.Collection, finding.DocumentID, []gfirestore.Update{
+				{Path: "created_at", Value: finding.CreatedAt},
+			})
+		}
+		if err != nil {
+			report.Failed[finding.Collection+"/"+finding.DocumentID] = err.Error()
+			continue
+		}
+		report.Applied++
+	}
+	if len(report.Failed) == 0 {
+		report.Failed = nil
+	}
+	return report
+}
+
+// stillApplies relee el documento del hallazgo y comprueba que el problema persiste
+func stillApplies(ctx context.Context, finding Finding, recreated map[string]bool) (bool, error) {
+	if finding.Check == CheckOrphanedClaims {
+		return !recreated[finding.DocumentID], nil
+	}
+
+	snap, err := firebase.GetFirestoreClient().Collection(finding.Collection).Doc(finding.DocumentID).Get(ctx)
+	if firestore.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to re-check finding: %w", err)
+	}
+	switch finding.Check {
+	case CheckExpiredSessions, CheckExpiredOTPs:
+		expiresAt, ok := snap.Data()["expires_at"].(time.Time)
+		return ok && expiresAt.Before(firebase.Now()), nil
+	case CheckMissingCreatedAt:
+		_, ok := snap.Data()["created_at"]
+		return !ok, nil
+	}
+	return true, nil
+}
+
+// recreatedUsers consulta en Auth, en lotes de 100, los UID de los hallazgos de
+// orphaned_claims y retorna los que ahora existen
+func recreatedUsers(ctx context.Context, findings []Finding) (map[string]bool, error) {
+	var identifiers []auth.UserIdentifier
+	for _, finding := range findings {
+		if finding.Check == CheckOrphanedClaims {
+			identifiers = append(identifiers, auth.UIDIdentifier{UID: finding.DocumentID})
+		}
+	}
+
+	recreated := map[string]bool{}
+	for start := 0; start < len(identifiers); start += 100 {
+		end := min(start+100, len(identifiers))
+		result, err := firebase.GetAuthClient().GetUsers(ctx, identifiers[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up users: %w", err)
+		}
+		for _, user := range result.Users {
+			recreated[user.UID] = true
+		}
+	}
+	return recreated, nil
+}
+
+func diagnoseExpired(ctx context.Context, plan *Plan, check string, limit int) error {
+	now := firebase.Now()
+	for _, collection := range expiringCollections[check] {
+		iter := firebase.GetFirestoreClient().Collection(collection).Where("expires_at", "<", now).Select("expires_at").Documents(ctx)
+		err := each(iter, func(snap *gfirestore.DocumentSnapshot) bool {
+			plan.Scanned[check]++
+			expiresAt, _ := snap.Data()["expires_at"].(time.Time)
+			return plan.add(Finding{
+				Check:      check,
+				Collection: collection,
+				DocumentID: snap.Ref.ID,
+				Problem:    "expired at " + expiresAt.Format(time.RFC3339),
+				Fix:        FixDelete,
+			}, limit)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// diagnoseOrphanedClaims busca los UID de user_claims en Auth en lotes de 100
+func diagnoseOrphanedClaims(ctx context.Context, plan *Plan, limit int) error {
+	var pending []string
+	flush := func() (bool, error) {
+		if len(pending) == 0 {
+			return true, nil
+		}
+		identifiers := make([]auth.UserIdentifier, len(pending))
+		for i, uid := range pending {
+			identifiers[i] = auth.UIDIdentifier{UID: uid}
+		}
+		pending = pending[:0]
+		result, err := firebase.GetAuthClient().GetUsers(ctx, identifiers)
+		if err != nil {
+			return false, fmt.Errorf("failed to look up users: %w", err)
+		}
+		for _, missing := range result.NotFound {
+			identifier, ok := missing.(auth.UIDIdentifier)
+			if !ok {
+				continue
+			}
+			if !plan.add(Finding{
+				Check:      CheckOrphanedClaims,
+				Collection: "user_claims",
+				DocumentID: identifier.UID,
+				Problem:    "no Auth user with this uid",
+				Fix:        FixDelete,
+			}, limit) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	var flushErr error
+	iter := firebase.GetFirestoreClient().Collection("user_claims").Select().Documents(ctx)
+	err := each(iter, func(snap *gfirestore.DocumentSnapshot) bool {
+		plan.Scanned[CheckOrphanedClaims]++
+		pending = append(pending, snap.Ref.ID)
+		if len(pending) < 100 {
+			return true
+		}
+		more, err := flush()
+		flushErr = err
+		return more && err == nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan user_claims: %w", err)
+	}
+	if flushErr != nil {
+		return flushErr
+	}
+	_, err = flush()
+	return err
+}
+
+// diagnoseDocuments recorre las colecciones una sola vez para missing_created_at y
+// oversized
+func diagnoseDocuments(ctx context.Context, plan *Plan, checks []string, options Options) error {
+	missing, oversized := contains(checks, CheckMissingCreatedAt), contains(checks, CheckOversized)
+	collections := options.Collections
+	if len(collections) == 0 {
+		refs, err := firebase.GetFirestoreClient().Collections(ctx).GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to list collections: %w", err)
+		}
+		for _, ref := range refs {
+			collections = append(collections, ref.ID)
+		}
+		sort.Strings(collections)
+	}
+
+	for _, collection := range collections {
+		iter := firebase.GetFirestoreClient().Collection(collection).Documents(ctx)
+		err := each(iter, func(snap *gfirestore.DocumentSnapshot) bool {
+			data := snap.Data()
+			if missing {
+				plan.Scanned[CheckMissingCreatedAt]++
+				if _, ok := data["created_at"]; !ok {
+					plan.add(Finding{
+						Check:      CheckMissingCreatedAt,
+						Collection: collection,
+						DocumentID: snap.Ref.ID,
+						Problem:    "missing created_at",
+						Fix:        FixSetCreatedAt,
+						CreatedAt:  snap.CreateTime,
+					}, options.Limit)
+				}
+			}
+			if oversized {
+				plan.Scanned[CheckOversized]++
+				if size := firestore.EstimateDocumentSize(collection, snap.Ref.ID, data); size >= options.SizeThreshold {
+					plan.add(Finding{
+						Check:      CheckOversized,
+						Collection: collection,
+						DocumentID: snap.Ref.ID,
+						Problem:    fmt.Sprintf("estimated size %d bytes (limit %d)", size, firestore.MaxDocumentSize),
+						Fix:        FixNone,
+					}, options.Limit)
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// add agrega el hallazgo si no se alcanzó el límite de su verificación; retorna false
+// cuando el límite se alcanzó
+func (p *Plan) add(finding Finding, limit int) bool {
+	if limit > 0 && p.counts[finding.Check] >= limit {
+		if !contains(p.Truncated, finding.Check) {
+			p.Truncated = append(p.Truncated, finding.Check)
+		}
+		return false
+	}
+	p.Findings = append(p.Findings, finding)
+	p.counts[finding.Check]++
+	return true
+}
+
+// each recorre el iterador hasta agotarlo o hasta que fn retorne false
+func each(iter *gfirestore.DocumentIterator, fn func(*gfirestore.DocumentSnapshot) bool) error {
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !fn(snap) {
+			return nil
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package firebase
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Get retorna el valor de field. Admite rutas con puntos ("address.city") para campos
+// anidados en mapas.
+func (d *Document) Get(field string) (interface{}, bool) {
+	if d == nil || d.Data == nil {
+		return nil, false
+	}
+	var current interface{} = d.Data
+	for _, part := range strings.Split(field, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// GetString retorna field como string
+func (d *Document) GetString(field string) (string, bool) {
+	value, _ := d.Get(field)
+	s, ok := value.(string)
+	return s, ok
+}
+
+// GetBool retorna field como bool
+func (d *Document) GetBool(field string) (bool, bool) {
+	value, _ := d.Get(field)
+	b, ok := value.(bool)
+	return b, ok
+}
+
+// GetInt retorna field como int64. Firestore devuelve los enteros como int64, pero los
+// datos escritos desde JavaScript pueden llegar como float64; se aceptan si no tienen
+// parte decimal.
+func (d *Document) GetInt(field string) (int64, bool) {
+	value, _ := d.Get(field)
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case float64:
+		// float64(math.MaxInt64) es 2^63, que ya no cabe en int64
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// GetFloat retorna field como float64 (acepta enteros)
+func (d *Document) GetFloat(field string) (float64, bool) {
+	value, _ := d.Get(field)
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// GetTime retorna field como time.Time en UTC. Acepta timestamps de Firestore y cadenas
+// RFC 3339 (fechas guardadas como texto por otros clientes).
+func (d *Document) GetTime(field string) (time.Time, bool) {
+	value, _ := d.Get(field)
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC(), true
+	case *time.Time:
+		if v != nil {
+			return v.UTC(), true
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// GetMap retorna field como mapa
+func (d *Document) GetMap(field string) (map[string]interface{}, bool) {
+	value, _ := d.Get(field)
+	m, ok := value.(map[string]interface{})
+	return m, ok
+}
+
+// GetStringSlice retorna field como []string. Falla si algún elemento no es string.
+func (d *Document) GetStringSlice(field string) ([]string, bool) {
+	value, _ := d.Get(field)
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		result := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			result[i] = s
+		}
+		return result, true
+	}
+	return nil, false
+}
@@ -1,13 +1,29 @@
 package firebase
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Errores globales
 var (
-	ErrMissingCredentials = &MissingCredentialsError{}
-	ErrDocumentNotFound   = &DocumentNotFoundError{}
-	ErrFileNotFound       = &FileNotFoundError{}
-	ErrUserNotFound       = &UserNotFoundError{}
+	ErrMissingCredentials  = &MissingCredentialsError{}
+	ErrDocumentNotFound    = &DocumentNotFoundError{}
+	ErrFileNotFound        = &FileNotFoundError{}
+	ErrUserNotFound        = &UserNotFoundError{}
+	ErrUnauthenticated     = &UnauthenticatedError{}
+	ErrReadOnly            = &ReadOnlyError{}
+	ErrMissingIndex        = &MissingIndexError{}
+	ErrIntegrity           = &IntegrityError{}
+	ErrImmutableCollection = &ImmutableCollectionError{}
+	ErrLegalHold           = &LegalHoldError{}
+	ErrIPBlocked           = &IPBlockedError{}
+	ErrEmailUndeliverable  = &EmailUndeliverableError{}
+	ErrUsername            = &UsernameError{}
+	ErrConsentRequired     = &ConsentRequiredError{}
+	ErrAPIKey              = &APIKeyError{}
+	ErrRequestSignature    = &RequestSignatureError{}
 )
 
 // MissingCredentialsError cuando no se encuentran las credenciales
@@ -44,4 +60,401 @@ type UserNotFoundError struct {
 
 func (e *UserNotFoundError) Error() string {
 	return fmt.Sprintf("user not found: %s", e.Identifier)
-}
\ No newline at end of file
+}
+
+// InvalidStateTransitionError cuando una transición de estado de cuenta no está permitida
+type InvalidStateTransitionError struct {
+	UID  string
+	From string
+	To   string
+}
+
+func (e *InvalidStateTransitionError) Error() string {
+	return fmt.Sprintf("invalid account state transition for user '%s': %s -> %s", e.UID, e.From, e.To)
+}
+
+// UnauthenticatedError cuando una operación requiere una sesión y no se proporcionó
+type UnauthenticatedError struct{}
+
+func (e *UnauthenticatedError) Error() string {
+	return "a valid session is required for this operation"
+}
+
+// ReauthenticationRequiredError cuando una operación sensible exige una verificación de
+// identidad más reciente que la de la sesión
+type ReauthenticationRequiredError struct {
+	AuthTime time.Time
+	MaxAge   time.Duration
+}
+
+func (e *ReauthenticationRequiredError) Error() string {
+	return fmt.Sprintf("recent authentication required: last authenticated at %s, max age %s", e.AuthTime.Format(time.RFC3339), e.MaxAge)
+}
+
+// SessionBindingError cuando la petición no coincide con el cliente que creó la sesión
+type SessionBindingError struct {
+	Reason string
+}
+
+func (e *SessionBindingError) Error() string {
+	return fmt.Sprintf("session binding mismatch: %s", e.Reason)
+}
+
+// DecodeError cuando los datos de un documento no se pueden decodificar en un struct
+type DecodeError struct {
+	DocumentID string
+	Field      string
+	Reason     string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode document '%s': field '%s': %s", e.DocumentID, e.Field, e.Reason)
+}
+
+// ReadOnlyError cuando se intenta escribir durante el modo solo lectura. errors.Is(err,
+// ErrReadOnly) es verdadero para cualquier colección.
+type ReadOnlyError struct {
+	Collection string
+	Reason     string
+}
+
+func (e *ReadOnlyError) Error() string {
+	msg := "writes are disabled (read-only mode)"
+	if e.Collection != "" {
+		msg = fmt.Sprintf("writes to collection '%s' are disabled (read-only mode)", e.Collection)
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// Is permite comparar con ErrReadOnly
+func (e *ReadOnlyError) Is(target error) bool {
+	_, ok := target.(*ReadOnlyError)
+	return ok
+}
+
+// TenantRequiredError cuando una colección particionada por tenant se usa sin tenant en el
+// contexto
+type TenantRequiredError struct {
+	Collection string
+}
+
+func (e *TenantRequiredError) Error() string {
+	return fmt.Sprintf("collection '%s' is tenant-scoped and no tenant was found in the context", e.Collection)
+}
+
+// WebhookSignatureError cuando la firma de un webhook entrante no es válida
+type WebhookSignatureError struct {
+	Provider string
+	Reason   string
+}
+
+func (e *WebhookSignatureError) Error() string {
+	return fmt.Sprintf("invalid %s webhook signature: %s", e.Provider, e.Reason)
+}
+
+// BatchError cuando una o más operaciones de un lote en modo BestEffort fallaron (el
+// detalle está en los resultados por operación)
+type BatchError struct {
+	Failed int
+	Total  int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d batch operations failed", e.Failed, e.Total)
+}
+
+// PreconditionFailedError cuando un documento no cumple la precondición de una escritura
+// atómica
+type PreconditionFailedError struct {
+	Collection string
+	DocumentID string
+	Reason     string
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("precondition failed for document '%s' in collection '%s': %s", e.DocumentID, e.Collection, e.Reason)
+}
+
+// PermissionDeniedError cuando una política de acceso rechaza la operación
+type PermissionDeniedError struct {
+	Collection string
+	DocumentID string
+	Operation  Operation
+}
+
+func (e *PermissionDeniedError) Error() string {
+	return fmt.Sprintf("permission denied: %s on document '%s' in collection '%s'", e.Operation, e.DocumentID, e.Collection)
+}
+
+// InvalidOperatorError cuando un filtro usa un operador no soportado
+type InvalidOperatorError struct {
+	Field    string
+	Operator Operator
+}
+
+func (e *InvalidOperatorError) Error() string {
+	allowed := make([]string, len(ValidOperators))
+	for i, op := range ValidOperators {
+		allowed[i] = fmt.Sprintf("%q", op)
+	}
+	return fmt.Sprintf("invalid operator %q for field '%s': allowed operators are %s", e.Operator, e.Field, strings.Join(allowed, ", "))
+}
+
+// DisjunctionLimitError cuando un filtro disyuntivo excede el límite de valores de Firestore
+type DisjunctionLimitError struct {
+	Field    string
+	Operator Operator
+	Count    int
+	Max      int
+}
+
+func (e *DisjunctionLimitError) Error() string {
+	return fmt.Sprintf("filter %q on field '%s' has %d values, maximum is %d", e.Operator, e.Field, e.Count, e.Max)
+}
+
+// InequalityFieldsError cuando una consulta mezcla filtros de desigualdad sobre campos distintos
+type InequalityFieldsError struct {
+	Fields []string
+}
+
+func (e *InequalityFieldsError) Error() string {
+	return fmt.Sprintf("inequality filters must use a single field, got: %s", strings.Join(e.Fields, ", "))
+}
+
+// ValidationIssue describe un problema de validación en un campo concreto
+type ValidationIssue struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError cuando los datos de un documento no pasan la validación previa a la escritura
+type ValidationError struct {
+	Collection string
+	DocumentID string
+	Issues     []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		parts[i] = fmt.Sprintf("%s: %s", issue.Field, issue.Reason)
+	}
+	return fmt.Sprintf("invalid document '%s' in collection '%s': %s", e.DocumentID, e.Collection, strings.Join(parts, "; "))
+}
+
+// MissingIndexError cuando una consulta requiere un índice compuesto que no existe.
+// CreateURL es el enlace de la consola para crearlo; errors.Is(err, ErrMissingIndex)
+// es verdadero para cualquier colección.
+type MissingIndexError struct {
+	Collection string
+	CreateURL  string
+	Err        error
+}
+
+func (e *MissingIndexError) Error() string {
+	msg := fmt.Sprintf("query on collection '%s' requires a composite index", e.Collection)
+	if e.CreateURL != "" {
+		msg += "; create it at " + e.CreateURL
+	}
+	return msg
+}
+
+func (e *MissingIndexError) Unwrap() error {
+	return e.Err
+}
+
+// Is permite comparar con ErrMissingIndex
+func (e *MissingIndexError) Is(target error) bool {
+	_, ok := target.(*MissingIndexError)
+	return ok
+}
+
+// QueryStringError cuando un parámetro de ParseQueryString no es válido
+type QueryStringError struct {
+	Parameter string
+	Reason    string
+}
+
+func (e *QueryStringError) Error() string {
+	return fmt.Sprintf("invalid query parameter '%s': %s", e.Parameter, e.Reason)
+}
+
+// IntegrityError cuando el checksum guardado de un documento no coincide con su
+// contenido (o falta). errors.Is(err, ErrIntegrity) es verdadero para cualquier documento.
+type IntegrityError struct {
+	Collection string
+	DocumentID string
+	Expected   string
+	Actual     string
+}
+
+func (e *IntegrityError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("document '%s' in collection '%s' has no integrity checksum", e.DocumentID, e.Collection)
+	}
+	return fmt.Sprintf("document '%s' in collection '%s' failed integrity check: expected %s, got %s", e.DocumentID, e.Collection, e.Expected, e.Actual)
+}
+
+// Is permite comparar con ErrIntegrity
+func (e *IntegrityError) Is(target error) bool {
+	_, ok := target.(*IntegrityError)
+	return ok
+}
+
+// ImmutableCollectionError cuando se intenta modificar o eliminar documentos de una
+// colección de solo inserción. errors.Is(err, ErrImmutableCollection) es verdadero para
+// cualquier colección.
+type ImmutableCollectionError struct {
+	Collection string
+	Operation  string
+}
+
+func (e *ImmutableCollectionError) Error() string {
+	return fmt.Sprintf("collection '%s' is append-only: %s is not allowed", e.Collection, e.Operation)
+}
+
+// Is permite comparar con ErrImmutableCollection
+func (e *ImmutableCollectionError) Is(target error) bool {
+	_, ok := target.(*ImmutableCollectionError)
+	return ok
+}
+
+// LegalHoldError cuando se intenta eliminar un documento bajo retención legal.
+// errors.Is(err, ErrLegalHold) es verdadero para cualquier documento.
+type LegalHoldError struct {
+	Collection string
+	DocumentID string
+	HoldIDs    []string
+}
+
+func (e *LegalHoldError) Error() string {
+	return fmt.Sprintf("document '%s' in collection '%s' is under legal hold (%s)", e.DocumentID, e.Collection, strings.Join(e.HoldIDs, ", "))
+}
+
+// Is permite comparar con ErrLegalHold
+func (e *LegalHoldError) Is(target error) bool {
+	_, ok := target.(*LegalHoldError)
+	return ok
+}
+
+// IPBlockedError cuando la IP del cliente está en la lista de bloqueo.
+// errors.Is(err, ErrIPBlocked) es verdadero para cualquier IP.
+type IPBlockedError struct {
+	IP        string
+	Range     string
+	ExpiresAt time.Time
+}
+
+func (e *IPBlockedError) Error() string {
+	if e.ExpiresAt.IsZero() {
+		return fmt.Sprintf("ip '%s' is blocked (range %s)", e.IP, e.Range)
+	}
+	return fmt.Sprintf("ip '%s' is blocked (range %s) until %s", e.IP, e.Range, e.ExpiresAt.Format(time.RFC3339))
+}
+
+// Is permite comparar con ErrIPBlocked
+func (e *IPBlockedError) Is(target error) bool {
+	_, ok := target.(*IPBlockedError)
+	return ok
+}
+
+// EmailUndeliverableError cuando una dirección está suprimida por rebotes o quejas.
+// errors.Is(err, ErrEmailUndeliverable) es verdadero para cualquier dirección.
+type EmailUndeliverableError struct {
+	Email  string
+	Reason string
+}
+
+func (e *EmailUndeliverableError) Error() string {
+	return fmt.Sprintf("email '%s' is undeliverable (%s)", e.Email, e.Reason)
+}
+
+// Is permite comparar con ErrEmailUndeliverable
+func (e *EmailUndeliverableError) Is(target error) bool {
+	_, ok := target.(*EmailUndeliverableError)
+	return ok
+}
+
+// UsernameError cuando no se puede asignar un nombre de usuario. Reason es "invalid",
+// "reserved", "taken" o "cooldown"; errors.Is(err, ErrUsername) es verdadero para
+// cualquiera.
+type UsernameError struct {
+	Username string
+	Reason   string
+	RetryAt  time.Time // solo con "cooldown"
+}
+
+func (e *UsernameError) Error() string {
+	if e.Reason == "cooldown" {
+		return fmt.Sprintf("username cannot be changed until %s", e.RetryAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("username '%s' is not available (%s)", e.Username, e.Reason)
+}
+
+// Is permite comparar con ErrUsername
+func (e *UsernameError) Is(target error) bool {
+	_, ok := target.(*UsernameError)
+	return ok
+}
+
+// ConsentRequiredError cuando el usuario debe aceptar la versión vigente de uno o más
+// documentos legales. errors.Is(err, ErrConsentRequired) es verdadero para cualquiera.
+type ConsentRequiredError struct {
+	UID   string
+	Kinds []string
+}
+
+func (e *ConsentRequiredError) Error() string {
+	return fmt.Sprintf("user '%s' must accept the current version of: %s", e.UID, strings.Join(e.Kinds, ", "))
+}
+
+// Is permite comparar con ErrConsentRequired
+func (e *ConsentRequiredError) Is(target error) bool {
+	_, ok := target.(*ConsentRequiredError)
+	return ok
+}
+
+// APIKeyError cuando una clave de API no es válida o agotó su cuota. Reason es
+// "invalid", "revoked", "expired" o "quota"; errors.Is(err, ErrAPIKey) es verdadero para
+// cualquiera.
+type APIKeyError struct {
+	KeyID   string
+	Reason  string
+	RetryAt time.Time // solo con "quota"
+}
+
+func (e *APIKeyError) Error() string {
+	if e.KeyID == "" {
+		return fmt.Sprintf("api key is not valid (%s)", e.Reason)
+	}
+	return fmt.Sprintf("api key '%s' is not valid (%s)", e.KeyID, e.Reason)
+}
+
+// Is permite comparar con ErrAPIKey
+func (e *APIKeyError) Is(target error) bool {
+	_, ok := target.(*APIKeyError)
+	return ok
+}
+
+// RequestSignatureError cuando una petición entre servicios no tiene una firma válida.
+// errors.Is(err, ErrRequestSignature) es verdadero para cualquier motivo.
+type RequestSignatureError struct {
+	ClientID string
+	Reason   string
+}
+
+func (e *RequestSignatureError) Error() string {
+	if e.ClientID == "" {
+		return fmt.Sprintf("invalid request signature: %s", e.Reason)
+	}
+	return fmt.Sprintf("invalid request signature from client '%s': %s", e.ClientID, e.Reason)
+}
+
+// Is permite comparar con ErrRequestSignature
+func (e *RequestSignatureError) Is(target error) bool {
+	_, ok := target.(*RequestSignatureError)
+	return ok
+}
@@ -0,0 +1,132 @@
+package firebase
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Fault falla simulada para pruebas de resiliencia. Operation y Collection vacíos
+// coinciden con cualquier llamada; BatchIndexes limita la falla a esas operaciones de
+// un lote (el lote completo se rechaza, como lo haría Firestore).
+type Fault struct {
+	Operation    string
+	Collection   string
+	Latency      time.Duration
+	Err          error
+	Probability  float64 // 0 equivale a siempre
+	Times        int     // 0 equivale a ilimitado
+	BatchIndexes []int
+}
+
+type activeFault struct {
+	fault Fault
+	hits  int
+}
+
+var (
+	faultsMu sync.Mutex
+	faults   []*activeFault
+)
+
+const faultsInterceptor = "faults"
+
+// EnableFaults registra el interceptor de fallas simuladas. Solo debe llamarse en
+// pruebas: sin él InjectFault no tiene efecto y las llamadas no pagan su costo.
+func EnableFaults() {
+	if !HasInterceptor(faultsInterceptor) {
+		RegisterInterceptor(Interceptor{Name: faultsInterceptor, Before: applyFaults})
+	}
+}
+
+// DisableFaults retira el interceptor y todas las fallas simuladas
+func DisableFaults() {
+	UnregisterInterceptor(faultsInterceptor)
+	ClearFaults()
+}
+
+// InjectFault registra una falla simulada y retorna la función que la retira. Entra en
+// pánico si EnableFaults no fue llamado, para que una prueba no pase sin la falla.
+func InjectFault(fault Fault) func() {
+	if !HasInterceptor(faultsInterceptor) {
+		panic("fault injection is not enabled. Call EnableFaults first.")
+	}
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	active := &activeFault{fault: fault}
+	faults = append(faults, active)
+
+	return func() {
+		faultsMu.Lock()
+		defer faultsMu.Unlock()
+		for i, f := range faults {
+			if f == active {
+				faults = append(faults[:i], faults[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// ClearFaults retira todas las fallas simuladas
+func ClearFaults() {
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	faults = nil
+}
+
+// applyFaults interceptor que aplica la latencia y el error de la primera falla que coincide
+func applyFaults(ctx context.Context, call *Call) error {
+	fault, ok := matchFault(call)
+	if !ok {
+		return nil
+	}
+
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return fault.Err
+}
+
+func matchFault(call *Call) (Fault, bool) {
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+
+	for _, active := range faults {
+		f := active.fault
+		if f.Operation != "" && f.Operation != call.Operation {
+			continue
+		}
+		if f.Collection != "" && f.Collection != call.Collection {
+			continue
+		}
+		if len(f.BatchIndexes) > 0 && !containsIndex(f.BatchIndexes, call.Index) {
+			continue
+		}
+		if f.Times > 0 && active.hits >= f.Times {
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() >= f.Probability {
+			continue
+		}
+		active.hits++
+		return f, true
+	}
+	return Fault{}, false
+}
+
+func containsIndex(indexes []int, index int) bool {
+	for _, i := range indexes {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package firebase
+
+// Límites de Firestore para filtros disyuntivos
+const (
+	MaxDisjunctionValues = 30 // "in" y "array-contains-any"
+	MaxNotInValues       = 10 // "not-in"
+)
+
+// Where crea un filtro genérico
+func Where(field string, op Operator, value interface{}) QueryFilter {
+	return QueryFilter{Field: field, Operator: op, Value: value}
+}
+
+// WhereIn crea un filtro "in". Con más de MaxDisjunctionValues valores, QueryDocuments
+// divide la consulta en varias y combina los resultados.
+func WhereIn(field string, values ...interface{}) QueryFilter {
+	return QueryFilter{Field: field, Operator: OpIn, Value: values}
+}
+
+// WhereNotIn crea un filtro "not-in" (máximo MaxNotInValues valores)
+func WhereNotIn(field string, values ...interface{}) QueryFilter {
+	return QueryFilter{Field: field, Operator: OpNotIn, Value: values}
+}
+
+// WhereArrayContainsAny crea un filtro "array-contains-any". Con más de
+// MaxDisjunctionValues valores, QueryDocuments divide la consulta.
+func WhereArrayContainsAny(field string, values ...interface{}) QueryFilter {
+	return QueryFilter{Field: field, Operator: OpArrayContainsAny, Value: values}
+}
+
+// Between crea el par de filtros field >= from y field < to (intervalo semiabierto), de
+// modo que rangos consecutivos no se solapen. Ambos filtros usan el mismo campo, por lo
+// que respetan la restricción de un único campo de desigualdad.
+func Between(field string, from, to interface{}) []QueryFilter {
+	return []QueryFilter{
+		{Field: field, Operator: OpGreaterThanOrEqual, Value: from},
+		{Field: field, Operator: OpLessThan, Value: to},
+	}
+}
+
+// IsInequality indica si el operador es de desigualdad (rango o exclusión)
+func (o Operator) IsInequality() bool {
+	switch o {
+	case OpLessThan, OpLessThanOrEqual, OpGreaterThan, OpGreaterThanOrEqual, OpNotEqual, OpNotIn:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+// Package firebasetest contiene helpers para pruebas de integración contra el emulador
+// (o un proyecto de pruebas). Las rutas de documentos son "colección/id" y admiten
+// subcolecciones ("users/u1/orders/o1"); los documentos se leen directamente, sin
+// interceptores ni políticas.
+package firebasetest
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// PollInterval intervalo entre lecturas de EventuallyDocument
+var PollInterval = 100 * time.Millisecond
+
+// AssertDocumentExists falla la prueba si el documento no existe y lo retorna
+func AssertDocumentExists(ctx context.Context, t testing.TB, docPath string) *firebase.Document {
+	t.Helper()
+	doc, err := readDocument(ctx, docPath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", docPath, err)
+	}
+	if doc == nil {
+		t.Fatalf("expected document %s to exist", docPath)
+	}
+	return doc
+}
+
+// AssertDocumentMissing falla la prueba si el documento existe
+func AssertDocumentMissing(ctx context.Context, t testing.TB, docPath string) {
+	t.Helper()
+	doc, err := readDocument(ctx, docPath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", docPath, err)
+	}
+	if doc != nil {
+		t.Fatalf("expected document %s not to exist, got %v", docPath, doc.Data)
+	}
+}
+
+// AssertDocumentField falla la prueba si field (admite rutas con puntos) no es igual a
+// want. Los números se comparan por valor, de modo que 3 es igual a int64(3) y 3.0.
+func AssertDocumentField(ctx context.Context, t testing.TB, docPath, field string, want interface{}) {
+	t.Helper()
+	doc := AssertDocumentExists(ctx, t, docPath)
+	got, ok := doc.Get(field)
+	if !ok {
+		t.Fatalf("document %s has no field %q", docPath, field)
+	}
+	if !equalValues(doc, field, want) {
+		t.Fatalf("document %s field %q = %v (%T), want %v (%T)", docPath, field, got, got, want, want)
+	}
+}
+
+// AssertUserHasClaim falla la prueba si el usuario no tiene el custom claim con el valor want
+func AssertUserHasClaim(ctx context.Context, t testing.TB, uid, claim string, want interface{}) {
+	t.Helper()
+	user, err := firebase.GetAuthClient().GetUser(ctx, uid)
+	if err != nil {
+		t.Fatalf("getting user %s: %v", uid, err)
+	}
+	claims := &firebase.Document{Data: user.CustomClaims}
+	got, ok := claims.Get(claim)
+	if !ok {
+		t.Fatalf("user %s has no claim %q (claims: %v)", uid, claim, user.CustomClaims)
+	}
+	if !equalValues(claims, claim, want) {
+		t.Fatalf("user %s claim %q = %v (%T), want %v (%T)", uid, claim, got, got, want, want)
+	}
+}
+
+// EventuallyDocument lee el documento cada PollInterval hasta que exista y cumpla cond
+// (nil = solo que exista) o se agote timeout, en cuyo caso falla la prueba. Útil para
+// efectos asíncronos como triggers, CDC o colas.
+func EventuallyDocument(ctx context.Context, t testing.TB, docPath string, cond func(doc *firebase.Document) bool, timeout time.Duration) *firebase.Document {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	var last *firebase.Document
+	var lastErr error
+	for {
+		last, lastErr = readDocument(ctx, docPath)
+		if lastErr == nil && last != nil && (cond == nil || cond(last)) {
+			return last
+		}
+
+		select {
+		case <-ctx.Done():
+			switch {
+			case lastErr != nil:
+				t.Fatalf("document %s not ready after %s: %v", docPath, timeout, lastErr)
+			case last == nil:
+				t.Fatalf("document %s did not appear within %s", docPath, timeout)
+			default:
+				t.Fatalf("document %s did not satisfy the condition within %s (last: %v)", docPath, timeout, last.Data)
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// FieldEquals condición para EventuallyDocument
+func FieldEquals(field string, want interface{}) func(doc *firebase.Document) bool {
+	return func(doc *firebase.Document) bool {
+		return equalValues(doc, field, want)
+	}
+}
+
+// readDocument lee el documento; retorna nil sin error si no existe
+func readDocument(ctx context.Context, docPath string) (*firebase.Document, error) {
+	collection, id := path.Split(docPath)
+	collection = path.Clean(collection)
+	if id == "" || collection == "." {
+		return nil, fmt.Errorf("invalid document path %q", docPath)
+	}
+
+	snap, err := firebase.FirestoreClientFor(ctx, collection).Doc(docPath).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}, nil
+}
+
+// equalValues compara con las reglas de igualdad de las consultas (números por valor) y,
+// para el resto de tipos, por igualdad profunda
+func equalValues(doc *firebase.Document, field string, want interface{}) bool {
+	if firestore.MatchesFilters(doc, []firebase.QueryFilter{firebase.Where(field, firebase.OpEqual, want)}) {
+		return true
+	}
+	got, _ := doc.Get(field)
+	return reflect.DeepEqual(got, want)
+}
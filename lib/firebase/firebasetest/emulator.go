@@ -0,0 +1,246 @@
+package firebasetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// EmulatorOptions opciones de StartEmulator
+type EmulatorOptions struct {
+	// ProjectID proyecto del emulador; por defecto "demo-test" (los proyectos "demo-*"
+	// nunca acceden a recursos reales)
+	ProjectID     string
+	FirestorePort int           // por defecto 8080
+	AuthPort      int           // por defecto 9099
+	StartTimeout  time.Duration // por defecto 2 minutos (la primera vez se descargan los emuladores)
+	// Fixtures archivos de fixtures (ver firestore.LoadFixtures) que se cargan al empezar,
+	// por ejemplo un embed.FS u os.DirFS("testdata/fixtures")
+	Fixtures fs.FS
+	// KeepData conserva los datos al terminar la prueba (por defecto se borran)
+	KeepData bool
+}
+
+// Emulator emuladores a los que apunta el paquete
+type Emulator struct {
+	ProjectID     string
+	FirestoreHost string
+	AuthHost      string
+	// Fixtures IDs y UIDs asignados por ref al cargar EmulatorOptions.Fixtures
+	Fixtures *firestore.Fixtures
+}
+
+// errNoEmulatorCLI sin CLI de Firebase para lanzar los emuladores
+var errNoEmulatorCLI = errors.New("firebase CLI not found (install firebase-tools or Node.js)")
+
+var (
+	emulatorMu  sync.Mutex
+	emulatorCmd *exec.Cmd
+	emulatorDir string
+)
+
+// StartEmulator apunta el paquete a los emuladores de Firestore y Auth: usa los de
+// FIRESTORE_EMULATOR_HOST y FIREBASE_AUTH_EMULATOR_HOST o los de los puertos locales si ya
+// están en marcha y, si no, los lanza con el CLI de Firebase (firebase o, en su defecto,
+// npx firebase-tools, que lo descarga). Los emuladores lanzados se comparten entre las
+// pruebas del paquete; StopEmulator (desde TestMain) los detiene. Cada llamada empieza con
+// los datos vacíos, carga los fixtures y los borra al terminar la prueba. Sin CLI
+// ni emuladores, la prueba se omite.
+func StartEmulator(t testing.TB, opts ...EmulatorOptions) *Emulator {
+	t.Helper()
+	var o EmulatorOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.ProjectID == "" {
+		o.ProjectID = "demo-test"
+	}
+	if o.FirestorePort == 0 {
+		o.FirestorePort = 8080
+	}
+	if o.AuthPort == 0 {
+		o.AuthPort = 9099
+	}
+	if o.StartTimeout <= 0 {
+		o.StartTimeout = 2 * time.Minute
+	}
+
+	emulator := &Emulator{
+		ProjectID:     o.ProjectID,
+		FirestoreHost: os.Getenv("FIRESTORE_EMULATOR_HOST"),
+		AuthHost:      os.Getenv("FIREBASE_AUTH_EMULATOR_HOST"),
+	}
+	if emulator.FirestoreHost == "" {
+		emulator.FirestoreHost = fmt.Sprintf("127.0.0.1:%d", o.FirestorePort)
+	}
+	if emulator.AuthHost == "" {
+		emulator.AuthHost = fmt.Sprintf("127.0.0.1:%d", o.AuthPort)
+	}
+
+	if err := ensureEmulator(emulator, o); err != nil {
+		if errors.Is(err, errNoEmulatorCLI) {
+			t.Skipf("firestore emulator not available: %v", err)
+		}
+		t.Fatalf("starting emulator: %v", err)
+	}
+	os.Setenv("FIRESTORE_EMULATOR_HOST", emulator.FirestoreHost)
+	os.Setenv("FIREBASE_AUTH_EMULATOR_HOST", emulator.AuthHost)
+	if err := firebase.InitFirebaseForEmulator(o.ProjectID); err != nil {
+		t.Fatalf("initializing firebase for emulator: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := emulator.Reset(ctx); err != nil {
+		t.Fatalf("resetting emulator: %v", err)
+	}
+	if o.Fixtures != nil {
+		fixtures, err := firestore.LoadFixtures(ctx, o.Fixtures)
+		if err != nil {
+			t.Fatalf("loading fixtures: %v", err)
+		}
+		emulator.Fixtures = fixtures
+	}
+	if !o.KeepData {
+		t.Cleanup(func() {
+			if err := emulator.Reset(context.Background()); err != nil {
+				t.Errorf("resetting emulator: %v", err)
+			}
+		})
+	}
+	return emulator
+}
+
+// StopEmulator detiene los emuladores lanzados por StartEmulator (no los que ya estaban
+// en marcha)
+func StopEmulator() error {
+	emulatorMu.Lock()
+	defer emulatorMu.Unlock()
+	if emulatorCmd == nil {
+		return nil
+	}
+	emulatorCmd.Process.Signal(os.Interrupt)
+	done := make(chan error, 1)
+	go func() { done <- emulatorCmd.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		emulatorCmd.Process.Kill()
+	}
+	emulatorCmd = nil
+	os.RemoveAll(emulatorDir)
+	return nil
+}
+
+// Reset borra todos los documentos y usuarios de los emuladores
+func (e *Emulator) Reset(ctx context.Context) error {
+	endpoints := []string{
+		fmt.Sprintf("http://%s/emulator/v1/projects/%s/databases/(default)/documents", e.FirestoreHost, e.ProjectID),
+		fmt.Sprintf("http://%s/emulator/v1/projects/%s/accounts", e.AuthHost, e.ProjectID),
+	}
+	for _, endpoint := range endpoints {
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to reset emulator: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("failed to reset emulator: %s responded %d", endpoint, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+// ensureEmulator comprueba que los emuladores responden o los lanza
+func ensureEmulator(e *Emulator, o EmulatorOptions) error {
+	emulatorMu.Lock()
+	defer emulatorMu.Unlock()
+	if reachable(e.FirestoreHost) && reachable(e.AuthHost) {
+		return nil
+	}
+	if emulatorCmd != nil {
+		return waitReachable(e, o.StartTimeout)
+	}
+
+	name, args := "firebase", []string{}
+	if _, err := exec.LookPath("firebase"); err != nil {
+		if _, err := exec.LookPath("npx"); err != nil {
+			return errNoEmulatorCLI
+		}
+		name, args = "npx", []string{"--yes", "firebase-tools"}
+	}
+
+	dir, err := os.MkdirTemp("", "firebasetest-emulator")
+	if err != nil {
+		return err
+	}
+	config := map[string]interface{}{
+		"emulators": map[string]interface{}{
+			"firestore": map[string]interface{}{"host": "127.0.0.1", "port": o.FirestorePort},
+			"auth":      map[string]interface{}{"host": "127.0.0.1", "port": o.AuthPort},
+			"ui":        map[string]interface{}{"enabled": false},
+		},
+	}
+	content, _ := json.Marshal(config)
+	if err := os.WriteFile(filepath.Join(dir, "firebase.json"), content, 0o644); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	args = append(args, "emulators:start", "--only", "firestore,auth", "--project", o.ProjectID)
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("failed to launch emulators: %w", err)
+	}
+	emulatorCmd, emulatorDir = cmd, dir
+	if err := waitReachable(e, o.StartTimeout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		emulatorCmd = nil
+		os.RemoveAll(dir)
+		return fmt.Errorf("%w\n%s", err, output.String())
+	}
+	return nil
+}
+
+func waitReachable(e *Emulator, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if reachable(e.FirestoreHost) && reachable(e.AuthHost) {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("emulators did not start within %s", timeout)
+}
+
+func reachable(host string) bool {
+	conn, err := net.DialTimeout("tcp", host, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,198 @@
+package firebasetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// UpdateGoldenEnv variable de entorno que, con valor "1", reescribe los archivos golden en
+// lugar de compararlos
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// GoldenOptions opciones de AssertGoldenCollection
+type GoldenOptions struct {
+	// MaskFields campos (admiten rutas con puntos) cuyo valor se reemplaza por "<masked>",
+	// además de los timestamps, que siempre se enmascaran
+	MaskFields []string
+	// MaskIDs reemplaza los IDs de los documentos por "<doc-N>" (para IDs generados); el
+	// orden pasa a ser el del contenido serializado
+	MaskIDs bool
+	// Filters limita los documentos incluidos
+	Filters []firebase.QueryFilter
+}
+
+// AssertGoldenCollection serializa el estado de la colección (normalizado, con claves
+// ordenadas y timestamps enmascarados) y lo compara con el archivo golden. Si el archivo
+// no existe o UPDATE_GOLDEN=1, lo escribe y la prueba pasa.
+func AssertGoldenCollection(ctx context.Context, t testing.TB, collection, goldenPath string, options GoldenOptions) {
+	t.Helper()
+	got, err := SnapshotCollection(ctx, collection, options)
+	if err != nil {
+		t.Fatalf("snapshotting %s: %v", collection, err)
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if os.IsNotExist(err) || os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+			t.Fatalf("creating golden directory: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+			t.Fatalf("writing golden file %s: %v", goldenPath, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("reading golden file %s: %v", goldenPath, err)
+	}
+
+	if string(want) != string(got) {
+		t.Fatalf("collection %s does not match %s (run with %s=1 to update):\n%s", collection, goldenPath, UpdateGoldenEnv, lineDiff(string(want), string(got)))
+	}
+}
+
+// SnapshotCollection retorna la serialización normalizada usada por AssertGoldenCollection
+func SnapshotCollection(ctx context.Context, collection string, options GoldenOptions) ([]byte, error) {
+	query := firebase.FirestoreClientFor(ctx, collection).Collection(collection).Query
+	for _, filter := range options.Filters {
+		query = query.Where(filter.Field, string(filter.Operator), filter.Value)
+	}
+
+	type entry struct {
+		ID   string                 `json:"id"`
+		Data map[string]interface{} `json:"data"`
+	}
+	var entries []entry
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read collection '%s': %w", collection, err)
+		}
+
+		data := normalizeValue(snap.Data()).(map[string]interface{})
+		for _, field := range options.MaskFields {
+			maskField(data, strings.Split(field, "."))
+		}
+		entries = append(entries, entry{ID: snap.Ref.ID, Data: data})
+	}
+
+	if options.MaskIDs {
+		keys := make(map[int]string, len(entries))
+		for i := range entries {
+			content, _ := json.Marshal(entries[i].Data)
+			keys[i] = string(content)
+		}
+		order := make([]int, len(entries))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return keys[order[a]] < keys[order[b]] })
+		sorted := make([]entry, len(entries))
+		for n, i := range order {
+			sorted[n] = entries[i]
+			sorted[n].ID = fmt.Sprintf("<doc-%d>", n+1)
+		}
+		entries = sorted
+	} else {
+		sort.Slice(entries, func(a, b int) bool { return entries[a].ID < entries[b].ID })
+	}
+
+	out, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize collection '%s': %w", collection, err)
+	}
+	return append(out, '\n'), nil
+}
+
+// normalizeValue convierte los valores de Firestore a una forma estable para JSON
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = normalizeValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	case time.Time:
+		return "<timestamp>"
+	case *gfirestore.DocumentRef:
+		return "ref:" + v.Path[strings.Index(v.Path, "/documents/")+len("/documents/"):]
+	}
+	return value
+}
+
+func maskField(data map[string]interface{}, path []string) {
+	value, ok := data[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		data[path[0]] = "<masked>"
+		return
+	}
+	if nested, ok := value.(map[string]interface{}); ok {
+		maskField(nested, path[1:])
+	}
+}
+
+// lineDiff muestra las líneas eliminadas (-) y agregadas (+) entre want y got
+func lineDiff(want, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// Subsecuencia común más larga por líneas
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&out, "+ %s\n", b[j])
+			j++
+		default:
+			fmt.Fprintf(&out, "- %s\n", a[i])
+			i++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,300 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Count crea una agregación de conteo
+func Count(alias string) firebase.Aggregation {
+	return firebase.Aggregation{Op: firebase.AggCount, Alias: alias}
+}
+
+// Sum crea una agregación de suma sobre field
+func Sum(field, alias string) firebase.Aggregation {
+	return firebase.Aggregation{Op: firebase.AggSum, Field: field, Alias: alias}
+}
+
+// Avg crea una agregación de promedio sobre field
+func Avg(field, alias string) firebase.Aggregation {
+	return firebase.Aggregation{Op: firebase.AggAvg, Field: field, Alias: alias}
+}
+
+// Min crea una agregación de mínimo sobre field
+func Min(field, alias string) firebase.Aggregation {
+	return firebase.Aggregation{Op: firebase.AggMin, Field: field, Alias: alias}
+}
+
+// Max crea una agregación de máximo sobre field
+func Max(field, alias string) firebase.Aggregation {
+	return firebase.Aggregation{Op: firebase.AggMax, Field: field, Alias: alias}
+}
+
+// Aggregate calcula agregaciones sobre los documentos que cumplen los filtros, agrupando
+// opcionalmente por groupBy. Cuando es posible (sin agrupación y solo count/sum/avg) se
+// usa una consulta de agregación en el servidor; en otro caso los documentos se
+// recorren en streaming y se reducen en memoria. Los filtros "in" y
+// "array-contains-any" con más de firebase.MaxDisjunctionValues valores se dividen en
+// varias consultas como en QueryDocuments. Pasa por los interceptores como una consulta,
+// por lo que tenancy y el alcance de las claves de API aplican igual que al listar.
+func Aggregate(ctx context.Context, collection string, filters []firebase.QueryFilter, groupBy string, aggregations ...firebase.Aggregation) (_ []*firebase.AggregateGroup, err error) {
+	if len(aggregations) == 0 {
+		return nil, fmt.Errorf("at least one aggregation is required")
+	}
+	for _, agg := range aggregations {
+		if agg.Alias == "" {
+			return nil, fmt.Errorf("aggregation %q requires an alias", agg.Op)
+		}
+		if agg.Op != firebase.AggCount && agg.Field == "" {
+			return nil, fmt.Errorf("aggregation %q requires a field", agg.Op)
+		}
+	}
+
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	// Copia: un interceptor puede agregar filtros (p. ej. tenant) sin tocar los del llamador
+	filters = append([]firebase.QueryFilter(nil), filters...)
+	call := newCall(firebase.CallFirestoreQuery, collection, "", &filters)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
+	collection = call.Collection
+
+	filters, chunkIndex, err := normalizeDisjunctions(filters)
+	if err != nil {
+		return nil, err
+	}
+	chunks := disjunctionChunks(filters, chunkIndex)
+
+	// Los bloques de un "in" son disjuntos y sus conteos y sumas se pueden acumular; los
+	// de "array-contains-any" pueden repetir documentos y el promedio no es aditivo
+	additive := chunkIndex < 0 || (filters[chunkIndex].Operator == firebase.OpIn && !hasAggregation(aggregations, firebase.AggAvg))
+	var groups []*firebase.AggregateGroup
+	if _, rules := activePolicies(ctx, collection); groupBy == "" && serverSupported(aggregations) && additive && rules == nil {
+		groups, err = aggregateOnServer(ctx, client, collection, chunks, aggregations)
+	} else {
+		groups, err = aggregateOnClient(ctx, client, collection, chunks, groupBy, aggregations)
+	}
+	if err != nil {
+		return nil, err
+	}
+	call.Result = groups
+	return groups, nil
+}
+
+// disjunctionChunks retorna un conjunto de filtros por cada bloque de valores del filtro
+// chunkIndex (o solo filters si es -1)
+func disjunctionChunks(filters []firebase.QueryFilter, chunkIndex int) [][]firebase.QueryFilter {
+	if chunkIndex < 0 {
+		return [][]firebase.QueryFilter{filters}
+	}
+	values := filters[chunkIndex].Value.([]interface{})
+	var chunks [][]firebase.QueryFilter
+	for start := 0; start < len(values); start += firebase.MaxDisjunctionValues {
+		end := min(start+firebase.MaxDisjunctionValues, len(values))
+		chunk := make([]firebase.QueryFilter, len(filters))
+		copy(chunk, filters)
+		chunk[chunkIndex].Value = values[start:end]
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func hasAggregation(aggregations []firebase.Aggregation, op firebase.AggregateOp) bool {
+	for _, agg := range aggregations {
+		if agg.Op == op {
+			return true
+		}
+	}
+	return false
+}
+
+func serverSupported(aggregations []firebase.Aggregation) bool {
+	for _, agg := range aggregations {
+		switch agg.Op {
+		case firebase.AggCount, firebase.AggSum, firebase.AggAvg:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// aggregateOnServer ejecuta una consulta de agregación por bloque y acumula los
+// resultados (solo se llama con bloques disjuntos o sin promedio si hay varios)
+func aggregateOnServer(ctx context.Context, client *firestore.Client, collection string, chunks [][]firebase.QueryFilter, aggregations []firebase.Aggregation) ([]*firebase.AggregateGroup, error) {
+	group := &firebase.AggregateGroup{Values: make(map[string]float64)}
+	for _, filters := range chunks {
+		if err := aggregateChunkOnServer(ctx, client, collection, filters, aggregations, group); err != nil {
+			return nil, err
+		}
+	}
+	return []*firebase.AggregateGroup{group}, nil
+}
+
+func aggregateChunkOnServer(ctx context.Context, client *firestore.Client, collection string, filters []firebase.QueryFilter, aggregations []firebase.Aggregation, group *firebase.AggregateGroup) error {
+	query, err := applyFilters(client.Collection(collection).Query, filters)
+	if err != nil {
+		return err
+	}
+
+	const countAlias = "__count"
+	aggQuery := query.NewAggregationQuery().WithCount(countAlias)
+	for _, agg := range aggregations {
+		switch agg.Op {
+		case firebase.AggCount:
+			aggQuery = aggQuery.WithCount(agg.Alias)
+		case firebase.AggSum:
+			aggQuery = aggQuery.WithSum(agg.Field, agg.Alias)
+		case firebase.AggAvg:
+			aggQuery = aggQuery.WithAvg(agg.Field, agg.Alias)
+		}
+	}
+
+	result, err := aggQuery.Get(ctx)
+	if err != nil {
+		return indexError(ctx, collection, err, fmt.Errorf("failed to run aggregation query on collection '%s': %w", collection, err))
+	}
+
+	count, _ := aggregateValue(result[countAlias])
+	group.Count += int64(count)
+	for _, agg := range aggregations {
+		value, _ := aggregateValue(result[agg.Alias])
+		group.Values[agg.Alias] += value
+	}
+	return nil
+}
+
+// aggregateValue convierte el valor protobuf de una agregación a float64
+func aggregateValue(value interface{}) (float64, bool) {
+	pbValue, ok := value.(*firestorepb.Value)
+	if !ok {
+		return 0, false
+	}
+	switch v := pbValue.ValueType.(type) {
+	case *firestorepb.Value_IntegerValue:
+		return float64(v.IntegerValue), true
+	case *firestorepb.Value_DoubleValue:
+		return v.DoubleValue, true
+	}
+	return 0, false
+}
+
+type groupAccumulator struct {
+	group *firebase.AggregateGroup
+	sums  map[string]float64
+	seen  map[string]int64
+}
+
+func aggregateOnClient(ctx context.Context, client *firestore.Client, collection string, chunks [][]firebase.QueryFilter, groupBy string, aggregations []firebase.Aggregation) ([]*firebase.AggregateGroup, error) {
+	groups := make(map[string]*groupAccumulator)
+	var order []*groupAccumulator
+
+	// Con varios bloques un documento puede coincidir en más de uno
+	scanned := make(map[string]bool)
+	for _, filters := range chunks {
+		query, err := applyFilters(client.Collection(collection).Query, filters)
+		if err != nil {
+			return nil, err
+		}
+
+		iter := query.Documents(ctx)
+		for {
+			snap, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				iter.Stop()
+				return nil, indexError(ctx, collection, err, fmt.Errorf("failed to aggregate documents in collection '%s': %w", collection, err))
+			}
+			if len(chunks) > 1 {
+				if scanned[snap.Ref.ID] {
+					continue
+				}
+				scanned[snap.Ref.ID] = true
+			}
+
+			doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+			if authorize(ctx, collection, doc, firebase.OperationRead) != nil {
+				continue
+			}
+			order = accumulate(groups, order, doc, groupBy, aggregations)
+		}
+		iter.Stop()
+	}
+
+	results := make([]*firebase.AggregateGroup, 0, len(order))
+	for _, acc := range order {
+		for _, agg := range aggregations {
+			switch agg.Op {
+			case firebase.AggCount:
+				acc.group.Values[agg.Alias] = float64(acc.group.Count)
+			case firebase.AggSum:
+				acc.group.Values[agg.Alias] = acc.sums[agg.Alias]
+			case firebase.AggAvg:
+				// Igual que en el servidor, un promedio sin valores numéricos queda en 0
+				if n := acc.seen[agg.Alias]; n > 0 {
+					acc.group.Values[agg.Alias] = acc.sums[agg.Alias] / float64(n)
+				}
+			}
+		}
+		results = append(results, acc.group)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return compareValues(results[i].Key, results[j].Key) < 0
+	})
+	return results, nil
+}
+
+// accumulate suma doc al grupo de su valor de groupBy y retorna el orden de los grupos
+func accumulate(groups map[string]*groupAccumulator, order []*groupAccumulator, doc *firebase.Document, groupBy string, aggregations []firebase.Aggregation) []*groupAccumulator {
+	var key interface{}
+	if groupBy != "" {
+		key = doc.Data[groupBy]
+	}
+	groupKey := fmt.Sprintf("%T:%v", key, key)
+	acc, ok := groups[groupKey]
+	if !ok {
+		acc = &groupAccumulator{
+			group: &firebase.AggregateGroup{Key: key, Values: make(map[string]float64)},
+			sums:  make(map[string]float64),
+			seen:  make(map[string]int64),
+		}
+		groups[groupKey] = acc
+		order = append(order, acc)
+	}
+	acc.group.Count++
+
+	for _, agg := range aggregations {
+		if agg.Op == firebase.AggCount {
+			continue
+		}
+		value, ok := toFloat(doc.Data[agg.Field])
+		if !ok {
+			continue
+		}
+		acc.sums[agg.Alias] += value
+		acc.seen[agg.Alias]++
+		switch agg.Op {
+		case firebase.AggMin:
+			if acc.seen[agg.Alias] == 1 || value < acc.group.Values[agg.Alias] {
+				acc.group.Values[agg.Alias] = value
+			}
+		case firebase.AggMax:
+			if acc.seen[agg.Alias] == 1 || value > acc.group.Values[agg.Alias] {
+				acc.group.Values[agg.Alias] = value
+			}
+		}
+	}
+	return order
+}
@@ -0,0 +1,284 @@
+package firestore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Campos del documento stub que reemplaza a un documento archivado
+const (
+	archivedField       = "archived"
+	archiveObjectField  = "archive_object"
+	archivedAtField     = "archived_at"
+	archiveBatchMaxDocs = 500
+)
+
+// ArchiveResult resultado de ArchiveOldDocuments
+type ArchiveResult struct {
+	Archived int      `json:"archived"`
+	Held     int      `json:"held"`    // omitidos por estar bajo retención legal
+	Objects  []string `json:"objects"` // gs://bucket/objeto de cada lote
+}
+
+// archivedLine línea JSONL de un archivo: ID y datos con tipos (EncodeJSONValue)
+type archivedLine struct {
+	ID   string                 `json:"id"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// ArchiveOldDocuments mueve los documentos de la colección con updated_at anterior a
+// ahora - olderThan a objetos JSONL comprimidos con gzip en destBucket (lotes de hasta 500
+// documentos en archive/<colección>/) y reemplaza cada documento por un stub con
+// archived=true y la ruta del objeto. Omite los documentos bajo retención legal. Los
+// stubs se restauran con RestoreFromArchive. Es una operación de sistema: no pasa por
+// interceptores ni políticas.
+func ArchiveOldDocuments(ctx context.Context, collection string, olderThan time.Duration, destBucket string) (*ArchiveResult, error) {
+	if err := firebase.CheckWritable(collection); err != nil {
+		return nil, err
+	}
+	if err := firebase.CheckMutable(collection, "archive"); err != nil {
+		return nil, err
+	}
+	client := firebase.FirestoreClientFor(ctx, collection)
+	bucket, err := firebase.GetStorageBucketByName(destBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	holds, err := ActiveLegalHolds(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ArchiveResult{}
+	cutoff := firebase.Now().Add(-olderThan)
+	query := client.Collection(collection).
+		Where("updated_at", "<", cutoff).
+		OrderBy("updated_at", firestore.Asc).
+		Limit(archiveBatchMaxDocs)
+
+	var last *firestore.DocumentSnapshot
+	for {
+		page := query
+		if last != nil {
+			page = query.StartAfter(last)
+		}
+		snaps, err := page.Documents(ctx).GetAll()
+		if err != nil {
+			return result, fmt.Errorf("failed to read documents to archive from '%s': %w", collection, err)
+		}
+		if len(snaps) == 0 {
+			return result, nil
+		}
+		last = snaps[len(snaps)-1]
+
+		var batch []*firestore.DocumentSnapshot
+		for _, snap := range snaps {
+			if archived, _ := snap.Data()[archivedField].(bool); archived {
+				continue
+			}
+			if len(HeldBy(holds, &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()})) > 0 {
+				result.Held++
+				continue
+			}
+			batch = append(batch, snap)
+		}
+		if len(batch) > 0 {
+			object, archived, err := archiveBatch(ctx, client, bucket, destBucket, collection, batch)
+			if err != nil {
+				return result, err
+			}
+			result.Archived += archived
+			result.Objects = append(result.Objects, object)
+		}
+		if len(snaps) < archiveBatchMaxDocs {
+			return result, nil
+		}
+	}
+}
+
+// archiveBatch sube el lote y, solo si la subida se completó, reemplaza los documentos.
+// Retorna el objeto y cuántos documentos quedaron archivados.
+func archiveBatch(ctx context.Context, client *firestore.Client, bucket *storage.BucketHandle, bucketName, collection string, snaps []*firestore.DocumentSnapshot) (string, int, error) {
+	id, err := NewULID()
+	if err != nil {
+		return "", 0, err
+	}
+	object := fmt.Sprintf("archive/%s/%s.jsonl.gz", strings.ReplaceAll(collection, "/", "_"), id)
+	uri := fmt.Sprintf("gs://%s/%s", bucketName, object)
+
+	writer := bucket.Object(object).NewWriter(ctx)
+	writer.ContentType = "application/gzip"
+	gz := gzip.NewWriter(writer)
+	encoder := json.NewEncoder(gz)
+	for _, snap := range snaps {
+		line := archivedLine{ID: snap.Ref.ID, Data: EncodeJSONValue(snap.Data()).(map[string]interface{})}
+		if err := encoder.Encode(line); err != nil {
+			writer.Close()
+			return "", 0, fmt.Errorf("failed to encode document '%s' for archive: %w", snap.Ref.ID, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		writer.Close()
+		return "", 0, fmt.Errorf("failed to write archive '%s': %w", uri, err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to upload archive '%s': %w", uri, err)
+	}
+
+	// Los stubs se escriben en una transacción que omite los documentos modificados
+	// durante el archivado (conservan su versión viva; la copia archivada queda huérfana)
+	now := firebase.Now()
+	archived := 0
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		archived = 0
+		current := make([]*firestore.DocumentSnapshot, len(snaps))
+		for i, snap := range snaps {
+			if current[i], err = tx.Get(snap.Ref); err != nil && !IsNotFound(err) {
+				return err
+			}
+		}
+		for i, snap := range snaps {
+			if current[i] == nil || !current[i].Exists() || !current[i].UpdateTime.Equal(snap.UpdateTime) {
+				continue
+			}
+			stub := map[string]interface{}{
+				archivedField:      true,
+				archiveObjectField: uri,
+				archivedAtField:    now,
+				"created_at":       snap.Data()["created_at"],
+				"updated_at":       snap.Data()["updated_at"],
+			}
+			if err := tx.Set(snap.Ref, stub); err != nil {
+				return err
+			}
+			archived++
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write archive stubs for '%s': %w", uri, err)
+	}
+	return uri, archived, nil
+}
+
+// IsArchived indica si el documento es un stub de archivo
+func IsArchived(doc *firebase.Document) bool {
+	archived, _ := doc.GetBool(archivedField)
+	return archived
+}
+
+// RestoreFromArchive restaura un documento archivado a partir de su stub
+func RestoreFromArchive(ctx context.Context, collection, docID string) error {
+	if err := firebase.CheckWritable(collection); err != nil {
+		return err
+	}
+	if err := firebase.CheckMutable(collection, "restore"); err != nil {
+		return err
+	}
+	client := firebase.FirestoreClientFor(ctx, collection)
+	snap, err := client.Collection(collection).Doc(docID).Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read archive stub '%s': %w", docID, err)
+	}
+	uri, _ := snap.Data()[archiveObjectField].(string)
+	if archived, _ := snap.Data()[archivedField].(bool); !archived || uri == "" {
+		return fmt.Errorf("document '%s' in collection '%s' is not archived", docID, collection)
+	}
+
+	restored := 0
+	err = readArchive(ctx, client, uri, func(id string, data map[string]interface{}) error {
+		if id != docID {
+			return nil
+		}
+		restored++
+		_, err := client.Collection(collection).Doc(id).Set(ctx, data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return fmt.Errorf("document '%s' not found in archive '%s'", docID, uri)
+	}
+	return nil
+}
+
+// RestoreArchiveObject restaura todos los documentos de un objeto de archivo cuyo stub
+// sigue apuntando a él (los que ya se restauraron o se reescribieron no se tocan)
+func RestoreArchiveObject(ctx context.Context, collection, uri string) (int, error) {
+	if err := firebase.CheckWritable(collection); err != nil {
+		return 0, err
+	}
+	if err := firebase.CheckMutable(collection, "restore"); err != nil {
+		return 0, err
+	}
+	client := firebase.FirestoreClientFor(ctx, collection)
+	restored := 0
+	err := readArchive(ctx, client, uri, func(id string, data map[string]interface{}) error {
+		ref := client.Collection(collection).Doc(id)
+		return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			snap, err := tx.Get(ref)
+			if err != nil && !IsNotFound(err) {
+				return err
+			}
+			if snap == nil || !snap.Exists() || snap.Data()[archiveObjectField] != uri {
+				return nil
+			}
+			restored++
+			return tx.Set(ref, data)
+		})
+	})
+	return restored, err
+}
+
+// readArchive recorre las líneas de un objeto de archivo
+func readArchive(ctx context.Context, client *firestore.Client, uri string, fn func(id string, data map[string]interface{}) error) error {
+	bucketName, object, ok := strings.Cut(strings.TrimPrefix(uri, "gs://"), "/")
+	if !ok {
+		return fmt.Errorf("invalid archive reference '%s'", uri)
+	}
+	bucket, err := firebase.GetStorageBucketByName(bucketName)
+	if err != nil {
+		return err
+	}
+	reader, err := bucket.Object(object).NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read archive '%s': %w", uri, err)
+	}
+	defer reader.Close()
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("failed to decompress archive '%s': %w", uri, err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+		decoder.UseNumber()
+		var line archivedLine
+		if err := decoder.Decode(&line); err != nil {
+			return fmt.Errorf("failed to parse archive '%s': %w", uri, err)
+		}
+		data, _ := DecodeJSONValue(client, line.Data).(map[string]interface{})
+		if err := fn(line.ID, data); err != nil {
+			return fmt.Errorf("failed to restore '%s' from archive: %w", line.ID, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read archive '%s': %w", uri, err)
+	}
+	return nil
+}
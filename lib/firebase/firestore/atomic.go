@@ -0,0 +1,104 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// AtomicWrite ejecuta las operaciones en una transacción (a diferencia de BatchWrite, que
+// usa un lote): primero lee los documentos con MustExist, Preconditions o tipo "check" y
+// verifica sus condiciones, y solo si todas se cumplen aplica las escrituras. Una
+// condición incumplida retorna un *firebase.PreconditionFailedError y no se escribe
+// nada. Ejemplo: crear un pedido, descontar inventario con la precondición stock >= n
+// (Data {"stock": firestore.Increment(-n)}) y agregar un asiento contable, todo o nada.
+func AtomicWrite(ctx context.Context, operations []firebase.BatchOperation) ([]firebase.BatchResult, error) {
+	results := make([]firebase.BatchResult, len(operations))
+	for i := range results {
+		results[i].Index = i
+	}
+	if len(operations) == 0 {
+		return results, nil
+	}
+
+	ctx, client, operations, err := startBatch(ctx, operations, results)
+	if err != nil {
+		return failResults(results, err), err
+	}
+
+	calls := make([]*firebase.Call, len(operations))
+	defer func() {
+		for i, call := range calls {
+			if call != nil {
+				firebase.FinishCall(ctx, call, results[i].Error)
+			}
+		}
+	}()
+
+	var writes []pendingWrite
+	var checks []int
+	refs := make([]*firestore.DocumentRef, len(operations))
+	for i, op := range operations {
+		write, call, err := prepareBatchOp(ctx, client, i, op, true)
+		calls[i] = call
+		if err != nil {
+			return failResults(results, err), err
+		}
+		refs[i] = write.ref
+		if op.Type == "check" || op.MustExist || len(op.Preconditions) > 0 {
+			checks = append(checks, i)
+		}
+		if write.kind != "check" {
+			writes = append(writes, write)
+		}
+	}
+
+	err = commitChecked(ctx, writes, func(tx *firestore.Transaction) error {
+		for _, i := range checks {
+			if err := checkPreconditions(tx, refs[i], operations[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("atomic write failed: %w", err)
+		return failResults(results, err), err
+	}
+
+	for i := range results {
+		results[i].Success = true
+	}
+	return results, nil
+}
+
+// checkPreconditions lee el documento dentro de la transacción y verifica sus condiciones
+func checkPreconditions(tx *firestore.Transaction, ref *firestore.DocumentRef, op firebase.BatchOperation) error {
+	snap, err := tx.Get(ref)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	exists := snap != nil && snap.Exists()
+	if !exists {
+		if op.MustExist || len(op.Preconditions) > 0 || op.Type == "check" {
+			return &firebase.PreconditionFailedError{Collection: op.Collection, DocumentID: ref.ID, Reason: "document does not exist"}
+		}
+		return nil
+	}
+
+	doc := &firebase.Document{ID: ref.ID, Data: snap.Data()}
+	for _, filter := range op.Preconditions {
+		if !MatchesFilters(doc, []firebase.QueryFilter{filter}) {
+			return &firebase.PreconditionFailedError{
+				Collection: op.Collection,
+				DocumentID: ref.ID,
+				Reason:     fmt.Sprintf("%s %s %v not satisfied", filter.Field, filter.Operator, filter.Value),
+			}
+		}
+	}
+	return nil
+}
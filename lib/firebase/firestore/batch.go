@@ -0,0 +1,470 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Límites de Firestore para un commit; los lotes que los superan se dividen en fragmentos
+const (
+	MaxBatchOperations = 500
+	MaxBatchBytes      = 10 << 20
+
+	defaultBatchConcurrency = 4
+)
+
+// preparedOp operación de un lote ya validada y lista para confirmarse
+type preparedOp struct {
+	index int
+	write pendingWrite
+}
+
+// BatchWrite realiza operaciones en lote (todas o ninguna). Todas las operaciones deben
+// pertenecer a la misma región (ver firebase.RouteCollection). Los lotes de más de
+// MaxBatchOperations operaciones o MaxBatchBytes se dividen automáticamente; en ese
+// caso cada fragmento es atómico pero el lote completo no.
+func BatchWrite(ctx context.Context, operations []firebase.BatchOperation) error {
+	_, err := BatchWriteWithResults(ctx, operations, firebase.BatchOptions{})
+	return err
+}
+
+// BatchWriteWithResults es como BatchWrite pero retorna un resultado por operación (en el
+// mismo orden) con el ID del documento (también el generado para los create sin
+// DocumentID) y la hora de escritura. Los valores firebase.BatchRef en Data se
+// reemplazan por el ID de la operación referida. En modo atómico una falla
+// aborta el lote y todos los resultados llevan el error; con options.BestEffort las
+// operaciones que fallan se marcan y el resto se confirma, y el error es un
+// *firebase.BatchError si alguna falló.
+func BatchWriteWithResults(ctx context.Context, operations []firebase.BatchOperation, options firebase.BatchOptions) ([]firebase.BatchResult, error) {
+	results := make([]firebase.BatchResult, len(operations))
+	for i := range results {
+		results[i].Index = i
+		results[i].DocumentID = operations[i].DocumentID
+	}
+	if len(operations) == 0 {
+		return results, nil
+	}
+
+	ctx, client, operations, err := startBatch(ctx, operations, results)
+	if err != nil {
+		return failResults(results, err), err
+	}
+
+	// Los hooks After de cada operación reciben el resultado de esa operación
+	calls := make([]*firebase.Call, len(operations))
+	defer func() {
+		for i, call := range calls {
+			if call != nil {
+				firebase.FinishCall(ctx, call, results[i].Error)
+			}
+		}
+	}()
+
+	var prepared []preparedOp
+	for i := range operations {
+		write, call, err := prepareBatchOp(ctx, client, i, operations[i], false)
+		calls[i] = call
+		if err != nil {
+			if !options.BestEffort {
+				return failResults(results, err), err
+			}
+			results[i].Error = err
+			continue
+		}
+		results[i].DocumentID = write.ref.ID
+		prepared = append(prepared, preparedOp{index: i, write: write})
+	}
+
+	if options.BestEffort {
+		commitBestEffort(ctx, client, prepared, results)
+		return results, batchOutcome(results)
+	}
+
+	chunks := chunkPrepared(prepared)
+	if len(chunks) > 1 {
+		commitChunks(ctx, client, chunks, results, options.MaxConcurrency)
+		return results, batchOutcome(results)
+	}
+
+	if err := commitAtomic(ctx, client, prepared, results); err != nil {
+		err = fmt.Errorf("failed to commit batch operations: %w", err)
+		return failResults(results, err), err
+	}
+	return results, nil
+}
+
+// batchOutcome retorna un *firebase.BatchError si alguna operación falló
+func batchOutcome(results []firebase.BatchResult) error {
+	failed := 0
+	for i := range results {
+		if results[i].Error != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return &firebase.BatchError{Failed: failed, Total: len(results)}
+	}
+	return nil
+}
+
+// chunkPrepared divide las escrituras en fragmentos que respetan los límites de un commit.
+// Las escrituras sobre colecciones con agregados cuentan también los documentos de rollup
+// que actualizan.
+func chunkPrepared(prepared []preparedOp) [][]preparedOp {
+	var chunks [][]preparedOp
+	var current []preparedOp
+	ops, bytes := 0, 0
+	for _, p := range prepared {
+		weight := 1 + len(rollupsFor(p.write.collection))
+		size := EstimateDocumentSize(p.write.collection, p.write.ref.ID, p.write.data)
+		if len(current) > 0 && (ops+weight > MaxBatchOperations || bytes+size > MaxBatchBytes) {
+			chunks = append(chunks, current)
+			current, ops, bytes = nil, 0, 0
+		}
+		current = append(current, p)
+		ops += weight
+		bytes += size
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// commitChunks confirma cada fragmento por separado con a lo sumo concurrency commits en
+// paralelo; una falla marca solo las operaciones de su fragmento
+func commitChunks(ctx context.Context, client *firestore.Client, chunks [][]preparedOp, results []firebase.BatchResult, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for n, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(n int, chunk []preparedOp) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Cada goroutine escribe solo los resultados de su fragmento
+			if err := commitAtomic(ctx, client, chunk, results); err != nil {
+				err = fmt.Errorf("failed to commit batch chunk %d: %w", n, err)
+				for _, p := range chunk {
+					results[p.index].Success = false
+					results[p.index].Error = err
+				}
+			}
+		}(n, chunk)
+	}
+	wg.Wait()
+}
+
+// startBatch fija la región del lote (todas las operaciones deben compartirla), asigna
+// los IDs de los create sin DocumentID y resuelve los BatchRef. Retorna una copia de las
+// operaciones para no modificar las del llamador.
+func startBatch(ctx context.Context, operations []firebase.BatchOperation, results []firebase.BatchResult) (context.Context, *firestore.Client, []firebase.BatchOperation, error) {
+	region := firebase.RegionFor(ctx, operations[0].Collection)
+	for _, op := range operations[1:] {
+		if other := firebase.RegionFor(ctx, op.Collection); other != region {
+			return ctx, nil, nil, fmt.Errorf("batch operations span regions '%s' and '%s'", region, other)
+		}
+	}
+	ctx = firebase.WithRegion(ctx, region)
+	client := firebase.FirestoreClientFor(ctx, operations[0].Collection)
+
+	operations = append([]firebase.BatchOperation(nil), operations...)
+	ids := make([]string, len(operations))
+	for i := range operations {
+		if operations[i].Type == "create" && operations[i].DocumentID == "" {
+			operations[i].DocumentID = client.Collection(operations[i].Collection).NewDoc().ID
+		}
+		ids[i] = operations[i].DocumentID
+		results[i].DocumentID = ids[i]
+	}
+	for i := range operations {
+		if containsBatchRef(operations[i].Data) {
+			resolved, err := resolveBatchRefs(operations[i].Data, ids)
+			if err != nil {
+				return ctx, nil, nil, fmt.Errorf("batch operation %d: %w", i, err)
+			}
+			operations[i].Data = resolved.(map[string]interface{})
+		}
+	}
+	return ctx, client, operations, nil
+}
+
+// prepareBatchOp pasa la operación por read-only, interceptores, plantillas, guardas y
+// políticas, y retorna la escritura pendiente. Las operaciones "check" y las
+// precondiciones solo se admiten en transacciones (AtomicWrite).
+func prepareBatchOp(ctx context.Context, client *firestore.Client, index int, op firebase.BatchOperation, transactional bool) (pendingWrite, *firebase.Call, error) {
+	if !transactional && (op.Type == "check" || op.MustExist || len(op.Preconditions) > 0) {
+		return pendingWrite{}, nil, fmt.Errorf("batch operation %d: checks and preconditions require AtomicWrite", index)
+	}
+	if op.Type != "check" {
+		if err := firebase.CheckWritable(op.Collection); err != nil {
+			return pendingWrite{}, nil, err
+		}
+	}
+	immutable := firebase.IsImmutable(op.Collection)
+	if immutable && (op.Type == "update" || op.Type == "delete") {
+		return pendingWrite{}, nil, firebase.CheckMutable(op.Collection, op.Type)
+	}
+
+	// op es una copia: los interceptores pueden modificarla sin tocar la del llamador
+	call := &firebase.Call{Operation: firebase.CallFirestoreBatch, Collection: op.Collection, DocumentID: op.DocumentID, Index: index, Payload: &op}
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return pendingWrite{}, call, fmt.Errorf("batch operation %d rejected: %w", index, err)
+	}
+	op.Collection = call.Collection
+
+	switch op.Type {
+	case "create":
+		docRef := client.Collection(op.Collection).NewDoc()
+		if op.DocumentID != "" {
+			docRef = client.Collection(op.Collection).Doc(op.DocumentID)
+		}
+		call.DocumentID = docRef.ID
+
+		applyTemplate(op.Collection, op.Data)
+
+		// Agregar timestamps automáticamente
+		now := firebase.Now()
+		op.Data["created_at"] = now
+		op.Data["updated_at"] = now
+
+		if err := compressFields(op.Collection, op.Data); err != nil {
+			return pendingWrite{}, call, err
+		}
+		blobs, err := offloadBlobs(op.Collection, op.Data)
+		if err != nil {
+			return pendingWrite{}, call, err
+		}
+		if err := ValidateDocument(op.Collection, docRef.ID, op.Data); err != nil {
+			return pendingWrite{}, call, err
+		}
+		if err := authorize(ctx, op.Collection, &firebase.Document{ID: op.DocumentID, Data: op.Data}, firebase.OperationCreate); err != nil {
+			return pendingWrite{}, call, err
+		}
+		if err := uploadBlobs(ctx, blobs); err != nil {
+			return pendingWrite{}, call, err
+		}
+		// En colecciones de solo inserción un ID existente falla en lugar de sobrescribirse
+		kind := "set"
+		if immutable {
+			kind = "create"
+		}
+		return pendingWrite{collection: op.Collection, ref: docRef, kind: kind, data: op.Data}, call, nil
+
+	case "update":
+		if err := authorizeStored(ctx, op.Collection, op.DocumentID, firebase.OperationUpdate); err != nil {
+			return pendingWrite{}, call, err
+		}
+		docRef := client.Collection(op.Collection).Doc(op.DocumentID)
+		op.Data["updated_at"] = firebase.Now()
+		if err := compressFields(op.Collection, op.Data); err != nil {
+			return pendingWrite{}, call, err
+		}
+		blobs, err := offloadBlobs(op.Collection, op.Data)
+		if err != nil {
+			return pendingWrite{}, call, err
+		}
+		if err := ValidateDocument(op.Collection, op.DocumentID, op.Data); err != nil {
+			return pendingWrite{}, call, err
+		}
+		if err := uploadBlobs(ctx, blobs); err != nil {
+			return pendingWrite{}, call, err
+		}
+		return pendingWrite{collection: op.Collection, ref: docRef, kind: "merge", data: op.Data}, call, nil
+
+	case "delete":
+		if err := authorizeStored(ctx, op.Collection, op.DocumentID, firebase.OperationDelete); err != nil {
+			return pendingWrite{}, call, err
+		}
+		if err := checkLegalHold(ctx, op.Collection, op.DocumentID); err != nil {
+			return pendingWrite{}, call, err
+		}
+		docRef := client.Collection(op.Collection).Doc(op.DocumentID)
+		return pendingWrite{collection: op.Collection, ref: docRef, kind: "delete"}, call, nil
+
+	case "check":
+		if err := authorizeStored(ctx, op.Collection, op.DocumentID, firebase.OperationRead); err != nil {
+			return pendingWrite{}, call, err
+		}
+		docRef := client.Collection(op.Collection).Doc(op.DocumentID)
+		return pendingWrite{collection: op.Collection, ref: docRef, kind: "check"}, call, nil
+	}
+	return pendingWrite{}, call, fmt.Errorf("unsupported batch operation type: %s", op.Type)
+}
+
+// commitAtomic confirma todas las escrituras juntas; las colecciones con agregados
+// materializados requieren una transacción
+func commitAtomic(ctx context.Context, client *firestore.Client, prepared []preparedOp, results []firebase.BatchResult) error {
+	writes := make([]pendingWrite, len(prepared))
+	useTransaction := false
+	for i, p := range prepared {
+		writes[i] = p.write
+		useTransaction = useTransaction || needsTransaction(p.write.collection)
+	}
+
+	if useTransaction {
+		if err := commitWithRollups(ctx, writes); err != nil {
+			return err
+		}
+		for _, p := range prepared {
+			results[p.index].Success = true
+		}
+		return nil
+	}
+
+	batch := client.Batch()
+	for _, w := range writes {
+		addToBatch(batch, w)
+	}
+	writeResults, err := batch.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	for i, p := range prepared {
+		results[p.index].Success = true
+		if i < len(writeResults) {
+			results[p.index].WriteTime = writeResults[i].UpdateTime
+		}
+	}
+	return nil
+}
+
+// commitBestEffort confirma cada escritura por separado con un BulkWriter (las de
+// colecciones con agregados, con su propia transacción)
+func commitBestEffort(ctx context.Context, client *firestore.Client, prepared []preparedOp, results []firebase.BatchResult) {
+	writer := client.BulkWriter(ctx)
+	jobs := map[int]*firestore.BulkWriterJob{}
+
+	for _, p := range prepared {
+		if needsTransaction(p.write.collection) {
+			if err := commitWithRollups(ctx, []pendingWrite{p.write}); err != nil {
+				results[p.index].Error = fmt.Errorf("failed to commit batch operation %d: %w", p.index, err)
+				continue
+			}
+			results[p.index].Success = true
+			continue
+		}
+
+		var job *firestore.BulkWriterJob
+		var err error
+		switch p.write.kind {
+		case "set":
+			job, err = writer.Set(p.write.ref, p.write.data)
+		case "create":
+			job, err = writer.Create(p.write.ref, p.write.data)
+		case "merge":
+			job, err = writer.Set(p.write.ref, p.write.data, firestore.MergeAll)
+		case "delete":
+			job, err = writer.Delete(p.write.ref)
+		}
+		if err != nil {
+			results[p.index].Error = fmt.Errorf("failed to enqueue batch operation %d: %w", p.index, err)
+			continue
+		}
+		jobs[p.index] = job
+	}
+	writer.End()
+
+	for index, job := range jobs {
+		writeResult, err := job.Results()
+		if err != nil {
+			results[index].Error = fmt.Errorf("failed to commit batch operation %d: %w", index, err)
+			continue
+		}
+		results[index].Success = true
+		results[index].WriteTime = writeResult.UpdateTime
+	}
+}
+
+func addToBatch(batch *firestore.WriteBatch, w pendingWrite) {
+	switch w.kind {
+	case "set":
+		batch.Set(w.ref, w.data)
+	case "create":
+		batch.Create(w.ref, w.data)
+	case "merge":
+		batch.Set(w.ref, w.data, firestore.MergeAll)
+	case "delete":
+		batch.Delete(w.ref)
+	}
+}
+
+// DocumentIDs retorna el ID del documento de cada operación, en el orden del lote
+func DocumentIDs(results []firebase.BatchResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.DocumentID
+	}
+	return ids
+}
+
+func containsBatchRef(value interface{}) bool {
+	switch v := value.(type) {
+	case firebase.BatchRef:
+		return true
+	case map[string]interface{}:
+		for _, item := range v {
+			if containsBatchRef(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if containsBatchRef(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveBatchRefs copia value reemplazando cada BatchRef por el ID correspondiente
+func resolveBatchRefs(value interface{}, ids []string) (interface{}, error) {
+	switch v := value.(type) {
+	case firebase.BatchRef:
+		if int(v) < 0 || int(v) >= len(ids) || ids[v] == "" {
+			return nil, fmt.Errorf("invalid batch reference to operation %d", int(v))
+		}
+		return ids[v], nil
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := resolveBatchRefs(item, ids)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := resolveBatchRefs(item, ids)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
+
+// failResults marca todas las operaciones del lote con el error
+func failResults(results []firebase.BatchResult, err error) []firebase.BatchResult {
+	for i := range results {
+		results[i].Success = false
+		results[i].Error = err
+	}
+	return results
+}
@@ -0,0 +1,185 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Campos de la referencia que reemplaza a un blob descargado a Cloud Storage
+const (
+	blobRefField  = "blob_ref"
+	blobSizeField = "blob_size"
+)
+
+// BlobOffloadOptions configura la descarga de campos []byte grandes a Cloud Storage
+type BlobOffloadOptions struct {
+	Threshold int    // tamaño en bytes a partir del cual se descarga (0 desactiva)
+	Bucket    string // por defecto firebase.GetStorageBucketName()
+	Prefix    string // prefijo de los objetos, por defecto "blobs"
+}
+
+var (
+	blobMu      sync.RWMutex
+	blobOptions BlobOffloadOptions
+)
+
+// SetBlobOffload configura la descarga de blobs. Los valores []byte mayores que
+// Threshold se suben a Cloud Storage y se reemplazan por una referencia que
+// GetDocument rehidrata de forma transparente.
+func SetBlobOffload(options BlobOffloadOptions) {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	if options.Prefix == "" {
+		options.Prefix = "blobs"
+	}
+	blobOptions = options
+}
+
+func currentBlobOptions() BlobOffloadOptions {
+	blobMu.RLock()
+	defer blobMu.RUnlock()
+	return blobOptions
+}
+
+// pendingBlob blob ya reemplazado por su referencia en el documento, pendiente de subir
+type pendingBlob struct {
+	bucket  string
+	object  string
+	field   string
+	content []byte
+}
+
+// offloadBlobs reemplaza los []byte que superan el umbral por referencias a Cloud
+// Storage y retorna los blobs pendientes. No sube nada: el documento se valida y
+// autoriza con las referencias y solo después se llama a uploadBlobs.
+func offloadBlobs(collection string, data map[string]interface{}) ([]pendingBlob, error) {
+	options := currentBlobOptions()
+	if options.Threshold <= 0 {
+		return nil, nil
+	}
+	var pending []pendingBlob
+	if err := offloadMap(options, collection, "", data, &pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+func offloadMap(options BlobOffloadOptions, collection, prefix string, data map[string]interface{}, pending *[]pendingBlob) error {
+	for key, value := range data {
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case []byte:
+			if len(v) <= options.Threshold {
+				continue
+			}
+			blob, err := newPendingBlob(options, collection, field, v)
+			if err != nil {
+				return err
+			}
+			*pending = append(*pending, blob)
+			data[key] = map[string]interface{}{blobRefField: blob.ref(), blobSizeField: len(v)}
+		case map[string]interface{}:
+			if err := offloadMap(options, collection, field, v, pending); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newPendingBlob(options BlobOffloadOptions, collection, field string, content []byte) (pendingBlob, error) {
+	bucketName := options.Bucket
+	if bucketName == "" {
+		bucketName = firebase.GetStorageBucketName()
+	}
+	id, err := NewULID()
+	if err != nil {
+		return pendingBlob{}, err
+	}
+	object := fmt.Sprintf("%s/%s/%s-%s", options.Prefix, strings.ReplaceAll(collection, "/", "_"), id, field)
+	return pendingBlob{bucket: bucketName, object: object, field: field, content: content}, nil
+}
+
+func (b pendingBlob) ref() string {
+	return fmt.Sprintf("gs://%s/%s", b.bucket, b.object)
+}
+
+// uploadBlobs sube los blobs retornados por offloadBlobs
+func uploadBlobs(ctx context.Context, pending []pendingBlob) error {
+	for _, blob := range pending {
+		bucket, err := firebase.GetStorageBucketByName(blob.bucket)
+		if err != nil {
+			return err
+		}
+		writer := bucket.Object(blob.object).NewWriter(ctx)
+		writer.ContentType = "application/octet-stream"
+		if _, err := writer.Write(blob.content); err != nil {
+			writer.Close()
+			return fmt.Errorf("failed to upload blob for field '%s': %w", blob.field, err)
+		}
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("failed to upload blob for field '%s': %w", blob.field, err)
+		}
+	}
+	return nil
+}
+
+// RehydrateBlobs reemplaza las referencias a blobs de un documento por su contenido
+func RehydrateBlobs(ctx context.Context, doc *firebase.Document) error {
+	return rehydrateMap(ctx, doc.Data)
+}
+
+func rehydrateMap(ctx context.Context, data map[string]interface{}) error {
+	for key, value := range data {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref, isBlob := nested[blobRefField].(string)
+		if !isBlob || !strings.HasPrefix(ref, "gs://") {
+			if err := rehydrateMap(ctx, nested); err != nil {
+				return err
+			}
+			continue
+		}
+
+		content, err := downloadBlob(ctx, ref)
+		if err != nil {
+			return err
+		}
+		data[key] = content
+	}
+	return nil
+}
+
+func downloadBlob(ctx context.Context, ref string) ([]byte, error) {
+	bucketName, object, ok := strings.Cut(strings.TrimPrefix(ref, "gs://"), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid blob reference '%s'", ref)
+	}
+	bucket, err := firebase.GetStorageBucketByName(bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := bucket.Object(object).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob '%s': %w", ref, err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob '%s': %w", ref, err)
+	}
+	return content, nil
+}
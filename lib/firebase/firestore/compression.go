@@ -0,0 +1,167 @@
+package firestore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Algoritmos de compresión de campos
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Campos del valor que reemplaza a un string comprimido
+const (
+	compressedField     = "$compressed"
+	compressedDataField = "data"
+)
+
+// CompressionOptions compresión de un campo de texto
+type CompressionOptions struct {
+	Algorithm string // CompressionGzip (por defecto) o CompressionZstd
+	MinSize   int    // bytes a partir de los cuales se comprime (por defecto 1024)
+}
+
+var (
+	compressionMu     sync.RWMutex
+	compressionFields = map[string]map[string]CompressionOptions{} // colección -> campo
+
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// RegisterCompression comprime de forma transparente un campo string (se admiten rutas con
+// puntos) de la colección: las escrituras del paquete lo guardan comprimido como bytes y
+// las lecturas (GetDocument, GetAllDocuments, consultas y listeners) lo descomprimen.
+// Los valores menores que MinSize se guardan sin cambios.
+func RegisterCompression(collection, field string, options CompressionOptions) error {
+	if options.Algorithm == "" {
+		options.Algorithm = CompressionGzip
+	}
+	if options.Algorithm != CompressionGzip && options.Algorithm != CompressionZstd {
+		return fmt.Errorf("unsupported compression algorithm: %s", options.Algorithm)
+	}
+	if options.MinSize <= 0 {
+		options.MinSize = 1024
+	}
+
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	if compressionFields[collection] == nil {
+		compressionFields[collection] = map[string]CompressionOptions{}
+	}
+	compressionFields[collection][field] = options
+	return nil
+}
+
+func compressedFieldsFor(collection string) map[string]CompressionOptions {
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+	return compressionFields[firebase.LogicalCollection(collection)]
+}
+
+// compressFields reemplaza los campos registrados por su versión comprimida
+func compressFields(collection string, data map[string]interface{}) error {
+	for field, options := range compressedFieldsFor(collection) {
+		parent, key, ok := fieldParent(data, field)
+		if !ok {
+			continue
+		}
+		text, ok := parent[key].(string)
+		if !ok || len(text) < options.MinSize {
+			continue
+		}
+		compressed, err := compress(options.Algorithm, []byte(text))
+		if err != nil {
+			return fmt.Errorf("failed to compress field '%s': %w", field, err)
+		}
+		parent[key] = map[string]interface{}{compressedField: options.Algorithm, compressedDataField: compressed}
+	}
+	return nil
+}
+
+// decompressDocuments restaura los campos comprimidos de los documentos leídos
+func decompressDocuments(collection string, docs ...*firebase.Document) error {
+	fields := compressedFieldsFor(collection)
+	if len(fields) == 0 {
+		return nil
+	}
+	for _, doc := range docs {
+		for field := range fields {
+			parent, key, ok := fieldParent(doc.Data, field)
+			if !ok {
+				continue
+			}
+			value, ok := parent[key].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			algorithm, _ := value[compressedField].(string)
+			content, _ := value[compressedDataField].([]byte)
+			if algorithm == "" {
+				continue
+			}
+			text, err := decompress(algorithm, content)
+			if err != nil {
+				return fmt.Errorf("failed to decompress field '%s' of document '%s': %w", field, doc.ID, err)
+			}
+			parent[key] = string(text)
+		}
+	}
+	return nil
+}
+
+// fieldParent retorna el mapa que contiene el último segmento de una ruta con puntos
+func fieldParent(data map[string]interface{}, field string) (map[string]interface{}, string, bool) {
+	parts := strings.Split(field, ".")
+	current := data
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			return nil, "", false
+		}
+		current = next
+	}
+	key := parts[len(parts)-1]
+	_, ok := current[key]
+	return current, key, ok
+}
+
+func compress(algorithm string, content []byte) ([]byte, error) {
+	if algorithm == CompressionZstd {
+		return zstdEncoder.EncodeAll(content, nil), nil
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(content); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(algorithm string, content []byte) ([]byte, error) {
+	switch algorithm {
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(content, nil)
+	case CompressionGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return io.ReadAll(gz)
+	}
+	return nil, fmt.Errorf("unsupported compression algorithm: %s", algorithm)
+}
@@ -0,0 +1,33 @@
+package firestore
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// encodeCursor genera un cursor opaco a partir del ID del último documento devuelto
+func encodeCursor(docID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(docID))
+}
+
+// decodeCursor obtiene el snapshot del documento referenciado por el cursor
+func decodeCursor(ctx context.Context, collection, cursor string) (*firestore.DocumentSnapshot, error) {
+	docID, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(docID) == 0 {
+		return nil, fmt.Errorf("invalid query cursor")
+	}
+
+	snap, err := firebase.FirestoreClientFor(ctx, collection).Collection(collection).Doc(string(docID)).Get(ctx)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("query cursor document '%s' no longer exists", docID)
+		}
+		return nil, fmt.Errorf("failed to resolve query cursor: %w", err)
+	}
+	return snap, nil
+}
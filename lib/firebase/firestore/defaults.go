@@ -0,0 +1,110 @@
+package firestore
+
+import (
+	"strings"
+	"sync"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// ComputedField calcula el valor de un campo a partir de los datos del documento
+// (retornar nil deja el campo sin modificar)
+type ComputedField func(data map[string]interface{}) interface{}
+
+type collectionTemplate struct {
+	defaults map[string]interface{}
+	computed []computedEntry
+}
+
+type computedEntry struct {
+	field string
+	fn    ComputedField
+}
+
+var (
+	templatesMu sync.RWMutex
+	templates   = map[string]*collectionTemplate{}
+)
+
+// RegisterDefaults registra valores por defecto que se aplican al crear documentos en la
+// colección cuando el campo no viene en los datos
+func RegisterDefaults(collection string, defaults map[string]interface{}) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+
+	tpl := templateFor(collection)
+	for field, value := range defaults {
+		tpl.defaults[field] = value
+	}
+}
+
+// RegisterComputedField registra un campo calculado que se aplica al crear documentos
+// en la colección (después de los valores por defecto)
+func RegisterComputedField(collection, field string, fn ComputedField) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+
+	tpl := templateFor(collection)
+	tpl.computed = append(tpl.computed, computedEntry{field: field, fn: fn})
+}
+
+// Lowercase retorna un ComputedField con el valor en minúsculas del campo source
+// (por ejemplo, para normalizar emails)
+func Lowercase(source string) ComputedField {
+	return func(data map[string]interface{}) interface{} {
+		if value, ok := data[source].(string); ok {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+		return nil
+	}
+}
+
+// templateFor obtiene (o crea) la plantilla de una colección; requiere templatesMu
+func templateFor(collection string) *collectionTemplate {
+	tpl, ok := templates[collection]
+	if !ok {
+		tpl = &collectionTemplate{defaults: map[string]interface{}{}}
+		templates[collection] = tpl
+	}
+	return tpl
+}
+
+// applyTemplate aplica los valores por defecto y los campos calculados de la colección
+func applyTemplate(collection string, data map[string]interface{}) {
+	templatesMu.RLock()
+	tpl, ok := templates[firebase.LogicalCollection(collection)]
+	templatesMu.RUnlock()
+	if !ok {
+		return
+	}
+
+	for field, value := range tpl.defaults {
+		if _, exists := data[field]; !exists {
+			data[field] = copyDefault(value)
+		}
+	}
+	for _, entry := range tpl.computed {
+		if value := entry.fn(data); value != nil {
+			data[entry.field] = value
+		}
+	}
+}
+
+// copyDefault copia mapas y slices para que los documentos no compartan el valor registrado
+func copyDefault(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			copied[k] = copyDefault(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyDefault(item)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,216 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+const (
+	denormalizationJobsCollection = "denormalization_jobs"
+	denormalizationPageSize       = 500
+)
+
+// DenormalizationRule declara que SourceCollection.SourceField se copia en
+// TargetCollection.TargetField de los documentos cuyo ForeignKey apunta al origen
+// (por ejemplo users.display_name -> posts.author_name vía posts.author_id)
+type DenormalizationRule struct {
+	SourceCollection string `json:"source_collection"`
+	SourceField      string `json:"source_field"`
+	TargetCollection string `json:"target_collection"`
+	TargetField      string `json:"target_field"`
+	ForeignKey       string `json:"foreign_key"`
+}
+
+// key identifica la regla en los documentos de trabajo
+func (r DenormalizationRule) key() string {
+	return fmt.Sprintf("%s.%s->%s.%s", r.SourceCollection, r.SourceField, r.TargetCollection, r.TargetField)
+}
+
+var (
+	denormMu    sync.RWMutex
+	denormRules = map[string][]DenormalizationRule{}
+)
+
+// RegisterDenormalization registra una regla de desnormalización. Cuando el campo origen
+// cambia a través del paquete, el nuevo valor se propaga a todos los documentos destino
+// en lotes; el progreso se guarda en denormalization_jobs para poder reanudarlo.
+func RegisterDenormalization(rule DenormalizationRule) error {
+	if rule.SourceCollection == "" || rule.SourceField == "" || rule.TargetCollection == "" || rule.TargetField == "" || rule.ForeignKey == "" {
+		return fmt.Errorf("denormalization rule requires source, target and foreign key fields")
+	}
+
+	denormMu.Lock()
+	defer denormMu.Unlock()
+	denormRules[rule.SourceCollection] = append(denormRules[rule.SourceCollection], rule)
+	return nil
+}
+
+func denormalizationRulesFor(collection string) []DenormalizationRule {
+	denormMu.RLock()
+	defer denormMu.RUnlock()
+	return append([]DenormalizationRule(nil), denormRules[firebase.LogicalCollection(collection)]...)
+}
+
+// loadDenormalizationSource lee el estado previo del documento origen si la colección
+// tiene reglas de desnormalización (nil en caso contrario)
+func loadDenormalizationSource(ctx context.Context, collection, docID string) (map[string]interface{}, error) {
+	if len(denormalizationRulesFor(collection)) == 0 {
+		return nil, nil
+	}
+
+	snap, err := firebase.FirestoreClientFor(ctx, collection).Collection(collection).Doc(docID).Get(ctx)
+	if err != nil && !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to read denormalization source '%s': %w", docID, err)
+	}
+	if snap != nil && snap.Exists() {
+		return snap.Data(), nil
+	}
+	return map[string]interface{}{}, nil
+}
+
+// propagateDenormalized compara el estado previo con el resultado de la escritura y
+// propaga los campos origen que cambiaron
+func propagateDenormalized(ctx context.Context, collection, docID string, previous map[string]interface{}, write pendingWrite) error {
+	if previous == nil {
+		return nil
+	}
+
+	next := write.nextState(previous)
+	for _, rule := range denormalizationRulesFor(collection) {
+		value, ok := next[rule.SourceField]
+		if !ok || reflect.DeepEqual(previous[rule.SourceField], value) {
+			continue
+		}
+		if err := SyncDenormalization(ctx, rule, docID, value); err != nil {
+			return fmt.Errorf("document written but denormalization is pending: %w", err)
+		}
+	}
+	return nil
+}
+
+// SyncDenormalization copia value en todos los documentos destino que referencian a
+// sourceID. El trabajo se registra en denormalization_jobs y avanza página a página.
+func SyncDenormalization(ctx context.Context, rule DenormalizationRule, sourceID string, value interface{}) error {
+	client := firebase.GetFirestoreClient()
+
+	jobRef := client.Collection(denormalizationJobsCollection).Doc(rule.TargetCollection + "_" + rule.TargetField + "_" + sourceID)
+	_, err := jobRef.Set(ctx, map[string]interface{}{
+		"rule":       rule.key(),
+		"source_id":  sourceID,
+		"value":      value,
+		"cursor":     "",
+		"status":     "running",
+		"updated_at": firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create denormalization job: %w", err)
+	}
+
+	return runDenormalizationJob(ctx, rule, jobRef, sourceID, value, "")
+}
+
+// ResumeDenormalizationJobs reanuda los trabajos de desnormalización que no terminaron
+func ResumeDenormalizationJobs(ctx context.Context) error {
+	client := firebase.GetFirestoreClient()
+
+	iter := client.Collection(denormalizationJobsCollection).Where("status", "==", "running").Documents(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list denormalization jobs: %w", err)
+		}
+
+		data := snap.Data()
+		ruleKey, _ := data["rule"].(string)
+		sourceID, _ := data["source_id"].(string)
+		cursor, _ := data["cursor"].(string)
+
+		rule, ok := findDenormalizationRule(ruleKey)
+		if !ok {
+			continue
+		}
+		if err := runDenormalizationJob(ctx, rule, snap.Ref, sourceID, data["value"], cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findDenormalizationRule(key string) (DenormalizationRule, bool) {
+	denormMu.RLock()
+	defer denormMu.RUnlock()
+	for _, rules := range denormRules {
+		for _, rule := range rules {
+			if rule.key() == key {
+				return rule, true
+			}
+		}
+	}
+	return DenormalizationRule{}, false
+}
+
+// runDenormalizationJob actualiza los destinos página a página con BatchWrite (read-only,
+// interceptores, políticas y updated_at como cualquier escritura del paquete) y guarda el
+// cursor después de cada página; reanudar repite a lo sumo la última página, que es
+// idempotente
+func runDenormalizationJob(ctx context.Context, rule DenormalizationRule, jobRef *firestore.DocumentRef, sourceID string, value interface{}, cursor string) error {
+	targets := firebase.FirestoreClientFor(ctx, rule.TargetCollection).Collection(rule.TargetCollection)
+
+	for {
+		query := targets.Where(rule.ForeignKey, "==", sourceID).OrderBy(firestore.DocumentID, firestore.Asc).Limit(denormalizationPageSize)
+		if cursor != "" {
+			query = query.StartAfter(cursor)
+		}
+
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to list denormalization targets: %w", err)
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		operations := make([]firebase.BatchOperation, len(docs))
+		for i, doc := range docs {
+			operations[i] = firebase.BatchOperation{
+				Type:       "update",
+				Collection: rule.TargetCollection,
+				DocumentID: doc.Ref.ID,
+				Data:       map[string]interface{}{rule.TargetField: value},
+			}
+		}
+		if err := BatchWrite(ctx, operations); err != nil {
+			return fmt.Errorf("failed to update denormalized field '%s' in '%s': %w", rule.TargetField, rule.TargetCollection, err)
+		}
+		cursor = docs[len(docs)-1].Ref.ID
+		if _, err := jobRef.Update(ctx, []firestore.Update{
+			{Path: "cursor", Value: cursor},
+			{Path: "updated_at", Value: firebase.Now()},
+		}); err != nil {
+			return fmt.Errorf("failed to save denormalization job cursor: %w", err)
+		}
+
+		if len(docs) < denormalizationPageSize {
+			break
+		}
+	}
+
+	if _, err := jobRef.Update(ctx, []firestore.Update{
+		{Path: "status", Value: "done"},
+		{Path: "updated_at", Value: firebase.Now()},
+	}); err != nil {
+		return fmt.Errorf("failed to complete denormalization job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// normalizeDisjunctions convierte los valores de filtros "in", "not-in" y
+// "array-contains-any" a []interface{}, valida los límites y retorna el índice del
+// filtro que debe dividirse en varias consultas (-1 si ninguno)
+func normalizeDisjunctions(filters []firebase.QueryFilter) ([]firebase.QueryFilter, int, error) {
+	normalized := make([]firebase.QueryFilter, len(filters))
+	copy(normalized, filters)
+
+	chunkIndex := -1
+	for i, filter := range normalized {
+		switch filter.Operator {
+		case firebase.OpIn, firebase.OpNotIn, firebase.OpArrayContainsAny:
+		default:
+			continue
+		}
+
+		values, ok := toInterfaceSlice(filter.Value)
+		if !ok {
+			return nil, -1, fmt.Errorf("filter %q on field '%s' requires a slice value, got %T", filter.Operator, filter.Field, filter.Value)
+		}
+		normalized[i].Value = values
+
+		if filter.Operator == firebase.OpNotIn {
+			if len(values) > firebase.MaxNotInValues {
+				return nil, -1, &firebase.DisjunctionLimitError{Field: filter.Field, Operator: filter.Operator, Count: len(values), Max: firebase.MaxNotInValues}
+			}
+			continue
+		}
+
+		if len(values) > firebase.MaxDisjunctionValues {
+			if chunkIndex >= 0 {
+				// Solo se puede dividir un filtro; la combinación cruzada no está soportada
+				return nil, -1, &firebase.DisjunctionLimitError{Field: filter.Field, Operator: filter.Operator, Count: len(values), Max: firebase.MaxDisjunctionValues}
+			}
+			chunkIndex = i
+		}
+	}
+	return normalized, chunkIndex, nil
+}
+
+// queryChunked ejecuta una consulta por cada bloque de valores del filtro disyuntivo y
+// combina los resultados (sin duplicados), aplicando orden, offset y límite en memoria
+func queryChunked(ctx context.Context, collection string, options firebase.QueryOptions, chunkIndex int) (*firebase.QueryResult, error) {
+	filter := options.Filters[chunkIndex]
+	values := filter.Value.([]interface{})
+
+	chunkOptions := options
+	chunkOptions.Offset = 0
+	if options.Limit > 0 {
+		chunkOptions.Limit = options.Offset + options.Limit
+	}
+
+	result := &firebase.QueryResult{}
+	seen := make(map[string]bool)
+	var merged []*firebase.Document
+	for start := 0; start < len(values); start += firebase.MaxDisjunctionValues {
+		end := start + firebase.MaxDisjunctionValues
+		if end > len(values) {
+			end = len(values)
+		}
+
+		chunkOptions.Filters = make([]firebase.QueryFilter, len(options.Filters))
+		copy(chunkOptions.Filters, options.Filters)
+		chunkOptions.Filters[chunkIndex].Value = values[start:end]
+
+		chunk, err := QueryDocumentsWithMeta(ctx, collection, chunkOptions)
+		if err != nil {
+			return nil, err
+		}
+		result.DocumentsRead += chunk.DocumentsRead
+		result.Truncated = result.Truncated || chunk.Truncated
+		if result.ReadTime.IsZero() {
+			result.ReadTime = chunk.ReadTime
+		}
+		for _, doc := range chunk.Documents {
+			if !seen[doc.ID] {
+				seen[doc.ID] = true
+				merged = append(merged, doc)
+			}
+		}
+	}
+
+	if clauses := options.OrderClauses(); len(clauses) > 0 {
+		sort.SliceStable(merged, func(i, j int) bool {
+			return compareDocuments(merged[i], merged[j], clauses) < 0
+		})
+	}
+
+	if options.Offset > 0 {
+		if options.Offset >= len(merged) {
+			merged = nil
+		} else {
+			merged = merged[options.Offset:]
+		}
+	}
+	if options.Limit > 0 && len(merged) > options.Limit {
+		merged = merged[:options.Limit]
+		result.Truncated = true
+	}
+	result.Documents = merged
+	return result, nil
+}
+
+// compareDocuments compara dos documentos según los criterios de ordenamiento, usando el
+// ID como desempate final
+func compareDocuments(a, b *firebase.Document, clauses []firebase.OrderClause) int {
+	for _, clause := range clauses {
+		var cmp int
+		if clause.Field == firestore.DocumentID {
+			cmp = strings.Compare(a.ID, b.ID)
+		} else {
+			cmp = compareValues(a.Data[clause.Field], b.Data[clause.Field])
+		}
+		if clause.Direction == "desc" {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// toInterfaceSlice convierte cualquier slice o array a []interface{}
+func toInterfaceSlice(value interface{}) ([]interface{}, bool) {
+	if values, ok := value.([]interface{}); ok {
+		return values, true
+	}
+
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return nil, false
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, true
+}
+
+// compareValues compara dos valores de Firestore siguiendo (de forma simplificada) su
+// orden de tipos: nil < bool < números < time < string
+func compareValues(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch va := a.(type) {
+	case bool:
+		vb := b.(bool)
+		switch {
+		case va == vb:
+			return 0
+		case !va:
+			return -1
+		default:
+			return 1
+		}
+	case time.Time:
+		return va.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(va, b.(string))
+	}
+
+	if fa, ok := toFloat(a); ok {
+		fb, _ := toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+	}
+	return 0
+}
+
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int, int32, int64, float32, float64:
+		return 2
+	case time.Time:
+		return 3
+	case string:
+		return 4
+	default:
+		return 5
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,125 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// ExplainScanRatio cantidad de entradas de índice escaneadas por resultado a partir de la
+// cual ExplainQuery advierte que la consulta escanea más de lo necesario
+var ExplainScanRatio int64 = 10
+
+// ExplainQuery ejecuta la consulta con Query Explain (analyze) y retorna los índices usados,
+// las lecturas facturadas y las estadísticas de escaneo, con advertencias cuando la
+// consulta combina varios índices de un campo (falta un índice compuesto) o escanea
+// muchas más entradas que resultados. La consulta se ejecuta de verdad: se facturan sus
+// lecturas. Las consultas divididas por límites de disyunción no se admiten.
+func ExplainQuery(ctx context.Context, collection string, options firebase.QueryOptions) (_ *firebase.QueryExplain, err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	call := newCall(firebase.CallFirestoreQuery, collection, "", &options)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
+	collection = call.Collection
+
+	filters, chunkIndex, err := normalizeDisjunctions(options.Filters)
+	if err != nil {
+		return nil, err
+	}
+	if chunkIndex >= 0 {
+		return nil, fmt.Errorf("cannot explain a query split by disjunction limits")
+	}
+
+	query, err := applyFilters(client.Collection(collection).Query, filters)
+	if err != nil {
+		return nil, err
+	}
+	query = applyOrders(query, options.OrderClauses())
+	if options.Offset > 0 {
+		query = query.Offset(options.Offset)
+	}
+	if options.Limit > 0 {
+		query = query.Limit(options.Limit)
+	}
+
+	iter := query.WithRunOptions(firestore.ExplainOptions{Analyze: true}).Documents(ctx)
+	defer iter.Stop()
+	for {
+		if _, err := iter.Next(); err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, indexError(ctx, collection, err, fmt.Errorf("failed to explain query on collection '%s': %w", collection, err))
+		}
+	}
+
+	metrics, err := iter.ExplainMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get explain metrics for collection '%s': %w", collection, err)
+	}
+
+	explain := &firebase.QueryExplain{}
+	if metrics.PlanSummary != nil {
+		for _, index := range metrics.PlanSummary.IndexesUsed {
+			if index != nil {
+				explain.IndexesUsed = append(explain.IndexesUsed, *index)
+			}
+		}
+	}
+	if stats := metrics.ExecutionStats; stats != nil {
+		explain.ResultsReturned = stats.ResultsReturned
+		explain.ReadOperations = stats.ReadOperations
+		if stats.ExecutionDuration != nil {
+			explain.ExecutionDuration = *stats.ExecutionDuration
+		}
+		if stats.DebugStats != nil {
+			explain.DebugStats = *stats.DebugStats
+			explain.DocumentsScanned = statInt(explain.DebugStats["documents_scanned"])
+			explain.IndexEntriesScanned = statInt(explain.DebugStats["index_entries_scanned"])
+		}
+	}
+	explain.Warnings = explainWarnings(explain)
+
+	call.Result = int(explain.ReadOperations)
+	return explain, nil
+}
+
+// explainWarnings detecta los patrones que suelen indicar un índice faltante
+func explainWarnings(explain *firebase.QueryExplain) []string {
+	var warnings []string
+	if len(explain.IndexesUsed) > 1 {
+		warnings = append(warnings, fmt.Sprintf("query merges %d indexes; a composite index may be cheaper", len(explain.IndexesUsed)))
+	}
+	results := explain.ResultsReturned
+	if results < 1 {
+		results = 1
+	}
+	if explain.IndexEntriesScanned > results*ExplainScanRatio {
+		warnings = append(warnings, fmt.Sprintf("query scanned %d index entries for %d results", explain.IndexEntriesScanned, explain.ResultsReturned))
+	}
+	if explain.DocumentsScanned > results*ExplainScanRatio {
+		warnings = append(warnings, fmt.Sprintf("query scanned %d documents for %d results", explain.DocumentsScanned, explain.ResultsReturned))
+	}
+	return warnings
+}
+
+// statInt convierte un valor de las estadísticas de depuración (que llegan como string)
+func statInt(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return 0
+}
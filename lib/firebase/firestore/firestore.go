@@ -2,25 +2,76 @@ package firestore
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	firebase "github.com/andrescris/firestore/lib/firebase"
 )
 
 // CreateDocument crea un nuevo documento en la colección especificada
-func CreateDocument(ctx context.Context, collection string, data map[string]interface{}) (string, error) {
-	client := firebase.GetFirestoreClient()
+func CreateDocument(ctx context.Context, collection string, data map[string]interface{}) (_ string, err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	if err := firebase.CheckWritable(collection); err != nil {
+		return "", err
+	}
+	call := newCall(firebase.CallFirestoreCreate, collection, "", data)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return "", err
+	}
+	collection = call.Collection
+
+	// Aplicar valores por defecto y campos calculados de la colección
+	applyTemplate(collection, data)
 
 	// Agregar timestamps automáticamente
-	now := time.Now()
+	now := firebase.Now()
 	data["created_at"] = now
 	data["updated_at"] = now
 
-	docRef, _, err := client.Collection(collection).Add(ctx, data)
+	if err := compressFields(collection, data); err != nil {
+		return "", err
+	}
+	blobs, err := offloadBlobs(collection, data)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateDocument(collection, "", data); err != nil {
+		return "", err
+	}
+	if err := authorize(ctx, collection, &firebase.Document{Data: data}, firebase.OperationCreate); err != nil {
+		return "", err
+	}
+	if err := uploadBlobs(ctx, blobs); err != nil {
+		return "", err
+	}
+
+	// Generar el ID con la estrategia de la colección (o aleatorio)
+	docRef := client.Collection(collection).NewDoc()
+	if hasIDStrategy(collection) {
+		docID, err := GenerateID(ctx, collection, data)
+		if err != nil {
+			return "", err
+		}
+		docRef = client.Collection(collection).Doc(docID)
+	}
+	// Los hooks After reciben el ID generado
+	call.DocumentID = docRef.ID
+
+	if needsTransaction(collection) {
+		if err := commitWithRollups(ctx, []pendingWrite{{collection: collection, ref: docRef, kind: "create", data: data}}); err != nil {
+			return "", fmt.Errorf("failed to create document in collection '%s': %w", collection, err)
+		}
+		return docRef.ID, nil
+	}
+
+	_, err = docRef.Create(ctx, data)
 	if err != nil {
 		return "", fmt.Errorf("failed to create document in collection '%s': %w", collection, err)
 	}
@@ -30,24 +81,93 @@ func CreateDocument(ctx context.Context, collection string, data map[string]inte
 
 // CreateDocumentWithID crea un documento con un ID específico
 func CreateDocumentWithID(ctx context.Context, collection, docID string, data map[string]interface{}) error {
-	client := firebase.GetFirestoreClient()
+	return createDocumentWithID(ctx, collection, docID, data, false)
+}
+
+// CreateDocumentIfAbsent crea un documento con un ID específico solo si no existe; si ya
+// existe falla (ver IsAlreadyExists) sin modificarlo
+func CreateDocumentIfAbsent(ctx context.Context, collection, docID string, data map[string]interface{}) error {
+	return createDocumentWithID(ctx, collection, docID, data, true)
+}
+
+func createDocumentWithID(ctx context.Context, collection, docID string, data map[string]interface{}, mustNotExist bool) (err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	if err := firebase.CheckWritable(collection); err != nil {
+		return err
+	}
+	// En colecciones de solo inserción un ID existente falla en lugar de sobrescribirse
+	kind := "set"
+	if mustNotExist || firebase.IsImmutable(collection) {
+		kind = "create"
+	}
+	call := newCall(firebase.CallFirestoreCreate, collection, docID, data)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
+	collection = call.Collection
+
+	// Aplicar valores por defecto y campos calculados de la colección
+	applyTemplate(collection, data)
 
 	// Agregar timestamps automáticamente
-	now := time.Now()
+	now := firebase.Now()
 	data["created_at"] = now
 	data["updated_at"] = now
 
-	_, err := client.Collection(collection).Doc(docID).Set(ctx, data)
+	if err := compressFields(collection, data); err != nil {
+		return err
+	}
+	blobs, err := offloadBlobs(collection, data)
+	if err != nil {
+		return err
+	}
+	if err := ValidateDocument(collection, docID, data); err != nil {
+		return err
+	}
+	if err := authorize(ctx, collection, &firebase.Document{ID: docID, Data: data}, firebase.OperationCreate); err != nil {
+		return err
+	}
+	if err := uploadBlobs(ctx, blobs); err != nil {
+		return err
+	}
+
+	previous, err := loadDenormalizationSource(ctx, collection, docID)
+	if err != nil {
+		return err
+	}
+	write := pendingWrite{collection: collection, ref: client.Collection(collection).Doc(docID), kind: kind, data: data}
+
+	if needsTransaction(collection) {
+		if err := commitWithRollups(ctx, []pendingWrite{write}); err != nil {
+			return fmt.Errorf("failed to create document with ID '%s' in collection '%s': %w", docID, collection, err)
+		}
+		return propagateDenormalized(ctx, collection, docID, previous, write)
+	}
+
+	if kind == "create" {
+		_, err = client.Collection(collection).Doc(docID).Create(ctx, data)
+	} else {
+		_, err = client.Collection(collection).Doc(docID).Set(ctx, data)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create document with ID '%s' in collection '%s': %w", docID, collection, err)
 	}
 
-	return nil
+	return propagateDenormalized(ctx, collection, docID, previous, write)
 }
 
 // GetDocument obtiene un documento por su ID
-func GetDocument(ctx context.Context, collection, docID string) (*firebase.Document, error) {
-	client := firebase.GetFirestoreClient()
+func GetDocument(ctx context.Context, collection, docID string) (_ *firebase.Document, err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	call := newCall(firebase.CallFirestoreGet, collection, docID, nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
+	collection = call.Collection
 
 	doc, err := client.Collection(collection).Doc(docID).Get(ctx)
 	if err != nil {
@@ -58,17 +178,45 @@ func GetDocument(ctx context.Context, collection, docID string) (*firebase.Docum
 		return nil, &firebase.DocumentNotFoundError{Collection: collection, DocumentID: docID}
 	}
 
-	return &firebase.Document{
+	document := &firebase.Document{
 		ID:   doc.Ref.ID,
 		Data: doc.Data(),
-	}, nil
+	}
+	if err := authorize(ctx, collection, document, firebase.OperationRead); err != nil {
+		return nil, err
+	}
+	if currentBlobOptions().Threshold > 0 {
+		if err := RehydrateBlobs(ctx, document); err != nil {
+			return nil, err
+		}
+	}
+	if err := decompressDocuments(collection, document); err != nil {
+		return nil, err
+	}
+
+	call.Result = document
+	return document, nil
 }
 
 // GetAllDocuments obtiene todos los documentos de una colección
-func GetAllDocuments(ctx context.Context, collection string) ([]*firebase.Document, error) {
-	client := firebase.GetFirestoreClient()
+func GetAllDocuments(ctx context.Context, collection string) (_ []*firebase.Document, err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	// Sin filtros propios; un interceptor puede agregarlos (p. ej. el de tenancy)
+	var options firebase.QueryOptions
+	call := newCall(firebase.CallFirestoreQuery, collection, "", &options)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
+	collection = call.Collection
+
+	query, err := applyFilters(client.Collection(collection).Query, options.Filters)
+	if err != nil {
+		return nil, err
+	}
 
-	iter := client.Collection(collection).Documents(ctx)
+	iter := query.Documents(ctx)
 	defer iter.Stop()
 
 	var documents []*firebase.Document
@@ -79,7 +227,7 @@ func GetAllDocuments(ctx context.Context, collection string) ([]*firebase.Docume
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to iterate documents in collection '%s': %w", collection, err)
+			return nil, indexError(ctx, collection, err, fmt.Errorf("failed to iterate documents in collection '%s': %w", collection, err))
 		}
 
 		documents = append(documents, &firebase.Document{
@@ -88,47 +236,184 @@ func GetAllDocuments(ctx context.Context, collection string) ([]*firebase.Docume
 		})
 	}
 
+	documents = filterReadable(ctx, collection, documents)
+	if err := decompressDocuments(collection, documents...); err != nil {
+		return nil, err
+	}
+	call.Result = documents
 	return documents, nil
 }
 
-// UpdateDocument actualiza un documento existente (merge completo)
-func UpdateDocument(ctx context.Context, collection, docID string, data map[string]interface{}) error {
-	client := firebase.GetFirestoreClient()
+// UpdateDocument actualiza un documento existente (merge completo, o con las estrategias
+// de RegisterMergeStrategy si la colección las tiene)
+func UpdateDocument(ctx context.Context, collection, docID string, data map[string]interface{}) (err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	if err := firebase.CheckWritable(collection); err != nil {
+		return err
+	}
+	if err := firebase.CheckMutable(collection, "update"); err != nil {
+		return err
+	}
+	call := newCall(firebase.CallFirestoreUpdate, collection, docID, data)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
+	collection = call.Collection
+
+	if err := authorizeStored(ctx, collection, docID, firebase.OperationUpdate); err != nil {
+		return err
+	}
 
 	// Agregar timestamp de actualización
-	data["updated_at"] = time.Now()
+	data["updated_at"] = firebase.Now()
 
-	_, err := client.Collection(collection).Doc(docID).Set(ctx, data, firestore.MergeAll)
+	if err := compressFields(collection, data); err != nil {
+		return err
+	}
+	blobs, err := offloadBlobs(collection, data)
+	if err != nil {
+		return err
+	}
+	if err := ValidateDocument(collection, docID, data); err != nil {
+		return err
+	}
+	if err := uploadBlobs(ctx, blobs); err != nil {
+		return err
+	}
+
+	previous, err := loadDenormalizationSource(ctx, collection, docID)
+	if err != nil {
+		return err
+	}
+	write := pendingWrite{collection: collection, ref: client.Collection(collection).Doc(docID), kind: "merge", data: data}
+
+	// Con estrategias de merge el valor final depende del almacenado: se lee en la
+	// transacción (incoming conserva los valores originales para los reintentos)
+	if hasMergeStrategies(collection) {
+		incoming := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			incoming[k] = v
+		}
+		err := commitChecked(ctx, []pendingWrite{write}, func(tx *firestore.Transaction) error {
+			snap, err := tx.Get(write.ref)
+			if err != nil && !IsNotFound(err) {
+				return err
+			}
+			var current map[string]interface{}
+			if snap != nil && snap.Exists() {
+				current = snap.Data()
+			}
+			mergeWrite(collection, data, incoming, current)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update document '%s' in collection '%s': %w", docID, collection, err)
+		}
+		return propagateDenormalized(ctx, collection, docID, previous, write)
+	}
+
+	if needsTransaction(collection) {
+		if err := commitWithRollups(ctx, []pendingWrite{write}); err != nil {
+			return fmt.Errorf("failed to update document '%s' in collection '%s': %w", docID, collection, err)
+		}
+		return propagateDenormalized(ctx, collection, docID, previous, write)
+	}
+
+	_, err = client.Collection(collection).Doc(docID).Set(ctx, data, firestore.MergeAll)
 	if err != nil {
 		return fmt.Errorf("failed to update document '%s' in collection '%s': %w", docID, collection, err)
 	}
 
-	return nil
+	return propagateDenormalized(ctx, collection, docID, previous, write)
 }
 
 // UpdateDocumentFields actualiza campos específicos de un documento
-func UpdateDocumentFields(ctx context.Context, collection, docID string, updates []firestore.Update) error {
-	client := firebase.GetFirestoreClient()
+func UpdateDocumentFields(ctx context.Context, collection, docID string, updates []firestore.Update) (err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	if err := firebase.CheckWritable(collection); err != nil {
+		return err
+	}
+	if err := firebase.CheckMutable(collection, "update"); err != nil {
+		return err
+	}
+	call := newCall(firebase.CallFirestoreUpdate, collection, docID, updates)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
+	collection = call.Collection
+
+	if err := authorizeStored(ctx, collection, docID, firebase.OperationUpdate); err != nil {
+		return err
+	}
 
 	// Agregar timestamp de actualización
 	updates = append(updates, firestore.Update{
 		Path:  "updated_at",
-		Value: time.Now(),
+		Value: firebase.Now(),
 	})
 
-	_, err := client.Collection(collection).Doc(docID).Update(ctx, updates)
+	if err := validateUpdates(collection, docID, updates); err != nil {
+		return err
+	}
+
+	previous, err := loadDenormalizationSource(ctx, collection, docID)
+	if err != nil {
+		return err
+	}
+	write := pendingWrite{collection: collection, ref: client.Collection(collection).Doc(docID), kind: "update", updates: updates}
+
+	if needsTransaction(collection) {
+		if err := commitWithRollups(ctx, []pendingWrite{write}); err != nil {
+			return fmt.Errorf("failed to update fields in document '%s' in collection '%s': %w", docID, collection, err)
+		}
+		return propagateDenormalized(ctx, collection, docID, previous, write)
+	}
+
+	_, err = client.Collection(collection).Doc(docID).Update(ctx, updates)
 	if err != nil {
 		return fmt.Errorf("failed to update fields in document '%s' in collection '%s': %w", docID, collection, err)
 	}
 
-	return nil
+	return propagateDenormalized(ctx, collection, docID, previous, write)
 }
 
 // DeleteDocument elimina un documento
-func DeleteDocument(ctx context.Context, collection, docID string) error {
-	client := firebase.GetFirestoreClient()
+func DeleteDocument(ctx context.Context, collection, docID string) (err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
 
-	_, err := client.Collection(collection).Doc(docID).Delete(ctx)
+	if err := firebase.CheckWritable(collection); err != nil {
+		return err
+	}
+	if err := firebase.CheckMutable(collection, "delete"); err != nil {
+		return err
+	}
+	call := newCall(firebase.CallFirestoreDelete, collection, docID, nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
+	collection = call.Collection
+
+	if err := authorizeStored(ctx, collection, docID, firebase.OperationDelete); err != nil {
+		return err
+	}
+	if err := checkLegalHold(ctx, collection, docID); err != nil {
+		return err
+	}
+
+	if hasRollups(collection) {
+		docRef := client.Collection(collection).Doc(docID)
+		if err := commitWithRollups(ctx, []pendingWrite{{collection: collection, ref: docRef, kind: "delete"}}); err != nil {
+			return fmt.Errorf("failed to delete document '%s' from collection '%s': %w", docID, collection, err)
+		}
+		return nil
+	}
+
+	_, err = client.Collection(collection).Doc(docID).Delete(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to delete document '%s' from collection '%s': %w", docID, collection, err)
 	}
@@ -138,22 +423,60 @@ func DeleteDocument(ctx context.Context, collection, docID string) error {
 
 // QueryDocuments realiza una consulta con filtros y opciones
 func QueryDocuments(ctx context.Context, collection string, options firebase.QueryOptions) ([]*firebase.Document, error) {
-	client := firebase.GetFirestoreClient()
+	result, err := QueryDocumentsWithMeta(ctx, collection, options)
+	if err != nil {
+		return nil, err
+	}
+	return result.Documents, nil
+}
+
+// QueryDocumentsWithMeta realiza una consulta y retorna los documentos junto con los
+// metadatos de ejecución (lecturas, read time, si hay más resultados y el cursor siguiente)
+func QueryDocumentsWithMeta(ctx context.Context, collection string, options firebase.QueryOptions) (_ *firebase.QueryResult, err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
 
-	query := client.Collection(collection).Query
+	// El payload es un puntero para que un interceptor pueda agregar filtros (p. ej. tenant)
+	call := newCall(firebase.CallFirestoreQuery, collection, "", &options)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
+	collection = call.Collection
+
+	// Normalizar filtros disyuntivos y dividir la consulta si exceden el límite
+	filters, chunkIndex, err := normalizeDisjunctions(options.Filters)
+	if err != nil {
+		return nil, err
+	}
+	options.Filters = filters
+	if chunkIndex >= 0 {
+		if options.Cursor != "" {
+			return nil, fmt.Errorf("cursors are not supported for queries split by disjunction limits")
+		}
+		result, err := queryChunked(ctx, collection, options, chunkIndex)
+		if err != nil {
+			return nil, err
+		}
+		call.Result = result
+		return result, nil
+	}
 
 	// Aplicar filtros
-	for _, filter := range options.Filters {
-		query = query.Where(filter.Field, filter.Operator, filter.Value)
+	query, err := applyFilters(client.Collection(collection).Query, options.Filters)
+	if err != nil {
+		return nil, err
 	}
 
 	// Aplicar ordenamiento
-	if options.OrderBy != "" {
-		dir := firestore.Asc
-		if options.OrderDir == "desc" {
-			dir = firestore.Desc
+	query = applyOrders(query, options.OrderClauses())
+
+	// Continuar desde el cursor
+	if options.Cursor != "" {
+		cursorDoc, err := decodeCursor(ctx, collection, options.Cursor)
+		if err != nil {
+			return nil, err
 		}
-		query = query.OrderBy(options.OrderBy, dir)
+		query = query.StartAfter(cursorDoc)
 	}
 
 	// Aplicar offset
@@ -161,14 +484,18 @@ func QueryDocuments(ctx context.Context, collection string, options firebase.Que
 		query = query.Offset(options.Offset)
 	}
 
-	// Aplicar límite
-	if options.Limit > 0 {
-		query = query.Limit(options.Limit)
+	// Aplicar límite (se pide un documento extra para saber si hay más resultados). Con
+	// políticas de lectura no se limita la consulta: se recorren documentos hasta reunir
+	// suficientes legibles para que las páginas no queden cortas.
+	_, rules := activePolicies(ctx, collection)
+	if options.Limit > 0 && rules == nil {
+		query = query.Limit(options.Limit + 1)
 	}
 
 	iter := query.Documents(ctx)
 	defer iter.Stop()
 
+	result := &firebase.QueryResult{}
 	var documents []*firebase.Document
 
 	for {
@@ -177,39 +504,143 @@ func QueryDocuments(ctx context.Context, collection string, options firebase.Que
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to query documents in collection '%s': %w", collection, err)
+			return nil, indexError(ctx, collection, err, fmt.Errorf("failed to query documents in collection '%s': %w", collection, err))
 		}
 
-		documents = append(documents, &firebase.Document{
+		result.DocumentsRead++
+		if result.ReadTime.IsZero() {
+			result.ReadTime = doc.ReadTime
+		}
+		document := &firebase.Document{
 			ID:   doc.Ref.ID,
 			Data: doc.Data(),
-		})
+		}
+		if rules != nil && authorize(ctx, collection, document, firebase.OperationRead) != nil {
+			continue
+		}
+		documents = append(documents, document)
+		if options.Limit > 0 && len(documents) > options.Limit {
+			break
+		}
 	}
 
-	return documents, nil
+	// El cursor apunta al último documento retornado: los ilegibles que lo siguen se
+	// vuelven a recorrer en la página siguiente y se descartan de nuevo
+	if options.Limit > 0 && len(documents) > options.Limit {
+		documents = documents[:options.Limit]
+		result.Truncated = true
+		result.NextCursor = encodeCursor(documents[len(documents)-1].ID)
+	}
+
+	result.Documents = documents
+	if err := decompressDocuments(collection, result.Documents...); err != nil {
+		return nil, err
+	}
+	call.Result = result
+	return result, nil
+}
+
+// applyFilters valida los operadores y aplica los filtros a la consulta
+func applyFilters(query firestore.Query, filters []firebase.QueryFilter) (firestore.Query, error) {
+	var inequalityFields []string
+	for _, filter := range filters {
+		if !filter.Operator.IsValid() {
+			return query, &firebase.InvalidOperatorError{Field: filter.Field, Operator: filter.Operator}
+		}
+		if filter.Operator.IsInequality() && !containsString(inequalityFields, filter.Field) {
+			inequalityFields = append(inequalityFields, filter.Field)
+		}
+		query = query.Where(filter.Field, string(filter.Operator), filter.Value)
+	}
+	if len(inequalityFields) > 1 {
+		return query, &firebase.InequalityFieldsError{Fields: inequalityFields}
+	}
+	return query, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOrders aplica los criterios de ordenamiento y agrega el ID del documento como
+// desempate, para que la paginación sea estable cuando varios documentos comparten valores
+func applyOrders(query firestore.Query, clauses []firebase.OrderClause) firestore.Query {
+	if len(clauses) == 0 {
+		return query
+	}
+
+	dir := firestore.Asc
+	for _, clause := range clauses {
+		dir = firestore.Asc
+		if clause.Direction == "desc" {
+			dir = firestore.Desc
+		}
+		if clause.Field == firestore.DocumentID {
+			return query.OrderBy(firestore.DocumentID, dir)
+		}
+		query = query.OrderBy(clause.Field, dir)
+	}
+	return query.OrderBy(firestore.DocumentID, dir)
 }
 
 // DocumentExists verifica si un documento existe
-func DocumentExists(ctx context.Context, collection, docID string) (bool, error) {
-	client := firebase.GetFirestoreClient()
+func DocumentExists(ctx context.Context, collection, docID string) (_ bool, err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	call := newCall(firebase.CallFirestoreGet, collection, docID, nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return false, err
+	}
+	collection = call.Collection
 
 	doc, err := client.Collection(collection).Doc(docID).Get(ctx)
 	if err != nil {
 		return false, fmt.Errorf("failed to check if document exists '%s' in collection '%s': %w", docID, collection, err)
 	}
+	if doc.Exists() {
+		if err := authorize(ctx, collection, &firebase.Document{ID: docID, Data: doc.Data()}, firebase.OperationRead); err != nil {
+			return false, err
+		}
+	}
 
+	call.Result = doc.Exists()
 	return doc.Exists(), nil
 }
 
 // CountDocuments cuenta los documentos en una colección (con filtros opcionales)
-func CountDocuments(ctx context.Context, collection string, filters []firebase.QueryFilter) (int, error) {
-	client := firebase.GetFirestoreClient()
+func CountDocuments(ctx context.Context, collection string, filters []firebase.QueryFilter) (_ int, err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
 
-	query := client.Collection(collection).Query
+	call := newCall(firebase.CallFirestoreQuery, collection, "", &filters)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return 0, err
+	}
+	collection = call.Collection
+
+	filters, chunkIndex, err := normalizeDisjunctions(filters)
+	if err != nil {
+		return 0, err
+	}
+	if chunkIndex >= 0 {
+		// Las consultas divididas pueden solaparse; contar sobre el resultado combinado
+		result, err := queryChunked(ctx, collection, firebase.QueryOptions{Filters: filters}, chunkIndex)
+		if err != nil {
+			return 0, err
+		}
+		return len(result.Documents), nil
+	}
 
 	// Aplicar filtros
-	for _, filter := range filters {
-		query = query.Where(filter.Field, filter.Operator, filter.Value)
+	query, err := applyFilters(client.Collection(collection).Query, filters)
+	if err != nil {
+		return 0, err
 	}
 
 	iter := query.Documents(ctx)
@@ -217,57 +648,43 @@ func CountDocuments(ctx context.Context, collection string, filters []firebase.Q
 
 	count := 0
 	for {
-		_, err := iter.Next()
+		doc, err := iter.Next()
 		if err == iterator.Done {
 			break
 		}
 		if err != nil {
-			return 0, fmt.Errorf("failed to count documents in collection '%s': %w", collection, err)
+			return 0, indexError(ctx, collection, err, fmt.Errorf("failed to count documents in collection '%s': %w", collection, err))
+		}
+		if authorize(ctx, collection, &firebase.Document{ID: doc.Ref.ID, Data: doc.Data()}, firebase.OperationRead) != nil {
+			continue
 		}
 		count++
 	}
 
+	call.Result = count
 	return count, nil
 }
 
-// BatchWrite realiza operaciones en lote
-func BatchWrite(ctx context.Context, operations []firebase.BatchOperation) error {
-	client := firebase.GetFirestoreClient()
-	batch := client.Batch()
-
-	for _, op := range operations {
-		switch op.Type {
-		case "create":
-			docRef := client.Collection(op.Collection).NewDoc()
-			if op.DocumentID != "" {
-				docRef = client.Collection(op.Collection).Doc(op.DocumentID)
-			}
-
-			// Agregar timestamps automáticamente
-			now := time.Now()
-			op.Data["created_at"] = now
-			op.Data["updated_at"] = now
-
-			batch.Set(docRef, op.Data)
-
-		case "update":
-			docRef := client.Collection(op.Collection).Doc(op.DocumentID)
-			op.Data["updated_at"] = time.Now()
-			batch.Set(docRef, op.Data, firestore.MergeAll)
-
-		case "delete":
-			docRef := client.Collection(op.Collection).Doc(op.DocumentID)
-			batch.Delete(docRef)
+// newCall describe una llamada fuera de lotes para la cadena de interceptores
+func newCall(operation, collection, docID string, payload interface{}) *firebase.Call {
+	return &firebase.Call{Operation: operation, Collection: collection, DocumentID: docID, Index: -1, Payload: payload}
+}
 
-		default:
-			return fmt.Errorf("unsupported batch operation type: %s", op.Type)
-		}
-	}
+// finishCall ejecuta los hooks After con el error final (pensado para usarse con defer)
+func finishCall(ctx context.Context, call *firebase.Call, err *error) {
+	firebase.FinishCall(ctx, call, *err)
+}
 
-	_, err := batch.Commit(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to commit batch operations: %w", err)
+// IsNotFound indica si el error corresponde a un documento inexistente
+func IsNotFound(err error) bool {
+	var notFound *firebase.DocumentNotFoundError
+	if errors.As(err, &notFound) {
+		return true
 	}
+	return status.Code(err) == codes.NotFound
+}
 
-	return nil
-}
\ No newline at end of file
+// IsAlreadyExists indica si el error corresponde a un create sobre un documento existente
+func IsAlreadyExists(err error) bool {
+	return status.Code(err) == codes.AlreadyExists
+}
@@ -0,0 +1,279 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"firebase.google.com/go/v4/auth"
+	"gopkg.in/yaml.v3"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// FixtureFile contenido de un archivo de fixtures (YAML o JSON). En los valores de data,
+// "$user:<ref>" se reemplaza por el UID del usuario, "$doc:<ref>" por el ID del
+// documento y "$now" por la hora actual.
+//
+//	users:
+//	  - ref: alice
+//	    email: alice@example.com
+//	    password: secret123
+//	    claims: {role: admin}
+//	documents:
+//	  - ref: order1
+//	    collection: orders
+//	    data: {owner_id: "$user:alice", total: 30}
+type FixtureFile struct {
+	Users     []FixtureUser     `yaml:"users" json:"users"`
+	Documents []FixtureDocument `yaml:"documents" json:"documents"`
+}
+
+// FixtureUser usuario de Auth a crear
+type FixtureUser struct {
+	Ref         string                 `yaml:"ref" json:"ref"`
+	UID         string                 `yaml:"uid" json:"uid"`
+	Email       string                 `yaml:"email" json:"email"`
+	Password    string                 `yaml:"password" json:"password"`
+	DisplayName string                 `yaml:"display_name" json:"display_name"`
+	Claims      map[string]interface{} `yaml:"claims" json:"claims"`
+}
+
+// FixtureDocument documento a crear; sin id se genera uno
+type FixtureDocument struct {
+	Ref        string                 `yaml:"ref" json:"ref"`
+	Collection string                 `yaml:"collection" json:"collection"`
+	ID         string                 `yaml:"id" json:"id"`
+	Data       map[string]interface{} `yaml:"data" json:"data"`
+}
+
+// Fixtures resultado de LoadFixtures: UIDs e IDs asignados por ref y lo necesario para
+// deshacer la carga
+type Fixtures struct {
+	Users     map[string]string // ref -> UID
+	Documents map[string]string // ref -> ID
+	created   []*firestore.DocumentRef
+	users     []string // UIDs creados por la carga (no los que ya existían)
+}
+
+// LoadFixtures carga todos los archivos .yaml, .yml y .json de fsys (por ejemplo un
+// embed.FS) en orden alfabético: primero los usuarios y luego los documentos. Los
+// documentos se escriben directamente, sin interceptores ni políticas. Pensado para el
+// emulador o un proyecto de desarrollo; usar Teardown al terminar la prueba.
+func LoadFixtures(ctx context.Context, fsys fs.FS) (*Fixtures, error) {
+	files, err := readFixtureFiles(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := &Fixtures{Users: map[string]string{}, Documents: map[string]string{}}
+	for _, file := range files {
+		for _, user := range file.Users {
+			if err := fixtures.createUser(ctx, user); err != nil {
+				fixtures.Teardown(ctx)
+				return nil, err
+			}
+		}
+	}
+
+	// Asignar todos los IDs antes de escribir para que las referencias puedan apuntar a
+	// documentos definidos más adelante
+	var docs []FixtureDocument
+	for _, file := range files {
+		for _, doc := range file.Documents {
+			if doc.Collection == "" {
+				fixtures.Teardown(ctx)
+				return nil, fmt.Errorf("fixture document '%s' has no collection", doc.Ref)
+			}
+			if doc.ID == "" {
+				doc.ID = firebase.FirestoreClientFor(ctx, doc.Collection).Collection(doc.Collection).NewDoc().ID
+			}
+			if doc.Ref != "" {
+				fixtures.Documents[doc.Ref] = doc.ID
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	for _, doc := range docs {
+		data, err := fixtures.resolve(doc.Data)
+		if err != nil {
+			fixtures.Teardown(ctx)
+			return nil, fmt.Errorf("fixture document '%s/%s': %w", doc.Collection, doc.ID, err)
+		}
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+
+		ref := firebase.FirestoreClientFor(ctx, doc.Collection).Collection(doc.Collection).Doc(doc.ID)
+		if _, err := ref.Set(ctx, data); err != nil {
+			fixtures.Teardown(ctx)
+			return nil, fmt.Errorf("failed to write fixture '%s/%s': %w", doc.Collection, doc.ID, err)
+		}
+		fixtures.created = append(fixtures.created, ref)
+	}
+	return fixtures, nil
+}
+
+// Teardown elimina los documentos y usuarios creados por LoadFixtures (los usuarios que ya
+// existían se conservan)
+func (f *Fixtures) Teardown(ctx context.Context) error {
+	var failures []string
+	for i := len(f.created) - 1; i >= 0; i-- {
+		if _, err := f.created[i].Delete(ctx); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", f.created[i].Path, err))
+		}
+	}
+	for _, uid := range f.users {
+		if err := firebase.GetAuthClient().DeleteUser(ctx, uid); err != nil && !auth.IsUserNotFound(err) {
+			failures = append(failures, fmt.Sprintf("user %s: %v", uid, err))
+		}
+	}
+	f.created, f.users = nil, nil
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to tear down fixtures: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (f *Fixtures) createUser(ctx context.Context, user FixtureUser) error {
+	client := firebase.GetAuthClient()
+
+	var record *auth.UserRecord
+	var err error
+	if user.Email != "" {
+		record, err = client.GetUserByEmail(ctx, user.Email)
+	} else if user.UID != "" {
+		record, err = client.GetUser(ctx, user.UID)
+	} else {
+		return fmt.Errorf("fixture user '%s' needs an email or uid", user.Ref)
+	}
+	if err != nil && !auth.IsUserNotFound(err) {
+		return fmt.Errorf("failed to look up fixture user '%s': %w", user.Ref, err)
+	}
+
+	if record == nil {
+		params := (&auth.UserToCreate{}).EmailVerified(true)
+		if user.UID != "" {
+			params = params.UID(user.UID)
+		}
+		if user.Email != "" {
+			params = params.Email(user.Email)
+		}
+		if user.Password != "" {
+			params = params.Password(user.Password)
+		}
+		if user.DisplayName != "" {
+			params = params.DisplayName(user.DisplayName)
+		}
+		record, err = client.CreateUser(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to create fixture user '%s': %w", user.Ref, err)
+		}
+		f.users = append(f.users, record.UID)
+	}
+
+	if len(user.Claims) > 0 {
+		if err := client.SetCustomUserClaims(ctx, record.UID, user.Claims); err != nil {
+			return fmt.Errorf("failed to set claims for fixture user '%s': %w", user.Ref, err)
+		}
+	}
+	if user.Ref != "" {
+		f.Users[user.Ref] = record.UID
+	}
+	return nil
+}
+
+// resolve reemplaza los marcadores $user:, $doc: y $now en una copia de value
+func (f *Fixtures) resolve(value interface{}) (map[string]interface{}, error) {
+	resolved, err := f.resolveValue(value)
+	if err != nil || resolved == nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+func (f *Fixtures) resolveValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := f.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := f.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	case string:
+		switch {
+		case v == "$now":
+			return firebase.Now(), nil
+		case strings.HasPrefix(v, "$user:"):
+			uid, ok := f.Users[strings.TrimPrefix(v, "$user:")]
+			if !ok {
+				return nil, fmt.Errorf("unknown fixture user reference %q", v)
+			}
+			return uid, nil
+		case strings.HasPrefix(v, "$doc:"):
+			id, ok := f.Documents[strings.TrimPrefix(v, "$doc:")]
+			if !ok {
+				return nil, fmt.Errorf("unknown fixture document reference %q", v)
+			}
+			return id, nil
+		}
+	}
+	return value, nil
+}
+
+// readFixtureFiles lee y decodifica los archivos de fixtures en orden alfabético. JSON es
+// un subconjunto de YAML, por lo que ambos formatos usan el mismo decodificador (que
+// además conserva los enteros como int).
+func readFixtureFiles(fsys fs.FS) ([]FixtureFile, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch path.Ext(p) {
+		case ".yaml", ".yml", ".json":
+			if !d.IsDir() {
+				paths = append(paths, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixture files: %w", err)
+	}
+	sort.Strings(paths)
+
+	files := make([]FixtureFile, 0, len(paths))
+	for _, p := range paths {
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture file '%s': %w", p, err)
+		}
+		var file FixtureFile
+		if err := yaml.Unmarshal(content, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture file '%s': %w", p, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,183 @@
+package firestore
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/genproto/googleapis/type/latlng"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Límites de Firestore usados por los guardas por defecto
+const (
+	MaxDocumentSize  = 1024 * 1024 // 1 MiB
+	MaxNestingDepth  = 20
+	MaxFieldNameSize = 1500
+)
+
+// GuardOptions configura las validaciones que se ejecutan antes de cada escritura
+type GuardOptions struct {
+	Disabled        bool // desactiva todos los guardas
+	MaxDocumentSize int  // bytes estimados según las reglas de tamaño de Firestore
+	MaxDepth        int  // niveles de mapas anidados
+	AllowNonFinite  bool // permitir NaN e Inf en floats
+}
+
+var (
+	guardsMu sync.RWMutex
+	guards   = DefaultGuards()
+)
+
+var reservedFieldName = regexp.MustCompile(`^__.*__$`)
+
+// DefaultGuards retorna los guardas con los límites de Firestore
+func DefaultGuards() GuardOptions {
+	return GuardOptions{MaxDocumentSize: MaxDocumentSize, MaxDepth: MaxNestingDepth}
+}
+
+// SetGuards reemplaza la configuración de los guardas
+func SetGuards(options GuardOptions) {
+	guardsMu.Lock()
+	defer guardsMu.Unlock()
+	guards = options
+}
+
+// ValidateDocument valida los datos de un documento con los guardas configurados y
+// retorna un *firebase.ValidationError con todos los problemas encontrados
+func ValidateDocument(collection, docID string, data map[string]interface{}) error {
+	guardsMu.RLock()
+	options := guards
+	guardsMu.RUnlock()
+	if options.Disabled {
+		return nil
+	}
+
+	v := &guardValidator{options: options}
+	size := v.walkMap("", data, 1)
+	size += len(collection) + len(docID) + 16 // nombre del documento
+	if options.MaxDocumentSize > 0 && size > options.MaxDocumentSize {
+		v.issue("(document)", fmt.Sprintf("estimated size %d bytes exceeds limit of %d bytes", size, options.MaxDocumentSize))
+	}
+
+	if len(v.issues) > 0 {
+		return &firebase.ValidationError{Collection: collection, DocumentID: docID, Issues: v.issues}
+	}
+	return nil
+}
+
+// EstimateDocumentSize estima el tamaño en bytes de un documento según las reglas de
+// almacenamiento de Firestore (el mismo cálculo que usan los guardas)
+func EstimateDocumentSize(collection, docID string, data map[string]interface{}) int {
+	v := &guardValidator{}
+	return v.walkMap("", data, 1) + len(collection) + len(docID) + 16
+}
+
+// validateUpdates valida los valores de una lista de updates
+func validateUpdates(collection, docID string, updates []firestore.Update) error {
+	data := make(map[string]interface{}, len(updates))
+	for _, update := range updates {
+		path := update.Path
+		if path == "" {
+			path = fmt.Sprint(update.FieldPath)
+		}
+		data[path] = update.Value
+	}
+	return ValidateDocument(collection, docID, data)
+}
+
+type guardValidator struct {
+	options GuardOptions
+	issues  []firebase.ValidationIssue
+}
+
+func (v *guardValidator) issue(field, reason string) {
+	v.issues = append(v.issues, firebase.ValidationIssue{Field: field, Reason: reason})
+}
+
+// walkMap valida un mapa y retorna su tamaño estimado
+func (v *guardValidator) walkMap(prefix string, data map[string]interface{}, depth int) int {
+	if v.options.MaxDepth > 0 && depth > v.options.MaxDepth {
+		v.issue(prefix, fmt.Sprintf("nesting depth exceeds %d levels", v.options.MaxDepth))
+		return 0
+	}
+
+	size := 0
+	for key, value := range data {
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+
+		switch {
+		case key == "":
+			v.issue(field, "field name must not be empty")
+		case !utf8.ValidString(key):
+			v.issue(field, "field name must be valid UTF-8")
+		case reservedFieldName.MatchString(key):
+			v.issue(field, "field names matching __.*__ are reserved")
+		case len(key) > MaxFieldNameSize:
+			v.issue(field, fmt.Sprintf("field name exceeds %d bytes", MaxFieldNameSize))
+		}
+
+		size += len(key) + 1 + v.walkValue(field, value, depth)
+	}
+	return size
+}
+
+// walkValue valida un valor y retorna su tamaño estimado
+func (v *guardValidator) walkValue(field string, value interface{}, depth int) int {
+	switch val := value.(type) {
+	case nil, bool:
+		return 1
+	case string:
+		if !utf8.ValidString(val) {
+			v.issue(field, "string value must be valid UTF-8")
+		}
+		return len(val) + 1
+	case []byte:
+		return len(val) + 1
+	case float32:
+		v.checkFloat(field, float64(val))
+		return 8
+	case float64:
+		v.checkFloat(field, val)
+		return 8
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, time.Time, *time.Time:
+		return 8
+	case *latlng.LatLng:
+		return 16
+	case *firestore.DocumentRef:
+		if val == nil {
+			return 1
+		}
+		return len(val.Path) + 1
+	case map[string]interface{}:
+		return v.walkMap(field, val, depth+1)
+	case []interface{}:
+		size := 0
+		for i, item := range val {
+			size += v.walkValue(fmt.Sprintf("%s[%d]", field, i), item, depth)
+		}
+		return size
+	case []string:
+		size := 0
+		for _, item := range val {
+			size += len(item) + 1
+		}
+		return size
+	}
+	// Sentinels (Increment, Delete, ServerTimestamp) y otros tipos
+	return 8
+}
+
+func (v *guardValidator) checkFloat(field string, value float64) {
+	if !v.options.AllowNonFinite && (math.IsNaN(value) || math.IsInf(value, 0)) {
+		v.issue(field, "NaN and Inf values are not allowed")
+	}
+}
@@ -0,0 +1,216 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Colecciones de las retenciones legales y de su registro de auditoría
+const (
+	LegalHoldsCollection      = "legal_holds"
+	LegalHoldEventsCollection = "legal_hold_events"
+)
+
+// LegalHold retención legal sobre documentos de una colección: los IDs listados, los que
+// cumplen los filtros o, sin ninguno de los dos, la colección completa. Mientras está
+// activa, el archivado y los borrados del paquete omiten o rechazan esos documentos.
+// Collection es la ruta física (la que usa ArchiveOldDocuments).
+type LegalHold struct {
+	ID            string                 `json:"id"`
+	Collection    string                 `json:"collection"`
+	DocumentIDs   []string               `json:"document_ids,omitempty"`
+	Filters       []firebase.QueryFilter `json:"filters,omitempty"`
+	Matter        string                 `json:"matter,omitempty"` // caso o expediente
+	Reason        string                 `json:"reason,omitempty"`
+	PlacedBy      string                 `json:"placed_by"`
+	PlacedAt      time.Time              `json:"placed_at"`
+	Active        bool                   `json:"active"`
+	ReleasedBy    string                 `json:"released_by,omitempty"`
+	ReleasedAt    time.Time              `json:"released_at,omitempty"`
+	ReleaseReason string                 `json:"release_reason,omitempty"`
+}
+
+// covers indica si la retención alcanza al documento
+func (h *LegalHold) covers(doc *firebase.Document) bool {
+	if len(h.DocumentIDs) > 0 {
+		for _, id := range h.DocumentIDs {
+			if id == doc.ID {
+				return true
+			}
+		}
+		return false
+	}
+	return MatchesFilters(doc, h.Filters)
+}
+
+// PlaceLegalHold activa una retención legal y registra el evento "placed". Retorna su ID.
+func PlaceLegalHold(ctx context.Context, hold LegalHold) (string, error) {
+	if hold.Collection == "" || hold.PlacedBy == "" {
+		return "", fmt.Errorf("legal hold requires a collection and placed_by")
+	}
+	for _, filter := range hold.Filters {
+		if !filter.Operator.IsValid() {
+			return "", &firebase.InvalidOperatorError{Field: filter.Field, Operator: filter.Operator}
+		}
+	}
+
+	client := firebase.GetFirestoreClient()
+	ref := client.Collection(LegalHoldsCollection).NewDoc()
+	now := firebase.Now()
+	data := map[string]interface{}{
+		"collection":   hold.Collection,
+		"document_ids": hold.DocumentIDs,
+		"filters":      encodeFilters(hold.Filters),
+		"matter":       hold.Matter,
+		"reason":       hold.Reason,
+		"placed_by":    hold.PlacedBy,
+		"placed_at":    now,
+		"active":       true,
+	}
+
+	batch := client.Batch()
+	batch.Create(ref, data)
+	batch.Create(client.Collection(LegalHoldEventsCollection).NewDoc(), legalHoldEvent(ref.ID, hold.Collection, "placed", hold.PlacedBy, hold.Reason, now))
+	if _, err := batch.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to place legal hold: %w", err)
+	}
+	return ref.ID, nil
+}
+
+// ReleaseLegalHold levanta una retención activa y registra el evento "released"
+func ReleaseLegalHold(ctx context.Context, holdID, releasedBy, reason string) error {
+	if releasedBy == "" {
+		return fmt.Errorf("releasing a legal hold requires released_by")
+	}
+	client := firebase.GetFirestoreClient()
+	ref := client.Collection(LegalHoldsCollection).Doc(holdID)
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			if IsNotFound(err) {
+				return &firebase.DocumentNotFoundError{Collection: LegalHoldsCollection, DocumentID: holdID}
+			}
+			return fmt.Errorf("failed to get legal hold '%s': %w", holdID, err)
+		}
+		hold, err := legalHoldFromSnapshot(snap)
+		if err != nil {
+			return err
+		}
+		if !hold.Active {
+			return fmt.Errorf("legal hold '%s' is already released", holdID)
+		}
+
+		now := firebase.Now()
+		if err := tx.Update(ref, []firestore.Update{
+			{Path: "active", Value: false},
+			{Path: "released_by", Value: releasedBy},
+			{Path: "released_at", Value: now},
+			{Path: "release_reason", Value: reason},
+		}); err != nil {
+			return err
+		}
+		return tx.Create(client.Collection(LegalHoldEventsCollection).NewDoc(), legalHoldEvent(holdID, hold.Collection, "released", releasedBy, reason, now))
+	})
+}
+
+// GetLegalHold obtiene una retención por su ID
+func GetLegalHold(ctx context.Context, holdID string) (*LegalHold, error) {
+	snap, err := firebase.GetFirestoreClient().Collection(LegalHoldsCollection).Doc(holdID).Get(ctx)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, &firebase.DocumentNotFoundError{Collection: LegalHoldsCollection, DocumentID: holdID}
+		}
+		return nil, fmt.Errorf("failed to get legal hold '%s': %w", holdID, err)
+	}
+	return legalHoldFromSnapshot(snap)
+}
+
+// ActiveLegalHolds retorna las retenciones activas de una colección
+func ActiveLegalHolds(ctx context.Context, collection string) ([]*LegalHold, error) {
+	snaps, err := firebase.GetFirestoreClient().Collection(LegalHoldsCollection).
+		Where("collection", "==", collection).
+		Where("active", "==", true).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list legal holds for '%s': %w", collection, err)
+	}
+	holds := make([]*LegalHold, 0, len(snaps))
+	for _, snap := range snaps {
+		hold, err := legalHoldFromSnapshot(snap)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, nil
+}
+
+// HeldBy retorna los IDs de las retenciones activas que alcanzan al documento
+func HeldBy(holds []*LegalHold, doc *firebase.Document) []string {
+	var ids []string
+	for _, hold := range holds {
+		if hold.covers(doc) {
+			ids = append(ids, hold.ID)
+		}
+	}
+	return ids
+}
+
+// checkLegalHold retorna un *firebase.LegalHoldError si el documento almacenado está bajo
+// una retención activa (solo lo lee si la colección tiene retenciones)
+func checkLegalHold(ctx context.Context, collection, docID string) error {
+	holds, err := ActiveLegalHolds(ctx, firebase.LogicalCollection(collection))
+	if err != nil || len(holds) == 0 {
+		return err
+	}
+	snap, err := firebase.FirestoreClientFor(ctx, collection).Collection(collection).Doc(docID).Get(ctx)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get document '%s' from collection '%s': %w", docID, collection, err)
+	}
+	if ids := HeldBy(holds, &firebase.Document{ID: docID, Data: snap.Data()}); len(ids) > 0 {
+		return &firebase.LegalHoldError{Collection: collection, DocumentID: docID, HoldIDs: ids}
+	}
+	return nil
+}
+
+func legalHoldEvent(holdID, collection, action, actor, reason string, at time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"hold_id":    holdID,
+		"collection": collection,
+		"action":     action,
+		"actor":      actor,
+		"reason":     reason,
+		"created_at": at,
+	}
+}
+
+func legalHoldFromSnapshot(snap *firestore.DocumentSnapshot) (*LegalHold, error) {
+	doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+	hold := &LegalHold{ID: doc.ID}
+	hold.Collection, _ = doc.GetString("collection")
+	hold.DocumentIDs, _ = doc.GetStringSlice("document_ids")
+	hold.Matter, _ = doc.GetString("matter")
+	hold.Reason, _ = doc.GetString("reason")
+	hold.PlacedBy, _ = doc.GetString("placed_by")
+	hold.PlacedAt, _ = doc.GetTime("placed_at")
+	hold.Active, _ = doc.GetBool("active")
+	hold.ReleasedBy, _ = doc.GetString("released_by")
+	hold.ReleasedAt, _ = doc.GetTime("released_at")
+	hold.ReleaseReason, _ = doc.GetString("release_reason")
+
+	filters, err := decodeFilters(doc)
+	if err != nil {
+		return nil, fmt.Errorf("legal hold '%s': %w", doc.ID, err)
+	}
+	hold.Filters = filters
+	return hold, nil
+}
@@ -0,0 +1,262 @@
+package firestore
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// IDStrategy genera el ID de un documento nuevo a partir de sus datos
+type IDStrategy func(ctx context.Context, collection string, data map[string]interface{}) (string, error)
+
+var (
+	idStrategiesMu sync.RWMutex
+	idStrategies   = map[string]IDStrategy{}
+)
+
+// SetIDStrategy configura la estrategia de IDs de CreateDocument para una colección
+// (nil restaura los IDs aleatorios de Firestore)
+func SetIDStrategy(collection string, strategy IDStrategy) {
+	idStrategiesMu.Lock()
+	defer idStrategiesMu.Unlock()
+	if strategy == nil {
+		delete(idStrategies, collection)
+		return
+	}
+	idStrategies[collection] = strategy
+}
+
+// GenerateID genera un ID para la colección usando su estrategia configurada (o un ID
+// aleatorio de Firestore). Útil para CreateDocumentWithID.
+func GenerateID(ctx context.Context, collection string, data map[string]interface{}) (string, error) {
+	idStrategiesMu.RLock()
+	strategy := idStrategies[firebase.LogicalCollection(collection)]
+	idStrategiesMu.RUnlock()
+
+	if strategy == nil {
+		return firebase.GetFirestoreClient().Collection(collection).NewDoc().ID, nil
+	}
+	id, err := strategy(ctx, collection, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ID for collection '%s': %w", collection, err)
+	}
+	return id, nil
+}
+
+// hasIDStrategy indica si la colección tiene una estrategia configurada
+func hasIDStrategy(collection string) bool {
+	idStrategiesMu.RLock()
+	defer idStrategiesMu.RUnlock()
+	return idStrategies[firebase.LogicalCollection(collection)] != nil
+}
+
+// ULIDStrategy genera ULIDs (ordenables por tiempo de creación)
+func ULIDStrategy() IDStrategy {
+	return func(ctx context.Context, collection string, data map[string]interface{}) (string, error) {
+		return NewULID()
+	}
+}
+
+// KSUIDStrategy genera KSUIDs (ordenables por segundo de creación)
+func KSUIDStrategy() IDStrategy {
+	return func(ctx context.Context, collection string, data map[string]interface{}) (string, error) {
+		return NewKSUID()
+	}
+}
+
+// TimePrefixedStrategy genera IDs con prefijo de tiempo en milisegundos
+func TimePrefixedStrategy() IDStrategy {
+	return func(ctx context.Context, collection string, data map[string]interface{}) (string, error) {
+		return NewTimePrefixedID()
+	}
+}
+
+// SlugStrategy genera un slug a partir de field y agrega un sufijo (-2, -3, ...) si ya existe
+func SlugStrategy(field string) IDStrategy {
+	return func(ctx context.Context, collection string, data map[string]interface{}) (string, error) {
+		value, ok := data[field].(string)
+		if !ok || value == "" {
+			return "", fmt.Errorf("slug field '%s' must be a non-empty string", field)
+		}
+
+		base := Slugify(value)
+		if base == "" {
+			return "", fmt.Errorf("slug field '%s' produces an empty slug", field)
+		}
+
+		client := firebase.GetFirestoreClient()
+		candidate := base
+		for i := 2; i <= 100; i++ {
+			snap, err := client.Collection(collection).Doc(candidate).Get(ctx)
+			if err != nil && !IsNotFound(err) {
+				return "", err
+			}
+			if snap == nil || !snap.Exists() {
+				return candidate, nil
+			}
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		suffix, err := randomString(6, "abcdefghijklmnopqrstuvwxyz0123456789")
+		if err != nil {
+			return "", err
+		}
+		return base + "-" + suffix, nil
+	}
+}
+
+// DeterministicStrategy genera el ID a partir de un hash de los campos de clave natural
+// (ver DeterministicID)
+func DeterministicStrategy(keyFields ...string) IDStrategy {
+	return func(ctx context.Context, collection string, data map[string]interface{}) (string, error) {
+		return DeterministicID(keyFields, data)
+	}
+}
+
+// DeterministicID deriva un ID (32 caracteres hexadecimales de SHA-256) de los valores de
+// keyFields (admiten rutas con puntos). Los valores se comparan como en las sumas de
+// integridad: 3 e int64(3) dan el mismo ID, y los textos se usan tal cual.
+func DeterministicID(keyFields []string, data map[string]interface{}) (string, error) {
+	if len(keyFields) == 0 {
+		return "", fmt.Errorf("deterministic ID requires at least one key field")
+	}
+	doc := &firebase.Document{Data: data}
+	key := make([]interface{}, 0, len(keyFields)*2)
+	for _, field := range keyFields {
+		value, ok := doc.Get(field)
+		if !ok || value == nil {
+			return "", fmt.Errorf("key field '%s' is missing", field)
+		}
+		canonical, ok := canonicalValue(value)
+		if !ok {
+			return "", fmt.Errorf("key field '%s' has a value that cannot be hashed", field)
+		}
+		key = append(key, field, canonical)
+	}
+	content, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key fields: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// CreateDocumentDeterministic crea el documento con el ID derivado de naturalKeyFields, de
+// modo que volver a ingerir el mismo registro de origen lo sobrescribe en lugar de
+// duplicarlo. Retorna el ID.
+func CreateDocumentDeterministic(ctx context.Context, collection string, naturalKeyFields []string, data map[string]interface{}) (string, error) {
+	docID, err := DeterministicID(naturalKeyFields, data)
+	if err != nil {
+		return "", err
+	}
+	if err := CreateDocumentWithID(ctx, collection, docID, data); err != nil {
+		return "", err
+	}
+	return docID, nil
+}
+
+// Slugify convierte un texto en un slug en minúsculas, sin acentos y separado por guiones
+func Slugify(value string) string {
+	var b strings.Builder
+	lastDash := true
+	for _, r := range norm.NFD.String(value) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(unicode.ToLower(r))
+			lastDash = false
+		case !lastDash:
+			b.WriteByte('-')
+			lastDash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID genera un ULID: 48 bits de timestamp en milisegundos y 80 bits aleatorios
+func NewULID() (string, error) {
+	var raw [16]byte
+	ms := uint64(firebase.Now().UnixMilli())
+	raw[0] = byte(ms >> 40)
+	raw[1] = byte(ms >> 32)
+	raw[2] = byte(ms >> 24)
+	raw[3] = byte(ms >> 16)
+	raw[4] = byte(ms >> 8)
+	raw[5] = byte(ms)
+	if _, err := rand.Read(raw[6:]); err != nil {
+		return "", err
+	}
+
+	value := new(big.Int).SetBytes(raw[:])
+	out := make([]byte, 26)
+	mask := big.NewInt(31)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[new(big.Int).And(value, mask).Int64()]
+		value.Rsh(value, 5)
+	}
+	return string(out), nil
+}
+
+const (
+	ksuidEpoch  = 1400000000
+	base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// NewKSUID genera un KSUID: 32 bits de timestamp (segundos desde 2014-05-13) y 128 bits aleatorios
+func NewKSUID() (string, error) {
+	var raw [20]byte
+	binary.BigEndian.PutUint32(raw[:4], uint32(firebase.Now().Unix()-ksuidEpoch))
+	if _, err := rand.Read(raw[4:]); err != nil {
+		return "", err
+	}
+
+	value := new(big.Int).SetBytes(raw[:])
+	base := big.NewInt(62)
+	out := make([]byte, 27)
+	for i := range out {
+		out[i] = '0'
+	}
+	mod := new(big.Int)
+	for i := 26; i >= 0 && value.Sign() > 0; i-- {
+		value.DivMod(value, base, mod)
+		out[i] = base62Chars[mod.Int64()]
+	}
+	return string(out), nil
+}
+
+// NewTimePrefixedID genera un ID con 12 dígitos hexadecimales de timestamp en
+// milisegundos seguidos de 8 caracteres aleatorios
+func NewTimePrefixedID() (string, error) {
+	suffix, err := randomString(8, "0123456789abcdefghijklmnopqrstuvwxyz")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%012x%s", firebase.Now().UnixMilli(), suffix), nil
+}
+
+func randomString(length int, alphabet string) (string, error) {
+	out := make([]byte, length)
+	for i := range out {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		out[i] = alphabet[n.Int64()]
+	}
+	return string(out), nil
+}
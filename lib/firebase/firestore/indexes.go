@@ -0,0 +1,122 @@
+package firestore
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	admin "cloud.google.com/go/firestore/apiv1/admin"
+	"cloud.google.com/go/firestore/apiv1/admin/adminpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+var indexURLPattern = regexp.MustCompile(`https://console\.firebase\.google\.com/\S+`)
+
+var (
+	autoCreateMu      sync.Mutex
+	autoCreateIndexes bool
+	requestedIndexes  = map[string]bool{}
+)
+
+// SetAutoCreateIndexes activa la creación automática (con la Admin API) de los índices
+// compuestos que falten al ejecutar una consulta. Cada índice se solicita una sola vez por
+// proceso; la consulta sigue fallando hasta que el índice termina de construirse. Pensado
+// para entornos de desarrollo.
+func SetAutoCreateIndexes(enabled bool) {
+	autoCreateMu.Lock()
+	defer autoCreateMu.Unlock()
+	autoCreateIndexes = enabled
+}
+
+// indexError retorna un *firebase.MissingIndexError si err se debe a un índice compuesto
+// faltante (y lo solicita si la creación automática está activa); en otro caso retorna
+// fallback
+func indexError(ctx context.Context, collection string, err, fallback error) error {
+	missing := parseMissingIndex(collection, err)
+	if missing == nil {
+		return fallback
+	}
+
+	autoCreateMu.Lock()
+	request := autoCreateIndexes && missing.CreateURL != "" && !requestedIndexes[missing.CreateURL]
+	if request {
+		requestedIndexes[missing.CreateURL] = true
+	}
+	autoCreateMu.Unlock()
+
+	if request {
+		if createErr := createIndexFromURL(ctx, missing.CreateURL); createErr != nil {
+			return fmt.Errorf("%w (auto-create failed: %v)", missing, createErr)
+		}
+	}
+	return missing
+}
+
+// parseMissingIndex reconoce el error FailedPrecondition de índice faltante y extrae el
+// enlace de creación
+func parseMissingIndex(collection string, err error) *firebase.MissingIndexError {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.FailedPrecondition || !strings.Contains(st.Message(), "index") {
+		return nil
+	}
+	return &firebase.MissingIndexError{
+		Collection: collection,
+		CreateURL:  strings.TrimRight(indexURLPattern.FindString(st.Message()), ".,;)"),
+		Err:        err,
+	}
+}
+
+// createIndexFromURL decodifica la definición del índice del parámetro create_composite
+// del enlace y la envía a la Admin API sin esperar a que termine de construirse
+func createIndexFromURL(ctx context.Context, createURL string) error {
+	parsed, err := url.Parse(createURL)
+	if err != nil {
+		return fmt.Errorf("invalid index URL: %w", err)
+	}
+	encoded := parsed.Query().Get("create_composite")
+	if encoded == "" {
+		return fmt.Errorf("index URL has no create_composite parameter")
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		if raw, err = base64.URLEncoding.DecodeString(encoded); err != nil {
+			return fmt.Errorf("failed to decode index definition: %w", err)
+		}
+	}
+
+	index := &adminpb.Index{}
+	if err := proto.Unmarshal(raw, index); err != nil {
+		return fmt.Errorf("failed to parse index definition: %w", err)
+	}
+	// El nombre es "projects/p/databases/d/collectionGroups/c/indexes/_"
+	parent := index.GetName()
+	if i := strings.LastIndex(parent, "/indexes/"); i >= 0 {
+		parent = parent[:i]
+	}
+	if parent == "" {
+		return fmt.Errorf("index definition has no collection group")
+	}
+	index.Name = ""
+
+	client, err := admin.NewFirestoreAdminClient(ctx, firebase.GetClientOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore admin client: %w", err)
+	}
+	defer client.Close()
+
+	if _, err := client.CreateIndex(ctx, &adminpb.CreateIndexRequest{Parent: parent, Index: index}); err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return nil
+		}
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+	return nil
+}
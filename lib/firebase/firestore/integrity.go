@@ -0,0 +1,221 @@
+package firestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// ChecksumField campo donde se guarda el sha256 del contenido del documento
+const ChecksumField = "content_checksum"
+
+var (
+	integrityMu          sync.RWMutex
+	integrityCollections = map[string]bool{}
+)
+
+// EnableIntegrity hace que las escrituras del paquete sobre la colección guarden en
+// ChecksumField el sha256 del documento resultante, para detectar con
+// VerifyDocumentIntegrity cambios hechos por fuera (consola, otros clientes). Las
+// escrituras pasan a ejecutarse en una transacción que lee el estado previo. Los
+// transforms (Increment, ArrayUnion, ServerTimestamp) no tienen valor conocido antes de
+// escribir, así que esas escrituras eliminan el checksum.
+func EnableIntegrity(collection string) {
+	integrityMu.Lock()
+	defer integrityMu.Unlock()
+	integrityCollections[collection] = true
+}
+
+// DisableIntegrity deja de calcular el checksum de la colección
+func DisableIntegrity(collection string) {
+	integrityMu.Lock()
+	defer integrityMu.Unlock()
+	delete(integrityCollections, collection)
+}
+
+// integrityEnabled indica si la colección guarda checksum
+func integrityEnabled(collection string) bool {
+	integrityMu.RLock()
+	defer integrityMu.RUnlock()
+	return integrityCollections[firebase.LogicalCollection(collection)]
+}
+
+// needsTransaction indica si las escrituras de la colección deben leer el estado previo
+// dentro de una transacción (rollups o checksum)
+func needsTransaction(collection string) bool {
+	return hasRollups(collection) || integrityEnabled(collection)
+}
+
+// DocumentChecksum calcula el sha256 (hex) del contenido canónico del documento: JSON
+// con claves ordenadas, sin ChecksumField, con timestamps en UTC truncados a
+// microsegundos (la precisión de Firestore) y los tipos de EncodeJSONValue. Retorna
+// false si el documento contiene transforms sin valor conocido.
+func DocumentChecksum(data map[string]interface{}) (string, bool) {
+	content := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if k != ChecksumField {
+			content[k] = v
+		}
+	}
+	canonical, ok := canonicalValue(content)
+	if !ok {
+		return "", false
+	}
+	encoded, err := json.Marshal(EncodeJSONValue(canonical))
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// canonicalValue normaliza los valores cuyo tipo cambia al guardarse en Firestore
+func canonicalValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			c, ok := canonicalValue(item)
+			if !ok {
+				return nil, false
+			}
+			out[key] = c
+		}
+		return out, true
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			c, ok := canonicalValue(item)
+			if !ok {
+				return nil, false
+			}
+			out[i] = c
+		}
+		return out, true
+	case time.Time:
+		return v.UTC().Truncate(time.Microsecond), true
+	case *time.Time:
+		if v == nil {
+			return nil, true
+		}
+		return v.UTC().Truncate(time.Microsecond), true
+	case float32:
+		return float64(v), true
+	case *firestore.DocumentRef:
+		return v, true
+	}
+	if value != nil {
+		// Sentinels y transforms del SDK (Increment, ServerTimestamp, ArrayUnion...)
+		if t := reflect.TypeOf(value); t.PkgPath() == "cloud.google.com/go/firestore" ||
+			(t.Kind() == reflect.Ptr && t.Elem().PkgPath() == "cloud.google.com/go/firestore") {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// withChecksum agrega a la escritura el checksum del estado resultante
+func withChecksum(w pendingWrite, previous map[string]interface{}) pendingWrite {
+	if w.kind == "delete" || w.kind == "check" {
+		return w
+	}
+	sum, ok := DocumentChecksum(w.nextState(previous))
+	var value interface{} = sum
+	if !ok {
+		value = firestore.Delete
+	}
+
+	if w.kind == "update" {
+		updates := make([]firestore.Update, 0, len(w.updates)+1)
+		updates = append(updates, w.updates...)
+		w.updates = append(updates, firestore.Update{Path: ChecksumField, Value: value})
+		return w
+	}
+
+	data := make(map[string]interface{}, len(w.data)+1)
+	for k, v := range w.data {
+		data[k] = v
+	}
+	if ok || w.kind == "merge" {
+		data[ChecksumField] = value
+	} else {
+		delete(data, ChecksumField)
+	}
+	w.data = data
+	return w
+}
+
+// VerifyDocumentIntegrity recalcula el checksum de un documento y lo compara con el
+// guardado. Retorna *firebase.IntegrityError (errors.Is(err, firebase.ErrIntegrity)) si
+// no coinciden o si el documento no tiene checksum.
+func VerifyDocumentIntegrity(ctx context.Context, collection, docID string) (err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	call := newCall(firebase.CallFirestoreGet, collection, docID, nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
+	collection = call.Collection
+
+	snap, err := client.Collection(collection).Doc(docID).Get(ctx)
+	if err != nil {
+		if IsNotFound(err) {
+			return &firebase.DocumentNotFoundError{Collection: collection, DocumentID: docID}
+		}
+		return fmt.Errorf("failed to get document '%s' from collection '%s': %w", docID, collection, err)
+	}
+	return verifySnapshot(collection, snap)
+}
+
+// VerifyCollectionIntegrity verifica todos los documentos de la colección y retorna los
+// que no superan la verificación
+func VerifyCollectionIntegrity(ctx context.Context, collection string) (_ []*firebase.IntegrityError, err error) {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	call := newCall(firebase.CallFirestoreQuery, collection, "", nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
+	collection = call.Collection
+
+	iter := client.Collection(collection).Documents(ctx)
+	defer iter.Stop()
+
+	var failures []*firebase.IntegrityError
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return failures, fmt.Errorf("failed to iterate documents: %w", err)
+		}
+		if err := verifySnapshot(collection, snap); err != nil {
+			failures = append(failures, err.(*firebase.IntegrityError))
+		}
+	}
+	return failures, nil
+}
+
+// verifySnapshot compara el checksum guardado con el del contenido actual
+func verifySnapshot(collection string, snap *firestore.DocumentSnapshot) error {
+	data := snap.Data()
+	expected, _ := data[ChecksumField].(string)
+	actual, _ := DocumentChecksum(data)
+	if expected == "" || expected != actual {
+		return &firebase.IntegrityError{Collection: collection, DocumentID: snap.Ref.ID, Expected: expected, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package firestore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+// EncodeJSONValue convierte un valor de Firestore a una forma JSON que conserva el tipo
+// al decodificarla con DecodeJSONValue: los timestamps, bytes, referencias y puntos
+// geográficos se envuelven en {"$time"}, {"$bytes"}, {"$ref"} y {"$geo"}.
+func EncodeJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = EncodeJSONValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = EncodeJSONValue(item)
+		}
+		return out
+	case time.Time:
+		return map[string]interface{}{"$time": v.UTC().Format(time.RFC3339Nano)}
+	case []byte:
+		return map[string]interface{}{"$bytes": base64.StdEncoding.EncodeToString(v)}
+	case *firestore.DocumentRef:
+		return map[string]interface{}{"$ref": v.Path}
+	case *latlng.LatLng:
+		return map[string]interface{}{"$geo": []interface{}{v.Latitude, v.Longitude}}
+	}
+	return value
+}
+
+// DecodeJSONValue revierte EncodeJSONValue sobre un valor decodificado con UseNumber: los
+// números enteros vuelven como int64 y el resto como float64. Las referencias se
+// restauran con client.
+func DecodeJSONValue(client *firestore.Client, value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = DecodeJSONValue(client, item)
+		}
+		return out
+	case map[string]interface{}:
+		if len(v) == 1 {
+			if s, ok := v["$time"].(string); ok {
+				if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					return t
+				}
+			}
+			if s, ok := v["$bytes"].(string); ok {
+				if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+					return b
+				}
+			}
+			if s, ok := v["$ref"].(string); ok && client != nil {
+				if i := strings.Index(s, "/documents/"); i >= 0 {
+					return client.Doc(s[i+len("/documents/"):])
+				}
+			}
+			if pair, ok := v["$geo"].([]interface{}); ok && len(pair) == 2 {
+				lat, _ := toFloat(DecodeJSONValue(nil, pair[0]))
+				lng, _ := toFloat(DecodeJSONValue(nil, pair[1]))
+				return &latlng.LatLng{Latitude: lat, Longitude: lng}
+			}
+		}
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = DecodeJSONValue(client, item)
+		}
+		return out
+	}
+	return value
+}
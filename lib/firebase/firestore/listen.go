@@ -0,0 +1,126 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Listen escucha los cambios de una consulta y llama a fn con los cambios de cada snapshot
+// (el primero trae todos los documentos como "added") hasta que ctx se cancele o fn
+// retorne un error. La consulta pasa por los interceptores (p. ej. filtros de tenant) y
+// los documentos que las políticas no permiten leer se omiten. Limit, Offset y Cursor no
+// se aplican.
+func Listen(ctx context.Context, collection string, options firebase.QueryOptions, fn func(changes []firebase.DocumentChange) error) error {
+	client := firebase.FirestoreClientFor(ctx, collection)
+
+	call := newCall(firebase.CallFirestoreQuery, collection, "", &options)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		firebase.FinishCall(ctx, call, err)
+		return err
+	}
+	collection = call.Collection
+
+	query, err := applyFilters(client.Collection(collection).Query, options.Filters)
+	if err != nil {
+		firebase.FinishCall(ctx, call, err)
+		return err
+	}
+	query = applyOrders(query, options.OrderClauses())
+	firebase.FinishCall(ctx, call, nil)
+
+	iter := query.Snapshots(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err != nil {
+			if status.Code(err) == codes.Canceled || ctx.Err() != nil {
+				return nil
+			}
+			return indexError(ctx, collection, err, fmt.Errorf("failed to listen to collection '%s': %w", collection, err))
+		}
+
+		var changes []firebase.DocumentChange
+		for _, change := range snap.Changes {
+			doc := &firebase.Document{ID: change.Doc.Ref.ID, Data: change.Doc.Data()}
+			if authorize(ctx, collection, doc, firebase.OperationRead) != nil {
+				continue
+			}
+			if err := decompressDocuments(collection, doc); err != nil {
+				return err
+			}
+			changes = append(changes, firebase.DocumentChange{Type: changeType(change.Kind), Document: doc, ReadTime: snap.ReadTime})
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if err := fn(changes); err != nil {
+			return err
+		}
+	}
+}
+
+// ListenDocument escucha un documento y llama a fn con cada versión ("added" la primera
+// vez que existe, "modified" después y "removed" al eliminarse)
+func ListenDocument(ctx context.Context, collection, docID string, fn func(change firebase.DocumentChange) error) error {
+	call := newCall(firebase.CallFirestoreGet, collection, docID, nil)
+	err := firebase.InterceptCall(ctx, call)
+	firebase.FinishCall(ctx, call, err)
+	if err != nil {
+		return err
+	}
+	collection = call.Collection
+
+	iter := firebase.FirestoreClientFor(ctx, collection).Collection(collection).Doc(docID).Snapshots(ctx)
+	defer iter.Stop()
+	existed := false
+	for {
+		snap, err := iter.Next()
+		if err != nil {
+			if status.Code(err) == codes.Canceled || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to listen to document '%s' in collection '%s': %w", docID, collection, err)
+		}
+
+		change := firebase.DocumentChange{Document: &firebase.Document{ID: docID}, ReadTime: snap.ReadTime}
+		switch {
+		case snap.Exists():
+			change.Document.Data = snap.Data()
+			change.Type = "modified"
+			if !existed {
+				change.Type = "added"
+			}
+			existed = true
+		case existed:
+			change.Type = "removed"
+			existed = false
+		default:
+			continue
+		}
+		if change.Type != "removed" && authorize(ctx, collection, change.Document, firebase.OperationRead) != nil {
+			continue
+		}
+		if err := decompressDocuments(collection, change.Document); err != nil {
+			return err
+		}
+		if err := fn(change); err != nil {
+			return err
+		}
+	}
+}
+
+func changeType(kind firestore.DocumentChangeKind) string {
+	switch kind {
+	case firestore.DocumentAdded:
+		return "added"
+	case firestore.DocumentRemoved:
+		return "removed"
+	}
+	return "modified"
+}
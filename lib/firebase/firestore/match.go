@@ -0,0 +1,88 @@
+package firestore
+
+import (
+	"reflect"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// MatchesFilters evalúa los filtros sobre un documento en memoria con la semántica de
+// una consulta de Firestore: un campo ausente no cumple ningún filtro
+func MatchesFilters(doc *firebase.Document, filters []firebase.QueryFilter) bool {
+	for _, filter := range filters {
+		value, present := doc.Get(filter.Field)
+		if !present || !matchesFilter(value, filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesFilter(value interface{}, filter firebase.QueryFilter) bool {
+	switch filter.Operator {
+	case firebase.OpEqual:
+		return sameValue(value, filter.Value)
+	case firebase.OpNotEqual:
+		return value != nil && !sameValue(value, filter.Value)
+	case firebase.OpLessThan, firebase.OpLessThanOrEqual, firebase.OpGreaterThan, firebase.OpGreaterThanOrEqual:
+		// Las desigualdades solo comparan valores del mismo tipo
+		if typeRank(value) != typeRank(filter.Value) {
+			return false
+		}
+		cmp := compareValues(value, filter.Value)
+		switch filter.Operator {
+		case firebase.OpLessThan:
+			return cmp < 0
+		case firebase.OpLessThanOrEqual:
+			return cmp <= 0
+		case firebase.OpGreaterThan:
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	case firebase.OpIn, firebase.OpNotIn:
+		candidates, _ := toInterfaceSlice(filter.Value)
+		found := false
+		for _, candidate := range candidates {
+			if sameValue(value, candidate) {
+				found = true
+				break
+			}
+		}
+		if filter.Operator == firebase.OpIn {
+			return found
+		}
+		return value != nil && !found
+	case firebase.OpArrayContains, firebase.OpArrayContainsAny:
+		items, ok := toInterfaceSlice(value)
+		if !ok {
+			return false
+		}
+		candidates := []interface{}{filter.Value}
+		if filter.Operator == firebase.OpArrayContainsAny {
+			candidates, _ = toInterfaceSlice(filter.Value)
+		}
+		for _, item := range items {
+			for _, candidate := range candidates {
+				if sameValue(item, candidate) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// sameValue igualdad de Firestore: los números se comparan por valor sin importar el tipo
+func sameValue(a, b interface{}) bool {
+	if typeRank(a) != typeRank(b) {
+		return false
+	}
+	if typeRank(a) == typeRank(nil) {
+		return true
+	}
+	if typeRank(a) < 5 {
+		return compareValues(a, b) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
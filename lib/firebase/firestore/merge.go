@@ -0,0 +1,139 @@
+package firestore
+
+import (
+	"sync"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// MergeStrategy combina el valor almacenado de un campo con el entrante. exists indica si
+// el campo existía en el documento almacenado.
+type MergeStrategy func(current, incoming interface{}, exists bool) interface{}
+
+var (
+	mergeMu         sync.RWMutex
+	mergeStrategies = map[string]map[string]MergeStrategy{} // colección -> campo ("*" = resto)
+)
+
+// RegisterMergeStrategy define cómo UpdateDocument (y datasync con la política
+// MergeFields) combina un campo con el valor almacenado, en lugar de reemplazarlo. field
+// "*" aplica a los campos sin estrategia propia. Con estrategias registradas,
+// UpdateDocument lee y escribe el documento en una transacción.
+func RegisterMergeStrategy(collection, field string, strategy MergeStrategy) {
+	mergeMu.Lock()
+	defer mergeMu.Unlock()
+	if mergeStrategies[collection] == nil {
+		mergeStrategies[collection] = map[string]MergeStrategy{}
+	}
+	mergeStrategies[collection][field] = strategy
+}
+
+func hasMergeStrategies(collection string) bool {
+	mergeMu.RLock()
+	defer mergeMu.RUnlock()
+	return len(mergeStrategies[firebase.LogicalCollection(collection)]) > 0
+}
+
+// MergeDocuments combina incoming sobre current campo por campo con las estrategias de la
+// colección (sin estrategia, el valor entrante reemplaza al almacenado). Retorna los
+// campos a escribir: los de incoming con su valor combinado.
+func MergeDocuments(collection string, current, incoming map[string]interface{}) map[string]interface{} {
+	mergeMu.RLock()
+	strategies := mergeStrategies[firebase.LogicalCollection(collection)]
+	mergeMu.RUnlock()
+
+	merged := make(map[string]interface{}, len(incoming))
+	for field, value := range incoming {
+		strategy, ok := strategies[field]
+		if !ok {
+			strategy, ok = strategies["*"]
+		}
+		if !ok || field == "updated_at" || field == "created_at" {
+			merged[field] = value
+			continue
+		}
+		old, exists := current[field]
+		merged[field] = strategy(old, value, exists)
+	}
+	return merged
+}
+
+// MergeReplace el valor entrante reemplaza al almacenado (comportamiento por defecto)
+func MergeReplace(current, incoming interface{}, exists bool) interface{} {
+	return incoming
+}
+
+// MergeDeep combina mapas recursivamente; los demás valores se reemplazan
+func MergeDeep(current, incoming interface{}, exists bool) interface{} {
+	oldMap, ok1 := current.(map[string]interface{})
+	newMap, ok2 := incoming.(map[string]interface{})
+	if !exists || !ok1 || !ok2 {
+		return incoming
+	}
+	out := make(map[string]interface{}, len(oldMap)+len(newMap))
+	for k, v := range oldMap {
+		out[k] = v
+	}
+	for k, v := range newMap {
+		previous, had := oldMap[k]
+		out[k] = MergeDeep(previous, v, had)
+	}
+	return out
+}
+
+// MergeSetUnion une los arreglos sin duplicados (conserva el orden: primero los
+// almacenados)
+func MergeSetUnion(current, incoming interface{}, exists bool) interface{} {
+	oldList, ok1 := current.([]interface{})
+	newList, ok2 := incoming.([]interface{})
+	if !exists || !ok1 || !ok2 {
+		return incoming
+	}
+	out := append([]interface{}(nil), oldList...)
+	for _, item := range newList {
+		found := false
+		for _, existing := range out {
+			if sameValue(existing, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// MergeMax conserva el mayor de dos números (útil para contadores monótonos)
+func MergeMax(current, incoming interface{}, exists bool) interface{} {
+	a, ok1 := toFloat(current)
+	b, ok2 := toFloat(incoming)
+	if exists && ok1 && ok2 && a > b {
+		return current
+	}
+	return incoming
+}
+
+// MergeMin conserva el menor de dos números
+func MergeMin(current, incoming interface{}, exists bool) interface{} {
+	a, ok1 := toFloat(current)
+	b, ok2 := toFloat(incoming)
+	if exists && ok1 && ok2 && a < b {
+		return current
+	}
+	return incoming
+}
+
+// mergeWrite aplica las estrategias dentro de la transacción: reemplaza el contenido de
+// data por la combinación de incoming con el documento leído
+func mergeWrite(collection string, data, incoming, current map[string]interface{}) {
+	merged := MergeDocuments(collection, current, incoming)
+	for k := range data {
+		delete(data, k)
+	}
+	for k, v := range merged {
+		data[k] = v
+	}
+	data["updated_at"] = firebase.Now()
+}
@@ -0,0 +1,35 @@
+package firestore
+
+import (
+	"context"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// OwnerField campo donde se guarda el UID del propietario de un documento
+const OwnerField = "owner_uid"
+
+// CreateOwnedDocument crea un documento marcado con el UID del usuario de la sesión
+func CreateOwnedDocument(ctx context.Context, session *firebase.SessionInfo, collection string, data map[string]interface{}) (string, error) {
+	if session == nil || session.UID == "" {
+		return "", firebase.ErrUnauthenticated
+	}
+
+	data[OwnerField] = session.UID
+	return CreateDocument(ctx, collection, data)
+}
+
+// QueryMyDocuments consulta solo los documentos que pertenecen al usuario de la sesión
+func QueryMyDocuments(ctx context.Context, session *firebase.SessionInfo, collection string, options firebase.QueryOptions) ([]*firebase.Document, error) {
+	if session == nil || session.UID == "" {
+		return nil, firebase.ErrUnauthenticated
+	}
+
+	// Copiar los filtros para no modificar el slice del llamador
+	filters := make([]firebase.QueryFilter, 0, len(options.Filters)+1)
+	filters = append(filters, options.Filters...)
+	filters = append(filters, firebase.QueryFilter{Field: OwnerField, Operator: "==", Value: session.UID})
+	options.Filters = filters
+
+	return QueryDocuments(ctx, collection, options)
+}
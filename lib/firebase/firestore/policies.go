@@ -0,0 +1,96 @@
+package firestore
+
+import (
+	"context"
+	"sync"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Policy decide si la sesión puede realizar op sobre doc. Para create, doc contiene
+// los datos a escribir; para update y delete, el documento almacenado.
+type Policy func(session *firebase.SessionInfo, doc *firebase.Document, op firebase.Operation) bool
+
+var (
+	policiesMu sync.RWMutex
+	policies   = map[string][]Policy{}
+)
+
+// RegisterPolicy registra una política de acceso para una colección. Las políticas solo
+// se evalúan cuando el contexto transporta una sesión (firebase.WithSession) y todas
+// deben permitir la operación.
+func RegisterPolicy(collection string, policy Policy) {
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	policies[collection] = append(policies[collection], policy)
+}
+
+// ClearPolicies elimina las políticas registradas para una colección
+func ClearPolicies(collection string) {
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	delete(policies, collection)
+}
+
+// activePolicies retorna la sesión y las políticas aplicables, o nil si no hay que evaluar nada
+func activePolicies(ctx context.Context, collection string) (*firebase.SessionInfo, []Policy) {
+	session, ok := firebase.SessionFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	collection = firebase.LogicalCollection(collection)
+	policiesMu.RLock()
+	defer policiesMu.RUnlock()
+	if len(policies[collection]) == 0 {
+		return nil, nil
+	}
+	return session, append([]Policy(nil), policies[collection]...)
+}
+
+// authorize evalúa las políticas de la colección para un documento
+func authorize(ctx context.Context, collection string, doc *firebase.Document, op firebase.Operation) error {
+	session, rules := activePolicies(ctx, collection)
+	if rules == nil {
+		return nil
+	}
+	for _, policy := range rules {
+		if !policy(session, doc, op) {
+			return &firebase.PermissionDeniedError{Collection: collection, DocumentID: doc.ID, Operation: op}
+		}
+	}
+	return nil
+}
+
+// authorizeStored carga el documento almacenado y evalúa las políticas (update/delete)
+func authorizeStored(ctx context.Context, collection, docID string, op firebase.Operation) error {
+	if _, rules := activePolicies(ctx, collection); rules == nil {
+		return nil
+	}
+
+	snap, err := firebase.FirestoreClientFor(ctx, collection).Collection(collection).Doc(docID).Get(ctx)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	doc := &firebase.Document{ID: docID, Data: map[string]interface{}{}}
+	if snap != nil && snap.Exists() {
+		doc.Data = snap.Data()
+	}
+	return authorize(ctx, collection, doc, op)
+}
+
+// filterReadable descarta los documentos que la sesión no puede leer
+func filterReadable(ctx context.Context, collection string, docs []*firebase.Document) []*firebase.Document {
+	if _, rules := activePolicies(ctx, collection); rules == nil {
+		return docs
+	}
+
+	readable := docs[:0]
+	for _, doc := range docs {
+		if authorize(ctx, collection, doc, firebase.OperationRead) == nil {
+			readable = append(readable, doc)
+		}
+	}
+	return readable
+}
@@ -0,0 +1,164 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// LinksCollection colección de unión para relaciones muchos-a-muchos. Cada relación se
+// guarda dos veces (directa e inversa) para poder listarla desde cualquiera de los extremos.
+const LinksCollection = "links"
+
+// Link relaciona collectionA/idA con collectionB/idB (idempotente: las relaciones que
+// ya existen conservan su created_at)
+func Link(ctx context.Context, collectionA, idA, collectionB, idB string) error {
+	var operations []firebase.BatchOperation
+	for _, dir := range [][4]string{{collectionA, idA, collectionB, idB}, {collectionB, idB, collectionA, idA}} {
+		id := linkID(dir[0], dir[1], dir[2], dir[3])
+		exists, err := DocumentExists(ctx, LinksCollection, id)
+		if err != nil {
+			return fmt.Errorf("failed to link '%s/%s' with '%s/%s': %w", collectionA, idA, collectionB, idB, err)
+		}
+		if !exists {
+			operations = append(operations, firebase.BatchOperation{Type: "create", Collection: LinksCollection, DocumentID: id, Data: linkData(dir[0], dir[1], dir[2], dir[3])})
+		}
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+
+	err := BatchWrite(ctx, operations)
+	if err != nil {
+		return fmt.Errorf("failed to link '%s/%s' with '%s/%s': %w", collectionA, idA, collectionB, idB, err)
+	}
+	return nil
+}
+
+// Unlink elimina la relación entre collectionA/idA y collectionB/idB
+func Unlink(ctx context.Context, collectionA, idA, collectionB, idB string) error {
+	err := BatchWrite(ctx, []firebase.BatchOperation{
+		{Type: "delete", Collection: LinksCollection, DocumentID: linkID(collectionA, idA, collectionB, idB)},
+		{Type: "delete", Collection: LinksCollection, DocumentID: linkID(collectionB, idB, collectionA, idA)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unlink '%s/%s' from '%s/%s': %w", collectionA, idA, collectionB, idB, err)
+	}
+	return nil
+}
+
+// IsLinked indica si existe la relación entre ambos documentos
+func IsLinked(ctx context.Context, collectionA, idA, collectionB, idB string) (bool, error) {
+	_, err := GetDocument(ctx, LinksCollection, linkID(collectionA, idA, collectionB, idB))
+	if err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ListLinked lista los documentos de targetCollection relacionados con collection/id,
+// paginados por pageSize. El cursor es el NextCursor del resultado anterior.
+func ListLinked(ctx context.Context, collection, id, targetCollection string, pageSize int, cursor string) (*firebase.QueryResult, error) {
+	links, err := QueryDocumentsWithMeta(ctx, LinksCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			{Field: "from_collection", Operator: firebase.OpEqual, Value: collection},
+			{Field: "from_id", Operator: firebase.OpEqual, Value: id},
+			{Field: "to_collection", Operator: firebase.OpEqual, Value: targetCollection},
+		},
+		OrderBy: "to_id",
+		Limit:   pageSize,
+		Cursor:  cursor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links of '%s/%s': %w", collection, id, err)
+	}
+
+	client := firebase.FirestoreClientFor(ctx, targetCollection)
+	result := &firebase.QueryResult{
+		DocumentsRead: links.DocumentsRead,
+		ReadTime:      links.ReadTime,
+		Truncated:     links.Truncated,
+		NextCursor:    links.NextCursor,
+	}
+	if len(links.Documents) == 0 {
+		return result, nil
+	}
+
+	// Cada destino pasa por los interceptores como en GetDocument (tenancy, alcance de las
+	// claves de API); la colección resuelta es la misma para todos
+	refs := make([]*firestore.DocumentRef, len(links.Documents))
+	resolved := targetCollection
+	for i, link := range links.Documents {
+		targetID, _ := link.Data["to_id"].(string)
+		refs[i], resolved, err = linkedRef(ctx, client, targetCollection, targetID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	snaps, err := client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents linked to '%s/%s': %w", collection, id, err)
+	}
+	for _, snap := range snaps {
+		result.DocumentsRead++
+		if !snap.Exists() {
+			continue // relación huérfana: el documento destino fue eliminado
+		}
+		result.Documents = append(result.Documents, &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()})
+	}
+	result.Documents = filterReadable(ctx, resolved, result.Documents)
+	if currentBlobOptions().Threshold > 0 {
+		for _, document := range result.Documents {
+			if err := RehydrateBlobs(ctx, document); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := decompressDocuments(resolved, result.Documents...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// linkedRef referencia del documento destino con la colección que resuelven los
+// interceptores
+func linkedRef(ctx context.Context, client *firestore.Client, collection, docID string) (_ *firestore.DocumentRef, _ string, err error) {
+	call := newCall(firebase.CallFirestoreGet, collection, docID, nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, "", err
+	}
+	return client.Collection(call.Collection).Doc(docID), call.Collection, nil
+}
+
+func linkData(fromCollection, fromID, toCollection, toID string) map[string]interface{} {
+	return map[string]interface{}{
+		"from_collection": fromCollection,
+		"from_id":         fromID,
+		"to_collection":   toCollection,
+		"to_id":           toID,
+	}
+}
+
+// linkID deriva un ID determinista para la relación
+func linkID(fromCollection, fromID, toCollection, toID string) string {
+	return CompositeID(fromCollection, fromID, toCollection, toID)
+}
+
+// CompositeID une varias partes en un ID de documento determinista y sin ambigüedad:
+// cada parte se escapa (incluidos "/" y "_") y se separa con "__"
+func CompositeID(parts ...string) string {
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		escaped[i] = strings.ReplaceAll(url.PathEscape(part), "_", "%5F")
+	}
+	return strings.Join(escaped, "__")
+}
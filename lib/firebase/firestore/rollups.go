@@ -0,0 +1,388 @@
+package firestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// RollupSpec define un agregado materializado: por cada valor de GroupBy se mantiene un
+// documento en Target con el conteo de documentos y la suma de los campos de Sum
+type RollupSpec struct {
+	Collection string   `json:"collection"`
+	GroupBy    string   `json:"group_by"`
+	Sum        []string `json:"sum,omitempty"`
+	Target     string   `json:"target,omitempty"` // por defecto "<collection>_by_<group_by>"
+}
+
+// maxRollupIDBytes límite de Firestore para el ID de un documento
+const maxRollupIDBytes = 1500
+
+var (
+	rollupsMu sync.RWMutex
+	rollups   = map[string][]RollupSpec{}
+)
+
+// RegisterAggregate registra un agregado materializado. Desde ese momento toda escritura
+// sobre la colección hecha a través del paquete actualiza el rollup en la misma transacción.
+// Usar RebuildAggregate para calcular el estado inicial de datos ya existentes.
+func RegisterAggregate(spec RollupSpec) error {
+	if spec.Collection == "" || spec.GroupBy == "" {
+		return fmt.Errorf("rollup requires collection and group_by")
+	}
+	if spec.Target == "" {
+		spec.Target = fmt.Sprintf("%s_by_%s", spec.Collection, spec.GroupBy)
+	}
+
+	rollupsMu.Lock()
+	defer rollupsMu.Unlock()
+	rollups[spec.Collection] = append(rollups[spec.Collection], spec)
+	return nil
+}
+
+// RebuildAggregate recalcula un rollup desde cero recorriendo la colección origen y
+// elimina los grupos que ya no tienen documentos. El recorrido pasa por los interceptores
+// como una consulta: con tenancy por prefijo se recalcula el rollup del tenant del
+// contexto (tenants/{id}/<target>). Si un interceptor filtra la consulta (p. ej. tenancy
+// por campo) el rollup es compartido y se rechaza: usar un contexto sin alcance.
+func RebuildAggregate(ctx context.Context, spec RollupSpec) (err error) {
+	if spec.Target == "" {
+		spec.Target = fmt.Sprintf("%s_by_%s", spec.Collection, spec.GroupBy)
+	}
+
+	var scope []firebase.QueryFilter
+	call := newCall(firebase.CallFirestoreQuery, spec.Collection, "", &scope)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
+	if len(scope) > 0 {
+		return fmt.Errorf("rollup '%s' is shared across scopes and cannot be rebuilt from a filtered scan", spec.Target)
+	}
+	source := call.Collection
+	target := rollupTarget(source, spec.Target)
+	client := firebase.FirestoreClientFor(ctx, source)
+
+	totals := make(map[string]map[string]interface{})
+	iter := client.Collection(source).Documents(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to scan collection '%s': %w", source, err)
+		}
+
+		data := snap.Data()
+		id := rollupDocID(data[spec.GroupBy])
+		row, ok := totals[id]
+		if !ok {
+			row = map[string]interface{}{"group_key": data[spec.GroupBy], "count": int64(0)}
+			for _, field := range spec.Sum {
+				row["sum_"+field] = float64(0)
+			}
+			totals[id] = row
+		}
+		row["count"] = row["count"].(int64) + 1
+		for _, field := range spec.Sum {
+			value, _ := toFloat(data[field])
+			row["sum_"+field] = row["sum_"+field].(float64) + value
+		}
+	}
+
+	stale, err := client.Collection(target).DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to list rollup collection '%s': %w", target, err)
+	}
+
+	batch := client.BulkWriter(ctx)
+	defer batch.End()
+	jobs := make(map[string]*firestore.BulkWriterJob, len(totals)+len(stale))
+	for id, row := range totals {
+		row["updated_at"] = firebase.Now()
+		job, err := batch.Set(client.Collection(target).Doc(id), row)
+		if err != nil {
+			return fmt.Errorf("failed to write rollup '%s': %w", id, err)
+		}
+		jobs[id] = job
+	}
+	for _, ref := range stale {
+		if _, ok := totals[ref.ID]; ok {
+			continue
+		}
+		job, err := batch.Delete(ref)
+		if err != nil {
+			return fmt.Errorf("failed to delete rollup '%s': %w", ref.ID, err)
+		}
+		jobs[ref.ID] = job
+	}
+	batch.Flush()
+
+	for id, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return fmt.Errorf("failed to write rollup '%s': %w", id, err)
+		}
+	}
+	return nil
+}
+
+// rollupTarget ruta de la colección de rollup para la colección origen collection (ya
+// resuelta por los interceptores): el rollup queda bajo el mismo prefijo que el origen
+// ("tenants/{id}/orders" -> "tenants/{id}/<target>")
+func rollupTarget(collection, target string) string {
+	return strings.TrimSuffix(collection, firebase.LogicalCollection(collection)) + target
+}
+
+func hasRollups(collection string) bool {
+	rollupsMu.RLock()
+	defer rollupsMu.RUnlock()
+	return len(rollups[firebase.LogicalCollection(collection)]) > 0
+}
+
+func rollupsFor(collection string) []RollupSpec {
+	rollupsMu.RLock()
+	defer rollupsMu.RUnlock()
+	return append([]RollupSpec(nil), rollups[firebase.LogicalCollection(collection)]...)
+}
+
+// pendingWrite describe una escritura que debe ejecutarse dentro de una transacción
+type pendingWrite struct {
+	collection string
+	ref        *firestore.DocumentRef
+	kind       string // "create", "set", "merge", "update", "delete"
+	data       map[string]interface{}
+	updates    []firestore.Update
+}
+
+func (w pendingWrite) apply(tx *firestore.Transaction) error {
+	switch w.kind {
+	case "create":
+		return tx.Create(w.ref, w.data)
+	case "set":
+		return tx.Set(w.ref, w.data)
+	case "merge":
+		return tx.Set(w.ref, w.data, firestore.MergeAll)
+	case "update":
+		return tx.Update(w.ref, w.updates)
+	case "delete":
+		return tx.Delete(w.ref)
+	}
+	return fmt.Errorf("unsupported write kind: %s", w.kind)
+}
+
+// nextState calcula el documento resultante de la escritura a partir del estado previo
+func (w pendingWrite) nextState(old map[string]interface{}) map[string]interface{} {
+	switch w.kind {
+	case "create", "set":
+		return w.data
+	case "delete":
+		return nil
+	}
+
+	next := make(map[string]interface{}, len(old))
+	for k, v := range old {
+		next[k] = v
+	}
+	if w.kind == "merge" {
+		mergeInto(next, w.data)
+		return next
+	}
+	for _, update := range w.updates {
+		path := update.FieldPath
+		if update.Path != "" {
+			path = strings.Split(update.Path, ".")
+		}
+		setPath(next, path, update.Value)
+	}
+	return next
+}
+
+// mergeInto aplica data sobre dst como Set con MergeAll: los mapas anidados se combinan
+// campo a campo en lugar de reemplazarse
+func mergeInto(dst, data map[string]interface{}) {
+	for k, v := range data {
+		// Un mapa vacío es una hoja para MergeAll: reemplaza el valor almacenado
+		if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+			current, _ := dst[k].(map[string]interface{})
+			merged := make(map[string]interface{}, len(current)+len(nested))
+			for ck, cv := range current {
+				merged[ck] = cv
+			}
+			mergeInto(merged, nested)
+			dst[k] = merged
+			continue
+		}
+		if v == firestore.Delete {
+			delete(dst, k)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// setPath asigna value en la ruta anidada de doc copiando los mapas intermedios
+// (firestore.Delete elimina el campo)
+func setPath(doc map[string]interface{}, path []string, value interface{}) {
+	if len(path) == 0 {
+		return
+	}
+	if len(path) == 1 {
+		if value == firestore.Delete {
+			delete(doc, path[0])
+		} else {
+			doc[path[0]] = value
+		}
+		return
+	}
+	current, _ := doc[path[0]].(map[string]interface{})
+	child := make(map[string]interface{}, len(current))
+	for k, v := range current {
+		child[k] = v
+	}
+	setPath(child, path[1:], value)
+	doc[path[0]] = child
+}
+
+// rollupDelta cambios acumulados para un documento de rollup
+type rollupDelta struct {
+	ref      *firestore.DocumentRef
+	groupKey interface{}
+	count    int64
+	sums     map[string]float64
+}
+
+// commitWithRollups ejecuta las escrituras en una transacción junto con los incrementos
+// de los agregados materializados de cada colección afectada
+func commitWithRollups(ctx context.Context, writes []pendingWrite) error {
+	return commitChecked(ctx, writes, nil)
+}
+
+// commitChecked es como commitWithRollups pero ejecuta before al inicio de la transacción
+// (lecturas y precondiciones); si before falla no se escribe nada
+func commitChecked(ctx context.Context, writes []pendingWrite, before func(tx *firestore.Transaction) error) error {
+	// La transacción y los agregados van a la región de las escrituras
+	if len(writes) > 0 {
+		ctx = firebase.WithRegion(ctx, firebase.RegionFor(ctx, writes[0].collection))
+	}
+	client := firebase.FirestoreClientFor(ctx, "")
+
+	return RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if before != nil {
+			if err := before(tx); err != nil {
+				return err
+			}
+		}
+
+		// 1. Lecturas: estado previo de cada documento (deben preceder a las escrituras)
+		previous := make([]map[string]interface{}, len(writes))
+		for i, w := range writes {
+			if w.kind == "create" || !needsTransaction(w.collection) {
+				continue
+			}
+			snap, err := tx.Get(w.ref)
+			if err != nil && !IsNotFound(err) {
+				return err
+			}
+			if snap != nil && snap.Exists() {
+				previous[i] = snap.Data()
+			}
+		}
+
+		// 2. Calcular los deltas de cada rollup
+		deltas := make(map[string]*rollupDelta)
+		addContribution := func(collection string, spec RollupSpec, data map[string]interface{}, sign int64) {
+			if data == nil {
+				return
+			}
+			ref := client.Collection(rollupTarget(collection, spec.Target)).Doc(rollupDocID(data[spec.GroupBy]))
+			delta, ok := deltas[ref.Path]
+			if !ok {
+				delta = &rollupDelta{ref: ref, groupKey: data[spec.GroupBy], sums: make(map[string]float64)}
+				deltas[ref.Path] = delta
+			}
+			delta.count += sign
+			for _, field := range spec.Sum {
+				value, _ := toFloat(data[field])
+				delta.sums[field] += float64(sign) * value
+			}
+		}
+		for i, w := range writes {
+			for _, spec := range rollupsFor(w.collection) {
+				addContribution(w.collection, spec, previous[i], -1)
+				addContribution(w.collection, spec, w.nextState(previous[i]), 1)
+			}
+		}
+
+		// 3. Escrituras (con el checksum del estado resultante si la colección lo usa)
+		for i, w := range writes {
+			if integrityEnabled(w.collection) {
+				w = withChecksum(w, previous[i])
+			}
+			if err := w.apply(tx); err != nil {
+				return err
+			}
+		}
+		for _, delta := range deltas {
+			if delta.count == 0 && allZero(delta.sums) {
+				continue
+			}
+			row := map[string]interface{}{
+				"group_key":  delta.groupKey,
+				"count":      firestore.Increment(delta.count),
+				"updated_at": firebase.Now(),
+			}
+			for field, value := range delta.sums {
+				row["sum_"+field] = firestore.Increment(value)
+			}
+			if err := tx.Set(delta.ref, row, firestore.MergeAll); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func allZero(values map[string]float64) bool {
+	for _, v := range values {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rollupDocID deriva el ID del documento de rollup a partir del valor de agrupación.
+// El valor se escapa de forma reversible para que nunca sea un ID inválido ("/", "." o
+// "..", "__x__") ni coincida con los IDs reservados que empiezan por "_".
+func rollupDocID(key interface{}) string {
+	if key == nil {
+		return "_null"
+	}
+	value := fmt.Sprint(key)
+	if value == "" {
+		return "_empty"
+	}
+
+	id := strings.NewReplacer("%", "%25", "/", "%2F").Replace(value)
+	switch {
+	case id == "." || id == "..":
+		id = strings.ReplaceAll(id, ".", "%2E")
+	case strings.HasPrefix(id, "_"):
+		id = "%5F" + id[1:]
+	}
+	if len(id) > maxRollupIDBytes {
+		sum := sha256.Sum256([]byte(value))
+		return "_h" + hex.EncodeToString(sum[:])
+	}
+	return id
+}
@@ -0,0 +1,172 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// SavedSearchesCollection búsquedas guardadas por los usuarios ("<uid>__<nombre>")
+const SavedSearchesCollection = "saved_searches"
+
+// SavedSearch consulta con nombre guardada por un usuario. Igual que en las vistas, un
+// string "$nombre" en el valor de un filtro se reemplaza por params["nombre"] al
+// ejecutarla. De Options se guardan los filtros, el orden y el límite.
+type SavedSearch struct {
+	UID         string                `json:"uid"`
+	Name        string                `json:"name"`
+	Collection  string                `json:"collection"`
+	Options     firebase.QueryOptions `json:"options"`
+	Description string                `json:"description,omitempty"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+}
+
+// SaveSearch guarda (o reemplaza) una búsqueda del usuario
+func SaveSearch(ctx context.Context, uid string, search SavedSearch) (*SavedSearch, error) {
+	if uid == "" || strings.Contains(uid, "/") || search.Name == "" || strings.Contains(search.Name, "/") || search.Collection == "" {
+		return nil, fmt.Errorf("saved search requires a uid, a valid name and a collection")
+	}
+	for _, filter := range search.Options.Filters {
+		if !filter.Operator.IsValid() {
+			return nil, &firebase.InvalidOperatorError{Field: filter.Field, Operator: filter.Operator}
+		}
+	}
+
+	// OrderBy/OrderDir se guardan como la primera cláusula de orden
+	var orderClauses []firebase.OrderClause
+	if search.Options.OrderBy != "" {
+		orderClauses = append(orderClauses, firebase.OrderClause{Field: search.Options.OrderBy, Direction: search.Options.OrderDir})
+	}
+	orderClauses = append(orderClauses, search.Options.Orders...)
+	orders := make([]interface{}, len(orderClauses))
+	for i, order := range orderClauses {
+		orders[i] = map[string]interface{}{"field": order.Field, "direction": order.Direction}
+	}
+
+	now := firebase.Now()
+	ref := DocRefContext(ctx, SavedSearchesCollection, savedSearchID(uid, search.Name))
+	createdAt := now
+	if snap, err := ref.Get(ctx); err == nil {
+		if existing, ok := snap.Data()["created_at"].(time.Time); ok {
+			createdAt = existing
+		}
+	} else if !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get saved search '%s': %w", search.Name, err)
+	}
+
+	_, err := ref.Set(ctx, map[string]interface{}{
+		"uid":         uid,
+		"name":        search.Name,
+		"collection":  search.Collection,
+		"filters":     encodeFilters(search.Options.Filters),
+		"orders":      orders,
+		"limit":       search.Options.Limit,
+		"description": search.Description,
+		"created_at":  createdAt,
+		"updated_at":  now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save search '%s': %w", search.Name, err)
+	}
+
+	search.UID = uid
+	search.Options = firebase.QueryOptions{Filters: search.Options.Filters, Orders: orderClauses, Limit: search.Options.Limit}
+	search.CreatedAt, search.UpdatedAt = createdAt, now
+	return &search, nil
+}
+
+// GetSavedSearch obtiene una búsqueda guardada del usuario
+func GetSavedSearch(ctx context.Context, uid, name string) (*SavedSearch, error) {
+	id := savedSearchID(uid, name)
+	snap, err := DocRefContext(ctx, SavedSearchesCollection, id).Get(ctx)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, &firebase.DocumentNotFoundError{Collection: SavedSearchesCollection, DocumentID: id}
+		}
+		return nil, fmt.Errorf("failed to get saved search '%s': %w", name, err)
+	}
+	return savedSearchFromDocument(&firebase.Document{ID: snap.Ref.ID, Data: snap.Data()})
+}
+
+// ListSavedSearches lista las búsquedas guardadas del usuario ordenadas por nombre
+func ListSavedSearches(ctx context.Context, uid string) ([]*SavedSearch, error) {
+	docs, err := QueryDocuments(ctx, SavedSearchesCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{{Field: "uid", Operator: firebase.OpEqual, Value: uid}},
+		OrderBy: "name",
+	})
+	if err != nil {
+		return nil, err
+	}
+	searches := make([]*SavedSearch, 0, len(docs))
+	for _, doc := range docs {
+		search, err := savedSearchFromDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	return searches, nil
+}
+
+// DeleteSavedSearch elimina una búsqueda guardada del usuario
+func DeleteSavedSearch(ctx context.Context, uid, name string) error {
+	if _, err := DocRefContext(ctx, SavedSearchesCollection, savedSearchID(uid, name)).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete saved search '%s': %w", name, err)
+	}
+	return nil
+}
+
+// RunSavedSearch ejecuta la búsqueda guardada con los parámetros indicados a través de
+// QueryDocumentsWithMeta (aplican interceptores, tenancy y políticas de lectura). cursor
+// es el NextCursor de la página anterior, o vacío.
+func RunSavedSearch(ctx context.Context, uid, name string, params map[string]interface{}, cursor string) (*firebase.QueryResult, error) {
+	search, err := GetSavedSearch(ctx, uid, name)
+	if err != nil {
+		return nil, err
+	}
+	filters, err := bindParams(search.Options.Filters, params)
+	if err != nil {
+		return nil, fmt.Errorf("saved search '%s': %w", name, err)
+	}
+	options := search.Options
+	options.Filters = filters
+	options.Cursor = cursor
+	return QueryDocumentsWithMeta(ctx, search.Collection, options)
+}
+
+func savedSearchFromDocument(doc *firebase.Document) (*SavedSearch, error) {
+	search := &SavedSearch{}
+	search.UID, _ = doc.GetString("uid")
+	search.Name, _ = doc.GetString("name")
+	search.Collection, _ = doc.GetString("collection")
+	search.Description, _ = doc.GetString("description")
+	search.CreatedAt, _ = doc.GetTime("created_at")
+	search.UpdatedAt, _ = doc.GetTime("updated_at")
+	if limit, ok := doc.GetInt("limit"); ok {
+		search.Options.Limit = int(limit)
+	}
+
+	filters, err := decodeFilters(doc)
+	if err != nil {
+		return nil, fmt.Errorf("saved search '%s': %w", doc.ID, err)
+	}
+	search.Options.Filters = filters
+
+	orders, _ := doc.Get("orders")
+	items, _ := orders.([]interface{})
+	for _, item := range items {
+		entry := &firebase.Document{Data: asMap(item)}
+		field, _ := entry.GetString("field")
+		direction, _ := entry.GetString("direction")
+		search.Options.Orders = append(search.Options.Orders, firebase.OrderClause{Field: field, Direction: direction})
+	}
+	return search, nil
+}
+
+func savedSearchID(uid, name string) string {
+	return uid + "__" + name
+}
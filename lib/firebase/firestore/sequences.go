@@ -0,0 +1,92 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+const sequencesCollection = "sequences"
+
+// NextSequence retorna el siguiente valor del contador name (empieza en 1). Cada
+// llamada es una transacción, por lo que los valores son únicos y consecutivos.
+func NextSequence(ctx context.Context, name string) (int64, error) {
+	start, _, err := ReserveSequenceBlock(ctx, name, 1)
+	return start, err
+}
+
+// ReserveSequenceBlock reserva transaccionalmente size valores consecutivos del contador
+// y retorna el rango [start, end]
+func ReserveSequenceBlock(ctx context.Context, name string, size int64) (int64, int64, error) {
+	if size <= 0 {
+		return 0, 0, fmt.Errorf("sequence block size must be positive")
+	}
+
+	ref := DocRefContext(ctx, sequencesCollection, name)
+	var start, end int64
+	err := RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var current int64
+		snap, err := tx.Get(ref)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if snap != nil && snap.Exists() {
+			current, _ = snap.Data()["value"].(int64)
+		}
+
+		start, end = current+1, current+size
+		return tx.Set(ref, map[string]interface{}{
+			"value":      end,
+			"updated_at": firebase.Now(),
+		})
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to advance sequence '%s': %w", name, err)
+	}
+	return start, end, nil
+}
+
+// sequenceBlock bloque reservado de un contador; su mutex serializa solo las llamadas
+// a ese contador mientras se reserva un bloque nuevo
+type sequenceBlock struct {
+	mu   sync.Mutex
+	next int64
+	end  int64
+}
+
+var (
+	sequenceBlocksMu sync.Mutex
+	sequenceBlocks   = map[string]*sequenceBlock{}
+)
+
+// NextSequenceFromBlock retorna el siguiente valor usando bloques de blockSize valores
+// reservados en memoria, reduciendo las transacciones a una cada blockSize llamadas.
+// Los valores son únicos, pero entre procesos distintos no son estrictamente
+// crecientes y los valores no usados de un bloque se pierden al reiniciar.
+func NextSequenceFromBlock(ctx context.Context, name string, blockSize int64) (int64, error) {
+	sequenceBlocksMu.Lock()
+	block := sequenceBlocks[name]
+	if block == nil {
+		block = &sequenceBlock{next: 1, end: 0}
+		sequenceBlocks[name] = block
+	}
+	sequenceBlocksMu.Unlock()
+
+	block.mu.Lock()
+	defer block.mu.Unlock()
+	if block.next > block.end {
+		start, end, err := ReserveSequenceBlock(ctx, name, blockSize)
+		if err != nil {
+			return 0, err
+		}
+		block.next, block.end = start, end
+	}
+
+	value := block.next
+	block.next++
+	return value, nil
+}
@@ -0,0 +1,90 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// RunTransaction ejecuta fn dentro de una transacción de Firestore (reintenta en caso de
+// contención). En modo solo lectura global las transacciones se rechazan; el bloqueo por
+// colección no aplica porque no se sabe qué documentos escribirá fn. La transacción se
+// ejecuta en la región del contexto (firebase.WithRegion o el resolver).
+func RunTransaction(ctx context.Context, fn func(ctx context.Context, tx *firestore.Transaction) error) (err error) {
+	client := firebase.FirestoreClientFor(ctx, "")
+
+	if err := firebase.CheckWritable(""); err != nil {
+		return err
+	}
+	call := newCall(firebase.CallFirestoreTransaction, "", "", nil)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
+
+	if err := client.RunTransaction(ctx, fn); err != nil {
+		return fmt.Errorf("transaction failed: %w", err)
+	}
+	return nil
+}
+
+// DocRef retorna la referencia a un documento de la colección indicada. Sin contexto solo
+// aplica la ruta de la colección; con rutas por tenant usar DocRefContext.
+//
+// Deprecated: usar DocRefContext, que elige la misma región que RunTransaction.
+func DocRef(collection, docID string) *firestore.DocumentRef {
+	return DocRefContext(context.Background(), collection, docID)
+}
+
+// DocRefContext es como DocRef pero elige la región con el contexto
+func DocRefContext(ctx context.Context, collection, docID string) *firestore.DocumentRef {
+	return firebase.FirestoreClientFor(ctx, collection).Collection(collection).Doc(docID)
+}
+
+// CheckedDocRef convierte una ruta "colección/doc[/subcolección/doc...]" en la referencia
+// del documento para usarla dentro de RunTransaction, aplicando antes las mismas
+// comprobaciones que las escrituras del paquete: solo lectura (CheckWritable),
+// interceptores (que pueden reescribir la colección, p. ej. por tenant) y políticas de op.
+// Con OperationCreate las políticas se evalúan sobre data; con las demás, sobre el
+// documento almacenado.
+func CheckedDocRef(ctx context.Context, path string, op firebase.Operation, data map[string]interface{}) (_ *firestore.DocumentRef, err error) {
+	trimmed := strings.Trim(path, "/")
+	segments := strings.Split(trimmed, "/")
+	if trimmed == "" || len(segments)%2 != 0 {
+		return nil, fmt.Errorf("invalid document path '%s'", path)
+	}
+	collection := strings.Join(segments[:len(segments)-1], "/")
+	docID := segments[len(segments)-1]
+
+	if op != firebase.OperationRead {
+		if err := firebase.CheckWritable(collection); err != nil {
+			return nil, err
+		}
+	}
+	operations := map[firebase.Operation]string{
+		firebase.OperationRead:   firebase.CallFirestoreGet,
+		firebase.OperationCreate: firebase.CallFirestoreCreate,
+		firebase.OperationUpdate: firebase.CallFirestoreUpdate,
+		firebase.OperationDelete: firebase.CallFirestoreDelete,
+	}
+	call := newCall(operations[op], collection, docID, data)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return nil, err
+	}
+	collection = call.Collection
+
+	if op == firebase.OperationCreate {
+		err = authorize(ctx, collection, &firebase.Document{ID: docID, Data: data}, op)
+	} else {
+		err = authorizeStored(ctx, collection, docID, op)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DocRefContext(ctx, collection, docID), nil
+}
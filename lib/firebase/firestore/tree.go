@@ -0,0 +1,285 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Campos usados por las jerarquías de ruta materializada. La ruta de un nodo tiene la
+// forma "/raiz/hijo/nieto/" e incluye su propio ID.
+const (
+	TreePathField   = "path"
+	TreeParentField = "parent_id"
+	TreeDepthField  = "depth"
+)
+
+const (
+	treeMovesCollection = "tree_moves"
+	treeMovePageSize    = 500
+)
+
+// CreateChild crea un nodo bajo parentID (o un nodo raíz si parentID es vacío)
+func CreateChild(ctx context.Context, collection, parentID string, data map[string]interface{}) (string, error) {
+	parentPath := "/"
+	depth := 0
+	if parentID != "" {
+		parent, err := GetDocument(ctx, collection, parentID)
+		if err != nil {
+			return "", err
+		}
+		parentPath, _ = parent.Data[TreePathField].(string)
+		if parentPath == "" {
+			return "", fmt.Errorf("document '%s' in collection '%s' is not a tree node", parentID, collection)
+		}
+		depth = treeDepth(parentPath)
+	}
+
+	docID, err := GenerateID(ctx, collection, data)
+	if err != nil {
+		return "", err
+	}
+
+	data[TreePathField] = parentPath + docID + "/"
+	data[TreeParentField] = parentID
+	data[TreeDepthField] = depth
+	if err := CreateDocumentWithID(ctx, collection, docID, data); err != nil {
+		return "", err
+	}
+	return docID, nil
+}
+
+// QueryChildren obtiene los hijos directos de un nodo
+func QueryChildren(ctx context.Context, collection, parentID string, options firebase.QueryOptions) ([]*firebase.Document, error) {
+	options.Filters = append(append([]firebase.QueryFilter(nil), options.Filters...),
+		firebase.QueryFilter{Field: TreeParentField, Operator: firebase.OpEqual, Value: parentID})
+	return QueryDocuments(ctx, collection, options)
+}
+
+// QueryDescendants obtiene todos los descendientes de un nodo (sin incluirlo) usando el
+// prefijo de su ruta
+func QueryDescendants(ctx context.Context, collection, nodeID string, options firebase.QueryOptions) ([]*firebase.Document, error) {
+	nodePath, err := treePath(ctx, collection, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	options.Filters = append(append([]firebase.QueryFilter(nil), options.Filters...),
+		firebase.QueryFilter{Field: TreePathField, Operator: firebase.OpGreaterThan, Value: nodePath},
+		firebase.QueryFilter{Field: TreePathField, Operator: firebase.OpLessThan, Value: nodePath + "\uf8ff"},
+	)
+	return QueryDocuments(ctx, collection, options)
+}
+
+// QueryAncestors obtiene los ancestros de un nodo ordenados desde la raíz
+func QueryAncestors(ctx context.Context, collection, nodeID string) ([]*firebase.Document, error) {
+	nodePath, err := treePath(ctx, collection, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := strings.Split(strings.Trim(nodePath, "/"), "/")
+	ids = ids[:len(ids)-1] // excluir el propio nodo
+
+	ancestors := make([]*firebase.Document, 0, len(ids))
+	for _, id := range ids {
+		doc, err := GetDocument(ctx, collection, id)
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, doc)
+	}
+	return ancestors, nil
+}
+
+// MoveSubtree mueve un nodo y todos sus descendientes bajo newParentID ("" para
+// convertirlo en raíz). Las rutas se reescriben página a página con BatchWrite (cada
+// nodo pasa por read-only, interceptores y políticas) y el trabajo se registra en
+// tree_moves para que ResumeTreeMoves lo complete si se interrumpe. El trabajo guarda la
+// colección y los filtros que resolvieron los interceptores (p. ej. el tenant), de modo
+// que al reanudarlo se recorre el mismo subárbol.
+func MoveSubtree(ctx context.Context, collection, nodeID, newParentID string) (err error) {
+	oldPath, err := treePath(ctx, collection, nodeID)
+	if err != nil {
+		return err
+	}
+
+	newParentPath := "/"
+	if newParentID != "" {
+		newParentPath, err = treePath(ctx, collection, newParentID)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(newParentPath, oldPath) {
+			return fmt.Errorf("cannot move node '%s' under its own descendant '%s'", nodeID, newParentID)
+		}
+	}
+	newPath := newParentPath + nodeID + "/"
+	if newPath == oldPath {
+		return nil
+	}
+
+	// El recorrido de descendientes pasa por los interceptores como una consulta
+	var scope []firebase.QueryFilter
+	call := newCall(firebase.CallFirestoreQuery, collection, "", &scope)
+	defer finishCall(ctx, call, &err)
+	if err := firebase.InterceptCall(ctx, call); err != nil {
+		return err
+	}
+	collection = call.Collection
+
+	if err := authorizeStored(ctx, collection, nodeID, firebase.OperationUpdate); err != nil {
+		return err
+	}
+
+	jobRef := firebase.GetFirestoreClient().Collection(treeMovesCollection).Doc(CompositeID(collection, nodeID))
+	_, err = jobRef.Set(ctx, map[string]interface{}{
+		"collection":    collection,
+		"filters":       encodeTreeScope(scope),
+		"node_id":       nodeID,
+		"new_parent_id": newParentID,
+		"old_path":      oldPath,
+		"new_path":      newPath,
+		"moved":         0,
+		"status":        "running",
+		"updated_at":    firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tree move job: %w", err)
+	}
+
+	return runTreeMove(ctx, jobRef, collection, scope, nodeID, newParentID, oldPath, newPath)
+}
+
+// ResumeTreeMoves reanuda los movimientos de subárboles que no terminaron. Cada trabajo
+// recorre la colección y los filtros guardados al iniciarlo; las escrituras siguen
+// pasando por los interceptores, por lo que con tenancy por campo el contexto debe
+// permitirlas (p. ej. tenancy.WithCrossTenantAccess).
+func ResumeTreeMoves(ctx context.Context) error {
+	iter := firebase.GetFirestoreClient().Collection(treeMovesCollection).Where("status", "==", "running").Documents(ctx)
+	defer iter.Stop()
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list tree move jobs: %w", err)
+		}
+
+		data := snap.Data()
+		collection, _ := data["collection"].(string)
+		scope := decodeTreeScope(data["filters"])
+		nodeID, _ := data["node_id"].(string)
+		newParentID, _ := data["new_parent_id"].(string)
+		oldPath, _ := data["old_path"].(string)
+		newPath, _ := data["new_path"].(string)
+		if err := runTreeMove(ctx, snap.Ref, collection, scope, nodeID, newParentID, oldPath, newPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runTreeMove reescribe las rutas de los nodos de collection (ya resuelta por los
+// interceptores, con sus filtros scope) que aún tienen el prefijo oldPath. Cada página
+// actualizada deja de coincidir con la consulta, así que reanudar continúa con los nodos
+// pendientes sin necesidad de cursor.
+func runTreeMove(ctx context.Context, jobRef *firestore.DocumentRef, collection string, scope []firebase.QueryFilter, nodeID, newParentID, oldPath, newPath string) error {
+	nodes, err := applyFilters(firebase.FirestoreClientFor(ctx, firebase.LogicalCollection(collection)).Collection(collection).Query, scope)
+	if err != nil {
+		return err
+	}
+
+	for {
+		docs, err := nodes.
+			Where(TreePathField, ">=", oldPath).
+			Where(TreePathField, "<", oldPath+"\uf8ff").
+			Limit(treeMovePageSize).
+			Documents(ctx).GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to load subtree of '%s': %w", nodeID, err)
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		operations := make([]firebase.BatchOperation, len(docs))
+		for i, doc := range docs {
+			path, _ := doc.Data()[TreePathField].(string)
+			path = newPath + strings.TrimPrefix(path, oldPath)
+			data := map[string]interface{}{
+				TreePathField:  path,
+				TreeDepthField: treeDepth(path) - 1,
+			}
+			if doc.Ref.ID == nodeID {
+				data[TreeParentField] = newParentID
+			}
+			operations[i] = firebase.BatchOperation{Type: "update", Collection: collection, DocumentID: doc.Ref.ID, Data: data}
+		}
+		if err := BatchWrite(ctx, operations); err != nil {
+			return fmt.Errorf("failed to move subtree of '%s': %w", nodeID, err)
+		}
+		if _, err := jobRef.Update(ctx, []firestore.Update{
+			{Path: "moved", Value: firestore.Increment(len(docs))},
+			{Path: "updated_at", Value: firebase.Now()},
+		}); err != nil {
+			return fmt.Errorf("failed to save tree move progress: %w", err)
+		}
+	}
+
+	if _, err := jobRef.Update(ctx, []firestore.Update{
+		{Path: "status", Value: "done"},
+		{Path: "updated_at", Value: firebase.Now()},
+	}); err != nil {
+		return fmt.Errorf("failed to complete tree move job: %w", err)
+	}
+	return nil
+}
+
+// encodeTreeScope guarda los filtros de alcance de un trabajo de tree_moves
+func encodeTreeScope(filters []firebase.QueryFilter) []interface{} {
+	encoded := make([]interface{}, len(filters))
+	for i, filter := range filters {
+		encoded[i] = map[string]interface{}{"field": filter.Field, "operator": string(filter.Operator), "value": filter.Value}
+	}
+	return encoded
+}
+
+func decodeTreeScope(value interface{}) []firebase.QueryFilter {
+	items, _ := value.([]interface{})
+	filters := make([]firebase.QueryFilter, 0, len(items))
+	for _, item := range items {
+		data, _ := item.(map[string]interface{})
+		field, _ := data["field"].(string)
+		operator, _ := data["operator"].(string)
+		filters = append(filters, firebase.QueryFilter{Field: field, Operator: firebase.Operator(operator), Value: data["value"]})
+	}
+	return filters
+}
+
+func treePath(ctx context.Context, collection, nodeID string) (string, error) {
+	doc, err := GetDocument(ctx, collection, nodeID)
+	if err != nil {
+		return "", err
+	}
+	path, _ := doc.Data[TreePathField].(string)
+	if path == "" {
+		return "", fmt.Errorf("document '%s' in collection '%s' is not a tree node", nodeID, collection)
+	}
+	return path, nil
+}
+
+// treeDepth retorna la cantidad de segmentos de una ruta ("/a/b/" -> 2)
+func treeDepth(path string) int {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return 0
+	}
+	return strings.Count(trimmed, "/") + 1
+}
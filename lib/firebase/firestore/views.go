@@ -0,0 +1,194 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// ViewsCollection colección donde se guardan las definiciones de las vistas
+const ViewsCollection = "query_views"
+
+// ViewCacheTTL tiempo que se reutiliza una definición leída antes de volver a leerla
+var ViewCacheTTL = time.Minute
+
+// View consulta con nombre guardada en Firestore. En los valores de los filtros, un
+// string "$nombre" se reemplaza por params["nombre"] al ejecutarla.
+type View struct {
+	Name        string                 `json:"name"`
+	Collection  string                 `json:"collection"`
+	Filters     []firebase.QueryFilter `json:"filters,omitempty"`
+	Orders      []firebase.OrderClause `json:"orders,omitempty"`
+	Limit       int                    `json:"limit,omitempty"`
+	Description string                 `json:"description,omitempty"`
+}
+
+type cachedView struct {
+	view      *View
+	expiresAt time.Time
+}
+
+var (
+	viewsMu    sync.Mutex
+	viewsCache = map[string]cachedView{}
+)
+
+// SaveView guarda (o reemplaza) la definición de una vista
+func SaveView(ctx context.Context, view View) error {
+	if view.Name == "" || strings.Contains(view.Name, "/") || view.Collection == "" {
+		return fmt.Errorf("view requires a valid name and a collection")
+	}
+	for _, filter := range view.Filters {
+		if !filter.Operator.IsValid() {
+			return &firebase.InvalidOperatorError{Field: filter.Field, Operator: filter.Operator}
+		}
+	}
+
+	filters := encodeFilters(view.Filters)
+	orders := make([]interface{}, len(view.Orders))
+	for i, order := range view.Orders {
+		orders[i] = map[string]interface{}{"field": order.Field, "direction": order.Direction}
+	}
+
+	_, err := firebase.GetFirestoreClient().Collection(ViewsCollection).Doc(view.Name).Set(ctx, map[string]interface{}{
+		"collection":  view.Collection,
+		"filters":     filters,
+		"orders":      orders,
+		"limit":       view.Limit,
+		"description": view.Description,
+		"updated_at":  firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save view '%s': %w", view.Name, err)
+	}
+
+	viewsMu.Lock()
+	delete(viewsCache, view.Name)
+	viewsMu.Unlock()
+	return nil
+}
+
+// GetView obtiene la definición de una vista (con caché de ViewCacheTTL)
+func GetView(ctx context.Context, name string) (*View, error) {
+	viewsMu.Lock()
+	cached, ok := viewsCache[name]
+	viewsMu.Unlock()
+	hit := ok && firebase.Now().Before(cached.expiresAt)
+	firebase.RecordCacheLookup("views", hit)
+	if hit {
+		return cached.view, nil
+	}
+
+	snap, err := firebase.GetFirestoreClient().Collection(ViewsCollection).Doc(name).Get(ctx)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, &firebase.DocumentNotFoundError{Collection: ViewsCollection, DocumentID: name}
+		}
+		return nil, fmt.Errorf("failed to get view '%s': %w", name, err)
+	}
+	view, err := viewFromDocument(&firebase.Document{ID: snap.Ref.ID, Data: snap.Data()})
+	if err != nil {
+		return nil, err
+	}
+
+	viewsMu.Lock()
+	viewsCache[name] = cachedView{view: view, expiresAt: firebase.Now().Add(ViewCacheTTL)}
+	viewsMu.Unlock()
+	return view, nil
+}
+
+// RunView ejecuta la vista con los parámetros indicados a través de QueryDocuments, por lo
+// que aplican interceptores, tenancy y políticas de lectura
+func RunView(ctx context.Context, name string, params map[string]interface{}) ([]*firebase.Document, error) {
+	view, err := GetView(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	filters, err := bindParams(view.Filters, params)
+	if err != nil {
+		return nil, fmt.Errorf("view '%s': %w", name, err)
+	}
+	return QueryDocuments(ctx, view.Collection, firebase.QueryOptions{Filters: filters, Orders: view.Orders, Limit: view.Limit})
+}
+
+// bindParams reemplaza los valores "$nombre" de los filtros por params["nombre"]
+func bindParams(filters []firebase.QueryFilter, params map[string]interface{}) ([]firebase.QueryFilter, error) {
+	bound := make([]firebase.QueryFilter, 0, len(filters))
+	for _, filter := range filters {
+		if placeholder, ok := filter.Value.(string); ok && strings.HasPrefix(placeholder, "$") {
+			value, ok := params[strings.TrimPrefix(placeholder, "$")]
+			if !ok {
+				return nil, fmt.Errorf("missing parameter '%s'", strings.TrimPrefix(placeholder, "$"))
+			}
+			filter.Value = value
+		}
+		bound = append(bound, filter)
+	}
+	return bound, nil
+}
+
+func viewFromDocument(doc *firebase.Document) (*View, error) {
+	view := &View{Name: doc.ID}
+	view.Collection, _ = doc.GetString("collection")
+	view.Description, _ = doc.GetString("description")
+	if limit, ok := doc.GetInt("limit"); ok {
+		view.Limit = int(limit)
+	}
+	if view.Collection == "" {
+		return nil, fmt.Errorf("view '%s' has no collection", doc.ID)
+	}
+
+	filters, err := decodeFilters(doc)
+	if err != nil {
+		return nil, fmt.Errorf("view '%s': %w", doc.ID, err)
+	}
+	view.Filters = filters
+
+	orders, _ := doc.Get("orders")
+	items, _ := orders.([]interface{})
+	for _, item := range items {
+		entry := &firebase.Document{Data: asMap(item)}
+		field, _ := entry.GetString("field")
+		direction, _ := entry.GetString("direction")
+		view.Orders = append(view.Orders, firebase.OrderClause{Field: field, Direction: direction})
+	}
+	return view, nil
+}
+
+// encodeFilters convierte los filtros a la forma en que se guardan en un documento
+func encodeFilters(filters []firebase.QueryFilter) []interface{} {
+	encoded := make([]interface{}, len(filters))
+	for i, filter := range filters {
+		encoded[i] = map[string]interface{}{"field": filter.Field, "operator": string(filter.Operator), "value": filter.Value}
+	}
+	return encoded
+}
+
+// decodeFilters lee los filtros guardados con encodeFilters en el campo "filters"
+func decodeFilters(doc *firebase.Document) ([]firebase.QueryFilter, error) {
+	var filters []firebase.QueryFilter
+	value, _ := doc.Get("filters")
+	items, _ := value.([]interface{})
+	for _, item := range items {
+		entry := &firebase.Document{Data: asMap(item)}
+		field, _ := entry.GetString("field")
+		operator, _ := entry.GetString("operator")
+		value, _ := entry.Get("value")
+		filter := firebase.QueryFilter{Field: field, Operator: firebase.Operator(operator), Value: value}
+		if !filter.Operator.IsValid() {
+			return nil, &firebase.InvalidOperatorError{Field: field, Operator: filter.Operator}
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func asMap(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	return m
+}
@@ -0,0 +1,157 @@
+// Package graphql expone las colecciones registradas como un API GraphQL (opcional): por
+// cada colección genera el tipo, consultas por ID y listados con paginación por cursor, y
+// mutaciones de creación, actualización y eliminación. Todo pasa por el paquete firestore,
+// por lo que aplican interceptores, tenancy, guardas y políticas con la sesión de la
+// petición (ver SessionMiddleware).
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// FieldType tipo de un campo expuesto
+type FieldType string
+
+// Tipos de campo admitidos
+const (
+	TypeString  FieldType = "String"
+	TypeInt     FieldType = "Int"
+	TypeFloat   FieldType = "Float"
+	TypeBoolean FieldType = "Boolean"
+	TypeTime    FieldType = "DateTime"
+	TypeJSON    FieldType = "JSON" // mapas, listas o valores sin tipo fijo
+)
+
+// Field campo de una colección expuesta
+type Field struct {
+	Name     string
+	Type     FieldType
+	List     bool
+	Required bool // obligatorio al crear
+}
+
+// CollectionSchema colección expuesta por el gateway
+type CollectionSchema struct {
+	Collection string
+	TypeName   string // por defecto la colección en PascalCase ("blog_posts" -> "BlogPosts")
+	Fields     []Field
+	ReadOnly   bool // sin mutaciones
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = map[string]CollectionSchema{}
+)
+
+// RegisterCollection expone una colección en el gateway. Los cambios se reflejan en los
+// handlers creados después del registro.
+func RegisterCollection(schema CollectionSchema) error {
+	if schema.Collection == "" || len(schema.Fields) == 0 {
+		return fmt.Errorf("graphql collection requires a name and at least one field")
+	}
+	if schema.TypeName == "" {
+		schema.TypeName = pascalCase(schema.Collection)
+	}
+	for _, field := range schema.Fields {
+		if scalarFor(field.Type) == nil {
+			return fmt.Errorf("unsupported graphql field type '%s' for field '%s'", field.Type, field.Name)
+		}
+	}
+
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	schemas[schema.Collection] = schema
+	return nil
+}
+
+// JSON escalar para valores sin tipo fijo (mapas, listas, valores de filtros)
+var JSON = gql.NewScalar(gql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Valor JSON arbitrario",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: parseLiteral,
+})
+
+func parseLiteral(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.IntValue:
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.EnumValue:
+		return v.Value
+	case *ast.ListValue:
+		out := make([]interface{}, len(v.Values))
+		for i, item := range v.Values {
+			out[i] = parseLiteral(item)
+		}
+		return out
+	case *ast.ObjectValue:
+		out := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			out[field.Name.Value] = parseLiteral(field.Value)
+		}
+		return out
+	}
+	return nil
+}
+
+func scalarFor(t FieldType) gql.Output {
+	switch t {
+	case TypeString:
+		return gql.String
+	case TypeInt:
+		return gql.Int
+	case TypeFloat:
+		return gql.Float
+	case TypeBoolean:
+		return gql.Boolean
+	case TypeTime:
+		return gql.DateTime
+	case TypeJSON:
+		return JSON
+	}
+	return nil
+}
+
+// pascalCase convierte "blog_posts" en "BlogPosts"
+func pascalCase(name string) string {
+	var out strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '_' || r == '-' || r == '.' || r == '/' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// lowerFirst convierte "BlogPosts" en "blogPosts"
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,306 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	gql "github.com/graphql-go/graphql"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Options opciones del gateway
+type Options struct {
+	// RequireSession rechaza las peticiones sin sesión (401)
+	RequireSession bool
+	// DefaultPageSize y MaxPageSize del argumento first de los listados (20 y 100)
+	DefaultPageSize int
+	MaxPageSize     int
+}
+
+// Handler construye el esquema con las colecciones registradas y retorna el handler HTTP
+// (POST con {"query", "variables", "operationName"} o GET con ?query=), envuelto en
+// auth.SessionMiddleware
+func Handler(options Options) (http.Handler, error) {
+	schema, err := BuildSchema(options)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query         string                 `json:"query"`
+			Variables     map[string]interface{} `json:"variables"`
+			OperationName string                 `json:"operationName"`
+		}
+		switch r.Method {
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, "cuerpo JSON inválido", http.StatusBadRequest)
+				return
+			}
+		case http.MethodGet:
+			request.Query = r.URL.Query().Get("query")
+			request.OperationName = r.URL.Query().Get("operationName")
+		default:
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		result := gql.Do(gql.Params{
+			Schema:         schema,
+			RequestString:  request.Query,
+			VariableValues: request.Variables,
+			OperationName:  request.OperationName,
+			Context:        r.Context(),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+	return auth.SessionMiddleware(options.RequireSession)(handler), nil
+}
+
+// BuildSchema genera el esquema GraphQL de las colecciones registradas
+func BuildSchema(options Options) (gql.Schema, error) {
+	if options.DefaultPageSize <= 0 {
+		options.DefaultPageSize = 20
+	}
+	if options.MaxPageSize <= 0 {
+		options.MaxPageSize = 100
+	}
+
+	schemasMu.RLock()
+	registered := make([]CollectionSchema, 0, len(schemas))
+	for _, schema := range schemas {
+		registered = append(registered, schema)
+	}
+	schemasMu.RUnlock()
+	if len(registered) == 0 {
+		return gql.Schema{}, fmt.Errorf("no collections registered for graphql")
+	}
+	sort.Slice(registered, func(i, j int) bool { return registered[i].TypeName < registered[j].TypeName })
+
+	pageInfo := gql.NewObject(gql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: gql.Fields{
+			"hasNextPage": &gql.Field{Type: gql.NewNonNull(gql.Boolean)},
+			"endCursor":   &gql.Field{Type: gql.String},
+		},
+	})
+	filterInput := gql.NewInputObject(gql.InputObjectConfig{
+		Name: "Filter",
+		Fields: gql.InputObjectConfigFieldMap{
+			"field": &gql.InputObjectFieldConfig{Type: gql.NewNonNull(gql.String)},
+			"op":    &gql.InputObjectFieldConfig{Type: gql.NewNonNull(gql.String)},
+			"value": &gql.InputObjectFieldConfig{Type: JSON},
+		},
+	})
+
+	queries := gql.Fields{}
+	mutations := gql.Fields{}
+	for _, schema := range registered {
+		object := objectType(schema)
+		connection := gql.NewObject(gql.ObjectConfig{
+			Name: schema.TypeName + "Connection",
+			Fields: gql.Fields{
+				"nodes":    &gql.Field{Type: gql.NewList(object)},
+				"pageInfo": &gql.Field{Type: gql.NewNonNull(pageInfo)},
+			},
+		})
+		r := &resolver{schema: schema, options: options}
+		name := lowerFirst(schema.TypeName)
+
+		queries[name] = &gql.Field{
+			Type:    object,
+			Args:    gql.FieldConfigArgument{"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)}},
+			Resolve: r.get,
+		}
+		queries[name+"List"] = &gql.Field{
+			Type: connection,
+			Args: gql.FieldConfigArgument{
+				"first":    &gql.ArgumentConfig{Type: gql.Int},
+				"after":    &gql.ArgumentConfig{Type: gql.String},
+				"where":    &gql.ArgumentConfig{Type: gql.NewList(filterInput)},
+				"orderBy":  &gql.ArgumentConfig{Type: gql.String},
+				"orderDir": &gql.ArgumentConfig{Type: gql.String},
+			},
+			Resolve: r.list,
+		}
+
+		if schema.ReadOnly {
+			continue
+		}
+		input := inputType(schema)
+		mutations["create"+schema.TypeName] = &gql.Field{
+			Type: object,
+			Args: gql.FieldConfigArgument{
+				"id":    &gql.ArgumentConfig{Type: gql.ID},
+				"input": &gql.ArgumentConfig{Type: gql.NewNonNull(input)},
+			},
+			Resolve: r.create,
+		}
+		mutations["update"+schema.TypeName] = &gql.Field{
+			Type: object,
+			Args: gql.FieldConfigArgument{
+				"id":    &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)},
+				"input": &gql.ArgumentConfig{Type: gql.NewNonNull(input)},
+			},
+			Resolve: r.update,
+		}
+		mutations["delete"+schema.TypeName] = &gql.Field{
+			Type:    gql.Boolean,
+			Args:    gql.FieldConfigArgument{"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)}},
+			Resolve: r.delete,
+		}
+	}
+
+	config := gql.SchemaConfig{Query: gql.NewObject(gql.ObjectConfig{Name: "Query", Fields: queries})}
+	if len(mutations) > 0 {
+		config.Mutation = gql.NewObject(gql.ObjectConfig{Name: "Mutation", Fields: mutations})
+	}
+	schema, err := gql.NewSchema(config)
+	if err != nil {
+		return gql.Schema{}, fmt.Errorf("failed to build graphql schema: %w", err)
+	}
+	return schema, nil
+}
+
+func objectType(schema CollectionSchema) *gql.Object {
+	fields := gql.Fields{
+		"id":         &gql.Field{Type: gql.NewNonNull(gql.ID)},
+		"created_at": &gql.Field{Type: gql.DateTime},
+		"updated_at": &gql.Field{Type: gql.DateTime},
+	}
+	for _, field := range schema.Fields {
+		var t gql.Output = scalarFor(field.Type)
+		if field.List {
+			t = gql.NewList(t)
+		}
+		fields[field.Name] = &gql.Field{Type: t}
+	}
+	return gql.NewObject(gql.ObjectConfig{Name: schema.TypeName, Fields: fields})
+}
+
+func inputType(schema CollectionSchema) *gql.InputObject {
+	fields := gql.InputObjectConfigFieldMap{}
+	for _, field := range schema.Fields {
+		var t gql.Input = scalarFor(field.Type).(gql.Input)
+		if field.List {
+			t = gql.NewList(t)
+		}
+		fields[field.Name] = &gql.InputObjectFieldConfig{Type: t}
+	}
+	return gql.NewInputObject(gql.InputObjectConfig{Name: schema.TypeName + "Input", Fields: fields})
+}
+
+// resolver resuelve las operaciones de una colección con el paquete firestore
+type resolver struct {
+	schema  CollectionSchema
+	options Options
+}
+
+func (r *resolver) get(p gql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	doc, err := firestore.GetDocument(p.Context, r.schema.Collection, id)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return documentValue(doc), nil
+}
+
+func (r *resolver) list(p gql.ResolveParams) (interface{}, error) {
+	first := r.options.DefaultPageSize
+	if n, ok := p.Args["first"].(int); ok && n > 0 {
+		first = n
+	}
+	if first > r.options.MaxPageSize {
+		first = r.options.MaxPageSize
+	}
+
+	options := firebase.QueryOptions{Limit: first}
+	options.Cursor, _ = p.Args["after"].(string)
+	options.OrderBy, _ = p.Args["orderBy"].(string)
+	options.OrderDir, _ = p.Args["orderDir"].(string)
+	where, _ := p.Args["where"].([]interface{})
+	for _, item := range where {
+		filter, _ := item.(map[string]interface{})
+		field, _ := filter["field"].(string)
+		op, _ := filter["op"].(string)
+		options.Filters = append(options.Filters, firebase.Where(field, firebase.Operator(op), filter["value"]))
+	}
+
+	result, err := firestore.QueryDocumentsWithMeta(p.Context, r.schema.Collection, options)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]interface{}, len(result.Documents))
+	for i, doc := range result.Documents {
+		nodes[i] = documentValue(doc)
+	}
+	info := map[string]interface{}{"hasNextPage": result.Truncated}
+	if result.NextCursor != "" {
+		info["endCursor"] = result.NextCursor
+	}
+	return map[string]interface{}{"nodes": nodes, "pageInfo": info}, nil
+}
+
+func (r *resolver) create(p gql.ResolveParams) (interface{}, error) {
+	input, _ := p.Args["input"].(map[string]interface{})
+	for _, field := range r.schema.Fields {
+		if field.Required && input[field.Name] == nil {
+			return nil, fmt.Errorf("field '%s' is required", field.Name)
+		}
+	}
+
+	id, _ := p.Args["id"].(string)
+	if id != "" {
+		// Un ID existente falla: create solo se autoriza como creación y no debe sobrescribir
+		if err := firestore.CreateDocumentIfAbsent(p.Context, r.schema.Collection, id, input); err != nil {
+			if firestore.IsAlreadyExists(err) {
+				return nil, fmt.Errorf("document '%s' already exists", id)
+			}
+			return nil, err
+		}
+	} else {
+		created, err := firestore.CreateDocument(p.Context, r.schema.Collection, input)
+		if err != nil {
+			return nil, err
+		}
+		id = created
+	}
+	return r.get(gql.ResolveParams{Context: p.Context, Args: map[string]interface{}{"id": id}})
+}
+
+func (r *resolver) update(p gql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	input, _ := p.Args["input"].(map[string]interface{})
+	if err := firestore.UpdateDocument(p.Context, r.schema.Collection, id, input); err != nil {
+		return nil, err
+	}
+	return r.get(p)
+}
+
+func (r *resolver) delete(p gql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	if err := firestore.DeleteDocument(p.Context, r.schema.Collection, id); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+// documentValue convierte el documento en el mapa que resuelven los campos del tipo
+func documentValue(doc *firebase.Document) map[string]interface{} {
+	value := make(map[string]interface{}, len(doc.Data)+1)
+	for k, v := range doc.Data {
+		value[k] = v
+	}
+	value["id"] = doc.ID
+	return value
+}
@@ -0,0 +1,37 @@
+package firebase
+
+import "sync"
+
+var (
+	immutableMu          sync.RWMutex
+	immutableCollections = map[string]bool{}
+)
+
+// SetImmutable marca una colección como de solo inserción (logs de auditoría, libros
+// contables): el paquete firestore rechaza las actualizaciones, sobrescrituras y
+// borrados con *ImmutableCollectionError y solo admite creaciones. Las subcolecciones de
+// sus documentos también quedan protegidas.
+func SetImmutable(collection string, immutable bool) {
+	immutableMu.Lock()
+	defer immutableMu.Unlock()
+	if immutable {
+		immutableCollections[collection] = true
+	} else {
+		delete(immutableCollections, collection)
+	}
+}
+
+// IsImmutable indica si collection es de solo inserción
+func IsImmutable(collection string) bool {
+	immutableMu.RLock()
+	defer immutableMu.RUnlock()
+	return immutableCollections[LogicalCollection(collection)] || immutableCollections[rootCollection(collection)]
+}
+
+// CheckMutable retorna un *ImmutableCollectionError si collection es de solo inserción
+func CheckMutable(collection, operation string) error {
+	if !IsImmutable(collection) {
+		return nil
+	}
+	return &ImmutableCollectionError{Collection: collection, Operation: operation}
+}
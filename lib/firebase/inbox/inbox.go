@@ -0,0 +1,168 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Page página de notificaciones con el total de no leídas
+type Page struct {
+	Notifications []*firebase.Document `json:"notifications"`
+	Unread        int                  `json:"unread"`
+	NextCursor    string               `json:"next_cursor,omitempty"`
+	HasMore       bool                 `json:"has_more"`
+}
+
+// Collection retorna la subcolección de notificaciones del usuario (users/{uid}/notifications)
+func Collection(uid string) string {
+	return "users/" + uid + "/notifications"
+}
+
+// PublishNotification agrega una notificación no leída al inbox del usuario
+func PublishNotification(ctx context.Context, uid string, payload map[string]interface{}) (string, error) {
+	data := make(map[string]interface{}, len(payload)+1)
+	for key, value := range payload {
+		data[key] = value
+	}
+	data["read"] = false
+
+	id, err := firestore.CreateDocument(ctx, Collection(uid), data)
+	if err != nil {
+		return "", fmt.Errorf("failed to publish notification for user '%s': %w", uid, err)
+	}
+	return id, nil
+}
+
+// ListNotifications lista las notificaciones del usuario, más recientes primero. Con
+// unreadOnly solo retorna las no leídas. El cursor es el NextCursor de la página anterior.
+func ListNotifications(ctx context.Context, uid string, pageSize int, cursor string, unreadOnly bool) (*Page, error) {
+	options := firebase.QueryOptions{
+		OrderBy:  "created_at",
+		OrderDir: "desc",
+		Limit:    pageSize,
+		Cursor:   cursor,
+	}
+	if unreadOnly {
+		options.Filters = []firebase.QueryFilter{firebase.Where("read", firebase.OpEqual, false)}
+	}
+
+	result, err := firestore.QueryDocumentsWithMeta(ctx, Collection(uid), options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications for user '%s': %w", uid, err)
+	}
+
+	unread, err := UnreadCount(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page{
+		Notifications: result.Documents,
+		Unread:        unread,
+		NextCursor:    result.NextCursor,
+		HasMore:       result.Truncated,
+	}, nil
+}
+
+// UnreadCount retorna el número de notificaciones no leídas del usuario
+func UnreadCount(ctx context.Context, uid string) (int, error) {
+	count, err := firestore.CountDocuments(ctx, Collection(uid), []firebase.QueryFilter{
+		firebase.Where("read", firebase.OpEqual, false),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications for user '%s': %w", uid, err)
+	}
+	return count, nil
+}
+
+// MarkRead marca una notificación como leída
+func MarkRead(ctx context.Context, uid, notificationID string) error {
+	err := firestore.UpdateDocument(ctx, Collection(uid), notificationID, map[string]interface{}{
+		"read":    true,
+		"read_at": firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark notification '%s' as read: %w", notificationID, err)
+	}
+	return nil
+}
+
+// MarkAllRead marca como leídas todas las notificaciones del usuario y retorna cuántas cambiaron
+func MarkAllRead(ctx context.Context, uid string) (int, error) {
+	unread, err := firestore.QueryDocuments(ctx, Collection(uid), firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{firebase.Where("read", firebase.OpEqual, false)},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list unread notifications for user '%s': %w", uid, err)
+	}
+
+	now := firebase.Now()
+	operations := make([]firebase.BatchOperation, 0, len(unread))
+	for _, doc := range unread {
+		operations = append(operations, firebase.BatchOperation{
+			Type:       "update",
+			Collection: Collection(uid),
+			DocumentID: doc.ID,
+			Data:       map[string]interface{}{"read": true, "read_at": now},
+		})
+	}
+
+	// BatchWrite divide los lotes grandes; si falla un fragmento se cuentan los confirmados
+	results, err := firestore.BatchWriteWithResults(ctx, operations, firebase.BatchOptions{})
+	marked := 0
+	for _, result := range results {
+		if result.Success {
+			marked++
+		}
+	}
+	if err != nil {
+		return marked, fmt.Errorf("failed to mark notifications as read for user '%s': %w", uid, err)
+	}
+	return marked, nil
+}
+
+// Watch escucha en tiempo real las últimas notificaciones del usuario e invoca fn con cada
+// cambio y el total de no leídas. Bloquea hasta que ctx se cancela o fn retorna error.
+func Watch(ctx context.Context, uid string, limit int, fn func(notifications []*firebase.Document, unread int) error) error {
+	client := firebase.GetFirestoreClient()
+	query := client.Collection(Collection(uid)).OrderBy("created_at", gfirestore.Desc)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	snapshots := query.Snapshots(ctx)
+	defer snapshots.Stop()
+
+	for {
+		snap, err := snapshots.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to watch notifications for user '%s': %w", uid, err)
+		}
+
+		docs, err := snap.Documents.GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to read notifications snapshot: %w", err)
+		}
+
+		notifications := make([]*firebase.Document, 0, len(docs))
+		for _, doc := range docs {
+			notifications = append(notifications, &firebase.Document{ID: doc.Ref.ID, Data: doc.Data()})
+		}
+
+		unread, err := UnreadCount(ctx, uid)
+		if err != nil {
+			return err
+		}
+		if err := fn(notifications, unread); err != nil {
+			return err
+		}
+	}
+}
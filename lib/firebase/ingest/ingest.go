@@ -0,0 +1,291 @@
+// Package ingest carga datos externos en Firestore: lee registros de una Source (CSV,
+// JSON/JSONL, una API HTTP paginada o cualquier implementación propia, p. ej. un lector
+// de Parquet), los pasa por las transformaciones del usuario y los escribe por lotes con
+// firestore.BatchWriteWithResults (interceptores, plantillas y validación incluidos). Con
+// KeyFields el ID del documento se deriva de la clave natural (firestore.DeterministicID),
+// de modo que reanudar o repetir una carga sobrescribe en lugar de duplicar. Con Name, la
+// posición en la fuente se guarda después de cada lote para continuar tras un corte.
+//
+//	file, _ := os.Open("customers.csv")
+//	job := &ingest.Job{
+//		Name: "customers-2024", Source: ingest.CSVSource(file, ingest.CSVOptions{InferTypes: true}),
+//		Collection: "customers", KeyFields: []string{"external_id"}, RatePerSecond: 500,
+//	}
+//	report, err := job.Run(ctx)
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// CheckpointsCollection colección donde se guarda la posición de cada carga
+const CheckpointsCollection = "ingest_checkpoints"
+
+// Record registro leído de la fuente
+type Record = map[string]interface{}
+
+// Source fuente de registros. Next retorna io.EOF al terminar. Checkpoint describe la
+// posición después del último registro retornado y Resume, llamado antes del primer
+// Next, continúa desde ella.
+type Source interface {
+	Next(ctx context.Context) (Record, error)
+	Checkpoint() string
+	Resume(checkpoint string) error
+}
+
+// Transform transforma un registro; retornar nil lo descarta
+type Transform func(ctx context.Context, record Record) (Record, error)
+
+// Job carga de una fuente en una colección
+type Job struct {
+	// Name identifica el checkpoint; vacío no guarda ni reanuda
+	Name       string
+	Source     Source
+	Collection string
+	Transforms []Transform
+	// KeyFields campos de clave natural de los que se deriva el ID del documento; los
+	// registros repetidos en la carga se omiten (cuenta el primero)
+	KeyFields []string
+	// IDField toma el ID del documento de este campo (si no hay KeyFields); sin ninguno
+	// de los dos los IDs son aleatorios y una carga repetida duplica los documentos
+	IDField         string
+	BatchSize       int  // registros por lote (por defecto 500)
+	RatePerSecond   int  // máximo de registros escritos por segundo (0 = sin límite)
+	ContinueOnError bool // cuenta las escrituras fallidas y sigue; por defecto la carga se detiene
+	DryRun          bool // lee y transforma sin escribir
+	// Progress se llama después de cada lote
+	Progress func(report Report)
+}
+
+// Report resultado de una carga
+type Report struct {
+	Read       int      `json:"read"`
+	Written    int      `json:"written"`
+	Filtered   int      `json:"filtered"`   // descartados por una transformación
+	Duplicates int      `json:"duplicates"` // misma clave natural que un registro anterior
+	Failed     int      `json:"failed"`
+	Errors     []string `json:"errors,omitempty"` // primeros errores de escritura
+	Resumed    bool     `json:"resumed"`
+	Checkpoint string   `json:"checkpoint,omitempty"`
+}
+
+// maxReportedErrors errores de escritura que se guardan en Report.Errors
+const maxReportedErrors = 20
+
+// Run ejecuta la carga hasta agotar la fuente o que ctx se cancele. Si la carga se
+// interrumpe, volver a ejecutarla con el mismo Name continúa desde el último lote
+// confirmado. Las claves vistas en una ejecución anterior no se recuerdan: con KeyFields
+// los registros ya escritos se sobrescriben con el mismo contenido.
+func (j *Job) Run(ctx context.Context) (*Report, error) {
+	if j.Source == nil || j.Collection == "" {
+		return nil, fmt.Errorf("ingest job requires a source and a collection")
+	}
+	batchSize := j.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	report := &Report{}
+	if j.Name != "" {
+		checkpoint, err := LoadCheckpoint(ctx, j.Name)
+		if err != nil {
+			return nil, err
+		}
+		if checkpoint != nil {
+			if err := j.Source.Resume(checkpoint.Position); err != nil {
+				return nil, fmt.Errorf("failed to resume ingest '%s': %w", j.Name, err)
+			}
+			report.Resumed = true
+			report.Read, report.Written = checkpoint.Read, checkpoint.Written
+			report.Checkpoint = checkpoint.Position
+		}
+	}
+
+	seen := map[string]bool{}
+	started := time.Now()
+	startWritten := report.Written
+	var batch []firebase.BatchOperation
+	for {
+		record, err := j.Source.Next(ctx)
+		done := err == io.EOF
+		if err != nil && !done {
+			return report, fmt.Errorf("failed to read ingest source: %w", err)
+		}
+		if !done {
+			report.Read++
+			op, keep, err := j.prepare(ctx, record, seen, report)
+			if err != nil {
+				return report, err
+			}
+			if keep {
+				batch = append(batch, op)
+			}
+		}
+		if len(batch) < batchSize && !done {
+			continue
+		}
+
+		if err := j.flush(ctx, batch, report); err != nil {
+			return report, err
+		}
+		batch = batch[:0]
+		if j.Progress != nil {
+			j.Progress(*report)
+		}
+		if done {
+			return report, nil
+		}
+		if !j.pace(ctx, started, report.Written-startWritten) {
+			return report, ctx.Err()
+		}
+	}
+}
+
+// prepare aplica las transformaciones y resuelve el ID del registro
+func (j *Job) prepare(ctx context.Context, record Record, seen map[string]bool, report *Report) (firebase.BatchOperation, bool, error) {
+	for _, transform := range j.Transforms {
+		var err error
+		record, err = transform(ctx, record)
+		if err != nil {
+			return firebase.BatchOperation{}, false, fmt.Errorf("failed to transform record %d: %w", report.Read, err)
+		}
+		if record == nil {
+			report.Filtered++
+			return firebase.BatchOperation{}, false, nil
+		}
+	}
+
+	op := firebase.BatchOperation{Type: "create", Collection: j.Collection, Data: record}
+	switch {
+	case len(j.KeyFields) > 0:
+		id, err := firestore.DeterministicID(j.KeyFields, record)
+		if err != nil {
+			return op, false, fmt.Errorf("record %d: %w", report.Read, err)
+		}
+		op.DocumentID = id
+	case j.IDField != "":
+		id, ok := record[j.IDField]
+		if !ok || id == nil || fmt.Sprint(id) == "" {
+			return op, false, fmt.Errorf("record %d has no '%s'", report.Read, j.IDField)
+		}
+		op.DocumentID = fmt.Sprint(id)
+	}
+	if op.DocumentID != "" {
+		if seen[op.DocumentID] {
+			report.Duplicates++
+			return op, false, nil
+		}
+		seen[op.DocumentID] = true
+	}
+	return op, true, nil
+}
+
+// flush escribe el lote y guarda el checkpoint. Con fallos y sin ContinueOnError la carga
+// se detiene sin avanzar el checkpoint, de modo que el lote se reintenta al reanudar.
+func (j *Job) flush(ctx context.Context, batch []firebase.BatchOperation, report *Report) error {
+	if len(batch) > 0 && !j.DryRun {
+		results, err := firestore.BatchWriteWithResults(ctx, batch, firebase.BatchOptions{BestEffort: true})
+		var batchErr *firebase.BatchError
+		if err != nil && !errors.As(err, &batchErr) {
+			return fmt.Errorf("failed to write ingest batch: %w", err)
+		}
+		failed := 0
+		for _, result := range results {
+			if result.Success {
+				report.Written++
+				continue
+			}
+			failed++
+			if len(report.Errors) < maxReportedErrors && result.Error != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", batch[result.Index].DocumentID, result.Error))
+			}
+		}
+		report.Failed += failed
+		if failed > 0 && !j.ContinueOnError {
+			return fmt.Errorf("failed to write %d records of ingest batch: %w", failed, err)
+		}
+	} else if j.DryRun {
+		report.Written += len(batch)
+	}
+
+	report.Checkpoint = j.Source.Checkpoint()
+	if j.Name == "" || j.DryRun {
+		return nil
+	}
+	return saveCheckpoint(ctx, j.Name, j.Collection, report)
+}
+
+// pace espera lo necesario para no superar RatePerSecond
+func (j *Job) pace(ctx context.Context, started time.Time, written int) bool {
+	if j.RatePerSecond <= 0 {
+		return true
+	}
+	wait := time.Duration(float64(written)/float64(j.RatePerSecond)*float64(time.Second)) - time.Since(started)
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Checkpoint posición guardada de una carga
+type Checkpoint struct {
+	Position string    `json:"position"`
+	Read     int       `json:"read"`
+	Written  int       `json:"written"`
+	SavedAt  time.Time `json:"saved_at"`
+}
+
+// LoadCheckpoint obtiene el checkpoint de una carga (nil si no hay)
+func LoadCheckpoint(ctx context.Context, name string) (*Checkpoint, error) {
+	snap, err := firebase.GetFirestoreClient().Collection(CheckpointsCollection).Doc(name).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load ingest checkpoint '%s': %w", name, err)
+	}
+	doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+	checkpoint := &Checkpoint{}
+	checkpoint.Position, _ = doc.GetString("position")
+	read, _ := doc.GetInt("read")
+	written, _ := doc.GetInt("written")
+	checkpoint.Read, checkpoint.Written = int(read), int(written)
+	checkpoint.SavedAt, _ = doc.GetTime("saved_at")
+	return checkpoint, nil
+}
+
+// ResetCheckpoint borra el checkpoint: la próxima ejecución empieza desde el principio
+func ResetCheckpoint(ctx context.Context, name string) error {
+	if _, err := firebase.GetFirestoreClient().Collection(CheckpointsCollection).Doc(name).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to reset ingest checkpoint '%s': %w", name, err)
+	}
+	return nil
+}
+
+func saveCheckpoint(ctx context.Context, name, collection string, report *Report) error {
+	_, err := firebase.GetFirestoreClient().Collection(CheckpointsCollection).Doc(name).Set(ctx, map[string]interface{}{
+		"collection": collection,
+		"position":   report.Checkpoint,
+		"read":       report.Read,
+		"written":    report.Written,
+		"saved_at":   firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save ingest checkpoint '%s': %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// OpenFile abre un archivo según su extensión: .csv (con cabecera e inferencia de tipos),
+// .json (un array o objetos concatenados) y .jsonl/.ndjson. Otros formatos, como Parquet,
+// no están incluidos: implementar Source con el lector correspondiente.
+func OpenFile(path string) (Source, io.Closer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open ingest file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return CSVSource(file, CSVOptions{InferTypes: true}), file, nil
+	case ".json", ".jsonl", ".ndjson":
+		return JSONSource(file), file, nil
+	}
+	file.Close()
+	return nil, nil, fmt.Errorf("unsupported ingest file format '%s'", filepath.Ext(path))
+}
+
+// offsetSource base de las fuentes de archivo: el checkpoint es el número de registros
+// leídos y Resume los vuelve a leer y descarta
+type offsetSource struct {
+	read int
+	skip int
+}
+
+func (s *offsetSource) Checkpoint() string {
+	return strconv.Itoa(s.read)
+}
+
+func (s *offsetSource) Resume(checkpoint string) error {
+	n, err := strconv.Atoi(checkpoint)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid checkpoint '%s'", checkpoint)
+	}
+	s.skip = n
+	return nil
+}
+
+// CSVOptions opciones de CSVSource
+type CSVOptions struct {
+	Comma rune // por defecto ','
+	// Header nombres de las columnas; vacío toma la primera fila
+	Header []string
+	// InferTypes convierte los valores que parecen números o booleanos; sin ella todos
+	// los valores son textos. Las celdas vacías se omiten.
+	InferTypes bool
+}
+
+// CSVSource lee registros de un CSV, una columna por campo
+func CSVSource(r io.Reader, options CSVOptions) Source {
+	reader := csv.NewReader(r)
+	if options.Comma != 0 {
+		reader.Comma = options.Comma
+	}
+	reader.FieldsPerRecord = -1
+	return &csvSource{reader: reader, options: options, header: options.Header}
+}
+
+type csvSource struct {
+	offsetSource
+	reader  *csv.Reader
+	options CSVOptions
+	header  []string
+}
+
+func (s *csvSource) Next(ctx context.Context) (Record, error) {
+	if s.header == nil {
+		header, err := s.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		s.header = header
+	}
+	for {
+		row, err := s.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		s.read++
+		if s.skip > 0 {
+			s.skip--
+			continue
+		}
+		record := Record{}
+		for i, value := range row {
+			if i >= len(s.header) || value == "" {
+				continue
+			}
+			if s.options.InferTypes {
+				record[s.header[i]] = inferValue(value)
+			} else {
+				record[s.header[i]] = value
+			}
+		}
+		return record, nil
+	}
+}
+
+// inferValue convierte números y booleanos. Los valores con ceros a la izquierda
+// (códigos postales, identificadores) se conservan como texto.
+func inferValue(value string) interface{} {
+	digits := strings.TrimPrefix(value, "-")
+	if digits == "" || (len(digits) > 1 && digits[0] == '0' && digits[1] != '.') {
+		return value
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if digits[0] >= '0' && digits[0] <= '9' {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	switch strings.ToLower(value) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return value
+}
+
+// JSONSource lee un array JSON de objetos o una secuencia de objetos (JSONL). Los números
+// enteros se leen como int64.
+func JSONSource(r io.Reader) Source {
+	return &jsonSource{reader: bufio.NewReader(r)}
+}
+
+type jsonSource struct {
+	offsetSource
+	reader  *bufio.Reader
+	decoder *json.Decoder
+}
+
+func (s *jsonSource) Next(ctx context.Context) (Record, error) {
+	if s.decoder == nil {
+		if err := s.start(); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		if !s.decoder.More() {
+			return nil, io.EOF
+		}
+		var value map[string]interface{}
+		if err := s.decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid JSON record %d: %w", s.read+1, err)
+		}
+		s.read++
+		if s.skip > 0 {
+			s.skip--
+			continue
+		}
+		return normalizeNumbers(value).(map[string]interface{}), nil
+	}
+}
+
+// start crea el decoder; si la entrada es un array lo recorre elemento por elemento sin
+// cargarlo entero
+func (s *jsonSource) start() error {
+	for {
+		b, err := s.reader.Peek(1)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+		s.reader.ReadByte()
+	}
+	s.decoder = json.NewDecoder(s.reader)
+	s.decoder.UseNumber()
+	if b, err := s.reader.Peek(1); err == nil && b[0] == '[' {
+		if _, err := s.decoder.Token(); err != nil {
+			return fmt.Errorf("invalid JSON array: %w", err)
+		}
+	}
+	return nil
+}
+
+// normalizeNumbers convierte json.Number en int64 o float64
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+		return v
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// HTTPOptions opciones de HTTPSource
+type HTTPOptions struct {
+	URL    string
+	Header http.Header
+	Client *http.Client // por defecto http.DefaultClient
+	// RecordsPath ruta con puntos al array de registros en la respuesta ("data",
+	// "result.items"); vacío si la respuesta es el array
+	RecordsPath string
+	// NextPath ruta con puntos a la URL de la página siguiente o, con CursorParam, al
+	// cursor. Vacío usa la cabecera Link rel="next".
+	NextPath string
+	// CursorParam parámetro de consulta donde se envía el cursor de NextPath
+	CursorParam string
+}
+
+// HTTPSource lee registros de una API JSON paginada. El checkpoint guarda la URL de la
+// página en curso y los registros ya entregados de ella.
+func HTTPSource(options HTTPOptions) Source {
+	return &httpSource{options: options, page: options.URL}
+}
+
+type httpSource struct {
+	options HTTPOptions
+	page    string // URL de la página en curso
+	next    string // URL de la página siguiente ("" = última)
+	records []interface{}
+	index   int
+	loaded  bool
+	// resumeAt registros de la primera página ya entregados antes de reanudar
+	resumeAt int
+}
+
+type httpCheckpoint struct {
+	Page   string `json:"page"`
+	Offset int    `json:"offset"`
+}
+
+func (s *httpSource) Checkpoint() string {
+	content, _ := json.Marshal(httpCheckpoint{Page: s.page, Offset: s.index})
+	return string(content)
+}
+
+func (s *httpSource) Resume(checkpoint string) error {
+	var position httpCheckpoint
+	if err := json.Unmarshal([]byte(checkpoint), &position); err != nil || position.Page == "" {
+		return fmt.Errorf("invalid checkpoint '%s'", checkpoint)
+	}
+	s.page, s.resumeAt, s.loaded = position.Page, position.Offset, false
+	return nil
+}
+
+func (s *httpSource) Next(ctx context.Context) (Record, error) {
+	for {
+		if !s.loaded {
+			if err := s.load(ctx); err != nil {
+				return nil, err
+			}
+			s.index, s.resumeAt = s.resumeAt, 0
+		}
+		if s.index < len(s.records) {
+			record, ok := s.records[s.index].(map[string]interface{})
+			s.index++
+			if !ok {
+				return nil, fmt.Errorf("record %d of page '%s' is not an object", s.index, s.page)
+			}
+			return record, nil
+		}
+		if s.next == "" {
+			return nil, io.EOF
+		}
+		s.page, s.loaded = s.next, false
+	}
+}
+
+// load descarga la página s.page
+func (s *httpSource) load(ctx context.Context) error {
+	client := s.options.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.page, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build ingest request: %w", err)
+	}
+	for key, values := range s.options.Header {
+		req.Header[key] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch '%s': %w", s.page, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to fetch '%s': status %d", s.page, resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return fmt.Errorf("invalid JSON from '%s': %w", s.page, err)
+	}
+	body = normalizeNumbers(body)
+
+	records := body
+	if s.options.RecordsPath != "" {
+		object, _ := body.(map[string]interface{})
+		records, _ = (&firebase.Document{Data: object}).Get(s.options.RecordsPath)
+	}
+	list, ok := records.([]interface{})
+	if !ok && records != nil {
+		return fmt.Errorf("response of '%s' has no records array", s.page)
+	}
+	s.records, s.index, s.loaded = list, 0, true
+
+	s.next, err = s.nextPage(body, resp.Header.Get("Link"))
+	return err
+}
+
+var linkNext = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// nextPage URL de la página siguiente ("" si no hay)
+func (s *httpSource) nextPage(body interface{}, link string) (string, error) {
+	if s.options.NextPath == "" {
+		if match := linkNext.FindStringSubmatch(link); match != nil {
+			return s.resolve(match[1])
+		}
+		return "", nil
+	}
+	object, _ := body.(map[string]interface{})
+	value, _ := (&firebase.Document{Data: object}).Get(s.options.NextPath)
+	if value == nil || fmt.Sprint(value) == "" || len(s.records) == 0 {
+		return "", nil
+	}
+	if s.options.CursorParam == "" {
+		return s.resolve(fmt.Sprint(value))
+	}
+	next, err := url.Parse(s.options.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid ingest URL: %w", err)
+	}
+	query := next.Query()
+	query.Set(s.options.CursorParam, fmt.Sprint(value))
+	next.RawQuery = query.Encode()
+	return next.String(), nil
+}
+
+// resolve resuelve una URL relativa respecto de la página en curso
+func (s *httpSource) resolve(ref string) (string, error) {
+	base, err := url.Parse(s.page)
+	if err != nil {
+		return "", fmt.Errorf("invalid ingest URL: %w", err)
+	}
+	next, err := base.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page URL '%s': %w", ref, err)
+	}
+	return next.String(), nil
+}
@@ -0,0 +1,143 @@
+package firebase
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Nombres de las operaciones que pasan por la cadena de interceptores
+const (
+	CallFirestoreGet         = "firestore.get"
+	CallFirestoreQuery       = "firestore.query"
+	CallFirestoreCreate      = "firestore.create"
+	CallFirestoreUpdate      = "firestore.update"
+	CallFirestoreDelete      = "firestore.delete"
+	CallFirestoreBatch       = "firestore.batch"
+	CallFirestoreTransaction = "firestore.transaction"
+	CallAuthCreateUser       = "auth.create_user"
+	CallAuthGetUser          = "auth.get_user"
+	CallAuthUpdateUser       = "auth.update_user"
+	CallAuthDeleteUser       = "auth.delete_user"
+	CallAuthSetClaims        = "auth.set_claims"
+	CallAuthListUsers        = "auth.list_users"
+)
+
+// Call describe una llamada a Firestore o Auth que atraviesa la cadena de interceptores.
+// En las operaciones de un lote Index es su posición; fuera de lotes es -1. Payload es el
+// dato de la llamada: el mapa a escribir, []firestore.Update, *QueryOptions,
+// *[]QueryFilter, *BatchOperation, *CreateUserRequest, *UpdateUserRequest o los claims.
+// En las llamadas a Firestore un Before puede reescribir Collection (p. ej. para
+// particionar por tenant) y el wrapper usa la ruta resultante.
+type Call struct {
+	Operation  string
+	Collection string
+	DocumentID string
+	Index      int
+	Payload    interface{}
+	// Result resultado de las lecturas exitosas (disponible en After): *Document,
+	// []*Document, *QueryResult, []*AggregateGroup (Aggregate), bool (DocumentExists) o int
+	// (CountDocuments)
+	Result interface{}
+}
+
+// Interceptor hooks que se ejecutan alrededor de cada llamada a Firestore o Auth, para
+// métricas, validación o aislamiento por tenant sin modificar los wrappers. Before puede
+// modificar call.Payload o rechazar la llamada retornando un error. After recibe el error
+// final de la llamada (incluido el rechazo de un Before).
+type Interceptor struct {
+	Name   string
+	Before func(ctx context.Context, call *Call) error
+	After  func(ctx context.Context, call *Call, err error)
+}
+
+var (
+	interceptorsMu sync.RWMutex
+	interceptors   []Interceptor
+)
+
+// RegisterInterceptor agrega un interceptor al final de la cadena (si ya existe uno con el
+// mismo nombre, se quita y el nuevo pasa al final)
+func RegisterInterceptor(interceptor Interceptor) {
+	interceptorsMu.Lock()
+	defer interceptorsMu.Unlock()
+	// Copia al escribir: InterceptCall recorre la cadena sin mantener el lock
+	chain := make([]Interceptor, 0, len(interceptors)+1)
+	for _, existing := range interceptors {
+		if interceptor.Name == "" || existing.Name != interceptor.Name {
+			chain = append(chain, existing)
+		}
+	}
+	interceptors = append(chain, interceptor)
+}
+
+// UnregisterInterceptor quita el interceptor con el nombre indicado
+func UnregisterInterceptor(name string) {
+	interceptorsMu.Lock()
+	defer interceptorsMu.Unlock()
+	for i, existing := range interceptors {
+		if existing.Name == name {
+			interceptors = append(interceptors[:i:i], interceptors[i+1:]...)
+			return
+		}
+	}
+}
+
+// HasInterceptor indica si hay un interceptor registrado con el nombre indicado
+func HasInterceptor(name string) bool {
+	for _, existing := range currentInterceptors() {
+		if existing.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func currentInterceptors() []Interceptor {
+	interceptorsMu.RLock()
+	defer interceptorsMu.RUnlock()
+	return interceptors
+}
+
+// InterceptCall ejecuta los hooks Before en orden de registro. Lo usan los paquetes de la
+// librería; el primer error corta la cadena y la llamada no se realiza.
+func InterceptCall(ctx context.Context, call *Call) error {
+	for _, interceptor := range currentInterceptors() {
+		if interceptor.Before == nil {
+			continue
+		}
+		if err := interceptor.Before(ctx, call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FinishCall ejecuta los hooks After en orden inverso con el resultado de la llamada
+func FinishCall(ctx context.Context, call *Call, err error) {
+	chain := currentInterceptors()
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].After != nil {
+			chain[i].After(ctx, call, err)
+		}
+	}
+}
+
+// TenantsCollection colección raíz bajo la que tenancy (StrategyPrefix) reescribe las
+// rutas de las colecciones particionadas: tenants/{id}/{colección}
+const TenantsCollection = "tenants"
+
+// LogicalCollection nombre lógico de una ruta reescrita por un interceptor
+// ("tenants/{id}/orders" -> "orders"). Los registros por colección (políticas, rollups,
+// compresión, plantillas, webhooks...) se consultan con este nombre; la ruta reescrita
+// solo se usa para construir las referencias.
+func LogicalCollection(collection string) string {
+	rest, ok := strings.CutPrefix(collection, TenantsCollection+"/")
+	if !ok {
+		return collection
+	}
+	if _, logical, ok := strings.Cut(rest, "/"); ok && logical != "" {
+		return logical
+	}
+	return collection
+}
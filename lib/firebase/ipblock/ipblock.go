@@ -0,0 +1,329 @@
+// Package ipblock mantiene una lista de bloqueo de rangos IP contra ataques de fuerza
+// bruta: los logins fallidos se cuentan por rango (/24 en IPv4, /64 en IPv6) y, al
+// superar el umbral, el rango se bloquea durante BlockDuration. RequestOTP y LoginWithOTP
+// la consultan con la IP de firebase.ClientInfoFromContext; Middleware la aplica a
+// cualquier handler. Los bloqueos manuales admiten cualquier CIDR y pueden no expirar.
+package ipblock
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/ratelimit"
+)
+
+// Collection colección con los rangos bloqueados (un documento por CIDR). El campo
+// expires_at permite configurar una política TTL de Firestore para los bloqueos vencidos.
+const Collection = "ip_blocklist"
+
+// Options umbrales del bloqueo automático
+type Options struct {
+	Threshold     int           // fallos por rango en Window que provocan el bloqueo (0 = sin bloqueo automático)
+	Window        time.Duration // por defecto 15 minutos
+	BlockDuration time.Duration // por defecto 1 hora
+	IPv4Prefix    int           // por defecto 24
+	IPv6Prefix    int           // por defecto 64
+	CacheTTL      time.Duration // frecuencia de recarga de la lista (por defecto 30 segundos)
+}
+
+// Block rango bloqueado
+type Block struct {
+	Range     string    `json:"range" firestore:"range"`
+	Reason    string    `json:"reason" firestore:"reason"`
+	Manual    bool      `json:"manual" firestore:"manual"`
+	Failures  int64     `json:"failures,omitempty" firestore:"failures"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty" firestore:"expires_at,omitempty"` // cero = permanente
+}
+
+// Active indica si el bloqueo sigue vigente en now
+func (b *Block) Active(now time.Time) bool {
+	return b.ExpiresAt.IsZero() || now.Before(b.ExpiresAt)
+}
+
+var (
+	mu      sync.RWMutex
+	options = Options{
+		Threshold:     20,
+		Window:        15 * time.Minute,
+		BlockDuration: time.Hour,
+		IPv4Prefix:    24,
+		IPv6Prefix:    64,
+		CacheTTL:      30 * time.Second,
+	}
+
+	// cache bloqueos vigentes, recargados como mucho cada CacheTTL
+	cache     []*cachedBlock
+	cachedAt  time.Time
+	cacheLock sync.Mutex
+)
+
+type cachedBlock struct {
+	block   *Block
+	network *net.IPNet
+}
+
+// Configure reemplaza los umbrales; los valores cero toman el valor por defecto
+func Configure(o Options) {
+	if o.Window <= 0 {
+		o.Window = 15 * time.Minute
+	}
+	if o.BlockDuration <= 0 {
+		o.BlockDuration = time.Hour
+	}
+	if o.IPv4Prefix <= 0 || o.IPv4Prefix > 32 {
+		o.IPv4Prefix = 24
+	}
+	if o.IPv6Prefix <= 0 || o.IPv6Prefix > 128 {
+		o.IPv6Prefix = 64
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = 30 * time.Second
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	options = o
+}
+
+func currentOptions() Options {
+	mu.RLock()
+	defer mu.RUnlock()
+	return options
+}
+
+// RangeOf retorna el rango (CIDR) al que pertenece ip según los prefijos configurados
+func RangeOf(ip string) (string, error) {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP address '%s'", ip)
+	}
+	o := currentOptions()
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(o.IPv4Prefix, 32)), Mask: net.CIDRMask(o.IPv4Prefix, 32)}).String(), nil
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(o.IPv6Prefix, 128)), Mask: net.CIDRMask(o.IPv6Prefix, 128)}).String(), nil
+}
+
+// Check retorna *firebase.IPBlockedError si ip pertenece a un rango bloqueado vigente
+func Check(ctx context.Context, ip string) error {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return nil
+	}
+	blocks, err := activeBlocks(ctx)
+	if err != nil {
+		return err
+	}
+	now := firebase.Now()
+	for _, cached := range blocks {
+		if cached.network.Contains(parsed) && cached.block.Active(now) {
+			return &firebase.IPBlockedError{IP: ip, Range: cached.block.Range, ExpiresAt: cached.block.ExpiresAt}
+		}
+	}
+	return nil
+}
+
+// CheckContext es Check con la IP de firebase.ClientInfoFromContext (nil si no hay IP)
+func CheckContext(ctx context.Context) error {
+	client, ok := firebase.ClientInfoFromContext(ctx)
+	if !ok || client.IP == "" {
+		return nil
+	}
+	return Check(ctx, client.IP)
+}
+
+// RecordFailure cuenta un login fallido desde ip y bloquea su rango al alcanzar el umbral
+func RecordFailure(ctx context.Context, ip string) error {
+	o := currentOptions()
+	if o.Threshold <= 0 {
+		return nil
+	}
+	cidr, err := RangeOf(ip)
+	if err != nil {
+		return err
+	}
+	result, err := ratelimit.Check(ctx, "ip_failures:"+cidr, o.Threshold, o.Window)
+	if err != nil {
+		return fmt.Errorf("failed to count login failure: %w", err)
+	}
+	if result.Count < int64(o.Threshold) {
+		return nil
+	}
+	// No reemplazar un bloqueo vigente (p. ej. uno manual permanente)
+	if err := Check(ctx, ip); err != nil {
+		return nil
+	}
+	return saveBlock(ctx, &Block{
+		Range:     cidr,
+		Reason:    fmt.Sprintf("%d failed logins in %s", result.Count, o.Window),
+		Failures:  result.Count,
+		CreatedAt: firebase.Now(),
+		ExpiresAt: firebase.Now().Add(o.BlockDuration),
+	})
+}
+
+// RecordFailureContext es RecordFailure con la IP de firebase.ClientInfoFromContext
+func RecordFailureContext(ctx context.Context) error {
+	client, ok := firebase.ClientInfoFromContext(ctx)
+	if !ok || client.IP == "" {
+		return nil
+	}
+	return RecordFailure(ctx, client.IP)
+}
+
+// BlockRange bloquea manualmente un CIDR o una IP durante duration (0 = permanente)
+func BlockRange(ctx context.Context, cidr, reason string, duration time.Duration) (*Block, error) {
+	network, err := parseRange(cidr)
+	if err != nil {
+		return nil, err
+	}
+	block := &Block{Range: network.String(), Reason: reason, Manual: true, CreatedAt: firebase.Now()}
+	if duration > 0 {
+		block.ExpiresAt = block.CreatedAt.Add(duration)
+	}
+	if err := saveBlock(ctx, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// Unblock elimina el bloqueo de un CIDR o una IP
+func Unblock(ctx context.Context, cidr string) error {
+	network, err := parseRange(cidr)
+	if err != nil {
+		return err
+	}
+	if _, err := firestore.DocRefContext(ctx, Collection, docID(network.String())).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to unblock '%s': %w", network, err)
+	}
+	invalidate()
+	return nil
+}
+
+// ListBlocks retorna los bloqueos vigentes
+func ListBlocks(ctx context.Context) ([]*Block, error) {
+	blocks, err := loadBlocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Block, 0, len(blocks))
+	for _, cached := range blocks {
+		result = append(result, cached.block)
+	}
+	return result, nil
+}
+
+// Middleware responde 403 a las peticiones desde rangos bloqueados. Toma la IP de
+// firebase.ClientInfoFromContext o, si no está, de firebase.RequestIP.
+// Si la lista no puede leerse, la petición continúa.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := Check(r.Context(), clientIP(r)); err != nil {
+			if blocked, ok := err.(*firebase.IPBlockedError); ok {
+				if !blocked.ExpiresAt.IsZero() {
+					w.Header().Set("Retry-After", fmt.Sprintf("%d", int(time.Until(blocked.ExpiresAt).Seconds())+1))
+				}
+				http.Error(w, "acceso bloqueado desde tu red", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	if client, ok := firebase.ClientInfoFromContext(r.Context()); ok && client.IP != "" {
+		return client.IP
+	}
+	return firebase.RequestIP(r)
+}
+
+func saveBlock(ctx context.Context, block *Block) error {
+	if _, err := firestore.DocRefContext(ctx, Collection, docID(block.Range)).Set(ctx, block); err != nil {
+		return fmt.Errorf("failed to block '%s': %w", block.Range, err)
+	}
+	invalidate()
+	return nil
+}
+
+// activeBlocks lista cacheada de bloqueos vigentes
+func activeBlocks(ctx context.Context) ([]*cachedBlock, error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	if !cachedAt.IsZero() && time.Since(cachedAt) < currentOptions().CacheTTL {
+		firebase.RecordCacheLookup("ipblock", true)
+		return cache, nil
+	}
+	firebase.RecordCacheLookup("ipblock", false)
+	blocks, err := loadBlocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache, cachedAt = blocks, time.Now()
+	return cache, nil
+}
+
+func loadBlocks(ctx context.Context) ([]*cachedBlock, error) {
+	now := firebase.Now()
+	iter := firebase.FirestoreClientFor(ctx, Collection).Collection(Collection).Documents(ctx)
+	defer iter.Stop()
+	var blocks []*cachedBlock
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read IP blocklist: %w", err)
+		}
+		var block Block
+		if err := snap.DataTo(&block); err != nil {
+			continue
+		}
+		_, network, err := net.ParseCIDR(block.Range)
+		if err != nil || !block.Active(now) {
+			continue
+		}
+		blocks = append(blocks, &cachedBlock{block: &block, network: network})
+	}
+}
+
+func invalidate() {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	cachedAt = time.Time{}
+}
+
+// parseRange acepta un CIDR o una IP suelta (/32 o /128)
+func parseRange(cidr string) (*net.IPNet, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP range '%s'", cidr)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP range '%s': %w", cidr, err)
+	}
+	return network, nil
+}
+
+// docID ID de documento del CIDR ("/" no es válido en un ID)
+func docID(cidr string) string {
+	return strings.NewReplacer("/", "_", ":", "-").Replace(cidr)
+}
@@ -0,0 +1,368 @@
+package ledger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+const (
+	// EntriesCollection colección de asientos (solo se crean, nunca se modifican)
+	EntriesCollection = "ledger_entries"
+	// HeadsCollection último asiento de cada libro (secuencia y hash)
+	HeadsCollection = "ledger_heads"
+	// BalancesCollection saldo materializado por cuenta
+	BalancesCollection = "ledger_balances"
+	// ReferencesCollection referencias externas ya registradas (idempotencia de webhooks)
+	ReferencesCollection = "ledger_references"
+)
+
+// Posting movimiento de un asiento sobre una cuenta. Amount está en unidades mínimas
+// (centavos): positivo es débito y negativo es crédito.
+type Posting struct {
+	Account string `json:"account"`
+	Amount  int64  `json:"amount"`
+}
+
+// Entry asiento de partida doble; la suma de sus Postings siempre es cero
+type Entry struct {
+	ID          string    `json:"id"`
+	Ledger      string    `json:"ledger"`
+	Sequence    int64     `json:"sequence"`
+	Currency    string    `json:"currency"`
+	Description string    `json:"description,omitempty"`
+	Reference   string    `json:"reference,omitempty"` // ID externo (p. ej. evento del proveedor de pagos)
+	Postings    []Posting `json:"postings"`
+	CreatedAt   time.Time `json:"created_at"`
+	PrevHash    string    `json:"prev_hash"`
+	Hash        string    `json:"hash"`
+}
+
+// ChainError cuando la cadena de hashes de un libro no coincide (posible manipulación)
+type ChainError struct {
+	Ledger   string
+	Sequence int64
+	Reason   string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("ledger '%s' chain broken at sequence %d: %s", e.Ledger, e.Sequence, e.Reason)
+}
+
+// Record agrega un asiento al libro ledger. El asiento se encadena con el hash del
+// anterior y actualiza los saldos de las cuentas en la misma transacción. Si reference no
+// está vacío y ya fue registrado, retorna el asiento existente sin duplicarlo.
+func Record(ctx context.Context, ledger, currency, description, reference string, postings []Posting) (*Entry, error) {
+	if err := validatePostings(postings); err != nil {
+		return nil, err
+	}
+	if currency == "" {
+		return nil, fmt.Errorf("ledger entry requires a currency")
+	}
+
+	headRef := firestore.DocRefContext(ctx, HeadsCollection, ledger)
+	var refDoc *gfirestore.DocumentRef
+	if reference != "" {
+		refDoc = firestore.DocRefContext(ctx, ReferencesCollection, hashKey(ledger, reference))
+	}
+
+	var entry *Entry
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		entry = nil
+		if refDoc != nil {
+			snap, err := tx.Get(refDoc)
+			if err != nil && !firestore.IsNotFound(err) {
+				return err
+			}
+			if err == nil {
+				entryID, _ := snap.Data()["entry_id"].(string)
+				existing, err := tx.Get(firestore.DocRefContext(ctx, EntriesCollection, entryID))
+				if err != nil {
+					return err
+				}
+				entry = entryFromData(existing.Ref.ID, existing.Data())
+				return nil
+			}
+		}
+
+		var sequence int64
+		prevHash := ""
+		head, err := tx.Get(headRef)
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			sequence, _ = head.Data()["sequence"].(int64)
+			prevHash, _ = head.Data()["hash"].(string)
+		}
+
+		// Firestore guarda microsegundos; se trunca para que el hash sea verificable
+		entry = &Entry{
+			Ledger:      ledger,
+			Sequence:    sequence + 1,
+			Currency:    currency,
+			Description: description,
+			Reference:   reference,
+			Postings:    postings,
+			CreatedAt:   firebase.Now().Truncate(time.Microsecond),
+			PrevHash:    prevHash,
+		}
+		entry.ID = entryID(ledger, entry.Sequence)
+		entry.Hash = computeHash(entry)
+
+		if err := tx.Create(firestore.DocRefContext(ctx, EntriesCollection, entry.ID), entryData(entry)); err != nil {
+			return err
+		}
+		if err := tx.Set(headRef, map[string]interface{}{
+			"sequence":   entry.Sequence,
+			"hash":       entry.Hash,
+			"updated_at": entry.CreatedAt,
+		}); err != nil {
+			return err
+		}
+		if refDoc != nil {
+			if err := tx.Create(refDoc, map[string]interface{}{"entry_id": entry.ID, "reference": reference}); err != nil {
+				return err
+			}
+		}
+		for _, posting := range postings {
+			if err := tx.Set(firestore.DocRefContext(ctx, BalancesCollection, balanceID(posting.Account, currency)), map[string]interface{}{
+				"account":    posting.Account,
+				"currency":   currency,
+				"balance":    gfirestore.Increment(posting.Amount),
+				"updated_at": entry.CreatedAt,
+			}, gfirestore.MergeAll); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record ledger entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Transfer registra un movimiento simple de amount desde from hacia to
+func Transfer(ctx context.Context, ledger, currency, from, to string, amount int64, description, reference string) (*Entry, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("transfer amount must be positive")
+	}
+	return Record(ctx, ledger, currency, description, reference, []Posting{
+		{Account: to, Amount: amount},
+		{Account: from, Amount: -amount},
+	})
+}
+
+// GetBalance retorna el saldo materializado de una cuenta (0 si no tiene movimientos)
+func GetBalance(ctx context.Context, account, currency string) (int64, error) {
+	snap, err := firestore.DocRefContext(ctx, BalancesCollection, balanceID(account, currency)).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get balance of '%s': %w", account, err)
+	}
+	balance, _ := snap.Data()["balance"].(int64)
+	return balance, nil
+}
+
+// ComputeBalance recalcula el saldo de una cuenta sumando sus asientos hasta asOf (zero
+// = todos). Sirve para auditar el saldo materializado o consultar saldos históricos.
+func ComputeBalance(ctx context.Context, account, currency string, asOf time.Time) (int64, error) {
+	filters := []firebase.QueryFilter{
+		firebase.Where("accounts", firebase.OpArrayContains, account),
+		firebase.Where("currency", firebase.OpEqual, currency),
+	}
+	if !asOf.IsZero() {
+		filters = append(filters, firebase.Where("created_at", firebase.OpLessThanOrEqual, asOf))
+	}
+
+	docs, err := firestore.QueryDocuments(ctx, EntriesCollection, firebase.QueryOptions{Filters: filters})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list ledger entries of '%s': %w", account, err)
+	}
+
+	var balance int64
+	for _, doc := range docs {
+		for _, posting := range entryFromData(doc.ID, doc.Data).Postings {
+			if posting.Account == account {
+				balance += posting.Amount
+			}
+		}
+	}
+	return balance, nil
+}
+
+// ListEntries lista los asientos del libro en orden de secuencia. El cursor es el
+// NextCursor del resultado anterior.
+func ListEntries(ctx context.Context, ledger string, pageSize int, cursor string) (*firebase.QueryResult, error) {
+	result, err := firestore.QueryDocumentsWithMeta(ctx, EntriesCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{firebase.Where("ledger", firebase.OpEqual, ledger)},
+		OrderBy: "sequence",
+		Limit:   pageSize,
+		Cursor:  cursor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entries of ledger '%s': %w", ledger, err)
+	}
+	return result, nil
+}
+
+// VerifyChain recorre el libro completo recalculando los hashes. Retorna un *ChainError
+// en el primer asiento alterado, faltante o fuera de orden.
+func VerifyChain(ctx context.Context, ledger string) error {
+	iter := firebase.GetFirestoreClient().Collection(EntriesCollection).
+		Where("ledger", "==", ledger).
+		OrderBy("sequence", gfirestore.Asc).
+		Documents(ctx)
+	defer iter.Stop()
+
+	docs, err := iter.GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to read ledger '%s': %w", ledger, err)
+	}
+
+	prevHash := ""
+	for i, doc := range docs {
+		entry := entryFromData(doc.Ref.ID, doc.Data())
+		expected := int64(i + 1)
+		switch {
+		case entry.Sequence != expected:
+			return &ChainError{Ledger: ledger, Sequence: expected, Reason: "missing entry"}
+		case entry.PrevHash != prevHash:
+			return &ChainError{Ledger: ledger, Sequence: entry.Sequence, Reason: "previous hash mismatch"}
+		case computeHash(entry) != entry.Hash:
+			return &ChainError{Ledger: ledger, Sequence: entry.Sequence, Reason: "content hash mismatch"}
+		case validatePostings(entry.Postings) != nil:
+			return &ChainError{Ledger: ledger, Sequence: entry.Sequence, Reason: "unbalanced postings"}
+		}
+		prevHash = entry.Hash
+	}
+
+	head, err := firestore.DocRefContext(ctx, HeadsCollection, ledger).Get(ctx)
+	if err != nil && !firestore.IsNotFound(err) {
+		return fmt.Errorf("failed to read head of ledger '%s': %w", ledger, err)
+	}
+	if err == nil {
+		if hash, _ := head.Data()["hash"].(string); hash != prevHash {
+			return &ChainError{Ledger: ledger, Sequence: int64(len(docs)), Reason: "head does not match last entry"}
+		}
+	}
+	return nil
+}
+
+func validatePostings(postings []Posting) error {
+	if len(postings) < 2 {
+		return fmt.Errorf("ledger entry requires at least two postings")
+	}
+	var sum int64
+	for _, posting := range postings {
+		if posting.Account == "" {
+			return fmt.Errorf("posting requires an account")
+		}
+		if posting.Amount == 0 {
+			return fmt.Errorf("posting amount for '%s' cannot be zero", posting.Account)
+		}
+		sum += posting.Amount
+	}
+	if sum != 0 {
+		return fmt.Errorf("postings are unbalanced by %d", sum)
+	}
+	return nil
+}
+
+// computeHash hash SHA-256 de la representación canónica del asiento encadenado con el anterior
+func computeHash(entry *Entry) string {
+	postings := make([]string, len(entry.Postings))
+	for i, posting := range entry.Postings {
+		postings[i] = fmt.Sprintf("%q:%d", posting.Account, posting.Amount)
+	}
+	sort.Strings(postings)
+
+	canonical := strings.Join([]string{
+		entry.PrevHash,
+		fmt.Sprintf("%q", entry.Ledger),
+		fmt.Sprintf("%d", entry.Sequence),
+		fmt.Sprintf("%q", entry.Currency),
+		fmt.Sprintf("%q", entry.Description),
+		fmt.Sprintf("%q", entry.Reference),
+		strings.Join(postings, ","),
+		fmt.Sprintf("%d", entry.CreatedAt.UnixMicro()),
+	}, "|")
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+func entryData(entry *Entry) map[string]interface{} {
+	postings := make([]interface{}, len(entry.Postings))
+	accounts := make([]interface{}, 0, len(entry.Postings))
+	seen := map[string]bool{}
+	for i, posting := range entry.Postings {
+		postings[i] = map[string]interface{}{"account": posting.Account, "amount": posting.Amount}
+		if !seen[posting.Account] {
+			seen[posting.Account] = true
+			accounts = append(accounts, posting.Account)
+		}
+	}
+	return map[string]interface{}{
+		"ledger":      entry.Ledger,
+		"sequence":    entry.Sequence,
+		"currency":    entry.Currency,
+		"description": entry.Description,
+		"reference":   entry.Reference,
+		"postings":    postings,
+		"accounts":    accounts,
+		"created_at":  entry.CreatedAt,
+		"prev_hash":   entry.PrevHash,
+		"hash":        entry.Hash,
+	}
+}
+
+func entryFromData(id string, data map[string]interface{}) *Entry {
+	entry := &Entry{ID: id}
+	entry.Ledger, _ = data["ledger"].(string)
+	entry.Sequence, _ = data["sequence"].(int64)
+	entry.Currency, _ = data["currency"].(string)
+	entry.Description, _ = data["description"].(string)
+	entry.Reference, _ = data["reference"].(string)
+	entry.CreatedAt, _ = data["created_at"].(time.Time)
+	entry.PrevHash, _ = data["prev_hash"].(string)
+	entry.Hash, _ = data["hash"].(string)
+
+	postings, _ := data["postings"].([]interface{})
+	for _, raw := range postings {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		posting := Posting{}
+		posting.Account, _ = fields["account"].(string)
+		posting.Amount, _ = fields["amount"].(int64)
+		entry.Postings = append(entry.Postings, posting)
+	}
+	return entry
+}
+
+// entryID ID ordenable del asiento dentro de su libro
+func entryID(ledger string, sequence int64) string {
+	return fmt.Sprintf("%s_%020d", hashKey(ledger)[:16], sequence)
+}
+
+func balanceID(account, currency string) string {
+	return hashKey(account, currency)
+}
+
+func hashKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
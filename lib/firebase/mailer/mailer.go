@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Message representa un correo electrónico a enviar
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+}
+
+// Mailer define el transporte usado para enviar correos
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrMailerNotConfigured se retorna al enviar sin haber llamado a SetMailer
+var ErrMailerNotConfigured = errors.New("mailer not configured: call SetMailer")
+
+var (
+	mu      sync.RWMutex
+	current Mailer = unconfiguredMailer{}
+)
+
+// SetMailer reemplaza el transporte usado por el paquete. Sin transporte configurado los
+// envíos fallan con ErrMailerNotConfigured; LogMailer debe elegirse explícitamente.
+func SetMailer(m Mailer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if m == nil {
+		m = unconfiguredMailer{}
+	}
+	current = m
+}
+
+// GetMailer retorna el transporte configurado
+func GetMailer() Mailer {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Send envía un correo usando el transporte configurado
+func Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return fmt.Errorf("email recipient is required")
+	}
+	if err := GetMailer().Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send email to '%s': %w", msg.To, err)
+	}
+	return nil
+}
+
+// unconfiguredMailer transporte por defecto: falla en lugar de descartar el correo
+type unconfiguredMailer struct{}
+
+func (unconfiguredMailer) Send(ctx context.Context, msg Message) error {
+	return ErrMailerNotConfigured
+}
+
+// LogMailer escribe los correos en el log en lugar de enviarlos (solo para desarrollo:
+// el log incluye los enlaces y códigos de un solo uso)
+type LogMailer struct{}
+
+// Send registra el correo en el log
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 Email para %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
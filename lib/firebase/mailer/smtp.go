@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTPMailer envía correos usando un servidor SMTP
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailerFromEnv crea un SMTPMailer desde variables de entorno
+// (SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM)
+func NewSMTPMailerFromEnv() (*SMTPMailer, error) {
+	m := &SMTPMailer{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if m.Host == "" {
+		return nil, fmt.Errorf("SMTP_HOST environment variable not set")
+	}
+	if m.From == "" {
+		return nil, fmt.Errorf("SMTP_FROM environment variable not set")
+	}
+	if m.Port == "" {
+		m.Port = "587"
+	}
+	return m, nil
+}
+
+// Send envía el correo a través del servidor SMTP
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	body, err := m.buildMessage(msg)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(m.Host, m.Port)
+	if err := smtp.SendMail(addr, auth, m.From, []string{msg.To}, body); err != nil {
+		return fmt.Errorf("smtp send failed: %w", err)
+	}
+	return nil
+}
+
+// buildMessage arma el mensaje MIME (multipart/alternative si hay HTML). Rechaza saltos de
+// línea en las cabeceras, que permitirían inyectar otras, y codifica el asunto (RFC 2047).
+func (m *SMTPMailer) buildMessage(msg Message) ([]byte, error) {
+	for name, value := range map[string]string{"From": m.From, "To": msg.To, "Subject": msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid %s header: contains a line break", name)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + m.From + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		b.WriteString(msg.Text)
+		return []byte(b.String()), nil
+	}
+
+	boundary := fmt.Sprintf("boundary-%d", time.Now().UnixNano())
+	b.WriteString("Content-Type: multipart/alternative; boundary=" + boundary + "\r\n\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Text + "\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	b.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.HTML + "\r\n")
+	b.WriteString("--" + boundary + "--\r\n")
+	return []byte(b.String()), nil
+}
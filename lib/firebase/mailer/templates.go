@@ -0,0 +1,412 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// TemplatesCollection colección de plantillas editables. Cada documento (name__locale)
+// guarda la versión activa y sus versiones en la subcolección "versions".
+const TemplatesCollection = "email_templates"
+
+// DefaultLocale idioma usado cuando no hay variante para el solicitado
+var DefaultLocale = "es"
+
+// Template plantilla de correo. Subject y Text usan text/template y HTML usa
+// html/template; Variables son las variables obligatorias al renderizar.
+type Template struct {
+	Name      string   `json:"name"`
+	Locale    string   `json:"locale"`
+	Version   int64    `json:"version,omitempty"`
+	Subject   string   `json:"subject"`
+	Text      string   `json:"text"`
+	HTML      string   `json:"html,omitempty"`
+	Variables []string `json:"variables,omitempty"`
+}
+
+var (
+	templatesMu      sync.RWMutex
+	builtinTemplates = map[string]Template{}
+)
+
+func init() {
+	for _, tpl := range defaultTemplates {
+		RegisterTemplate(tpl)
+	}
+}
+
+// RegisterTemplate registra una plantilla en memoria. Se usa cuando no existe una versión
+// en Firestore para el nombre e idioma.
+func RegisterTemplate(tpl Template) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	builtinTemplates[templateKey(tpl.Name, tpl.Locale)] = tpl
+}
+
+// LoadTemplatesFS registra plantillas desde un sistema de archivos (p. ej. embed.FS) con
+// archivos "<name>.<locale>.subject", "<name>.<locale>.txt" y "<name>.<locale>.html"
+// dentro de dir
+func LoadTemplatesFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read templates directory '%s': %w", dir, err)
+	}
+
+	loaded := map[string]*Template{}
+	for _, entry := range entries {
+		parts := strings.Split(entry.Name(), ".")
+		if entry.IsDir() || len(parts) != 3 {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read template '%s': %w", entry.Name(), err)
+		}
+
+		key := templateKey(parts[0], parts[1])
+		tpl, ok := loaded[key]
+		if !ok {
+			tpl = &Template{Name: parts[0], Locale: parts[1]}
+			loaded[key] = tpl
+		}
+		switch parts[2] {
+		case "subject":
+			tpl.Subject = strings.TrimSpace(string(content))
+		case "txt":
+			tpl.Text = string(content)
+		case "html":
+			tpl.HTML = string(content)
+		}
+	}
+
+	for _, tpl := range loaded {
+		if _, err := tpl.render(nil, false); err != nil {
+			return err
+		}
+		RegisterTemplate(*tpl)
+	}
+	return nil
+}
+
+// SaveTemplate guarda una nueva versión de la plantilla en Firestore y la activa.
+// Verifica que compile y que solo use las variables declaradas. Retorna la versión creada.
+func SaveTemplate(ctx context.Context, tpl Template) (int64, error) {
+	if tpl.Name == "" || tpl.Locale == "" {
+		return 0, fmt.Errorf("template requires a name and a locale")
+	}
+	if _, err := Preview(tpl, nil); err != nil {
+		return 0, err
+	}
+
+	headRef := firestore.DocRefContext(ctx, TemplatesCollection, templateKey(tpl.Name, tpl.Locale))
+	var version int64
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		var latest int64
+		snap, err := tx.Get(headRef)
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			latest, _ = snap.Data()["latest_version"].(int64)
+		}
+
+		version = latest + 1
+		now := firebase.Now()
+		variables := make([]interface{}, len(tpl.Variables))
+		for i, name := range tpl.Variables {
+			variables[i] = name
+		}
+		if err := tx.Create(headRef.Collection("versions").Doc(fmt.Sprintf("%d", version)), map[string]interface{}{
+			"subject":    tpl.Subject,
+			"text":       tpl.Text,
+			"html":       tpl.HTML,
+			"variables":  variables,
+			"created_at": now,
+		}); err != nil {
+			return err
+		}
+		return tx.Set(headRef, map[string]interface{}{
+			"name":           tpl.Name,
+			"locale":         tpl.Locale,
+			"latest_version": version,
+			"active_version": version,
+			"updated_at":     now,
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to save template '%s' (%s): %w", tpl.Name, tpl.Locale, err)
+	}
+	return version, nil
+}
+
+// ActivateTemplateVersion cambia la versión activa de una plantilla (p. ej. para revertir)
+func ActivateTemplateVersion(ctx context.Context, name, locale string, version int64) error {
+	headRef := firestore.DocRefContext(ctx, TemplatesCollection, templateKey(name, locale))
+	if _, err := headRef.Collection("versions").Doc(fmt.Sprintf("%d", version)).Get(ctx); err != nil {
+		return fmt.Errorf("failed to get version %d of template '%s' (%s): %w", version, name, locale, err)
+	}
+	_, err := headRef.Update(ctx, []gfirestore.Update{
+		{Path: "active_version", Value: version},
+		{Path: "updated_at", Value: firebase.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to activate template '%s' (%s): %w", name, locale, err)
+	}
+	return nil
+}
+
+// GetTemplate resuelve la plantilla activa probando, en orden, el idioma pedido, su idioma
+// base ("es-MX" -> "es") y DefaultLocale; primero en Firestore y luego en memoria
+func GetTemplate(ctx context.Context, name, locale string) (*Template, error) {
+	candidates := localeCandidates(locale)
+
+	for _, candidate := range candidates {
+		tpl, err := loadStoredTemplate(ctx, name, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if tpl != nil {
+			return tpl, nil
+		}
+	}
+
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	for _, candidate := range candidates {
+		if tpl, ok := builtinTemplates[templateKey(name, candidate)]; ok {
+			return &tpl, nil
+		}
+	}
+	return nil, fmt.Errorf("email template '%s' not found for locale '%s'", name, locale)
+}
+
+// Render resuelve la plantilla y la ejecuta con vars, validando las variables obligatorias
+func Render(ctx context.Context, name, locale string, vars map[string]interface{}) (*Message, error) {
+	tpl, err := GetTemplate(ctx, name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []firebase.ValidationIssue
+	for _, variable := range tpl.Variables {
+		if _, ok := vars[variable]; !ok {
+			issues = append(issues, firebase.ValidationIssue{Field: variable, Reason: "missing template variable"})
+		}
+	}
+	if len(issues) > 0 {
+		return nil, &firebase.ValidationError{Collection: TemplatesCollection, DocumentID: templateKey(tpl.Name, tpl.Locale), Issues: issues}
+	}
+	return tpl.render(vars, true)
+}
+
+// Preview renderiza una plantilla sin guardarla. Las variables declaradas que falten en
+// vars se reemplazan por su nombre entre corchetes.
+func Preview(tpl Template, vars map[string]interface{}) (*Message, error) {
+	sample := make(map[string]interface{}, len(tpl.Variables)+len(vars))
+	for _, variable := range tpl.Variables {
+		sample[variable] = "[" + variable + "]"
+	}
+	for key, value := range vars {
+		sample[key] = value
+	}
+	return tpl.render(sample, true)
+}
+
+// SendTemplate renderiza la plantilla y la envía a to
+func SendTemplate(ctx context.Context, to, name, locale string, vars map[string]interface{}) error {
+	msg, err := Render(ctx, name, locale, vars)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return Send(ctx, *msg)
+}
+
+// render ejecuta la plantilla; con strict falla si se usa una variable no proporcionada
+func (tpl Template) render(vars map[string]interface{}, strict bool) (*Message, error) {
+	missingKey := "missingkey=zero"
+	if strict {
+		missingKey = "missingkey=error"
+	}
+
+	msg := &Message{}
+	for _, part := range []struct {
+		name   string
+		source string
+		target *string
+	}{
+		{"subject", tpl.Subject, &msg.Subject},
+		{"text", tpl.Text, &msg.Text},
+	} {
+		parsed, err := template.New(part.name).Option(missingKey).Parse(part.source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s of template '%s': %w", part.name, tpl.Name, err)
+		}
+		var buf bytes.Buffer
+		if err := parsed.Execute(&buf, vars); err != nil {
+			return nil, fmt.Errorf("failed to render %s of template '%s': %w", part.name, tpl.Name, err)
+		}
+		*part.target = buf.String()
+	}
+
+	if tpl.HTML != "" {
+		parsed, err := htmltemplate.New("html").Option(missingKey).Parse(tpl.HTML)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse html of template '%s': %w", tpl.Name, err)
+		}
+		var buf bytes.Buffer
+		if err := parsed.Execute(&buf, vars); err != nil {
+			return nil, fmt.Errorf("failed to render html of template '%s': %w", tpl.Name, err)
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+// loadStoredTemplate obtiene la versión activa guardada en Firestore (nil si no existe)
+func loadStoredTemplate(ctx context.Context, name, locale string) (*Template, error) {
+	headRef := firestore.DocRefContext(ctx, TemplatesCollection, templateKey(name, locale))
+	head, err := headRef.Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get template '%s' (%s): %w", name, locale, err)
+	}
+
+	version, _ := head.Data()["active_version"].(int64)
+	snap, err := headRef.Collection("versions").Doc(fmt.Sprintf("%d", version)).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version %d of template '%s' (%s): %w", version, name, locale, err)
+	}
+
+	data := snap.Data()
+	tpl := &Template{Name: name, Locale: locale, Version: version}
+	tpl.Subject, _ = data["subject"].(string)
+	tpl.Text, _ = data["text"].(string)
+	tpl.HTML, _ = data["html"].(string)
+	if variables, ok := data["variables"].([]interface{}); ok {
+		for _, variable := range variables {
+			if v, ok := variable.(string); ok {
+				tpl.Variables = append(tpl.Variables, v)
+			}
+		}
+	}
+	return tpl, nil
+}
+
+// ListTemplateVersions retorna las versiones guardadas de una plantilla, de la más nueva
+// a la más antigua
+func ListTemplateVersions(ctx context.Context, name, locale string) ([]int64, error) {
+	refs, err := firestore.DocRefContext(ctx, TemplatesCollection, templateKey(name, locale)).Collection("versions").DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of template '%s' (%s): %w", name, locale, err)
+	}
+
+	versions := make([]int64, 0, len(refs))
+	for _, ref := range refs {
+		var version int64
+		if _, err := fmt.Sscanf(ref.ID, "%d", &version); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	return versions, nil
+}
+
+func localeCandidates(locale string) []string {
+	var candidates []string
+	add := func(value string) {
+		for _, existing := range candidates {
+			if existing == value {
+				return
+			}
+		}
+		if value != "" {
+			candidates = append(candidates, value)
+		}
+	}
+	add(locale)
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		add(locale[:i])
+	}
+	add(DefaultLocale)
+	return candidates
+}
+
+func templateKey(name, locale string) string {
+	return name + "__" + locale
+}
+
+// defaultTemplates plantillas incluidas usadas por auth y notifications
+var defaultTemplates = []Template{
+	{
+		Name:      "otp",
+		Locale:    "es",
+		Subject:   "Tu código de acceso",
+		Text:      "Tu código de un solo uso es: {{.OTP}}\n\nEs válido por {{.ValidMinutes}} minutos.",
+		Variables: []string{"OTP", "ValidMinutes"},
+	},
+	{
+		Name:      "invitation",
+		Locale:    "es",
+		Subject:   "Tienes una invitación",
+		Text:      "{{if .AcceptURL}}Has sido invitado a unirte. Acepta la invitación aquí:\n\n{{.AcceptURL}}{{else}}Has sido invitado a unirte. Tu código de invitación es:\n\n{{.Token}}{{end}}",
+		Variables: []string{"Token", "AcceptURL"},
+	},
+	{
+		Name:      "password_reset",
+		Locale:    "es",
+		Subject:   "Restablece tu contraseña",
+		Text:      "Usa el siguiente enlace para restablecer tu contraseña:\n\n{{.Link}}",
+		Variables: []string{"Link"},
+	},
+	{
+		Name:      "email_verification",
+		Locale:    "es",
+		Subject:   "Verifica tu correo electrónico",
+		Text:      "Confirma tu dirección de correo con el siguiente enlace:\n\n{{.Link}}",
+		Variables: []string{"Link"},
+	},
+	{
+		Name:      "sign_in_link",
+		Locale:    "es",
+		Subject:   "Tu enlace de inicio de sesión",
+		Text:      "Inicia sesión con el siguiente enlace:\n\n{{.Link}}",
+		Variables: []string{"Link"},
+	},
+	{
+		Name:      "email_change",
+		Locale:    "es",
+		Subject:   "Confirma tu nuevo correo electrónico",
+		Text:      "Tu código para confirmar el cambio de correo es: {{.OTP}}\n\nEs válido por {{.ValidMinutes}} minutos.{{if .ConfirmURL}}\n\nTambién puedes confirmarlo con el siguiente enlace:\n\n{{.ConfirmURL}}{{end}}",
+		Variables: []string{"OTP", "ValidMinutes", "ConfirmURL"},
+	},
+	{
+		Name:      "email_change_notice",
+		Locale:    "es",
+		Subject:   "Solicitud de cambio de correo",
+		Text:      "Se solicitó cambiar el correo de tu cuenta a {{.NewEmail}}. Tu correo actual seguirá activo hasta que se confirme el cambio. Si no fuiste tú, contacta con soporte.",
+		Variables: []string{"NewEmail"},
+	},
+	{
+		Name:      "notification",
+		Locale:    "es",
+		Subject:   "{{.Title}}",
+		Text:      "{{.Body}}",
+		Variables: []string{"Title", "Body"},
+	},
+}
@@ -0,0 +1,266 @@
+package metering
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/tenancy"
+)
+
+// UsageCollection colección con el uso diario por tenant (documento {tenant}_{AAAA-MM-DD})
+const UsageCollection = "tenant_usage"
+
+// Usage uso de un tenant en un día (UTC) o acumulado
+type Usage struct {
+	TenantID     string `json:"tenant_id"`
+	Date         string `json:"date,omitempty"`
+	Reads        int64  `json:"reads"`
+	Writes       int64  `json:"writes"`
+	Deletes      int64  `json:"deletes"`
+	BytesWritten int64  `json:"bytes_written"`
+}
+
+// UsageReport uso de un tenant en un rango de días
+type UsageReport struct {
+	TenantID string   `json:"tenant_id"`
+	Days     []*Usage `json:"days"`
+	Total    Usage    `json:"total"`
+}
+
+// meter acumula el uso en memoria hasta el siguiente volcado
+type meter struct {
+	mu      sync.Mutex
+	pending map[string]*Usage
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+var (
+	meterMu     sync.Mutex
+	activeMeter *meter
+)
+
+// Enable empieza a medir las lecturas, escrituras y bytes escritos a través del paquete
+// firestore y los atribuye al tenant del contexto (tenancy.TenantFromContext). El uso se
+// acumula en memoria y se vuelca con incrementos cada flushInterval (por defecto 1 minuto).
+func Enable(ctx context.Context, flushInterval time.Duration) {
+	if flushInterval <= 0 {
+		flushInterval = time.Minute
+	}
+	Disable(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	m := &meter{pending: map[string]*Usage{}, cancel: cancel, done: make(chan struct{})}
+
+	meterMu.Lock()
+	activeMeter = m
+	meterMu.Unlock()
+	firebase.RegisterInterceptor(firebase.Interceptor{Name: "metering", After: m.record})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.flush(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Disable deja de medir y vuelca el uso pendiente
+func Disable(ctx context.Context) error {
+	firebase.UnregisterInterceptor("metering")
+
+	meterMu.Lock()
+	m := activeMeter
+	activeMeter = nil
+	meterMu.Unlock()
+	if m == nil {
+		return nil
+	}
+	m.cancel()
+	<-m.done
+	return m.flush(ctx)
+}
+
+// Flush vuelca de inmediato el uso acumulado
+func Flush(ctx context.Context) error {
+	meterMu.Lock()
+	m := activeMeter
+	meterMu.Unlock()
+	if m == nil {
+		return nil
+	}
+	return m.flush(ctx)
+}
+
+// GetTenantUsage retorna el uso diario de un tenant entre from y to (inclusive) y el total
+func GetTenantUsage(ctx context.Context, tenantID string, from, to time.Time) (*UsageReport, error) {
+	docs, err := firestore.QueryDocuments(ctx, UsageCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{
+			{Field: "tenant_id", Operator: firebase.OpEqual, Value: tenantID},
+			{Field: "date", Operator: firebase.OpGreaterThanOrEqual, Value: dayKey(from)},
+			{Field: "date", Operator: firebase.OpLessThanOrEqual, Value: dayKey(to)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage for tenant '%s': %w", tenantID, err)
+	}
+
+	report := &UsageReport{TenantID: tenantID, Total: Usage{TenantID: tenantID}}
+	for _, doc := range docs {
+		day := &Usage{TenantID: tenantID}
+		day.Date, _ = doc.GetString("date")
+		day.Reads, _ = doc.GetInt("reads")
+		day.Writes, _ = doc.GetInt("writes")
+		day.Deletes, _ = doc.GetInt("deletes")
+		day.BytesWritten, _ = doc.GetInt("bytes_written")
+		report.Days = append(report.Days, day)
+
+		report.Total.Reads += day.Reads
+		report.Total.Writes += day.Writes
+		report.Total.Deletes += day.Deletes
+		report.Total.BytesWritten += day.BytesWritten
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Date < report.Days[j].Date })
+	return report, nil
+}
+
+// record hook After: atribuye la llamada exitosa al tenant del contexto
+func (m *meter) record(ctx context.Context, call *firebase.Call, err error) {
+	if err != nil {
+		return
+	}
+	tenantID, ok := tenancy.TenantFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	var reads, writes, deletes, bytes int64
+	switch call.Operation {
+	case firebase.CallFirestoreGet:
+		reads = 1
+	case firebase.CallFirestoreQuery:
+		reads = documentsRead(call.Result)
+	case firebase.CallFirestoreCreate, firebase.CallFirestoreUpdate:
+		writes = 1
+		bytes = payloadSize(call.Collection, call.DocumentID, call.Payload)
+	case firebase.CallFirestoreDelete:
+		deletes = 1
+	case firebase.CallFirestoreBatch:
+		op, _ := call.Payload.(*firebase.BatchOperation)
+		if op != nil && op.Type == "delete" {
+			deletes = 1
+		} else if op != nil && op.Type == "check" {
+			reads = 1
+		} else if op != nil {
+			writes = 1
+			bytes = payloadSize(call.Collection, call.DocumentID, op.Data)
+		}
+	default:
+		return
+	}
+
+	day := dayKey(firebase.Now())
+	key := tenantID + "_" + day
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage, ok := m.pending[key]
+	if !ok {
+		usage = &Usage{TenantID: tenantID, Date: day}
+		m.pending[key] = usage
+	}
+	usage.Reads += reads
+	usage.Writes += writes
+	usage.Deletes += deletes
+	usage.BytesWritten += bytes
+}
+
+// flush escribe los contadores pendientes con incrementos atómicos. Escribe con el
+// cliente directamente para que el volcado no se mida ni pase por los interceptores.
+func (m *meter) flush(ctx context.Context) error {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = map[string]*Usage{}
+	m.mu.Unlock()
+
+	client := firebase.GetFirestoreClient()
+	var failed []string
+	for key, usage := range pending {
+		_, err := client.Collection(UsageCollection).Doc(key).Set(ctx, map[string]interface{}{
+			"tenant_id":     usage.TenantID,
+			"date":          usage.Date,
+			"reads":         gfirestore.Increment(usage.Reads),
+			"writes":        gfirestore.Increment(usage.Writes),
+			"deletes":       gfirestore.Increment(usage.Deletes),
+			"bytes_written": gfirestore.Increment(usage.BytesWritten),
+			"updated_at":    firebase.Now(),
+		}, gfirestore.MergeAll)
+		if err != nil {
+			// Se conserva para el siguiente volcado
+			m.mu.Lock()
+			m.merge(key, usage)
+			m.mu.Unlock()
+			failed = append(failed, key)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to flush usage for %d tenant days", len(failed))
+	}
+	return nil
+}
+
+func (m *meter) merge(key string, usage *Usage) {
+	current, ok := m.pending[key]
+	if !ok {
+		m.pending[key] = usage
+		return
+	}
+	current.Reads += usage.Reads
+	current.Writes += usage.Writes
+	current.Deletes += usage.Deletes
+	current.BytesWritten += usage.BytesWritten
+}
+
+func documentsRead(result interface{}) int64 {
+	switch r := result.(type) {
+	case *firebase.QueryResult:
+		return int64(r.DocumentsRead)
+	case []*firebase.Document:
+		return int64(len(r))
+	case int:
+		return int64(r)
+	}
+	return 1
+}
+
+func payloadSize(collection, docID string, payload interface{}) int64 {
+	switch data := payload.(type) {
+	case map[string]interface{}:
+		return int64(firestore.EstimateDocumentSize(collection, docID, data))
+	case []gfirestore.Update:
+		fields := make(map[string]interface{}, len(data))
+		for _, update := range data {
+			fields[update.Path] = update.Value
+		}
+		return int64(firestore.EstimateDocumentSize(collection, docID, fields))
+	}
+	return 0
+}
+
+func dayKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
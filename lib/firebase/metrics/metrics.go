@@ -0,0 +1,188 @@
+// Package metrics publica en formato Prometheus las métricas de la librería: operaciones
+// de Firestore y Auth (contadores e histogramas de latencia por interceptor), aciertos de
+// las cachés, emisión y canje de OTPs y sesiones activas.
+//
+//	metrics.Enable()
+//	http.Handle("/metrics", metrics.Handler())
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// ActiveSessionsInterval frecuencia máxima con la que se recuenta el gauge de sesiones
+// activas (cada recuento es una agregación sobre user_sessions)
+var ActiveSessionsInterval = time.Minute
+
+var (
+	operations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "firebase_operations_total",
+		Help: "Operaciones de Firestore y Auth por operación, colección y resultado.",
+	}, []string{"operation", "collection", "status"})
+
+	latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "firebase_operation_duration_seconds",
+		Help:    "Latencia de las operaciones de Firestore y Auth.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12), // 5ms .. ~10s
+	}, []string{"operation"})
+
+	registry   = prometheus.NewRegistry()
+	enableOnce sync.Once
+
+	// started inicio de cada llamada en curso (Before -> After)
+	started sync.Map
+)
+
+// Enable registra el interceptor que mide las operaciones y los colectores en el registro
+// del paquete. Registrar el interceptor antes que los demás mide también las llamadas que
+// ellos rechazan.
+func Enable() {
+	enableOnce.Do(func() {
+		registry.MustRegister(operations, latency, &libraryCollector{})
+		registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	})
+	firebase.RegisterInterceptor(firebase.Interceptor{Name: "metrics", Before: before, After: after})
+}
+
+// Disable deja de medir operaciones (los colectores siguen publicando lo acumulado)
+func Disable() {
+	firebase.UnregisterInterceptor("metrics")
+}
+
+// Handler retorna el http.Handler de /metrics con el registro del paquete
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Registry registro del paquete, para agregar métricas propias de la aplicación
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+func before(ctx context.Context, call *firebase.Call) error {
+	started.Store(call, time.Now())
+	return nil
+}
+
+func after(ctx context.Context, call *firebase.Call, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	operations.WithLabelValues(call.Operation, collectionLabel(call.Collection), status).Inc()
+	if start, ok := started.LoadAndDelete(call); ok {
+		latency.WithLabelValues(call.Operation).Observe(time.Since(start.(time.Time)).Seconds())
+	}
+}
+
+// collectionLabel último segmento de colección de la ruta ("tenants/t1/orders" ->
+// "orders"), para no crear una serie por tenant o documento padre
+func collectionLabel(path string) string {
+	if path == "" {
+		return ""
+	}
+	segments := strings.Split(path, "/")
+	if len(segments)%2 == 0 {
+		// Ruta de documento: la colección es el penúltimo segmento
+		return segments[len(segments)-2]
+	}
+	return segments[len(segments)-1]
+}
+
+var (
+	eventsDesc = prometheus.NewDesc("firebase_events_total",
+		"Eventos de la librería (emisión de OTP, logins con OTP exitosos y fallidos).", []string{"event"}, nil)
+	cacheHitsDesc = prometheus.NewDesc("firebase_cache_hits_total",
+		"Aciertos de las cachés de la librería.", []string{"cache"}, nil)
+	cacheMissesDesc = prometheus.NewDesc("firebase_cache_misses_total",
+		"Fallos de las cachés de la librería.", []string{"cache"}, nil)
+	cacheHitRatioDesc = prometheus.NewDesc("firebase_cache_hit_ratio",
+		"Proporción de aciertos de cada caché desde el arranque.", []string{"cache"}, nil)
+	otpSuccessRatioDesc = prometheus.NewDesc("firebase_otp_login_success_ratio",
+		"Proporción de logins con OTP exitosos sobre los intentos desde el arranque.", nil, nil)
+	activeSessionsDesc = prometheus.NewDesc("firebase_active_sessions",
+		"Sesiones activas y no expiradas en user_sessions.", nil, nil)
+)
+
+// libraryCollector publica los contadores de firebase.EventCounts y firebase.CacheStats
+// y el gauge de sesiones activas
+type libraryCollector struct {
+	mu             sync.Mutex
+	activeSessions float64
+	countedAt      time.Time
+}
+
+func (c *libraryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- eventsDesc
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheHitRatioDesc
+	ch <- otpSuccessRatioDesc
+	ch <- activeSessionsDesc
+}
+
+func (c *libraryCollector) Collect(ch chan<- prometheus.Metric) {
+	events := firebase.EventCounts()
+	for _, name := range []string{firebase.EventOTPIssued, firebase.EventOTPLoginSuccess, firebase.EventOTPLoginFailure} {
+		if _, ok := events[name]; !ok {
+			events[name] = 0
+		}
+	}
+	for name, count := range events {
+		ch <- prometheus.MustNewConstMetric(eventsDesc, prometheus.CounterValue, float64(count), name)
+	}
+	if attempts := events[firebase.EventOTPLoginSuccess] + events[firebase.EventOTPLoginFailure]; attempts > 0 {
+		ratio := float64(events[firebase.EventOTPLoginSuccess]) / float64(attempts)
+		ch <- prometheus.MustNewConstMetric(otpSuccessRatioDesc, prometheus.GaugeValue, ratio)
+	}
+
+	for name, counter := range firebase.CacheStats() {
+		ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(counter.Hits), name)
+		ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(counter.Misses), name)
+		if total := counter.Hits + counter.Misses; total > 0 {
+			ch <- prometheus.MustNewConstMetric(cacheHitRatioDesc, prometheus.GaugeValue, float64(counter.Hits)/float64(total), name)
+		}
+	}
+
+	if value, ok := c.countActiveSessions(); ok {
+		ch <- prometheus.MustNewConstMetric(activeSessionsDesc, prometheus.GaugeValue, value)
+	}
+}
+
+// countActiveSessions recuenta las sesiones activas como mucho cada ActiveSessionsInterval
+func (c *libraryCollector) countActiveSessions() (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.countedAt.IsZero() && time.Since(c.countedAt) < ActiveSessionsInterval {
+		return c.activeSessions, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Directo al SDK: el recuento no debe aparecer en las métricas de operaciones
+	query := firebase.GetFirestoreClient().Collection("user_sessions").
+		Where("active", "==", true).
+		Where("expires_at", ">", firebase.Now())
+	result, err := query.NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return c.activeSessions, !c.countedAt.IsZero()
+	}
+	count, ok := result["count"].(*firestorepb.Value)
+	if !ok {
+		return c.activeSessions, !c.countedAt.IsZero()
+	}
+	c.activeSessions = float64(count.GetIntegerValue())
+	c.countedAt = time.Now()
+	return c.activeSessions, true
+}
@@ -0,0 +1,248 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"firebase.google.com/go/v4/messaging"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/mailer"
+)
+
+// PreferencesCollection colección con las preferencias de notificación por usuario
+const PreferencesCollection = "notification_preferences"
+
+// Channel canal de entrega de una notificación
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+	ChannelPush  Channel = "push"
+)
+
+// DefaultChannelOrder orden de preferencia cuando el usuario no definió uno
+var DefaultChannelOrder = []Channel{ChannelPush, ChannelEmail, ChannelSMS}
+
+// Preferences preferencias de notificación de un usuario
+type Preferences struct {
+	Enabled     map[Channel]bool `json:"enabled"`
+	Order       []Channel        `json:"order"`
+	PhoneNumber string           `json:"phone_number,omitempty"`
+	Locale      string           `json:"locale,omitempty"` // idioma de las plantillas de correo
+	PushTokens  []string         `json:"push_tokens,omitempty"`
+}
+
+// Message notificación a enviar
+type Message struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// DeliveryAttempt resultado de un intento por un canal
+type DeliveryAttempt struct {
+	Channel Channel `json:"channel"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// DeliveryReport resultado de Send
+type DeliveryReport struct {
+	Delivered bool              `json:"delivered"`
+	Channel   Channel           `json:"channel,omitempty"`
+	Attempts  []DeliveryAttempt `json:"attempts"`
+}
+
+// SMSSender envía mensajes SMS (Twilio, SNS, etc.)
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, body string) error
+}
+
+var (
+	smsMu     sync.RWMutex
+	smsSender SMSSender
+)
+
+// SetSMSSender configura el proveedor de SMS (sin proveedor el canal SMS se omite)
+func SetSMSSender(sender SMSSender) {
+	smsMu.Lock()
+	defer smsMu.Unlock()
+	smsSender = sender
+}
+
+// GetPreferences obtiene las preferencias de un usuario (por defecto todos los canales
+// habilitados en DefaultChannelOrder)
+func GetPreferences(ctx context.Context, uid string) (*Preferences, error) {
+	prefs := &Preferences{
+		Enabled: map[Channel]bool{ChannelEmail: true, ChannelSMS: true, ChannelPush: true},
+		Order:   append([]Channel(nil), DefaultChannelOrder...),
+	}
+
+	doc, err := firestore.GetDocument(ctx, PreferencesCollection, uid)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return prefs, nil
+		}
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+
+	if enabled, ok := doc.Data["enabled"].(map[string]interface{}); ok {
+		for channel, value := range enabled {
+			if on, ok := value.(bool); ok {
+				prefs.Enabled[Channel(channel)] = on
+			}
+		}
+	}
+	if order, ok := doc.Data["order"].([]interface{}); ok && len(order) > 0 {
+		prefs.Order = prefs.Order[:0]
+		for _, channel := range order {
+			if c, ok := channel.(string); ok {
+				prefs.Order = append(prefs.Order, Channel(c))
+			}
+		}
+	}
+	prefs.PhoneNumber, _ = doc.Data["phone_number"].(string)
+	prefs.Locale, _ = doc.Data["locale"].(string)
+	if tokens, ok := doc.Data["push_tokens"].([]interface{}); ok {
+		for _, token := range tokens {
+			if t, ok := token.(string); ok {
+				prefs.PushTokens = append(prefs.PushTokens, t)
+			}
+		}
+	}
+	return prefs, nil
+}
+
+// SetPreferences guarda las preferencias de canales de un usuario (los tokens push se
+// gestionan con RegisterPushToken)
+func SetPreferences(ctx context.Context, uid string, prefs Preferences) error {
+	enabled := make(map[string]interface{}, len(prefs.Enabled))
+	for channel, on := range prefs.Enabled {
+		enabled[string(channel)] = on
+	}
+	order := make([]interface{}, len(prefs.Order))
+	for i, channel := range prefs.Order {
+		order[i] = string(channel)
+	}
+
+	data := map[string]interface{}{"enabled": enabled, "order": order}
+	if prefs.PhoneNumber != "" {
+		data["phone_number"] = prefs.PhoneNumber
+	}
+	if prefs.Locale != "" {
+		data["locale"] = prefs.Locale
+	}
+	if err := firestore.UpdateDocument(ctx, PreferencesCollection, uid, data); err != nil {
+		return fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return nil
+}
+
+// RegisterPushToken agrega un token FCM de dispositivo al usuario
+func RegisterPushToken(ctx context.Context, uid, token string) error {
+	return firestore.UpdateDocument(ctx, PreferencesCollection, uid, map[string]interface{}{
+		"push_tokens": gfirestore.ArrayUnion(token),
+	})
+}
+
+// UnregisterPushToken elimina un token FCM del usuario
+func UnregisterPushToken(ctx context.Context, uid, token string) error {
+	return firestore.UpdateDocument(ctx, PreferencesCollection, uid, map[string]interface{}{
+		"push_tokens": gfirestore.ArrayRemove(token),
+	})
+}
+
+// Send entrega msg al usuario por el primer canal habilitado que funcione, siguiendo el
+// orden de sus preferencias y pasando al siguiente canal si uno falla
+func Send(ctx context.Context, uid string, msg Message) (*DeliveryReport, error) {
+	prefs, err := GetPreferences(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeliveryReport{}
+	for _, channel := range prefs.Order {
+		if !prefs.Enabled[channel] {
+			continue
+		}
+
+		err := deliver(ctx, channel, uid, prefs, msg)
+		attempt := DeliveryAttempt{Channel: channel}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		report.Attempts = append(report.Attempts, attempt)
+		if err == nil {
+			report.Delivered = true
+			report.Channel = channel
+			return report, nil
+		}
+	}
+
+	if len(report.Attempts) == 0 {
+		return report, fmt.Errorf("user '%s' has no enabled notification channels", uid)
+	}
+	failures := make([]string, len(report.Attempts))
+	for i, attempt := range report.Attempts {
+		failures[i] = fmt.Sprintf("%s: %s", attempt.Channel, attempt.Error)
+	}
+	return report, fmt.Errorf("failed to notify user '%s': %s", uid, strings.Join(failures, "; "))
+}
+
+func deliver(ctx context.Context, channel Channel, uid string, prefs *Preferences, msg Message) error {
+	switch channel {
+	case ChannelEmail:
+		user, err := auth.GetUser(ctx, uid)
+		if err != nil {
+			return err
+		}
+		if user.Email == "" {
+			return fmt.Errorf("user has no email")
+		}
+		return mailer.SendTemplate(ctx, user.Email, "notification", prefs.Locale, map[string]interface{}{
+			"Title": msg.Title,
+			"Body":  msg.Body,
+			"Data":  msg.Data,
+		})
+
+	case ChannelSMS:
+		smsMu.RLock()
+		sender := smsSender
+		smsMu.RUnlock()
+		if sender == nil {
+			return fmt.Errorf("no SMS sender configured")
+		}
+		if prefs.PhoneNumber == "" {
+			return fmt.Errorf("user has no phone number")
+		}
+		return sender.SendSMS(ctx, prefs.PhoneNumber, msg.Title+": "+msg.Body)
+
+	case ChannelPush:
+		if len(prefs.PushTokens) == 0 {
+			return fmt.Errorf("user has no push tokens")
+		}
+		client, err := firebase.GetMessagingClient()
+		if err != nil {
+			return err
+		}
+		resp, err := client.SendEachForMulticast(ctx, &messaging.MulticastMessage{
+			Tokens:       prefs.PushTokens,
+			Notification: &messaging.Notification{Title: msg.Title, Body: msg.Body},
+			Data:         msg.Data,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.SuccessCount == 0 {
+			return fmt.Errorf("push delivery failed for all %d devices", resp.FailureCount)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown channel %q", channel)
+}
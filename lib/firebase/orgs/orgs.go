@@ -0,0 +1,170 @@
+package orgs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+const (
+	organizationsCollection = "organizations"
+	membershipsCollection   = "org_memberships"
+)
+
+// Roles predefinidos dentro de una organización
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Organization representa una organización (equipo) de usuarios
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	OwnerUID  string    `json:"owner_uid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Member representa la membresía de un usuario en una organización
+type Member struct {
+	OrgID    string    `json:"org_id"`
+	UID      string    `json:"uid"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// CreateOrganization crea una organización y agrega a ownerUID como propietario
+func CreateOrganization(ctx context.Context, name, ownerUID string) (*Organization, error) {
+	orgID, err := firestore.CreateDocument(ctx, organizationsCollection, map[string]interface{}{
+		"name":      name,
+		"owner_uid": ownerUID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	if err := AddMember(ctx, orgID, ownerUID, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	return GetOrganization(ctx, orgID)
+}
+
+// GetOrganization obtiene una organización por su ID
+func GetOrganization(ctx context.Context, orgID string) (*Organization, error) {
+	doc, err := firestore.GetDocument(ctx, organizationsCollection, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	name, _ := doc.Data["name"].(string)
+	owner, _ := doc.Data["owner_uid"].(string)
+	createdAt, _ := doc.Data["created_at"].(time.Time)
+	return &Organization{ID: doc.ID, Name: name, OwnerUID: owner, CreatedAt: createdAt}, nil
+}
+
+// AddMember agrega (o actualiza el rol de) un usuario en una organización y
+// propaga el rol a los claims de sesión bajo claims.orgs.<orgID>. Una membresía
+// existente solo cambia de rol y conserva su fecha de alta.
+func AddMember(ctx context.Context, orgID, uid, role string) error {
+	id := membershipID(orgID, uid)
+	err := firestore.CreateDocumentIfAbsent(ctx, membershipsCollection, id, map[string]interface{}{
+		"org_id": orgID,
+		"uid":    uid,
+		"role":   role,
+	})
+	if firestore.IsAlreadyExists(err) {
+		err = firestore.UpdateDocument(ctx, membershipsCollection, id, map[string]interface{}{"role": role})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add member '%s' to organization '%s': %w", uid, orgID, err)
+	}
+
+	err = auth.ModifyClaims(ctx, uid, func(claims map[string]interface{}) {
+		orgs := map[string]interface{}{}
+		if current, ok := claims["orgs"].(map[string]interface{}); ok {
+			for id, currentRole := range current {
+				orgs[id] = currentRole
+			}
+		}
+		orgs[orgID] = role
+		claims["orgs"] = orgs
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sync organization claims for user '%s': %w", uid, err)
+	}
+	return nil
+}
+
+// RemoveMember elimina a un usuario de una organización y de sus claims de sesión
+func RemoveMember(ctx context.Context, orgID, uid string) error {
+	if err := firestore.DeleteDocument(ctx, membershipsCollection, membershipID(orgID, uid)); err != nil {
+		return fmt.Errorf("failed to remove member '%s' from organization '%s': %w", uid, orgID, err)
+	}
+
+	err := auth.ModifyClaims(ctx, uid, func(claims map[string]interface{}) {
+		current, ok := claims["orgs"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		orgs := map[string]interface{}{}
+		for id, role := range current {
+			if id != orgID {
+				orgs[id] = role
+			}
+		}
+		claims["orgs"] = orgs
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sync organization claims for user '%s': %w", uid, err)
+	}
+	return nil
+}
+
+// ListMembers lista los miembros de una organización
+func ListMembers(ctx context.Context, orgID string) ([]*Member, error) {
+	return queryMembers(ctx, "org_id", orgID)
+}
+
+// ListUserOrganizations lista las membresías de un usuario
+func ListUserOrganizations(ctx context.Context, uid string) ([]*Member, error) {
+	return queryMembers(ctx, "uid", uid)
+}
+
+// RoleFromClaims obtiene el rol de la organización desde los claims de una sesión
+func RoleFromClaims(claims map[string]interface{}, orgID string) (string, bool) {
+	orgs, ok := claims["orgs"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	role, ok := orgs[orgID].(string)
+	return role, ok
+}
+
+func queryMembers(ctx context.Context, field, value string) ([]*Member, error) {
+	docs, err := firestore.QueryDocuments(ctx, membershipsCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{{Field: field, Operator: "==", Value: value}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memberships: %w", err)
+	}
+
+	members := make([]*Member, 0, len(docs))
+	for _, doc := range docs {
+		member := &Member{}
+		member.OrgID, _ = doc.Data["org_id"].(string)
+		member.UID, _ = doc.Data["uid"].(string)
+		member.Role, _ = doc.Data["role"].(string)
+		member.JoinedAt, _ = doc.Data["created_at"].(time.Time)
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func membershipID(orgID, uid string) string {
+	return orgID + "_" + uid
+}
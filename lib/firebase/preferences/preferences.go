@@ -0,0 +1,223 @@
+// Package preferences guarda las preferencias de cada usuario agrupadas por espacio de
+// nombres ("notifications", "display", ...) en users/{uid}/preferences/{namespace}, con
+// valores por defecto registrados por la aplicación y una caché local por usuario.
+//
+//	preferences.RegisterDefaults("display", map[string]interface{}{"theme": "light", "page_size": 20})
+//	theme, err := preferences.GetPreference[string](ctx, uid, "display", "theme")
+//	err = preferences.SetPreference(ctx, uid, "display", "page_size", 50)
+package preferences
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Collection subcolección de preferencias dentro de cada perfil de usuario
+const Collection = "preferences"
+
+// CacheTTL tiempo que se reutilizan las preferencias leídas de un usuario
+var CacheTTL = time.Minute
+
+var (
+	defaultsMu sync.RWMutex
+	defaults   = map[string]map[string]interface{}{}
+)
+
+type cachedNamespace struct {
+	values    map[string]interface{}
+	expiresAt time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]cachedNamespace{}
+)
+
+// RegisterDefaults registra (o amplía) los valores por defecto de un espacio de nombres
+func RegisterDefaults(namespace string, values map[string]interface{}) {
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+	current, ok := defaults[namespace]
+	if !ok {
+		current = map[string]interface{}{}
+		defaults[namespace] = current
+	}
+	for key, value := range values {
+		current[key] = value
+	}
+}
+
+// Defaults retorna una copia de los valores por defecto del espacio de nombres
+func Defaults(namespace string) map[string]interface{} {
+	defaultsMu.RLock()
+	defer defaultsMu.RUnlock()
+	values := make(map[string]interface{}, len(defaults[namespace]))
+	for key, value := range defaults[namespace] {
+		values[key] = value
+	}
+	return values
+}
+
+// GetPreference retorna la preferencia convertida a T: el valor guardado, el valor por
+// defecto o el valor cero de T si no hay ninguno. Los números se convierten entre int y
+// float cuando no hay pérdida y los mapas se decodifican en structs (vía JSON).
+func GetPreference[T any](ctx context.Context, uid, namespace, key string) (T, error) {
+	var result T
+	values, err := GetNamespace(ctx, uid, namespace)
+	if err != nil {
+		return result, err
+	}
+	value, ok := values[key]
+	if !ok || value == nil {
+		return result, nil
+	}
+	if typed, ok := value.(T); ok {
+		return typed, nil
+	}
+	if err := convert(value, &result); err != nil {
+		return result, fmt.Errorf("preference '%s.%s' of user '%s' is %T, not %T: %w", namespace, key, uid, value, result, err)
+	}
+	return result, nil
+}
+
+// GetNamespace retorna todas las preferencias del espacio de nombres, combinando los
+// valores guardados con los valores por defecto
+func GetNamespace(ctx context.Context, uid, namespace string) (map[string]interface{}, error) {
+	stored, err := load(ctx, uid, namespace)
+	if err != nil {
+		return nil, err
+	}
+	values := Defaults(namespace)
+	for key, value := range stored {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SetPreference guarda una preferencia del usuario
+func SetPreference(ctx context.Context, uid, namespace, key string, value interface{}) error {
+	return SetPreferences(ctx, uid, namespace, map[string]interface{}{key: value})
+}
+
+// SetPreferences guarda varias preferencias del mismo espacio de nombres en una escritura
+// (p. ej. al enviar una pantalla de ajustes); las demás no se modifican
+func SetPreferences(ctx context.Context, uid, namespace string, values map[string]interface{}) error {
+	if err := validate(uid, namespace); err != nil {
+		return err
+	}
+	for key := range values {
+		if key == "" {
+			return fmt.Errorf("preference key cannot be empty")
+		}
+	}
+	_, err := ref(ctx, uid, namespace).Set(ctx, map[string]interface{}{
+		"values":     values,
+		"updated_at": firebase.Now(),
+	}, gfirestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to save preferences '%s' of user '%s': %w", namespace, uid, err)
+	}
+	invalidate(uid, namespace)
+	return nil
+}
+
+// DeletePreference elimina el valor guardado, de modo que vuelve a regir el valor por
+// defecto
+func DeletePreference(ctx context.Context, uid, namespace, key string) error {
+	if err := validate(uid, namespace); err != nil {
+		return err
+	}
+	_, err := ref(ctx, uid, namespace).Update(ctx, []gfirestore.Update{
+		{FieldPath: gfirestore.FieldPath{"values", key}, Value: gfirestore.Delete},
+		{Path: "updated_at", Value: firebase.Now()},
+	})
+	if err != nil && !firestore.IsNotFound(err) {
+		return fmt.Errorf("failed to delete preference '%s.%s' of user '%s': %w", namespace, key, uid, err)
+	}
+	invalidate(uid, namespace)
+	return nil
+}
+
+// ResetNamespace elimina todas las preferencias guardadas del espacio de nombres
+func ResetNamespace(ctx context.Context, uid, namespace string) error {
+	if err := validate(uid, namespace); err != nil {
+		return err
+	}
+	if _, err := ref(ctx, uid, namespace).Delete(ctx); err != nil && !firestore.IsNotFound(err) {
+		return fmt.Errorf("failed to reset preferences '%s' of user '%s': %w", namespace, uid, err)
+	}
+	invalidate(uid, namespace)
+	return nil
+}
+
+// load lee los valores guardados del espacio de nombres (con caché de CacheTTL)
+func load(ctx context.Context, uid, namespace string) (map[string]interface{}, error) {
+	if err := validate(uid, namespace); err != nil {
+		return nil, err
+	}
+	key := cacheKey(uid, namespace)
+	cacheMu.Lock()
+	cached, ok := cache[key]
+	cacheMu.Unlock()
+	hit := ok && firebase.Now().Before(cached.expiresAt)
+	firebase.RecordCacheLookup("preferences", hit)
+	if hit {
+		return cached.values, nil
+	}
+
+	values := map[string]interface{}{}
+	snap, err := ref(ctx, uid, namespace).Get(ctx)
+	if err != nil && !firestore.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to load preferences '%s' of user '%s': %w", namespace, uid, err)
+	}
+	if err == nil {
+		if stored, ok := snap.Data()["values"].(map[string]interface{}); ok {
+			values = stored
+		}
+	}
+
+	cacheMu.Lock()
+	cache[key] = cachedNamespace{values: values, expiresAt: firebase.Now().Add(CacheTTL)}
+	cacheMu.Unlock()
+	return values, nil
+}
+
+func invalidate(uid, namespace string) {
+	cacheMu.Lock()
+	delete(cache, cacheKey(uid, namespace))
+	cacheMu.Unlock()
+}
+
+func ref(ctx context.Context, uid, namespace string) *gfirestore.DocumentRef {
+	return firestore.DocRefContext(ctx, auth.UsersCollection, uid).Collection(Collection).Doc(namespace)
+}
+
+func validate(uid, namespace string) error {
+	if uid == "" || namespace == "" || strings.Contains(uid, "/") || strings.Contains(namespace, "/") {
+		return fmt.Errorf("preferences require a valid uid and namespace")
+	}
+	return nil
+}
+
+func cacheKey(uid, namespace string) string {
+	return uid + "/" + namespace
+}
+
+// convert convierte un valor leído de Firestore al tipo de target pasando por JSON
+func convert(value interface{}, target interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, target)
+}
@@ -0,0 +1,88 @@
+package firebase
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedProxiesMu sync.RWMutex
+	trustedProxies   []*net.IPNet
+)
+
+// SetTrustedProxies define los rangos (CIDR o IP) de los proxies cuya cabecera
+// X-Forwarded-For se acepta. Sin proxies de confianza la IP del cliente es siempre la
+// dirección remota de la conexión.
+func SetTrustedProxies(ranges ...string) error {
+	networks := make([]*net.IPNet, 0, len(ranges))
+	for _, value := range ranges {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy '%s'", value)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy '%s': %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+
+	trustedProxiesMu.Lock()
+	trustedProxies = networks
+	trustedProxiesMu.Unlock()
+	return nil
+}
+
+// RequestIP IP del cliente de la petición. Solo si la conexión viene de un proxy de
+// confianza se recorre X-Forwarded-For de derecha a izquierda y se toma el primer salto
+// que no es de confianza: las entradas de la izquierda las escribe el cliente.
+func RequestIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// Entrada inválida: lo que queda a la izquierda no es confiable
+			break
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		remote = hop
+	}
+	return remote
+}
+
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	trustedProxiesMu.RLock()
+	defer trustedProxiesMu.RUnlock()
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package firebase
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldKind tipo al que se convierte el valor de un filtro de query string
+type FieldKind string
+
+// Tipos de campo de ParseQueryString
+const (
+	KindString FieldKind = "string"
+	KindInt    FieldKind = "int"
+	KindFloat  FieldKind = "float"
+	KindBool   FieldKind = "bool"
+	KindTime   FieldKind = "time" // RFC 3339
+)
+
+// queryStringOperators operadores admitidos en filter[campo][op]
+var queryStringOperators = map[string]Operator{
+	"eq":           OpEqual,
+	"ne":           OpNotEqual,
+	"lt":           OpLessThan,
+	"lte":          OpLessThanOrEqual,
+	"gt":           OpGreaterThan,
+	"gte":          OpGreaterThanOrEqual,
+	"in":           OpIn,
+	"nin":          OpNotIn,
+	"contains":     OpArrayContains,
+	"contains_any": OpArrayContainsAny,
+}
+
+// QueryStringSchema campos que una API expone en su query string. Solo se aceptan filtros
+// sobre Fields y orden sobre Sortable (por defecto, los mismos campos).
+type QueryStringSchema struct {
+	Fields          map[string]FieldKind
+	Sortable        []string
+	DefaultPageSize int // por defecto 20
+	MaxPageSize     int // por defecto 100
+}
+
+// ParseQueryString convierte parámetros al estilo JSON:API en QueryOptions:
+//
+//	filter[status]=active               status == "active"
+//	filter[price][gte]=10               price >= 10 (eq, ne, lt, lte, gt, gte, in, nin, contains, contains_any)
+//	filter[tags][in]=a,b                tags in ["a", "b"]
+//	sort=-created_at,name               created_at desc, name asc
+//	page[size]=20&page[cursor]=...      límite y cursor (también page[offset])
+//
+// Los campos fuera del esquema, los operadores desconocidos y los valores que no
+// corresponden al tipo declarado retornan un *QueryStringError. Los demás parámetros se
+// ignoran.
+func ParseQueryString(values url.Values, schema QueryStringSchema) (QueryOptions, error) {
+	if schema.DefaultPageSize <= 0 {
+		schema.DefaultPageSize = 20
+	}
+	if schema.MaxPageSize <= 0 {
+		schema.MaxPageSize = 100
+	}
+	options := QueryOptions{Limit: schema.DefaultPageSize}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // orden estable de los filtros
+
+	for _, key := range keys {
+		value := values.Get(key)
+		switch {
+		case strings.HasPrefix(key, "filter["):
+			filter, err := parseFilterParam(key, value, schema)
+			if err != nil {
+				return QueryOptions{}, err
+			}
+			options.Filters = append(options.Filters, filter)
+
+		case key == "sort":
+			for _, item := range strings.Split(value, ",") {
+				item = strings.TrimSpace(item)
+				if item == "" {
+					continue
+				}
+				clause := OrderClause{Field: item, Direction: "asc"}
+				if strings.HasPrefix(item, "-") {
+					clause = OrderClause{Field: item[1:], Direction: "desc"}
+				}
+				if !schema.sortable(clause.Field) {
+					return QueryOptions{}, &QueryStringError{Parameter: key, Reason: "field '" + clause.Field + "' is not sortable"}
+				}
+				options.Orders = append(options.Orders, clause)
+			}
+
+		case key == "page[size]":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return QueryOptions{}, &QueryStringError{Parameter: key, Reason: "must be a positive integer"}
+			}
+			if size > schema.MaxPageSize {
+				size = schema.MaxPageSize
+			}
+			options.Limit = size
+
+		case key == "page[offset]":
+			offset, err := strconv.Atoi(value)
+			if err != nil || offset < 0 {
+				return QueryOptions{}, &QueryStringError{Parameter: key, Reason: "must be a non-negative integer"}
+			}
+			options.Offset = offset
+
+		case key == "page[cursor]":
+			options.Cursor = value
+		}
+	}
+	return options, nil
+}
+
+func (s QueryStringSchema) sortable(field string) bool {
+	if s.Sortable == nil {
+		_, ok := s.Fields[field]
+		return ok
+	}
+	for _, allowed := range s.Sortable {
+		if allowed == field {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilterParam interpreta filter[campo] y filter[campo][op]
+func parseFilterParam(key, raw string, schema QueryStringSchema) (QueryFilter, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]"), "][")
+	if len(parts) == 0 || len(parts) > 2 || parts[0] == "" || !strings.HasSuffix(key, "]") {
+		return QueryFilter{}, &QueryStringError{Parameter: key, Reason: "malformed filter"}
+	}
+
+	field := parts[0]
+	kind, ok := schema.Fields[field]
+	if !ok {
+		return QueryFilter{}, &QueryStringError{Parameter: key, Reason: "field '" + field + "' is not filterable"}
+	}
+	op := OpEqual
+	if len(parts) == 2 {
+		if op, ok = queryStringOperators[parts[1]]; !ok {
+			return QueryFilter{}, &QueryStringError{Parameter: key, Reason: "unknown operator '" + parts[1] + "'"}
+		}
+	}
+
+	if op == OpIn || op == OpNotIn || op == OpArrayContainsAny {
+		items := strings.Split(raw, ",")
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := convertQueryValue(strings.TrimSpace(item), kind)
+			if err != nil {
+				return QueryFilter{}, &QueryStringError{Parameter: key, Reason: err.Error()}
+			}
+			values[i] = value
+		}
+		return QueryFilter{Field: field, Operator: op, Value: values}, nil
+	}
+
+	value, err := convertQueryValue(raw, kind)
+	if err != nil {
+		return QueryFilter{}, &QueryStringError{Parameter: key, Reason: err.Error()}
+	}
+	return QueryFilter{Field: field, Operator: op, Value: value}, nil
+}
+
+// convertQueryValue convierte el texto al tipo declarado del campo
+func convertQueryValue(raw string, kind FieldKind) (interface{}, error) {
+	switch kind {
+	case KindInt:
+		return strconv.ParseInt(raw, 10, 64)
+	case KindFloat:
+		return strconv.ParseFloat(raw, 64)
+	case KindBool:
+		return strconv.ParseBool(raw)
+	case KindTime:
+		return time.Parse(time.RFC3339, raw)
+	}
+	return raw, nil
+}
@@ -0,0 +1,120 @@
+package firebase
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testQuerySchema = QueryStringSchema{
+	Fields: map[string]FieldKind{
+		"status":     KindString,
+		"price":      KindFloat,
+		"stock":      KindInt,
+		"active":     KindBool,
+		"created_at": KindTime,
+		"tags":       KindString,
+	},
+}
+
+func TestParseQueryString(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	values, err := url.ParseQuery("filter[status]=active&filter[price][gte]=9.5&filter[stock][lt]=10" +
+		"&filter[active]=true&filter[created_at][gt]=2024-01-02T03:04:05Z&filter[tags][contains_any]=a,%20b" +
+		"&sort=-created_at,,price&page[size]=500&page[offset]=40&page[cursor]=abc&include=author")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := ParseQueryString(values, testQuerySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := QueryOptions{
+		Filters: []QueryFilter{
+			{Field: "active", Operator: OpEqual, Value: true},
+			{Field: "created_at", Operator: OpGreaterThan, Value: created},
+			{Field: "price", Operator: OpGreaterThanOrEqual, Value: 9.5},
+			{Field: "status", Operator: OpEqual, Value: "active"},
+			{Field: "stock", Operator: OpLessThan, Value: int64(10)},
+			{Field: "tags", Operator: OpArrayContainsAny, Value: []interface{}{"a", "b"}},
+		},
+		Orders: []OrderClause{{Field: "created_at", Direction: "desc"}, {Field: "price", Direction: "asc"}},
+		Limit:  100, // page[size] se recorta a MaxPageSize
+		Offset: 40,
+		Cursor: "abc",
+	}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("got  %+v\nwant %+v", options, want)
+	}
+}
+
+func TestParseQueryStringDefaults(t *testing.T) {
+	options, err := ParseQueryString(url.Values{}, testQuerySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(options, QueryOptions{Limit: 20}) {
+		t.Errorf("got %+v", options)
+	}
+
+	schema := testQuerySchema
+	schema.DefaultPageSize, schema.MaxPageSize = 5, 10
+	options, err = ParseQueryString(url.Values{"filter[stock][nin]": {"1,2"}, "page[size]": {"50"}}, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := QueryOptions{Filters: []QueryFilter{{Field: "stock", Operator: OpNotIn, Value: []interface{}{int64(1), int64(2)}}}, Limit: 10}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("got %+v, want %+v", options, want)
+	}
+}
+
+func TestParseQueryStringSortable(t *testing.T) {
+	schema := testQuerySchema
+	schema.Sortable = []string{"rank"}
+	if _, err := ParseQueryString(url.Values{"sort": {"-rank"}}, schema); err != nil {
+		t.Errorf("rank should be sortable: %v", err)
+	}
+	if _, err := ParseQueryString(url.Values{"sort": {"price"}}, schema); err == nil {
+		t.Error("price should not be sortable when Sortable is set")
+	}
+}
+
+func TestParseQueryStringErrors(t *testing.T) {
+	tests := []struct {
+		key, value string
+		reason     string
+	}{
+		{"filter[secret]", "x", "field 'secret' is not filterable"},
+		{"filter[price][like]", "1", "unknown operator 'like'"},
+		{"filter[]", "x", "malformed filter"},
+		{"filter[price][gt][x]", "1", "malformed filter"},
+		{"filter[price", "1", "malformed filter"},
+		{"filter[stock]", "1.5", ""},
+		{"filter[price][in]", "1,x", ""},
+		{"filter[active]", "maybe", ""},
+		{"filter[created_at]", "2024-01-02", ""},
+		{"sort", "-secret", "field 'secret' is not sortable"},
+		{"page[size]", "0", "must be a positive integer"},
+		{"page[size]", "ten", "must be a positive integer"},
+		{"page[offset]", "-1", "must be a non-negative integer"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			_, err := ParseQueryString(url.Values{tc.key: {tc.value}}, testQuerySchema)
+			var queryErr *QueryStringError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("got %v, want QueryStringError", err)
+			}
+			if queryErr.Parameter != tc.key {
+				t.Errorf("got parameter %q, want %q", queryErr.Parameter, tc.key)
+			}
+			if tc.reason != "" && queryErr.Reason != tc.reason {
+				t.Errorf("got reason %q, want %q", queryErr.Reason, tc.reason)
+			}
+		})
+	}
+}
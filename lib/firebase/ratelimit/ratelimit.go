@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Collection colección donde se guardan los contadores. El campo expires_at permite
+// configurar una política TTL de Firestore para limpiar ventanas antiguas.
+const Collection = "rate_limits"
+
+// Result resultado de una comprobación de límite
+type Result struct {
+	Allowed   bool      `json:"allowed"`
+	Count     int64     `json:"count"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// Allow indica si la acción identificada por key puede ejecutarse (máximo limit veces
+// por ventana fija de duración window) y, si es así, la contabiliza
+func Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	result, err := Check(ctx, key, limit, window)
+	if err != nil {
+		return false, err
+	}
+	return result.Allowed, nil
+}
+
+// Check es como Allow pero retorna el detalle del contador. Usa una transacción, por lo
+// que el límite es exacto aun con llamadas concurrentes.
+func Check(ctx context.Context, key string, limit int, window time.Duration) (*Result, error) {
+	if limit <= 0 || window <= 0 {
+		return nil, fmt.Errorf("rate limit requires positive limit and window")
+	}
+
+	windowStart, resetAt := currentWindow(window)
+	ref := firestore.DocRefContext(ctx, Collection, counterID(key, windowStart))
+
+	result := &Result{ResetAt: resetAt}
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		var count int64
+		snap, err := tx.Get(ref)
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+		if snap != nil && snap.Exists() {
+			count, _ = snap.Data()["count"].(int64)
+		}
+
+		result.Count = count
+		if count >= int64(limit) {
+			result.Allowed = false
+			return nil
+		}
+
+		result.Allowed = true
+		result.Count = count + 1
+		return tx.Set(ref, map[string]interface{}{
+			"key_hash":   hashKey(key),
+			"count":      count + 1,
+			"window":     windowStart,
+			"expires_at": resetAt,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	result.Remaining = int64(limit) - result.Count
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	return result, nil
+}
+
+// AllowSharded es una variante para claves con mucho tráfico: incrementa uno de shards
+// contadores sin transacción y suma todos para decidir. Es aproximado: con llamadas
+// concurrentes el límite puede excederse ligeramente.
+func AllowSharded(ctx context.Context, key string, limit int, window time.Duration, shards int) (bool, error) {
+	if shards <= 1 {
+		return Allow(ctx, key, limit, window)
+	}
+
+	client := firebase.GetFirestoreClient()
+	windowStart, resetAt := currentWindow(window)
+	baseID := counterID(key, windowStart)
+
+	refs := make([]*gfirestore.DocumentRef, shards)
+	for i := range refs {
+		refs[i] = client.Collection(Collection).Doc(fmt.Sprintf("%s_%d", baseID, i))
+	}
+
+	snaps, err := client.GetAll(ctx, refs)
+	if err != nil {
+		return false, fmt.Errorf("failed to read rate limit shards: %w", err)
+	}
+	var total int64
+	for _, snap := range snaps {
+		if snap.Exists() {
+			count, _ := snap.Data()["count"].(int64)
+			total += count
+		}
+	}
+	if total >= int64(limit) {
+		return false, nil
+	}
+
+	shard := refs[rand.Intn(shards)]
+	_, err = shard.Set(ctx, map[string]interface{}{
+		"key_hash":   hashKey(key),
+		"count":      gfirestore.Increment(1),
+		"window":     windowStart,
+		"expires_at": resetAt,
+	}, gfirestore.MergeAll)
+	if err != nil {
+		return false, fmt.Errorf("failed to increment rate limit shard: %w", err)
+	}
+	return true, nil
+}
+
+// Reset elimina el contador de la ventana actual de key
+func Reset(ctx context.Context, key string, window time.Duration) error {
+	windowStart, _ := currentWindow(window)
+	return firestore.DeleteDocument(ctx, Collection, counterID(key, windowStart))
+}
+
+func currentWindow(window time.Duration) (time.Time, time.Time) {
+	start := firebase.Now().Truncate(window)
+	return start, start.Add(window)
+}
+
+// counterID deriva el ID del contador sin exponer la clave (puede contener emails o IPs)
+func counterID(key string, windowStart time.Time) string {
+	return fmt.Sprintf("%s_%d", hashKey(key), windowStart.Unix())
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
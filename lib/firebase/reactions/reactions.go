@@ -0,0 +1,165 @@
+package reactions
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Shards número de fragmentos del contador de cada objetivo. Firestore admite ~1
+// escritura por segundo por documento, así que los contadores se reparten en Shards
+// documentos y se suman al leer.
+const Shards = 10
+
+// Summary conteo de reacciones de un objetivo
+type Summary struct {
+	Counts map[string]int64 `json:"counts"`
+	Total  int64            `json:"total"`
+	Mine   string           `json:"mine,omitempty"` // reacción del usuario consultado, si existe
+}
+
+// React registra la reacción del usuario sobre targetPath. Cada usuario tiene como máximo
+// una reacción por objetivo: repetir la misma no cambia nada y una distinta la reemplaza.
+func React(ctx context.Context, session *firebase.SessionInfo, targetPath, reaction string) error {
+	if session == nil || session.UID == "" {
+		return firebase.ErrUnauthenticated
+	}
+	if reaction == "" || strings.ContainsAny(reaction, ".`") {
+		return fmt.Errorf("invalid reaction '%s'", reaction)
+	}
+	target, err := firestore.CheckedDocRef(ctx, targetPath, firebase.OperationUpdate, nil)
+	if err != nil {
+		return err
+	}
+
+	userRef := target.Collection("reactions").Doc(session.UID)
+	err = firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		previous, err := currentReaction(tx, userRef)
+		if err != nil {
+			return err
+		}
+		if previous == reaction {
+			return nil
+		}
+
+		increments := map[string]interface{}{reaction: gfirestore.Increment(1)}
+		if previous != "" {
+			increments[previous] = gfirestore.Increment(-1)
+		}
+		if err := tx.Set(randomShard(target), map[string]interface{}{"counts": increments}, gfirestore.MergeAll); err != nil {
+			return err
+		}
+		return tx.Set(userRef, map[string]interface{}{
+			"uid":        session.UID,
+			"reaction":   reaction,
+			"updated_at": firebase.Now(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to react to '%s': %w", targetPath, err)
+	}
+	return nil
+}
+
+// Unreact elimina la reacción del usuario sobre targetPath (idempotente)
+func Unreact(ctx context.Context, session *firebase.SessionInfo, targetPath string) error {
+	if session == nil || session.UID == "" {
+		return firebase.ErrUnauthenticated
+	}
+	target, err := firestore.CheckedDocRef(ctx, targetPath, firebase.OperationUpdate, nil)
+	if err != nil {
+		return err
+	}
+
+	userRef := target.Collection("reactions").Doc(session.UID)
+	err = firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		previous, err := currentReaction(tx, userRef)
+		if err != nil || previous == "" {
+			return err
+		}
+		if err := tx.Set(randomShard(target), map[string]interface{}{
+			"counts": map[string]interface{}{previous: gfirestore.Increment(-1)},
+		}, gfirestore.MergeAll); err != nil {
+			return err
+		}
+		return tx.Delete(userRef)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove reaction from '%s': %w", targetPath, err)
+	}
+	return nil
+}
+
+// GetReactionSummary suma los fragmentos del contador de targetPath. Si session no es nil
+// incluye la reacción del usuario.
+func GetReactionSummary(ctx context.Context, session *firebase.SessionInfo, targetPath string) (*Summary, error) {
+	target, err := firestore.CheckedDocRef(ctx, targetPath, firebase.OperationRead, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]*gfirestore.DocumentRef, Shards)
+	for i := range refs {
+		refs[i] = shardRef(target, i)
+	}
+	snaps, err := firebase.GetFirestoreClient().GetAll(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reaction counters of '%s': %w", targetPath, err)
+	}
+
+	summary := &Summary{Counts: map[string]int64{}}
+	for _, snap := range snaps {
+		if !snap.Exists() {
+			continue
+		}
+		counts, _ := snap.Data()["counts"].(map[string]interface{})
+		for reaction, value := range counts {
+			if n, ok := value.(int64); ok {
+				summary.Counts[reaction] += n
+				summary.Total += n
+			}
+		}
+	}
+	for reaction, n := range summary.Counts {
+		if n <= 0 {
+			delete(summary.Counts, reaction)
+		}
+	}
+
+	if session != nil && session.UID != "" {
+		snap, err := target.Collection("reactions").Doc(session.UID).Get(ctx)
+		if err != nil && !firestore.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to read user reaction: %w", err)
+		}
+		if err == nil {
+			summary.Mine, _ = snap.Data()["reaction"].(string)
+		}
+	}
+	return summary, nil
+}
+
+func currentReaction(tx *gfirestore.Transaction, ref *gfirestore.DocumentRef) (string, error) {
+	snap, err := tx.Get(ref)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	reaction, _ := snap.Data()["reaction"].(string)
+	return reaction, nil
+}
+
+func randomShard(target *gfirestore.DocumentRef) *gfirestore.DocumentRef {
+	return shardRef(target, rand.Intn(Shards))
+}
+
+func shardRef(target *gfirestore.DocumentRef, shard int) *gfirestore.DocumentRef {
+	return target.Collection("reaction_counters").Doc(fmt.Sprintf("%d", shard))
+}
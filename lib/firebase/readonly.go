@@ -0,0 +1,122 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ReadOnlyConfigPath documento de configuración del modo solo lectura. Campos: global
+// (bool), collections ([]string) y reason (string).
+const ReadOnlyConfigPath = "system_config/read_only"
+
+type readOnlyState struct {
+	global      bool
+	collections map[string]bool
+	reason      string
+}
+
+var (
+	readOnlyMu sync.RWMutex
+	readOnly   = readOnlyState{collections: map[string]bool{}}
+)
+
+// SetReadOnly activa o desactiva el modo solo lectura. Sin colecciones aplica a todas;
+// con colecciones solo a esas (y desactivarlo las quita de la lista).
+func SetReadOnly(enabled bool, reason string, collections ...string) {
+	readOnlyMu.Lock()
+	defer readOnlyMu.Unlock()
+	if len(collections) == 0 {
+		readOnly.global = enabled
+		if !enabled {
+			readOnly.collections = map[string]bool{}
+		}
+	}
+	for _, collection := range collections {
+		if enabled {
+			readOnly.collections[collection] = true
+		} else {
+			delete(readOnly.collections, collection)
+		}
+	}
+	readOnly.reason = reason
+}
+
+// IsReadOnly indica si las escrituras en collection están bloqueadas ("" consulta solo el
+// modo global)
+func IsReadOnly(collection string) bool {
+	readOnlyMu.RLock()
+	defer readOnlyMu.RUnlock()
+	return readOnly.global || (collection != "" && readOnly.collections[rootCollection(collection)])
+}
+
+// CheckWritable retorna un *ReadOnlyError si collection está en modo solo lectura
+func CheckWritable(collection string) error {
+	if !IsReadOnly(collection) {
+		return nil
+	}
+	readOnlyMu.RLock()
+	defer readOnlyMu.RUnlock()
+	return &ReadOnlyError{Collection: collection, Reason: readOnly.reason}
+}
+
+// readOnlyFromEnv aplica READ_ONLY_MODE: "true" bloquea todo; una lista separada por comas
+// bloquea esas colecciones
+func readOnlyFromEnv() {
+	value := strings.TrimSpace(os.Getenv("READ_ONLY_MODE"))
+	switch strings.ToLower(value) {
+	case "", "false", "0":
+		return
+	case "true", "1":
+		SetReadOnly(true, "READ_ONLY_MODE")
+	default:
+		SetReadOnly(true, "READ_ONLY_MODE", strings.Split(value, ",")...)
+	}
+}
+
+// WatchReadOnlyConfig escucha ReadOnlyConfigPath y aplica sus cambios en caliente, de
+// modo que toda una flota de servicios se congela editando un único documento. Bloquea
+// hasta que ctx se cancela.
+func WatchReadOnlyConfig(ctx context.Context) error {
+	snapshots := GetFirestoreClient().Doc(ReadOnlyConfigPath).Snapshots(ctx)
+	defer snapshots.Stop()
+
+	for {
+		snap, err := snapshots.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to watch read-only config: %w", err)
+		}
+
+		state := readOnlyState{collections: map[string]bool{}}
+		if snap.Exists() {
+			data := snap.Data()
+			state.global, _ = data["global"].(bool)
+			state.reason, _ = data["reason"].(string)
+			if collections, ok := data["collections"].([]interface{}); ok {
+				for _, collection := range collections {
+					if name, ok := collection.(string); ok {
+						state.collections[name] = true
+					}
+				}
+			}
+		}
+
+		readOnlyMu.Lock()
+		readOnly = state
+		readOnlyMu.Unlock()
+	}
+}
+
+// rootCollection primer segmento de una ruta de colección ("users/abc/notes" -> "users")
+func rootCollection(collection string) string {
+	collection = LogicalCollection(collection)
+	if i := strings.Index(collection, "/"); i >= 0 {
+		return collection[:i]
+	}
+	return collection
+}
@@ -0,0 +1,95 @@
+// Package realtime reenvía los listeners de Firestore a navegadores por WebSocket o
+// Server-Sent Events, con la sesión de la petición (auth.SessionMiddleware) y
+// suscripciones filtradas por conexión. Los filtros usan la sintaxis de
+// firebase.ParseQueryString, limitada al esquema declarado por colección.
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Options opciones de los handlers
+type Options struct {
+	// Schemas colecciones que se pueden escuchar y los campos filtrables de cada una
+	Schemas map[string]firebase.QueryStringSchema
+	// RequireSession rechaza las conexiones sin sesión (401)
+	RequireSession bool
+	// AllowedOrigins orígenes aceptados para WebSocket (vacío = solo el mismo host)
+	AllowedOrigins []string
+	// MaxSubscriptions por conexión WebSocket (por defecto 20)
+	MaxSubscriptions int
+	// KeepAlive intervalo de los pings (por defecto 25s)
+	KeepAlive time.Duration
+}
+
+// Subscription suscripción de una conexión: un documento (DocumentID) o una colección con
+// filtros en Query (p. ej. "filter[status]=open&sort=-created_at")
+type Subscription struct {
+	ID         string `json:"id"`
+	Collection string `json:"collection"`
+	DocumentID string `json:"document_id,omitempty"`
+	Query      string `json:"query,omitempty"`
+}
+
+// Event mensaje enviado al cliente
+type Event struct {
+	Subscription string                 `json:"subscription"`
+	Type         string                 `json:"type"` // added, modified, removed, error
+	ID           string                 `json:"id,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+func (o *Options) setDefaults() {
+	if o.MaxSubscriptions <= 0 {
+		o.MaxSubscriptions = 20
+	}
+	if o.KeepAlive <= 0 {
+		o.KeepAlive = 25 * time.Second
+	}
+}
+
+// listen valida la suscripción y envía sus cambios hasta que ctx se cancele
+func listen(ctx context.Context, options Options, sub Subscription, send func(Event) error) error {
+	schema, ok := options.Schemas[sub.Collection]
+	if !ok {
+		return fmt.Errorf("collection '%s' is not available for subscriptions", sub.Collection)
+	}
+
+	if sub.DocumentID != "" {
+		return firestore.ListenDocument(ctx, sub.Collection, sub.DocumentID, func(change firebase.DocumentChange) error {
+			return send(eventFor(sub.ID, change))
+		})
+	}
+
+	values, err := url.ParseQuery(sub.Query)
+	if err != nil {
+		return fmt.Errorf("invalid subscription query: %w", err)
+	}
+	query, err := firebase.ParseQueryString(values, schema)
+	if err != nil {
+		return err
+	}
+	return firestore.Listen(ctx, sub.Collection, query, func(changes []firebase.DocumentChange) error {
+		for _, change := range changes {
+			if err := send(eventFor(sub.ID, change)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func eventFor(subscription string, change firebase.DocumentChange) Event {
+	event := Event{Subscription: subscription, Type: change.Type, ID: change.Document.ID}
+	if change.Type != "removed" {
+		event.Data = change.Document.Data
+	}
+	return event
+}
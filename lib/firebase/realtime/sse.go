@@ -0,0 +1,91 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andrescris/firestore/lib/firebase/auth"
+)
+
+// SSEHandler abre una suscripción por petición con Server-Sent Events. La suscripción se
+// define en la URL: ?collection=orders&filter[status]=open o
+// ?collection=orders&document=abc. Cada cambio se envía como evento "change" con un Event
+// en JSON.
+func SSEHandler(options Options) http.Handler {
+	options.setDefaults()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming no soportado", http.StatusInternalServerError)
+			return
+		}
+
+		values := r.URL.Query()
+		sub := Subscription{ID: "sse", Collection: values.Get("collection"), DocumentID: values.Get("document")}
+		values.Del("collection")
+		values.Del("document")
+		sub.Query = values.Encode()
+		if _, ok := options.Schemas[sub.Collection]; !ok {
+			http.Error(w, "colección no disponible", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		var mu sync.Mutex
+		send := func(event Event) error {
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if _, err := fmt.Fprintf(w, "event: change\ndata: %s\n\n", payload); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		}
+
+		// Esperar al keep-alive antes de retornar: no debe escribir después del handler
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(options.KeepAlive)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					mu.Lock()
+					fmt.Fprint(w, ": ping\n\n")
+					flusher.Flush()
+					mu.Unlock()
+				}
+			}
+		}()
+
+		if err := listen(ctx, options, sub, send); err != nil {
+			payload, _ := json.Marshal(Event{Subscription: sub.ID, Type: "error", Error: err.Error()})
+			mu.Lock()
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", payload)
+			flusher.Flush()
+			mu.Unlock()
+		}
+	})
+	return auth.SessionMiddleware(options.RequireSession)(handler)
+}
@@ -0,0 +1,141 @@
+package realtime
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/andrescris/firestore/lib/firebase/auth"
+)
+
+// clientMessage mensaje del cliente: {"action": "subscribe", ...Subscription} o
+// {"action": "unsubscribe", "id": "..."}
+type clientMessage struct {
+	Action string `json:"action"`
+	Subscription
+}
+
+// WebSocketHandler acepta conexiones WebSocket en las que el cliente abre y cierra
+// suscripciones; cada cambio se envía como un Event en JSON
+func WebSocketHandler(options Options) http.Handler {
+	options.setDefaults()
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return originAllowed(r, options.AllowedOrigins) }}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return // Upgrade ya respondió con el error
+		}
+		c := &connection{conn: conn, options: options, subs: map[string]context.CancelFunc{}}
+		c.serve(r.Context())
+	})
+	return auth.SessionMiddleware(options.RequireSession)(handler)
+}
+
+// connection conexión WebSocket con sus suscripciones activas
+type connection struct {
+	conn    *websocket.Conn
+	options Options
+	writeMu sync.Mutex
+	subsMu  sync.Mutex
+	subs    map[string]context.CancelFunc
+}
+
+func (c *connection) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer c.conn.Close()
+
+	go c.keepAlive(ctx)
+	for {
+		var message clientMessage
+		if err := c.conn.ReadJSON(&message); err != nil {
+			return
+		}
+
+		switch message.Action {
+		case "subscribe":
+			c.subscribe(ctx, message.Subscription)
+		case "unsubscribe":
+			c.subsMu.Lock()
+			if stop, ok := c.subs[message.ID]; ok {
+				stop()
+				delete(c.subs, message.ID)
+			}
+			c.subsMu.Unlock()
+		default:
+			c.send(Event{Subscription: message.ID, Type: "error", Error: "unknown action"})
+		}
+	}
+}
+
+func (c *connection) subscribe(ctx context.Context, sub Subscription) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if sub.ID == "" || c.subs[sub.ID] != nil {
+		c.send(Event{Subscription: sub.ID, Type: "error", Error: "subscription id missing or already in use"})
+		return
+	}
+	if len(c.subs) >= c.options.MaxSubscriptions {
+		c.send(Event{Subscription: sub.ID, Type: "error", Error: "too many subscriptions"})
+		return
+	}
+
+	subCtx, stop := context.WithCancel(ctx)
+	c.subs[sub.ID] = stop
+	go func() {
+		if err := listen(subCtx, c.options, sub, c.send); err != nil {
+			c.send(Event{Subscription: sub.ID, Type: "error", Error: err.Error()})
+		}
+		c.subsMu.Lock()
+		delete(c.subs, sub.ID)
+		c.subsMu.Unlock()
+		stop()
+	}()
+}
+
+// send serializa las escrituras: gorilla/websocket no admite escritores concurrentes
+func (c *connection) send(event Event) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.conn.WriteJSON(event)
+}
+
+func (c *connection) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(c.options.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+			c.writeMu.Unlock()
+			if err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// originAllowed acepta el mismo host o los orígenes configurados
+func originAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range allowed {
+		if o == origin || o == "*" {
+			return true
+		}
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
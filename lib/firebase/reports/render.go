@@ -0,0 +1,316 @@
+package reports
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/columnar"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Format formato de salida de un reporte
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// ContentType tipo MIME del formato
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Table resultado tabular de un reporte
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// FromDocuments construye la tabla con las columnas indicadas (admiten rutas con puntos).
+// Sin columnas se infieren de los documentos como en columnar.Infer.
+func FromDocuments(docs []*firebase.Document, columns ...string) *Table {
+	if len(columns) == 0 {
+		ids := make([]string, len(docs))
+		data := make([]map[string]interface{}, len(docs))
+		for i, doc := range docs {
+			ids[i], data[i] = doc.ID, doc.Data
+		}
+		inferred := columnar.Infer(ids, data)
+		table := &Table{Rows: make([][]interface{}, inferred.Rows)}
+		for _, column := range inferred.Columns {
+			table.Columns = append(table.Columns, column.Name)
+		}
+		for i := range table.Rows {
+			table.Rows[i] = make([]interface{}, len(inferred.Columns))
+			for j, column := range inferred.Columns {
+				table.Rows[i][j] = column.Values[i]
+			}
+		}
+		return table
+	}
+
+	table := &Table{Columns: columns, Rows: make([][]interface{}, len(docs))}
+	for i, doc := range docs {
+		row := make([]interface{}, len(columns))
+		for j, column := range columns {
+			if column == columnar.IDColumn {
+				row[j] = doc.ID
+				continue
+			}
+			row[j], _ = doc.Get(column)
+		}
+		table.Rows[i] = row
+	}
+	return table
+}
+
+// FromAggregate construye la tabla de los grupos de firestore.Aggregate: una columna
+// "key" (si hay agrupación), "count" y una por alias
+func FromAggregate(groups []*firebase.AggregateGroup) *Table {
+	aliases := map[string]bool{}
+	grouped := false
+	for _, group := range groups {
+		grouped = grouped || group.Key != nil
+		for alias := range group.Values {
+			aliases[alias] = true
+		}
+	}
+	names := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		if alias != "count" {
+			names = append(names, alias)
+		}
+	}
+	sort.Strings(names)
+
+	table := &Table{}
+	if grouped {
+		table.Columns = append(table.Columns, "key")
+	}
+	table.Columns = append(table.Columns, "count")
+	table.Columns = append(table.Columns, names...)
+	for _, group := range groups {
+		var row []interface{}
+		if grouped {
+			row = append(row, group.Key)
+		}
+		row = append(row, group.Count)
+		for _, alias := range names {
+			if value, ok := group.Values[alias]; ok {
+				row = append(row, value)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+// Render escribe la tabla en el formato indicado
+func Render(w io.Writer, format Format, table *Table) error {
+	switch format {
+	case FormatCSV, "":
+		return RenderCSV(w, table)
+	case FormatXLSX:
+		return RenderXLSX(w, table)
+	}
+	return fmt.Errorf("unsupported report format '%s'", format)
+}
+
+// RenderCSV escribe la tabla como CSV con cabecera
+func RenderCSV(w io.Writer, table *Table) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(table.Columns); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	record := make([]string, len(table.Columns))
+	for _, row := range table.Rows {
+		for i := range record {
+			record[i] = ""
+			if i < len(row) {
+				record[i] = formatCell(row[i])
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write csv: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
+
+// formatCell representa un valor como texto: fechas en RFC 3339 y tipos compuestos como JSON
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case []byte:
+		return string(v)
+	}
+	content, err := json.Marshal(firestore.EncodeJSONValue(value))
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(content)
+}
+
+// RenderXLSX escribe la tabla como libro de Excel (Office Open XML) con una sola hoja.
+// Los números y booleanos se guardan como tales; lo demás como texto.
+func RenderXLSX(w io.Writer, table *Table) error {
+	archive := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("failed to write xlsx: %w", err)
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return fmt.Errorf("failed to write xlsx: %w", err)
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return fmt.Errorf("failed to write xlsx: %w", err)
+	}
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	writeRow := func(index int, row []interface{}, header bool) {
+		fmt.Fprintf(&b, `<row r="%d">`, index)
+		for i, value := range row {
+			ref := columnName(i) + strconv.Itoa(index)
+			style := ""
+			if header {
+				style = ` s="1"`
+			}
+			switch v := value.(type) {
+			case nil:
+				continue
+			case bool:
+				n := 0
+				if v {
+					n = 1
+				}
+				fmt.Fprintf(&b, `<c r="%s" t="b"><v>%d</v></c>`, ref, n)
+			case float64:
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, formatCell(v))
+					continue
+				}
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, formatCell(v))
+			case int, int64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, formatCell(v))
+			default:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">`, ref, style)
+				xml.EscapeText(&b, []byte(sanitizeXML(formatCell(v))))
+				b.WriteString(`</t></is></c>`)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	header := make([]interface{}, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = column
+	}
+	writeRow(1, header, true)
+	for i, row := range table.Rows {
+		writeRow(i+2, row, false)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	if _, err := io.WriteString(sheet, b.String()); err != nil {
+		return fmt.Errorf("failed to write xlsx: %w", err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write xlsx: %w", err)
+	}
+	return nil
+}
+
+// columnName convierte un índice de columna (0 = A) en su nombre de Excel
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// sanitizeXML elimina los caracteres de control que XML 1.0 no admite
+func sanitizeXML(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="Reporte" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// Estilos: 0 normal, 1 negrita (cabecera)
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
@@ -0,0 +1,341 @@
+// Package reports genera reportes periódicos: ejecuta consultas o agregaciones
+// registradas según su calendario, las renderiza en CSV o Excel, sube el resultado a
+// Cloud Storage y envía los enlaces a los destinatarios con el mailer configurado.
+//
+//	reports.Register(reports.Report{
+//		Name:     "pedidos-diarios",
+//		Schedule: reports.Daily(7, 0, nil),
+//		Query: reports.QuerySource("orders", firebase.QueryOptions{
+//			Filters: []firebase.QueryFilter{{Field: "status", Operator: "==", Value: "paid"}},
+//		}, "_id", "customer", "total", "created_at"),
+//		Formats:    []reports.Format{reports.FormatCSV, reports.FormatXLSX},
+//		Recipients: []string{"ops@example.com"},
+//	})
+//	go (&reports.Scheduler{}).Run(ctx)
+//
+// El estado de cada reporte se guarda en RunsCollection; la próxima ejecución se reclama
+// con una transacción, por lo que varias instancias pueden ejecutar el Scheduler sin
+// generar el mismo reporte dos veces.
+package reports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/mailer"
+	"github.com/andrescris/firestore/lib/firebase/storage"
+)
+
+// RunsCollection colección con el estado de ejecución de cada reporte
+const RunsCollection = "report_runs"
+
+// Query produce la tabla de un reporte
+type Query func(ctx context.Context) (*Table, error)
+
+// Report definición de un reporte registrado
+type Report struct {
+	Name          string
+	Title         string   // asunto de los correos (por defecto Name)
+	Schedule      Schedule // nil = solo bajo demanda con RunNow
+	Query         Query
+	Formats       []Format // por defecto CSV
+	Recipients    []string
+	StoragePrefix string        // por defecto "reports/<name>/"
+	LinkTTL       time.Duration // validez de los enlaces firmados (por defecto 7 días)
+}
+
+// File archivo generado por una ejecución
+type File struct {
+	Format Format `json:"format"`
+	Path   string `json:"path"`
+	URL    string `json:"url,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+// Result resultado de una ejecución
+type Result struct {
+	Report      string    `json:"report"`
+	Rows        int       `json:"rows"`
+	Files       []File    `json:"files"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Report{}
+)
+
+// Register registra (o reemplaza) un reporte
+func Register(report Report) error {
+	if report.Name == "" || strings.Contains(report.Name, "/") {
+		return fmt.Errorf("report requires a valid name")
+	}
+	if report.Query == nil {
+		return fmt.Errorf("report '%s' requires a query", report.Name)
+	}
+	for _, format := range report.Formats {
+		if format != FormatCSV && format != FormatXLSX {
+			return fmt.Errorf("unsupported report format '%s'", format)
+		}
+	}
+	registryMu.Lock()
+	registry[report.Name] = report
+	registryMu.Unlock()
+	return nil
+}
+
+// Unregister elimina un reporte del registro
+func Unregister(name string) {
+	registryMu.Lock()
+	delete(registry, name)
+	registryMu.Unlock()
+}
+
+// Registered retorna los reportes registrados ordenados por nombre
+func Registered() []Report {
+	registryMu.RLock()
+	reports := make([]Report, 0, len(registry))
+	for _, report := range registry {
+		reports = append(reports, report)
+	}
+	registryMu.RUnlock()
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports
+}
+
+// QuerySource consulta una colección con QueryDocuments y toma las columnas indicadas
+// (ver FromDocuments)
+func QuerySource(collection string, options firebase.QueryOptions, columns ...string) Query {
+	return func(ctx context.Context) (*Table, error) {
+		docs, err := firestore.QueryDocuments(ctx, collection, options)
+		if err != nil {
+			return nil, err
+		}
+		return FromDocuments(docs, columns...), nil
+	}
+}
+
+// ViewSource ejecuta una vista guardada (firestore.RunView)
+func ViewSource(view string, params map[string]interface{}, columns ...string) Query {
+	return func(ctx context.Context) (*Table, error) {
+		docs, err := firestore.RunView(ctx, view, params)
+		if err != nil {
+			return nil, err
+		}
+		return FromDocuments(docs, columns...), nil
+	}
+}
+
+// AggregateSource ejecuta firestore.Aggregate (ver FromAggregate)
+func AggregateSource(collection string, filters []firebase.QueryFilter, groupBy string, aggregations ...firebase.Aggregation) Query {
+	return func(ctx context.Context) (*Table, error) {
+		groups, err := firestore.Aggregate(ctx, collection, filters, groupBy, aggregations...)
+		if err != nil {
+			return nil, err
+		}
+		return FromAggregate(groups), nil
+	}
+}
+
+// RunNow genera el reporte inmediatamente, fuera de su calendario
+func RunNow(ctx context.Context, name string) (*Result, error) {
+	registryMu.RLock()
+	report, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("report '%s' is not registered", name)
+	}
+	return generate(ctx, report)
+}
+
+// Scheduler ejecuta los reportes registrados cuando les corresponde
+type Scheduler struct {
+	Interval time.Duration // frecuencia de revisión (por defecto 1 minuto)
+}
+
+// Run revisa los calendarios cada Interval hasta que ctx se cancela
+func (s *Scheduler) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Tick(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Tick genera los reportes vencidos que esta instancia logre reclamar y retorna sus
+// resultados. Los errores de cada reporte se registran en el log y en RunsCollection.
+func (s *Scheduler) Tick(ctx context.Context) []*Result {
+	var results []*Result
+	for _, report := range Registered() {
+		if report.Schedule == nil {
+			continue
+		}
+		due, err := claim(ctx, report)
+		if err != nil {
+			log.Printf("⚠️  Error scheduling report '%s': %v", report.Name, err)
+			continue
+		}
+		if !due {
+			continue
+		}
+		result, err := generate(ctx, report)
+		if err != nil {
+			log.Printf("⚠️  Error generating report '%s': %v", report.Name, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// claim reclama la ejecución vencida de un reporte adelantando next_run en una
+// transacción. Un reporte sin estado previo solo programa su primera ejecución.
+func claim(ctx context.Context, report Report) (bool, error) {
+	ref := firestore.DocRefContext(ctx, RunsCollection, report.Name)
+	now := firebase.Now()
+	due := false
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		due = false
+		snap, err := tx.Get(ref)
+		if err != nil && !firestore.IsNotFound(err) {
+			return err
+		}
+		if snap != nil && snap.Exists() {
+			next, ok := snap.Data()["next_run"].(time.Time)
+			if ok && now.Before(next) {
+				return nil
+			}
+			due = true
+		}
+		return tx.Set(ref, map[string]interface{}{
+			"next_run":   report.Schedule.Next(now),
+			"claimed_at": now,
+		}, gfirestore.MergeAll)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to claim report run: %w", err)
+	}
+	return due, nil
+}
+
+// generate ejecuta la consulta, sube cada formato y notifica a los destinatarios
+func generate(ctx context.Context, report Report) (*Result, error) {
+	result, err := render(ctx, report)
+	status := map[string]interface{}{"last_run": firebase.Now(), "last_status": "ok", "last_error": ""}
+	if err != nil {
+		status["last_status"], status["last_error"] = "failed", err.Error()
+	} else {
+		status["last_rows"] = result.Rows
+		paths := make([]string, len(result.Files))
+		for i, file := range result.Files {
+			paths[i] = file.Path
+		}
+		status["last_files"] = paths
+	}
+	if _, setErr := firestore.DocRefContext(ctx, RunsCollection, report.Name).Set(ctx, status, gfirestore.MergeAll); setErr != nil {
+		log.Printf("⚠️  Error recording report run '%s': %v", report.Name, setErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := notify(ctx, report, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func render(ctx context.Context, report Report) (*Result, error) {
+	table, err := report.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run report '%s': %w", report.Name, err)
+	}
+	formats := report.Formats
+	if len(formats) == 0 {
+		formats = []Format{FormatCSV}
+	}
+	prefix := report.StoragePrefix
+	if prefix == "" {
+		prefix = "reports/" + report.Name + "/"
+	}
+	ttl := report.LinkTTL
+	if ttl <= 0 || ttl > 7*24*time.Hour {
+		ttl = 7 * 24 * time.Hour
+	}
+
+	now := firebase.Now().UTC()
+	result := &Result{Report: report.Name, Rows: len(table.Rows), GeneratedAt: now}
+	for _, format := range formats {
+		var buf bytes.Buffer
+		if err := Render(&buf, format, table); err != nil {
+			return nil, err
+		}
+		path := fmt.Sprintf("%s%s-%s.%s", prefix, report.Name, now.Format("20060102T150405Z"), format)
+		size := int64(buf.Len())
+		if _, err := storage.UploadFile(ctx, path, format.ContentType(), &buf, map[string]string{"report": report.Name}); err != nil {
+			return nil, err
+		}
+		file := File{Format: format, Path: path, Size: size}
+		if url, err := storage.DownloadURL(path, ttl); err == nil {
+			file.URL = url
+		} else {
+			log.Printf("⚠️  Error signing report URL for '%s': %v", path, err)
+		}
+		result.Files = append(result.Files, file)
+	}
+	return result, nil
+}
+
+// notify envía a cada destinatario los enlaces de los archivos generados
+func notify(ctx context.Context, report Report, result *Result) error {
+	if len(report.Recipients) == 0 {
+		return nil
+	}
+	title := report.Title
+	if title == "" {
+		title = report.Name
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "Reporte \"%s\" generado el %s con %d filas.\n\n", title,
+		result.GeneratedAt.Format("2006-01-02 15:04 MST"), result.Rows)
+	for _, file := range result.Files {
+		link := file.URL
+		if link == "" {
+			link = fmt.Sprintf("gs://%s/%s", firebase.GetStorageBucketName(), file.Path)
+		}
+		fmt.Fprintf(&text, "%s: %s\n", strings.ToUpper(string(file.Format)), link)
+	}
+
+	var errs []string
+	for _, to := range report.Recipients {
+		if err := mailer.Send(ctx, mailer.Message{
+			To:      to,
+			Subject: "Reporte: " + title,
+			Text:    text.String(),
+		}); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to notify report recipients: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package reports
+
+import "time"
+
+// Schedule calcula la próxima ejecución de un reporte
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// ScheduleFunc adapta una función a Schedule
+type ScheduleFunc func(after time.Time) time.Time
+
+// Next llama a la función
+func (f ScheduleFunc) Next(after time.Time) time.Time {
+	return f(after)
+}
+
+// Every ejecuta el reporte cada interval, alineado a múltiplos de interval en UTC
+func Every(interval time.Duration) Schedule {
+	return ScheduleFunc(func(after time.Time) time.Time {
+		return after.UTC().Truncate(interval).Add(interval)
+	})
+}
+
+// Daily ejecuta el reporte todos los días a la hora indicada (loc nil = UTC)
+func Daily(hour, minute int, loc *time.Location) Schedule {
+	return ScheduleFunc(func(after time.Time) time.Time {
+		after = after.In(location(loc))
+		next := time.Date(after.Year(), after.Month(), after.Day(), hour, minute, 0, 0, after.Location())
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	})
+}
+
+// Weekly ejecuta el reporte una vez por semana el día y la hora indicados
+func Weekly(weekday time.Weekday, hour, minute int, loc *time.Location) Schedule {
+	return ScheduleFunc(func(after time.Time) time.Time {
+		after = after.In(location(loc))
+		next := time.Date(after.Year(), after.Month(), after.Day(), hour, minute, 0, 0, after.Location())
+		next = next.AddDate(0, 0, (int(weekday)-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	})
+}
+
+// Monthly ejecuta el reporte una vez al mes el día indicado (1-28) a la hora indicada
+func Monthly(day, hour, minute int, loc *time.Location) Schedule {
+	if day < 1 {
+		day = 1
+	} else if day > 28 {
+		day = 28
+	}
+	return ScheduleFunc(func(after time.Time) time.Time {
+		after = after.In(location(loc))
+		next := time.Date(after.Year(), after.Month(), day, hour, minute, 0, 0, after.Location())
+		if !next.After(after) {
+			next = next.AddDate(0, 1, 0)
+		}
+		return next
+	})
+}
+
+func location(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
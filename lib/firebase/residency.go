@@ -0,0 +1,129 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Region base de datos de Firestore a la que se enrutan ciertos datos (p. ej. "eu" en un
+// proyecto o base de datos europea)
+type Region struct {
+	Name       string
+	ProjectID  string
+	DatabaseID string
+	client     *firestore.Client
+}
+
+// RegionResolver elige la región de una operación a partir del contexto (p. ej. según el
+// tenant); collection es "" en transacciones
+type RegionResolver func(ctx context.Context, collection string) (string, bool)
+
+type regionContextKey struct{}
+
+var (
+	regionsMu        sync.RWMutex
+	regions          = map[string]*Region{}
+	collectionRoutes = map[string]string{}
+	regionResolver   RegionResolver
+)
+
+// RegisterRegion crea el cliente de una región con las credenciales de la inicialización.
+// databaseID vacío usa la base de datos "(default)" del proyecto.
+func RegisterRegion(ctx context.Context, name, projectID, databaseID string) error {
+	if databaseID == "" {
+		databaseID = firestore.DefaultDatabaseID
+	}
+	client, err := firestore.NewClientWithDatabase(ctx, projectID, databaseID, GetClientOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client for region '%s': %w", name, err)
+	}
+
+	regionsMu.Lock()
+	previous := regions[name]
+	regions[name] = &Region{Name: name, ProjectID: projectID, DatabaseID: databaseID, client: client}
+	regionsMu.Unlock()
+	if previous != nil {
+		previous.client.Close()
+	}
+	return nil
+}
+
+// RouteCollection envía todas las operaciones de una colección a una región ("" quita la ruta)
+func RouteCollection(collection, region string) {
+	regionsMu.Lock()
+	defer regionsMu.Unlock()
+	if region == "" {
+		delete(collectionRoutes, collection)
+		return
+	}
+	collectionRoutes[collection] = region
+}
+
+// SetRegionResolver define cómo elegir la región desde el contexto cuando no hay una
+// explícita ni una ruta por colección (tenancy.EnableResidency lo usa con el tenant)
+func SetRegionResolver(resolver RegionResolver) {
+	regionsMu.Lock()
+	defer regionsMu.Unlock()
+	regionResolver = resolver
+}
+
+// WithRegion retorna un contexto cuyas operaciones van a la región indicada
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionContextKey{}, region)
+}
+
+// RegionFor región de una operación: la explícita del contexto, la ruta de la colección,
+// la del resolver o "" (base de datos por defecto)
+func RegionFor(ctx context.Context, collection string) string {
+	if region, ok := ctx.Value(regionContextKey{}).(string); ok {
+		return region
+	}
+
+	regionsMu.RLock()
+	region, routed := collectionRoutes[rootCollection(collection)]
+	resolver := regionResolver
+	regionsMu.RUnlock()
+	if routed {
+		return region
+	}
+	if resolver != nil {
+		if region, ok := resolver(ctx, collection); ok {
+			return region
+		}
+	}
+	return ""
+}
+
+// FirestoreClientFor retorna el cliente de la región que corresponde a la operación. Una
+// región sin registrar provoca panic, igual que usar el cliente sin inicializar: es un
+// error de configuración que no debe escribir datos fuera de su región.
+func FirestoreClientFor(ctx context.Context, collection string) *firestore.Client {
+	region := RegionFor(ctx, collection)
+	if region == "" {
+		return GetFirestoreClient()
+	}
+
+	regionsMu.RLock()
+	r, ok := regions[region]
+	regionsMu.RUnlock()
+	if !ok {
+		panic(fmt.Sprintf("Firestore region '%s' not registered. Call RegisterRegion first.", region))
+	}
+	return r.client
+}
+
+// closeRegions cierra los clientes de las regiones registradas
+func closeRegions() error {
+	regionsMu.Lock()
+	defer regionsMu.Unlock()
+	for name, r := range regions {
+		if err := r.client.Close(); err != nil {
+			return fmt.Errorf("firestore close error for region '%s': %w", name, err)
+		}
+		delete(regions, name)
+	}
+	return nil
+}
@@ -0,0 +1,408 @@
+// Package signing firma y verifica peticiones HTTP entre servicios internos con HMAC
+// SHA-256. Cada cliente tiene uno o más secretos compartidos guardados en Firestore;
+// RotateKey emite uno nuevo y mantiene los anteriores válidos durante un periodo de
+// gracia para que los servicios cambien de secreto sin cortes.
+//
+//	client, secret, err := signing.CreateClient(ctx, "billing", "Servicio de facturación")
+//	http.Client{Transport: &signing.Transport{ClientID: "billing", KeyID: client.PrimaryKey, Secret: secret}}
+//	mux.Handle("/internal/", signing.Middleware(internalAPI))
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// ClientsCollection clientes autorizados y sus secretos (el documento es el ID del cliente)
+const ClientsCollection = "signing_clients"
+
+// Cabeceras de una petición firmada. X-Signature tiene la forma
+// "t=<unix>,k=<id del secreto>,v1=<hex>".
+const (
+	HeaderClient    = "X-Signature-Client"
+	HeaderSignature = "X-Signature"
+)
+
+var (
+	// Tolerance diferencia máxima entre el timestamp firmado y el reloj local
+	Tolerance = 5 * time.Minute
+	// MaxBodyBytes tamaño máximo del cuerpo que se lee para verificar la firma
+	MaxBodyBytes int64 = 10 << 20
+	// CacheTTL tiempo que se reutilizan los secretos leídos de un cliente
+	CacheTTL = time.Minute
+)
+
+// Key secreto de un cliente. ExpiresAt se fija al rotarlo; vacío = vigente.
+type Key struct {
+	ID        string    `json:"id"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// Client servicio autorizado a llamar a la capa HTTP
+type Client struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Disabled   bool      `json:"disabled"`
+	PrimaryKey string    `json:"primary_key"` // secreto con el que debe firmar
+	Keys       []Key     `json:"keys"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type cachedClient struct {
+	client    *Client
+	expiresAt time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]cachedClient{}
+)
+
+// CreateClient registra un cliente y retorna su primer secreto (no se vuelve a mostrar)
+func CreateClient(ctx context.Context, id, name string) (*Client, string, error) {
+	if id == "" || strings.Contains(id, "/") {
+		return nil, "", fmt.Errorf("signing client requires a valid id")
+	}
+	key, err := newKey()
+	if err != nil {
+		return nil, "", err
+	}
+	client := &Client{ID: id, Name: name, PrimaryKey: key.ID, Keys: []Key{key}, CreatedAt: firebase.Now()}
+	_, err = firebase.GetFirestoreClient().Collection(ClientsCollection).Doc(id).Create(ctx, map[string]interface{}{
+		"name":        name,
+		"disabled":    false,
+		"primary_key": key.ID,
+		"keys":        encodeKeys(client.Keys),
+		"created_at":  client.CreatedAt,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create signing client '%s': %w", id, err)
+	}
+	return client, key.Secret, nil
+}
+
+// RotateKey emite un secreto nuevo que pasa a ser el principal. Los secretos vigentes
+// siguen aceptándose durante grace; los ya vencidos se eliminan.
+func RotateKey(ctx context.Context, id string, grace time.Duration) (*Key, error) {
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	ref := firestore.DocRefContext(ctx, ClientsCollection, id)
+	err = firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		now := firebase.Now()
+		keys := []Key{key}
+		for _, existing := range clientFromDocument(&firebase.Document{ID: id, Data: snap.Data()}).Keys {
+			if existing.ExpiresAt.IsZero() {
+				existing.ExpiresAt = now.Add(grace)
+			}
+			if existing.ExpiresAt.After(now) {
+				keys = append(keys, existing)
+			}
+		}
+		return tx.Update(ref, []gfirestore.Update{
+			{Path: "primary_key", Value: key.ID},
+			{Path: "keys", Value: encodeKeys(keys)},
+			{Path: "rotated_at", Value: now},
+		})
+	})
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, &firebase.DocumentNotFoundError{Collection: ClientsCollection, DocumentID: id}
+		}
+		return nil, fmt.Errorf("failed to rotate key of signing client '%s': %w", id, err)
+	}
+	invalidate(id)
+	return &key, nil
+}
+
+// RevokeKey elimina de inmediato un secreto del cliente (no puede ser el principal)
+func RevokeKey(ctx context.Context, id, keyID string) error {
+	ref := firestore.DocRefContext(ctx, ClientsCollection, id)
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		client := clientFromDocument(&firebase.Document{ID: id, Data: snap.Data()})
+		if client.PrimaryKey == keyID {
+			return fmt.Errorf("cannot revoke the primary key, rotate it first")
+		}
+		keys := make([]Key, 0, len(client.Keys))
+		for _, key := range client.Keys {
+			if key.ID != keyID {
+				keys = append(keys, key)
+			}
+		}
+		return tx.Update(ref, []gfirestore.Update{{Path: "keys", Value: encodeKeys(keys)}})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke key '%s' of signing client '%s': %w", keyID, id, err)
+	}
+	invalidate(id)
+	return nil
+}
+
+// SetDisabled habilita o deshabilita el cliente sin borrar sus secretos
+func SetDisabled(ctx context.Context, id string, disabled bool) error {
+	if err := firestore.UpdateDocument(ctx, ClientsCollection, id, map[string]interface{}{"disabled": disabled}); err != nil {
+		return fmt.Errorf("failed to update signing client '%s': %w", id, err)
+	}
+	invalidate(id)
+	return nil
+}
+
+// GetClient obtiene un cliente y sus secretos (con caché de CacheTTL)
+func GetClient(ctx context.Context, id string) (*Client, error) {
+	cacheMu.Lock()
+	cached, ok := cache[id]
+	cacheMu.Unlock()
+	hit := ok && firebase.Now().Before(cached.expiresAt)
+	firebase.RecordCacheLookup("signing_clients", hit)
+	if hit {
+		return cached.client, nil
+	}
+
+	snap, err := firebase.GetFirestoreClient().Collection(ClientsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, &firebase.DocumentNotFoundError{Collection: ClientsCollection, DocumentID: id}
+		}
+		return nil, fmt.Errorf("failed to get signing client '%s': %w", id, err)
+	}
+	client := clientFromDocument(&firebase.Document{ID: id, Data: snap.Data()})
+
+	cacheMu.Lock()
+	cache[id] = cachedClient{client: client, expiresAt: firebase.Now().Add(CacheTTL)}
+	cacheMu.Unlock()
+	return client, nil
+}
+
+// Sign calcula la cabecera X-Signature de una petición. Se firman el timestamp, el
+// método, la ruta con su query string y el SHA-256 del cuerpo.
+func Sign(keyID, secret string, at time.Time, method, requestURI string, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",k=" + keyID + ",v1=" + hex.EncodeToString(mac(secret, timestamp, method, requestURI, body))
+}
+
+// SignRequest agrega las cabeceras de firma a la petición (lee el cuerpo y lo repone)
+func SignRequest(req *http.Request, clientID, keyID, secret string) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HeaderClient, clientID)
+	req.Header.Set(HeaderSignature, Sign(keyID, secret, firebase.Now(), req.Method, req.URL.RequestURI(), body))
+	return nil
+}
+
+// Transport http.RoundTripper que firma cada petición saliente
+type Transport struct {
+	ClientID string
+	KeyID    string
+	Secret   string
+	Base     http.RoundTripper // por defecto http.DefaultTransport
+}
+
+// RoundTrip firma una copia de la petición y la envía con Base
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+	if err := SignRequest(signed, t.ClientID, t.KeyID, t.Secret); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(signed)
+}
+
+// VerifyRequest verifica la firma de la petición con los secretos vigentes del cliente
+// indicado en X-Signature-Client. Retorna *firebase.RequestSignatureError si no es
+// válida. El cuerpo queda disponible para el handler.
+func VerifyRequest(ctx context.Context, req *http.Request) (*Client, error) {
+	clientID := req.Header.Get(HeaderClient)
+	var timestamp, keyID string
+	var signatures []string
+	for _, part := range strings.Split(req.Header.Get(HeaderSignature), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "t":
+			timestamp = value
+		case "k":
+			keyID = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if clientID == "" || timestamp == "" || keyID == "" || len(signatures) == 0 {
+		return nil, &firebase.RequestSignatureError{ClientID: clientID, Reason: "malformed signature headers"}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, &firebase.RequestSignatureError{ClientID: clientID, Reason: "invalid timestamp"}
+	}
+	now := firebase.Now()
+	if age := now.Sub(time.Unix(seconds, 0)); age > Tolerance || age < -Tolerance {
+		return nil, &firebase.RequestSignatureError{ClientID: clientID, Reason: "timestamp outside tolerance"}
+	}
+
+	client, err := GetClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, firebase.ErrDocumentNotFound) {
+			return nil, &firebase.RequestSignatureError{ClientID: clientID, Reason: "unknown client"}
+		}
+		return nil, err
+	}
+	if client.Disabled {
+		return nil, &firebase.RequestSignatureError{ClientID: clientID, Reason: "client disabled"}
+	}
+	var secret string
+	for _, key := range client.Keys {
+		if key.ID == keyID && (key.ExpiresAt.IsZero() || now.Before(key.ExpiresAt)) {
+			secret = key.Secret
+		}
+	}
+	if secret == "" {
+		return nil, &firebase.RequestSignatureError{ClientID: clientID, Reason: "unknown or expired key"}
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	expected := mac(secret, timestamp, req.Method, req.URL.RequestURI(), body)
+	for _, signature := range signatures {
+		decoded, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return client, nil
+		}
+	}
+	return nil, &firebase.RequestSignatureError{ClientID: clientID, Reason: "no matching signature"}
+}
+
+type clientContextKey struct{}
+
+// ClientFromContext obtiene el cliente verificado por Middleware (si existe)
+func ClientFromContext(ctx context.Context) (*Client, bool) {
+	client, ok := ctx.Value(clientContextKey{}).(*Client)
+	return client, ok && client != nil
+}
+
+// Middleware rechaza con 401 las peticiones sin una firma válida y guarda el cliente
+// verificado en el contexto
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, err := VerifyRequest(r.Context(), r)
+		if err != nil {
+			if errors.Is(err, firebase.ErrRequestSignature) {
+				http.Error(w, "firma de la petición inválida", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "no se pudo verificar la firma de la petición", http.StatusInternalServerError)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientContextKey{}, client)))
+	})
+}
+
+func mac(secret, timestamp, method, requestURI string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "\n" + strings.ToUpper(method) + "\n" + requestURI + "\n" + hex.EncodeToString(bodyHash[:])))
+	return h.Sum(nil)
+}
+
+// readBody lee el cuerpo (hasta MaxBodyBytes) y lo repone en la petición
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, MaxBodyBytes+1))
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(body)) > MaxBodyBytes {
+		return nil, fmt.Errorf("request body exceeds %d bytes", MaxBodyBytes)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func newKey() (Key, error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return Key{}, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return Key{ID: hex.EncodeToString(id), Secret: hex.EncodeToString(secret), CreatedAt: firebase.Now()}, nil
+}
+
+func encodeKeys(keys []Key) []interface{} {
+	encoded := make([]interface{}, len(keys))
+	for i, key := range keys {
+		entry := map[string]interface{}{"id": key.ID, "secret": key.Secret, "created_at": key.CreatedAt}
+		if !key.ExpiresAt.IsZero() {
+			entry["expires_at"] = key.ExpiresAt
+		}
+		encoded[i] = entry
+	}
+	return encoded
+}
+
+func clientFromDocument(doc *firebase.Document) *Client {
+	client := &Client{ID: doc.ID}
+	client.Name, _ = doc.GetString("name")
+	client.Disabled, _ = doc.GetBool("disabled")
+	client.PrimaryKey, _ = doc.GetString("primary_key")
+	client.CreatedAt, _ = doc.GetTime("created_at")
+	value, _ := doc.Get("keys")
+	items, _ := value.([]interface{})
+	for _, item := range items {
+		data, _ := item.(map[string]interface{})
+		entry := &firebase.Document{Data: data}
+		key := Key{}
+		key.ID, _ = entry.GetString("id")
+		key.Secret, _ = entry.GetString("secret")
+		key.CreatedAt, _ = entry.GetTime("created_at")
+		key.ExpiresAt, _ = entry.GetTime("expires_at")
+		client.Keys = append(client.Keys, key)
+	}
+	return client
+}
+
+func invalidate(id string) {
+	cacheMu.Lock()
+	delete(cache, id)
+	cacheMu.Unlock()
+}
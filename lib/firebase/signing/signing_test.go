@@ -0,0 +1,184 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// withClients congela el reloj en now y deja los clientes en la caché para que
+// VerifyRequest no consulte Firestore
+func withClients(t *testing.T, now time.Time, clients ...*Client) {
+	t.Helper()
+	firebase.SetClock(firebase.NewFakeClock(now))
+	cacheMu.Lock()
+	for _, client := range clients {
+		cache[client.ID] = cachedClient{client: client, expiresAt: now.Add(time.Hour)}
+	}
+	cacheMu.Unlock()
+	t.Cleanup(func() {
+		firebase.SetClock(nil)
+		for _, client := range clients {
+			invalidate(client.ID)
+		}
+	})
+}
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	header := Sign("k1", "secret", at, "post", "/v1/items?a=1", []byte(`{"x":1}`))
+	if !strings.HasPrefix(header, "t=1700000000,k=k1,v1=") || len(header) != len("t=1700000000,k=k1,v1=")+64 {
+		t.Fatalf("unexpected header %q", header)
+	}
+	if header != Sign("k1", "secret", at, "POST", "/v1/items?a=1", []byte(`{"x":1}`)) {
+		t.Error("method case should not change the signature")
+	}
+	for name, other := range map[string]string{
+		"secret": Sign("k1", "other", at, "POST", "/v1/items?a=1", []byte(`{"x":1}`)),
+		"time":   Sign("k1", "secret", at.Add(time.Second), "POST", "/v1/items?a=1", []byte(`{"x":1}`)),
+		"method": Sign("k1", "secret", at, "PUT", "/v1/items?a=1", []byte(`{"x":1}`)),
+		"uri":    Sign("k1", "secret", at, "POST", "/v1/items?a=2", []byte(`{"x":1}`)),
+		"body":   Sign("k1", "secret", at, "POST", "/v1/items?a=1", []byte(`{"x":2}`)),
+	} {
+		if signature(other) == signature(header) {
+			t.Errorf("changing the %s did not change the signature", name)
+		}
+	}
+}
+
+func signature(header string) string {
+	_, value, _ := strings.Cut(header, ",v1=")
+	return value
+}
+
+func TestVerifyRequest(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	active := &Client{ID: "billing", PrimaryKey: "new", Keys: []Key{
+		{ID: "new", Secret: "new-secret"},
+		{ID: "old", Secret: "old-secret", ExpiresAt: now.Add(time.Hour)},
+		{ID: "gone", Secret: "gone-secret", ExpiresAt: now.Add(-time.Second)},
+	}}
+	disabled := &Client{ID: "legacy", Disabled: true, PrimaryKey: "k", Keys: []Key{{ID: "k", Secret: "s"}}}
+	withClients(t, now, active, disabled)
+
+	const body = `{"amount":100}`
+	signed := func(clientID, keyID, secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/internal/charges?currency=PEN", strings.NewReader(body))
+		if err := SignRequest(req, clientID, keyID, secret); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		reason string // vacío si la firma es válida
+	}{
+		{"primary key", func() *http.Request { return signed("billing", "new", "new-secret") }, ""},
+		{"rotated key in grace period", func() *http.Request { return signed("billing", "old", "old-secret") }, ""},
+		{"expired key", func() *http.Request { return signed("billing", "gone", "gone-secret") }, "unknown or expired key"},
+		{"unknown key", func() *http.Request { return signed("billing", "nope", "new-secret") }, "unknown or expired key"},
+		{"wrong secret", func() *http.Request { return signed("billing", "new", "old-secret") }, "no matching signature"},
+		{"disabled client", func() *http.Request { return signed("legacy", "k", "s") }, "client disabled"},
+		{"tampered body", func() *http.Request {
+			req := signed("billing", "new", "new-secret")
+			req.Body = io.NopCloser(strings.NewReader(`{"amount":1}`))
+			return req
+		}, "no matching signature"},
+		{"tampered query", func() *http.Request {
+			req := signed("billing", "new", "new-secret")
+			req.URL.RawQuery = "currency=USD"
+			return req
+		}, "no matching signature"},
+		{"other method", func() *http.Request {
+			req := signed("billing", "new", "new-secret")
+			req.Method = http.MethodPut
+			return req
+		}, "no matching signature"},
+		{"missing client", func() *http.Request {
+			req := signed("billing", "new", "new-secret")
+			req.Header.Del(HeaderClient)
+			return req
+		}, "malformed signature headers"},
+		{"missing signature", func() *http.Request {
+			req := signed("billing", "new", "new-secret")
+			req.Header.Set(HeaderSignature, "t=1,k=new")
+			return req
+		}, "malformed signature headers"},
+		{"invalid timestamp", func() *http.Request {
+			req := signed("billing", "new", "new-secret")
+			req.Header.Set(HeaderSignature, strings.Replace(req.Header.Get(HeaderSignature), "t=", "t=x", 1))
+			return req
+		}, "invalid timestamp"},
+		{"outside tolerance", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/internal/charges?currency=PEN", strings.NewReader(body))
+			req.Header.Set(HeaderClient, "billing")
+			req.Header.Set(HeaderSignature, Sign("new", "new-secret", now.Add(-Tolerance-time.Second), req.Method, req.URL.RequestURI(), []byte(body)))
+			return req
+		}, "timestamp outside tolerance"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := tc.req()
+			client, err := VerifyRequest(context.Background(), req)
+			if tc.reason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if client.ID != "billing" {
+					t.Errorf("got client %q", client.ID)
+				}
+				if got, _ := io.ReadAll(req.Body); string(got) != body {
+					t.Errorf("body not restored for the handler: %q", got)
+				}
+				return
+			}
+			var sigErr *firebase.RequestSignatureError
+			if !errors.As(err, &sigErr) || !errors.Is(err, firebase.ErrRequestSignature) {
+				t.Fatalf("got %v, want RequestSignatureError", err)
+			}
+			if sigErr.Reason != tc.reason {
+				t.Errorf("got reason %q, want %q", sigErr.Reason, tc.reason)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	withClients(t, now, &Client{ID: "billing", PrimaryKey: "k", Keys: []Key{{ID: "k", Secret: "s"}}})
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, ok := ClientFromContext(r.Context())
+		if !ok {
+			t.Error("verified client missing from context")
+			return
+		}
+		w.Write([]byte(client.ID))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/internal/status", nil)
+	req.Header.Set(HeaderClient, "billing")
+	req.Header.Set(HeaderSignature, Sign("k", "s", now, req.Method, req.URL.RequestURI(), nil))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "billing" {
+		t.Errorf("signed request: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	req.Header.Set(HeaderSignature, "t="+strconv.FormatInt(now.Unix(), 10)+",k=k,v1=00")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature: got %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package firebase
+
+import "sync"
+
+// Eventos de la librería contados con RecordEvent
+const (
+	EventOTPIssued       = "otp_issued"
+	EventOTPLoginSuccess = "otp_login_success"
+	EventOTPLoginFailure = "otp_login_failure"
+
+	EventWebhookDelivered = "webhook_delivered"
+	EventWebhookFailed    = "webhook_failed" // agotó los intentos o no pudo encolarse
+	EventWebhookError     = "webhook_error"  // error interno (configuración, codificación)
+)
+
+// CacheCounter aciertos y fallos de una caché
+type CacheCounter struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+var (
+	statsMu     sync.Mutex
+	eventCounts = map[string]int64{}
+	cacheCounts = map[string]*CacheCounter{}
+)
+
+// RecordEvent incrementa el contador de un evento (ver EventOTPIssued y siguientes)
+func RecordEvent(name string) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	eventCounts[name]++
+}
+
+// RecordCacheLookup registra un acierto o fallo de la caché indicada
+func RecordCacheLookup(cache string, hit bool) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	counter, ok := cacheCounts[cache]
+	if !ok {
+		counter = &CacheCounter{}
+		cacheCounts[cache] = counter
+	}
+	if hit {
+		counter.Hits++
+	} else {
+		counter.Misses++
+	}
+}
+
+// EventCounts retorna una copia de los contadores de eventos desde el arranque
+func EventCounts() map[string]int64 {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	counts := make(map[string]int64, len(eventCounts))
+	for name, count := range eventCounts {
+		counts[name] = count
+	}
+	return counts
+}
+
+// CacheStats retorna una copia de los contadores de cada caché desde el arranque
+func CacheStats() map[string]CacheCounter {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats := make(map[string]CacheCounter, len(cacheCounts))
+	for name, counter := range cacheCounts {
+		stats[name] = *counter
+	}
+	return stats
+}
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // registra el decodificador GIF
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// ImagesCollection colección con los metadatos de las imágenes subidas
+const ImagesCollection = "images"
+
+// ImageOptions configura la validación y las variantes de una imagen
+type ImageOptions struct {
+	AllowedTypes []string // por defecto image/jpeg, image/png, image/gif
+	MaxBytes     int64    // por defecto 10 MiB
+	MaxPixels    int64    // ancho × alto máximo antes de decodificar, por defecto 40 megapíxeles
+	Sizes        []int    // lado mayor de cada miniatura, por defecto 64, 256 y 512
+	JPEGQuality  int      // por defecto 85
+	// Metadata metadatos de Cloud Storage del original y de cada variante (p. ej.
+	// firebaseStorageDownloadTokens para DownloadTokenURL)
+	Metadata map[string]string
+}
+
+// ImageVariant describe una variante (original o miniatura) de una imagen
+type ImageVariant struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"`
+}
+
+// ImageRecord metadatos de una imagen subida con sus variantes
+type ImageRecord struct {
+	ID          string         `json:"id"`
+	ContentType string         `json:"content_type"`
+	Original    ImageVariant   `json:"original"`
+	Variants    []ImageVariant `json:"variants"`
+}
+
+func (o ImageOptions) withDefaults() ImageOptions {
+	if len(o.AllowedTypes) == 0 {
+		o.AllowedTypes = []string{"image/jpeg", "image/png", "image/gif"}
+	}
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = 10 * 1024 * 1024
+	}
+	if o.MaxPixels <= 0 {
+		o.MaxPixels = 40_000_000
+	}
+	if len(o.Sizes) == 0 {
+		o.Sizes = []int{64, 256, 512}
+	}
+	if o.JPEGQuality <= 0 {
+		o.JPEGQuality = 85
+	}
+	return o
+}
+
+// UploadImage valida el tipo de contenido, sube el original, genera y sube las
+// miniaturas y guarda un documento de metadatos en ImagesCollection. name es la ruta
+// del original (por ejemplo "avatars/uid123.jpg"); las variantes se guardan a su lado.
+// Si algún paso falla se eliminan los objetos ya subidos.
+func UploadImage(ctx context.Context, name string, reader io.Reader, options ImageOptions) (record *ImageRecord, err error) {
+	options = options.withDefaults()
+
+	content, err := io.ReadAll(io.LimitReader(reader, options.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(content)) > options.MaxBytes {
+		return nil, fmt.Errorf("image exceeds maximum size of %d bytes", options.MaxBytes)
+	}
+
+	contentType := http.DetectContentType(content)
+	if !containsType(options.AllowedTypes, contentType) {
+		return nil, fmt.Errorf("content type %q is not allowed (allowed: %s)", contentType, strings.Join(options.AllowedTypes, ", "))
+	}
+
+	// Las dimensiones se leen de la cabecera para no reservar memoria para
+	// imágenes pequeñas en bytes pero enormes en píxeles.
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > options.MaxPixels {
+		return nil, fmt.Errorf("image dimensions %dx%d exceed maximum of %d pixels", config.Width, config.Height, options.MaxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var uploaded []string
+	defer func() {
+		if err != nil {
+			deleteFiles(context.WithoutCancel(ctx), uploaded)
+		}
+	}()
+
+	original, err := UploadFile(ctx, name, contentType, bytes.NewReader(content), options.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	uploaded = append(uploaded, name)
+	bounds := img.Bounds()
+	record = &ImageRecord{
+		ContentType: contentType,
+		Original: ImageVariant{
+			Name:   "original",
+			Path:   name,
+			Width:  bounds.Dx(),
+			Height: bounds.Dy(),
+			Size:   original.Size,
+		},
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for _, size := range options.Sizes {
+		thumb := Thumbnail(img, size)
+		encoded, variantType, variantExt, err := encodeImage(thumb, contentType, options.JPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+
+		variantPath := fmt.Sprintf("%s_%d%s", base, size, variantExt)
+		info, err := UploadFile(ctx, variantPath, variantType, bytes.NewReader(encoded), options.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		uploaded = append(uploaded, variantPath)
+		record.Variants = append(record.Variants, ImageVariant{
+			Name:   fmt.Sprintf("%d", size),
+			Path:   variantPath,
+			Width:  thumb.Bounds().Dx(),
+			Height: thumb.Bounds().Dy(),
+			Size:   info.Size,
+		})
+	}
+
+	variants := make([]interface{}, len(record.Variants))
+	for i, v := range record.Variants {
+		variants[i] = variantData(v)
+	}
+	record.ID, err = firestore.CreateDocument(ctx, ImagesCollection, map[string]interface{}{
+		"content_type": contentType,
+		"original":     variantData(record.Original),
+		"variants":     variants,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save image metadata: %w", err)
+	}
+	return record, nil
+}
+
+// DeleteImage elimina el documento de metadatos y después el original y las
+// variantes, para que nunca quede un documento apuntando a objetos borrados
+func DeleteImage(ctx context.Context, imageID string) error {
+	doc, err := firestore.GetDocument(ctx, ImagesCollection, imageID)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	if original, ok := doc.Data["original"].(map[string]interface{}); ok {
+		if p, ok := original["path"].(string); ok {
+			paths = append(paths, p)
+		}
+	}
+	if variants, ok := doc.Data["variants"].([]interface{}); ok {
+		for _, v := range variants {
+			if variant, ok := v.(map[string]interface{}); ok {
+				if p, ok := variant["path"].(string); ok {
+					paths = append(paths, p)
+				}
+			}
+		}
+	}
+
+	if err := firestore.DeleteDocument(ctx, ImagesCollection, imageID); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := DeleteFile(ctx, p); err != nil && !IsFileNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteFiles elimina objetos subidos por una operación fallida; los errores se
+// ignoran porque la operación ya retorna el error original
+func deleteFiles(ctx context.Context, paths []string) {
+	for _, p := range paths {
+		_ = DeleteFile(ctx, p)
+	}
+}
+
+// Thumbnail escala img para que su lado mayor mida como máximo maxSide (interpolación
+// bilineal). Las imágenes más pequeñas se retornan sin cambios.
+func Thumbnail(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	nw, nh := maxSide, maxSide
+	if w > h {
+		nh = max(1, h*maxSide/w)
+	} else {
+		nw = max(1, w*maxSide/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	xRatio := float64(w-1) / float64(max(nw-1, 1))
+	yRatio := float64(h-1) / float64(max(nh-1, 1))
+	for y := 0; y < nh; y++ {
+		sy := float64(y) * yRatio
+		y0 := int(sy)
+		y1 := min(y0+1, h-1)
+		fy := sy - float64(y0)
+		for x := 0; x < nw; x++ {
+			sx := float64(x) * xRatio
+			x0 := int(sx)
+			x1 := min(x0+1, w-1)
+			fx := sx - float64(x0)
+
+			c00 := color.RGBAModel.Convert(img.At(bounds.Min.X+x0, bounds.Min.Y+y0)).(color.RGBA)
+			c10 := color.RGBAModel.Convert(img.At(bounds.Min.X+x1, bounds.Min.Y+y0)).(color.RGBA)
+			c01 := color.RGBAModel.Convert(img.At(bounds.Min.X+x0, bounds.Min.Y+y1)).(color.RGBA)
+			c11 := color.RGBAModel.Convert(img.At(bounds.Min.X+x1, bounds.Min.Y+y1)).(color.RGBA)
+
+			lerp := func(a, b, c, d uint8) uint8 {
+				top := float64(a)*(1-fx) + float64(b)*fx
+				bottom := float64(c)*(1-fx) + float64(d)*fx
+				return uint8(top*(1-fy) + bottom*fy + 0.5)
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: lerp(c00.R, c10.R, c01.R, c11.R),
+				G: lerp(c00.G, c10.G, c01.G, c11.G),
+				B: lerp(c00.B, c10.B, c01.B, c11.B),
+				A: lerp(c00.A, c10.A, c01.A, c11.A),
+			})
+		}
+	}
+	return dst
+}
+
+// encodeImage codifica la variante en el formato del original (GIF se convierte a PNG)
+func encodeImage(img image.Image, contentType string, quality int) ([]byte, string, string, error) {
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", "", fmt.Errorf("failed to encode jpeg thumbnail: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", ".jpg", nil
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", "", fmt.Errorf("failed to encode png thumbnail: %w", err)
+		}
+		return buf.Bytes(), "image/png", ".png", nil
+	}
+}
+
+func variantData(v ImageVariant) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   v.Name,
+		"path":   v.Path,
+		"width":  v.Width,
+		"height": v.Height,
+		"size":   v.Size,
+	}
+}
+
+func containsType(types []string, contentType string) bool {
+	for _, t := range types {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/vision/v1"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// QuarantinePrefix prefijo donde se mueven los archivos marcados por un escáner
+const QuarantinePrefix = "quarantine/"
+
+// ScanVerdict resultado de un escáner
+type ScanVerdict string
+
+const (
+	VerdictClean    ScanVerdict = "clean"
+	VerdictInfected ScanVerdict = "infected"
+	VerdictFlagged  ScanVerdict = "flagged"
+)
+
+// ScanResult resultado de un escáner sobre un archivo
+type ScanResult struct {
+	Scanner string      `json:"scanner"`
+	Verdict ScanVerdict `json:"verdict"`
+	Reason  string      `json:"reason,omitempty"`
+}
+
+// ScanReport resultados de todos los escáneres sobre un archivo
+type ScanReport struct {
+	File    *FileInfo     `json:"file"`
+	Results []*ScanResult `json:"results"`
+}
+
+// Clean indica si ningún escáner marcó el archivo
+func (r *ScanReport) Clean() bool {
+	for _, result := range r.Results {
+		if result.Verdict != VerdictClean {
+			return false
+		}
+	}
+	return true
+}
+
+// Reason resume los motivos de los escáneres que marcaron el archivo
+func (r *ScanReport) Reason() string {
+	var reasons []string
+	for _, result := range r.Results {
+		if result.Verdict != VerdictClean {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", result.Scanner, result.Reason))
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// Scanner analiza un archivo subido (antivirus, moderación de contenido, etc.)
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, file *FileInfo, content io.Reader) (*ScanResult, error)
+}
+
+var (
+	scannersMu sync.RWMutex
+	scanners   []Scanner
+)
+
+// RegisterScanner registra un escáner que se ejecuta al completar cada subida
+func RegisterScanner(scanner Scanner) {
+	scannersMu.Lock()
+	defer scannersMu.Unlock()
+	scanners = append(scanners, scanner)
+}
+
+// ScanFile ejecuta todos los escáneres registrados sobre un archivo
+func ScanFile(ctx context.Context, file *FileInfo) (*ScanReport, error) {
+	scannersMu.RLock()
+	registered := append([]Scanner(nil), scanners...)
+	scannersMu.RUnlock()
+
+	report := &ScanReport{File: file}
+	for _, scanner := range registered {
+		reader, err := OpenFile(ctx, file.Name)
+		if err != nil {
+			return nil, err
+		}
+		result, err := scanner.Scan(ctx, file, reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("scanner %s failed on '%s': %w", scanner.Name(), file.Name, err)
+		}
+		result.Scanner = scanner.Name()
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// QuarantineFile mueve un archivo bajo QuarantinePrefix
+func QuarantineFile(ctx context.Context, path string) error {
+	if err := CopyFile(ctx, path, QuarantinePrefix+path); err != nil {
+		return err
+	}
+	return DeleteFile(ctx, path)
+}
+
+// flagDocument marca el documento asociado a un archivo en cuarentena
+func flagDocument(ctx context.Context, collection, docID, reason string) error {
+	err := firestore.UpdateDocument(ctx, collection, docID, map[string]interface{}{
+		"moderation_status": UploadQuarantined,
+		"moderation_reason": reason,
+		"moderated_at":      firebase.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to flag document '%s' in collection '%s': %w", docID, collection, err)
+	}
+	return nil
+}
+
+// ClamAVScanner escanea archivos con un servidor clamd usando el protocolo INSTREAM
+type ClamAVScanner struct {
+	Address string        // por ejemplo "localhost:3310"
+	Timeout time.Duration // por defecto 30 segundos
+}
+
+// Name identifica al escáner
+func (s *ClamAVScanner) Name() string { return "clamav" }
+
+// Scan envía el contenido a clamd y retorna infected si se detecta una firma
+func (s *ClamAVScanner) Scan(ctx context.Context, file *FileInfo, content io.Reader) (*ScanResult, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 32*1024)
+	size := make([]byte, 4)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	response := strings.TrimRight(string(reply), "\x00\n")
+	switch {
+	case strings.HasSuffix(response, "OK"):
+		return &ScanResult{Verdict: VerdictClean}, nil
+	case strings.HasSuffix(response, "FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(response, "stream: "), " FOUND")
+		return &ScanResult{Verdict: VerdictInfected, Reason: signature}, nil
+	}
+	return nil, fmt.Errorf("unexpected clamd reply: %s", response)
+}
+
+// SafeSearchScanner modera imágenes con Cloud Vision SafeSearch
+type SafeSearchScanner struct {
+	// MinLikelihood nivel a partir del cual se marca la imagen
+	// ("POSSIBLE", "LIKELY" o "VERY_LIKELY"; por defecto "LIKELY")
+	MinLikelihood string
+}
+
+// Name identifica al escáner
+func (s *SafeSearchScanner) Name() string { return "safesearch" }
+
+// Scan analiza la imagen directamente desde Cloud Storage; los archivos que no son
+// imágenes se consideran limpios
+func (s *SafeSearchScanner) Scan(ctx context.Context, file *FileInfo, content io.Reader) (*ScanResult, error) {
+	if !strings.HasPrefix(file.ContentType, "image/") {
+		return &ScanResult{Verdict: VerdictClean}, nil
+	}
+
+	service, err := vision.NewService(ctx, firebase.GetClientOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vision client: %w", err)
+	}
+
+	resp, err := service.Images.Annotate(&vision.BatchAnnotateImagesRequest{
+		Requests: []*vision.AnnotateImageRequest{{
+			Image:    &vision.Image{Source: &vision.ImageSource{GcsImageUri: fmt.Sprintf("gs://%s/%s", file.Bucket, file.Name)}},
+			Features: []*vision.Feature{{Type: "SAFE_SEARCH_DETECTION"}},
+		}},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("safe search request failed: %w", err)
+	}
+	if len(resp.Responses) == 0 || resp.Responses[0].SafeSearchAnnotation == nil {
+		return &ScanResult{Verdict: VerdictClean}, nil
+	}
+
+	annotation := resp.Responses[0].SafeSearchAnnotation
+	threshold := likelihoodRank(s.MinLikelihood)
+	if threshold == 0 {
+		threshold = likelihoodRank("LIKELY")
+	}
+
+	var flagged []string
+	for _, category := range []struct{ name, likelihood string }{
+		{"adult", annotation.Adult},
+		{"violence", annotation.Violence},
+		{"racy", annotation.Racy},
+	} {
+		if likelihoodRank(category.likelihood) >= threshold {
+			flagged = append(flagged, fmt.Sprintf("%s=%s", category.name, category.likelihood))
+		}
+	}
+	if len(flagged) > 0 {
+		return &ScanResult{Verdict: VerdictFlagged, Reason: strings.Join(flagged, ", ")}, nil
+	}
+	return &ScanResult{Verdict: VerdictClean}, nil
+}
+
+func likelihoodRank(likelihood string) int {
+	switch likelihood {
+	case "POSSIBLE":
+		return 3
+	case "LIKELY":
+		return 4
+	case "VERY_LIKELY":
+		return 5
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// FileInfo información de un archivo en Cloud Storage
+type FileInfo struct {
+	Bucket      string            `json:"bucket"`
+	Name        string            `json:"name"`
+	ContentType string            `json:"content_type"`
+	Size        int64             `json:"size"`
+	MD5         []byte            `json:"md5,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// UploadFile sube el contenido de reader al bucket por defecto
+func UploadFile(ctx context.Context, name, contentType string, reader io.Reader, metadata map[string]string) (*FileInfo, error) {
+	bucket, err := firebase.GetStorageBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	writer := bucket.Object(name).NewWriter(ctx)
+	writer.ContentType = contentType
+	writer.Metadata = metadata
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to upload file '%s': %w", name, err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to upload file '%s': %w", name, err)
+	}
+	return mapObjectAttrs(writer.Attrs()), nil
+}
+
+// DownloadFile descarga un archivo completo del bucket por defecto
+func DownloadFile(ctx context.Context, name string) ([]byte, error) {
+	reader, err := OpenFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file '%s': %w", name, err)
+	}
+	return content, nil
+}
+
+// OpenFile abre un archivo del bucket por defecto para lectura en streaming
+func OpenFile(ctx context.Context, name string) (io.ReadCloser, error) {
+	bucket, err := firebase.GetStorageBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := bucket.Object(name).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, gcs.ErrObjectNotExist) {
+			return nil, &firebase.FileNotFoundError{Bucket: firebase.GetStorageBucketName(), FileName: name}
+		}
+		return nil, fmt.Errorf("failed to open file '%s': %w", name, err)
+	}
+	return reader, nil
+}
+
+// GetFileInfo obtiene los metadatos de un archivo
+func GetFileInfo(ctx context.Context, name string) (*FileInfo, error) {
+	bucket, err := firebase.GetStorageBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := bucket.Object(name).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, gcs.ErrObjectNotExist) {
+			return nil, &firebase.FileNotFoundError{Bucket: firebase.GetStorageBucketName(), FileName: name}
+		}
+		return nil, fmt.Errorf("failed to get file info '%s': %w", name, err)
+	}
+	return mapObjectAttrs(attrs), nil
+}
+
+// DeleteFile elimina un archivo del bucket por defecto
+func DeleteFile(ctx context.Context, name string) error {
+	bucket, err := firebase.GetStorageBucket()
+	if err != nil {
+		return err
+	}
+
+	if err := bucket.Object(name).Delete(ctx); err != nil {
+		if errors.Is(err, gcs.ErrObjectNotExist) {
+			return &firebase.FileNotFoundError{Bucket: firebase.GetStorageBucketName(), FileName: name}
+		}
+		return fmt.Errorf("failed to delete file '%s': %w", name, err)
+	}
+	return nil
+}
+
+// CopyFile copia un archivo dentro del bucket por defecto
+func CopyFile(ctx context.Context, source, destination string) error {
+	bucket, err := firebase.GetStorageBucket()
+	if err != nil {
+		return err
+	}
+
+	if _, err := bucket.Object(destination).CopierFrom(bucket.Object(source)).Run(ctx); err != nil {
+		return fmt.Errorf("failed to copy file '%s' to '%s': %w", source, destination, err)
+	}
+	return nil
+}
+
+// ListFiles lista los archivos con el prefijo indicado
+func ListFiles(ctx context.Context, prefix string) ([]*FileInfo, error) {
+	bucket, err := firebase.GetStorageBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	iter := bucket.Objects(ctx, &gcs.Query{Prefix: prefix})
+	var files []*FileInfo
+	for {
+		attrs, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files with prefix '%s': %w", prefix, err)
+		}
+		files = append(files, mapObjectAttrs(attrs))
+	}
+	return files, nil
+}
+
+// DownloadURL genera una URL V4 firmada para descargar un archivo del bucket por defecto
+// durante expires (máximo 7 días)
+func DownloadURL(name string, expires time.Duration) (string, error) {
+	bucket, err := firebase.GetStorageBucket()
+	if err != nil {
+		return "", err
+	}
+
+	url, err := bucket.SignedURL(name, &gcs.SignedURLOptions{
+		Scheme:  gcs.SigningSchemeV4,
+		Method:  http.MethodGet,
+		Expires: firebase.Now().Add(expires),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign download URL for '%s': %w", name, err)
+	}
+	return url, nil
+}
+
+// DownloadTokenURL URL de descarga de Firebase (sin vencimiento) de un archivo subido con
+// el metadato firebaseStorageDownloadTokens = token
+func DownloadTokenURL(name, token string) string {
+	return fmt.Sprintf("https://firebasestorage.googleapis.com/v0/b/%s/o/%s?alt=media&token=%s",
+		firebase.GetStorageBucketName(), url.PathEscape(name), url.QueryEscape(token))
+}
+
+// mapObjectAttrs convierte gcs.ObjectAttrs a FileInfo
+func mapObjectAttrs(attrs *gcs.ObjectAttrs) *FileInfo {
+	if attrs == nil {
+		return nil
+	}
+	return &FileInfo{
+		Bucket:      attrs.Bucket,
+		Name:        attrs.Name,
+		ContentType: attrs.ContentType,
+		Size:        attrs.Size,
+		MD5:         attrs.MD5,
+		Metadata:    attrs.Metadata,
+		CreatedAt:   attrs.Created,
+		UpdatedAt:   attrs.Updated,
+	}
+}
+
+// IsFileNotFound indica si el error corresponde a un archivo inexistente
+func IsFileNotFound(err error) bool {
+	var notFound *firebase.FileNotFoundError
+	return errors.As(err, &notFound) || errors.Is(err, gcs.ErrObjectNotExist)
+}
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	pathpkg "path"
+	"strings"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+	gcs "cloud.google.com/go/storage"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// PendingUploadsCollection colección con las subidas directas emitidas
+const PendingUploadsCollection = "pending_uploads"
+
+// UploadsPrefix carpeta raíz de las subidas directas; cada usuario solo puede subir a
+// uploads/{uid}/
+const UploadsPrefix = "uploads/"
+
+// Estados de una subida directa
+const (
+	UploadPending     = "pending"
+	UploadVerifying   = "verifying" // CompleteUpload en curso
+	UploadCompleted   = "completed"
+	UploadRejected    = "rejected"
+	UploadQuarantined = "quarantined"
+)
+
+// UploadConstraints restricciones de una URL de subida firmada
+type UploadConstraints struct {
+	ContentType string        // obligatorio: el cliente debe enviar exactamente este Content-Type
+	MaxBytes    int64         // tamaño máximo, por defecto 10 MiB
+	Expires     time.Duration // validez de la URL, por defecto 15 minutos
+
+	// Documento de Firestore asociado al archivo; se marca si el escaneo lo pone en cuarentena
+	LinkedCollection string
+	LinkedDocumentID string
+}
+
+// UploadURL URL firmada para que el cliente suba un archivo directamente a Cloud Storage
+type UploadURL struct {
+	UploadID  string            `json:"upload_id"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Headers   map[string]string `json:"headers"` // cabeceras que el cliente debe enviar
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// CreateUploadURL genera una URL V4 firmada para un PUT directo a Cloud Storage con
+// restricciones de tipo y tamaño, y registra la subida pendiente asociada a la sesión.
+// La ruta se ubica siempre bajo uploads/{uid}/ (ver UserUploadPath).
+func CreateUploadURL(ctx context.Context, session *firebase.SessionInfo, path string, constraints UploadConstraints) (*UploadURL, error) {
+	if session == nil || session.UID == "" {
+		return nil, firebase.ErrUnauthenticated
+	}
+	if path == "" || constraints.ContentType == "" {
+		return nil, fmt.Errorf("upload path and content type are required")
+	}
+	path, err := UserUploadPath(session.UID, path)
+	if err != nil {
+		return nil, err
+	}
+	if constraints.MaxBytes <= 0 {
+		constraints.MaxBytes = 10 * 1024 * 1024
+	}
+	if constraints.Expires <= 0 {
+		constraints.Expires = 15 * time.Minute
+	}
+
+	bucket, err := firebase.GetStorageBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := firebase.Now().Add(constraints.Expires)
+	lengthRange := fmt.Sprintf("x-goog-content-length-range:0,%d", constraints.MaxBytes)
+	url, err := bucket.SignedURL(path, &gcs.SignedURLOptions{
+		Scheme:      gcs.SigningSchemeV4,
+		Method:      http.MethodPut,
+		ContentType: constraints.ContentType,
+		Headers:     []string{lengthRange},
+		Expires:     expiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign upload URL for '%s': %w", path, err)
+	}
+
+	uploadID, err := firestore.CreateDocument(ctx, PendingUploadsCollection, map[string]interface{}{
+		"uid":                session.UID,
+		"path":               path,
+		"bucket":             firebase.GetStorageBucketName(),
+		"content_type":       constraints.ContentType,
+		"max_bytes":          constraints.MaxBytes,
+		"status":             UploadPending,
+		"expires_at":         expiresAt,
+		"linked_collection":  constraints.LinkedCollection,
+		"linked_document_id": constraints.LinkedDocumentID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record pending upload: %w", err)
+	}
+
+	return &UploadURL{
+		UploadID: uploadID,
+		URL:      url,
+		Method:   http.MethodPut,
+		Path:     path,
+		Headers: map[string]string{
+			"Content-Type":                constraints.ContentType,
+			"x-goog-content-length-range": fmt.Sprintf("0,%d", constraints.MaxBytes),
+		},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// UserUploadPath normaliza la ruta de una subida bajo uploads/{uid}/: una ruta que ya
+// está en la carpeta del usuario se mantiene y cualquier otra se ubica dentro de ella
+func UserUploadPath(uid, path string) (string, error) {
+	if uid == "" || strings.Contains(uid, "/") {
+		return "", fmt.Errorf("invalid upload owner '%s'", uid)
+	}
+	prefix := UploadsPrefix + uid + "/"
+	cleaned := strings.TrimPrefix(pathpkg.Clean("/"+path), "/")
+	if cleaned == "" || cleaned+"/" == prefix {
+		return "", fmt.Errorf("upload path '%s' must name a file", path)
+	}
+	if !strings.HasPrefix(cleaned, prefix) {
+		cleaned = prefix + cleaned
+	}
+	return cleaned, nil
+}
+
+// CompleteUpload verifica que el archivo de una subida pendiente exista y cumpla las
+// restricciones, y marca la subida como completada (o rechazada, eliminando el archivo).
+// Después ejecuta los escáneres registrados: si alguno marca el archivo, se mueve a
+// cuarentena y se marca el documento asociado. La subida pasa a verifying en una
+// transacción, por lo que dos llamadas concurrentes no la procesan dos veces.
+func CompleteUpload(ctx context.Context, session *firebase.SessionInfo, uploadID string) (*FileInfo, error) {
+	if session == nil || session.UID == "" {
+		return nil, firebase.ErrUnauthenticated
+	}
+
+	doc, err := claimUpload(ctx, session.UID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	path, _ := doc.Data["path"].(string)
+	info, err := GetFileInfo(ctx, path)
+	if err != nil {
+		// El archivo aún no se subió (o falló la lectura): se puede reintentar
+		if releaseErr := transitionUpload(ctx, uploadID, UploadVerifying, UploadPending, ""); releaseErr != nil {
+			return nil, releaseErr
+		}
+		return nil, err
+	}
+
+	contentType, _ := doc.Data["content_type"].(string)
+	maxBytes, _ := doc.Data["max_bytes"].(int64)
+	if info.ContentType != contentType || (maxBytes > 0 && info.Size > maxBytes) {
+		if err := DeleteFile(ctx, path); err != nil && !IsFileNotFound(err) {
+			return nil, err
+		}
+		if err := transitionUpload(ctx, uploadID, UploadVerifying, UploadRejected, "constraints not met"); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("upload '%s' does not match its constraints", uploadID)
+	}
+
+	report, err := ScanFile(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Clean() {
+		reason := report.Reason()
+		if err := QuarantineFile(ctx, path); err != nil {
+			return nil, err
+		}
+		if err := transitionUpload(ctx, uploadID, UploadVerifying, UploadQuarantined, reason); err != nil {
+			return nil, err
+		}
+		linkedCollection, _ := doc.Data["linked_collection"].(string)
+		linkedID, _ := doc.Data["linked_document_id"].(string)
+		if linkedCollection != "" && linkedID != "" {
+			if err := flagDocument(ctx, linkedCollection, linkedID, reason); err != nil {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("upload '%s' was quarantined: %s", uploadID, reason)
+	}
+
+	if err := transitionUpload(ctx, uploadID, UploadVerifying, UploadCompleted, ""); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// claimUpload pasa la subida de pending a verifying si pertenece al usuario
+func claimUpload(ctx context.Context, uid, uploadID string) (*firebase.Document, error) {
+	ref := firestore.DocRefContext(ctx, PendingUploadsCollection, uploadID)
+	var doc *firebase.Document
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		doc = &firebase.Document{ID: uploadID, Data: snap.Data()}
+		if owner, _ := doc.Data["uid"].(string); owner != uid {
+			return &firebase.PermissionDeniedError{Collection: PendingUploadsCollection, DocumentID: uploadID, Operation: firebase.OperationUpdate}
+		}
+		if status, _ := doc.Data["status"].(string); status != UploadPending {
+			return fmt.Errorf("upload '%s' is already %s", uploadID, status)
+		}
+		return tx.Update(ref, []gfirestore.Update{
+			{Path: "status", Value: UploadVerifying},
+			{Path: "updated_at", Value: firebase.Now()},
+		})
+	})
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, &firebase.DocumentNotFoundError{Collection: PendingUploadsCollection, DocumentID: uploadID}
+		}
+		return nil, err
+	}
+	return doc, nil
+}
+
+// transitionUpload cambia el estado de la subida solo si sigue en from
+func transitionUpload(ctx context.Context, uploadID, from, to, reason string) error {
+	ref := firestore.DocRefContext(ctx, PendingUploadsCollection, uploadID)
+	err := firestore.RunTransaction(ctx, func(ctx context.Context, tx *gfirestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if status, _ := snap.Data()["status"].(string); status != from {
+			return fmt.Errorf("upload '%s' is %s, expected %s", uploadID, status, from)
+		}
+		now := firebase.Now()
+		updates := []gfirestore.Update{{Path: "status", Value: to}, {Path: "updated_at", Value: now}}
+		if to != UploadPending {
+			updates = append(updates, gfirestore.Update{Path: "completed_at", Value: now})
+		}
+		if reason != "" {
+			updates = append(updates, gfirestore.Update{Path: "reason", Value: reason})
+		}
+		return tx.Update(ref, updates)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update upload '%s': %w", uploadID, err)
+	}
+	return nil
+}
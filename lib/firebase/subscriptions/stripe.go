@@ -0,0 +1,210 @@
+package subscriptions
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// StripeEventsCollection eventos de Stripe ya procesados (idempotencia de reintentos)
+const StripeEventsCollection = "stripe_events"
+
+// StripeSignatureTolerance antigüedad máxima aceptada del timestamp de la firma
+var StripeSignatureTolerance = 5 * time.Minute
+
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object stripeSubscription `json:"object"`
+	} `json:"data"`
+}
+
+type stripeSubscription struct {
+	ID                string            `json:"id"`
+	Customer          string            `json:"customer"`
+	Status            string            `json:"status"`
+	CurrentPeriodEnd  int64             `json:"current_period_end"`
+	TrialEnd          int64             `json:"trial_end"`
+	CancelAtPeriodEnd bool              `json:"cancel_at_period_end"`
+	Metadata          map[string]string `json:"metadata"`
+	Items             struct {
+		Data []struct {
+			Price struct {
+				ID string `json:"id"`
+			} `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// HandleStripeWebhook verifica la firma (cabecera Stripe-Signature, secreto en
+// STRIPE_WEBHOOK_SECRET) y aplica los eventos customer.subscription.* al documento de
+// suscripción. El titular se toma de metadata.subject_id de la suscripción de Stripe y
+// el plan, del plan cuyo stripe_price_id coincide con el precio. Los eventos repetidos
+// o más antiguos que el último aplicado se ignoran.
+func HandleStripeWebhook(ctx context.Context, payload []byte, signatureHeader string) error {
+	if err := verifyStripeSignature(payload, signatureHeader, os.Getenv("STRIPE_WEBHOOK_SECRET")); err != nil {
+		return err
+	}
+
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("failed to decode stripe event: %w", err)
+	}
+	if !strings.HasPrefix(event.Type, "customer.subscription.") {
+		return nil
+	}
+
+	processed, err := firestore.DocumentExists(ctx, StripeEventsCollection, event.ID)
+	if err != nil {
+		return err
+	}
+	if processed {
+		return nil
+	}
+
+	object := event.Data.Object
+	subjectID := object.Metadata["subject_id"]
+	if subjectID == "" {
+		return fmt.Errorf("stripe subscription '%s' has no subject_id metadata", object.ID)
+	}
+
+	current, err := GetSubscription(ctx, subjectID)
+	if err != nil && !firestore.IsNotFound(err) {
+		return err
+	}
+	if current == nil {
+		current = &Subscription{SubjectID: subjectID}
+	}
+
+	// Stripe no garantiza el orden de entrega
+	lastEvent, err := lastStripeEvent(ctx, subjectID)
+	if err != nil {
+		return err
+	}
+	if event.Created >= lastEvent {
+		if err := applyStripeSubscription(ctx, current, event, object); err != nil {
+			return err
+		}
+	}
+
+	return firestore.CreateDocumentWithID(ctx, StripeEventsCollection, event.ID, map[string]interface{}{
+		"type":       event.Type,
+		"subject_id": subjectID,
+	})
+}
+
+func applyStripeSubscription(ctx context.Context, sub *Subscription, event stripeEvent, object stripeSubscription) error {
+	if len(object.Items.Data) > 0 {
+		planID, err := planForPrice(ctx, object.Items.Data[0].Price.ID)
+		if err != nil {
+			return err
+		}
+		sub.PlanID = planID
+	}
+
+	sub.Status = object.Status
+	if event.Type == "customer.subscription.deleted" {
+		sub.Status = StatusCanceled
+	}
+	if object.TrialEnd > 0 {
+		sub.TrialEndsAt = time.Unix(object.TrialEnd, 0).UTC()
+	}
+	if object.CurrentPeriodEnd > 0 {
+		sub.CurrentPeriodEnd = time.Unix(object.CurrentPeriodEnd, 0).UTC()
+	}
+	sub.CancelAtPeriodEnd = object.CancelAtPeriodEnd
+	sub.StripeCustomerID = object.Customer
+	sub.StripeSubscriptionID = object.ID
+
+	if err := saveSubscription(ctx, sub); err != nil {
+		return err
+	}
+	return firestore.UpdateDocument(ctx, SubscriptionsCollection, sub.SubjectID, map[string]interface{}{
+		"stripe_event_created": event.Created,
+	})
+}
+
+func lastStripeEvent(ctx context.Context, subjectID string) (int64, error) {
+	doc, err := firestore.GetDocument(ctx, SubscriptionsCollection, subjectID)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	created, _ := doc.GetInt("stripe_event_created")
+	return created, nil
+}
+
+func planForPrice(ctx context.Context, priceID string) (string, error) {
+	docs, err := firestore.QueryDocuments(ctx, PlansCollection, firebase.QueryOptions{
+		Filters: []firebase.QueryFilter{{Field: "stripe_price_id", Operator: firebase.OpEqual, Value: priceID}},
+		Limit:   1,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find plan for stripe price '%s': %w", priceID, err)
+	}
+	if len(docs) == 0 {
+		return "", fmt.Errorf("no plan found for stripe price '%s'", priceID)
+	}
+	return docs[0].ID, nil
+}
+
+// verifyStripeSignature valida la cabecera "t=<timestamp>,v1=<firma>[,v1=...]": HMAC-SHA256
+// de "<timestamp>.<payload>" con el secreto del endpoint
+func verifyStripeSignature(payload []byte, header, secret string) error {
+	if secret == "" {
+		return &firebase.WebhookSignatureError{Provider: "stripe", Reason: "STRIPE_WEBHOOK_SECRET is not set"}
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return &firebase.WebhookSignatureError{Provider: "stripe", Reason: "malformed signature header"}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return &firebase.WebhookSignatureError{Provider: "stripe", Reason: "invalid timestamp"}
+	}
+	if age := firebase.Now().Sub(time.Unix(seconds, 0)); age > StripeSignatureTolerance || age < -StripeSignatureTolerance {
+		return &firebase.WebhookSignatureError{Provider: "stripe", Reason: "timestamp outside tolerance"}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	for _, signature := range signatures {
+		decoded, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return &firebase.WebhookSignatureError{Provider: "stripe", Reason: "no matching signature"}
+}
@@ -0,0 +1,71 @@
+package subscriptions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+func stripeSignature(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyStripeSignature(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	firebase.SetClock(firebase.NewFakeClock(now))
+	defer firebase.SetClock(nil)
+
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_1","type":"customer.subscription.updated"}`)
+	ts := now.Unix()
+	valid := stripeSignature(secret, ts, payload)
+	old := now.Add(-StripeSignatureTolerance - time.Second).Unix()
+
+	tests := []struct {
+		name    string
+		header  string
+		secret  string
+		payload []byte
+		reason  string // vacío si la firma es válida
+	}{
+		{"valid", fmt.Sprintf("t=%d,v1=%s", ts, valid), secret, payload, ""},
+		{"rotated secret", fmt.Sprintf("t=%d, v1=%s, v1=%s", ts, stripeSignature("old", ts, payload), valid), secret, payload, ""},
+		{"ignores v0", fmt.Sprintf("t=%d,v0=%s,v1=%s", ts, valid, valid), secret, payload, ""},
+		{"tampered payload", fmt.Sprintf("t=%d,v1=%s", ts, valid), secret, []byte(`{"id":"evt_2"}`), "no matching signature"},
+		{"wrong secret", fmt.Sprintf("t=%d,v1=%s", ts, valid), "whsec_other", payload, "no matching signature"},
+		{"non-hex signature", fmt.Sprintf("t=%d,v1=zz", ts), secret, payload, "no matching signature"},
+		{"only v0", fmt.Sprintf("t=%d,v0=%s", ts, valid), secret, payload, "malformed signature header"},
+		{"missing timestamp", "v1=" + valid, secret, payload, "malformed signature header"},
+		{"empty header", "", secret, payload, "malformed signature header"},
+		{"bad timestamp", "t=abc,v1=" + valid, secret, payload, "invalid timestamp"},
+		{"expired", fmt.Sprintf("t=%d,v1=%s", old, stripeSignature(secret, old, payload)), secret, payload, "timestamp outside tolerance"},
+		{"missing secret", fmt.Sprintf("t=%d,v1=%s", ts, valid), "", payload, "STRIPE_WEBHOOK_SECRET is not set"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyStripeSignature(tc.payload, tc.header, tc.secret)
+			if tc.reason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var sigErr *firebase.WebhookSignatureError
+			if !errors.As(err, &sigErr) {
+				t.Fatalf("got %v, want WebhookSignatureError", err)
+			}
+			if sigErr.Provider != "stripe" || sigErr.Reason != tc.reason {
+				t.Errorf("got %s/%q, want stripe/%q", sigErr.Provider, sigErr.Reason, tc.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+	"github.com/andrescris/firestore/lib/firebase/tenancy"
+)
+
+// Colecciones del módulo. El ID de una suscripción es su titular: el tenant de la sesión
+// o, sin tenancy, el UID del usuario.
+const (
+	PlansCollection         = "plans"
+	SubscriptionsCollection = "subscriptions"
+)
+
+// Estados de una suscripción (los mismos que usa Stripe)
+const (
+	StatusTrialing = "trialing"
+	StatusActive   = "active"
+	StatusPastDue  = "past_due"
+	StatusUnpaid   = "unpaid"
+	StatusCanceled = "canceled"
+)
+
+// GracePeriod tiempo tras el fin del periodo pagado durante el que una suscripción
+// past_due conserva sus funcionalidades
+var GracePeriod = 7 * 24 * time.Hour
+
+// Plan plan de suscripción
+type Plan struct {
+	ID            string           `json:"id"`
+	Name          string           `json:"name"`
+	Features      []string         `json:"features"`
+	Limits        map[string]int64 `json:"limits,omitempty"`
+	TrialDays     int              `json:"trial_days"`
+	StripePriceID string           `json:"stripe_price_id,omitempty"`
+}
+
+// Subscription estado de la suscripción de un titular
+type Subscription struct {
+	SubjectID            string    `json:"subject_id"`
+	PlanID               string    `json:"plan_id"`
+	Status               string    `json:"status"`
+	TrialEndsAt          time.Time `json:"trial_ends_at,omitempty"`
+	CurrentPeriodEnd     time.Time `json:"current_period_end,omitempty"`
+	CancelAtPeriodEnd    bool      `json:"cancel_at_period_end"`
+	StripeCustomerID     string    `json:"stripe_customer_id,omitempty"`
+	StripeSubscriptionID string    `json:"stripe_subscription_id,omitempty"`
+}
+
+// Entitled indica si la suscripción da acceso a las funcionalidades de su plan en now:
+// trial vigente, activa dentro del periodo, o past_due dentro del periodo de gracia
+func (s *Subscription) Entitled(now time.Time) bool {
+	switch s.Status {
+	case StatusTrialing:
+		return now.Before(s.TrialEndsAt)
+	case StatusActive:
+		return s.CurrentPeriodEnd.IsZero() || now.Before(s.CurrentPeriodEnd)
+	case StatusPastDue:
+		return now.Before(s.CurrentPeriodEnd.Add(GracePeriod))
+	}
+	return false
+}
+
+// SavePlan crea o reemplaza un plan
+func SavePlan(ctx context.Context, plan Plan) error {
+	err := firestore.CreateDocumentWithID(ctx, PlansCollection, plan.ID, map[string]interface{}{
+		"name":            plan.Name,
+		"features":        plan.Features,
+		"limits":          plan.Limits,
+		"trial_days":      plan.TrialDays,
+		"stripe_price_id": plan.StripePriceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save plan '%s': %w", plan.ID, err)
+	}
+	return nil
+}
+
+// GetPlan obtiene un plan por su ID
+func GetPlan(ctx context.Context, planID string) (*Plan, error) {
+	doc, err := firestore.GetDocument(ctx, PlansCollection, planID)
+	if err != nil {
+		return nil, err
+	}
+	return planFromDocument(doc), nil
+}
+
+// StartTrial inicia el periodo de prueba del plan para un titular
+func StartTrial(ctx context.Context, subjectID, planID string) (*Subscription, error) {
+	plan, err := GetPlan(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.TrialDays <= 0 {
+		return nil, fmt.Errorf("plan '%s' has no trial period", planID)
+	}
+
+	sub := &Subscription{
+		SubjectID:   subjectID,
+		PlanID:      planID,
+		Status:      StatusTrialing,
+		TrialEndsAt: firebase.Now().AddDate(0, 0, plan.TrialDays),
+	}
+	if err := saveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// GetSubscription obtiene la suscripción de un titular
+func GetSubscription(ctx context.Context, subjectID string) (*Subscription, error) {
+	doc, err := firestore.GetDocument(ctx, SubscriptionsCollection, subjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{SubjectID: doc.ID}
+	sub.PlanID, _ = doc.GetString("plan_id")
+	sub.Status, _ = doc.GetString("status")
+	sub.TrialEndsAt, _ = doc.GetTime("trial_ends_at")
+	sub.CurrentPeriodEnd, _ = doc.GetTime("current_period_end")
+	sub.CancelAtPeriodEnd, _ = doc.GetBool("cancel_at_period_end")
+	sub.StripeCustomerID, _ = doc.GetString("stripe_customer_id")
+	sub.StripeSubscriptionID, _ = doc.GetString("stripe_subscription_id")
+	return sub, nil
+}
+
+// SubjectFor titular de la suscripción de una sesión: el tenant (si lo hay) o el usuario
+func SubjectFor(ctx context.Context, session *firebase.SessionInfo) string {
+	if tenantID, ok := tenancy.TenantFromContext(firebase.WithSession(ctx, session)); ok {
+		return tenantID
+	}
+	return session.UID
+}
+
+// CanUseFeature indica si la suscripción del titular de la sesión incluye feature y da
+// acceso en este momento. Sin suscripción retorna false sin error.
+func CanUseFeature(ctx context.Context, session *firebase.SessionInfo, feature string) (bool, error) {
+	if session == nil {
+		return false, firebase.ErrUnauthenticated
+	}
+
+	sub, err := GetSubscription(ctx, SubjectFor(ctx, session))
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !sub.Entitled(firebase.Now()) {
+		return false, nil
+	}
+
+	plan, err := GetPlan(ctx, sub.PlanID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load plan '%s': %w", sub.PlanID, err)
+	}
+	for _, f := range plan.Features {
+		if f == feature {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func saveSubscription(ctx context.Context, sub *Subscription) error {
+	err := firestore.UpdateDocument(ctx, SubscriptionsCollection, sub.SubjectID, map[string]interface{}{
+		"plan_id":                sub.PlanID,
+		"status":                 sub.Status,
+		"trial_ends_at":          sub.TrialEndsAt,
+		"current_period_end":     sub.CurrentPeriodEnd,
+		"cancel_at_period_end":   sub.CancelAtPeriodEnd,
+		"stripe_customer_id":     sub.StripeCustomerID,
+		"stripe_subscription_id": sub.StripeSubscriptionID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save subscription '%s': %w", sub.SubjectID, err)
+	}
+	return nil
+}
+
+func planFromDocument(doc *firebase.Document) *Plan {
+	plan := &Plan{ID: doc.ID, Limits: map[string]int64{}}
+	plan.Name, _ = doc.GetString("name")
+	plan.Features, _ = doc.GetStringSlice("features")
+	plan.StripePriceID, _ = doc.GetString("stripe_price_id")
+	if days, ok := doc.GetInt("trial_days"); ok {
+		plan.TrialDays = int(days)
+	}
+	if limits, ok := doc.GetMap("limits"); ok {
+		for name := range limits {
+			if value, ok := doc.GetInt("limits." + name); ok {
+				plan.Limits[name] = value
+			}
+		}
+	}
+	return plan
+}
@@ -0,0 +1,409 @@
+package tenancy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/auth"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+// Estados de un tenant
+const (
+	StatusProvisioning   = "provisioning"
+	StatusActive         = "active"
+	StatusDeprovisioning = "deprovisioning"
+)
+
+// Pasos del aprovisionamiento; cada uno se registra en steps.<paso> al completarse, por lo
+// que repetir ProvisionTenant tras una falla continúa donde quedó
+const (
+	stepSeed  = "seed"
+	stepRoles = "roles"
+	stepOwner = "owner"
+)
+
+// DefaultRoles roles que se crean cuando la especificación no define ninguno
+var DefaultRoles = map[string][]string{
+	"owner":  {"*"},
+	"admin":  {"read", "write", "manage_users"},
+	"member": {"read", "write"},
+}
+
+// TenantSpec especificación de un tenant a aprovisionar
+type TenantSpec struct {
+	ID         string
+	Name       string
+	OwnerEmail string
+	OwnerRole  string              // por defecto "owner"
+	Region     string              // región de residencia (ver EnableResidency)
+	Roles      map[string][]string // rol -> permisos (por defecto DefaultRoles)
+	// Seed documentos iniciales por colección; el campo "id" (opcional) fija el ID
+	Seed     map[string][]map[string]interface{}
+	Metadata map[string]interface{}
+}
+
+// Tenant estado de un tenant
+type Tenant struct {
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Status   string                 `json:"status"`
+	OwnerUID string                 `json:"owner_uid,omitempty"`
+	Region   string                 `json:"region,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// DeprovisionOptions opciones de DeprovisionTenant
+type DeprovisionOptions struct {
+	// Export recibe cada documento (JSON por línea: path y data) antes de eliminarlo
+	Export io.Writer
+	// RevokeClaims quita el claim de tenant a los usuarios que lo tengan
+	RevokeClaims bool
+}
+
+// ProvisionTenant crea el documento del tenant, los documentos iniciales, los roles por
+// defecto y la invitación del propietario. Es reanudable: los pasos completados se
+// registran en el documento del tenant y no se repiten.
+func ProvisionTenant(ctx context.Context, spec TenantSpec) (*Tenant, error) {
+	if spec.ID == "" || strings.Contains(spec.ID, "/") {
+		return nil, fmt.Errorf("invalid tenant ID: %q", spec.ID)
+	}
+	if spec.OwnerRole == "" {
+		spec.OwnerRole = "owner"
+	}
+	if len(spec.Roles) == 0 {
+		spec.Roles = DefaultRoles
+	}
+
+	steps, err := startProvisioning(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if steps[stepSeed] == nil {
+		if err := seedTenant(ctx, spec); err != nil {
+			return nil, err
+		}
+		if err := completeStep(ctx, spec.ID, stepSeed, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if steps[stepRoles] == nil {
+		for role, permissions := range spec.Roles {
+			err := firestore.CreateDocumentWithID(WithTenant(ctx, spec.ID), CollectionPath(spec.ID, "roles"), role, map[string]interface{}{
+				"permissions": permissions,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create role '%s' for tenant '%s': %w", role, spec.ID, err)
+			}
+		}
+		if err := completeStep(ctx, spec.ID, stepRoles, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if steps[stepOwner] == nil && spec.OwnerEmail != "" {
+		ownerUID, err := inviteOwner(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		if err := completeStep(ctx, spec.ID, stepOwner, map[string]interface{}{"owner_uid": ownerUID}); err != nil {
+			return nil, err
+		}
+	}
+
+	err = firestore.UpdateDocumentFields(ctx, TenantsCollection, spec.ID, []gfirestore.Update{
+		{Path: "status", Value: StatusActive},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate tenant '%s': %w", spec.ID, err)
+	}
+	return GetTenant(ctx, spec.ID)
+}
+
+// GetTenant obtiene el estado de un tenant
+func GetTenant(ctx context.Context, tenantID string) (*Tenant, error) {
+	doc, err := firestore.GetDocument(ctx, TenantsCollection, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	tenant := &Tenant{ID: doc.ID}
+	tenant.Name, _ = doc.GetString("name")
+	tenant.Status, _ = doc.GetString("status")
+	tenant.OwnerUID, _ = doc.GetString("owner_uid")
+	tenant.Region, _ = doc.GetString("region")
+	tenant.Metadata, _ = doc.GetMap("metadata")
+	return tenant, nil
+}
+
+// DeprovisionTenant entrega los datos del tenant a options.Export (si se indica) y los
+// elimina recursivamente: el documento del tenant con sus subcolecciones y, con
+// StrategyField, los documentos de las colecciones particionadas que le pertenecen.
+func DeprovisionTenant(ctx context.Context, tenantID string, options DeprovisionOptions) error {
+	if tenantID == "" {
+		return fmt.Errorf("invalid tenant ID: %q", tenantID)
+	}
+	if err := firebase.CheckWritable(TenantsCollection); err != nil {
+		return err
+	}
+
+	err := firestore.UpdateDocumentFields(ctx, TenantsCollection, tenantID, []gfirestore.Update{
+		{Path: "status", Value: StatusDeprovisioning},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark tenant '%s' for deprovisioning: %w", tenantID, err)
+	}
+
+	// Con residencia, los datos del tenant están en su región y su documento en la base
+	// por defecto. El documento se elimina al final, para que un reintento tras una falla
+	// vuelva a encontrar el resto.
+	client := firebase.GetFirestoreClient()
+	dataClient := firebase.FirestoreClientFor(WithTenant(ctx, tenantID), "")
+
+	var dataRoots []*gfirestore.DocumentRef
+	if dataClient != client {
+		dataRoots = append(dataRoots, dataClient.Collection(TenantsCollection).Doc(tenantID))
+	}
+	if config := currentConfig(); config != nil && config.Strategy == StrategyField {
+		for _, collection := range config.Collections {
+			refs, err := dataClient.Collection(collection).Where(config.Field, "==", tenantID).Documents(ctx).GetAll()
+			if err != nil {
+				return fmt.Errorf("failed to list tenant documents in '%s': %w", collection, err)
+			}
+			for _, snap := range refs {
+				dataRoots = append(dataRoots, snap.Ref)
+			}
+		}
+	}
+
+	if err := sweep(ctx, dataClient, dataRoots, options.Export); err != nil {
+		return err
+	}
+	if err := sweep(ctx, client, []*gfirestore.DocumentRef{client.Collection(TenantsCollection).Doc(tenantID)}, options.Export); err != nil {
+		return err
+	}
+
+	if options.RevokeClaims {
+		report, err := auth.BulkSetClaims(ctx, auth.ClaimSelector{Claim: tenantClaim(), Value: tenantID},
+			map[string]interface{}{tenantClaim(): nil}, auth.BulkClaimsOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to revoke tenant claims: %w", err)
+		}
+		if len(report.Failed) > 0 {
+			return fmt.Errorf("failed to revoke tenant claims for %d users", len(report.Failed))
+		}
+	}
+	return nil
+}
+
+// startProvisioning crea el documento del tenant (o lo retoma) y retorna los pasos completados
+func startProvisioning(ctx context.Context, spec TenantSpec) (map[string]interface{}, error) {
+	doc, err := firestore.GetDocument(ctx, TenantsCollection, spec.ID)
+	if err == nil {
+		steps, _ := doc.GetMap("steps")
+		if steps == nil {
+			steps = map[string]interface{}{}
+		}
+		return steps, nil
+	}
+	if !firestore.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to load tenant '%s': %w", spec.ID, err)
+	}
+
+	err = firestore.CreateDocumentWithID(ctx, TenantsCollection, spec.ID, map[string]interface{}{
+		"name":        spec.Name,
+		"status":      StatusProvisioning,
+		"owner_email": spec.OwnerEmail,
+		"metadata":    spec.Metadata,
+		"region":      spec.Region,
+		"steps":       map[string]interface{}{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant '%s': %w", spec.ID, err)
+	}
+
+	tenantRegionsMu.Lock()
+	tenantRegions[spec.ID] = spec.Region
+	tenantRegionsMu.Unlock()
+	return map[string]interface{}{}, nil
+}
+
+func completeStep(ctx context.Context, tenantID, step string, fields map[string]interface{}) error {
+	updates := []gfirestore.Update{{Path: "steps." + step, Value: firebase.Now()}}
+	for field, value := range fields {
+		updates = append(updates, gfirestore.Update{Path: field, Value: value})
+	}
+	if err := firestore.UpdateDocumentFields(ctx, TenantsCollection, tenantID, updates); err != nil {
+		return fmt.Errorf("failed to record step '%s' for tenant '%s': %w", step, tenantID, err)
+	}
+	return nil
+}
+
+// seedTenant escribe los documentos iniciales con IDs estables, de modo que repetir el
+// paso sobrescribe en lugar de duplicar
+func seedTenant(ctx context.Context, spec TenantSpec) error {
+	tenantCtx := WithTenant(ctx, spec.ID)
+	config := currentConfig()
+
+	for collection, docs := range spec.Seed {
+		target := collection
+		if config == nil || config.Strategy == StrategyPrefix || !IsScoped(collection) {
+			target = CollectionPath(spec.ID, collection)
+		}
+
+		for i, seed := range docs {
+			data := make(map[string]interface{}, len(seed))
+			for key, value := range seed {
+				data[key] = value
+			}
+			docID, _ := data["id"].(string)
+			delete(data, "id")
+			if docID == "" {
+				docID = fmt.Sprintf("seed-%d", i)
+			}
+
+			if err := firestore.CreateDocumentWithID(tenantCtx, target, docID, data); err != nil {
+				return fmt.Errorf("failed to seed '%s' for tenant '%s': %w", collection, spec.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// inviteOwner invita al propietario (o reutiliza su cuenta si ya existe) y le asigna el
+// claim del tenant y su rol. Una cuenta existente que ya pertenece a otro tenant, o que
+// tiene otro rol sin pertenecer a este, se rechaza: los claims solo admiten un tenant y
+// sobrescribirlos la sacaría del anterior.
+func inviteOwner(ctx context.Context, spec TenantSpec) (string, error) {
+	var uid string
+	if user, err := auth.GetUserByEmail(ctx, spec.OwnerEmail); err == nil {
+		uid = user.UID
+		resolved, err := auth.ResolveClaims(ctx, uid)
+		if err != nil {
+			return "", fmt.Errorf("failed to read claims of owner of tenant '%s': %w", spec.ID, err)
+		}
+		current, _ := resolved.Claims[tenantClaim()].(string)
+		role, _ := resolved.Claims["role"].(string)
+		switch {
+		case current != "" && current != spec.ID:
+			return "", fmt.Errorf("owner '%s' already belongs to tenant '%s'", spec.OwnerEmail, current)
+		case current == "" && role != "" && role != spec.OwnerRole:
+			return "", fmt.Errorf("owner '%s' already has role '%s'", spec.OwnerEmail, role)
+		}
+	} else {
+		invitation, err := auth.InviteUser(ctx, spec.OwnerEmail, spec.OwnerRole)
+		if err != nil {
+			return "", fmt.Errorf("failed to invite owner of tenant '%s': %w", spec.ID, err)
+		}
+		uid = invitation.UID
+	}
+
+	err := auth.UpdateClaims(ctx, uid, map[string]interface{}{
+		tenantClaim(): spec.ID,
+		"role":        spec.OwnerRole,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to set owner claims for tenant '%s': %w", spec.ID, err)
+	}
+	return uid, nil
+}
+
+// sweep exporta y elimina recursivamente los documentos indicados de un cliente. Espera
+// el resultado de cada eliminación y retorna la primera falla con el total de fallidas.
+func sweep(ctx context.Context, client *gfirestore.Client, roots []*gfirestore.DocumentRef, export io.Writer) error {
+	if len(roots) == 0 {
+		return nil
+	}
+	writer := client.BulkWriter(ctx)
+	defer writer.End()
+	var jobs []*gfirestore.BulkWriterJob
+	for _, root := range roots {
+		if err := exportAndDelete(ctx, writer, root, export, &jobs); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	var first error
+	failed := 0
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	if first != nil {
+		return fmt.Errorf("failed to delete %d of %d documents: %w", failed, len(jobs), first)
+	}
+	return nil
+}
+
+// exportAndDelete recorre el documento y sus subcolecciones (hijos primero), entrega
+// cada documento a export y lo elimina
+func exportAndDelete(ctx context.Context, writer *gfirestore.BulkWriter, ref *gfirestore.DocumentRef, export io.Writer, jobs *[]*gfirestore.BulkWriterJob) error {
+	collections := ref.Collections(ctx)
+	for {
+		child, err := collections.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list subcollections of '%s': %w", ref.Path, err)
+		}
+
+		docs := child.DocumentRefs(ctx)
+		for {
+			docRef, err := docs.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to list documents of '%s': %w", child.Path, err)
+			}
+			if err := exportAndDelete(ctx, writer, docRef, export, jobs); err != nil {
+				return err
+			}
+		}
+	}
+
+	if export != nil {
+		snap, err := ref.Get(ctx)
+		if err != nil && !firestore.IsNotFound(err) {
+			return fmt.Errorf("failed to read '%s' for export: %w", ref.Path, err)
+		}
+		if snap != nil && snap.Exists() {
+			line, err := json.Marshal(map[string]interface{}{"path": documentPath(ref), "data": snap.Data()})
+			if err != nil {
+				return fmt.Errorf("failed to encode '%s' for export: %w", ref.Path, err)
+			}
+			if _, err := export.Write(append(line, '\n')); err != nil {
+				return fmt.Errorf("failed to export '%s': %w", ref.Path, err)
+			}
+		}
+	}
+
+	job, err := writer.Delete(ref)
+	if err != nil {
+		return fmt.Errorf("failed to delete '%s': %w", ref.Path, err)
+	}
+	*jobs = append(*jobs, job)
+	return nil
+}
+
+// documentPath ruta relativa a la base de datos ("tenants/acme/roles/owner")
+func documentPath(ref *gfirestore.DocumentRef) string {
+	if i := strings.Index(ref.Path, "/documents/"); i >= 0 {
+		return ref.Path[i+len("/documents/"):]
+	}
+	return ref.Path
+}
@@ -0,0 +1,84 @@
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+var (
+	tenantRegionsMu sync.RWMutex
+	tenantRegions   = map[string]string{}
+)
+
+// EnableResidency enruta las operaciones de cada tenant a la región guardada en el campo
+// region de su documento (las regiones se registran con firebase.RegisterRegion). Los
+// documentos de la colección tenants quedan en la base de datos por defecto; sus
+// subcolecciones y los datos del tenant van a su región.
+func EnableResidency() {
+	firebase.SetRegionResolver(resolveTenantRegion)
+}
+
+// DisableResidency deja de enrutar por tenant
+func DisableResidency() {
+	firebase.SetRegionResolver(nil)
+	tenantRegionsMu.Lock()
+	tenantRegions = map[string]string{}
+	tenantRegionsMu.Unlock()
+}
+
+// SetTenantRegion asigna la región de un tenant. No migra datos existentes: debe hacerse
+// antes de escribir datos del tenant (TenantSpec.Region lo hace al aprovisionar).
+func SetTenantRegion(ctx context.Context, tenantID, region string) error {
+	err := firestore.UpdateDocumentFields(ctx, TenantsCollection, tenantID, []gfirestore.Update{
+		{Path: "region", Value: region},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set region for tenant '%s': %w", tenantID, err)
+	}
+
+	tenantRegionsMu.Lock()
+	tenantRegions[tenantID] = region
+	tenantRegionsMu.Unlock()
+	return nil
+}
+
+// resolveTenantRegion lee la región del documento del tenant (con caché en memoria). Un
+// error al leerlo provoca panic, como una región sin registrar: continuar en la base de
+// datos por defecto escribiría datos del tenant fuera de su región. Un tenant inexistente
+// no se guarda en caché, porque puede aprovisionarse después con su región.
+func resolveTenantRegion(ctx context.Context, collection string) (string, bool) {
+	if collection == TenantsCollection {
+		return "", false
+	}
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	tenantRegionsMu.RLock()
+	region, cached := tenantRegions[tenantID]
+	tenantRegionsMu.RUnlock()
+	if cached {
+		return region, region != ""
+	}
+
+	snap, err := firebase.GetFirestoreClient().Collection(TenantsCollection).Doc(tenantID).Get(ctx)
+	if err != nil && !firestore.IsNotFound(err) {
+		panic(fmt.Sprintf("failed to resolve region for tenant '%s': %v", tenantID, err))
+	}
+	if !snap.Exists() {
+		return "", false
+	}
+	region, _ = snap.Data()["region"].(string)
+
+	tenantRegionsMu.Lock()
+	tenantRegions[tenantID] = region
+	tenantRegionsMu.Unlock()
+	return region, region != ""
+}
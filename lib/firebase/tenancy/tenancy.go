@@ -0,0 +1,243 @@
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	gfirestore "cloud.google.com/go/firestore"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+)
+
+// Strategy forma de particionar los datos de cada tenant
+type Strategy string
+
+const (
+	// StrategyPrefix guarda cada colección bajo tenants/{id}/{colección}
+	StrategyPrefix Strategy = "prefix"
+	// StrategyField guarda el tenant en un campo y lo agrega como filtro a cada consulta
+	StrategyField Strategy = "field"
+)
+
+// TenantsCollection colección raíz de StrategyPrefix
+const TenantsCollection = firebase.TenantsCollection
+
+// Config configuración de la capa de tenancy
+type Config struct {
+	Strategy Strategy
+	// Collections colecciones particionadas; el resto (incluidas las internas de la
+	// librería, como sesiones y OTPs) no se modifica
+	Collections []string
+	// Claim claim de la sesión con el ID del tenant (por defecto "tenant_id")
+	Claim string
+	// Field campo del documento con el tenant en StrategyField (por defecto "tenant_id")
+	Field string
+}
+
+var (
+	mu       sync.RWMutex
+	active   *Config
+	isScoped map[string]bool
+)
+
+type tenantContextKey struct{}
+type crossTenantContextKey struct{}
+
+// Enable activa el aislamiento por tenant en las llamadas del paquete firestore. Las
+// operaciones sobre colecciones particionadas sin tenant en el contexto se rechazan.
+// Las transacciones (RunTransaction/DocRef) no se particionan: usar CollectionPath.
+func Enable(config Config) error {
+	if config.Strategy != StrategyPrefix && config.Strategy != StrategyField {
+		return fmt.Errorf("unsupported tenancy strategy: %q", config.Strategy)
+	}
+	if config.Claim == "" {
+		config.Claim = "tenant_id"
+	}
+	if config.Field == "" {
+		config.Field = "tenant_id"
+	}
+
+	scoped := make(map[string]bool, len(config.Collections))
+	for _, collection := range config.Collections {
+		scoped[collection] = true
+	}
+
+	mu.Lock()
+	active = &config
+	isScoped = scoped
+	mu.Unlock()
+
+	firebase.RegisterInterceptor(firebase.Interceptor{Name: "tenancy", Before: scopeCall})
+	return nil
+}
+
+// Disable desactiva el aislamiento por tenant
+func Disable() {
+	firebase.UnregisterInterceptor("tenancy")
+	mu.Lock()
+	active = nil
+	isScoped = nil
+	mu.Unlock()
+}
+
+// WithTenant retorna un contexto con un tenant explícito (tiene prioridad sobre el claim
+// de la sesión; útil en tareas en segundo plano)
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// WithCrossTenantAccess retorna un contexto que omite el aislamiento (tareas
+// administrativas que recorren todos los tenants)
+func WithCrossTenantAccess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, crossTenantContextKey{}, true)
+}
+
+// TenantFromContext obtiene el tenant del contexto: el explícito de WithTenant o el claim
+// de la sesión
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if tenantID, ok := ctx.Value(tenantContextKey{}).(string); ok && tenantID != "" {
+		return tenantID, true
+	}
+
+	if session, ok := firebase.SessionFromContext(ctx); ok {
+		if tenantID, ok := session.Claims[tenantClaim()].(string); ok && tenantID != "" {
+			return tenantID, true
+		}
+	}
+	return "", false
+}
+
+// CollectionPath ruta de una colección dentro de un tenant (StrategyPrefix)
+func CollectionPath(tenantID, collection string) string {
+	return fmt.Sprintf("%s/%s/%s", TenantsCollection, tenantID, collection)
+}
+
+// IsScoped indica si la colección está particionada por tenant
+func IsScoped(collection string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return isScoped[collection]
+}
+
+// tenantClaim claim de la sesión con el tenant (el configurado o "tenant_id")
+func tenantClaim() string {
+	if config := currentConfig(); config != nil {
+		return config.Claim
+	}
+	return "tenant_id"
+}
+
+func currentConfig() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active
+}
+
+// scopeCall interceptor que reescribe la ruta o aplica el campo de tenant
+func scopeCall(ctx context.Context, call *firebase.Call) error {
+	if !strings.HasPrefix(call.Operation, "firestore.") || call.Operation == firebase.CallFirestoreTransaction {
+		return nil
+	}
+	config := currentConfig()
+	if config == nil || !IsScoped(call.Collection) {
+		return nil
+	}
+	if crossTenant, _ := ctx.Value(crossTenantContextKey{}).(bool); crossTenant {
+		return nil
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return &firebase.TenantRequiredError{Collection: call.Collection}
+	}
+
+	if config.Strategy == StrategyPrefix {
+		call.Collection = CollectionPath(tenantID, call.Collection)
+		return nil
+	}
+	return scopeByField(ctx, config.Field, tenantID, call)
+}
+
+// scopeByField agrega el filtro de tenant a las consultas, fija el campo en las escrituras
+// y rechaza el acceso a documentos de otro tenant
+func scopeByField(ctx context.Context, field, tenantID string, call *firebase.Call) error {
+	filter := firebase.QueryFilter{Field: field, Operator: firebase.OpEqual, Value: tenantID}
+	op := operationOf(call)
+
+	switch payload := call.Payload.(type) {
+	case *firebase.QueryOptions:
+		payload.Filters = append(payload.Filters, filter)
+		return nil
+	case *[]firebase.QueryFilter:
+		*payload = append(*payload, filter)
+		return nil
+	case map[string]interface{}:
+		if err := stampTenant(payload, field, tenantID, call, op); err != nil {
+			return err
+		}
+	case *firebase.BatchOperation:
+		if payload.Data != nil {
+			if err := stampTenant(payload.Data, field, tenantID, call, op); err != nil {
+				return err
+			}
+		}
+	case []gfirestore.Update:
+		for _, update := range payload {
+			if update.Path == field && update.Value != tenantID {
+				return denied(call, op)
+			}
+		}
+	}
+
+	if call.DocumentID == "" {
+		return nil
+	}
+	return checkStoredTenant(ctx, field, tenantID, call, op)
+}
+
+// stampTenant fija el campo de tenant en los datos a escribir (rechaza otro valor)
+func stampTenant(data map[string]interface{}, field, tenantID string, call *firebase.Call, op firebase.Operation) error {
+	if value, ok := data[field]; ok && value != tenantID {
+		return denied(call, op)
+	}
+	data[field] = tenantID
+	return nil
+}
+
+// checkStoredTenant verifica que el documento almacenado (si existe) sea del tenant. Lee
+// directamente con el cliente para no volver a pasar por los interceptores.
+func checkStoredTenant(ctx context.Context, field, tenantID string, call *firebase.Call, op firebase.Operation) error {
+	snap, err := firebase.FirestoreClientFor(ctx, call.Collection).Collection(call.Collection).Doc(call.DocumentID).Get(ctx)
+	if err != nil {
+		if snap != nil && !snap.Exists() {
+			return nil
+		}
+		return fmt.Errorf("failed to check tenant of document '%s': %w", call.DocumentID, err)
+	}
+	if owner, _ := snap.Data()[field].(string); owner != tenantID {
+		return denied(call, op)
+	}
+	return nil
+}
+
+func operationOf(call *firebase.Call) firebase.Operation {
+	switch call.Operation {
+	case firebase.CallFirestoreCreate:
+		return firebase.OperationCreate
+	case firebase.CallFirestoreUpdate:
+		return firebase.OperationUpdate
+	case firebase.CallFirestoreDelete:
+		return firebase.OperationDelete
+	case firebase.CallFirestoreBatch:
+		if op, ok := call.Payload.(*firebase.BatchOperation); ok {
+			return firebase.Operation(op.Type)
+		}
+	}
+	return firebase.OperationRead
+}
+
+func denied(call *firebase.Call, op firebase.Operation) error {
+	return &firebase.PermissionDeniedError{Collection: call.Collection, DocumentID: call.DocumentID, Operation: op}
+}
@@ -8,28 +8,166 @@ type Document struct {
 	Data map[string]interface{} `json:"data"`
 }
 
+// Operator operador de comparación de un filtro de Firestore
+type Operator string
+
+const (
+	OpEqual              Operator = "=="
+	OpNotEqual           Operator = "!="
+	OpLessThan           Operator = "<"
+	OpLessThanOrEqual    Operator = "<="
+	OpGreaterThan        Operator = ">"
+	OpGreaterThanOrEqual Operator = ">="
+	OpIn                 Operator = "in"
+	OpNotIn              Operator = "not-in"
+	OpArrayContains      Operator = "array-contains"
+	OpArrayContainsAny   Operator = "array-contains-any"
+)
+
+// ValidOperators lista los operadores soportados por Firestore
+var ValidOperators = []Operator{
+	OpEqual, OpNotEqual, OpLessThan, OpLessThanOrEqual, OpGreaterThan,
+	OpGreaterThanOrEqual, OpIn, OpNotIn, OpArrayContains, OpArrayContainsAny,
+}
+
+// IsValid indica si el operador es soportado por Firestore
+func (o Operator) IsValid() bool {
+	for _, valid := range ValidOperators {
+		if o == valid {
+			return true
+		}
+	}
+	return false
+}
+
 // QueryFilter representa un filtro para consultas de Firestore
 type QueryFilter struct {
 	Field    string      `json:"field"`
-	Operator string      `json:"operator"` // "==", "!=", ">", ">=", "<", "<=", "in", "array-contains"
+	Operator Operator    `json:"operator"` // ver ValidOperators
 	Value    interface{} `json:"value"`
 }
 
+// OrderClause representa un criterio de ordenamiento
+type OrderClause struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction,omitempty"` // "asc" or "desc"
+}
+
 // QueryOptions representa opciones para consultas de Firestore
 type QueryOptions struct {
 	Filters  []QueryFilter `json:"filters,omitempty"`
 	OrderBy  string        `json:"order_by,omitempty"`
 	OrderDir string        `json:"order_dir,omitempty"` // "asc" or "desc"
+	Orders   []OrderClause `json:"orders,omitempty"`    // se aplican después de OrderBy
 	Limit    int           `json:"limit,omitempty"`
 	Offset   int           `json:"offset,omitempty"`
+	Cursor   string        `json:"cursor,omitempty"` // NextCursor de un QueryResult anterior
+}
+
+// QueryResult resultado de una consulta con metadatos de ejecución
+type QueryResult struct {
+	Documents     []*Document `json:"documents"`
+	DocumentsRead int         `json:"documents_read"`
+	ReadTime      time.Time   `json:"read_time"`
+	Truncated     bool        `json:"truncated"` // true si Limit dejó resultados fuera (hasMore)
+	NextCursor    string      `json:"next_cursor,omitempty"`
+}
+
+// QueryExplain plan y métricas de ejecución de una consulta (ver firestore.ExplainQuery)
+type QueryExplain struct {
+	IndexesUsed         []map[string]interface{} `json:"indexes_used"`
+	ResultsReturned     int64                    `json:"results_returned"`
+	ReadOperations      int64                    `json:"read_operations"` // lecturas facturadas
+	ExecutionDuration   time.Duration            `json:"execution_duration"`
+	DocumentsScanned    int64                    `json:"documents_scanned"`
+	IndexEntriesScanned int64                    `json:"index_entries_scanned"`
+	DebugStats          map[string]interface{}   `json:"debug_stats,omitempty"`
+	Warnings            []string                 `json:"warnings,omitempty"`
+}
+
+// DocumentChange cambio entregado por un listener (ver firestore.Listen). Type es
+// "added", "modified" o "removed".
+type DocumentChange struct {
+	Type     string    `json:"type"`
+	Document *Document `json:"document"`
+	ReadTime time.Time `json:"read_time"`
 }
 
+// OrderClauses retorna todos los criterios de ordenamiento (OrderBy seguido de Orders)
+func (o QueryOptions) OrderClauses() []OrderClause {
+	var clauses []OrderClause
+	if o.OrderBy != "" {
+		clauses = append(clauses, OrderClause{Field: o.OrderBy, Direction: o.OrderDir})
+	}
+	return append(clauses, o.Orders...)
+}
+
+// AggregateOp función de agregación
+type AggregateOp string
+
+const (
+	AggCount AggregateOp = "count"
+	AggSum   AggregateOp = "sum"
+	AggAvg   AggregateOp = "avg"
+	AggMin   AggregateOp = "min"
+	AggMax   AggregateOp = "max"
+)
+
+// Aggregation define una agregación sobre un campo (Field se ignora para count)
+type Aggregation struct {
+	Op    AggregateOp `json:"op"`
+	Field string      `json:"field,omitempty"`
+	Alias string      `json:"alias"`
+}
+
+// AggregateGroup resultado de las agregaciones para un grupo (Key es nil sin GroupBy)
+type AggregateGroup struct {
+	Key    interface{}        `json:"key"`
+	Count  int64              `json:"count"`
+	Values map[string]float64 `json:"values"`
+}
+
+// Operation tipo de operación sobre un documento (usado por las políticas de acceso)
+type Operation string
+
+const (
+	OperationRead   Operation = "read"
+	OperationCreate Operation = "create"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+)
+
 // BatchOperation representa una operación en lote para Firestore
 type BatchOperation struct {
-	Type       string                 `json:"type"`        // "create", "update", "delete"
+	Type       string                 `json:"type"`        // "create", "update", "delete", "check" (solo AtomicWrite)
 	Collection string                 `json:"collection"`
 	DocumentID string                 `json:"document_id,omitempty"`
 	Data       map[string]interface{} `json:"data,omitempty"`
+	// MustExist y Preconditions se verifican sobre el documento almacenado (solo AtomicWrite)
+	MustExist     bool          `json:"must_exist,omitempty"`
+	Preconditions []QueryFilter `json:"preconditions,omitempty"`
+}
+
+// BatchRef valor que, dentro de Data de una operación de un lote, se reemplaza por el ID
+// del documento de la operación con ese índice (p. ej. para enlazar una línea de pedido
+// con el pedido creado en el mismo lote)
+type BatchRef int
+
+// BatchOptions opciones de un lote. Con BestEffort cada operación se confirma por
+// separado y las fallas individuales no abortan el resto. MaxConcurrency limita los
+// fragmentos que se confirman en paralelo cuando el lote se divide (por defecto 4).
+type BatchOptions struct {
+	BestEffort     bool `json:"best_effort,omitempty"`
+	MaxConcurrency int  `json:"max_concurrency,omitempty"`
+}
+
+// BatchResult resultado de una operación de un lote
+type BatchResult struct {
+	Index      int       `json:"index"`
+	Success    bool      `json:"success"`
+	DocumentID string    `json:"document_id,omitempty"`
+	WriteTime  time.Time `json:"write_time,omitempty"`
+	Error      error     `json:"-"`
 }
 
 // UserRecord información de usuario de Auth
@@ -63,6 +201,31 @@ type UpdateUserRequest struct {
 	CustomClaims  map[string]interface{} `json:"custom_claims,omitempty"`
 }
 
+// SessionInfo información de una sesión válida
+type SessionInfo struct {
+	UID       string                 `json:"uid"`
+	Email     string                 `json:"email"`
+	Active    bool                   `json:"active"`
+	Claims    map[string]interface{} `json:"claims,omitempty"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	// ImpersonatedBy UID del administrador si la sesión es una suplantación
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+	// SessionID y AuthTime (última verificación de identidad) para step-up auth
+	SessionID string    `json:"session_id,omitempty"`
+	AuthTime  time.Time `json:"auth_time"`
+	// ClaimSources almacén del que proviene cada claim
+	ClaimSources map[string]ClaimSource `json:"claim_sources,omitempty"`
+}
+
+// ClaimSource almacén del que proviene un claim
+type ClaimSource string
+
+const (
+	ClaimSourceAuth      ClaimSource = "auth"      // custom claims de Firebase Auth
+	ClaimSourceFirestore ClaimSource = "firestore" // documento user_claims
+	ClaimSourceBoth      ClaimSource = "both"      // presente con el mismo valor en ambos
+)
+
 // RequestOTPRequest solicitud para pedir un OTP
 type RequestOTPRequest struct {
 	Email string `json:"email"`
@@ -74,6 +237,17 @@ type LoginWithOTPRequest struct {
 	OTP   string `json:"otp"`
 }
 
+// ActionCodeSettings configuración de los enlaces de acción (reset, verificación, sign-in)
+type ActionCodeSettings struct {
+	ContinueURL           string `json:"continue_url"`
+	DynamicLinkDomain     string `json:"dynamic_link_domain,omitempty"`
+	HandleCodeInApp       bool   `json:"handle_code_in_app,omitempty"`
+	IOSBundleID           string `json:"ios_bundle_id,omitempty"`
+	AndroidPackageName    string `json:"android_package_name,omitempty"`
+	AndroidMinimumVersion string `json:"android_minimum_version,omitempty"`
+	AndroidInstallApp     bool   `json:"android_install_app,omitempty"`
+}
+
 // IncrementValue representa un valor de incremento para Firestore
 type IncrementValue int
 
@@ -0,0 +1,605 @@
+// Package webhooks notifica a sistemas externos los cambios de documentos hechos a través
+// del paquete. Cada webhook se configura en WebhooksCollection con la colección, la URL,
+// un secreto y los eventos que le interesan; al crear, actualizar o eliminar un documento
+// de esa colección se envía un POST JSON firmado con HMAC-SHA256 en la cabecera
+// X-Webhook-Signature ("t=<unix>,v1=<hex>", firmando "<t>.<cuerpo>").
+//
+//	webhooks.Enable()
+//	hook, _ := webhooks.CreateWebhook(ctx, webhooks.Webhook{
+//		Collection: "orders",
+//		URL:        "https://erp.example.com/hooks/orders",
+//		Events:     []string{webhooks.EventCreated, webhooks.EventUpdated},
+//	})
+//	// hook.Secret se comparte con el receptor, que valida con VerifySignature
+//
+// Los envíos son asíncronos: una cola de QueueSize eventos atendida por Workers
+// goroutines, con reintentos y backoff exponencial. Los que agotan los intentos, o no caben
+// en la cola, se guardan en DeliveriesCollection para reenviarlos con RetryFailed. Los
+// resultados se cuentan con firebase.RecordEvent y los errores se entregan a OnError. Con
+// tenancy por prefijo la colección de los eventos es la ruta física.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gfirestore "cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	firebase "github.com/andrescris/firestore/lib/firebase"
+	"github.com/andrescris/firestore/lib/firebase/firestore"
+)
+
+const (
+	// WebhooksCollection colección con la configuración de los webhooks
+	WebhooksCollection = "webhooks"
+	// DeliveriesCollection colección con los envíos que agotaron sus intentos
+	DeliveriesCollection = "webhook_deliveries"
+)
+
+// Tipos de evento
+const (
+	EventCreated = "document.created"
+	EventUpdated = "document.updated"
+	EventDeleted = "document.deleted"
+)
+
+// Estados de un envío guardado
+const (
+	DeliveryFailed    = "failed"
+	DeliveryDelivered = "delivered"
+)
+
+var (
+	// MaxAttempts intentos de cada envío antes de guardarlo como fallido
+	MaxAttempts = 5
+	// RetryBackoff espera antes del primer reintento; se duplica en cada intento
+	RetryBackoff = time.Second
+	// CacheTTL tiempo que se reutiliza la configuración leída de una colección
+	CacheTTL = time.Minute
+	// SignatureTolerance antigüedad máxima de la firma aceptada por VerifySignature
+	SignatureTolerance = 5 * time.Minute
+	// HTTPClient cliente usado para los envíos
+	HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	// Workers goroutines que atienden la cola de envíos (se lee al iniciarla)
+	Workers = 8
+	// QueueSize eventos pendientes admitidos antes de guardarlos directamente como fallidos
+	QueueSize = 1000
+	// OnError recibe los errores de los envíos asíncronos (nil = solo se cuentan)
+	OnError func(err error)
+)
+
+// Webhook configuración de un webhook de salida
+type Webhook struct {
+	ID          string    `json:"id"`
+	Collection  string    `json:"collection"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events,omitempty"` // vacío = todos
+	Active      bool      `json:"active"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Handles indica si el webhook recibe el tipo de evento
+func (w *Webhook) Handles(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Event cuerpo de cada envío. Data contiene los campos escritos (codificados con
+// firestore.EncodeJSONValue); en las actualizaciones solo los modificados.
+type Event struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Collection string                 `json:"collection"`
+	DocumentID string                 `json:"document_id"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+type cachedWebhooks struct {
+	hooks     []*Webhook
+	expiresAt time.Time
+}
+
+type delivery struct {
+	ctx   context.Context
+	hook  *Webhook
+	event *Event
+}
+
+var (
+	cacheMu  sync.Mutex
+	cache    = map[string]cachedWebhooks{}
+	inflight sync.WaitGroup
+
+	queueOnce sync.Once
+	queue     chan delivery
+)
+
+// Enable registra el interceptor que dispara los webhooks e inicia la cola de envíos
+func Enable() {
+	startQueue()
+	firebase.RegisterInterceptor(firebase.Interceptor{Name: "webhooks", After: dispatch})
+}
+
+// startQueue crea la cola y sus Workers una sola vez por proceso
+func startQueue() {
+	queueOnce.Do(func() {
+		workers, size := max(Workers, 1), max(QueueSize, 1)
+		queue = make(chan delivery, size)
+		for i := 0; i < workers; i++ {
+			go func() {
+				for job := range queue {
+					deliver(job.ctx, job.hook, job.event)
+					inflight.Done()
+				}
+			}()
+		}
+	})
+}
+
+// reportError cuenta el error y lo entrega a OnError
+func reportError(event string, err error) {
+	firebase.RecordEvent(event)
+	if OnError != nil {
+		OnError(err)
+	}
+}
+
+// Disable quita el interceptor
+func Disable() {
+	firebase.UnregisterInterceptor("webhooks")
+}
+
+// Wait espera a que terminen los envíos encolados y en curso (p. ej. antes de apagar el
+// proceso)
+func Wait() {
+	inflight.Wait()
+}
+
+// CreateWebhook guarda un webhook activo. Si no trae Secret se genera uno, que se
+// retorna en el resultado.
+func CreateWebhook(ctx context.Context, hook Webhook) (*Webhook, error) {
+	if hook.Collection == "" || !strings.HasPrefix(hook.URL, "http://") && !strings.HasPrefix(hook.URL, "https://") {
+		return nil, fmt.Errorf("webhook requires a collection and an http(s) URL")
+	}
+	for _, event := range hook.Events {
+		if event != EventCreated && event != EventUpdated && event != EventDeleted {
+			return nil, fmt.Errorf("unknown webhook event '%s'", event)
+		}
+	}
+	if hook.Secret == "" {
+		secret, err := randomHex(32)
+		if err != nil {
+			return nil, err
+		}
+		hook.Secret = "whsec_" + secret
+	}
+	hook.Active = true
+	hook.CreatedAt = firebase.Now()
+
+	ref := firebase.GetFirestoreClient().Collection(WebhooksCollection).NewDoc()
+	if _, err := ref.Create(ctx, map[string]interface{}{
+		"collection":  hook.Collection,
+		"url":         hook.URL,
+		"secret":      hook.Secret,
+		"events":      hook.Events,
+		"active":      true,
+		"description": hook.Description,
+		"created_at":  hook.CreatedAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	hook.ID = ref.ID
+	invalidate(hook.Collection)
+	return &hook, nil
+}
+
+// SetActive activa o pausa un webhook
+func SetActive(ctx context.Context, id string, active bool) error {
+	hook, err := GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := firebase.GetFirestoreClient().Collection(WebhooksCollection).Doc(id).Update(ctx, []gfirestore.Update{
+		{Path: "active", Value: active},
+	}); err != nil {
+		return fmt.Errorf("failed to update webhook '%s': %w", id, err)
+	}
+	invalidate(hook.Collection)
+	return nil
+}
+
+// DeleteWebhook elimina un webhook
+func DeleteWebhook(ctx context.Context, id string) error {
+	hook, err := GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := firebase.GetFirestoreClient().Collection(WebhooksCollection).Doc(id).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete webhook '%s': %w", id, err)
+	}
+	invalidate(hook.Collection)
+	return nil
+}
+
+// GetWebhook obtiene un webhook por ID
+func GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	snap, err := firebase.GetFirestoreClient().Collection(WebhooksCollection).Doc(id).Get(ctx)
+	if err != nil {
+		if firestore.IsNotFound(err) {
+			return nil, &firebase.DocumentNotFoundError{Collection: WebhooksCollection, DocumentID: id}
+		}
+		return nil, fmt.Errorf("failed to get webhook '%s': %w", id, err)
+	}
+	return webhookFromSnapshot(snap), nil
+}
+
+// ListWebhooks lista los webhooks de una colección (todas si collection es vacío)
+func ListWebhooks(ctx context.Context, collection string) ([]*Webhook, error) {
+	query := firebase.GetFirestoreClient().Collection(WebhooksCollection).Query
+	if collection != "" {
+		query = query.Where("collection", "==", collection)
+	}
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+	var hooks []*Webhook
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list webhooks: %w", err)
+		}
+		hooks = append(hooks, webhookFromSnapshot(snap))
+	}
+	return hooks, nil
+}
+
+func webhookFromSnapshot(snap *gfirestore.DocumentSnapshot) *Webhook {
+	doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+	hook := &Webhook{ID: doc.ID}
+	hook.Collection, _ = doc.GetString("collection")
+	hook.URL, _ = doc.GetString("url")
+	hook.Secret, _ = doc.GetString("secret")
+	hook.Events, _ = doc.GetStringSlice("events")
+	hook.Active, _ = doc.GetBool("active")
+	hook.Description, _ = doc.GetString("description")
+	hook.CreatedAt, _ = doc.GetTime("created_at")
+	return hook
+}
+
+// activeWebhooks retorna los webhooks activos de la colección (con caché de CacheTTL)
+func activeWebhooks(ctx context.Context, collection string) ([]*Webhook, error) {
+	cacheMu.Lock()
+	cached, ok := cache[collection]
+	cacheMu.Unlock()
+	hit := ok && firebase.Now().Before(cached.expiresAt)
+	firebase.RecordCacheLookup("webhooks", hit)
+	if hit {
+		return cached.hooks, nil
+	}
+
+	all, err := ListWebhooks(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []*Webhook
+	for _, hook := range all {
+		if hook.Active {
+			hooks = append(hooks, hook)
+		}
+	}
+
+	cacheMu.Lock()
+	cache[collection] = cachedWebhooks{hooks: hooks, expiresAt: firebase.Now().Add(CacheTTL)}
+	cacheMu.Unlock()
+	return hooks, nil
+}
+
+func invalidate(collection string) {
+	cacheMu.Lock()
+	delete(cache, collection)
+	cacheMu.Unlock()
+}
+
+// dispatch convierte las escrituras exitosas en eventos y los envía a los webhooks de la
+// colección
+func dispatch(ctx context.Context, call *firebase.Call, err error) {
+	if err != nil || call.DocumentID == "" || call.Collection == WebhooksCollection || call.Collection == DeliveriesCollection {
+		return
+	}
+	event := eventFromCall(call)
+	if event == nil {
+		return
+	}
+	hooks, err := activeWebhooks(ctx, firebase.LogicalCollection(call.Collection))
+	if err != nil {
+		reportError(firebase.EventWebhookError, fmt.Errorf("failed to load webhooks for '%s': %w", call.Collection, err))
+		return
+	}
+	startQueue()
+	ctx = context.WithoutCancel(ctx)
+	for _, hook := range hooks {
+		if !hook.Handles(event.Type) {
+			continue
+		}
+		inflight.Add(1)
+		select {
+		case queue <- delivery{ctx: ctx, hook: hook, event: event}:
+		default:
+			// Cola llena: no se bloquea la escritura; el envío queda para RetryFailed
+			inflight.Done()
+			withID, body, err := encodeEvent(event)
+			if err != nil {
+				reportError(firebase.EventWebhookError, err)
+				continue
+			}
+			saveFailed(ctx, hook, withID, body, 0, fmt.Errorf("webhook queue is full"))
+		}
+	}
+}
+
+func eventFromCall(call *firebase.Call) *Event {
+	event := &Event{Collection: call.Collection, DocumentID: call.DocumentID, OccurredAt: firebase.Now()}
+	var data interface{}
+	switch call.Operation {
+	case firebase.CallFirestoreCreate:
+		event.Type, data = EventCreated, call.Payload
+	case firebase.CallFirestoreUpdate:
+		event.Type, data = EventUpdated, call.Payload
+	case firebase.CallFirestoreDelete:
+		event.Type = EventDeleted
+	case firebase.CallFirestoreBatch:
+		op, ok := call.Payload.(*firebase.BatchOperation)
+		if !ok {
+			return nil
+		}
+		switch op.Type {
+		case "create":
+			event.Type = EventCreated
+		case "update":
+			event.Type = EventUpdated
+		case "delete":
+			event.Type = EventDeleted
+		default:
+			return nil
+		}
+		data = op.Data
+	default:
+		return nil
+	}
+
+	switch v := data.(type) {
+	case map[string]interface{}:
+		event.Data = make(map[string]interface{}, len(v))
+		for key, value := range v {
+			event.Data[key] = firestore.EncodeJSONValue(value)
+		}
+	case []gfirestore.Update:
+		event.Data = make(map[string]interface{}, len(v))
+		for _, update := range v {
+			path := update.Path
+			if path == "" {
+				path = strings.Join(update.FieldPath, ".")
+			}
+			event.Data[path] = firestore.EncodeJSONValue(update.Value)
+		}
+	}
+	if event.Type == EventDeleted {
+		event.Data = nil
+	}
+	return event
+}
+
+// deliver envía el evento reintentando con backoff exponencial; si agota los intentos lo
+// guarda en DeliveriesCollection
+func deliver(ctx context.Context, hook *Webhook, event *Event) {
+	withID, body, err := encodeEvent(event)
+	if err != nil {
+		reportError(firebase.EventWebhookError, err)
+		return
+	}
+
+	backoff := RetryBackoff
+	attempts := 0
+	for attempts < MaxAttempts {
+		attempts++
+		if err = send(ctx, hook, withID.ID, withID.Type, body); err == nil {
+			firebase.RecordEvent(firebase.EventWebhookDelivered)
+			return
+		}
+		if attempts == MaxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff *= 2
+	}
+
+	saveFailed(ctx, hook, withID, body, attempts, err)
+}
+
+// encodeEvent asigna el ID del envío y codifica el cuerpo
+func encodeEvent(event *Event) (*Event, []byte, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate webhook event ID: %w", err)
+	}
+	withID := *event
+	withID.ID = "evt_" + id
+	body, err := json.Marshal(withID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	return &withID, body, nil
+}
+
+// saveFailed guarda el envío en DeliveriesCollection para RetryFailed
+func saveFailed(ctx context.Context, hook *Webhook, event *Event, body []byte, attempts int, cause error) {
+	reportError(firebase.EventWebhookFailed, fmt.Errorf("webhook '%s' failed after %d attempts: %w", hook.ID, attempts, cause))
+	if _, err := firebase.GetFirestoreClient().Collection(DeliveriesCollection).Doc(event.ID).Set(ctx, map[string]interface{}{
+		"webhook_id":      hook.ID,
+		"collection":      event.Collection,
+		"document_id":     event.DocumentID,
+		"type":            event.Type,
+		"body":            string(body),
+		"status":          DeliveryFailed,
+		"attempts":        attempts,
+		"last_error":      cause.Error(),
+		"last_attempt_at": firebase.Now(),
+	}); err != nil {
+		reportError(firebase.EventWebhookError, fmt.Errorf("failed to save webhook delivery '%s': %w", event.ID, err))
+	}
+}
+
+// send hace un POST firmado del cuerpo a la URL del webhook
+func send(ctx context.Context, hook *Webhook, eventID, eventType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", eventID)
+	req.Header.Set("X-Webhook-Event", eventType)
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, firebase.Now(), body))
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RetryFailed reenvía (una vez) los envíos fallidos guardados. Retorna cuántos se
+// entregaron.
+func RetryFailed(ctx context.Context) (int, error) {
+	iter := firebase.GetFirestoreClient().Collection(DeliveriesCollection).
+		Where("status", "==", DeliveryFailed).Documents(ctx)
+	defer iter.Stop()
+	delivered := 0
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return delivered, fmt.Errorf("failed to list webhook deliveries: %w", err)
+		}
+		doc := &firebase.Document{ID: snap.Ref.ID, Data: snap.Data()}
+		webhookID, _ := doc.GetString("webhook_id")
+		eventType, _ := doc.GetString("type")
+		body, _ := doc.GetString("body")
+		attempts, _ := doc.GetInt("attempts")
+
+		hook, err := GetWebhook(ctx, webhookID)
+		if err != nil {
+			if firestore.IsNotFound(err) {
+				continue
+			}
+			return delivered, err
+		}
+		update := []gfirestore.Update{
+			{Path: "attempts", Value: attempts + 1},
+			{Path: "last_attempt_at", Value: firebase.Now()},
+		}
+		if sendErr := send(ctx, hook, doc.ID, eventType, []byte(body)); sendErr != nil {
+			update = append(update, gfirestore.Update{Path: "last_error", Value: sendErr.Error()})
+		} else {
+			update = append(update, gfirestore.Update{Path: "status", Value: DeliveryDelivered})
+			delivered++
+		}
+		if _, err := snap.Ref.Update(ctx, update); err != nil {
+			return delivered, fmt.Errorf("failed to update webhook delivery '%s': %w", doc.ID, err)
+		}
+	}
+	return delivered, nil
+}
+
+// Sign calcula la cabecera X-Webhook-Signature del cuerpo
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature valida la cabecera X-Webhook-Signature de un envío recibido (para
+// receptores escritos en Go)
+func VerifySignature(body []byte, header, secret string) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return &firebase.WebhookSignatureError{Provider: "webhooks", Reason: "malformed signature header"}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return &firebase.WebhookSignatureError{Provider: "webhooks", Reason: "invalid timestamp"}
+	}
+	if age := firebase.Now().Sub(time.Unix(seconds, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return &firebase.WebhookSignatureError{Provider: "webhooks", Reason: "timestamp outside tolerance"}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		decoded, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return &firebase.WebhookSignatureError{Provider: "webhooks", Reason: "no matching signature"}
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
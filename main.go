@@ -73,7 +73,7 @@ func main() {
 	if err != nil || len(otpDocs) == 0 {
 		log.Fatalf("No se pudo obtener el OTP para el ejemplo.")
 	}
-	simulatedOTP := otpDocs[0].Data["otp"].(string)
+	simulatedOTP, _ := otpDocs[0].GetString("otp")
 	log.Printf("🤫 (Simulación) OTP obtenido para el ejemplo: %s", simulatedOTP)
 
 